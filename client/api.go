@@ -0,0 +1,780 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+func idQuery(key string, id uint64) url.Values {
+	return url.Values{key: {strconv.FormatUint(id, 10)}}
+}
+
+// -------------------- 用户 --------------------
+
+// Register 注册
+func (c *Client) Register(ctx context.Context, req service.RegisterReq) error {
+	return c.post(ctx, "/user/register", nil, req, nil)
+}
+
+// SendVerifyCode 发送验证码（purpose: register/forgot_password/login）；服务端开启 Debug 时结果中带验证码
+func (c *Client) SendVerifyCode(ctx context.Context, purpose, identifier, captchaToken string) (*service.SendCodeResult, error) {
+	var res service.SendCodeResult
+	body := map[string]string{"purpose": purpose, "identifier": identifier, "captcha_token": captchaToken}
+	if err := c.post(ctx, "/user/code/send", nil, body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Login 登录，成功后后续请求自动携带返回的 token
+func (c *Client) Login(ctx context.Context, req service.LoginReq) (*service.LoginResp, error) {
+	var resp service.LoginResp
+	if err := c.post(ctx, "/user/login", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.token, c.userID = resp.Token, resp.User.ID
+	c.mu.Unlock()
+	return &resp, nil
+}
+
+// IntrospectToken 查询当前 token 的用户、过期时间与登录设备
+func (c *Client) IntrospectToken(ctx context.Context) (*service.TokenInfo, error) {
+	var info service.TokenInfo
+	if err := c.get(ctx, "/user/token", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Logout 退出登录（all=true 时所有设备下线），成功后清空本地 token；绑定该 token 的 WS 连接会被服务端断开
+func (c *Client) Logout(ctx context.Context, all bool) error {
+	if err := c.post(ctx, "/user/logout", url.Values{"all": {strconv.FormatBool(all)}}, nil, nil); err != nil {
+		return err
+	}
+	c.SetToken("")
+	return nil
+}
+
+// LogoutDevice 注销当前设备上登录的所有账号（登录时需携带同一 device_id），成功后清空本地 token
+func (c *Client) LogoutDevice(ctx context.Context) error {
+	if err := c.post(ctx, "/user/logout", url.Values{"device": {"true"}}, nil, nil); err != nil {
+		return err
+	}
+	c.SetToken("")
+	return nil
+}
+
+// SwitchAccount 切换到同一设备上已登录的另一个账号（token 为该账号登录时返回的 token）。
+// 先换上新 token 再请求，服务端断开旧账号的 WS 连接后 Conn 自动用新 token 重连；失败时恢复原 token。
+func (c *Client) SwitchAccount(ctx context.Context, token string) (*service.AccountSwitchResp, error) {
+	c.mu.Lock()
+	from, fromUID := c.token, c.userID
+	c.token = token
+	c.mu.Unlock()
+
+	var resp service.AccountSwitchResp
+	if err := c.post(ctx, "/user/account/switch", nil, map[string]string{"from_token": from}, &resp); err != nil {
+		c.mu.Lock()
+		c.token, c.userID = from, fromUID
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.mu.Lock()
+	c.userID = resp.ToUserID
+	c.mu.Unlock()
+	return &resp, nil
+}
+
+// GetUserInfo 查询用户信息，userID 为 0 时查当前用户
+func (c *Client) GetUserInfo(ctx context.Context, userID uint64) (*service.UserDTO, error) {
+	var q url.Values
+	if userID > 0 {
+		q = idQuery("user_id", userID)
+	}
+	var u service.UserDTO
+	if err := c.get(ctx, "/user/info", q, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SearchUsers 按关键字搜索用户
+func (c *Client) SearchUsers(ctx context.Context, keyword string, limit, offset int) ([]service.UserDTO, error) {
+	q := url.Values{"keyword": {keyword}, "offset": {strconv.Itoa(offset)}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var list []service.UserDTO
+	err := c.get(ctx, "/user/search", q, &list)
+	return list, err
+}
+
+// GetUserBriefs 批量获取用户昵称头像（单次最多 service.UserBriefBatchMax 个）
+func (c *Client) GetUserBriefs(ctx context.Context, ids []uint64) ([]models.UserBrief, error) {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatUint(id, 10))
+	}
+	var list []models.UserBrief
+	err := c.get(ctx, "/user/briefs", url.Values{"ids": {strings.Join(parts, ",")}}, &list)
+	return list, err
+}
+
+// GetPrivacy 获取隐私设置
+func (c *Client) GetPrivacy(ctx context.Context) (*service.UserPrivacyDTO, error) {
+	var p service.UserPrivacyDTO
+	if err := c.get(ctx, "/user/privacy", nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetAppearOffline 开关隐身（他人看到的在线状态始终为离线）
+func (c *Client) SetAppearOffline(ctx context.Context, on bool) (*service.UserSettingsDTO, error) {
+	var st service.UserSettingsDTO
+	if err := c.post(ctx, "/user/settings", nil, map[string]any{"appear_offline": on}, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// GetPrefs 获取自定义设置，不传 namespaces 返回全部
+func (c *Client) GetPrefs(ctx context.Context, namespaces ...string) ([]service.UserPrefsDTO, error) {
+	var q url.Values
+	if len(namespaces) > 0 {
+		q = url.Values{"namespace": {strings.Join(namespaces, ",")}}
+	}
+	var out []service.UserPrefsDTO
+	err := c.get(ctx, "/user/prefs", q, &out)
+	return out, err
+}
+
+// PatchPrefs 修改一个命名空间的自定义设置（默认 JSON Merge Patch 合并）
+func (c *Client) PatchPrefs(ctx context.Context, req service.PatchPrefsReq) (*service.UserPrefsDTO, error) {
+	var out service.UserPrefsDTO
+	if err := c.post(ctx, "/user/prefs", nil, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdatePrivacy 更新隐私设置（只更新非 nil 字段）
+func (c *Client) UpdatePrivacy(ctx context.Context, req service.UpdatePrivacyReq) (*service.UserPrivacyDTO, error) {
+	var p service.UserPrivacyDTO
+	if err := c.post(ctx, "/user/privacy", nil, req, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UploadAvatar 上传头像（JPEG/PNG/GIF），crop 为 nil 时居中裁成正方形；返回各尺寸 URL
+func (c *Client) UploadAvatar(ctx context.Context, filename string, file io.Reader, crop *service.AvatarCrop) (*service.AvatarUploadDTO, error) {
+	var res service.AvatarUploadDTO
+	if err := c.Upload(ctx, "/user/avatar/upload", cropFields(crop), "file", filename, file, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func cropFields(crop *service.AvatarCrop) map[string]string {
+	fields := map[string]string{}
+	if crop != nil {
+		fields["crop_x"] = strconv.Itoa(crop.X)
+		fields["crop_y"] = strconv.Itoa(crop.Y)
+		fields["crop_size"] = strconv.Itoa(crop.Size)
+	}
+	return fields
+}
+
+// -------------------- 好友 --------------------
+
+// SendFriendRequest 发送好友申请
+func (c *Client) SendFriendRequest(ctx context.Context, toUser uint64, message string) error {
+	return c.post(ctx, "/friend/request", nil, map[string]any{"to_user": toUser, "message": message}, nil)
+}
+
+// SendFriendRequestFrom 发送好友申请并标记来源（search/group/nearby），source=group 时 roomID 为共同所在的群
+func (c *Client) SendFriendRequestFrom(ctx context.Context, toUser uint64, message, source string, roomID uint64) error {
+	body := map[string]any{"to_user": toUser, "message": message, "source": source, "room_id": roomID}
+	return c.post(ctx, "/friend/request", nil, body, nil)
+}
+
+// AcceptFriendRequest 同意好友申请
+func (c *Client) AcceptFriendRequest(ctx context.Context, requestID uint64) error {
+	return c.post(ctx, "/friend/accept", idQuery("request_id", requestID), nil, nil)
+}
+
+// RejectFriendRequest 拒绝好友申请
+func (c *Client) RejectFriendRequest(ctx context.Context, requestID uint64) error {
+	return c.post(ctx, "/friend/reject", idQuery("request_id", requestID), nil, nil)
+}
+
+// DeleteFriend 删除好友
+func (c *Client) DeleteFriend(ctx context.Context, friendID uint64) error {
+	return c.post(ctx, "/friend/delete", idQuery("friend_id", friendID), nil, nil)
+}
+
+// GetFriendList 好友列表
+func (c *Client) GetFriendList(ctx context.Context) ([]service.UserDTO, error) {
+	var list []service.UserDTO
+	err := c.get(ctx, "/friend/list", nil, &list)
+	return list, err
+}
+
+// GetFriendListSorted 好友列表，sortBy 为 service.FriendSortName / service.FriendSortAdded
+func (c *Client) GetFriendListSorted(ctx context.Context, sortBy string) ([]service.UserDTO, error) {
+	var list []service.UserDTO
+	err := c.get(ctx, "/friend/list", url.Values{"sort": {sortBy}}, &list)
+	return list, err
+}
+
+// GetFriendSections 按拼音首字母分组的好友列表（A–Z，最后是 "#"）
+func (c *Client) GetFriendSections(ctx context.Context) ([]service.FriendSection, error) {
+	var list []service.FriendSection
+	err := c.get(ctx, "/friend/sections", nil, &list)
+	return list, err
+}
+
+// GetStarredFriends 星标好友列表（按显示名排序）
+func (c *Client) GetStarredFriends(ctx context.Context) ([]service.UserDTO, error) {
+	var list []service.UserDTO
+	err := c.get(ctx, "/friend/starred", url.Values{"sort": {service.FriendSortName}}, &list)
+	return list, err
+}
+
+// SetFriendStar 星标/取消星标好友
+func (c *Client) SetFriendStar(ctx context.Context, friendID uint64, star bool) error {
+	return c.post(ctx, "/friend/star", nil, map[string]any{"friend_id": friendID, "star": star}, nil)
+}
+
+// GetPendingRequests 待处理的好友申请
+func (c *Client) GetPendingRequests(ctx context.Context) ([]service.FriendApplyDTO, error) {
+	var list []service.FriendApplyDTO
+	err := c.get(ctx, "/friend/pending", nil, &list)
+	return list, err
+}
+
+// GetMutualFriends 与 targetID 的共同好友，limit<=0 使用服务端默认
+func (c *Client) GetMutualFriends(ctx context.Context, targetID uint64, limit int) (*service.MutualFriendsDTO, error) {
+	q := idQuery("target_id", targetID)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out service.MutualFriendsDTO
+	if err := c.get(ctx, "/friend/mutual", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCommonGroups 与 targetID 都在的群聊，limit<=0 使用服务端默认
+func (c *Client) GetCommonGroups(ctx context.Context, targetID uint64, limit int) ([]service.CommonGroupDTO, error) {
+	q := idQuery("target_id", targetID)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var list []service.CommonGroupDTO
+	err := c.get(ctx, "/user/common-groups", q, &list)
+	return list, err
+}
+
+// -------------------- 房间 --------------------
+
+// CreatePrivateRoom 创建或获取与 targetID 的私聊房间
+func (c *Client) CreatePrivateRoom(ctx context.Context, targetID uint64) (*models.Room, error) {
+	var room models.Room
+	if err := c.post(ctx, "/room/private", idQuery("target_id", targetID), nil, &room); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// CreateGroupRoom 创建群聊（群 ID 通过 GetGroupRooms 或 member_added 推送获取）
+func (c *Client) CreateGroupRoom(ctx context.Context, name string, members []uint64) error {
+	return c.post(ctx, "/room/group", nil, map[string]any{"name": name, "members": members}, nil)
+}
+
+// GetUserRooms 当前用户的全部房间
+func (c *Client) GetUserRooms(ctx context.Context) ([]service.RoomDTO, error) {
+	var list []service.RoomDTO
+	err := c.get(ctx, "/room/list", nil, &list)
+	return list, err
+}
+
+// GetGroupRooms 当前用户的群聊
+func (c *Client) GetGroupRooms(ctx context.Context) ([]service.RoomDTO, error) {
+	var list []service.RoomDTO
+	err := c.get(ctx, "/room/group/list", nil, &list)
+	return list, err
+}
+
+// SaveGroup 保存/取消保存群聊到通讯录
+func (c *Client) SaveGroup(ctx context.Context, roomID uint64, saved bool) error {
+	return c.post(ctx, "/room/group/save", nil, map[string]any{"room_id": roomID, "saved": saved}, nil)
+}
+
+// GetSavedGroups 通讯录中的群聊
+func (c *Client) GetSavedGroups(ctx context.Context) ([]service.RoomDTO, error) {
+	var list []service.RoomDTO
+	err := c.get(ctx, "/room/group/saved", nil, &list)
+	return list, err
+}
+
+// GetGroupInfo 群基础信息
+func (c *Client) GetGroupInfo(ctx context.Context, roomID uint64) (*service.GroupInfoDTO, error) {
+	var info service.GroupInfoDTO
+	if err := c.get(ctx, "/room/group/info", idQuery("room_id", roomID), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// RoomOnline 房间在线人数与在线成员
+type RoomOnline struct {
+	RoomID  uint64 `json:"room_id"`
+	Online  int    `json:"online"`
+	Members []struct {
+		UserID   uint64 `json:"user_id"`
+		Nickname string `json:"nickname"`
+		Avatar   string `json:"avatar"`
+		Presence uint8  `json:"presence"`
+	} `json:"members"`
+}
+
+// GetRoomOnline 房间在线成员，limit<=0 使用服务端默认
+func (c *Client) GetRoomOnline(ctx context.Context, roomID uint64, limit int) (*RoomOnline, error) {
+	q := idQuery("room_id", roomID)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out RoomOnline
+	if err := c.get(ctx, "/room/online", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadGroupAvatar 上传群头像（群主/管理员），处理方式同 UploadAvatar
+func (c *Client) UploadGroupAvatar(ctx context.Context, roomID uint64, filename string, file io.Reader, crop *service.AvatarCrop) (*service.AvatarUploadDTO, error) {
+	fields := cropFields(crop)
+	fields["room_id"] = strconv.FormatUint(roomID, 10)
+	var res service.AvatarUploadDTO
+	if err := c.Upload(ctx, "/room/group/avatar/upload", fields, "file", filename, file, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// AddRoomMembers 批量拉人入群，逐个返回结果
+func (c *Client) AddRoomMembers(ctx context.Context, roomID uint64, userIDs []uint64) (*service.AddMembersResult, error) {
+	var res service.AddMembersResult
+	if err := c.post(ctx, "/room/member/add", nil, map[string]any{"room_id": roomID, "user_ids": userIDs}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SearchRoomMembers 群成员搜索（@ 提及补全）
+func (c *Client) SearchRoomMembers(ctx context.Context, roomID uint64, keyword string, limit int) ([]service.RoomMemberListItemDTO, error) {
+	q := url.Values{"room_id": {strconv.FormatUint(roomID, 10)}, "keyword": {keyword}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var list []service.RoomMemberListItemDTO
+	err := c.get(ctx, "/room/member/search", q, &list)
+	return list, err
+}
+
+// RemoveRoomMember 移出群成员
+func (c *Client) RemoveRoomMember(ctx context.Context, roomID, userID uint64) error {
+	return c.post(ctx, "/room/member/remove", nil, map[string]any{"room_id": roomID, "user_id": userID}, nil)
+}
+
+// SetRoomDisappearing 设置消息定时删除（ttl=0 关闭）
+func (c *Client) SetRoomDisappearing(ctx context.Context, roomID uint64, ttl time.Duration) error {
+	return c.post(ctx, "/room/disappearing", nil, map[string]any{"room_id": roomID, "ttl_seconds": int64(ttl / time.Second)}, nil)
+}
+
+// JoinGroupByAccount 按群号加群（answer/reason 视群的加群方式填写）
+func (c *Client) JoinGroupByAccount(ctx context.Context, account, answer, reason string) (*service.JoinGroupResult, error) {
+	var res service.JoinGroupResult
+	if err := c.post(ctx, "/room/join-by-account", nil, map[string]any{"room_account": account, "answer": answer, "reason": reason}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DiscoverGroups 搜索公开群（keyword 匹配群名或群号，category/tag 可为空）
+func (c *Client) DiscoverGroups(ctx context.Context, keyword, category, tag string, limit, offset int) ([]service.DiscoverGroupDTO, error) {
+	q := url.Values{}
+	if keyword != "" {
+		q.Set("keyword", keyword)
+	}
+	if category != "" {
+		q.Set("category", category)
+	}
+	if tag != "" {
+		q.Set("tag", tag)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	var list []service.DiscoverGroupDTO
+	err := c.get(ctx, "/room/discover", q, &list)
+	return list, err
+}
+
+// ListRoomAudit 群设置变更记录（群主/管理员），action 为空表示全部，cursor 传上一页的 NextCursor
+func (c *Client) ListRoomAudit(ctx context.Context, roomID uint64, action string, cursor uint64, limit int) (*service.RoomAuditPage, error) {
+	q := url.Values{"room_id": {strconv.FormatUint(roomID, 10)}}
+	if action != "" {
+		q.Set("action", action)
+	}
+	if cursor > 0 {
+		q.Set("cursor", strconv.FormatUint(cursor, 10))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out service.RoomAuditPage
+	err := c.get(ctx, "/room/audit", q, &out)
+	return &out, err
+}
+
+// QuitGroup 退出群聊
+func (c *Client) QuitGroup(ctx context.Context, roomID uint64) error {
+	return c.get(ctx, "/room/group/quit", idQuery("room_id", roomID), nil)
+}
+
+// -------------------- 消息 --------------------
+
+// GetConversations 会话列表
+func (c *Client) GetConversations(ctx context.Context) ([]service.ConversationListItemDTO, error) {
+	var list []service.ConversationListItemDTO
+	err := c.get(ctx, "/message/conversations", nil, &list)
+	return list, err
+}
+
+// GetConversationPage 分页获取会话列表（置顶在前），cursor 为上一页的 NextCursor，首页传空；limit<=0 用服务端默认值
+func (c *Client) GetConversationPage(ctx context.Context, cursor string, limit int) (*service.ConversationPageDTO, error) {
+	q := url.Values{}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out service.ConversationPageDTO
+	if err := c.get(ctx, "/message/conversations/page", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConversationUpdates 增量获取有变化的会话：首次传 since（分页接口的 ServerTime），之后传上次返回的 Cursor
+func (c *Client) GetConversationUpdates(ctx context.Context, since int64, cursor string) (*service.ConversationUpdatesDTO, error) {
+	q := url.Values{"since": {strconv.FormatInt(since, 10)}}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	var out service.ConversationUpdatesDTO
+	if err := c.get(ctx, "/message/conversations/updates", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConversationsByTag 打了某个标签的会话
+func (c *Client) GetConversationsByTag(ctx context.Context, tag string) ([]service.ConversationListItemDTO, error) {
+	var list []service.ConversationListItemDTO
+	err := c.get(ctx, "/message/conversations", url.Values{"tag": {tag}}, &list)
+	return list, err
+}
+
+// GetArchivedConversations 已归档的会话列表
+func (c *Client) GetArchivedConversations(ctx context.Context) ([]service.ConversationListItemDTO, error) {
+	var list []service.ConversationListItemDTO
+	err := c.get(ctx, "/message/conversations/archived", nil, &list)
+	return list, err
+}
+
+// ArchiveConversation 归档/取消归档会话
+func (c *Client) ArchiveConversation(ctx context.Context, roomID uint64, archived bool) error {
+	return c.post(ctx, "/message/conversation/archive", nil, map[string]any{"room_id": roomID, "archived": archived}, nil)
+}
+
+// GetMuteSettings 群禁言设置与自己当前的禁言状态
+func (c *Client) GetMuteSettings(ctx context.Context, roomID uint64) (*service.MuteSettingsDTO, error) {
+	var out service.MuteSettingsDTO
+	err := c.get(ctx, "/room/mute/settings", url.Values{"room_id": {strconv.FormatUint(roomID, 10)}}, &out)
+	return &out, err
+}
+
+// ListMutedMembers 当前被禁言的成员及解除时间
+func (c *Client) ListMutedMembers(ctx context.Context, roomID uint64) ([]service.MutedMemberDTO, error) {
+	var out []service.MutedMemberDTO
+	err := c.get(ctx, "/room/mute/list", url.Values{"room_id": {strconv.FormatUint(roomID, 10)}}, &out)
+	return out, err
+}
+
+// SetGroupMuteAdmins 设置全员禁言是否对管理员生效（群主）
+func (c *Client) SetGroupMuteAdmins(ctx context.Context, roomID uint64, muteAdmins bool) error {
+	return c.post(ctx, "/room/mute/admins", nil, map[string]any{"room_id": roomID, "mute_admins": muteAdmins}, nil)
+}
+
+// SetMuteExempt 设置/取消成员的全员禁言豁免（管理员）
+func (c *Client) SetMuteExempt(ctx context.Context, roomID uint64, userIDs []uint64, exempt bool) error {
+	return c.post(ctx, "/room/mute/exempt", nil, map[string]any{"room_id": roomID, "user_ids": userIDs, "exempt": exempt}, nil)
+}
+
+// MuteConversation 会话免打扰开关
+func (c *Client) MuteConversation(ctx context.Context, roomID uint64, muted bool) error {
+	return c.post(ctx, "/message/conversation/mute", nil, map[string]any{"room_id": roomID, "muted": muted}, nil)
+}
+
+// GetConversationSettings 会话设置（背景、别名），不传 roomIDs 返回全部设置过的会话
+func (c *Client) GetConversationSettings(ctx context.Context, roomIDs ...uint64) ([]service.ConversationSettingsDTO, error) {
+	var q url.Values
+	if len(roomIDs) > 0 {
+		ids := make([]string, len(roomIDs))
+		for i, id := range roomIDs {
+			ids[i] = strconv.FormatUint(id, 10)
+		}
+		q = url.Values{"room_id": {strings.Join(ids, ",")}}
+	}
+	var out []service.ConversationSettingsDTO
+	err := c.get(ctx, "/message/conversation/settings", q, &out)
+	return out, err
+}
+
+// UpdateConversationSettings 修改会话背景/别名（只更新非 nil 字段）
+func (c *Client) UpdateConversationSettings(ctx context.Context, req service.UpdateConversationSettingsReq) (*service.ConversationSettingsDTO, error) {
+	var out service.ConversationSettingsDTO
+	if err := c.post(ctx, "/message/conversation/settings", nil, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConversationTags 我用过的会话标签及各自的会话数
+func (c *Client) GetConversationTags(ctx context.Context) ([]service.ConversationTagDTO, error) {
+	var out []service.ConversationTagDTO
+	err := c.get(ctx, "/message/conversation/tags", nil, &out)
+	return out, err
+}
+
+// SetConversationTags 整体替换会话的标签（不传 tags 表示清除），返回会话当前的标签
+func (c *Client) SetConversationTags(ctx context.Context, roomID uint64, tags ...string) ([]string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	var out []string
+	err := c.post(ctx, "/message/conversation/tags", nil, service.SetConversationTagsReq{RoomID: roomID, Tags: tags}, &out)
+	return out, err
+}
+
+// RenameConversationTag 重命名会话标签（新名称已存在时合并）
+func (c *Client) RenameConversationTag(ctx context.Context, tag, newTag string) error {
+	return c.post(ctx, "/message/conversation/tag/rename", nil, service.RenameConversationTagReq{Tag: tag, NewTag: newTag}, nil)
+}
+
+// DeleteConversationTag 从所有会话上移除标签
+func (c *Client) DeleteConversationTag(ctx context.Context, tag string) error {
+	return c.post(ctx, "/message/conversation/tag/delete", nil, map[string]any{"tag": tag}, nil)
+}
+
+// UnreadTotal 消息总未读数（不含已归档会话）
+func (c *Client) UnreadTotal(ctx context.Context) (uint64, error) {
+	var res struct {
+		Unread uint64 `json:"unread"`
+	}
+	err := c.get(ctx, "/message/conversations/unread-total", nil, &res)
+	return res.Unread, err
+}
+
+// SetMessageReminder 设置消息提醒，到 remindAt 时收到 message.reminder 通知
+func (c *Client) SetMessageReminder(ctx context.Context, messageID uint64, remindAt time.Time, note string) (*service.MessageReminderDTO, error) {
+	var r service.MessageReminderDTO
+	body := map[string]any{"message_id": messageID, "remind_at": remindAt, "note": note}
+	if err := c.post(ctx, "/message/reminder", nil, body, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListMessageReminders 待提醒的消息
+func (c *Client) ListMessageReminders(ctx context.Context, limit, offset int) ([]service.MessageReminderDTO, error) {
+	q := url.Values{"offset": {strconv.Itoa(offset)}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var list []service.MessageReminderDTO
+	err := c.get(ctx, "/message/reminders", q, &list)
+	return list, err
+}
+
+// OpenViewOnceMessage 查看阅后即焚消息（只能查看一次）
+func (c *Client) OpenViewOnceMessage(ctx context.Context, messageID uint64) (*service.MessageListItemDTO, error) {
+	var msg service.MessageListItemDTO
+	if err := c.post(ctx, "/message/view-once/open", nil, map[string]any{"message_id": messageID}, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// SearchInRoom 会话内按关键词搜索，cursor 传上一页的 NextCursor
+func (c *Client) SearchInRoom(ctx context.Context, roomID uint64, keyword string, cursor uint64, limit int) (*service.RoomSearchPage, error) {
+	q := idQuery("room_id", roomID)
+	q.Set("keyword", keyword)
+	if cursor > 0 {
+		q.Set("cursor", strconv.FormatUint(cursor, 10))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out service.RoomSearchPage
+	if err := c.get(ctx, "/message/search-in-room", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMessageContext 定位到某条消息，返回其前后各若干条消息
+func (c *Client) GetMessageContext(ctx context.Context, roomID, messageID uint64, before, after int) (*service.MessageContextDTO, error) {
+	q := idQuery("room_id", roomID)
+	q.Set("message_id", strconv.FormatUint(messageID, 10))
+	q.Set("before", strconv.Itoa(before))
+	q.Set("after", strconv.Itoa(after))
+	var out service.MessageContextDTO
+	if err := c.get(ctx, "/message/context", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRoomMessages 房间历史消息，beforeID>0 时取该消息之前的
+func (c *Client) GetRoomMessages(ctx context.Context, roomID uint64, limit int, beforeID uint64) ([]service.MessageListItemDTO, error) {
+	q := idQuery("room_id", roomID)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if beforeID > 0 {
+		q.Set("mess_id", strconv.FormatUint(beforeID, 10))
+	}
+	var list []service.MessageListItemDTO
+	err := c.get(ctx, "/message/list", q, &list)
+	return list, err
+}
+
+// SendMessage 通过 HTTP 发送消息（无需 WS 连接），req.SendTo 为房间 ID，req.Type 忽略；返回落库后的消息
+func (c *Client) SendMessage(ctx context.Context, req message.Req) (*service.MessageDTO, error) {
+	body := map[string]any{"room_id": req.SendTo, "msg_type": req.SendType, "content": req.SendContent, "extra": req.Extra, "packet_id": req.PacketID}
+	var out service.MessageDTO
+	if err := c.post(ctx, "/message/send", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ClockOffset 通过 /time 估算服务端与本地时钟的偏差（服务端时间 ≈ 本地时间 + offset），按往返耗时的一半补偿网络延迟
+func (c *Client) ClockOffset(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	var out struct {
+		ServerTime int64 `json:"server_time"`
+	}
+	if err := c.get(ctx, "/time", url.Values{"client_time": {strconv.FormatInt(start.UnixMilli(), 10)}}, &out); err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	return time.UnixMilli(out.ServerTime).Sub(start.Add(rtt / 2)), nil
+}
+
+// RecallResult 批量撤回/删除结果
+type RecallResult struct {
+	SuccessIDs []uint64 `json:"success_ids"`
+	Failed     []struct {
+		MessageID uint64 `json:"message_id"`
+		Error     string `json:"error,omitempty"`
+	} `json:"failed"`
+}
+
+// RecallMessages 批量撤回（status=models.MessageStatusRecalled）或删除（MessageStatusDeleted / MessageStatusBothDeleted）
+func (c *Client) RecallMessages(ctx context.Context, messageIDs []uint64, status uint8) (*RecallResult, error) {
+	var res RecallResult
+	if err := c.post(ctx, "/message/recall", nil, map[string]any{"message_ids": messageIDs, "status": status}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PollEvent 长轮询事件，Data 与 WS 推送内容一致
+type PollEvent struct {
+	Seq  uint64          `json:"seq"`
+	Data json.RawMessage `json:"data"`
+}
+
+// PollResult 长轮询结果，下次请求携带 Cursor
+type PollResult struct {
+	Events []PollEvent `json:"events"`
+	Cursor uint64      `json:"cursor"`
+}
+
+// PollMessages 长轮询拉取事件（WS 不可用时的降级），timeoutSec 最大 60
+func (c *Client) PollMessages(ctx context.Context, cursor uint64, timeoutSec int) (*PollResult, error) {
+	q := url.Values{
+		"cursor":  {strconv.FormatUint(cursor, 10)},
+		"timeout": {strconv.Itoa(timeoutSec)},
+	}
+	var res PollResult
+	if err := c.get(ctx, "/message/poll", q, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// -------------------- 组织架构（服务端开启 WithOrgDirectory 时可用） --------------------
+
+// OrgTree 部门树
+func (c *Client) OrgTree(ctx context.Context) ([]service.DepartmentNode, error) {
+	var tree []service.DepartmentNode
+	err := c.get(ctx, "/org/tree", nil, &tree)
+	return tree, err
+}
+
+// OrgMembers 部门成员，recursive=true 时包含下级部门成员
+func (c *Client) OrgMembers(ctx context.Context, departmentID uint64, recursive bool) ([]service.OrgMemberDTO, error) {
+	q := idQuery("department_id", departmentID)
+	q.Set("recursive", strconv.FormatBool(recursive))
+	var list []service.OrgMemberDTO
+	err := c.get(ctx, "/org/department/members", q, &list)
+	return list, err
+}
+
+// MyDepartments 我所在的部门
+func (c *Client) MyDepartments(ctx context.Context) ([]service.OrgMemberDTO, error) {
+	var list []service.OrgMemberDTO
+	err := c.get(ctx, "/org/my", nil, &list)
+	return list, err
+}
+
+// StartOrgChat 与同事发起私聊（无需互为好友），返回私聊房间
+func (c *Client) StartOrgChat(ctx context.Context, userID uint64) (*service.OrgChatDTO, error) {
+	var chat service.OrgChatDTO
+	if err := c.post(ctx, "/org/chat", nil, map[string]any{"user_id": userID}, &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
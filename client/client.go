@@ -0,0 +1,218 @@
+// Package client 是 chat-sdk 服务端的 Go 客户端：HTTP 接口的类型化封装（Client）与 WebSocket 协议实现（Conn）。
+// 适用于 Go 编写的机器人、压测与集成测试。
+//
+//	c := client.New("http://127.0.0.1:8080/api/v1")
+//	if _, err := c.Login(ctx, service.LoginReq{Account: "alice", Password: "secret"}); err != nil {
+//	    log.Fatal(err)
+//	}
+//	conn := c.Dial("ws://127.0.0.1:8080/ws", client.ConnOptions{OnEvent: func(e client.Event) { ... }})
+//	defer conn.Close()
+//	msg, err := conn.SendText(ctx, roomID, "hello")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+)
+
+// Client HTTP 接口客户端，并发安全；登录成功后自动携带 token
+type Client struct {
+	baseURL string
+	http    *http.Client
+	lang    string
+
+	mu     sync.RWMutex
+	token  string
+	userID uint64
+}
+
+// Option Client 配置
+type Option func(*Client)
+
+// WithHTTPClient 自定义底层 http.Client（超时、代理、TLS 等），默认超时 70s（需大于长轮询最长挂起时间）
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithToken 使用已有 token（跳过 Login）
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithLanguage 设置 Accept-Language，错误文案按该语言返回
+func WithLanguage(lang string) Option {
+	return func(c *Client) {
+		c.lang = lang
+	}
+}
+
+// New 创建客户端，baseURL 为接口前缀，如 http://127.0.0.1:8080/api/v1
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 70 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token 当前 token
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// SetToken 替换 token（如切换账号）
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+// UserID Login 成功后的当前用户 ID（使用 WithToken 时为 0）
+func (c *Client) UserID() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.userID
+}
+
+// APIError 服务端返回的业务错误
+type APIError struct {
+	StatusCode int    // HTTP 状态码
+	Code       int    // 业务码，见 response.CodeXxx
+	Msg        string // 错误文案
+	// RetryAfter 服务端建议的重试等待时间（Retry-After 头，如过载保护返回 CodeServerBusy 时），没有则为 0
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("chat api error: code=%d status=%d msg=%s", e.Code, e.StatusCode, e.Msg)
+}
+
+// ErrorCode 取出 APIError 的业务码；非 APIError 返回 response.CodeInternalError，nil 返回 response.CodeSuccess
+func ErrorCode(err error) int {
+	if err == nil {
+		return response.CodeSuccess
+	}
+	var e *APIError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return response.CodeInternalError
+}
+
+// envelope 统一响应结构（同 response.Response，data 延迟解析）
+type envelope struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Do 调用任意接口：path 相对 baseURL（如 "/room/list"），body 非 nil 时按 JSON 发送，
+// 成功时把 data 解析到 out（out 为 nil 则丢弃）。未封装的接口可直接用它调用。
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var rd io.Reader
+	contentType := ""
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd, contentType = bytes.NewReader(b), "application/json"
+	}
+	return c.send(ctx, method, u, contentType, rd, out)
+}
+
+// send 发送请求并解析统一响应结构
+func (c *Client) send(ctx context.Context, method, u, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if c.lang != "" {
+		req.Header.Set("Accept-Language", c.lang)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return &APIError{StatusCode: resp.StatusCode, Code: response.CodeInternalError, Msg: fmt.Sprintf("decode response: %v", err)}
+	}
+	if env.Code != response.CodeSuccess {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Code: env.Code, Msg: env.Msg}
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && sec > 0 {
+			apiErr.RetryAfter = time.Duration(sec) * time.Second
+		}
+		return apiErr
+	}
+	if out == nil || len(env.Data) == 0 || string(env.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+// Upload 以 multipart/form-data 上传文件：fields 为普通表单字段，文件字段名为 fileField。
+// 成功时把 data 解析到 out。
+func (c *Client) Upload(ctx context.Context, path string, fields map[string]string, fileField, filename string, file io.Reader, out any) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	fw, err := mw.CreateFormFile(fileField, filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, file); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return c.send(ctx, http.MethodPost, c.baseURL+path, mw.FormDataContentType(), &buf, out)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	return c.Do(ctx, http.MethodGet, path, query, nil, out)
+}
+
+func (c *Client) post(ctx context.Context, path string, query url.Values, body, out any) error {
+	return c.Do(ctx, http.MethodPost, path, query, body, out)
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+// writeEnvelope 按服务端统一响应结构写出
+func writeEnvelope(w http.ResponseWriter, status, code int, msg string, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response.Response{Code: code, Msg: msg, Data: data})
+}
+
+// Login 成功后保存 token 与用户 ID，后续请求自动携带 token 与语言头
+func TestClient_LoginCarriesToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user/login", func(w http.ResponseWriter, r *http.Request) {
+		var req service.LoginReq
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+			writeEnvelope(w, http.StatusBadRequest, response.CodeParamError, "bad request", nil)
+			return
+		}
+		if req.Account != "alice" || req.Password != "secret" {
+			writeEnvelope(w, http.StatusUnauthorized, response.CodePasswordError, "密码错误", nil)
+			return
+		}
+		writeEnvelope(w, http.StatusOK, response.CodeSuccess, "", service.LoginResp{Token: "tok-1", User: service.UserDTO{ID: 7}})
+	})
+	mux.HandleFunc("/api/v1/user/info", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-1" {
+			writeEnvelope(w, http.StatusUnauthorized, response.CodeTokenInvalid, "token invalid", nil)
+			return
+		}
+		if got := r.Header.Get("Accept-Language"); got != "en" {
+			t.Errorf("Accept-Language = %q", got)
+		}
+		writeEnvelope(w, http.StatusOK, response.CodeSuccess, "", service.UserDTO{ID: 7, Nickname: "Alice"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(srv.URL+"/api/v1/", WithLanguage("en"))
+	if _, err := c.GetUserInfo(ctx, 0); ErrorCode(err) != response.CodeTokenInvalid {
+		t.Fatalf("before login: %v", err)
+	}
+	_, err := c.Login(ctx, service.LoginReq{Account: "alice", Password: "wrong"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != response.CodePasswordError || apiErr.Msg != "密码错误" {
+		t.Fatalf("wrong password: %v", err)
+	}
+	if c.Token() != "" {
+		t.Fatalf("token set after failed login: %q", c.Token())
+	}
+
+	if _, err := c.Login(ctx, service.LoginReq{Account: "alice", Password: "secret"}); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if c.Token() != "tok-1" || c.UserID() != 7 {
+		t.Fatalf("token=%q user=%d", c.Token(), c.UserID())
+	}
+	u, err := c.GetUserInfo(ctx, 0)
+	if err != nil || u.Nickname != "Alice" {
+		t.Fatalf("user info: %+v %v", u, err)
+	}
+}
+
+// 业务错误带上 HTTP 状态码与 Retry-After；响应不是统一结构时返回 CodeInternalError
+func TestClient_Errors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/busy", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		writeEnvelope(w, http.StatusServiceUnavailable, response.CodeServerBusy, "busy", nil)
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>bad gateway</html>", http.StatusBadGateway)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := New(srv.URL, WithToken("tok"))
+	err := c.Do(context.Background(), http.MethodGet, "/busy", nil, nil, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != response.CodeServerBusy || apiErr.RetryAfter != 3*time.Second {
+		t.Fatalf("busy: %#v", err)
+	}
+
+	err = c.Do(context.Background(), http.MethodGet, "/html", nil, nil, nil)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || ErrorCode(err) != response.CodeInternalError {
+		t.Fatalf("non-envelope: %#v", err)
+	}
+	if ErrorCode(nil) != response.CodeSuccess {
+		t.Fatal("ErrorCode(nil)")
+	}
+}
@@ -0,0 +1,516 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrClosed 连接已调用 Close（或鉴权失败后停止重连）
+	ErrClosed = errors.New("chat client: connection closed")
+	// ErrNotConnected 当前处于断线重连中
+	ErrNotConnected = errors.New("chat client: not connected")
+	// ErrAckTimeout Send 在 AckTimeout 内未收到服务端回显
+	ErrAckTimeout = errors.New("chat client: ack timeout")
+	// ErrDisconnected 等待回显期间连接断开（消息可能已落库，可按 packet_id 去重后重发）
+	ErrDisconnected = errors.New("chat client: disconnected before ack")
+	// ErrIdleKicked 长时间没有上行消息被服务端断开（服务端开启了空闲策略），不再自动重连
+	ErrIdleKicked = errors.New("chat client: kicked for idle")
+	// ErrTokenRevoked 连接绑定的 token 已注销（退出登录、账号被封禁），不再自动重连
+	ErrTokenRevoked = errors.New("chat client: token revoked")
+)
+
+const (
+	// closeIdle 服务端因空闲断开连接时的 close code（与 chat_sdk.WsCloseIdle 一致）
+	closeIdle = 4000
+	// closeRevoked token 注销后服务端断开连接的 close code（与 chat_sdk.WsCloseRevoked 一致）
+	closeRevoked = 4002
+	// 4003（chat_sdk.WsCloseSwitched）为同一设备切换账号，按普通断线处理：重连时读取 SetToken/SwitchAccount 换上的新 token
+)
+
+// SendError 服务端拒绝了消息（禁言、非群成员、被拉黑等）
+type SendError struct {
+	PacketID string
+	Msg      string
+	Code     int                // 业务码，见 message.WsErr* / response.CodeXxx（老版本服务端为 0）
+	Reason   string             // 细分原因，见 message.WsErrReason*（老版本服务端为空）
+	Mute     *service.MuteError // Code 为 response.CodeMuted 时的禁言截止时间
+	// Retryable 稍后重发是否可能成功（限流、繁忙、禁言到期）；RetryAfter 为服务端建议的等待时间，没有则为 0
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+func (e *SendError) Error() string {
+	return "chat client: send rejected: " + e.Msg
+}
+
+// Event 服务端推送（WS 推送与长轮询 PollEvent.Data 格式一致）
+type Event struct {
+	Type string          // session / message / recall / typing / conversation_read / message_status / error ...
+	Raw  json.RawMessage // 原始 JSON
+}
+
+// Session 建连后服务端下发的握手（type=session）。
+// 重连时 Conn 自动携带上次的 resume_token 与收到的最后一个 seq，Resumed=true 表示断线期间的推送已补发；
+// 重连后 Resumed=false 说明超出续传窗口（或服务端重启），需要通过 HTTP 接口重新同步会话与消息。
+type Session = message.SessionEvent
+
+// Decode 把推送解析到具体结构（如 Message，其他事件见 message 包中的 *Event）
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// Message 房间消息推送（type=message）
+type Message = message.RoomMessageEvent
+
+// ConnOptions WS 连接配置，零值可用
+type ConnOptions struct {
+	// OnEvent 收到推送时回调（读协程中串行调用，耗时操作请自行异步）
+	OnEvent func(Event)
+	// OnStateChange 连上/断开时回调，断开时 err 为原因
+	OnStateChange func(connected bool, err error)
+
+	// MinBackoff / MaxBackoff 断线重连的指数退避区间（带抖动），默认 500ms / 30s
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// AckTimeout Send 等待回显的超时，默认 10s
+	AckTimeout time.Duration
+	// TypingInterval 同一房间 Typing 的最小上报间隔，默认 3s
+	TypingInterval time.Duration
+
+	// AutoDeliveryAck 收到他人的消息后自动上报 delivery_ack（需要 UserID 区分自己发的消息）
+	AutoDeliveryAck bool
+	// UserID 当前用户 ID，Client.Dial 会自动填入 Login 得到的 ID
+	UserID uint64
+
+	// Dialer 默认 websocket.DefaultDialer
+	Dialer *websocket.Dialer
+	// Header 额外的握手请求头
+	Header http.Header
+}
+
+func (o ConnOptions) withDefaults() ConnOptions {
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 500 * time.Millisecond
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.AckTimeout <= 0 {
+		o.AckTimeout = 10 * time.Second
+	}
+	if o.TypingInterval <= 0 {
+		o.TypingInterval = 3 * time.Second
+	}
+	if o.Dialer == nil {
+		o.Dialer = websocket.DefaultDialer
+	}
+	return o
+}
+
+const (
+	wsWriteWait = 10 * time.Second
+	// wsReadWait 服务端每 54s 发一次 ping，超过该时间无任何数据视为断线
+	wsReadWait = 90 * time.Second
+)
+
+// ackResult Send 等待的结果
+type ackResult struct {
+	msg *Message
+	err error
+}
+
+// Conn WebSocket 连接：断线自动重连，Send 按 packet_id 等待服务端回显作为发送确认。并发安全。
+type Conn struct {
+	url   string
+	token func() string
+	opts  ConnOptions
+
+	mu         sync.Mutex
+	ws         *websocket.Conn
+	pending    map[string]chan ackResult
+	lastTyping map[uint64]time.Time
+
+	// resumeToken / lastEventID 断线重连续传用（见 Session）
+	resumeToken string
+	lastEventID uint64
+
+	writeMu sync.Mutex
+
+	packetPrefix string
+	packetSeq    atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Dial 使用当前 token 建立 WS 连接（wsURL 如 ws://127.0.0.1:8080/ws），立即返回，后台连接并自动重连。
+// 重连时会读取最新的 token，可配合 SetToken 续期。
+func (c *Client) Dial(wsURL string, opts ConnOptions) *Conn {
+	if opts.UserID == 0 {
+		opts.UserID = c.UserID()
+	}
+	return dial(wsURL, c.Token, opts)
+}
+
+// Dial 使用固定 token 建立 WS 连接（不需要 HTTP 客户端时使用）
+func Dial(wsURL, token string, opts ConnOptions) *Conn {
+	return dial(wsURL, func() string { return token }, opts)
+}
+
+func dial(wsURL string, token func() string, opts ConnOptions) *Conn {
+	var b [4]byte
+	_, _ = crand.Read(b[:])
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Conn{
+		url:          wsURL,
+		token:        token,
+		opts:         opts.withDefaults(),
+		pending:      make(map[string]chan ackResult),
+		lastTyping:   make(map[uint64]time.Time),
+		packetPrefix: hex.EncodeToString(b[:]),
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Connected 当前是否在线
+func (c *Conn) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws != nil
+}
+
+// Close 关闭连接并停止重连，等待中的 Send 返回 ErrClosed
+func (c *Conn) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	ws := c.ws
+	c.mu.Unlock()
+	if ws != nil {
+		c.writeMu.Lock()
+		_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		c.writeMu.Unlock()
+		_ = ws.Close()
+	}
+	<-c.done
+	return nil
+}
+
+// NewPacketID 生成连接内唯一的 packet_id
+func (c *Conn) NewPacketID() string {
+	return c.packetPrefix + "-" + strconv.FormatUint(c.packetSeq.Add(1), 10)
+}
+
+// Send 发送消息并等待服务端回显（即落库成功）；req.PacketID 为空时自动生成，req.Type 固定为 message。
+func (c *Conn) Send(ctx context.Context, req message.Req) (*Message, error) {
+	req.Type = message.WsTypeMessage
+	if req.PacketID == "" {
+		req.PacketID = c.NewPacketID()
+	}
+	ch := make(chan ackResult, 1)
+	c.mu.Lock()
+	c.pending[req.PacketID] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.PacketID)
+		c.mu.Unlock()
+	}()
+
+	if err := c.writeJSON(req); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.opts.AckTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-ch:
+		return res.msg, res.err
+	case <-timer.C:
+		return nil, ErrAckTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClosed
+	}
+}
+
+// SendText 发送文本消息
+func (c *Conn) SendText(ctx context.Context, roomID uint64, text string) (*Message, error) {
+	return c.Send(ctx, message.Req{SendTo: roomID, SendType: 1, SendContent: text})
+}
+
+// ReadAck 上报已读游标（last_read_msg_id 推荐填房间最新消息 ID）
+func (c *Conn) ReadAck(roomID, lastReadMsgID uint64) error {
+	return c.writeJSON(message.ReadAckReq{Type: message.WsTypeReadAck, RoomID: roomID, LastReadMsgID: lastReadMsgID})
+}
+
+// DeliveryAck 上报送达回执
+func (c *Conn) DeliveryAck(roomID uint64, messageIDs ...uint64) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	return c.writeJSON(message.DeliveryAckReq{Type: message.WsTypeDeliveryAck, RoomID: roomID, MessageIDs: messageIDs})
+}
+
+// Heartbeat 发送应用层心跳（计入活跃，避免被服务端空闲策略标记离开/断开），服务端回一条 type=heartbeat
+func (c *Conn) Heartbeat() error {
+	return c.writeJSON(map[string]any{"type": message.WsTypeHeartbeat})
+}
+
+// Typing 上报正在输入，TypingInterval 内重复调用会被忽略，可在每次按键时直接调用
+func (c *Conn) Typing(roomID uint64) error {
+	now := time.Now()
+	c.mu.Lock()
+	if last, ok := c.lastTyping[roomID]; ok && now.Sub(last) < c.opts.TypingInterval {
+		c.mu.Unlock()
+		return nil
+	}
+	c.lastTyping[roomID] = now
+	c.mu.Unlock()
+	return c.writeJSON(message.TypingReq{Type: message.WsTypeTyping, RoomID: roomID})
+}
+
+func (c *Conn) writeJSON(v any) error {
+	if c.ctx.Err() != nil {
+		return ErrClosed
+	}
+	c.mu.Lock()
+	ws := c.ws
+	c.mu.Unlock()
+	if ws == nil {
+		return ErrNotConnected
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = ws.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return ws.WriteJSON(v)
+}
+
+// run 连接 + 读循环，断开后按指数退避重连，直到 Close
+func (c *Conn) run() {
+	defer close(c.done)
+	backoff := c.opts.MinBackoff
+	for {
+		header := http.Header{}
+		for k, v := range c.opts.Header {
+			header[k] = v
+		}
+		header.Set("Authorization", "Bearer "+c.token())
+
+		ws, resp, err := c.opts.Dialer.DialContext(c.ctx, c.dialURL(), header)
+		if err == nil {
+			backoff = c.opts.MinBackoff
+			c.mu.Lock()
+			// Close 在握手期间调用时还拿不到 ws，这里关闭，否则 readLoop 会一直阻塞
+			if c.ctx.Err() != nil {
+				c.mu.Unlock()
+				_ = ws.Close()
+				c.notifyState(false, ErrClosed)
+				return
+			}
+			c.ws = ws
+			c.mu.Unlock()
+			c.notifyState(true, nil)
+
+			err = c.readLoop(ws)
+			_ = ws.Close()
+
+			c.mu.Lock()
+			c.ws = nil
+			for id, ch := range c.pending {
+				ch <- ackResult{err: ErrDisconnected}
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+
+			if websocket.IsCloseError(err, closeIdle) {
+				// 空闲被踢说明客户端已不活跃，重连没有意义；需要时由业务重新 Dial
+				c.notifyState(false, ErrIdleKicked)
+				c.cancel()
+				return
+			}
+			if websocket.IsCloseError(err, closeRevoked) {
+				c.notifyState(false, ErrTokenRevoked)
+				c.cancel()
+				return
+			}
+		} else if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			// token 无效或账号被封禁，重连没有意义
+			c.notifyState(false, fmt.Errorf("chat client: handshake rejected: %s", resp.Status))
+			c.cancel()
+			return
+		}
+
+		if c.ctx.Err() != nil {
+			c.notifyState(false, ErrClosed)
+			return
+		}
+		c.notifyState(false, err)
+
+		// 退避 + 抖动（0.5~1.0 倍），避免服务端重启后客户端同时重连
+		wait := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-time.After(wait):
+		case <-c.ctx.Done():
+			c.notifyState(false, ErrClosed)
+			return
+		}
+		backoff = min(backoff*2, c.opts.MaxBackoff)
+	}
+}
+
+// dialURL 有 resume_token 时附带续传参数
+func (c *Conn) dialURL() string {
+	c.mu.Lock()
+	token, last := c.resumeToken, c.lastEventID
+	c.mu.Unlock()
+	if token == "" {
+		return c.url
+	}
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return c.url
+	}
+	q := u.Query()
+	q.Set("resume_token", token)
+	q.Set("last_event_id", strconv.FormatUint(last, 10))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (c *Conn) readLoop(ws *websocket.Conn) error {
+	_ = ws.SetReadDeadline(time.Now().Add(wsReadWait))
+	ws.SetPingHandler(func(data string) error {
+		_ = ws.SetReadDeadline(time.Now().Add(wsReadWait))
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(wsWriteWait))
+	})
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		_ = ws.SetReadDeadline(time.Now().Add(wsReadWait))
+		// 服务端会把积压的多条推送写进同一帧（JSON 依次拼接），逐条解析
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				break
+			}
+			c.dispatch(raw)
+		}
+	}
+}
+
+// dispatch 匹配 Send 的回显/错误，然后回调 OnEvent
+func (c *Conn) dispatch(data []byte) {
+	var probe struct {
+		Type     string `json:"type"`
+		PacketID string `json:"packet_id"`
+		Message  string `json:"message"`
+		Code     int    `json:"code"`
+		Seq      uint64 `json:"seq"`
+		// error 事件
+		Reason     string `json:"reason"`
+		Retryable  bool   `json:"retryable"`
+		RetryAfter int64  `json:"retry_after"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return
+	}
+	ev := Event{Type: probe.Type, Raw: json.RawMessage(data)}
+
+	if probe.Seq > 0 {
+		c.mu.Lock()
+		c.lastEventID = probe.Seq
+		c.mu.Unlock()
+	}
+
+	switch probe.Type {
+	case message.WsEventSession:
+		var sess Session
+		if err := json.Unmarshal(data, &sess); err == nil {
+			c.mu.Lock()
+			c.resumeToken = sess.ResumeToken
+			// 续传时以随后补发的事件推进 lastEventID，补发中途断线可再次续传
+			if !sess.Resumed {
+				c.lastEventID = sess.LastEventID
+			}
+			c.mu.Unlock()
+		}
+	case message.WsTypeMessage:
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			break
+		}
+		if probe.PacketID != "" {
+			c.resolve(probe.PacketID, ackResult{msg: &msg})
+		}
+		if c.opts.AutoDeliveryAck && c.opts.UserID != 0 && msg.SenderID != c.opts.UserID && !msg.IsSystem {
+			_ = c.DeliveryAck(msg.RoomID, msg.ID)
+		}
+	case message.WsEventError:
+		if probe.PacketID != "" {
+			sendErr := &SendError{
+				PacketID: probe.PacketID, Msg: probe.Message, Code: probe.Code, Reason: probe.Reason,
+				Retryable: probe.Retryable, RetryAfter: time.Duration(probe.RetryAfter) * time.Second,
+			}
+			if probe.Code == response.CodeMuted {
+				var body struct {
+					Data *service.MuteError `json:"data"`
+				}
+				if json.Unmarshal(data, &body) == nil {
+					sendErr.Mute = body.Data
+				}
+			}
+			c.resolve(probe.PacketID, ackResult{err: sendErr})
+		}
+	}
+
+	if c.opts.OnEvent != nil {
+		c.opts.OnEvent(ev)
+	}
+}
+
+func (c *Conn) resolve(packetID string, res ackResult) {
+	c.mu.Lock()
+	ch, ok := c.pending[packetID]
+	if ok {
+		delete(c.pending, packetID)
+	}
+	c.mu.Unlock()
+	if ok {
+		ch <- res
+	}
+}
+
+func (c *Conn) notifyState(connected bool, err error) {
+	if c.opts.OnStateChange != nil {
+		c.opts.OnStateChange(connected, err)
+	}
+}
@@ -0,0 +1,302 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer 只接受 token 的 WS 服务端，每个建立的连接交给测试驱动
+type fakeServer struct {
+	srv   *httptest.Server
+	conns chan *fakeConn
+}
+
+type fakeConn struct {
+	ws    *websocket.Conn
+	query url.Values
+}
+
+func newFakeServer(t *testing.T, token string) *fakeServer {
+	t.Helper()
+	f := &fakeServer{conns: make(chan *fakeConn, 4)}
+	upgrader := websocket.Upgrader{}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		f.conns <- &fakeConn{ws: ws, query: r.URL.Query()}
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeServer) url() string {
+	return "ws" + strings.TrimPrefix(f.srv.URL, "http") + "/ws"
+}
+
+// accept 等待客户端（重新）建连
+func (f *fakeServer) accept(t *testing.T) *fakeConn {
+	t.Helper()
+	select {
+	case c := <-f.conns:
+		t.Cleanup(func() { _ = c.ws.Close() })
+		return c
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+func (c *fakeConn) push(t *testing.T, events ...message.Event) {
+	t.Helper()
+	for _, e := range events {
+		b, err := message.EncodeEvent(e)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := c.ws.WriteMessage(websocket.TextMessage, b); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+}
+
+func (c *fakeConn) read(t *testing.T, v any) {
+	t.Helper()
+	_ = c.ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := c.ws.ReadJSON(v); err != nil {
+		t.Fatalf("server read: %v", err)
+	}
+}
+
+func (c *fakeConn) closeWith(code int) {
+	_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+	_ = c.ws.Close()
+}
+
+type stateChange struct {
+	connected bool
+	err       error
+}
+
+// testConnOptions 快速重连，连接状态与推送写入返回的通道
+func testConnOptions() (ConnOptions, chan stateChange, chan Event) {
+	states := make(chan stateChange, 16)
+	events := make(chan Event, 16)
+	return ConnOptions{
+		MinBackoff:    10 * time.Millisecond,
+		MaxBackoff:    20 * time.Millisecond,
+		AckTimeout:    2 * time.Second,
+		OnStateChange: func(connected bool, err error) { states <- stateChange{connected, err} },
+		OnEvent:       func(e Event) { events <- e },
+	}, states, events
+}
+
+func waitState(t *testing.T, states chan stateChange) stateChange {
+	t.Helper()
+	select {
+	case s := <-states:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("no state change")
+		return stateChange{}
+	}
+}
+
+func waitEvent(t *testing.T, events chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+// token 无效时握手被拒，不再重连
+func TestConn_HandshakeRejected(t *testing.T) {
+	f := newFakeServer(t, "good")
+	opts, states, _ := testConnOptions()
+	conn := Dial(f.url(), "bad", opts)
+	defer conn.Close()
+
+	s := waitState(t, states)
+	if s.connected || s.err == nil || !strings.Contains(s.err.Error(), "401") {
+		t.Fatalf("state = %+v", s)
+	}
+	if _, err := conn.SendText(context.Background(), 1, "hi"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("send after reject: %v", err)
+	}
+	select {
+	case <-f.conns:
+		t.Fatal("reconnected after handshake rejected")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Send 按 packet_id 匹配服务端回显；被拒绝时返回 SendError（禁言带截止时间）
+func TestConn_SendAck(t *testing.T) {
+	f := newFakeServer(t, "good")
+	opts, states, _ := testConnOptions()
+	c := New("http://unused", WithToken("good"))
+	conn := c.Dial(f.url(), opts)
+	defer conn.Close()
+	server := f.accept(t)
+	if s := waitState(t, states); !s.connected {
+		t.Fatalf("state = %+v", s)
+	}
+
+	type result struct {
+		msg *Message
+		err error
+	}
+	send := func(text string) chan result {
+		ch := make(chan result, 1)
+		go func() {
+			msg, err := conn.SendText(context.Background(), 9, text)
+			ch <- result{msg, err}
+		}()
+		return ch
+	}
+
+	done := send("hello")
+	var req message.Req
+	server.read(t, &req)
+	if req.Type != message.WsTypeMessage || req.SendTo != 9 || req.SendContent != "hello" || req.PacketID == "" {
+		t.Fatalf("request = %+v", req)
+	}
+	// 无关的回显不会误匹配
+	server.push(t,
+		&message.RoomMessageEvent{PacketID: "other", ID: 1, RoomID: 9},
+		&message.RoomMessageEvent{PacketID: req.PacketID, ID: 2, RoomID: 9, Content: "hello"},
+	)
+	if res := <-done; res.err != nil || res.msg.ID != 2 {
+		t.Fatalf("send: %+v", res)
+	}
+
+	done = send("muted")
+	server.read(t, &req)
+	until := time.Now().Add(time.Minute).Truncate(time.Second)
+	ev := message.NewErrorEvent(message.WsErrMuted, "", "你已被禁言", req.PacketID)
+	ev.Data = &service.MuteError{Scope: "user", Until: until, RetryAfter: 60}
+	server.push(t, ev)
+	res := <-done
+	var sendErr *SendError
+	if !errors.As(res.err, &sendErr) || sendErr.Code != response.CodeMuted || !sendErr.Retryable {
+		t.Fatalf("muted: %+v", res)
+	}
+	if sendErr.Mute == nil || !sendErr.Mute.Until.Equal(until) {
+		t.Fatalf("mute detail = %+v", sendErr.Mute)
+	}
+}
+
+// 断线后自动重连并携带 resume_token 与最后收到的 seq；等待中的 Send 返回 ErrDisconnected
+func TestConn_ReconnectResume(t *testing.T) {
+	f := newFakeServer(t, "good")
+	opts, states, events := testConnOptions()
+	conn := Dial(f.url(), "good", opts)
+	defer conn.Close()
+
+	first := f.accept(t)
+	waitState(t, states)
+	if first.query.Get("resume_token") != "" {
+		t.Fatalf("first dial resumes: %v", first.query)
+	}
+	first.push(t, &message.SessionEvent{ResumeToken: "r1", LastEventID: 4})
+	// 积压的推送合并在一帧里
+	if err := first.ws.WriteMessage(websocket.TextMessage, []byte(`{"seq":5,"type":"typing"}{"seq":6,"type":"typing"}`)); err != nil {
+		t.Fatalf("push batch: %v", err)
+	}
+	for _, want := range []string{message.WsEventSession, "typing", "typing"} {
+		if e := waitEvent(t, events); e.Type != want {
+			t.Fatalf("event %s, want %s", e.Type, want)
+		}
+	}
+
+	pending := make(chan error, 1)
+	go func() {
+		_, err := conn.SendText(context.Background(), 1, "lost?")
+		pending <- err
+	}()
+	var req message.Req
+	first.read(t, &req)
+	first.closeWith(websocket.CloseGoingAway)
+
+	if err := <-pending; !errors.Is(err, ErrDisconnected) {
+		t.Fatalf("pending send: %v", err)
+	}
+	if s := waitState(t, states); s.connected {
+		t.Fatalf("state = %+v", s)
+	}
+
+	second := f.accept(t)
+	if s := waitState(t, states); !s.connected {
+		t.Fatalf("state = %+v", s)
+	}
+	if got := second.query; got.Get("resume_token") != "r1" || got.Get("last_event_id") != "6" {
+		t.Fatalf("resume query = %v", got)
+	}
+	second.push(t, &message.SessionEvent{ResumeToken: "r1", LastEventID: 7, Resumed: true, Replayed: 1})
+	if err := second.ws.WriteMessage(websocket.TextMessage, []byte(`{"seq":7,"type":"typing"}`)); err != nil {
+		t.Fatalf("push replay: %v", err)
+	}
+	waitEvent(t, events)
+	waitEvent(t, events)
+	// 再次断线：从补发推进到的 seq 续传
+	second.closeWith(websocket.CloseGoingAway)
+	waitState(t, states)
+	third := f.accept(t)
+	if got := third.query.Get("last_event_id"); got != "7" {
+		t.Fatalf("second resume last_event_id = %s", got)
+	}
+	// 客户端可能还没登记这条连接就被 Close：Close 仍要及时返回
+	closed := make(chan struct{})
+	go func() {
+		_ = conn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked during handshake")
+	}
+}
+
+// 空闲被踢或 token 注销时停止重连
+func TestConn_StopsOnTerminalClose(t *testing.T) {
+	for code, want := range map[int]error{closeIdle: ErrIdleKicked, closeRevoked: ErrTokenRevoked} {
+		f := newFakeServer(t, "good")
+		opts, states, _ := testConnOptions()
+		conn := Dial(f.url(), "good", opts)
+
+		server := f.accept(t)
+		waitState(t, states)
+		server.closeWith(code)
+		if s := waitState(t, states); s.connected || !errors.Is(s.err, want) {
+			t.Fatalf("close %d: state = %+v", code, s)
+		}
+		select {
+		case <-f.conns:
+			t.Fatalf("close %d: reconnected", code)
+		case <-time.After(50 * time.Millisecond):
+		}
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,82 @@
+// chat-server 独立部署的聊天服务：读取配置后启动完整 API + WebSocket，无需编写 Go 代码。
+//
+//	chat-server -config chat-server.yaml
+//	CHAT_DB_DSN="root:pwd@tcp(127.0.0.1:3306)/chat?charset=utf8mb4&parseTime=True&loc=Local" chat-server
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	chat_sdk "github.com/cydxin/chat-sdk"
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	path := flag.String("config", "", "配置文件路径（.yaml/.json），为空时只读 CHAT_* 环境变量")
+	flag.Parse()
+
+	cfg, err := chat_sdk.LoadFileConfig(*path)
+	if err != nil {
+		log.Fatal("加载配置失败: ", err)
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = ":8080"
+	}
+	if cfg.Upload.Dir == "" {
+		cfg.Upload.Dir = "./uploads"
+	}
+	if cfg.Upload.URLPrefix == "" {
+		cfg.Upload.URLPrefix = "/uploads"
+	}
+	if err := os.MkdirAll(cfg.Upload.Dir, 0o755); err != nil {
+		log.Fatal("创建上传目录失败: ", err)
+	}
+	if cfg.Redis.Addr == "" {
+		log.Println("未配置 Redis：登录 token、验证码、长轮询跨实例等功能不可用")
+	}
+
+	opts, err := cfg.Options()
+	if err != nil {
+		log.Fatal(err)
+	}
+	engine := chat_sdk.NewEngine(opts...)
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(gin.Logger(), gin.Recovery())
+	engine.RegisterGinRoutes(r, nil)
+	r.Static(cfg.Upload.URLPrefix, cfg.Upload.Dir)
+	if cfg.Swagger {
+		chat_sdk.RegisterSwagger(r, "/swagger/*any")
+	}
+
+	srv := &http.Server{Addr: cfg.Listen, Handler: r}
+	go func() {
+		log.Printf("chat-server 启动在 %s", cfg.Listen)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("服务器启动失败: ", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("正在关闭...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	if err := engine.WsServer.FlushReadState(); err != nil {
+		log.Printf("flush read state: %v", err)
+	}
+}
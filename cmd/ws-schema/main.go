@@ -0,0 +1,21 @@
+// ws-schema 导出 WS 下行事件的 JSON Schema（key 为事件 type），供其他语言的客户端生成类型或校验。
+//
+//	go run ./cmd/ws-schema > ws-events.schema.json
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/cydxin/chat-sdk/message"
+)
+
+func main() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(message.EventSchemas()); err != nil {
+		log.Fatal(err)
+	}
+}
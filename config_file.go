@@ -0,0 +1,431 @@
+package chat_sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/redis/go-redis/v9"
+	"go.yaml.in/yaml/v3"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Duration 配置文件中的时长，支持 time.ParseDuration 格式（"30s"、"72h"）及按天的 "7d"
+type Duration time.Duration
+
+// UnmarshalText 同时用于 YAML 与 JSON 解析
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := parseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// FileConfig 配置文件结构（YAML / JSON），字段均可被 CHAT_* 环境变量覆盖，见 LoadFileConfig
+type FileConfig struct {
+	// Listen / Swagger 仅 cmd/chat-server 使用
+	Listen  string `yaml:"listen" json:"listen"`
+	Swagger bool   `yaml:"swagger" json:"swagger"`
+
+	TablePrefix      string `yaml:"table_prefix" json:"table_prefix"`
+	Language         string `yaml:"language" json:"language"`
+	LegacyHTTPStatus bool   `yaml:"legacy_http_status" json:"legacy_http_status"`
+	AdminToken       string `yaml:"admin_token" json:"admin_token"`
+	NamecardSecret   string `yaml:"namecard_secret" json:"namecard_secret"`
+
+	DB struct {
+		// DSN MySQL 连接串，必填
+		DSN string `yaml:"dsn" json:"dsn"`
+		// Replicas 只读从库连接串，为空则读写都走主库
+		Replicas []string `yaml:"replicas" json:"replicas"`
+		// PrimaryReads 强制读主库的读取范围：conversation/message/search/moment
+		PrimaryReads []string `yaml:"primary_reads" json:"primary_reads"`
+	} `yaml:"db" json:"db"`
+
+	Redis struct {
+		// Addr 单机地址；Addr 与 Addrs 都为空则不连接 Redis
+		Addr string `yaml:"addr" json:"addr"`
+		// Addrs 多个地址：配置 MasterName 时为 Sentinel 地址，否则按 Cluster 节点连接
+		Addrs []string `yaml:"addrs" json:"addrs"`
+		// MasterName Sentinel 主节点名
+		MasterName string `yaml:"master_name" json:"master_name"`
+		Password   string `yaml:"password" json:"password"`
+		// DB Cluster 模式下忽略
+		DB int `yaml:"db" json:"db"`
+	} `yaml:"redis" json:"redis"`
+
+	Token struct {
+		// TTL 登录 token 有效期，默认 7d
+		TTL Duration `yaml:"ttl" json:"ttl"`
+	} `yaml:"token" json:"token"`
+
+	WS struct {
+		MaxMessageSize int64    `yaml:"max_message_size" json:"max_message_size"`
+		PongWait       Duration `yaml:"pong_wait" json:"pong_wait"`
+		WriteWait      Duration `yaml:"write_wait" json:"write_wait"`
+		SendBuffer     int      `yaml:"send_buffer" json:"send_buffer"`
+		// DropPolicy 发送缓冲满时的处理：newest（默认）/ oldest / disconnect
+		DropPolicy string `yaml:"drop_policy" json:"drop_policy"`
+		// AwayAfter / KickAfter 空闲策略（见 WsIdlePolicy），为空关闭
+		AwayAfter Duration `yaml:"away_after" json:"away_after"`
+		KickAfter Duration `yaml:"kick_after" json:"kick_after"`
+	} `yaml:"ws" json:"ws"`
+
+	Retention struct {
+		// Messages 历史消息保留时长（如 180d），为空永久保留
+		Messages Duration `yaml:"messages" json:"messages"`
+		// Notifications 通知投递保留时长（如 30d），为空永久保留
+		Notifications Duration `yaml:"notifications" json:"notifications"`
+		// FriendRequests 待处理好友申请的有效期（如 7d），为空永不过期
+		FriendRequests Duration `yaml:"friend_requests" json:"friend_requests"`
+	} `yaml:"retention" json:"retention"`
+
+	Upload struct {
+		// Dir 本地上传目录（群头像合成输出）
+		Dir string `yaml:"dir" json:"dir"`
+		// URLPrefix 上传文件对外访问前缀（写库用）
+		URLPrefix string `yaml:"url_prefix" json:"url_prefix"`
+	} `yaml:"upload" json:"upload"`
+
+	Storage struct {
+		// Type local（默认，写 upload.dir）/ s3（S3 兼容对象存储：AWS S3、MinIO、OSS 等）
+		Type            string `yaml:"type" json:"type"`
+		Endpoint        string `yaml:"endpoint" json:"endpoint"`
+		Region          string `yaml:"region" json:"region"`
+		Bucket          string `yaml:"bucket" json:"bucket"`
+		AccessKeyID     string `yaml:"access_key_id" json:"access_key_id"`
+		SecretAccessKey string `yaml:"secret_access_key" json:"secret_access_key"`
+		// PathStyle 使用 endpoint/bucket/key 形式访问（MinIO 需开启）
+		PathStyle bool `yaml:"path_style" json:"path_style"`
+		// PublicURL 对外访问前缀（CDN 域名），为空时使用存储地址
+		PublicURL string `yaml:"public_url" json:"public_url"`
+		// Prefix 对象 key 前缀
+		Prefix string `yaml:"prefix" json:"prefix"`
+	} `yaml:"storage" json:"storage"`
+
+	GroupAvatar struct {
+		// Disabled 关闭群头像自动合成
+		Disabled bool `yaml:"disabled" json:"disabled"`
+		// Debounce 成员变动后延迟多久重新合成（如 3s）
+		Debounce Duration `yaml:"debounce" json:"debounce"`
+	} `yaml:"group_avatar" json:"group_avatar"`
+}
+
+// LoadConfig 读取配置文件（.json 按 JSON 解析，其余按 YAML）并叠加 CHAT_* 环境变量，
+// 连接数据库/Redis 后返回 NewEngine 所需的 Option 列表。path 为空时只读环境变量。
+//
+// 使用示例:
+//
+//	opts, err := chat_sdk.LoadConfig("chat.yaml")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	engine := chat_sdk.NewEngine(append(opts, chat_sdk.WithWallet(myWallet))...)
+func LoadConfig(path string) ([]Option, error) {
+	fc, err := LoadFileConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return fc.Options()
+}
+
+// LoadFileConfig 只解析配置（文件 + 环境变量），不建立连接
+func LoadFileConfig(path string) (*FileConfig, error) {
+	fc := &FileConfig{}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			err = json.Unmarshal(b, fc)
+		} else {
+			err = yaml.Unmarshal(b, fc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	if err := fc.applyEnv(); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// applyEnv 环境变量覆盖（仅覆盖已设置的变量）
+func (fc *FileConfig) applyEnv() error {
+	e := envReader{}
+	e.str(&fc.Listen, "CHAT_LISTEN")
+	e.boolean(&fc.Swagger, "CHAT_SWAGGER")
+	e.str(&fc.TablePrefix, "CHAT_TABLE_PREFIX")
+	e.str(&fc.Language, "CHAT_LANGUAGE")
+	e.boolean(&fc.LegacyHTTPStatus, "CHAT_LEGACY_HTTP_STATUS")
+	e.str(&fc.AdminToken, "CHAT_ADMIN_TOKEN")
+	e.str(&fc.NamecardSecret, "CHAT_NAMECARD_SECRET")
+	e.str(&fc.DB.DSN, "CHAT_DB_DSN")
+	e.list(&fc.DB.Replicas, "CHAT_DB_REPLICAS")
+	e.list(&fc.DB.PrimaryReads, "CHAT_DB_PRIMARY_READS")
+	e.str(&fc.Redis.Addr, "CHAT_REDIS_ADDR")
+	e.list(&fc.Redis.Addrs, "CHAT_REDIS_ADDRS")
+	e.str(&fc.Redis.MasterName, "CHAT_REDIS_MASTER_NAME")
+	e.str(&fc.Redis.Password, "CHAT_REDIS_PASSWORD")
+	e.integer(&fc.Redis.DB, "CHAT_REDIS_DB")
+	e.duration(&fc.Token.TTL, "CHAT_TOKEN_TTL")
+	e.int64(&fc.WS.MaxMessageSize, "CHAT_WS_MAX_MESSAGE_SIZE")
+	e.duration(&fc.WS.PongWait, "CHAT_WS_PONG_WAIT")
+	e.duration(&fc.WS.WriteWait, "CHAT_WS_WRITE_WAIT")
+	e.integer(&fc.WS.SendBuffer, "CHAT_WS_SEND_BUFFER")
+	e.str(&fc.WS.DropPolicy, "CHAT_WS_DROP_POLICY")
+	e.duration(&fc.WS.AwayAfter, "CHAT_WS_AWAY_AFTER")
+	e.duration(&fc.WS.KickAfter, "CHAT_WS_KICK_AFTER")
+	e.duration(&fc.Retention.Messages, "CHAT_MESSAGE_RETENTION")
+	e.duration(&fc.Retention.Notifications, "CHAT_NOTIFICATION_RETENTION")
+	e.duration(&fc.Retention.FriendRequests, "CHAT_FRIEND_REQUEST_TTL")
+	e.str(&fc.Upload.Dir, "CHAT_UPLOAD_DIR")
+	e.str(&fc.Upload.URLPrefix, "CHAT_UPLOAD_URL_PREFIX")
+	e.str(&fc.Storage.Type, "CHAT_STORAGE_TYPE")
+	e.str(&fc.Storage.Endpoint, "CHAT_STORAGE_ENDPOINT")
+	e.str(&fc.Storage.Region, "CHAT_STORAGE_REGION")
+	e.str(&fc.Storage.Bucket, "CHAT_STORAGE_BUCKET")
+	e.str(&fc.Storage.AccessKeyID, "CHAT_STORAGE_ACCESS_KEY_ID")
+	e.str(&fc.Storage.SecretAccessKey, "CHAT_STORAGE_SECRET_ACCESS_KEY")
+	e.boolean(&fc.Storage.PathStyle, "CHAT_STORAGE_PATH_STYLE")
+	e.str(&fc.Storage.PublicURL, "CHAT_STORAGE_PUBLIC_URL")
+	e.str(&fc.Storage.Prefix, "CHAT_STORAGE_PREFIX")
+	e.boolean(&fc.GroupAvatar.Disabled, "CHAT_GROUP_AVATAR_DISABLED")
+	e.duration(&fc.GroupAvatar.Debounce, "CHAT_GROUP_AVATAR_DEBOUNCE")
+	return e.err
+}
+
+// Options 连接数据库/Redis，并把配置转换为 Option 列表
+func (fc *FileConfig) Options() ([]Option, error) {
+	if fc.DB.DSN == "" {
+		return nil, fmt.Errorf("缺少数据库配置：db.dsn 或 CHAT_DB_DSN")
+	}
+	dropPolicy, err := parseWsDropPolicy(fc.WS.DropPolicy)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := fc.fileStorage()
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(mysql.Open(fc.DB.DSN), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("数据库连接失败: %w", err)
+	}
+	replicas := make([]*gorm.DB, 0, len(fc.DB.Replicas))
+	for i, dsn := range fc.DB.Replicas {
+		r, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+		if err != nil {
+			return nil, fmt.Errorf("从库 %d 连接失败: %w", i, err)
+		}
+		replicas = append(replicas, r)
+	}
+
+	opts := []Option{
+		WithDB(db),
+		WithDBReplicas(replicas...),
+		WithPrimaryReads(fc.DB.PrimaryReads...),
+		WithAdminToken(fc.AdminToken),
+		WithNamecardSecret(fc.NamecardSecret),
+		WithLegacyHTTPStatus(fc.LegacyHTTPStatus),
+		WithTokenTTL(time.Duration(fc.Token.TTL)),
+		WithWsLimits(WsLimits{
+			MaxMessageSize: fc.WS.MaxMessageSize,
+			PongWait:       time.Duration(fc.WS.PongWait),
+			WriteWait:      time.Duration(fc.WS.WriteWait),
+			SendBuffer:     fc.WS.SendBuffer,
+			DropPolicy:     dropPolicy,
+		}),
+		WithWsIdlePolicy(WsIdlePolicy{
+			AwayAfter: time.Duration(fc.WS.AwayAfter),
+			KickAfter: time.Duration(fc.WS.KickAfter),
+		}),
+		WithMessageRetention(time.Duration(fc.Retention.Messages)),
+		WithNotificationRetention(time.Duration(fc.Retention.Notifications)),
+		WithFriendRequestExpiry(time.Duration(fc.Retention.FriendRequests)),
+	}
+	if fc.TablePrefix != "" {
+		opts = append(opts, WithTablePrefix(fc.TablePrefix))
+	}
+	if fc.Language != "" {
+		opts = append(opts, WithLanguage(fc.Language))
+	}
+	if addrs := fc.redisAddrs(); len(addrs) > 0 {
+		opts = append(opts, WithRDB(redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:      addrs,
+			MasterName: fc.Redis.MasterName,
+			Password:   fc.Redis.Password,
+			DB:         fc.Redis.DB,
+		})))
+	} else {
+		// 单机无 Redis：token / 验证码存进程内存（重启后需重新登录）
+		opts = append(opts, WithKVStore(service.NewMemoryKVStore()))
+	}
+	if fc.Upload.Dir != "" || fc.Upload.URLPrefix != "" {
+		// 只改存储位置，保留群头像合成的其他默认参数
+		dir, prefix := fc.Upload.Dir, fc.Upload.URLPrefix
+		opts = append(opts, func(c *Config) {
+			c.GroupAvatarMerge.OutputDir = dir
+			c.GroupAvatarMerge.URLPrefix = prefix
+		})
+	}
+	if storage != nil {
+		opts = append(opts, WithFileStorage(storage))
+	}
+	if fc.GroupAvatar.Disabled || fc.GroupAvatar.Debounce > 0 {
+		disabled, debounce := fc.GroupAvatar.Disabled, time.Duration(fc.GroupAvatar.Debounce)
+		opts = append(opts, func(c *Config) {
+			c.GroupAvatarMerge.Enabled = !disabled
+			c.GroupAvatarMerge.Debounce = debounce
+		})
+	}
+	return opts, nil
+}
+
+// envReader 读取 CHAT_* 环境变量，记录第一个解析错误
+type envReader struct {
+	err error
+}
+
+// redisAddrs Addrs 优先，否则使用单机 Addr
+func parseWsDropPolicy(v string) (WsDropPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "newest":
+		return WsDropNewest, nil
+	case "oldest":
+		return WsDropOldest, nil
+	case "disconnect":
+		return WsDropDisconnect, nil
+	}
+	return 0, fmt.Errorf("ws.drop_policy 只支持 newest/oldest/disconnect: %q", v)
+}
+
+// fileStorage storage.type=s3 时创建对象存储，local 返回 nil（使用引擎默认的本地目录）
+func (fc *FileConfig) fileStorage() (service.FileStorage, error) {
+	st := fc.Storage
+	switch strings.ToLower(strings.TrimSpace(st.Type)) {
+	case "", "local":
+		return nil, nil
+	case "s3":
+		if st.Endpoint == "" || st.Bucket == "" || st.AccessKeyID == "" || st.SecretAccessKey == "" {
+			return nil, fmt.Errorf("storage.type=s3 需要 endpoint、bucket、access_key_id、secret_access_key")
+		}
+		return &service.S3FileStorage{
+			Endpoint:        st.Endpoint,
+			Region:          st.Region,
+			Bucket:          st.Bucket,
+			AccessKeyID:     st.AccessKeyID,
+			SecretAccessKey: st.SecretAccessKey,
+			PathStyle:       st.PathStyle,
+			PublicURL:       st.PublicURL,
+			Prefix:          st.Prefix,
+		}, nil
+	}
+	return nil, fmt.Errorf("storage.type 只支持 local/s3: %q", st.Type)
+}
+
+func (fc *FileConfig) redisAddrs() []string {
+	var out []string
+	for _, a := range fc.Redis.Addrs {
+		if a = strings.TrimSpace(a); a != "" {
+			out = append(out, a)
+		}
+	}
+	if len(out) == 0 && fc.Redis.Addr != "" {
+		out = []string{fc.Redis.Addr}
+	}
+	return out
+}
+
+func (e *envReader) lookup(key string) (string, bool) {
+	if e.err != nil {
+		return "", false
+	}
+	return os.LookupEnv(key)
+}
+
+func (e *envReader) fail(key, v string, err error) {
+	e.err = fmt.Errorf("环境变量 %s=%q 无效: %w", key, v, err)
+}
+
+func (e *envReader) str(dst *string, key string) {
+	if v, ok := e.lookup(key); ok {
+		*dst = v
+	}
+}
+
+// list 逗号分隔
+func (e *envReader) list(dst *[]string, key string) {
+	if v, ok := e.lookup(key); ok {
+		*dst = strings.Split(v, ",")
+	}
+}
+
+func (e *envReader) boolean(dst *bool, key string) {
+	if v, ok := e.lookup(key); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			e.fail(key, v, err)
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e *envReader) integer(dst *int, key string) {
+	if v, ok := e.lookup(key); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.fail(key, v, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) int64(dst *int64, key string) {
+	if v, ok := e.lookup(key); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			e.fail(key, v, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) duration(dst *Duration, key string) {
+	if v, ok := e.lookup(key); ok {
+		d, err := parseDuration(v)
+		if err != nil {
+			e.fail(key, v, err)
+			return
+		}
+		*dst = Duration(d)
+	}
+}
@@ -52,4 +52,14 @@
 // @in query
 // @name token
 // @description 用于 WebSocket 等无法传 header 的场景
+//
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name X-Admin-Token
+// @description 运维/管理接口令牌（chat_sdk.WithAdminToken 配置）
+//
+// @securityDefinitions.apikey ComplianceToken
+// @in header
+// @name X-Compliance-Token
+// @description 合规导出接口令牌（chat_sdk.WithComplianceToken 配置）
 package chat_sdk
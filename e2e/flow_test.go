@@ -0,0 +1,258 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/client"
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+// eventTimeout 等待 WS 推送的最长时间
+const eventTimeout = 5 * time.Second
+
+var userSeq atomic.Int64
+
+// user 已登录并建立 WS 连接的测试用户
+type user struct {
+	*client.Client
+	ID     uint64
+	conn   *client.Conn
+	events chan client.Event
+}
+
+// newUser 注册 + 登录 + 建立 WS 连接，测试结束自动断开
+func newUser(t *testing.T, name string) *user {
+	t.Helper()
+	ctx := context.Background()
+	n := userSeq.Add(1)
+	username := fmt.Sprintf("%s_%d_%d", name, time.Now().UnixNano()%1e6, n)
+	email := username + "@e2e.test"
+
+	c := client.New(apiURL)
+	code, err := c.SendVerifyCode(ctx, string(service.VerifyCodePurposeRegister), email, "")
+	if err != nil {
+		t.Fatalf("send code: %v", err)
+	}
+	if err := c.Register(ctx, service.RegisterReq{
+		Username: username,
+		Email:    email,
+		NickName: name,
+		Password: "Passw0rd!",
+		Code:     code.Code,
+	}); err != nil {
+		t.Fatalf("register %s: %v", username, err)
+	}
+	resp, err := c.Login(ctx, service.LoginReq{Account: username, Password: "Passw0rd!"})
+	if err != nil {
+		t.Fatalf("login %s: %v", username, err)
+	}
+
+	u := &user{Client: c, ID: resp.User.ID, events: make(chan client.Event, 256)}
+	connected := make(chan struct{}, 1)
+	u.conn = c.Dial(wsURL, client.ConnOptions{
+		OnEvent: func(e client.Event) { u.events <- e },
+		OnStateChange: func(ok bool, _ error) {
+			if ok {
+				select {
+				case connected <- struct{}{}:
+				default:
+				}
+			}
+		},
+	})
+	t.Cleanup(func() { _ = u.conn.Close() })
+	select {
+	case <-connected:
+	case <-time.After(eventTimeout):
+		t.Fatalf("%s: ws not connected", username)
+	}
+	return u
+}
+
+// waitEvent 等待满足 match 的推送（跳过其他事件）
+func (u *user) waitEvent(t *testing.T, desc string, match func(client.Event) bool) client.Event {
+	t.Helper()
+	deadline := time.After(eventTimeout)
+	for {
+		select {
+		case e := <-u.events:
+			if match(e) {
+				return e
+			}
+		case <-deadline:
+			t.Fatalf("user %d: timeout waiting for %s", u.ID, desc)
+		}
+	}
+}
+
+// waitMessage 等待 room 中 id 为 msgID 的消息推送
+func (u *user) waitMessage(t *testing.T, roomID, msgID uint64) client.Message {
+	t.Helper()
+	var m client.Message
+	u.waitEvent(t, fmt.Sprintf("message %d", msgID), func(e client.Event) bool {
+		if e.Type != "message" || e.Decode(&m) != nil {
+			return false
+		}
+		return m.RoomID == roomID && m.ID == msgID
+	})
+	return m
+}
+
+// befriend a 发起申请，b 同意
+func befriend(t *testing.T, a, b *user) {
+	t.Helper()
+	ctx := context.Background()
+	if err := a.SendFriendRequest(ctx, b.ID, "hi"); err != nil {
+		t.Fatalf("friend request: %v", err)
+	}
+	pending, err := b.GetPendingRequests(ctx)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	var reqID uint64
+	for _, p := range pending {
+		if p.FromUser.ID == a.ID {
+			reqID = p.ID
+		}
+	}
+	if reqID == 0 {
+		t.Fatalf("friend request from %d not found in %+v", a.ID, pending)
+	}
+	if err := b.AcceptFriendRequest(ctx, reqID); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+}
+
+func TestFriendAndPrivateChat(t *testing.T) {
+	ctx := context.Background()
+	alice, bob := newUser(t, "alice"), newUser(t, "bob")
+
+	befriend(t, alice, bob)
+	friends, err := alice.GetFriendList(ctx)
+	if err != nil {
+		t.Fatalf("friend list: %v", err)
+	}
+	if len(friends) != 1 || friends[0].ID != bob.ID {
+		t.Fatalf("alice friends = %+v, want bob", friends)
+	}
+
+	room, err := alice.CreatePrivateRoom(ctx, bob.ID)
+	if err != nil {
+		t.Fatalf("private room: %v", err)
+	}
+
+	sent, err := alice.conn.SendText(ctx, room.ID, "hello bob")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	got := bob.waitMessage(t, room.ID, sent.ID)
+	if got.Content != "hello bob" || got.SenderID != alice.ID {
+		t.Fatalf("bob got %+v", got)
+	}
+
+	// 正在输入只推给对方
+	if err := bob.conn.Typing(room.ID); err != nil {
+		t.Fatalf("typing: %v", err)
+	}
+	alice.waitEvent(t, "typing", func(e client.Event) bool { return e.Type == service.EventTyping })
+
+	// 已读回执推进消息状态，发送者收到 message_status
+	if err := bob.conn.ReadAck(room.ID, sent.ID); err != nil {
+		t.Fatalf("read ack: %v", err)
+	}
+	alice.waitEvent(t, "message_status", func(e client.Event) bool { return e.Type == service.EventMessageStatus })
+
+	history, err := bob.GetRoomMessages(ctx, room.ID, 20, 0)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) == 0 || history[0].ID != sent.ID {
+		t.Fatalf("history = %+v, want latest %d", history, sent.ID)
+	}
+}
+
+func TestGroupChatAndRecall(t *testing.T) {
+	ctx := context.Background()
+	alice, bob, carol := newUser(t, "alice"), newUser(t, "bob"), newUser(t, "carol")
+	befriend(t, alice, bob)
+	befriend(t, alice, carol)
+
+	if err := alice.CreateGroupRoom(ctx, "e2e group", []uint64{bob.ID, carol.ID}); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	groups, err := alice.GetGroupRooms(ctx)
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("groups = %+v, err = %v", groups, err)
+	}
+	roomID := groups[0].ID
+
+	sent, err := alice.conn.SendText(ctx, roomID, "hello group")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	bob.waitMessage(t, roomID, sent.ID)
+	carol.waitMessage(t, roomID, sent.ID)
+
+	// 非成员发送被拒绝
+	dave := newUser(t, "dave")
+	_, err = dave.conn.SendText(ctx, roomID, "let me in")
+	if _, ok := err.(*client.SendError); !ok {
+		t.Fatalf("non-member send err = %v, want *client.SendError", err)
+	}
+
+	// HTTP 发送与 WS 同一流程：成员收到推送（含 packet_id），非成员同样被拒绝
+	viaHTTP, err := bob.SendMessage(ctx, message.Req{SendTo: roomID, SendType: 1, SendContent: "from http", PacketID: "http-1"})
+	if err != nil {
+		t.Fatalf("http send: %v", err)
+	}
+	if got := carol.waitMessage(t, roomID, viaHTTP.ID); got.Content != "from http" || got.SenderID != bob.ID {
+		t.Fatalf("carol got %+v", got)
+	}
+	if got := bob.waitMessage(t, roomID, viaHTTP.ID); got.PacketID != "http-1" {
+		t.Fatalf("bob echo %+v", got)
+	}
+	// 房间序号按落库顺序连续递增
+	if viaHTTP.RoomSeq != sent.RoomSeq+1 || viaHTTP.SendTime < sent.SendTime {
+		t.Fatalf("room_seq %d after %d, send_time %d after %d", viaHTTP.RoomSeq, sent.RoomSeq, viaHTTP.SendTime, sent.SendTime)
+	}
+	var apiErr *client.APIError
+	if _, err := dave.SendMessage(ctx, message.Req{SendTo: roomID, SendType: 1, SendContent: "via http"}); !errors.As(err, &apiErr) || apiErr.Code != response.CodePermissionDeny {
+		t.Fatalf("non-member http send err = %v", err)
+	}
+
+	res, err := alice.RecallMessages(ctx, []uint64{sent.ID}, models.MessageStatusRecalled)
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if len(res.SuccessIDs) != 1 {
+		t.Fatalf("recall result = %+v", res)
+	}
+	for _, u := range []*user{bob, carol} {
+		u.waitEvent(t, "recall notification", func(e client.Event) bool {
+			var n struct {
+				EventType string `json:"event_type"`
+				RoomID    uint64 `json:"room_id"`
+			}
+			return e.Type == service.EventNotification && e.Decode(&n) == nil &&
+				n.EventType == service.EventRecall && n.RoomID == roomID
+		})
+	}
+
+	// 退群后不再是成员
+	if err := carol.QuitGroup(ctx, roomID); err != nil {
+		t.Fatalf("quit: %v", err)
+	}
+	if _, err := carol.conn.SendText(ctx, roomID, "still here?"); err == nil {
+		t.Fatal("send after quit should fail")
+	}
+}
@@ -0,0 +1,125 @@
+//go:build e2e
+
+// Package e2e 端到端测试：用 dockertest 启动 MySQL + Redis，引擎挂在 httptest 上，
+// 通过 client 包走真实的 HTTP + WebSocket。需要本机 Docker：
+//
+//	go test -tags e2e ./e2e/...
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	chat_sdk "github.com/cydxin/chat-sdk"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	apiURL string // http://127.0.0.1:xxx/api/v1
+	wsURL  string // ws://127.0.0.1:xxx/ws
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		log.Printf("dockertest: %v", err)
+		return 1
+	}
+	pool.MaxWait = 2 * time.Minute
+
+	mysqlRes, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "mysql",
+		Tag:        "8.0",
+		Env:        []string{"MYSQL_ROOT_PASSWORD=secret", "MYSQL_DATABASE=chat_e2e"},
+	}, autoRemove)
+	if err != nil {
+		log.Printf("start mysql: %v", err)
+		return 1
+	}
+	defer purge(pool, mysqlRes)
+
+	redisRes, err := pool.RunWithOptions(&dockertest.RunOptions{Repository: "redis", Tag: "7-alpine"}, autoRemove)
+	if err != nil {
+		log.Printf("start redis: %v", err)
+		return 1
+	}
+	defer purge(pool, redisRes)
+
+	dsn := fmt.Sprintf("root:secret@tcp(localhost:%s)/chat_e2e?charset=utf8mb4&parseTime=True&loc=Local", mysqlRes.GetPort("3306/tcp"))
+	var db *gorm.DB
+	if err := pool.Retry(func() error {
+		var err error
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			return err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Ping()
+	}); err != nil {
+		log.Printf("connect mysql: %v", err)
+		return 1
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:" + redisRes.GetPort("6379/tcp")})
+	if err := pool.Retry(func() error { return rdb.Ping(context.Background()).Err() }); err != nil {
+		log.Printf("connect redis: %v", err)
+		return 1
+	}
+
+	avatarDir, err := os.MkdirTemp("", "chat-e2e-avatar")
+	if err != nil {
+		log.Printf("temp dir: %v", err)
+		return 1
+	}
+	defer os.RemoveAll(avatarDir)
+
+	// Debug 模式下发送验证码接口会返回验证码，便于走注册流程
+	engine := chat_sdk.NewEngine(
+		chat_sdk.WithDB(db),
+		chat_sdk.WithRDB(rdb),
+		chat_sdk.WithServiceDebug(true),
+		chat_sdk.WithLinkPreview(false),
+		chat_sdk.WithGroupAvatarMergeConfig(chat_sdk.GroupAvatarMergeConfig{OutputDir: avatarDir}),
+		// 所有测试用户都从本机注册，关闭验证码配额
+		chat_sdk.WithSendCodeLimits(service.SendCodeLimits{}),
+	)
+
+	gin.SetMode(gin.TestMode)
+	srv := httptest.NewServer(engine.Handler(nil))
+	defer srv.Close()
+	apiURL = srv.URL + "/api/v1"
+	wsURL = "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	return m.Run()
+}
+
+func autoRemove(hc *docker.HostConfig) {
+	hc.AutoRemove = true
+	hc.RestartPolicy = docker.RestartPolicy{Name: "no"}
+}
+
+func purge(pool *dockertest.Pool, res *dockertest.Resource) {
+	if err := pool.Purge(res); err != nil {
+		log.Printf("purge %s: %v", res.Container.Name, err)
+	}
+}
@@ -1,13 +1,20 @@
 package chat_sdk
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/cydxin/chat-sdk/middleware"
 	model "github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
 	"github.com/cydxin/chat-sdk/service"
 	"github.com/gin-gonic/gin"
 )
@@ -23,6 +30,26 @@ type ChatEngine struct {
 	MomentService       *service.MomentService
 	ConversationService *service.ConversationService
 	NotificationService *service.NotificationService
+	DisplayNameResolver *service.DisplayNameResolver
+	BotService          *service.BotService
+	AutoReplyService    *service.AutoReplyService
+	HelpDeskService     *service.HelpDeskService
+	LinkPreviewService  *service.LinkPreviewService // 未开启时为 nil
+	RedPacketService    *service.RedPacketService
+	PollService         *service.PollService
+	CheckInService      *service.CheckInService
+	GeoService          *service.GeoService
+	NamecardService     *service.NamecardService
+	RoomStatsService    *service.RoomStatsService
+	ServerStatsService  *service.ServerStatsService
+	AccountService      *service.AccountService
+	AntiSpamService     *service.AntiSpamService
+	SecurityService     *service.SecurityService
+	ProvisionService    *service.ProvisionService
+	OrgService          *service.OrgService        // 未开启时为 nil
+	ComplianceService   *service.ComplianceService // 未开启合规归档时为 nil
+	DeliverySLA         *service.DeliverySLATracker
+	LoadShedder         *service.LoadShedder // 未开启过载保护时为 nil
 	WsServer            *WsServer
 }
 
@@ -32,107 +59,344 @@ var (
 )
 
 // NewEngine 创建实例
-// 使用选项模式传入配置，Option回调
+// 使用选项模式传入配置，Option回调；缺少必填配置（如 WithDB）时直接 panic，避免带着半初始化的服务启动
 func NewEngine(opts ...Option) *ChatEngine {
 	once.Do(func() {
-		c := &Config{
-			TablePrefix: "im_", // Default
-			GroupAvatarMerge: GroupAvatarMergeConfig{
-				Enabled:    true,
-				CanvasSize: 256,
-				Padding:    8,
-				Gap:        4,
-				Timeout:    5 * time.Second,
-				OutputDir:  "",
-				URLPrefix:  "",
-			},
-		}
-		for _, opt := range opts {
-			opt(c)
+		e, err := newEngine(applyOptions(opts))
+		if err != nil {
+			panic(err)
 		}
+		e.startLoops()
+	})
+
+	return Instance
+}
+
+// applyOptions 默认配置 + Option 回调
+func applyOptions(opts []Option) *Config {
+	c := &Config{
+		TablePrefix:        "im_", // Default
+		LinkPreviewEnabled: true,
+		AntiSpamLimits:     service.DefaultAntiSpamLimits,
+		SendCodeLimits:     service.DefaultSendCodeLimits,
+		RecallPolicy:       service.DefaultRecallPolicy,
+		FriendDeletePolicy: service.DefaultFriendDeletePolicy,
+		TxRetry:            service.DefaultTxRetryPolicy,
+		GroupAvatarMerge: GroupAvatarMergeConfig{
+			Enabled:    true,
+			CanvasSize: 256,
+			Padding:    8,
+			Gap:        4,
+			Timeout:    5 * time.Second,
+			OutputDir:  "",
+			URLPrefix:  "",
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// newEngine 按配置组装引擎并设为 Instance（迁移表、绑定 WS 回调，不启动定时任务）
+func newEngine(c *Config) (*ChatEngine, error) {
+	if c.DB == nil {
+		return nil, errors.New("chat_sdk: DB is required (WithDB)")
+	}
+	response.LegacyHTTPStatus = c.LegacyHTTPStatus
+	if c.Language != "" {
+		response.DefaultLang = response.ParseAcceptLanguage(c.Language)
+	}
 
-		Instance = &ChatEngine{config: c}
-
-		// 初始化 WS
-		Instance.WsServer = NewWsServer()
-		go Instance.WsServer.Run()
-
-		// 初始化基础 Service，注入 WsNotifier 回调
-		baseService := &service.Service{
-			DB:          c.DB,
-			RDB:         c.RDB,
-			TablePrefix: c.TablePrefix,
-			WsNotifier:  Instance.WsServer.SendToUser, // 注入 WebSocket 通知函数
-			GroupAvatarMergeConfig: &service.GroupAvatarMergeConfig{
-				Enabled:    c.GroupAvatarMerge.Enabled,
-				CanvasSize: c.GroupAvatarMerge.CanvasSize,
-				Padding:    c.GroupAvatarMerge.Padding,
-				Gap:        c.GroupAvatarMerge.Gap,
-				Timeout:    c.GroupAvatarMerge.Timeout,
-				OutputDir:  c.GroupAvatarMerge.OutputDir,
-				URLPrefix:  c.GroupAvatarMerge.URLPrefix,
-			},
-			OnlineUserGetter: func(userID uint64) (string, string, bool) {
-				Instance.WsServer.mu.RLock()
-				sess := Instance.WsServer.Sessions[userID]
-				Instance.WsServer.mu.RUnlock()
-				if sess == nil {
-					return "", "", false
-				}
-				return sess.Nickname, sess.Avatar, true
-			},
-			SessionReadGetter: func(userID uint64) map[uint64]uint64 {
-				Instance.WsServer.mu.RLock()
-				sess := Instance.WsServer.Sessions[userID]
-				Instance.WsServer.mu.RUnlock()
-				if sess == nil {
-					return nil
-				}
-				sess.ReadMu.Lock()
-				defer sess.ReadMu.Unlock()
-				if len(sess.ReadList) == 0 {
-					return nil
-				}
-				snap := make(map[uint64]uint64, len(sess.ReadList))
-				for k, v := range sess.ReadList {
-					snap[k] = v
-				}
-				return snap
-			},
+	c.RDB = service.NormalizeRedisClient(c.RDB)
+	if c.KVStore == nil && c.RDB != nil {
+		c.KVStore = service.NewRedisKVStore(c.RDB)
+	}
+
+	Instance = &ChatEngine{config: c}
+
+	// 初始化 WS
+	Instance.WsServer = NewWsServer()
+	Instance.WsServer.limits = c.WsLimits.withDefaults()
+	Instance.WsServer.idle = c.WsIdlePolicy
+	Instance.WsServer.roomOnlineEvery = c.RoomOnlineFeed
+	// 在线状态变化：写入 user.online_status，再交给业务回调
+	Instance.WsServer.onPresence = func(ev PresenceEvent) {
+		if err := Instance.UserService.SetOnlineStatus(ev.UserID, ev.To, ev.At); err != nil {
+			log.Printf("update online status failed: user=%d err=%v", ev.UserID, err)
 		}
-		// 注入通知服务（统一落库 + WS 推送 + HTTP 拉取）
-		baseService.Notify = service.NewNotificationService(baseService)
-		// 注入已读回执服务（延迟落库）
-		baseService.ReadReceipt = service.NewReadReceiptService(baseService)
-		// 注入 WS 会话加载服务（建连时拉取已读游标）
-		baseService.SessionBootstrap = service.NewSessionBootstrapService(baseService)
-
-		// 初始化各个 Service
-		Instance.UserService = service.NewUserService(baseService)
-		Instance.RoomService = service.NewRoomService(baseService)
-		Instance.MsgService = service.NewMessageService(baseService)
-		Instance.MemberService = service.NewMemberService(baseService)
-		Instance.MomentService = service.NewMomentService(baseService)
-		Instance.ConversationService = service.NewConversationService(baseService)
-		Instance.NotificationService = baseService.Notify
-		Instance.AuthService = service.NewAuthService(c.RDB) // 初始化鉴权服务
-
-		// 迁移表
-		if err := Instance.AutoMigrate(); err != nil {
-			log.Printf("AutoMigrate failed: %v", err)
+		if c.PresenceHook != nil {
+			c.PresenceHook(ev)
 		}
+	}
+	// 建连时订阅所在房间（房间级扇出，见 WsServer.PublishToRoom）
+	Instance.WsServer.roomLoader = func(userID uint64) ([]uint64, error) {
+		var roomIDs []uint64
+		err := c.DB.Model(&model.RoomUser{}).Where("user_id = ?", userID).Pluck("room_id", &roomIDs).Error
+		return roomIDs, err
+	}
+	Instance.WsServer.invisibleLoader = func(userID uint64) (bool, error) {
+		var u model.User
+		err := c.DB.Select("id", "invisible").Where("id = ?", userID).First(&u).Error
+		return u.Invisible, err
+	}
+	go Instance.WsServer.Run()
 
-		// 绑定 WS 回调
-		Instance.bindWsHandlersOnMessage()
+	// 在线昵称/头像与已读游标快照，默认读 WsServer 内存 session
+	var sessions service.SessionStore = Instance.WsServer
+	if c.SessionStore != nil {
+		sessions = c.SessionStore
+	}
 
-	})
+	// 初始化基础 Service，注入 WsNotifier 回调
+	baseService := &service.Service{
+		DB:              c.DB,
+		Replicas:        c.DBReplicas,
+		TxRetry:         c.TxRetry,
+		RDB:             c.RDB,
+		KV:              c.KVStore,
+		TablePrefix:     c.TablePrefix,
+		WsNotifier:      Instance.WsServer.SendToUser, // 注入 WebSocket 通知函数
+		WsBatchNotifier: Instance.WsServer.SendToUsers,
+		WsBroadcaster:   Instance.WsServer.Broadcast,
+		RoomMembersChanged: func(roomID uint64, userIDs []uint64, joined bool) {
+			if joined {
+				Instance.WsServer.SubscribeRoom(roomID, userIDs...)
+			} else {
+				Instance.WsServer.UnsubscribeRoom(roomID, userIDs...)
+			}
+		},
+		VoiceMaxDuration: c.VoiceMaxDuration,
+		AutoUnarchive:    c.AutoUnarchive,
+		AdminUserIDs:     c.AdminUserIDs,
+		GroupAvatarMergeConfig: &service.GroupAvatarMergeConfig{
+			Enabled:    c.GroupAvatarMerge.Enabled,
+			CanvasSize: c.GroupAvatarMerge.CanvasSize,
+			Padding:    c.GroupAvatarMerge.Padding,
+			Gap:        c.GroupAvatarMerge.Gap,
+			Timeout:    c.GroupAvatarMerge.Timeout,
+			OutputDir:  c.GroupAvatarMerge.OutputDir,
+			URLPrefix:  c.GroupAvatarMerge.URLPrefix,
+			Debounce:   c.GroupAvatarMerge.Debounce,
+		},
+		Storage:           c.fileStorage(),
+		OnlineUserGetter:  sessions.OnlineUser,
+		SessionReadGetter: sessions.ReadSnapshot,
+	}
+	if len(c.PrimaryReads) > 0 {
+		baseService.PrimaryReads = make(map[string]bool, len(c.PrimaryReads))
+		for _, scope := range c.PrimaryReads {
+			baseService.PrimaryReads[scope] = true
+		}
+	}
+	// 注入通知服务（统一落库 + WS 推送 + HTTP 拉取）
+	baseService.Notify = service.NewNotificationService(baseService)
+	baseService.Notify.PushOnly = c.NotificationPushOnly
+	baseService.Notify.AnnouncementRate = c.AnnouncementRate
+	// 注入反垃圾频率限制
+	baseService.AntiSpam = service.NewAntiSpamService(baseService, c.AntiSpamLimits)
+	// 注入已读回执服务（延迟落库）
+	baseService.ReadReceipt = service.NewReadReceiptService(baseService)
+	// 注入 WS 会话加载服务（建连时拉取已读游标）
+	baseService.SessionBootstrap = service.NewSessionBootstrapService(baseService)
+	// 注入展示名解析服务（备注 > 群昵称 > 昵称 > 用户名）
+	baseService.DisplayNames = service.NewDisplayNameResolver(baseService)
+	// 注入群头像自动合成（成员变动后防抖异步执行）
+	baseService.GroupAvatar = service.NewGroupAvatarService(baseService)
+	// 注入系统消息服务（成员变动/群设置变更写入聊天记录并推送）
+	baseService.SystemMsg = service.NewSystemMessageService(baseService)
+	baseService.RoomMessagePusher = pushStoredMessage
+	// 合规归档（消息变更前追加到归档表 / 外部 sink）
+	if c.ComplianceArchiveEnabled {
+		baseService.Archive = &service.ComplianceArchive{Sink: c.ArchiveSink}
+	}
 
-	return Instance
+	// 初始化各个 Service
+	Instance.UserService = service.NewUserService(baseService)
+	Instance.UserService.Captcha = c.Captcha
+	if c.TokenTTL > 0 {
+		Instance.UserService.LoginTokenTTL = c.TokenTTL
+	}
+	if c.CaptchaLoginFailures > 0 {
+		Instance.UserService.CaptchaLoginFailures = c.CaptchaLoginFailures
+	}
+	Instance.RoomService = service.NewRoomService(baseService)
+	Instance.MsgService = service.NewMessageService(baseService)
+	Instance.MsgService.RecallPolicy = c.RecallPolicy
+	Instance.MsgService.ColdAfter = c.MessageColdAfter
+	if c.MessageStore != nil {
+		Instance.MsgService.Store = c.MessageStore
+	}
+	Instance.MemberService = service.NewMemberService(baseService)
+	Instance.MemberService.FriendDeletePolicy = c.FriendDeletePolicy
+	Instance.MemberService.FriendRequestTTL = c.FriendRequestTTL
+	Instance.MemberService.Pinyin = c.PinyinConverter
+	Instance.MomentService = service.NewMomentService(baseService)
+	Instance.ConversationService = service.NewConversationService(baseService)
+	Instance.NotificationService = baseService.Notify
+	Instance.DisplayNameResolver = baseService.DisplayNames
+	Instance.BotService = service.NewBotService(baseService)
+	Instance.AutoReplyService = service.NewAutoReplyService(baseService)
+	Instance.HelpDeskService = service.NewHelpDeskService(baseService)
+	if c.HelpDeskStrategy != "" {
+		Instance.HelpDeskService.Strategy = c.HelpDeskStrategy
+	}
+	if c.LinkPreviewEnabled {
+		Instance.LinkPreviewService = service.NewLinkPreviewService(baseService)
+	}
+	if c.OrgDirectoryEnabled {
+		Instance.OrgService = service.NewOrgService(baseService)
+	}
+	if c.ComplianceArchiveEnabled {
+		Instance.ComplianceService = service.NewComplianceService(baseService)
+	}
+	Instance.RedPacketService = service.NewRedPacketService(baseService)
+	Instance.RedPacketService.Wallet = c.Wallet
+	Instance.PollService = service.NewPollService(baseService)
+	Instance.CheckInService = service.NewCheckInService(baseService)
+	Instance.GeoService = service.NewGeoService(baseService)
+	Instance.NamecardService = service.NewNamecardService(baseService, c.NamecardSecret)
+	Instance.RoomStatsService = service.NewRoomStatsService(baseService)
+	Instance.ServerStatsService = service.NewServerStatsService(baseService)
+	Instance.AccountService = service.NewAccountService(baseService)
+	Instance.AntiSpamService = baseService.AntiSpam
+	Instance.SecurityService = service.NewSecurityService(baseService)
+	Instance.SecurityService.GeoIP = c.GeoIPResolver
+	Instance.SecurityService.TrustProxyHeaders = c.TrustProxyHeaders
+	Instance.ProvisionService = service.NewProvisionService(baseService)
+	Instance.ProvisionService.Users = Instance.UserService
+	Instance.DeliverySLA = service.NewDeliverySLATracker(c.SlowPersistThreshold, c.SlowFanoutThreshold)
+	if c.LoadShedding != nil {
+		Instance.LoadShedder = service.NewLoadShedder(baseService, *c.LoadShedding)
+		Instance.LoadShedder.QueueStats = Instance.WsServer.QueueStats
+	}
+	Instance.AuthService = service.NewAuthServiceWithKV(c.KVStore) // 初始化鉴权服务
+	Instance.AccountService.Auth = Instance.AuthService
+	Instance.AuthService.OnAccountSwitch = c.AccountSwitchHook
+
+	// 迁移表
+	if err := Instance.AutoMigrate(); err != nil {
+		log.Printf("AutoMigrate failed: %v", err)
+	}
+
+	// 加载 IP 访问规则
+	if err := Instance.SecurityService.Reload(); err != nil {
+		log.Printf("load ip rules failed: %v", err)
+	}
+
+	// 绑定 WS 回调
+	Instance.bindWsHandlersOnMessage()
+
+	if err := Instance.validate(); err != nil {
+		return nil, err
+	}
+	return Instance, nil
+}
+
+// fileStorage 未配置 FileStorage 时使用本地目录（OutputDir 为空时同群头像合成，落在系统临时目录）
+func (c *Config) fileStorage() service.FileStorage {
+	if c.FileStorage != nil {
+		return c.FileStorage
+	}
+	dir := c.GroupAvatarMerge.OutputDir
+	if strings.TrimSpace(dir) == "" {
+		dir = filepath.Join(os.TempDir(), "chat-sdk-avatars")
+	}
+	return &service.LocalFileStorage{Dir: dir, URLPrefix: c.GroupAvatarMerge.URLPrefix}
+}
+
+// optionalEngineServices 允许按配置关闭（为 nil）的服务，其余服务字段组装后必须非 nil
+var optionalEngineServices = map[string]bool{
+	"LinkPreviewService": true, // WithLinkPreview(false)
+	"OrgService":         true, // WithOrgDirectory(true) 才创建
+	"ComplianceService":  true, // WithComplianceArchive 才创建
+	"LoadShedder":        true, // WithLoadShedding 才创建
+}
+
+// validate 检查组装结果，保证 handler / WS 回调拿到的服务都已初始化
+func (e *ChatEngine) validate() error {
+	v := reflect.ValueOf(e).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Type.Kind() != reflect.Ptr || optionalEngineServices[f.Name] {
+			continue
+		}
+		if v.Field(i).IsNil() {
+			return fmt.Errorf("chat_sdk: %s is not initialized", f.Name)
+		}
+	}
+	base := e.MsgService.Service
+	if base.Notify == nil || base.ReadReceipt == nil || base.SessionBootstrap == nil || base.DisplayNames == nil || base.GroupAvatar == nil || base.SystemMsg == nil || base.AntiSpam == nil {
+		return errors.New("chat_sdk: base service wiring is incomplete")
+	}
+	if base.OnlineUserGetter == nil || base.SessionReadGetter == nil || base.WsNotifier == nil {
+		return errors.New("chat_sdk: base service callbacks are not set")
+	}
+	return nil
+}
+
+// startLoops 启动后台定时任务
+func (e *ChatEngine) startLoops() {
+	c := e.config
+	// 过期红包退回
+	if c.Wallet != nil {
+		go e.RedPacketService.RunRefundLoop(time.Minute)
+	}
+	// 到期投票自动结束
+	go e.PollService.RunCloseLoop(time.Minute)
+	// 到期的账号暂停自动恢复
+	go e.AccountService.RunReinstateLoop(time.Minute)
+	// 到期的成员禁言自动解除
+	go e.RoomService.RunUnmuteLoop(time.Minute)
+	// 每日定时禁言窗口开始/结束通知
+	go e.RoomService.RunScheduledMuteLoop(time.Minute)
+	// 同步其他实例修改的 IP 规则
+	go e.SecurityService.RunReloadLoop(time.Minute)
+	// 删除开启了定时删除的房间中到期的消息
+	go e.MsgService.RunDisappearingLoop(time.Minute)
+	// 触发到期的消息提醒
+	go e.MsgService.RunReminderLoop(time.Minute)
+	// 过载检测
+	if e.LoadShedder != nil {
+		go e.LoadShedder.RunLoop()
+	}
+	// 清理超过保留期的历史消息
+	if c.MessageRetention > 0 {
+		go e.MsgService.RunRetentionLoop(c.MessageRetention, time.Hour)
+	}
+	// 把超过冷却期的消息搬到冷表
+	if c.MessageColdAfter > 0 {
+		go e.MsgService.RunColdArchiveLoop(time.Hour)
+	}
+	// 清理超过保留期的通知
+	if c.NotificationRetention > 0 {
+		go e.NotificationService.RunRetentionLoop(c.NotificationRetention, time.Hour)
+	}
+	// 过期超过有效期的好友申请
+	if c.FriendRequestTTL > 0 {
+		go e.MemberService.RunFriendRequestExpireLoop(time.Minute)
+	}
 }
 
 func (c *ChatEngine) AutoMigrate() error {
 	db := c.config.DB
 	log.Println("AutoMigrate...")
+	if c.OrgService != nil {
+		if err := db.AutoMigrate(&model.Department{}, &model.DepartmentMember{}); err != nil {
+			return err
+		}
+	}
+	if c.ComplianceService != nil {
+		if err := db.AutoMigrate(&model.MessageArchive{}); err != nil {
+			return err
+		}
+	}
+	if c.config.MessageColdAfter > 0 {
+		if err := db.AutoMigrate(&model.MessageCold{}); err != nil {
+			return err
+		}
+	}
 	return db.AutoMigrate(
 		&model.User{},
 		&model.Room{},
@@ -147,6 +411,34 @@ func (c *ChatEngine) AutoMigrate() error {
 		&model.MomentComment{},
 		&model.RoomNotification{},
 		&model.RoomNotificationDelivery{},
+		&model.NotificationPreference{},
+		&model.NotificationCounter{},
+		&model.Bot{},
+		&model.AutoReplyRule{},
+		&model.HelpDeskAgent{},
+		&model.HelpDeskSession{},
+		&model.RedPacket{},
+		&model.RedPacketClaim{},
+		&model.Poll{},
+		&model.PollOption{},
+		&model.PollVote{},
+		&model.RoomCheckIn{},
+		&model.RoomCheckInStat{},
+		&model.RoomStatDaily{},
+		&model.RoomStatHourly{},
+		&model.RoomStatMember{},
+		&model.UserAppeal{},
+		&model.SpamViolation{},
+		&model.IPRule{},
+		&model.RoomJoinRequest{},
+		&model.RoomTag{},
+		&model.UserPrivacy{},
+		&model.MessageReminder{},
+		&model.MessageImportRef{},
+		&model.RoomAuditLog{},
+		&model.UserPreference{},
+		&model.ConversationSetting{},
+		&model.ConversationTag{},
 	)
 
 }
@@ -158,7 +450,7 @@ func (c *ChatEngine) AutoMigrate() error {
 
 // ServeWS 处理 WebSocket 请求，需要传入 userID 和 name
 func (c *ChatEngine) ServeWS(w http.ResponseWriter, r *http.Request, userID uint64, name string) {
-	user, err := Instance.UserService.GetUser(userID)
+	user, err := Instance.UserService.GetUser(userID, userID)
 	if err == nil && user != nil {
 		c.WsServer.ServeWS(w, r, userID, name, user.Nickname, user.Avatar)
 		return
@@ -166,6 +458,16 @@ func (c *ChatEngine) ServeWS(w http.ResponseWriter, r *http.Request, userID uint
 	c.WsServer.ServeWS(w, r, userID, name)
 }
 
+// ServeWSWithToken 同 ServeWS，连接绑定到 token，token 注销时立即断开（见 WsServer.ServeWSWithToken）
+func (c *ChatEngine) ServeWSWithToken(w http.ResponseWriter, r *http.Request, token string, userID uint64, name string) {
+	user, err := Instance.UserService.GetUser(userID, userID)
+	if err == nil && user != nil {
+		c.WsServer.ServeWSWithToken(w, r, token, userID, name, user.Nickname, user.Avatar)
+		return
+	}
+	c.WsServer.ServeWSWithToken(w, r, token, userID, name)
+}
+
 // HandleWS 返回 WebSocket 的Handler
 func (c *ChatEngine) HandleWS(userID int64, name string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -189,3 +491,43 @@ func (c *ChatEngine) HandleWS(userID int64, name string) http.HandlerFunc {
 func (c *ChatEngine) GinAuthMiddleware(opt *middleware.AuthOptions) gin.HandlerFunc {
 	return middleware.GinAuthMiddleware(c.AuthService, opt)
 }
+
+// GinAdminAuthMiddleware 返回管理接口鉴权中间件（X-Admin-Token，见 WithAdminToken）
+func (c *ChatEngine) GinAdminAuthMiddleware() gin.HandlerFunc {
+	return middleware.GinAdminAuthMiddleware(c.config.AdminToken)
+}
+
+// GinComplianceAuthMiddleware 返回合规导出接口鉴权中间件（X-Compliance-Token，见 WithComplianceToken）
+func (c *ChatEngine) GinComplianceAuthMiddleware() gin.HandlerFunc {
+	return middleware.GinComplianceAuthMiddleware(c.config.ComplianceToken)
+}
+
+// GinLoadShedMiddleware 返回过载保护中间件（过载期间返回 503 + Retry-After，见 WithLoadShedding），未开启时直接放行
+func (c *ChatEngine) GinLoadShedMiddleware() gin.HandlerFunc {
+	return middleware.GinLoadShedMiddleware(c.LoadShedder)
+}
+
+// GinSecurityMiddleware 返回 IP 访问控制中间件（CIDR 黑白名单 + 按国家屏蔽，规则通过 /admin/security/* 管理）
+//
+// 使用示例:
+//
+//	r := gin.Default()
+//	r.Use(engine.GinSecurityMiddleware()) // 对所有 HTTP 接口及 /ws 升级生效
+func (c *ChatEngine) GinSecurityMiddleware() gin.HandlerFunc {
+	return middleware.GinSecurityMiddleware(c.SecurityService)
+}
+
+// GinRoomMemberGuard 返回房间权限中间件：按 room_id（query 或 JSON body）校验调用方是成员且角色不低于 minRole（models.RoomRole*），
+// 需挂在用户鉴权之后；handler 可从 ctx.Get(middleware.ContextRoomRoleKey) 读取调用方角色。
+//
+// 使用示例:
+//
+//	roomAPI.GET("/notice/list", engine.GinRoomMemberGuard(models.RoomRoleMember), handler)
+func (c *ChatEngine) GinRoomMemberGuard(minRole uint8) gin.HandlerFunc {
+	return middleware.GinRoomMemberGuard(c.RoomService, minRole)
+}
+
+// GinBotAuthMiddleware 返回机器人 API Key 鉴权中间件（X-Bot-Key 或 Authorization: Bot <key>）
+func (c *ChatEngine) GinBotAuthMiddleware() gin.HandlerFunc {
+	return middleware.GinBotAuthMiddleware(c.BotService)
+}
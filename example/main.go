@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/cydxin/chat-sdk"
+	"github.com/cydxin/chat-sdk/models"
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -24,6 +25,7 @@ func main() {
 		chat_sdk.WithDB(db),
 		//chat_sdk.WithRDB(), // 配置 Redis
 		chat_sdk.WithTablePrefix("chat_"), // 自定义表前缀
+		//chat_sdk.WithOrgDirectory(true), // 开启组织架构（部门树、同事私聊、部门群）
 	)
 
 	// 3. 创建 Gin 路由
@@ -41,6 +43,9 @@ func main() {
 		c.Next()
 	})
 
+	// IP 黑白名单/国家屏蔽（规则见 /api/v1/admin/security/*）
+	r.Use(engine.GinSecurityMiddleware())
+
 	// 注册 Swagger UI
 	chat_sdk.RegisterSwagger(r, "/swagger/*any")
 
@@ -70,15 +75,40 @@ func main() {
 
 	// 5. API 路由组
 	api := r.Group("/api/v1")
+	// 房间级权限：按 room_id 校验成员身份（需在用户鉴权之后，见 engine.RegisterGinRoutes）
+	memberOnly := engine.GinRoomMemberGuard(models.RoomRoleMember)
+	// 非核心接口过载时返回 503（chat_sdk.WithLoadShedding 开启后生效）
+	shed := engine.GinLoadShedMiddleware()
+	// 校时（免登录）
+	api.GET("/time", engine.GinHandleServerTime)
 
 	// 消息模块
 	messageAPI := api.Group("/message")
 	{
 		messageAPI.GET("/conversations", engine.GinHandleGetMessageConversations)
+		messageAPI.GET("/conversations/page", engine.GinHandleGetConversationPage)
+		messageAPI.GET("/conversations/updates", engine.GinHandleGetConversationUpdates)
 		messageAPI.POST("/conversation/hide", engine.GinHandleHideConversation)
-		messageAPI.GET("/list", engine.GinHandleGetRoomMessages)
+		messageAPI.GET("/conversations/archived", engine.GinHandleGetArchivedConversations)
+		messageAPI.GET("/conversations/unread-total", engine.GinHandleConversationUnreadTotal)
+		messageAPI.POST("/conversation/archive", engine.GinHandleArchiveConversation)
+		messageAPI.POST("/conversation/mute", engine.GinHandleMuteConversation)
+		messageAPI.GET("/conversation/settings", engine.GinHandleGetConversationSettings)
+		messageAPI.POST("/conversation/settings", engine.GinHandleUpdateConversationSettings)
+		messageAPI.GET("/conversation/tags", engine.GinHandleListConversationTags)
+		messageAPI.POST("/conversation/tags", engine.GinHandleSetConversationTags)
+		messageAPI.POST("/conversation/tag/rename", engine.GinHandleRenameConversationTag)
+		messageAPI.POST("/conversation/tag/delete", engine.GinHandleDeleteConversationTag)
+		messageAPI.POST("/view-once/open", engine.GinHandleOpenViewOnceMessage)
+		messageAPI.POST("/reminder", engine.GinHandleSetMessageReminder)
+		messageAPI.POST("/reminder/cancel", engine.GinHandleCancelMessageReminder)
+		messageAPI.GET("/reminders", engine.GinHandleListMessageReminders)
+		messageAPI.GET("/list", memberOnly, engine.GinHandleGetRoomMessages)
 		messageAPI.GET("/detail", engine.GinHandleGetMessageByID)
+		messageAPI.GET("/receipts", engine.GinHandleGetMessageReceipts)
+		messageAPI.POST("/send", engine.GinHandleSendMessage)
 		messageAPI.POST("/recall", engine.GinHandleRecallMessage)
+		messageAPI.GET("/poll", engine.GinHandlePollMessages)
 	}
 
 	// 消息模块
@@ -88,11 +118,26 @@ func main() {
 		userAPI.POST("/login", engine.GinHandleUserLogin)
 		userAPI.POST("/code/send", engine.GinHandleSendVerifyCode)
 		userAPI.POST("/password/forgot", engine.GinHandleForgotPassword)
+		userAPI.POST("/appeal", engine.GinHandleSubmitAppeal)
 		userAPI.GET("/info", engine.GinHandleGetUserInfo)
 		userAPI.POST("/update", engine.GinHandleUpdateUserInfo)
 		userAPI.POST("/avatar", engine.GinHandleUpdateUserAvatar)
+		userAPI.POST("/avatar/upload", engine.GinHandleUploadUserAvatar)
 		userAPI.POST("/password", engine.GinHandleUpdateUserPassword)
-		userAPI.GET("/search", engine.GinHandleSearchUsers)
+		userAPI.GET("/search", shed, engine.GinHandleSearchUsers)
+		userAPI.GET("/briefs", engine.GinHandleGetUserBriefs)
+		userAPI.POST("/location", engine.GinHandleUpdateLocation)
+		userAPI.POST("/location/clear", engine.GinHandleClearLocation)
+		userAPI.GET("/nearby", shed, engine.GinHandleNearbyUsers)
+		userAPI.GET("/qrcode", engine.GinHandleUserQRCode)
+		userAPI.GET("/namecard", engine.GinHandleUserNamecard)
+		userAPI.GET("/privacy", engine.GinHandleGetPrivacy)
+		userAPI.POST("/privacy", engine.GinHandleUpdatePrivacy)
+		userAPI.GET("/settings", engine.GinHandleGetUserSettings)
+		userAPI.POST("/settings", engine.GinHandleUpdateUserSettings)
+		userAPI.GET("/token", engine.GinHandleIntrospectToken)
+		userAPI.POST("/logout", engine.GinHandleLogout)
+		userAPI.POST("/account/switch", engine.GinHandleSwitchAccount)
 	}
 
 	// 好友模块
@@ -103,8 +148,12 @@ func main() {
 		friendAPI.POST("/reject", engine.GinHandleRejectFriendRequest)
 		friendAPI.DELETE("/delete", engine.GinHandleDeleteFriend)
 		friendAPI.POST("/remark", engine.GinHandleSetFriendRemark)
+		friendAPI.POST("/star", engine.GinHandleSetFriendStar)
 		friendAPI.GET("/list", engine.GinHandleGetFriendList)
+		friendAPI.GET("/starred", engine.GinHandleGetStarredFriends)
+		friendAPI.GET("/sections", engine.GinHandleGetFriendSections)
 		friendAPI.GET("/pending", engine.GinHandleGetPendingRequests)
+		friendAPI.POST("/add-by-qr", engine.GinHandleAddFriendByQR)
 	}
 
 	// 通知模块
@@ -112,6 +161,10 @@ func main() {
 	{
 		notifyAPI.GET("/list", engine.GinHandleListNotifications)
 		notifyAPI.POST("/read", engine.GinHandleMarkNotificationsRead)
+		notifyAPI.POST("/read-all", engine.GinHandleMarkAllNotificationsRead)
+		notifyAPI.GET("/unread-count", engine.GinHandleNotificationUnreadCount)
+		notifyAPI.GET("/preference", engine.GinHandleListNotificationPreferences)
+		notifyAPI.POST("/preference", engine.GinHandleSetNotificationPreference)
 	}
 
 	// 房间模块
@@ -119,13 +172,128 @@ func main() {
 	{
 		roomAPI.POST("/private", engine.GinHandleCreatePrivateRoom)
 		roomAPI.POST("/group", engine.GinHandleCreateGroupRoom)
-		roomAPI.GET("/group/info", engine.GinHandleGetGroupInfo)
+		roomAPI.GET("/group/info", memberOnly, engine.GinHandleGetGroupInfo)
+		roomAPI.GET("/online", memberOnly, engine.GinHandleRoomOnline)
+		roomAPI.POST("/group/avatar/upload", engine.GinHandleUploadGroupAvatar)
 		roomAPI.GET("/list", engine.GinHandleGetUserRooms)
 		roomAPI.GET("/group/list", engine.GinHandleGetGroupRooms)
-		roomAPI.GET("/member/list", engine.GinHandleGetRoomMemberList)
+		roomAPI.POST("/group/save", engine.GinHandleSaveGroup)
+		roomAPI.GET("/group/saved", engine.GinHandleGetSavedGroups)
+		roomAPI.GET("/member/list", memberOnly, engine.GinHandleGetRoomMemberList)
+		roomAPI.GET("/member/search", shed, memberOnly, engine.GinHandleSearchRoomMembers)
 		roomAPI.POST("/member/nickname", engine.GinHandleSetMyGroupNickname)
 		roomAPI.POST("/member/add", engine.GinHandleAddRoomMember)
 		roomAPI.POST("/member/remove", engine.GinHandleRemoveRoomMember)
+		roomAPI.POST("/disappearing", engine.GinHandleSetRoomDisappearing)
+		roomAPI.GET("/join/lookup", engine.GinHandleLookupGroupByAccount)
+		roomAPI.POST("/join-by-account", engine.GinHandleJoinByAccount)
+		roomAPI.POST("/join/verification", engine.GinHandleSetJoinVerification)
+		roomAPI.GET("/join/requests", engine.GinHandleListJoinRequests)
+		roomAPI.POST("/join/handle", engine.GinHandleHandleJoinRequest)
+		roomAPI.POST("/discovery", engine.GinHandleSetRoomDiscovery)
+		roomAPI.GET("/discover", shed, engine.GinHandleDiscoverGroups)
+		roomAPI.POST("/checkin", engine.GinHandleRoomCheckIn)
+		roomAPI.GET("/checkin/leaderboard", shed, memberOnly, engine.GinHandleRoomCheckInLeaderboard)
+		roomAPI.GET("/stats", shed, engine.GinHandleRoomStats)
+		roomAPI.GET("/stats/senders", shed, engine.GinHandleRoomTopSenders)
+	}
+
+	// 机器人模块（管理接口走用户鉴权）
+	botAPI := api.Group("/bot")
+	{
+		botAPI.POST("/create", engine.GinHandleCreateBot)
+		botAPI.GET("/list", engine.GinHandleListBots)
+		botAPI.POST("/key/reset", engine.GinHandleResetBotKey)
+		botAPI.POST("/webhook", engine.GinHandleUpdateBotWebhook)
+	}
+	// 机器人发消息（API Key 鉴权：X-Bot-Key / Authorization: Bot <key>）
+	botOpenAPI := api.Group("/bot", engine.GinBotAuthMiddleware())
+	{
+		botOpenAPI.POST("/send", engine.GinHandleBotSendMessage)
+	}
+
+	// 自动回复模块
+	autoReplyAPI := api.Group("/autoreply")
+	{
+		autoReplyAPI.POST("/create", engine.GinHandleCreateAutoReplyRule)
+		autoReplyAPI.POST("/update", engine.GinHandleUpdateAutoReplyRule)
+		autoReplyAPI.POST("/delete", engine.GinHandleDeleteAutoReplyRule)
+		autoReplyAPI.GET("/list", engine.GinHandleListAutoReplyRules)
+	}
+
+	// 客服模块（坐席通过 engine.HelpDeskService.SetAgent 在业务后台配置）
+	helpDeskAPI := api.Group("/helpdesk")
+	{
+		helpDeskAPI.POST("/visitor", engine.GinHandleCreateVisitor)
+		helpDeskAPI.POST("/open", engine.GinHandleOpenHelpDeskSession)
+		helpDeskAPI.POST("/claim", engine.GinHandleClaimHelpDeskSession)
+		helpDeskAPI.POST("/transfer", engine.GinHandleTransferHelpDeskSession)
+		helpDeskAPI.POST("/close", engine.GinHandleCloseHelpDeskSession)
+		helpDeskAPI.POST("/rate", engine.GinHandleRateHelpDeskSession)
+		helpDeskAPI.GET("/sessions", engine.GinHandleListHelpDeskSessions)
+	}
+
+	// 红包模块（需通过 chat_sdk.WithWallet 注入钱包实现）
+	redPacketAPI := api.Group("/redpacket")
+	{
+		redPacketAPI.POST("/send", engine.GinHandleSendRedPacket)
+		redPacketAPI.POST("/claim", engine.GinHandleClaimRedPacket)
+		redPacketAPI.GET("/detail", engine.GinHandleGetRedPacket)
+	}
+
+	// 投票模块
+	pollAPI := api.Group("/poll")
+	{
+		pollAPI.POST("/create", engine.GinHandleCreatePoll)
+		pollAPI.POST("/vote", engine.GinHandleVotePoll)
+		pollAPI.POST("/close", engine.GinHandleClosePoll)
+		pollAPI.GET("/detail", engine.GinHandleGetPoll)
+	}
+
+	// 组织架构（chat_sdk.WithOrgDirectory(true) 开启后才有 OrgService）
+	if engine.OrgService != nil {
+		orgAPI := api.Group("/org")
+		orgAPI.GET("/tree", engine.GinHandleOrgTree)
+		orgAPI.GET("/department/members", engine.GinHandleOrgMembers)
+		orgAPI.GET("/my", engine.GinHandleMyDepartments)
+		orgAPI.POST("/chat", engine.GinHandleOrgStartChat)
+	}
+
+	// 运维管理（X-Admin-Token 鉴权，见 chat_sdk.WithAdminToken）
+	adminAPI := api.Group("/admin", engine.GinAdminAuthMiddleware())
+	{
+		adminAPI.GET("/stats", engine.GinHandleAdminStats)
+		adminAPI.GET("/delivery/sla", engine.GinHandleAdminDeliverySLA)
+		if engine.LoadShedder != nil {
+			adminAPI.GET("/load", engine.GinHandleAdminLoadStatus)
+		}
+		adminAPI.POST("/user/suspend", engine.GinHandleAdminSuspendUser)
+		adminAPI.POST("/user/reinstate", engine.GinHandleAdminReinstateUser)
+		adminAPI.GET("/appeals", engine.GinHandleAdminListAppeals)
+		adminAPI.POST("/appeal/handle", engine.GinHandleAdminHandleAppeal)
+		adminAPI.GET("/spam/violations", engine.GinHandleAdminSpamViolations)
+		adminAPI.GET("/ws/connections", engine.GinHandleAdminWsConnections)
+		adminAPI.GET("/security/rules", engine.GinHandleAdminListIPRules)
+		adminAPI.POST("/security/rule/add", engine.GinHandleAdminAddIPRule)
+		adminAPI.POST("/security/rule/delete", engine.GinHandleAdminDeleteIPRule)
+		adminAPI.POST("/message/import", engine.GinHandleAdminImportMessages)
+		adminAPI.POST("/announcement", engine.GinHandleAdminAnnouncement)
+		adminAPI.POST("/provision", engine.GinHandleAdminProvision)
+		adminAPI.GET("/provision/export", engine.GinHandleAdminProvisionExport)
+		if engine.OrgService != nil {
+			adminAPI.POST("/org/department/create", engine.GinHandleAdminCreateDepartment)
+			adminAPI.POST("/org/department/update", engine.GinHandleAdminUpdateDepartment)
+			adminAPI.POST("/org/department/delete", engine.GinHandleAdminDeleteDepartment)
+			adminAPI.POST("/org/department/group", engine.GinHandleAdminSetDepartmentGroup)
+			adminAPI.POST("/org/department/sync", engine.GinHandleAdminSyncDepartmentGroup)
+			adminAPI.POST("/org/member/add", engine.GinHandleAdminAddOrgMembers)
+			adminAPI.POST("/org/member/remove", engine.GinHandleAdminRemoveOrgMembers)
+		}
+	}
+
+	// 合规归档导出（X-Compliance-Token 鉴权，见 chat_sdk.WithComplianceArchive / WithComplianceToken）
+	if engine.ComplianceService != nil {
+		api.GET("/compliance/archive", engine.GinComplianceAuthMiddleware(), engine.GinHandleComplianceExportArchive)
 	}
 
 	// 6. 启动服务器
@@ -1,5 +1,18 @@
 package chat_sdk
 
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
 /* @title           Chat SDK API
 @version         1.0
 @description     Chat SDK API documentation
@@ -17,3 +30,129 @@ package chat_sdk
 - handler_room.go
 - handler_moment.go
 */
+
+// writeError 输出错误响应，HTTP 状态码按业务码映射（见 response.HTTPStatus）
+func writeError(ctx *gin.Context, code int, msg string) {
+	ctx.JSON(response.HTTPStatus(code), response.Error(code, msg))
+}
+
+// requestLang 请求语言：取 Accept-Language 中第一个受支持的语言，否则为 response.DefaultLang
+func requestLang(ctx *gin.Context) string {
+	return response.ParseAcceptLanguage(ctx.GetHeader("Accept-Language"))
+}
+
+// writeServiceError 输出 service 层错误：*service.Error 按其业务码返回，其余按内部错误处理。
+// 默认语言下返回 err.Error()（保留 %w 包装的补充说明），其他语言返回目录中的译文；
+// *service.SendCodeQuotaError 额外返回 data 与 Retry-After 头。
+func writeServiceError(ctx *gin.Context, err error) {
+	var se *service.Error
+	if !errors.As(err, &se) {
+		writeError(ctx, response.CodeInternalError, err.Error())
+		return
+	}
+	msg := err.Error()
+	if lang := requestLang(ctx); lang != response.DefaultLang {
+		msg = se.Localize(lang)
+	}
+	resp := response.Error(se.Code, msg)
+	// 配额超限：data 返回维度/原因/重试秒数
+	var qe *service.SendCodeQuotaError
+	if errors.As(err, &qe) {
+		ctx.Header("Retry-After", strconv.FormatInt(qe.RetryAfter, 10))
+		resp.Data = qe
+	}
+	// 被禁言：data 返回范围/截止时间/剩余秒数
+	var me *service.MuteError
+	if errors.As(err, &me) {
+		ctx.Header("Retry-After", strconv.FormatInt(me.RetryAfter, 10))
+		resp.Data = me
+	}
+	ctx.JSON(response.HTTPStatus(se.Code), resp)
+}
+
+// PageQuery 通用分页参数（嵌入到各 query 结构体中）
+type PageQuery struct {
+	Limit  int `form:"limit,default=20" binding:"min=1,max=100"`
+	Offset int `form:"offset" binding:"min=0"`
+}
+
+// bindJSON 绑定并校验 JSON body（binding tag），失败时输出 CodeParamError 并返回 false
+func bindJSON(ctx *gin.Context, req any) bool {
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		writeError(ctx, response.CodeParamError, formatBindError(requestLang(ctx), req, err))
+		return false
+	}
+	return true
+}
+
+// bindQuery 绑定并校验 query 参数（form tag 可用 default=，binding tag 做必填/范围校验），失败时输出 CodeParamError 并返回 false
+func bindQuery(ctx *gin.Context, req any) bool {
+	if err := ctx.ShouldBindQuery(req); err != nil {
+		writeError(ctx, response.CodeParamError, formatBindError(requestLang(ctx), req, err))
+		return false
+	}
+	return true
+}
+
+// validTags 有专门文案的校验 tag，其余按 valid.invalid 处理
+var validTags = map[string]bool{"required": true, "required_without": true, "min": true, "max": true, "gte": true, "lte": true, "oneof": true}
+
+// formatBindError 把绑定/校验错误转成可读文案，字段名取 form/json tag
+func formatBindError(lang string, req any, err error) string {
+	var ves validator.ValidationErrors
+	if errors.As(err, &ves) {
+		msgs := make([]string, 0, len(ves))
+		for _, fe := range ves {
+			key := "valid.invalid"
+			if validTags[fe.Tag()] {
+				key = "valid." + fe.Tag()
+				switch fe.Kind() {
+				case reflect.String, reflect.Slice, reflect.Map:
+					if fe.Tag() != "required" && fe.Tag() != "required_without" && fe.Tag() != "oneof" {
+						key += "_len"
+					}
+				}
+			}
+			name, param := bindFieldName(req, fe.StructField()), fe.Param()
+			if fe.Tag() == "required_without" {
+				param = bindFieldName(req, param)
+			}
+			if param == "" {
+				msgs = append(msgs, response.Translate(lang, key, name))
+			} else {
+				msgs = append(msgs, response.Translate(lang, key, name, param))
+			}
+		}
+		return strings.Join(msgs, "; ")
+	}
+	var ute *json.UnmarshalTypeError
+	if errors.As(err, &ute) {
+		return response.Translate(lang, "valid.type", ute.Field)
+	}
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		return response.Translate(lang, "valid.number", ne.Num)
+	}
+	return err.Error()
+}
+
+// bindFieldName 取结构体字段的 form/json tag 名，找不到时返回字段名
+func bindFieldName(req any, field string) string {
+	t := reflect.TypeOf(req)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return field
+	}
+	f, ok := t.FieldByName(field)
+	if !ok {
+		return field
+	}
+	for _, tag := range []string{"form", "json"} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field
+}
@@ -0,0 +1,411 @@
+package chat_sdk
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.ServerStatsDTO{}
+
+// -------------------- 运维管理（Admin）相关接口 --------------------
+
+// GinHandleAdminStats 全局运营统计
+// @Summary 全局运营统计
+// @Description 当前 WS 连接数/在线人数（本实例）、最近 1 分钟/1 小时/1 天消息量、DAU/MAU（Redis HyperLogLog，跨实例）
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.ServerStatsDTO} "统计"
+// @Failure 401 {object} response.Response "令牌无效"
+// @Failure 403 {object} response.Response "未配置管理令牌"
+// @Security AdminToken
+// @Router /admin/stats [get]
+func (c *ChatEngine) GinHandleAdminStats(ctx *gin.Context) {
+	stats, err := c.ServerStatsService.Snapshot(ctx.Request.Context(), time.Now())
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	stats.Connections, stats.OnlineUsers = c.WsServer.ConnStats()
+	ctx.JSON(http.StatusOK, response.Success(stats))
+}
+
+// GinHandleAdminDeliverySLA 消息投递耗时
+// @Summary 消息投递耗时（SLA）
+// @Description 本实例最近 2048 条 WS 消息的耗时分位数（毫秒）：persist 为收到到落库完成，fanout 为落库到写入各接收方发送队列，total 为两者之和；
+// @Description recent_slow 为最近 100 条超过阈值的慢消息（新的在前），阈值见 chat_sdk.WithSlowMessageThresholds。
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.DeliverySLAStats} "投递耗时"
+// @Security AdminToken
+// @Router /admin/delivery/sla [get]
+func (c *ChatEngine) GinHandleAdminDeliverySLA(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, response.Success(c.DeliverySLA.Snapshot()))
+}
+
+// GinHandleAdminLoadStatus 过载保护状态
+// @Summary 过载保护状态
+// @Description 本实例最近一次过载检测结果：WS 发送队列/广播队列占用率、数据库 ping 耗时，以及当前是否在拒绝非核心请求（shed_until 之前）。需开启 chat_sdk.WithLoadShedding。
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.LoadStatus} "过载状态"
+// @Security AdminToken
+// @Router /admin/load [get]
+func (c *ChatEngine) GinHandleAdminLoadStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, response.Success(c.LoadShedder.Status()))
+}
+
+type AdminSuspendUserReq struct {
+	UserID      uint64 `json:"user_id" binding:"required"`
+	Reason      string `json:"reason" binding:"required" example:"发布广告"`
+	DurationSec int64  `json:"duration_sec" example:"86400"` // 暂停时长（秒），<=0 表示永久封禁
+}
+
+// GinHandleAdminSuspendUser 暂停/封禁账号
+// @Summary 暂停/封禁账号
+// @Description duration_sec>0 暂停到期自动恢复，<=0 永久封禁；同时吊销该用户全部登录 token
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param req body AdminSuspendUserReq true "封禁参数"
+// @Success 200 {object} response.Response{data=service.AccountStatusDTO} "账号状态"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/user/suspend [post]
+func (c *ChatEngine) GinHandleAdminSuspendUser(ctx *gin.Context) {
+	var req AdminSuspendUserReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	st, err := c.AccountService.Suspend(ctx.Request.Context(), req.UserID, req.Reason, time.Duration(req.DurationSec)*time.Second)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(st))
+}
+
+type AdminReinstateUserReq struct {
+	UserID uint64 `json:"user_id" binding:"required"`
+}
+
+// GinHandleAdminReinstateUser 恢复账号
+// @Summary 恢复账号
+// @Description 解除暂停/封禁
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param req body AdminReinstateUserReq true "用户"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/user/reinstate [post]
+func (c *ChatEngine) GinHandleAdminReinstateUser(ctx *gin.Context) {
+	var req AdminReinstateUserReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.AccountService.Reinstate(req.UserID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// AdminListAppealsReq 申诉列表参数，status=-1 为全部
+type AdminListAppealsReq struct {
+	Status int `form:"status,default=-1" binding:"min=-1,max=2"`
+	PageQuery
+}
+
+// GinHandleAdminListAppeals 申诉列表
+// @Summary 申诉列表
+// @Description status 不传为全部：0-待处理 1-已通过 2-已驳回
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param status query int false "状态"
+// @Param limit query int false "每页数量(默认20,最大100)"
+// @Param offset query int false "偏移"
+// @Success 200 {object} response.Response{data=[]service.AppealDTO} "申诉列表"
+// @Security AdminToken
+// @Router /admin/appeals [get]
+func (c *ChatEngine) GinHandleAdminListAppeals(ctx *gin.Context) {
+	var req AdminListAppealsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	list, err := c.AccountService.ListAppeals(req.Status, req.Limit, req.Offset)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+type AdminHandleAppealReq struct {
+	AppealID uint64 `json:"appeal_id" binding:"required"`
+	Approve  bool   `json:"approve"` // true: 通过并恢复账号
+	Reply    string `json:"reply"`
+}
+
+// GinHandleAdminHandleAppeal 处理申诉
+// @Summary 处理申诉
+// @Description approve=true 通过申诉并恢复账号，false 驳回
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param req body AdminHandleAppealReq true "处理结果"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/appeal/handle [post]
+func (c *ChatEngine) GinHandleAdminHandleAppeal(ctx *gin.Context) {
+	var req AdminHandleAppealReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.AccountService.HandleAppeal(req.AppealID, req.Approve, req.Reply); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// AdminSpamViolationsReq 违规记录参数，user_id 不传为全部
+type AdminSpamViolationsReq struct {
+	UserID uint64 `form:"user_id"`
+	PageQuery
+}
+
+// GinHandleAdminSpamViolations 反垃圾违规记录
+// @Summary 反垃圾违规记录
+// @Description 超出好友申请/建群/拉人频率限制的记录（action: friend_request/create_group/invite）
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param user_id query uint64 false "用户ID(不传为全部)"
+// @Param limit query int false "每页数量(默认20,最大100)"
+// @Param offset query int false "偏移"
+// @Success 200 {object} response.Response{data=[]service.SpamViolationDTO} "违规记录"
+// @Security AdminToken
+// @Router /admin/spam/violations [get]
+func (c *ChatEngine) GinHandleAdminSpamViolations(ctx *gin.Context) {
+	var req AdminSpamViolationsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	list, err := c.AntiSpamService.ListViolations(req.UserID, req.Limit, req.Offset)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// AdminWsConnectionsReq WS 连接列表参数，user_id 不传为全部
+type AdminWsConnectionsReq struct {
+	UserID uint64 `form:"user_id"`
+	PageQuery
+}
+
+// GinHandleAdminWsConnections 本实例 WS 连接活跃情况
+// @Summary WS 连接活跃情况
+// @Description 本实例当前的 WS 连接：建连时间、客户端 IP、最后一次上行消息时间（pong 不算）、空闲秒数与用户在线状态（1-在线 2-离开），按空闲时长倒序
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param user_id query uint64 false "用户ID(不传为全部)"
+// @Param limit query int false "每页数量(默认20,最大100)"
+// @Param offset query int false "偏移"
+// @Success 200 {object} response.Response{data=[]WsConnInfo} "连接列表"
+// @Security AdminToken
+// @Router /admin/ws/connections [get]
+func (c *ChatEngine) GinHandleAdminWsConnections(ctx *gin.Context) {
+	var req AdminWsConnectionsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	list := c.WsServer.Connections(req.UserID)
+	start := min(req.Offset, len(list))
+	end := min(start+req.Limit, len(list))
+	ctx.JSON(http.StatusOK, response.Success(list[start:end]))
+}
+
+type AdminImportMessagesReq struct {
+	RoomID   uint64                    `json:"room_id" binding:"required"`
+	Messages []service.ImportedMessage `json:"messages" binding:"required"` // 单次最多 service.ImportMessagesMax 条
+}
+
+// GinHandleAdminImportMessages 导入历史消息
+// @Summary 导入历史消息（迁移）
+// @Description 从其他 IM 迁移历史消息：保留原始发送时间，按 sent_at 排序写入，按 (room_id, external_id) 去重（重复导入返回已有消息 ID，可安全重试）；
+// @Description 不校验禁言/频率、不推送、不增加未读数。单次最多 1000 条。
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param req body AdminImportMessagesReq true "房间与消息列表"
+// @Success 200 {object} response.Response{data=service.ImportMessagesResult} "导入结果"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/message/import [post]
+func (c *ChatEngine) GinHandleAdminImportMessages(ctx *gin.Context) {
+	var req AdminImportMessagesReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	res, err := c.MsgService.ImportMessages(req.RoomID, req.Messages)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(res))
+}
+
+// GinHandleAdminAnnouncement 发布系统公告
+// @Summary 发布系统公告
+// @Description 向全部用户（all）、指定房间的成员（rooms，最多 100 个房间）或当前在线用户（online）发布公告，用于停机维护、运营活动等。
+// @Description 公告写入通知（event_type=system.announcement，room_id=0），在后台分批投递并推送 notification（速率见 chat_sdk.WithAnnouncementRate）；
+// @Description 接口受理后立即返回，投递完成时向 AdminUserIDs 推送 admin.announcement（接收人数、批次、耗时）。
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param req body service.AnnouncementReq true "公告内容与接收范围"
+// @Success 200 {object} response.Response{data=service.AnnouncementDTO} "已受理"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/announcement [post]
+func (c *ChatEngine) GinHandleAdminAnnouncement(ctx *gin.Context) {
+	var req service.AnnouncementReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	res, err := c.NotificationService.PublishAnnouncement(req)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(res))
+}
+
+type AdminProvisionQuery struct {
+	DryRun bool `form:"dry_run"` // 只校验并返回报告，不落库
+}
+
+// GinHandleAdminProvision 批量开通用户、好友关系与群成员
+// @Summary 批量开通用户/好友/群成员
+// @Description 从企业 HR/组织架构批量导入：用户按 username、好友按双方 username、群按 room_account 幂等 upsert，重复导入不产生重复数据；
+// @Description 每行单独处理，失败的行在报告中给出原因，不影响其他行；dry_run=true 时完整执行后回滚，只返回报告。
+// @Description 请求体为 JSON（service.ProvisionRequest），或 multipart 上传 users/friendships/groups 三个 CSV 文件（任选，首行为表头，列名同 JSON 字段，admins/members 用 | 分隔）。单次最多 5000 行。
+// @Tags 运维
+// @Accept json,mpfd
+// @Produce json
+// @Param dry_run query bool false "只校验不落库"
+// @Param req body service.ProvisionRequest false "JSON 数据"
+// @Param users formData file false "用户 CSV：username,nickname,password,phone,email,avatar"
+// @Param friendships formData file false "好友关系 CSV：user,friend"
+// @Param groups formData file false "群 CSV：room_account,name,owner,admins,members"
+// @Success 200 {object} response.Response{data=service.ProvisionReport} "导入报告"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/provision [post]
+func (c *ChatEngine) GinHandleAdminProvision(ctx *gin.Context) {
+	var q AdminProvisionQuery
+	if !bindQuery(ctx, &q) {
+		return
+	}
+	var req service.ProvisionRequest
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		for _, kind := range []string{service.ProvisionKindUsers, service.ProvisionKindFriendships, service.ProvisionKindGroups} {
+			fh, err := ctx.FormFile(kind)
+			if err != nil {
+				continue
+			}
+			f, err := fh.Open()
+			if err != nil {
+				writeError(ctx, response.CodeInternalError, err.Error())
+				return
+			}
+			err = service.ParseProvisionCSV(kind, f, &req)
+			f.Close()
+			if err != nil {
+				writeServiceError(ctx, err)
+				return
+			}
+		}
+	} else if !bindJSON(ctx, &req) {
+		return
+	}
+
+	report, err := c.ProvisionService.Provision(req, q.DryRun)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(report))
+}
+
+type AdminProvisionExportReq struct {
+	// Kind CSV 导出时必填：users / friendships / groups
+	Kind   string `form:"kind" binding:"omitempty,oneof=users friendships groups"`
+	Format string `form:"format,default=json" binding:"oneof=json csv"`
+}
+
+// GinHandleAdminProvisionExport 导出用户、好友关系与群成员
+// @Summary 导出用户/好友/群成员
+// @Description 导出格式与批量开通接口的输入一致（不含密码、机器人与访客），可编辑后重新导入。format=json 返回全部数据（传 kind 时只含该类）；format=csv 按 kind 下载对应的 CSV 文件。
+// @Tags 运维
+// @Accept json
+// @Produce json,text/csv
+// @Param kind query string false "users/friendships/groups（csv 必填）"
+// @Param format query string false "json(默认)/csv"
+// @Success 200 {object} response.Response{data=service.ProvisionRequest} "导出数据"
+// @Security AdminToken
+// @Router /admin/provision/export [get]
+func (c *ChatEngine) GinHandleAdminProvisionExport(ctx *gin.Context) {
+	var req AdminProvisionExportReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	if req.Format == "csv" && req.Kind == "" {
+		writeError(ctx, response.CodeParamError, response.Translate(requestLang(ctx), "valid.required", "kind"))
+		return
+	}
+
+	data, err := c.ProvisionService.Export()
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	if req.Format == "csv" {
+		var buf bytes.Buffer
+		if err := service.WriteProvisionCSV(req.Kind, &buf, data); err != nil {
+			writeServiceError(ctx, err)
+			return
+		}
+		ctx.Header("Content-Disposition", `attachment; filename="`+req.Kind+`.csv"`)
+		ctx.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		return
+	}
+	switch req.Kind {
+	case service.ProvisionKindUsers:
+		data = &service.ProvisionRequest{Users: data.Users}
+	case service.ProvisionKindFriendships:
+		data = &service.ProvisionRequest{Friendships: data.Friendships}
+	case service.ProvisionKindGroups:
+		data = &service.ProvisionRequest{Groups: data.Groups}
+	}
+	ctx.JSON(http.StatusOK, response.Success(data))
+}
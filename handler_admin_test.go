@@ -0,0 +1,92 @@
+package chat_sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+// 封禁与申诉接口：用户的常规错误（密码错误、重复申诉、已处理等）返回 4xx，而不是 500
+func TestAccountHandlers_ErrorStatus(t *testing.T) {
+	e, err := NewTestEngine(WithAdminToken("admin-secret"))
+	if err != nil {
+		t.Fatalf("NewTestEngine: %v", err)
+	}
+	defer e.Close()
+	report, err := e.ProvisionService.Provision(service.ProvisionRequest{Users: []service.ProvisionUser{
+		{Username: "alice", Password: "secret123"},
+	}}, false)
+	if err != nil || report.Created != 1 {
+		t.Fatalf("provision: %+v %v", report, err)
+	}
+	aliceID := report.Rows[0].ID
+
+	srv := httptest.NewServer(e.Handler(nil))
+	defer srv.Close()
+	post := func(path string, admin bool, body any) (int, response.Response) {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1"+path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("X-Admin-Token", "admin-secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var out response.Response
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("%s: decode: %v", path, err)
+		}
+		return resp.StatusCode, out
+	}
+	appeal := func(password, content string) map[string]any {
+		return map[string]any{"account": "alice", "password": password, "content": content}
+	}
+
+	steps := []struct {
+		name   string
+		path   string
+		admin  bool
+		body   any
+		status int
+		code   int
+	}{
+		{"suspend unknown user", "/admin/user/suspend", true, map[string]any{"user_id": 9999, "reason": "spam"}, http.StatusNotFound, response.CodeUserNotFound},
+		{"suspend blank reason", "/admin/user/suspend", true, map[string]any{"user_id": aliceID, "reason": "  "}, http.StatusBadRequest, response.CodeParamError},
+		{"reinstate unknown user", "/admin/user/reinstate", true, map[string]any{"user_id": 9999}, http.StatusNotFound, response.CodeUserNotFound},
+		{"appeal active account", "/user/appeal", false, appeal("secret123", "误封"), http.StatusBadRequest, response.CodeParamError},
+		{"suspend", "/admin/user/suspend", true, map[string]any{"user_id": aliceID, "reason": "spam"}, http.StatusOK, response.CodeSuccess},
+		{"appeal wrong password", "/user/appeal", false, appeal("wrong-pass", "误封"), http.StatusUnauthorized, response.CodePasswordError},
+		{"appeal blank content", "/user/appeal", false, appeal("secret123", "  "), http.StatusBadRequest, response.CodeParamError},
+		{"appeal", "/user/appeal", false, appeal("secret123", "误封"), http.StatusOK, response.CodeSuccess},
+		{"appeal pending", "/user/appeal", false, appeal("secret123", "再看看"), http.StatusBadRequest, response.CodeParamError},
+		{"handle unknown appeal", "/admin/appeal/handle", true, map[string]any{"appeal_id": 9999}, http.StatusBadRequest, response.CodeParamError},
+	}
+	for _, st := range steps {
+		status, out := post(st.path, st.admin, st.body)
+		if status != st.status || out.Code != st.code {
+			t.Fatalf("%s: status=%d code=%d msg=%q, want %d/%d", st.name, status, out.Code, out.Msg, st.status, st.code)
+		}
+	}
+
+	appeals, err := e.AccountService.ListAppeals(-1, 10, 0)
+	if err != nil || len(appeals) != 1 {
+		t.Fatalf("appeals: %+v %v", appeals, err)
+	}
+	handle := map[string]any{"appeal_id": appeals[0].ID, "approve": true}
+	if status, out := post("/admin/appeal/handle", true, handle); status != http.StatusOK {
+		t.Fatalf("handle: status=%d resp=%+v", status, out)
+	}
+	status, out := post("/admin/appeal/handle", true, handle)
+	if status != http.StatusBadRequest || out.Code != response.CodeParamError {
+		t.Fatalf("handle twice: status=%d resp=%+v", status, out)
+	}
+}
@@ -0,0 +1,194 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.AutoReplyRuleDTO{}
+
+// -------------------- 自动回复（AutoReply）相关接口 --------------------
+
+type CreateAutoReplyRuleReq struct {
+	RoomID      uint64 `json:"room_id"`
+	BotID       uint64 `json:"bot_id"`
+	MatchType   uint8  `json:"match_type"` // 1-包含 2-完全匹配 3-正则
+	Pattern     string `json:"pattern" binding:"required"`
+	Reply       string `json:"reply" binding:"required"`
+	ReplyType   uint8  `json:"reply_type"`
+	Priority    int    `json:"priority"`
+	CooldownSec int    `json:"cooldown_sec"`
+}
+
+type UpdateAutoReplyRuleReq struct {
+	RuleID      uint64 `json:"rule_id" binding:"required"`
+	MatchType   uint8  `json:"match_type"`
+	Pattern     string `json:"pattern" binding:"required"`
+	Reply       string `json:"reply" binding:"required"`
+	ReplyType   uint8  `json:"reply_type"`
+	Priority    int    `json:"priority"`
+	CooldownSec int    `json:"cooldown_sec"`
+	Enabled     *bool  `json:"enabled"` // 不传默认启用
+}
+
+type AutoReplyRuleIDReq struct {
+	RuleID uint64 `json:"rule_id" binding:"required"`
+}
+
+// GinHandleCreateAutoReplyRule 创建自动回复规则
+// @Summary 创建自动回复规则
+// @Description 房间规则（room_id，群主/管理员）或机器人规则（bot_id，机器人创建者）。match_type: 1-包含 2-完全匹配 3-正则；cooldown_sec 为同一房间两次触发的最小间隔
+// @Tags 自动回复
+// @Accept json
+// @Produce json
+// @Param req body CreateAutoReplyRuleReq true "规则参数"
+// @Success 200 {object} response.Response{data=service.AutoReplyRuleDTO} "规则"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /autoreply/create [post]
+func (c *ChatEngine) GinHandleCreateAutoReplyRule(ctx *gin.Context) {
+	var req CreateAutoReplyRuleReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	rule, err := c.AutoReplyService.CreateRule(uid.(uint64), service.AutoReplyRuleReq{
+		RoomID:      req.RoomID,
+		BotID:       req.BotID,
+		MatchType:   req.MatchType,
+		Pattern:     req.Pattern,
+		Reply:       req.Reply,
+		ReplyType:   req.ReplyType,
+		Priority:    req.Priority,
+		CooldownSec: req.CooldownSec,
+	})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(rule))
+}
+
+// GinHandleUpdateAutoReplyRule 修改自动回复规则
+// @Summary 修改自动回复规则
+// @Description 修改规则内容/优先级/冷却时间，enabled=false 可临时停用
+// @Tags 自动回复
+// @Accept json
+// @Produce json
+// @Param req body UpdateAutoReplyRuleReq true "规则参数"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /autoreply/update [post]
+func (c *ChatEngine) GinHandleUpdateAutoReplyRule(ctx *gin.Context) {
+	var req UpdateAutoReplyRuleReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	err := c.AutoReplyService.UpdateRule(uid.(uint64), req.RuleID, service.AutoReplyRuleReq{
+		MatchType:   req.MatchType,
+		Pattern:     req.Pattern,
+		Reply:       req.Reply,
+		ReplyType:   req.ReplyType,
+		Priority:    req.Priority,
+		CooldownSec: req.CooldownSec,
+	}, enabled)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// GinHandleDeleteAutoReplyRule 删除自动回复规则
+// @Summary 删除自动回复规则
+// @Description 删除一条自动回复规则
+// @Tags 自动回复
+// @Accept json
+// @Produce json
+// @Param req body AutoReplyRuleIDReq true "规则ID"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /autoreply/delete [post]
+func (c *ChatEngine) GinHandleDeleteAutoReplyRule(ctx *gin.Context) {
+	var req AutoReplyRuleIDReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	if err := c.AutoReplyService.DeleteRule(uid.(uint64), req.RuleID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// ListAutoReplyRulesReq 规则列表参数，room_id 与 bot_id 至少传一个
+type ListAutoReplyRulesReq struct {
+	RoomID uint64 `form:"room_id" binding:"required_without=BotID"`
+	BotID  uint64 `form:"bot_id"`
+}
+
+// GinHandleListAutoReplyRules 自动回复规则列表
+// @Summary 自动回复规则列表
+// @Description 按房间（room_id）或机器人（bot_id）查询规则，bot_id 优先
+// @Tags 自动回复
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 false "房间ID"
+// @Param bot_id query uint64 false "机器人ID"
+// @Success 200 {object} response.Response{data=[]service.AutoReplyRuleDTO} "规则列表"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /autoreply/list [get]
+func (c *ChatEngine) GinHandleListAutoReplyRules(ctx *gin.Context) {
+	var req ListAutoReplyRulesReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	list, err := c.AutoReplyService.ListRules(uid.(uint64), req.RoomID, req.BotID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
@@ -0,0 +1,210 @@
+package chat_sdk
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------- 机器人（Bot）相关接口 --------------------
+
+type CreateBotReq struct {
+	Name       string `json:"name" binding:"required"`
+	Avatar     string `json:"avatar"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+type BotIDReq struct {
+	BotID uint64 `json:"bot_id" binding:"required"`
+}
+
+type UpdateBotWebhookReq struct {
+	BotID      uint64 `json:"bot_id" binding:"required"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+type BotSendMessageReq struct {
+	RoomID  uint64        `json:"room_id" binding:"required"`
+	Content string        `json:"content" binding:"required"`
+	MsgType uint8         `json:"msg_type"`
+	Extra   message.Extra `json:"extra"`
+}
+
+// GinHandleCreateBot 创建机器人
+// @Summary 创建机器人
+// @Description 创建机器人账号（同时生成对应用户），返回的 api_key / webhook_secret 只展示一次
+// @Tags 机器人
+// @Accept json
+// @Produce json
+// @Param req body CreateBotReq true "创建参数"
+// @Success 200 {object} response.Response{data=service.CreateBotResp} "机器人信息与 API Key"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /bot/create [post]
+func (c *ChatEngine) GinHandleCreateBot(ctx *gin.Context) {
+	var req CreateBotReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	resp, err := c.BotService.CreateBot(uid.(uint64), service.CreateBotReq{
+		Name:       req.Name,
+		Avatar:     req.Avatar,
+		WebhookURL: req.WebhookURL,
+	})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(resp))
+}
+
+// GinHandleListBots 我创建的机器人列表
+// @Summary 机器人列表
+// @Description 获取当前用户创建的机器人列表（不含 API Key 明文）
+// @Tags 机器人
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.BotDTO} "机器人列表"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /bot/list [get]
+func (c *ChatEngine) GinHandleListBots(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	list, err := c.BotService.ListBots(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// GinHandleResetBotKey 重置机器人 API Key
+// @Summary 重置机器人 API Key
+// @Description 重新生成机器人 API Key，旧 Key 立即失效；新 Key 只展示一次
+// @Tags 机器人
+// @Accept json
+// @Produce json
+// @Param req body BotIDReq true "机器人ID"
+// @Success 200 {object} response.Response{data=service.CreateBotResp} "新的 API Key"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /bot/key/reset [post]
+func (c *ChatEngine) GinHandleResetBotKey(ctx *gin.Context) {
+	var req BotIDReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	resp, err := c.BotService.ResetAPIKey(uid.(uint64), req.BotID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(resp))
+}
+
+// GinHandleUpdateBotWebhook 修改机器人 Webhook
+// @Summary 修改机器人 Webhook
+// @Description 修改机器人接收消息的 Webhook 地址，传空表示关闭投递。请求头 X-Chat-Signature: sha256=<hmac(webhook_secret, body)>
+// @Tags 机器人
+// @Accept json
+// @Produce json
+// @Param req body UpdateBotWebhookReq true "Webhook 参数"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /bot/webhook [post]
+func (c *ChatEngine) GinHandleUpdateBotWebhook(ctx *gin.Context) {
+	var req UpdateBotWebhookReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	if err := c.BotService.UpdateWebhook(uid.(uint64), req.BotID, req.WebhookURL); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// GinHandleBotSendMessage 机器人发送消息
+// @Summary 机器人发送消息
+// @Description 机器人通过 API Key 向所在房间发送消息（X-Bot-Key: <api_key> 或 Authorization: Bot <api_key>），与 WS / HTTP 发消息走同一流程（拉黑、客服会话、SLA 统计、自动回复）
+// @Tags 机器人
+// @Accept json
+// @Produce json
+// @Param X-Bot-Key header string true "机器人 API Key"
+// @Param req body BotSendMessageReq true "消息参数"
+// @Success 200 {object} response.Response{data=service.MessageDTO} "消息"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "API Key 无效"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /bot/send [post]
+func (c *ChatEngine) GinHandleBotSendMessage(ctx *gin.Context) {
+	received := time.Now()
+	var req BotSendMessageReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	botUserID := uid.(uint64)
+
+	if req.MsgType == 0 {
+		req.MsgType = 1
+	}
+	sender := c.UserService.UserBrief(botUserID)
+	savedMsg, err := sendUserMessage(botUserID, message.Req{
+		Type:        message.WsTypeMessage,
+		SendTo:      req.RoomID,
+		SendType:    req.MsgType,
+		SendContent: req.Content,
+		Extra:       req.Extra,
+	}, sender.Nickname, sender.Avatar, received)
+	if errors.Is(err, service.ErrNotRoomMember) {
+		err = service.ErrBotNotRoomMember
+	}
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(service.ToMessageDTO(savedMsg)))
+}
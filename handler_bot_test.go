@@ -0,0 +1,76 @@
+package chat_sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+// 机器人发消息与用户发消息走同一流程：推送给成员并计入投递 SLA；不在房间时返回 403
+func TestBotSendMessage_SharedPipeline(t *testing.T) {
+	e, err := NewTestEngine()
+	if err != nil {
+		t.Fatalf("NewTestEngine: %v", err)
+	}
+	defer e.Close()
+	report, err := e.ProvisionService.Provision(service.ProvisionRequest{Users: []service.ProvisionUser{
+		{Username: "alice"}, {Username: "bob"},
+	}}, false)
+	if err != nil || report.Created != 2 {
+		t.Fatalf("provision: %+v %v", report, err)
+	}
+	aliceID, bobID := report.Rows[0].ID, report.Rows[1].ID
+	bot, err := e.BotService.CreateBot(aliceID, service.CreateBotReq{Name: "helper"})
+	if err != nil {
+		t.Fatalf("CreateBot: %v", err)
+	}
+	room, err := e.RoomService.CreateGroupRoom("g", aliceID, []uint64{bobID, bot.Bot.UserID})
+	if err != nil {
+		t.Fatalf("CreateGroupRoom: %v", err)
+	}
+	other, err := e.RoomService.CreatePrivateRoom(aliceID, bobID)
+	if err != nil {
+		t.Fatalf("CreatePrivateRoom: %v", err)
+	}
+
+	srv := httptest.NewServer(e.Handler(nil))
+	defer srv.Close()
+	send := func(roomID uint64) (int, response.Response) {
+		t.Helper()
+		b, _ := json.Marshal(BotSendMessageReq{RoomID: roomID, Content: "hi"})
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/bot/send", bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Bot-Key", bot.APIKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("bot send: %v", err)
+		}
+		defer resp.Body.Close()
+		var out response.Response
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("bot send: decode: %v", err)
+		}
+		return resp.StatusCode, out
+	}
+
+	before := e.DeliverySLA.Snapshot().Observed
+	if status, out := send(room.ID); status != http.StatusOK || out.Code != response.CodeSuccess {
+		t.Fatalf("send: status=%d resp=%+v", status, out)
+	}
+	if got := e.PushedTypes(bobID); len(got) == 0 || got[len(got)-1] != "message" {
+		t.Fatalf("bob pushed = %v", got)
+	}
+	if got := e.DeliverySLA.Snapshot().Observed; got != before+1 {
+		t.Fatalf("delivery SLA observed %d, want %d", got, before+1)
+	}
+
+	status, out := send(other.ID)
+	if status != http.StatusForbidden || out.Code != response.CodePermissionDeny {
+		t.Fatalf("non-member: status=%d resp=%+v", status, out)
+	}
+}
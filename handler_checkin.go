@@ -0,0 +1,96 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.CheckInResult{}
+
+// -------------------- 群签到（CheckIn）相关接口 --------------------
+
+type RoomCheckInReq struct {
+	RoomID uint64 `json:"room_id" binding:"required"`
+}
+
+// GinHandleRoomCheckIn 群签到
+// @Summary 群签到
+// @Description 每日签到，返回连续/累计天数；连续签到 7/30/100/365 天时群里会出现系统消息
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param req body RoomCheckInReq true "房间ID"
+// @Success 200 {object} response.Response{data=service.CheckInResult} "签到结果"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /room/checkin [post]
+func (c *ChatEngine) GinHandleRoomCheckIn(ctx *gin.Context) {
+	var req RoomCheckInReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	res, err := c.CheckInService.CheckIn(req.RoomID, uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	if res.SystemMessage != nil {
+		if room, err := c.RoomService.GetRoomByID(req.RoomID); err == nil {
+			pushRoomMessage(room, res.SystemMessage, "", "", "")
+		}
+	}
+	ctx.JSON(http.StatusOK, response.Success(res))
+}
+
+// CheckInLeaderboardReq 签到排行榜参数
+type CheckInLeaderboardReq struct {
+	RoomID  uint64 `form:"room_id" binding:"required"`
+	OrderBy string `form:"order_by,default=streak" binding:"oneof=streak total"`
+	Limit   int    `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+// GinHandleRoomCheckInLeaderboard 群签到排行榜
+// @Summary 群签到排行榜
+// @Description order_by=streak 按当前连续天数（默认，已中断的不上榜），order_by=total 按累计天数
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "房间ID"
+// @Param order_by query string false "streak/total"
+// @Param limit query int false "数量，默认 20，最大 100"
+// @Success 200 {object} response.Response{data=[]service.CheckInRankItem} "排行榜"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /room/checkin/leaderboard [get]
+func (c *ChatEngine) GinHandleRoomCheckInLeaderboard(ctx *gin.Context) {
+	var req CheckInLeaderboardReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	list, err := c.CheckInService.Leaderboard(req.RoomID, uid.(uint64), req.OrderBy, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
@@ -0,0 +1,71 @@
+package chat_sdk
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------- 合规归档（Compliance）相关接口 --------------------
+
+type ComplianceArchiveReq struct {
+	RoomID    uint64 `form:"room_id"`
+	SenderID  uint64 `form:"sender_id"`
+	MessageID uint64 `form:"message_id"`
+	Event     string `form:"event" binding:"omitempty,oneof=created imported recalled deleted view_once_cleared expired purged"`
+	Since     int64  `form:"since"` // 归档时间下限（unix 秒，含）
+	Until     int64  `form:"until"` // 归档时间上限（unix 秒，不含）
+	Cursor    uint64 `form:"cursor"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// GinHandleComplianceExportArchive 导出合规归档
+// @Summary 导出合规归档
+// @Description 需开启 chat_sdk.WithComplianceArchive 并使用合规令牌（与管理员令牌不同）。按归档 id 升序返回，next_cursor 非 0 时带上继续翻页。每次调用都会记录审计日志。
+// @Tags 合规
+// @Accept json
+// @Produce json
+// @Param room_id query int false "房间 ID"
+// @Param sender_id query int false "发送者 ID"
+// @Param message_id query int false "消息 ID（查看一条消息的全部历史）"
+// @Param event query string false "created/imported/recalled/deleted/view_once_cleared/expired/purged"
+// @Param since query int false "归档时间下限（unix 秒）"
+// @Param until query int false "归档时间上限（unix 秒）"
+// @Param cursor query int false "上一页的 next_cursor"
+// @Param limit query int false "每页条数（默认 100，最大 1000）"
+// @Success 200 {object} response.Response{data=service.ArchivePage} "归档记录"
+// @Security ComplianceToken
+// @Router /compliance/archive [get]
+func (c *ChatEngine) GinHandleComplianceExportArchive(ctx *gin.Context) {
+	var req ComplianceArchiveReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	q := service.ArchiveQuery{
+		RoomID:    req.RoomID,
+		SenderID:  req.SenderID,
+		MessageID: req.MessageID,
+		Event:     req.Event,
+		Cursor:    req.Cursor,
+		Limit:     req.Limit,
+	}
+	if req.Since > 0 {
+		q.Since = time.Unix(req.Since, 0)
+	}
+	if req.Until > 0 {
+		q.Until = time.Unix(req.Until, 0)
+	}
+
+	page, err := c.ComplianceService.ExportArchive(q)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	log.Printf("audit: compliance export ip=%s room=%d sender=%d message=%d event=%s since=%d until=%d cursor=%d records=%d",
+		ctx.ClientIP(), req.RoomID, req.SenderID, req.MessageID, req.Event, req.Since, req.Until, req.Cursor, len(page.Records))
+	ctx.JSON(http.StatusOK, response.Success(page))
+}
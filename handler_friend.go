@@ -2,7 +2,6 @@ package chat_sdk
 
 import (
 	"net/http"
-	"strconv"
 
 	model "github.com/cydxin/chat-sdk/models"
 	"github.com/cydxin/chat-sdk/service"
@@ -19,43 +18,63 @@ var _ = service.FriendApplyDTO{}
 type SendFriendRequestReq struct {
 	ToUser  uint64 `json:"to_user" binding:"required" example:"1001"`
 	Message string `json:"message" example:"你好，交个朋友"`
+	// Source 申请来源：search(默认)/group/nearby；扫码请走 /friend/add-by-qr
+	Source string `json:"source" example:"group"`
+	// RoomID 来源群 ID（source=group 时必填，双方都须在群内）
+	RoomID uint64 `json:"room_id" example:"12"`
 }
 
 // GinHandleSendFriendRequest 发送好友申请
 // @Summary 发送好友申请
-// @Description 向目标用户发送好友申请
+// @Description 向目标用户发送好友申请，source 标记申请来源（search/group/nearby）并按对方隐私设置校验
 // @Tags 好友
 // @Accept json
 // @Produce json
 // @Param req body SendFriendRequestReq true "好友申请"
 // @Success 200 {object} response.Response "成功响应"
 // @Failure 400 {object} response.Response "参数错误"
+// @Failure 403 {object} response.Response "对方不允许通过该方式添加"
+// @Failure 429 {object} response.Response "操作过于频繁"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Security BearerAuth
 // @Router /friend/request [post]
 func (c *ChatEngine) GinHandleSendFriendRequest(ctx *gin.Context) {
 	var req SendFriendRequestReq
 
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	err := c.MemberService.SendFriendRequest(uid.(uint64), req.ToUser, req.Message)
+	err := c.MemberService.SendFriendRequestWithSource(uid.(uint64), req.ToUser, req.Message, req.Source, req.RoomID)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(map[string]interface{}{}, "好友申请已发送"))
 }
 
+// FriendRequestIDQuery 处理好友申请参数
+type FriendRequestIDQuery struct {
+	RequestID uint64 `form:"request_id" binding:"required"`
+}
+
+// FriendIDQuery 删除好友参数
+type FriendIDQuery struct {
+	FriendID uint64 `form:"friend_id" binding:"required"`
+}
+
+// TargetIDQuery 以目标用户为参数的查询（检查好友关系、创建私聊）
+type TargetIDQuery struct {
+	TargetID uint64 `form:"target_id" binding:"required"`
+}
+
 // GinHandleAcceptFriendRequest 同意好友申请
 // @Summary 同意好友申请
 // @Description 同意指定的好友申请
@@ -69,22 +88,21 @@ func (c *ChatEngine) GinHandleSendFriendRequest(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /friend/accept [post]
 func (c *ChatEngine) GinHandleAcceptFriendRequest(ctx *gin.Context) {
-	reqIDStr := ctx.Query("request_id")
-	reqID, err := strconv.ParseUint(reqIDStr, 10, 64)
-	if err != nil || reqID == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid request_id"))
+	var req FriendRequestIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	reqID := req.RequestID
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	err = c.MemberService.AcceptFriendRequest(reqID, uid.(uint64))
+	err := c.MemberService.AcceptFriendRequest(reqID, uid.(uint64))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -106,22 +124,21 @@ func (c *ChatEngine) GinHandleAcceptFriendRequest(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /friend/reject [post]
 func (c *ChatEngine) GinHandleRejectFriendRequest(ctx *gin.Context) {
-	reqIDStr := ctx.Query("request_id")
-	reqID, err := strconv.ParseUint(reqIDStr, 10, 64)
-	if err != nil || reqID == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid request_id"))
+	var req FriendRequestIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	reqID := req.RequestID
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	err = c.MemberService.RejectFriendRequest(reqID, uid.(uint64))
+	err := c.MemberService.RejectFriendRequest(reqID, uid.(uint64))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -143,22 +160,21 @@ func (c *ChatEngine) GinHandleRejectFriendRequest(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /friend/delete [post]
 func (c *ChatEngine) GinHandleDeleteFriend(ctx *gin.Context) {
-	friendIDStr := ctx.Query("friend_id")
-	friendID, err := strconv.ParseUint(friendIDStr, 10, 64)
-	if err != nil || friendID == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid friend_id"))
+	var req FriendIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	friendID := req.FriendID
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	err = c.MemberService.DeleteFriend(uid.(uint64), friendID)
+	err := c.MemberService.DeleteFriend(uid.(uint64), friendID)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -167,12 +183,18 @@ func (c *ChatEngine) GinHandleDeleteFriend(ctx *gin.Context) {
 	}))
 }
 
+// FriendListQuery 好友列表参数
+type FriendListQuery struct {
+	Sort string `form:"sort" binding:"omitempty,oneof=name added"`
+}
+
 // GinHandleGetFriendList 获取好友列表
 // @Summary 获取好友列表
-// @Description 获取当前用户的好友列表
+// @Description 获取当前用户的好友列表；sort=name 按显示名（备注>昵称>用户名）字母/拼音排序，sort=added 最近添加的在前
 // @Tags 好友
 // @Accept json
 // @Produce json
+// @Param sort query string false "排序: name/added，默认按添加顺序"
 // @Success 200 {object} response.Response{data=[]service.UserDTO} "好友列表"
 // @Failure 400 {object} response.Response "参数错误"
 // @Failure 500 {object} response.Response "服务器错误"
@@ -181,16 +203,77 @@ func (c *ChatEngine) GinHandleDeleteFriend(ctx *gin.Context) {
 func (c *ChatEngine) GinHandleGetFriendList(ctx *gin.Context) {
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req FriendListQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	friends, err := c.MemberService.ListFriends(uid.(uint64), service.FriendListOptions{Sort: req.Sort})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(friends))
+}
+
+// GinHandleGetFriendSections 按首字母分组的好友列表
+// @Summary 通讯录分组
+// @Description 按显示名（备注>昵称>用户名）拼音首字母分组的好友列表：A–Z 在前，无法识别的归入 "#"，组内按显示名排序
+// @Tags 好友
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.FriendSection} "通讯录分组"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /friend/sections [get]
+func (c *ChatEngine) GinHandleGetFriendSections(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	friends, err := c.MemberService.GetFriendList(uid.(uint64))
+	sections, err := c.MemberService.GetFriendSections(uid.(uint64))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
+	ctx.JSON(http.StatusOK, response.Success(sections))
+}
 
+// GinHandleGetStarredFriends 星标好友列表
+// @Summary 星标好友列表
+// @Description 获取当前用户的星标好友（通讯录顶部快捷列表），sort 同好友列表
+// @Tags 好友
+// @Accept json
+// @Produce json
+// @Param sort query string false "排序: name/added，默认按添加顺序"
+// @Success 200 {object} response.Response{data=[]service.UserDTO} "星标好友"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /friend/starred [get]
+func (c *ChatEngine) GinHandleGetStarredFriends(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req FriendListQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	friends, err := c.MemberService.ListFriends(uid.(uint64), service.FriendListOptions{Sort: req.Sort, StarredOnly: true})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
 	ctx.JSON(http.StatusOK, response.Success(friends))
 }
 
@@ -208,13 +291,13 @@ func (c *ChatEngine) GinHandleGetFriendList(ctx *gin.Context) {
 func (c *ChatEngine) GinHandleGetPendingRequests(ctx *gin.Context) {
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	requests, err := c.MemberService.GetPendingRequests(uid.(uint64))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -234,28 +317,95 @@ func (c *ChatEngine) GinHandleGetPendingRequests(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /friend/check [get]
 func (c *ChatEngine) GinHandleCheckFriendship(ctx *gin.Context) {
-	targetIDStr := ctx.Query("target_id")
-	targetID, err := strconv.ParseUint(targetIDStr, 10, 64)
-	if err != nil || targetID == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid target_id"))
+	var req TargetIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	targetID := req.TargetID
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	ok, err := c.MemberService.CheckFriendship(uid.(uint64), targetID)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(map[string]interface{}{"is_friend": ok}))
 }
 
+// MutualQuery 共同好友/共同群聊参数
+type MutualQuery struct {
+	TargetID uint64 `form:"target_id" binding:"required"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+// GinHandleMutualFriends 共同好友
+// @Summary 共同好友
+// @Description 当前用户与目标用户的共同好友数量及列表（按用户 ID 升序，默认最多 50 个）；任意一方拉黑对方时返回空
+// @Tags 好友
+// @Accept json
+// @Produce json
+// @Param target_id query uint64 true "目标用户 ID"
+// @Param limit query int false "列表数量(默认50,最大50)"
+// @Success 200 {object} response.Response{data=service.MutualFriendsDTO} "共同好友"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /friend/mutual [get]
+func (c *ChatEngine) GinHandleMutualFriends(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req MutualQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	out, err := c.MemberService.GetMutualFriends(uid.(uint64), req.TargetID, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
+// GinHandleCommonGroups 共同群聊
+// @Summary 共同群聊
+// @Description 当前用户与目标用户都在的群聊（按最近活跃排序，默认最多 50 个）；任意一方拉黑对方时返回空
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param target_id query uint64 true "目标用户 ID"
+// @Param limit query int false "数量(默认50,最大50)"
+// @Success 200 {object} response.Response{data=[]service.CommonGroupDTO} "共同群聊"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/common-groups [get]
+func (c *ChatEngine) GinHandleCommonGroups(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req MutualQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	out, err := c.MemberService.GetCommonGroups(uid.(uint64), req.TargetID, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
 // GinHandleMemberSearchUsers 搜索用户 (MemberService版本)
 // @Summary 搜索用户 (Member)
 // @Description 搜索用户，返回用户基本信息列表（用于添加好友等场景）
@@ -269,19 +419,19 @@ func (c *ChatEngine) GinHandleCheckFriendship(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /member/search [get]
 func (c *ChatEngine) GinHandleMemberSearchUsers(ctx *gin.Context) {
-	keyword := ctx.Query("keyword")
-	limitStr := ctx.Query("limit")
-
-	limit, _ := strconv.Atoi(limitStr)
+	var req SearchUsersReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
 
 	var curID int64
 	if uid, exists := ctx.Get("user_id"); exists {
 		curID = int64(uid.(uint64))
 	}
 
-	users, err := c.MemberService.SearchUsers(keyword, curID, limit)
+	users, err := c.MemberService.SearchUsers(req.Keyword, curID, req.Limit)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -307,19 +457,55 @@ type SetFriendRemarkReq struct {
 // @Router /friend/remark [post]
 func (c *ChatEngine) GinHandleSetFriendRemark(ctx *gin.Context) {
 	var req SetFriendRemarkReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	if err := c.MemberService.SetFriendRemark(uid.(uint64), req.FriendID, req.Remark); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+type SetFriendStarReq struct {
+	FriendID uint64 `json:"friend_id" binding:"required" example:"1002"`
+	Star     bool   `json:"star" example:"true"`
+}
+
+// GinHandleSetFriendStar 设置星标好友
+// @Summary 设置星标好友
+// @Description 星标/取消星标某个好友（仅影响自己视角），星标好友在好友列表中 is_star=true，并出现在 /friend/starred
+// @Tags 好友
+// @Accept json
+// @Produce json
+// @Param req body SetFriendStarReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /friend/star [post]
+func (c *ChatEngine) GinHandleSetFriendStar(ctx *gin.Context) {
+	var req SetFriendStarReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	if err := c.MemberService.SetFriendStar(uid.(uint64), req.FriendID, req.Star); err != nil {
+		writeServiceError(ctx, err)
 		return
 	}
 
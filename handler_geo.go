@@ -0,0 +1,108 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.NearbyUserDTO{}
+
+// -------------------- 附近的人（Geo）相关接口 --------------------
+
+type UpdateLocationReq struct {
+	Latitude  float64 `json:"latitude" binding:"required" example:"31.2304"`
+	Longitude float64 `json:"longitude" binding:"required" example:"121.4737"`
+}
+
+// GinHandleUpdateLocation 上报位置（开启附近的人）
+// @Summary 上报位置
+// @Description 上报当前位置，即开启“附近的人”（位置 24 小时后自动失效，需要重新上报）；需要 Redis
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param req body UpdateLocationReq true "经纬度"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/location [post]
+func (c *ChatEngine) GinHandleUpdateLocation(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req UpdateLocationReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.GeoService.UpdateLocation(ctx.Request.Context(), uid.(uint64), req.Latitude, req.Longitude); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// GinHandleClearLocation 清除位置（关闭附近的人）
+// @Summary 清除位置
+// @Description 清除已上报的位置，之后不会出现在他人的“附近的人”中
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/location/clear [post]
+func (c *ChatEngine) GinHandleClearLocation(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	if err := c.GeoService.ClearLocation(ctx.Request.Context(), uid.(uint64)); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// NearbyUsersReq 附近的人参数
+type NearbyUsersReq struct {
+	RadiusKm float64 `form:"radius_km,default=5" binding:"gt=0,max=50"`
+	Limit    int     `form:"limit,default=50" binding:"min=1,max=100"`
+}
+
+// GinHandleNearbyUsers 附近的人
+// @Summary 附近的人
+// @Description 以自己上报的位置为中心查询附近同样开启了该功能的用户（距离按 100 米取整，不返回坐标，已拉黑的不展示）；每分钟限 10 次
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param radius_km query number false "半径(公里,默认5,最大50)"
+// @Param limit query int false "数量(默认50,最大100)"
+// @Success 200 {object} response.Response{data=[]service.NearbyUserDTO} "附近的人"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/nearby [get]
+func (c *ChatEngine) GinHandleNearbyUsers(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req NearbyUsersReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	list, err := c.GeoService.Nearby(ctx.Request.Context(), uid.(uint64), req.RadiusKm, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
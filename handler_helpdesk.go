@@ -0,0 +1,234 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.HelpDeskSessionDTO{}
+
+// -------------------- 客服（HelpDesk）相关接口 --------------------
+
+type CreateVisitorReq struct {
+	Nickname string `json:"nickname"`
+}
+
+type HelpDeskSessionIDReq struct {
+	SessionID uint64 `json:"session_id" binding:"required"`
+}
+
+type TransferHelpDeskReq struct {
+	SessionID uint64 `json:"session_id" binding:"required"`
+	ToAgentID uint64 `json:"to_agent_id"` // 不传则自动分配
+}
+
+type RateHelpDeskReq struct {
+	SessionID uint64 `json:"session_id" binding:"required"`
+	Rating    uint8  `json:"rating" binding:"required"` // 1-5
+	Comment   string `json:"comment"`
+}
+
+// GinHandleCreateVisitor 创建匿名访客
+// @Summary 创建匿名访客
+// @Description 创建一个访客账号并返回 token（需要 Redis），访客用该 token 建立 WS 与调用客服接口
+// @Tags 客服
+// @Accept json
+// @Produce json
+// @Param req body CreateVisitorReq false "访客昵称"
+// @Success 200 {object} response.Response{data=service.VisitorResp} "访客信息与 token"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /helpdesk/visitor [post]
+func (c *ChatEngine) GinHandleCreateVisitor(ctx *gin.Context) {
+	var req CreateVisitorReq
+	_ = ctx.ShouldBindJSON(&req)
+
+	resp, err := c.HelpDeskService.CreateVisitor(ctx.Request.Context(), req.Nickname)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(resp))
+}
+
+// GinHandleOpenHelpDeskSession 发起客服咨询
+// @Summary 发起客服咨询
+// @Description 有未结束的会话直接返回；否则创建客服房间并按策略分配在线客服，无可用客服时进入排队（status=1）
+// @Tags 客服
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.HelpDeskSessionDTO} "会话"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /helpdesk/open [post]
+func (c *ChatEngine) GinHandleOpenHelpDeskSession(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	sess, err := c.HelpDeskService.OpenSession(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(sess))
+}
+
+// GinHandleClaimHelpDeskSession 客服领取排队会话
+// @Summary 领取排队会话
+// @Description 客服领取最早排队的会话，没有排队会话时 data 为 null
+// @Tags 客服
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.HelpDeskSessionDTO} "会话"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /helpdesk/claim [post]
+func (c *ChatEngine) GinHandleClaimHelpDeskSession(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	sess, err := c.HelpDeskService.ClaimSession(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(sess))
+}
+
+// GinHandleTransferHelpDeskSession 转接会话
+// @Summary 转接客服会话
+// @Description 当前接待客服把会话转给其他客服，to_agent_id 不传则自动分配
+// @Tags 客服
+// @Accept json
+// @Produce json
+// @Param req body TransferHelpDeskReq true "转接参数"
+// @Success 200 {object} response.Response{data=service.HelpDeskSessionDTO} "会话"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /helpdesk/transfer [post]
+func (c *ChatEngine) GinHandleTransferHelpDeskSession(ctx *gin.Context) {
+	var req TransferHelpDeskReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	sess, err := c.HelpDeskService.TransferSession(uid.(uint64), req.SessionID, req.ToAgentID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(sess))
+}
+
+// GinHandleCloseHelpDeskSession 结束会话
+// @Summary 结束客服会话
+// @Description 访客或当前客服结束会话，结束后房间不能再发消息
+// @Tags 客服
+// @Accept json
+// @Produce json
+// @Param req body HelpDeskSessionIDReq true "会话ID"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /helpdesk/close [post]
+func (c *ChatEngine) GinHandleCloseHelpDeskSession(ctx *gin.Context) {
+	var req HelpDeskSessionIDReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	if err := c.HelpDeskService.CloseSession(uid.(uint64), req.SessionID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// GinHandleRateHelpDeskSession 评价会话
+// @Summary 评价客服会话
+// @Description 访客对已结束的会话评分（1-5），每个会话只能评价一次
+// @Tags 客服
+// @Accept json
+// @Produce json
+// @Param req body RateHelpDeskReq true "评价参数"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /helpdesk/rate [post]
+func (c *ChatEngine) GinHandleRateHelpDeskSession(ctx *gin.Context) {
+	var req RateHelpDeskReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	if err := c.HelpDeskService.RateSession(uid.(uint64), req.SessionID, req.Rating, req.Comment); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// ListHelpDeskSessionsReq 坐席会话列表参数，status 不传为全部
+type ListHelpDeskSessionsReq struct {
+	Status uint8 `form:"status" binding:"omitempty,oneof=2 3"`
+}
+
+// GinHandleListHelpDeskSessions 客服会话列表
+// @Summary 客服会话列表
+// @Description 客服查看自己接待的会话，status 不传表示所有未结束的会话
+// @Tags 客服
+// @Accept json
+// @Produce json
+// @Param status query int false "状态: 2-接待中 3-已结束"
+// @Success 200 {object} response.Response{data=[]service.HelpDeskSessionDTO} "会话列表"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /helpdesk/sessions [get]
+func (c *ChatEngine) GinHandleListHelpDeskSessions(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req ListHelpDeskSessionsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	list, err := c.HelpDeskService.ListAgentSessions(uid.(uint64), req.Status)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
@@ -1,10 +1,13 @@
 package chat_sdk
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/cydxin/chat-sdk/message"
 	model "github.com/cydxin/chat-sdk/models"
 	"github.com/cydxin/chat-sdk/service"
 
@@ -18,12 +21,18 @@ var _ = service.MessageListItemDTO{}
 
 // -------------------- 消息（Message）相关接口 --------------------
 
+// ConversationListQuery 会话列表参数
+type ConversationListQuery struct {
+	Tag string `form:"tag" binding:"omitempty,max=60" example:"工作"`
+}
+
 // GinHandleGetMessageConversations 获取消息列表（会话列表）
 // @Summary 获取消息列表
-// @Description 获取当前用户的会话列表（未删除的会话），包含头像、名称、room、最后一条消息、未读数
+// @Description 获取当前用户的会话列表（未删除的会话），包含头像、名称、room、最后一条消息、未读数、标签；传 tag 时只返回打了该标签的会话
 // @Tags 消息
 // @Accept json
 // @Produce json
+// @Param tag query string false "按标签过滤"
 // @Success 200 {object} response.Response{data=[]service.ConversationListItemDTO} "会话列表"
 // @Failure 400 {object} response.Response "参数错误"
 // @Failure 500 {object} response.Response "服务器错误"
@@ -32,18 +41,100 @@ var _ = service.MessageListItemDTO{}
 func (c *ChatEngine) GinHandleGetMessageConversations(ctx *gin.Context) {
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req ConversationListQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
 
-	list, err := c.ConversationService.GetConversationList(uid.(uint64))
+	list, err := c.ConversationService.GetConversationList(uid.(uint64), req.Tag)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(list))
 }
 
+// ConversationPageQuery 会话列表分页参数
+type ConversationPageQuery struct {
+	Cursor   string `form:"cursor"` // 上一页的 next_cursor，首页不传
+	Limit    int    `form:"limit,default=20" binding:"min=1,max=100"`
+	Tag      string `form:"tag" binding:"omitempty,max=60"`
+	Archived bool   `form:"archived"`
+}
+
+// GinHandleGetConversationPage 分页获取会话列表
+// @Summary 分页获取会话列表
+// @Description 置顶在前，同组内按更新时间倒序（同一时间按会话 ID 倒序），以 next_cursor 翻页，为空表示没有更多；
+// @Description 拉完首页后以 server_time 作为 since 调用 /message/conversations/updates 做增量刷新。tag/archived 过滤同会话列表
+// @Tags 消息
+// @Produce json
+// @Param cursor query string false "上一页的 next_cursor"
+// @Param limit query int false "每页数量(默认20,最大100)"
+// @Param tag query string false "按标签过滤"
+// @Param archived query bool false "只看已归档"
+// @Success 200 {object} response.Response{data=service.ConversationPageDTO} "会话分页"
+// @Failure 400 {object} response.Response "参数错误/游标无效"
+// @Security BearerAuth
+// @Router /message/conversations/page [get]
+func (c *ChatEngine) GinHandleGetConversationPage(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req ConversationPageQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	page, err := c.ConversationService.ListConversationPage(uid.(uint64), service.ConversationListOptions{Tag: req.Tag, Archived: req.Archived}, req.Cursor, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(page))
+}
+
+// ConversationUpdatesQuery 会话增量参数
+type ConversationUpdatesQuery struct {
+	Since  int64  `form:"since" binding:"min=0"` // 毫秒，首次取分页接口的 server_time
+	Cursor string `form:"cursor"`                // 上次返回的 cursor，优先于 since
+	Limit  int    `form:"limit,default=100" binding:"min=1,max=500"`
+}
+
+// GinHandleGetConversationUpdates 增量获取有变化的会话
+// @Summary 会话增量刷新
+// @Description 返回 since 之后有变化（新消息、置顶/免打扰/归档/隐藏）的会话，按更新时间升序，结构同会话列表；包含已归档与已隐藏的会话（hidden=true 时从列表移除）。
+// @Description 每次保存返回的 cursor，下次传 cursor 即可；has_more=true 时继续拉取
+// @Tags 消息
+// @Produce json
+// @Param since query int false "起始时间（毫秒）"
+// @Param cursor query string false "上次返回的 cursor"
+// @Param limit query int false "条数(默认100,最大500)"
+// @Success 200 {object} response.Response{data=service.ConversationUpdatesDTO} "有变化的会话"
+// @Failure 400 {object} response.Response "参数错误/游标无效"
+// @Security BearerAuth
+// @Router /message/conversations/updates [get]
+func (c *ChatEngine) GinHandleGetConversationUpdates(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req ConversationUpdatesQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	out, err := c.ConversationService.ConversationUpdates(uid.(uint64), req.Since, req.Cursor, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
 // GinHandleHideConversation 隐藏会话（从消息列表不展示）
 // @Summary 隐藏会话
 // @Description 将当前用户某个房间的会话从消息列表隐藏（仅影响自己；新消息会自动重新展示）
@@ -57,35 +148,521 @@ func (c *ChatEngine) GinHandleGetMessageConversations(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /message/conversation/hide [post]
 func (c *ChatEngine) GinHandleHideConversation(ctx *gin.Context) {
-	ridStr := ctx.Query("room_id")
-	rid, err := strconv.ParseUint(ridStr, 10, 64)
-	if err != nil || rid == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid room_id"))
+	var req RoomIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	if err := c.ConversationService.SoftDeleteConversation(uid.(uint64), rid); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+	if err := c.ConversationService.SoftDeleteConversation(uid.(uint64), req.RoomID); err != nil {
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
 }
 
+// GinHandleGetArchivedConversations 已归档的会话列表
+// @Summary 已归档会话列表
+// @Description 获取当前用户已归档的会话（结构同消息列表）；已归档会话不出现在 /message/conversations 中，也不计入总未读
+// @Tags 消息
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.ConversationListItemDTO} "会话列表"
+// @Security BearerAuth
+// @Router /message/conversations/archived [get]
+func (c *ChatEngine) GinHandleGetArchivedConversations(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	list, err := c.ConversationService.GetArchivedConversationList(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// GinHandleConversationUnreadTotal 消息总未读数
+// @Summary 消息总未读数
+// @Description 可见且未归档的会话未读数之和（用于角标）
+// @Tags 消息
+// @Produce json
+// @Success 200 {object} response.Response "{unread}"
+// @Security BearerAuth
+// @Router /message/conversations/unread-total [get]
+func (c *ChatEngine) GinHandleConversationUnreadTotal(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	n, err := c.ConversationService.TotalUnread(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"unread": n}))
+}
+
+// ConversationArchiveReq 归档/取消归档会话
+type ConversationArchiveReq struct {
+	RoomID   uint64 `json:"room_id" binding:"required" example:"1"`
+	Archived bool   `json:"archived"`
+}
+
+// GinHandleArchiveConversation 归档/取消归档会话
+// @Summary 归档会话
+// @Description 归档后会话移到归档列表、不计入总未读；与隐藏、免打扰互相独立。新消息默认不会取消归档（见 chat_sdk.WithAutoUnarchive）
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body ConversationArchiveReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /message/conversation/archive [post]
+func (c *ChatEngine) GinHandleArchiveConversation(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req ConversationArchiveReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	if err := c.ConversationService.SetConversationArchived(uid.(uint64), req.RoomID, req.Archived); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// ConversationMuteReq 会话免打扰
+type ConversationMuteReq struct {
+	RoomID uint64 `json:"room_id" binding:"required" example:"1"`
+	Muted  bool   `json:"muted"`
+}
+
+// GinHandleMuteConversation 会话免打扰开关
+// @Summary 会话免打扰
+// @Description 只记录在会话上（列表返回 is_muted），由客户端决定是否提醒；免打扰的归档会话收到新消息也保持归档
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body ConversationMuteReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /message/conversation/mute [post]
+func (c *ChatEngine) GinHandleMuteConversation(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req ConversationMuteReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	if err := c.ConversationService.SetConversationMuted(uid.(uint64), req.RoomID, req.Muted); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// ConversationSettingsQuery 会话设置查询参数
+type ConversationSettingsQuery struct {
+	// RoomID 逗号分隔的房间 ID，为空返回全部设置过的会话
+	RoomID string `form:"room_id" example:"1,2"`
+}
+
+// GinHandleGetConversationSettings 获取会话设置
+// @Summary 获取会话设置
+// @Description 获取自己在会话中的客户端设置（聊天背景、自定义别名），只返回设置过的会话
+// @Tags 消息
+// @Produce json
+// @Param room_id query string false "逗号分隔的房间 ID"
+// @Success 200 {object} response.Response{data=[]service.ConversationSettingsDTO} "会话设置"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security BearerAuth
+// @Router /message/conversation/settings [get]
+func (c *ChatEngine) GinHandleGetConversationSettings(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req ConversationSettingsQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	var roomIDs []uint64
+	for _, part := range strings.Split(req.RoomID, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			writeError(ctx, response.CodeParamError, "room_id 格式错误")
+			return
+		}
+		roomIDs = append(roomIDs, id)
+	}
+	out, err := c.ConversationService.GetConversationSettings(uid.(uint64), roomIDs...)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
+// GinHandleUpdateConversationSettings 修改会话设置
+// @Summary 修改会话设置
+// @Description 修改自己在会话中的聊天背景/自定义别名（只对自己生效，不影响会话列表与消息），版本号加 1 并推送 conversation_settings 给本人所有设备；
+// @Description 传 base_version 且与服务端不一致时返回冲突
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body service.UpdateConversationSettingsReq true "请求参数"
+// @Success 200 {object} response.Response{data=service.ConversationSettingsDTO} "修改后的设置"
+// @Failure 400 {object} response.Response "参数错误/会话不存在/版本冲突"
+// @Security BearerAuth
+// @Router /message/conversation/settings [post]
+func (c *ChatEngine) GinHandleUpdateConversationSettings(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req service.UpdateConversationSettingsReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	out, err := c.ConversationService.UpdateConversationSettings(uid.(uint64), req)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
+// GinHandleListConversationTags 我的会话标签
+// @Summary 会话标签列表
+// @Description 我用过的全部会话标签（按名称排序）及各自的会话数，标签只对自己可见
+// @Tags 消息
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.ConversationTagDTO} "标签列表"
+// @Security BearerAuth
+// @Router /message/conversation/tags [get]
+func (c *ChatEngine) GinHandleListConversationTags(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	out, err := c.ConversationService.ListConversationTags(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
+// GinHandleSetConversationTags 设置会话标签
+// @Summary 设置会话标签
+// @Description 整体替换某个会话的标签（传空数组清除）。每个标签最长 20 个字符，每个会话最多 10 个，每人最多使用 50 个不同标签
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body service.SetConversationTagsReq true "请求参数"
+// @Success 200 {object} response.Response{data=[]string} "会话当前的标签"
+// @Failure 400 {object} response.Response "参数错误/会话不存在"
+// @Security BearerAuth
+// @Router /message/conversation/tags [post]
+func (c *ChatEngine) GinHandleSetConversationTags(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req service.SetConversationTagsReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	out, err := c.ConversationService.SetConversationTags(uid.(uint64), req)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
+// GinHandleRenameConversationTag 重命名会话标签
+// @Summary 重命名会话标签
+// @Description 所有打了该标签的会话一起改名；新名称已存在时合并
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body service.RenameConversationTagReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "参数错误/标签不存在"
+// @Security BearerAuth
+// @Router /message/conversation/tag/rename [post]
+func (c *ChatEngine) GinHandleRenameConversationTag(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req service.RenameConversationTagReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.ConversationService.RenameConversationTag(uid.(uint64), req); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// DeleteConversationTagReq 删除会话标签
+type DeleteConversationTagReq struct {
+	Tag string `json:"tag" binding:"required" example:"工作"`
+}
+
+// GinHandleDeleteConversationTag 删除会话标签
+// @Summary 删除会话标签
+// @Description 从所有会话上移除该标签，返回受影响的会话数
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body DeleteConversationTagReq true "请求参数"
+// @Success 200 {object} response.Response "{deleted}"
+// @Failure 400 {object} response.Response "参数错误/标签不存在"
+// @Security BearerAuth
+// @Router /message/conversation/tag/delete [post]
+func (c *ChatEngine) GinHandleDeleteConversationTag(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req DeleteConversationTagReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	n, err := c.ConversationService.DeleteConversationTag(uid.(uint64), req.Tag)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"deleted": n}))
+}
+
+// ViewOnceOpenReq 查看阅后即焚消息
+type ViewOnceOpenReq struct {
+	MessageID uint64 `json:"message_id" binding:"required" example:"1"`
+}
+
+// GinHandleOpenViewOnceMessage 查看阅后即焚消息
+// @Summary 查看阅后即焚消息
+// @Description 接收方只能查看一次，返回原内容后服务端清除内容并推送 view_once_viewed；发送时 extra.view_once=true（仅私聊）
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body ViewOnceOpenReq true "请求参数"
+// @Success 200 {object} response.Response{data=service.MessageListItemDTO}
+// @Security BearerAuth
+// @Router /message/view-once/open [post]
+func (c *ChatEngine) GinHandleOpenViewOnceMessage(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req ViewOnceOpenReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	msg, err := c.MsgService.OpenViewOnceMessage(uid.(uint64), req.MessageID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(msg))
+}
+
+// MessageReminderReq 设置消息提醒
+type MessageReminderReq struct {
+	MessageID uint64    `json:"message_id" binding:"required" example:"1"`
+	RemindAt  time.Time `json:"remind_at" binding:"required" example:"2025-01-01T09:00:00+08:00"`
+	Note      string    `json:"note" binding:"max=255" example:"记得回复"`
+}
+
+// GinHandleSetMessageReminder 设置消息提醒
+// @Summary 设置消息提醒（稍后提醒我）
+// @Description 标记会话中的一条消息，到 remind_at 时通过通知服务给自己发送 message.reminder 事件；同一消息重复设置会更新时间
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body MessageReminderReq true "请求参数"
+// @Success 200 {object} response.Response{data=service.MessageReminderDTO}
+// @Failure 400 {object} response.Response "参数错误 / 时间已过 / 数量超限"
+// @Failure 403 {object} response.Response "不是房间成员"
+// @Security BearerAuth
+// @Router /message/reminder [post]
+func (c *ChatEngine) GinHandleSetMessageReminder(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req MessageReminderReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	r, err := c.MsgService.SetMessageReminder(uid.(uint64), req.MessageID, req.RemindAt, req.Note)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(r))
+}
+
+// GinHandleCancelMessageReminder 取消消息提醒
+// @Summary 取消消息提醒
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body ViewOnceOpenReq true "请求参数（message_id）"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /message/reminder/cancel [post]
+func (c *ChatEngine) GinHandleCancelMessageReminder(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req ViewOnceOpenReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	if err := c.MsgService.CancelMessageReminder(uid.(uint64), req.MessageID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleListMessageReminders 待提醒的消息
+// @Summary 待提醒的消息
+// @Description 按提醒时间先后返回尚未触发的提醒，附带消息内容（消息已删除时 message 为空）
+// @Tags 消息
+// @Produce json
+// @Param limit query int false "数量，默认 20，最大 100"
+// @Param offset query int false "偏移"
+// @Success 200 {object} response.Response{data=[]service.MessageReminderDTO}
+// @Security BearerAuth
+// @Router /message/reminders [get]
+func (c *ChatEngine) GinHandleListMessageReminders(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req PageQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	list, err := c.MsgService.ListUpcomingReminders(uid.(uint64), req.Limit, req.Offset)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// SendMessageReq HTTP 发送消息，字段含义同 WS 的 message.Req
+type SendMessageReq struct {
+	RoomID   uint64        `json:"room_id" binding:"required" example:"1"`
+	MsgType  uint8         `json:"msg_type" binding:"required" example:"1"` // 1-文本 2-图片 3-语音 4-视频 5-文件 6-位置 7-引用 8-艾特
+	Content  string        `json:"content" example:"你好"`
+	Extra    message.Extra `json:"extra"`
+	PacketID string        `json:"packet_id" example:"c-1700000000000"` // 客户端包 ID，原样带回推送的 message 事件，用于去重
+}
+
+// GinHandleSendMessage 发送消息（HTTP）
+// @Summary 发送消息
+// @Description 不经 WS 发送消息，与 WS 发消息走同一流程：校验成员身份、私聊拉黑、客服会话状态与禁言，落库后推送给房间成员（含发送者的 WS 连接）并触发机器人/自动回复。
+// @Description 适合服务端集成与无长连接的客户端；失败时返回与 WS error 事件相同的业务码，被禁言时 data 为禁言信息。
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param req body SendMessageReq true "消息参数"
+// @Success 200 {object} response.Response{data=service.MessageDTO} "已发送的消息"
+// @Failure 400 {object} response.Response "参数错误/房间不存在"
+// @Failure 403 {object} response.Response "非成员/已拉黑/会话已结束/被禁言"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /message/send [post]
+func (c *ChatEngine) GinHandleSendMessage(ctx *gin.Context) {
+	received := time.Now()
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req SendMessageReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	userID := uid.(uint64)
+	sender := c.UserService.UserBrief(userID)
+	savedMsg, err := sendUserMessage(userID, message.Req{
+		Type:        message.WsTypeMessage,
+		SendTo:      req.RoomID,
+		SendType:    req.MsgType,
+		SendContent: req.Content,
+		Extra:       req.Extra,
+		PacketID:    req.PacketID,
+	}, sender.Nickname, sender.Avatar, received)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(service.ToMessageDTO(savedMsg)))
+}
+
 type RecallReqBody struct {
-	MessageIDs []uint64 `json:"message_ids" binding:"required" swaggertype:"array,integer"`
+	MessageIDs []uint64 `json:"message_ids" binding:"required,min=1,max=100" swaggertype:"array,integer"`
 	Status     uint8    `json:"status" binding:"required" example:"1"`
 }
 
 // GinHandleRecallMessage 撤回/删除消息（批量）
 // @Summary 撤回/删除消息（批量）
-// @Description 批量撤回/删除消息，body 传 message_ids + status
+// @Description 批量撤回/删除消息，body 传 message_ids + status；撤回时限、管理员能否撤回成员消息、是否保留占位见 chat_sdk.WithRecallPolicy
 // @Tags 消息
 // @Accept json
 // @Produce json
@@ -98,24 +675,19 @@ type RecallReqBody struct {
 func (c *ChatEngine) GinHandleRecallMessage(ctx *gin.Context) {
 
 	var req RecallReqBody
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
-		return
-	}
-	if len(req.MessageIDs) == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "message_ids is required"))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id未找到"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id未找到")
 		return
 	}
 
 	okIDs, failedMap, err := c.MsgService.RecallMessages(req.MessageIDs, uid.(uint64), req.Status)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodePermissionDeny, err.Error()))
+		writeError(ctx, response.CodePermissionDeny, err.Error())
 		return
 	}
 
@@ -138,14 +710,21 @@ func (c *ChatEngine) GinHandleRecallMessage(ctx *gin.Context) {
 	}))
 }
 
+// GetRoomMessagesReq 房间消息分页参数
+type GetRoomMessagesReq struct {
+	RoomID uint64 `form:"room_id" binding:"required"`
+	Limit  int    `form:"limit,default=20" binding:"min=1,max=100"`
+	MessID int    `form:"mess_id" binding:"min=0"`
+}
+
 // GinHandleGetRoomMessages 获取房间消息列表
 // @Summary 获取房间消息
-// @Description 分页获取房间历史消息
+// @Description 分页获取房间历史消息：已删除（双删 / 自己单删）的不返回，撤回的只返回不含内容的占位（status=4）
 // @Tags 消息
 // @Accept json
 // @Produce json
 // @Param room_id query uint64 true "房间ID"
-// @Param limit query int false "每页数量"
+// @Param limit query int false "每页数量(默认20,最大100)"
 // @Param mess_id query int false "偏移量 以你要查询的ID为基准，向前查询，不传则向后"
 // @Success 200 {object} response.Response{data=[]service.MessageListItemDTO} "消息列表"
 // @Failure 400 {object} response.Response "参数错误"
@@ -153,56 +732,178 @@ func (c *ChatEngine) GinHandleRecallMessage(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /message/list [get]
 func (c *ChatEngine) GinHandleGetRoomMessages(ctx *gin.Context) {
-	roomIDStr := ctx.Query("room_id")
-	limitStr := ctx.Query("limit")
-	messIdStr := ctx.Query("mess_id")
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req GetRoomMessagesReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	messages, err := c.MsgService.GetRoomMessagesDTO(req.RoomID, uid.(uint64), req.Limit, req.MessID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(messages))
+}
 
-	roomID, _ := strconv.ParseUint(roomIDStr, 10, 64)
-	limit, _ := strconv.Atoi(limitStr)
-	messId, _ := strconv.Atoi(messIdStr)
+// SearchInRoomReq 会话内搜索参数
+type SearchInRoomReq struct {
+	RoomID  uint64 `form:"room_id" binding:"required"`
+	Keyword string `form:"keyword" binding:"required,max=64"`
+	Cursor  uint64 `form:"cursor"`
+	Limit   int    `form:"limit,default=20" binding:"min=1,max=50"`
+}
 
-	if limit == 0 {
-		limit = 20
+// GinHandleSearchInRoom 会话内搜索
+// @Summary 会话内搜索
+// @Description 在单个房间内按关键词搜索文本/引用/@ 消息（不区分大小写），按消息 ID 从新到旧、游标翻页。
+// @Description highlights 为命中位置 [start, end)，按 Unicode 字符计；撤回、删除、阅后即焚、加密与系统消息不参与搜索。
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "房间ID"
+// @Param keyword query string true "关键词(最多64字)"
+// @Param cursor query uint64 false "上一页的 next_cursor"
+// @Param limit query int false "每页数量(默认20,最大50)"
+// @Success 200 {object} response.Response{data=service.RoomSearchPage} "搜索结果"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /message/search-in-room [get]
+func (c *ChatEngine) GinHandleSearchInRoom(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req SearchInRoomReq
+	if !bindQuery(ctx, &req) {
+		return
 	}
+	page, err := c.MsgService.SearchInRoom(req.RoomID, uid.(uint64), req.Keyword, req.Cursor, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(page))
+}
+
+// MessageContextReq 消息上下文参数
+type MessageContextReq struct {
+	RoomID    uint64 `form:"room_id" binding:"required"`
+	MessageID uint64 `form:"message_id" binding:"required"`
+	Before    int    `form:"before,default=20" binding:"min=0,max=50"`
+	After     int    `form:"after,default=20" binding:"min=0,max=50"`
+}
 
-	messages, err := c.MsgService.GetRoomMessagesDTO(roomID, limit, messId)
+// GinHandleGetMessageContext 定位消息
+// @Summary 定位消息（上下文）
+// @Description 返回指定消息及其前 before 条、后 after 条可见消息（按 ID 从旧到新），用于搜索结果跳转、引用定位后滚动到该消息
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "房间ID"
+// @Param message_id query uint64 true "目标消息ID"
+// @Param before query int false "之前的条数(默认20,最大50)"
+// @Param after query int false "之后的条数(默认20,最大50)"
+// @Success 200 {object} response.Response{data=service.MessageContextDTO} "消息上下文"
+// @Failure 400 {object} response.Response "参数错误/消息不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /message/context [get]
+func (c *ChatEngine) GinHandleGetMessageContext(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req MessageContextReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	out, err := c.MsgService.GetMessageContext(req.RoomID, uid.(uint64), req.MessageID, req.Before, req.After)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
 
-	ctx.JSON(http.StatusOK, response.Success(messages))
+// MessageIDQuery 单条消息查询参数
+type MessageIDQuery struct {
+	MessageID uint64 `form:"message_id" binding:"required"`
 }
 
 // GinHandleGetMessageByID 根据 message_id 获取消息
 // @Summary 获取消息详情
-// @Description 根据消息ID获取消息详情
+// @Description 根据消息ID获取消息详情，仅消息所在房间的成员可查看；撤回的消息只返回占位，双删/自己删除的消息视为不存在
 // @Tags 消息
 // @Accept json
 // @Produce json
 // @Param message_id query uint64 true "消息ID"
 // @Success 200 {object} response.Response{data=service.MessageDTO} "消息详情"
-// @Failure 400 {object} response.Response "参数错误"
+// @Failure 400 {object} response.Response "参数错误/消息不存在"
+// @Failure 403 {object} response.Response "不是房间成员"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Security BearerAuth
 // @Router /message/detail [get]
 func (c *ChatEngine) GinHandleGetMessageByID(ctx *gin.Context) {
-	msgIDStr := ctx.Query("message_id")
-	mid, err := strconv.ParseUint(msgIDStr, 10, 64)
-	if err != nil || mid == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid message_id"))
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req MessageIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
 
-	msg, err := c.MsgService.GetMessageByID(mid)
+	// 消息没有 room_id 参数，挂不了 RoomMemberGuard：由 service 按消息所在房间校验成员
+	msg, err := c.MsgService.GetMessageForViewer(req.MessageID, uid.(uint64))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(msg))
 }
 
+// GinHandleGetMessageReceipts 消息回执（谁已读）
+// @Summary 消息回执
+// @Description 查询自己发送的消息的已读/已送达未读/未送达成员（群消息"谁已读"）；回执由 WS delivery_ack / read_ack 上报
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param message_id query uint64 true "消息ID"
+// @Success 200 {object} response.Response{data=service.MessageReceiptDTO} "回执详情"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /message/receipts [get]
+func (c *ChatEngine) GinHandleGetMessageReceipts(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req MessageIDQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	receipts, err := c.MsgService.ReadReceipt.GetMessageReceipts(req.MessageID, uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(receipts))
+}
+
 // --- 转发/合并转发 ---
 
 type ForwardMessageReq struct {
@@ -216,24 +917,23 @@ type ForwardMessageReq struct {
 
 // GinHandleForwardMessages 转发消息（支持合并转发）
 // @Summary 转发消息
-// @Description 支持逐条转发(single) 或 合并转发(merge)
+// @Description 支持逐条转发(single) 或 合并转发(merge)；每个目标房间独立事务，results 为按房间的结果（error 非空表示该房间失败，如被禁言）
 // @Tags 消息
 // @Accept json
 // @Produce json
 // @Param req body ForwardMessageReq true "转发请求"
-// @Success 200 {object} response.Response{data=map[string]any} "创建的消息ID列表"
+// @Success 200 {object} response.Response{data=map[string]any} "message_ids 创建的消息ID列表 + results 按房间结果"
 // @Security BearerAuth
 // @Router /message/forward [post]
 func (c *ChatEngine) GinHandleForwardMessages(ctx *gin.Context) {
 	var req ForwardMessageReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
@@ -242,7 +942,7 @@ func (c *ChatEngine) GinHandleForwardMessages(ctx *gin.Context) {
 		items = append(items, service.ForwardItem{MessageID: it.MessageID})
 	}
 
-	created, err := c.MsgService.ForwardMessages(ctx.Request.Context(), service.ForwardReq{
+	results, err := c.MsgService.ForwardMessages(ctx.Request.Context(), service.ForwardReq{
 		FromUserID: uid.(uint64),
 		ToRoomIDs:  req.ToRoomIDs,
 		Mode:       service.ForwardMode(strings.ToLower(strings.TrimSpace(req.Mode))),
@@ -250,9 +950,79 @@ func (c *ChatEngine) GinHandleForwardMessages(ctx *gin.Context) {
 		Comment:    req.Comment,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
+		return
+	}
+
+	created := make([]uint64, 0)
+	for _, r := range results {
+		created = append(created, r.MessageIDs...)
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message_ids": created, "results": results}))
+}
+
+// PollMessagesReq 长轮询参数
+type PollMessagesReq struct {
+	Cursor  uint64 `form:"cursor"`
+	Timeout int    `form:"timeout" binding:"min=0,max=60"`
+}
+
+// GinHandlePollMessages 长轮询拉取事件（WS/SSE 不可用时的降级方案）
+// @Summary 长轮询拉取事件
+// @Description 挂起等待当前用户的新事件（与 WS 推送内容一致），有事件立即返回，否则超时返回空列表；下次请求携带返回的 cursor
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Param cursor query uint64 false "上次返回的 cursor（首次传 0）"
+// @Param timeout query int false "最长挂起秒数(默认25,最大60)"
+// @Success 200 {object} response.Response{data=map[string]interface{}} "data.events + data.cursor"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /message/poll [get]
+func (c *ChatEngine) GinHandlePollMessages(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	var req PollMessagesReq
+	if !bindQuery(ctx, &req) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message_ids": created}))
+	go c.ServerStatsService.RecordActive(context.Background(), uid.(uint64), time.Now())
+	events, next := c.WsServer.Poll(ctx.Request.Context(), uid.(uint64), req.Cursor, time.Duration(req.Timeout)*time.Second)
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{
+		"events": events,
+		"cursor": next,
+	}))
+}
+
+// ServerTimeQuery 校时参数
+type ServerTimeQuery struct {
+	ClientTime int64 `form:"client_time" binding:"min=0"` // 可选：客户端发出请求时的本地时间（毫秒），原样返回
+}
+
+// ServerTimeResp 服务端时间
+type ServerTimeResp struct {
+	ServerTime int64 `json:"server_time"`           // 毫秒时间戳
+	ClientTime int64 `json:"client_time,omitempty"` // 请求中的 client_time
+}
+
+// GinHandleServerTime 获取服务端时间（校时）
+// @Summary 获取服务端时间
+// @Description 免登录。客户端本地时钟不准时，用 server_time - (client_time + 往返耗时/2) 估算时钟偏差，
+// @Description 发送中的消息以校正后的时间展示，落库后以消息的 send_time / room_seq 为准
+// @Tags 消息
+// @Produce json
+// @Param client_time query int64 false "客户端本地时间（毫秒）"
+// @Success 200 {object} response.Response{data=ServerTimeResp} "服务端时间"
+// @Router /time [get]
+func (c *ChatEngine) GinHandleServerTime(ctx *gin.Context) {
+	var req ServerTimeQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(ServerTimeResp{ServerTime: time.Now().UnixMilli(), ClientTime: req.ClientTime}))
 }
@@ -0,0 +1,129 @@
+package chat_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+// newMessageDetailEngine alice、bob 的私聊里有一条 alice 发的消息，carol 不在房间里；返回各自的 token
+func newMessageDetailEngine(t *testing.T) (e *TestEngine, srv *httptest.Server, msgID uint64, ids map[string]uint64, tokens map[string]string) {
+	t.Helper()
+	e, err := NewTestEngine()
+	if err != nil {
+		t.Fatalf("NewTestEngine: %v", err)
+	}
+	t.Cleanup(e.Close)
+	report, err := e.ProvisionService.Provision(service.ProvisionRequest{Users: []service.ProvisionUser{
+		{Username: "alice", Password: "secret123"},
+		{Username: "bob", Password: "secret123"},
+		{Username: "carol", Password: "secret123"},
+	}}, false)
+	if err != nil || report.Created != 3 {
+		t.Fatalf("provision: %+v %v", report, err)
+	}
+	ids, tokens = make(map[string]uint64), make(map[string]string)
+	for _, row := range report.Rows {
+		login, err := e.UserService.LoginWithToken(context.Background(), service.LoginReq{Account: row.Key, Password: "secret123"})
+		if err != nil {
+			t.Fatalf("login %s: %v", row.Key, err)
+		}
+		ids[row.Key], tokens[row.Key] = row.ID, login.Token
+	}
+	room, err := e.RoomService.CreatePrivateRoom(ids["alice"], ids["bob"])
+	if err != nil {
+		t.Fatalf("CreatePrivateRoom: %v", err)
+	}
+	msg, err := e.MsgService.SaveMessage(room.ID, ids["alice"], "hello", 1, message.Extra{})
+	if err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	srv = httptest.NewServer(e.Handler(nil))
+	t.Cleanup(srv.Close)
+	return e, srv, msg.ID, ids, tokens
+}
+
+// getMessageDetail 以 token 调用 /message/detail
+func getMessageDetail(t *testing.T, srv *httptest.Server, token string, msgID uint64) (int, response.Response) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/message/detail?message_id=%d", srv.URL, msgID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("detail: %v", err)
+	}
+	defer resp.Body.Close()
+	var out response.Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("detail: decode: %v", err)
+	}
+	return resp.StatusCode, out
+}
+
+func TestGetMessageByID_MemberOnly(t *testing.T) {
+	_, srv, msgID, _, tokens := newMessageDetailEngine(t)
+
+	if status, out := getMessageDetail(t, srv, tokens["bob"], msgID); status != http.StatusOK || out.Code != response.CodeSuccess {
+		t.Fatalf("member: status=%d resp=%+v", status, out)
+	}
+	if status, out := getMessageDetail(t, srv, tokens["carol"], msgID); status != http.StatusForbidden || out.Code != response.CodePermissionDeny {
+		t.Fatalf("non-member: status=%d resp=%+v", status, out)
+	}
+	if status, out := getMessageDetail(t, srv, tokens["bob"], msgID+100); status != http.StatusBadRequest || out.Code != response.CodeParamError {
+		t.Fatalf("missing message: status=%d resp=%+v", status, out)
+	}
+}
+
+// 消息详情与消息列表同样的按人展示策略：撤回只留占位，双删与自己单删的消息不存在
+func TestGetMessageByID_ViewerPolicy(t *testing.T) {
+	e, srv, msgID, ids, tokens := newMessageDetailEngine(t)
+	alice, bob := ids["alice"], ids["bob"]
+	detail, _ := e.MsgService.GetMessageByID(msgID)
+	send := func(content string) uint64 {
+		m, err := e.MsgService.SaveMessage(detail.RoomID, alice, content, 1, message.Extra{})
+		if err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+		return m.ID
+	}
+	bothDeleted, forMe := send("both deleted"), send("deleted for bob")
+
+	if err := e.MsgService.RecallMessage(msgID, alice); err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if _, failed, err := e.MsgService.RecallMessages([]uint64{bothDeleted}, alice, models.MessageStatusBothDeleted); err != nil || len(failed) != 0 {
+		t.Fatalf("both delete: %v %v", failed, err)
+	}
+	if _, failed, err := e.MsgService.RecallMessages([]uint64{forMe}, bob, models.MessageStatusDeleted); err != nil || len(failed) != 0 {
+		t.Fatalf("delete for me: %v %v", failed, err)
+	}
+
+	status, out := getMessageDetail(t, srv, tokens["bob"], msgID)
+	if status != http.StatusOK {
+		t.Fatalf("recalled: status=%d resp=%+v", status, out)
+	}
+	var dto service.MessageDTO
+	b, _ := json.Marshal(out.Data)
+	_ = json.Unmarshal(b, &dto)
+	if dto.Status != models.MessageStatusRecalled || dto.Content != "" || len(dto.Extra) != 0 {
+		t.Fatalf("recalled message leaked: %+v", dto)
+	}
+
+	for name, id := range map[string]uint64{"both deleted": bothDeleted, "deleted for me": forMe} {
+		if status, out := getMessageDetail(t, srv, tokens["bob"], id); status != http.StatusBadRequest || out.Code != response.CodeParamError {
+			t.Fatalf("%s: status=%d resp=%+v", name, status, out)
+		}
+	}
+	// 单删只对自己生效
+	if status, out := getMessageDetail(t, srv, tokens["alice"], forMe); status != http.StatusOK {
+		t.Fatalf("sender view: status=%d resp=%+v", status, out)
+	}
+}
@@ -2,7 +2,6 @@ package chat_sdk
 
 import (
 	"net/http"
-	"strconv"
 
 	model "github.com/cydxin/chat-sdk/models"
 
@@ -29,20 +28,19 @@ var _ = model.Moment{}
 // @Router /moment/create [post]
 func (c *ChatEngine) GinHandleCreateMoment(ctx *gin.Context) {
 	var req service.CreateMomentReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	dto, err := c.MomentService.CreateMoment(uid.(uint64), req)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(dto))
@@ -54,25 +52,27 @@ func (c *ChatEngine) GinHandleCreateMoment(ctx *gin.Context) {
 // @Tags 朋友圈
 // @Accept json
 // @Produce json
-// @Param limit query int false "每页数量"
+// @Param limit query int false "每页数量(默认20,最大100)"
 // @Param offset query int false "偏移量"
 // @Success 200 {object} response.Response{data=[]service.MomentDTO} "动态列表"
 // @Failure 401 {object} response.Response "未登录"
 // @Security BearerAuth
 // @Router /moment/list [get]
 func (c *ChatEngine) GinHandleListFriendMoments(ctx *gin.Context) {
-	limit, _ := strconv.Atoi(ctx.Query("limit"))
-	offset, _ := strconv.Atoi(ctx.Query("offset"))
+	var req PageQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	list, err := c.MomentService.ListFriendMoments(uid.(uint64), limit, offset)
+	list, err := c.MomentService.ListFriendMoments(uid.(uint64), req.Limit, req.Offset)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(list))
@@ -95,46 +95,48 @@ type CommentMomentReq struct {
 // @Router /moment/comment [post]
 func (c *ChatEngine) GinHandleCommentMoment(ctx *gin.Context) {
 	var req CommentMomentReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 	if err := c.MomentService.AddComment(uid.(uint64), req.MomentID, req.Content, req.ParentID); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(nil))
 }
 
+// ListMomentCommentsReq 动态评论分页参数
+type ListMomentCommentsReq struct {
+	MomentID uint64 `form:"moment_id" binding:"required"`
+	Limit    int    `form:"limit,default=50" binding:"min=1,max=100"`
+	Offset   int    `form:"offset" binding:"min=0"`
+}
+
 // GinHandleListMomentComments 获取动态评论
 // @Summary 获取动态评论
 // @Tags 朋友圈
 // @Accept json
 // @Produce json
 // @Param moment_id query uint64 true "动态ID"
-// @Param limit query int false "每页数量"
+// @Param limit query int false "每页数量(默认50,最大100)"
 // @Param offset query int false "偏移量"
 // @Success 200 {object} response.Response{data=[]service.CommentDTO} "评论列表"
 // @Security BearerAuth
 // @Router /moment/comment/list [get]
 func (c *ChatEngine) GinHandleListMomentComments(ctx *gin.Context) {
-	midStr := ctx.Query("moment_id")
-	mid, err := strconv.ParseUint(midStr, 10, 64)
-	if err != nil || mid == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid moment_id"))
+	var req ListMomentCommentsReq
+	if !bindQuery(ctx, &req) {
 		return
 	}
-	limit, _ := strconv.Atoi(ctx.Query("limit"))
-	offset, _ := strconv.Atoi(ctx.Query("offset"))
 
-	list, err := c.MomentService.ListComments(mid, limit, offset)
+	list, err := c.MomentService.ListComments(req.MomentID, req.Limit, req.Offset)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(list))
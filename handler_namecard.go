@@ -0,0 +1,110 @@
+package chat_sdk
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.NamecardDTO{}
+
+// -------------------- 二维码名片（Namecard）相关接口 --------------------
+
+// UserQRCodeReq 二维码图片参数
+type UserQRCodeReq struct {
+	Size int `form:"size,default=256" binding:"min=64,max=1024"`
+}
+
+// GinHandleUserQRCode 获取我的二维码名片（PNG）
+// @Summary 我的二维码名片
+// @Description 返回 PNG 图片，内容为 chatsdk://namecard?uid=...&token=...（token 为带签名、会过期的加好友令牌，默认 7 天）
+// @Tags 用户
+// @Produce png
+// @Param size query int false "图片边长(像素,默认256,最大1024)"
+// @Success 200 {file} file "二维码图片"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/qrcode [get]
+func (c *ChatEngine) GinHandleUserQRCode(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req UserQRCodeReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	img, card, err := c.NamecardService.GenerateQRCode(uid.(uint64), req.Size)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Namecard-Expires-At", strconv.FormatInt(card.ExpiresAt, 10))
+	ctx.Data(http.StatusOK, "image/png", img)
+}
+
+// GinHandleUserNamecard 获取我的名片内容（不生成图片，供客户端自行绘制二维码）
+// @Summary 我的名片内容
+// @Description 返回二维码编码内容与加好友令牌
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.NamecardDTO} "名片内容"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/namecard [get]
+func (c *ChatEngine) GinHandleUserNamecard(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	card, err := c.NamecardService.GetNamecard(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(card))
+}
+
+type AddFriendByQRReq struct {
+	Token   string `json:"token" binding:"required"`
+	Message string `json:"message" example:"你好，交个朋友"`
+}
+
+// GinHandleAddFriendByQR 扫码加好友
+// @Summary 扫码加好友
+// @Description 携带二维码中的 token 向名片主人发送好友申请
+// @Tags 好友
+// @Accept json
+// @Produce json
+// @Param req body AddFriendByQRReq true "扫码结果"
+// @Success 200 {object} response.Response{data=service.UserBasicDTO} "名片主人"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /friend/add-by-qr [post]
+func (c *ChatEngine) GinHandleAddFriendByQR(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req AddFriendByQRReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	target, err := c.NamecardService.AddFriendByQR(uid.(uint64), req.Token, req.Message)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(target, "好友申请已发送"))
+}
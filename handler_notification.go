@@ -2,14 +2,23 @@ package chat_sdk
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
 	"github.com/gin-gonic/gin"
 )
 
 // -------------------- 通知（Notification）相关接口 --------------------
 
+// ListNotificationsReq 通知列表参数
+type ListNotificationsReq struct {
+	Days       int     `form:"days,default=2" binding:"min=1,max=30"`
+	Cursor     uint64  `form:"cursor"`
+	Limit      int     `form:"limit,default=50" binding:"min=1,max=200"`
+	RoomID     *uint64 `form:"room_id" binding:"omitempty,min=1"`
+	UnreadOnly bool    `form:"unread_only"`
+}
+
 // GinHandleListNotifications 拉取通知（默认近 2 天）
 // @Summary 拉取通知
 // @Tags 通知
@@ -26,34 +35,23 @@ import (
 func (c *ChatEngine) GinHandleListNotifications(ctx *gin.Context) {
 	uidAny, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 	uid := uidAny.(uint64)
 
+	var req ListNotificationsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
 	// 默认：近 2 天；但如果没传 room_id，则获取自己全部的一天内通知
-	days, _ := strconv.Atoi(ctx.DefaultQuery("days", "2"))
-	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
-	cursor, _ := strconv.ParseUint(ctx.DefaultQuery("cursor", "0"), 10, 64)
-	unreadOnly := ctx.DefaultQuery("unread_only", "false") == "true"
-
-	var roomID *uint64
-	if ridStr := ctx.Query("room_id"); ridStr != "" {
-		rid, err := strconv.ParseUint(ridStr, 10, 64)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid room_id"))
-			return
-		}
-		roomID = &rid
-	} else {
-		// 没传 room_id：强制取近 1 天 + 不按房间过滤（roomID=nil）
-		days = 1
-		roomID = nil
-	}
-
-	items, nextCursor, err := c.NotificationService.ListUserNotifications(uid, days, cursor, limit, roomID, unreadOnly)
+	if req.RoomID == nil {
+		req.Days = 1
+	}
+
+	items, nextCursor, err := c.NotificationService.ListUserNotifications(uid, req.Days, req.Cursor, req.Limit, req.RoomID, req.UnreadOnly)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -79,21 +77,161 @@ type MarkNotificationsReadReq struct {
 func (c *ChatEngine) GinHandleMarkNotificationsRead(ctx *gin.Context) {
 	uidAny, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 	uid := uidAny.(uint64)
 
 	var req MarkNotificationsReadReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	if err := c.NotificationService.MarkReadByIDs(uid, req.IDs); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(nil))
 }
+
+// MarkAllNotificationsReadReq 全部已读参数
+type MarkAllNotificationsReadReq struct {
+	// RoomID 只处理该房间，不传为全部
+	RoomID *uint64 `json:"room_id" binding:"omitempty,min=1"`
+}
+
+// GinHandleMarkAllNotificationsRead 全部通知标记已读
+// @Summary 全部通知标记已读
+// @Tags 通知
+// @Accept json
+// @Produce json
+// @Param req body MarkAllNotificationsReadReq false "请求参数"
+// @Success 200 {object} response.Response{data=map[string]interface{}} "data.updated 标记条数"
+// @Security BearerAuth
+// @Router /notification/read-all [post]
+func (c *ChatEngine) GinHandleMarkAllNotificationsRead(ctx *gin.Context) {
+	uidAny, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	uid := uidAny.(uint64)
+
+	// body 可省略
+	var req MarkAllNotificationsReadReq
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
+		return
+	}
+
+	n, err := c.NotificationService.MarkAllRead(uid, req.RoomID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"updated": n}))
+}
+
+// GinHandleNotificationUnreadCount 未读通知数
+// @Summary 未读通知数
+// @Tags 通知
+// @Produce json
+// @Success 200 {object} response.Response{data=map[string]interface{}} "data.unread"
+// @Security BearerAuth
+// @Router /notification/unread-count [get]
+func (c *ChatEngine) GinHandleNotificationUnreadCount(ctx *gin.Context) {
+	uidAny, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	uid := uidAny.(uint64)
+
+	n, err := c.NotificationService.UnreadCount(uid)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"unread": n}))
+}
+
+// NotificationPreferenceReq 屏蔽/恢复通知类型
+type NotificationPreferenceReq struct {
+	// RoomID 0 表示所有房间
+	RoomID     uint64   `json:"room_id"`
+	EventTypes []string `json:"event_types" binding:"required,min=1"`
+	Muted      bool     `json:"muted"`
+}
+
+// GinHandleSetNotificationPreference 屏蔽/恢复某房间的通知类型
+// @Summary 设置通知偏好
+// @Description 屏蔽后该类事件不再投递与推送（如大群里的 room.member.added）；room_id=0 对所有房间生效；可屏蔽类型见 service.MutableRoomEventTypes
+// @Tags 通知
+// @Accept json
+// @Produce json
+// @Param req body NotificationPreferenceReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /notification/preference [post]
+func (c *ChatEngine) GinHandleSetNotificationPreference(ctx *gin.Context) {
+	uidAny, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	uid := uidAny.(uint64)
+
+	var req NotificationPreferenceReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	if err := c.NotificationService.SetNotificationMuted(uid, req.RoomID, req.EventTypes, req.Muted); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// ListNotificationPreferencesReq 通知偏好查询参数
+type ListNotificationPreferencesReq struct {
+	RoomID *uint64 `form:"room_id"`
+}
+
+// GinHandleListNotificationPreferences 查看通知屏蔽设置
+// @Summary 查看通知偏好
+// @Description 传 room_id 时只返回该房间与全局（room_id=0）的设置
+// @Tags 通知
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 false "房间ID"
+// @Success 200 {object} response.Response{data=map[string]interface{}} "data.items + data.mutable_event_types"
+// @Security BearerAuth
+// @Router /notification/preference [get]
+func (c *ChatEngine) GinHandleListNotificationPreferences(ctx *gin.Context) {
+	uidAny, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	uid := uidAny.(uint64)
+
+	var req ListNotificationPreferencesReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	items, err := c.NotificationService.ListNotificationPreferences(uid, req.RoomID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{
+		"items":               items,
+		"mutable_event_types": service.MutableRoomEventTypes,
+	}))
+}
@@ -0,0 +1,290 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.DepartmentNode{}
+
+// -------------------- 组织架构（Org）相关接口，WithOrgDirectory(true) 时注册 --------------------
+
+// GinHandleOrgTree 部门树
+// @Summary 组织架构部门树
+// @Description 完整的部门树（按 sort 排序），含各部门直属成员数与部门群 room_id
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.DepartmentNode} "部门树"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /org/tree [get]
+func (c *ChatEngine) GinHandleOrgTree(ctx *gin.Context) {
+	tree, err := c.OrgService.Tree()
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(tree))
+}
+
+type OrgMembersQuery struct {
+	DepartmentID uint64 `form:"department_id" binding:"required"`
+	Recursive    bool   `form:"recursive"` // 包含下级部门成员
+}
+
+// GinHandleOrgMembers 部门成员
+// @Summary 部门成员
+// @Description 部门直属成员（负责人在前）；recursive=true 时包含所有下级部门成员，同一用户只出现一次
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param department_id query uint64 true "部门ID"
+// @Param recursive query bool false "包含下级部门"
+// @Success 200 {object} response.Response{data=[]service.OrgMemberDTO} "成员列表"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security BearerAuth
+// @Router /org/department/members [get]
+func (c *ChatEngine) GinHandleOrgMembers(ctx *gin.Context) {
+	var req OrgMembersQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	list, err := c.OrgService.Members(req.DepartmentID, req.Recursive)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// GinHandleMyDepartments 我所在的部门
+// @Summary 我所在的部门
+// @Description 当前用户所在的部门及职位（一个用户可属于多个部门）
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.OrgMemberDTO} "部门列表"
+// @Security BearerAuth
+// @Router /org/my [get]
+func (c *ChatEngine) GinHandleMyDepartments(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	list, err := c.OrgService.UserDepartments(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+type OrgStartChatReq struct {
+	UserID uint64 `json:"user_id" binding:"required"`
+}
+
+// GinHandleOrgStartChat 与同事发起私聊
+// @Summary 与同事发起私聊
+// @Description 双方都在组织架构中即可直接私聊，无需互为好友；私聊房间已存在时直接返回
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body OrgStartChatReq true "同事的用户ID"
+// @Success 200 {object} response.Response{data=service.OrgChatDTO} "私聊房间"
+// @Failure 403 {object} response.Response "对方或自己不在组织架构中"
+// @Security BearerAuth
+// @Router /org/chat [post]
+func (c *ChatEngine) GinHandleOrgStartChat(ctx *gin.Context) {
+	var req OrgStartChatReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	chat, err := c.OrgService.StartChat(uid.(uint64), req.UserID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(chat))
+}
+
+// GinHandleAdminCreateDepartment 创建部门
+// @Summary 创建部门
+// @Description parent_id=0 为顶级部门；auto_group=true 时同时开启部门群
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body service.CreateDepartmentReq true "部门"
+// @Success 200 {object} response.Response{data=service.DepartmentDTO} "部门"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/org/department/create [post]
+func (c *ChatEngine) GinHandleAdminCreateDepartment(ctx *gin.Context) {
+	var req service.CreateDepartmentReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	dept, err := c.OrgService.CreateDepartment(req)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(dept))
+}
+
+// GinHandleAdminUpdateDepartment 修改部门
+// @Summary 修改部门
+// @Description 修改名称/排序，或移动到其他上级部门（不能移到自己的下级）；改名同步到部门群
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body service.UpdateDepartmentReq true "只修改传入的字段"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/org/department/update [post]
+func (c *ChatEngine) GinHandleAdminUpdateDepartment(ctx *gin.Context) {
+	var req service.UpdateDepartmentReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.OrgService.UpdateDepartment(req); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+type AdminDepartmentReq struct {
+	DepartmentID uint64 `json:"department_id" binding:"required"`
+}
+
+// GinHandleAdminDeleteDepartment 删除部门
+// @Summary 删除部门
+// @Description 只能删除没有子部门和成员的部门；部门群保留为普通群
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body AdminDepartmentReq true "部门ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "部门不存在或不为空"
+// @Security AdminToken
+// @Router /admin/org/department/delete [post]
+func (c *ChatEngine) GinHandleAdminDeleteDepartment(ctx *gin.Context) {
+	var req AdminDepartmentReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.OrgService.DeleteDepartment(req.DepartmentID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+type AdminDepartmentGroupReq struct {
+	DepartmentID uint64 `json:"department_id" binding:"required"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// GinHandleAdminSetDepartmentGroup 开启/关闭部门群
+// @Summary 开启/关闭部门群
+// @Description 开启时创建群号为 dept_{id} 的群（之前关闭过则复用）并同步部门直属成员，负责人为管理员；关闭只解除关联，群保留为普通群
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body AdminDepartmentGroupReq true "部门ID与开关"
+// @Success 200 {object} response.Response{data=service.DepartmentDTO} "部门"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/org/department/group [post]
+func (c *ChatEngine) GinHandleAdminSetDepartmentGroup(ctx *gin.Context) {
+	var req AdminDepartmentGroupReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	dept, err := c.OrgService.SetDepartmentGroup(req.DepartmentID, req.Enabled)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(dept))
+}
+
+// GinHandleAdminSyncDepartmentGroup 重新同步部门群成员
+// @Summary 重新同步部门群成员
+// @Description 成员变动后会自动同步；直接改库或同步中断后可调用此接口修复
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body AdminDepartmentReq true "部门ID"
+// @Success 200 {object} response.Response "成功"
+// @Security AdminToken
+// @Router /admin/org/department/sync [post]
+func (c *ChatEngine) GinHandleAdminSyncDepartmentGroup(ctx *gin.Context) {
+	var req AdminDepartmentReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.OrgService.SyncDepartmentGroup(req.DepartmentID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleAdminAddOrgMembers 添加部门成员
+// @Summary 添加部门成员
+// @Description 已在部门中的用户更新职位与负责人标记；开启了部门群时自动入群
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body service.OrgMembersReq true "部门与用户"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/org/member/add [post]
+func (c *ChatEngine) GinHandleAdminAddOrgMembers(ctx *gin.Context) {
+	var req service.OrgMembersReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.OrgService.AddMembers(req); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleAdminRemoveOrgMembers 移除部门成员
+// @Summary 移除部门成员
+// @Description 开启了部门群时自动移出群聊
+// @Tags 组织架构
+// @Accept json
+// @Produce json
+// @Param req body service.OrgMembersReq true "部门与用户（title/is_leader 忽略）"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/org/member/remove [post]
+func (c *ChatEngine) GinHandleAdminRemoveOrgMembers(ctx *gin.Context) {
+	var req service.OrgMembersReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.OrgService.RemoveMembers(req); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
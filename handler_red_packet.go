@@ -0,0 +1,149 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.RedPacketDTO{}
+
+// -------------------- 红包（RedPacket）相关接口 --------------------
+
+type SendRedPacketReq struct {
+	RoomID        uint64 `json:"room_id" binding:"required"`
+	Type          uint8  `json:"type"`                            // 1-拼手气 2-普通
+	TotalAmount   int64  `json:"total_amount" binding:"required"` // 总金额（分）
+	TotalCount    int    `json:"total_count" binding:"required"`
+	Greeting      string `json:"greeting"`
+	ExpireMinutes int    `json:"expire_minutes"` // 默认 24 小时
+}
+
+type ClaimRedPacketReq struct {
+	RedPacketID uint64 `json:"red_packet_id" binding:"required"`
+}
+
+// GinHandleSendRedPacket 发红包
+// @Summary 发红包
+// @Description 从钱包扣款并在房间发送红包消息（msg_type=10），金额单位为分；过期未领完的金额自动退回
+// @Tags 红包
+// @Accept json
+// @Produce json
+// @Param req body SendRedPacketReq true "红包参数"
+// @Success 200 {object} response.Response{data=service.MessageDTO} "红包消息"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /redpacket/send [post]
+func (c *ChatEngine) GinHandleSendRedPacket(ctx *gin.Context) {
+	var req SendRedPacketReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	senderID := uid.(uint64)
+
+	room, err := c.RoomService.GetRoomByID(req.RoomID)
+	if err != nil {
+		writeError(ctx, response.CodeParamError, "房间不存在")
+		return
+	}
+	_, msg, err := c.RedPacketService.SendRedPacket(ctx.Request.Context(), senderID, service.SendRedPacketReq{
+		RoomID:        room.ID,
+		Type:          req.Type,
+		TotalAmount:   req.TotalAmount,
+		TotalCount:    req.TotalCount,
+		Greeting:      req.Greeting,
+		ExpireMinutes: req.ExpireMinutes,
+	})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	sender := c.UserService.UserBrief(senderID)
+	pushRoomMessage(room, msg, "", sender.Nickname, sender.Avatar)
+
+	ctx.JSON(http.StatusOK, response.Success(service.ToMessageDTO(msg)))
+}
+
+// GinHandleClaimRedPacket 抢红包
+// @Summary 抢红包
+// @Description 领取红包，金额入账到钱包，并向房间成员推送 redpacket.claimed 通知
+// @Tags 红包
+// @Accept json
+// @Produce json
+// @Param req body ClaimRedPacketReq true "红包ID"
+// @Success 200 {object} response.Response{data=map[string]interface{}} "领取金额（amount，分）"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /redpacket/claim [post]
+func (c *ChatEngine) GinHandleClaimRedPacket(ctx *gin.Context) {
+	var req ClaimRedPacketReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	amount, packet, err := c.RedPacketService.ClaimRedPacket(ctx.Request.Context(), uid.(uint64), req.RedPacketID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{
+		"amount":       amount,
+		"remain_count": packet.RemainCount,
+	}))
+}
+
+// RedPacketIDQuery 红包详情参数
+type RedPacketIDQuery struct {
+	RedPacketID uint64 `form:"red_packet_id" binding:"required"`
+}
+
+// GinHandleGetRedPacket 红包详情
+// @Summary 红包详情
+// @Description 红包状态与领取记录，my_amount 为当前用户领取金额
+// @Tags 红包
+// @Accept json
+// @Produce json
+// @Param red_packet_id query uint64 true "红包ID"
+// @Success 200 {object} response.Response{data=service.RedPacketDTO} "红包详情"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /redpacket/detail [get]
+func (c *ChatEngine) GinHandleGetRedPacket(ctx *gin.Context) {
+	var req RedPacketIDQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	id := req.RedPacketID
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	dto, err := c.RedPacketService.GetRedPacket(uid.(uint64), id)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(dto))
+}
@@ -3,6 +3,7 @@ package chat_sdk
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	model "github.com/cydxin/chat-sdk/models"
 	"github.com/cydxin/chat-sdk/service"
@@ -15,6 +16,7 @@ var _ = model.Room{}
 var _ = service.RoomDTO{}
 var _ = service.RoomMemberListItemDTO{}
 var _ = service.GroupInfoDTO{}
+var _ = service.AddMembersResult{}
 
 // -------------------- 房间（Room）相关接口 --------------------
 
@@ -32,31 +34,44 @@ type CreateGroupRoomReq struct {
 // @Param req body CreateGroupRoomReq true "创建参数"
 // @Success 200 {object} response.Response{data=model.Room} "房间信息"
 // @Failure 400 {object} response.Response "请求错误"
+// @Failure 409 {object} response.Response "群成员已达上限"
+// @Failure 429 {object} response.Response "操作过于频繁"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Security BearerAuth
 // @Router /room/group [post]
 func (c *ChatEngine) GinHandleCreateGroupRoom(ctx *gin.Context) {
 	var req CreateGroupRoomReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	_, err := c.RoomService.CreateGroupRoom(req.Name, uid.(uint64), req.Members)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(nil))
 }
 
+// RoomIDQuery 仅需 room_id 的查询参数
+type RoomIDQuery struct {
+	RoomID uint64 `form:"room_id" binding:"required"`
+}
+
+type RoomAuditQuery struct {
+	RoomID uint64 `form:"room_id" binding:"required"`
+	Action string `form:"action"` // 如 room.user.mute，为空表示全部
+	Cursor uint64 `form:"cursor"` // 上一页的 next_cursor
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
 // GinHandleCreatePrivateRoom 创建私聊房间
 // @Summary 创建私聊
 // @Description 创建或获取两人私聊房间
@@ -70,22 +85,20 @@ func (c *ChatEngine) GinHandleCreateGroupRoom(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /room/private [post]
 func (c *ChatEngine) GinHandleCreatePrivateRoom(ctx *gin.Context) {
-	targetIDStr := ctx.Query("target_id")
-	targetID, err := strconv.ParseUint(targetIDStr, 10, 64)
-	if err != nil || targetID == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid target_id"))
+	var req TargetIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	room, err := c.RoomService.CreatePrivateRoom(uid.(uint64), targetID)
+	room, err := c.RoomService.CreatePrivateRoom(uid.(uint64), req.TargetID)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -106,13 +119,13 @@ func (c *ChatEngine) GinHandleCreatePrivateRoom(ctx *gin.Context) {
 func (c *ChatEngine) GinHandleGetUserRooms(ctx *gin.Context) {
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	rooms, err := c.RoomService.GetUserRooms(uint(uid.(uint64)))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -133,19 +146,75 @@ func (c *ChatEngine) GinHandleGetUserRooms(ctx *gin.Context) {
 func (c *ChatEngine) GinHandleGetGroupRooms(ctx *gin.Context) {
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	rooms, err := c.RoomService.GetGroupList(uint(uid.(uint64)))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(rooms))
 }
 
+// SaveGroupReq 保存群聊到通讯录
+type SaveGroupReq struct {
+	RoomID uint64 `json:"room_id" binding:"required" example:"1"`
+	Saved  bool   `json:"saved" example:"true"`
+}
+
+// GinHandleSaveGroup 保存/取消保存群聊到通讯录
+// @Summary 保存群聊到通讯录
+// @Description saved=true 保存、false 取消；只影响自己，需是群成员
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param req body SaveGroupReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response "不是群成员"
+// @Security BearerAuth
+// @Router /room/group/save [post]
+func (c *ChatEngine) GinHandleSaveGroup(ctx *gin.Context) {
+	var req SaveGroupReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	if err := c.RoomService.SetGroupSaved(uid.(uint64), req.RoomID, req.Saved); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleGetSavedGroups 通讯录中的群聊
+// @Summary 通讯录中的群聊
+// @Description 只返回主动保存到通讯录的群聊（退群后自动移除）
+// @Tags 房间
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.RoomDTO} "群聊列表"
+// @Security BearerAuth
+// @Router /room/group/saved [get]
+func (c *ChatEngine) GinHandleGetSavedGroups(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	rooms, err := c.RoomService.GetSavedGroupList(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(rooms))
+}
+
 type RoomMemberReq struct {
 	RoomID  uint64   `json:"room_id" binding:"required" example:"1"`
 	UserID  uint64   `json:"user_id" example:"1001"`  // remove 用
@@ -154,38 +223,41 @@ type RoomMemberReq struct {
 
 // GinHandleAddRoomMember 添加房间成员
 // @Summary 添加房间成员
-// @Description 将用户添加到房间
+// @Description 批量将用户添加到群聊，逐个返回结果（ok/already_member/blocked/limit_reached/user_not_found），有人入群时群里出现一条邀请系统消息
 // @Tags 房间
 // @Accept json
 // @Produce json
 // @Param req body RoomMemberReq true "成员信息"
-// @Success 200 {object} response.Response "成功响应"
+// @Success 200 {object} response.Response{data=service.AddMembersResult} "逐个用户的结果"
 // @Failure 400 {object} response.Response "参数错误"
+// @Failure 403 {object} response.Response "权限不足"
+// @Failure 409 {object} response.Response "群成员已达上限"
+// @Failure 429 {object} response.Response "操作过于频繁"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Security BearerAuth
 // @Router /room/member/add [post]
 func (c *ChatEngine) GinHandleAddRoomMember(ctx *gin.Context) {
 	var req RoomMemberReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
-	err := c.MemberService.AddRoomMember(req.RoomID, req.UserIDS, uid.(uint64))
+	res, err := c.MemberService.AddRoomMember(req.RoomID, req.UserIDS, uid.(uint64))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
+	if res.SystemMessage != nil {
+		pushStoredMessage(res.SystemMessage)
+	}
 
-	ctx.JSON(http.StatusOK, response.Success(map[string]interface{}{
-		"message": "成员已添加",
-	}))
+	ctx.JSON(http.StatusOK, response.Success(res))
 }
 
 // GinHandleRemoveRoomMember 移除房间成员
@@ -202,21 +274,20 @@ func (c *ChatEngine) GinHandleAddRoomMember(ctx *gin.Context) {
 // @Router /room/member/remove [post]
 func (c *ChatEngine) GinHandleRemoveRoomMember(ctx *gin.Context) {
 	var req RoomMemberReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	err := c.MemberService.RemoveRoomMember(req.RoomID, req.UserID, uid.(uint64))
 
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -225,6 +296,12 @@ func (c *ChatEngine) GinHandleRemoveRoomMember(ctx *gin.Context) {
 	}))
 }
 
+// CheckRoomMemberReq 检查成员参数，user_id 为空则查自己
+type CheckRoomMemberReq struct {
+	RoomID uint64 `form:"room_id" binding:"required"`
+	UserID uint64 `form:"user_id"`
+}
+
 // GinHandleCheckRoomMember 检查用户是否是房间成员
 // @Summary 检查房间成员
 // @Description 检查用户是否是房间成员，如果不传 user_id 则检查当前用户
@@ -239,27 +316,17 @@ func (c *ChatEngine) GinHandleRemoveRoomMember(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /room/member/check [get]
 func (c *ChatEngine) GinHandleCheckRoomMember(ctx *gin.Context) {
-	roomIDStr := ctx.Query("room_id")
-	userIDStr := ctx.Query("user_id")
-
-	rid, err := strconv.ParseUint(roomIDStr, 10, 64)
-	if err != nil || rid == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid room_id"))
+	var req CheckRoomMemberReq
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	rid := req.RoomID
 
-	var targetUserID uint64
-	if userIDStr != "" {
-		id, err := strconv.ParseUint(userIDStr, 10, 64)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid user_id"))
-			return
-		}
-		targetUserID = id
-	} else {
+	targetUserID := req.UserID
+	if targetUserID == 0 {
 		uid, exists := ctx.Get("user_id")
 		if !exists {
-			ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+			writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 			return
 		}
 		targetUserID = uid.(uint64)
@@ -267,7 +334,7 @@ func (c *ChatEngine) GinHandleCheckRoomMember(ctx *gin.Context) {
 
 	ok, err := c.RoomService.CheckRoomMember(uint(rid), uint(targetUserID))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -287,22 +354,61 @@ func (c *ChatEngine) GinHandleCheckRoomMember(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /room/member/list [get]
 func (c *ChatEngine) GinHandleGetRoomMemberList(ctx *gin.Context) {
-	roomIDStr := ctx.Query("room_id")
-	rid, err := strconv.ParseUint(roomIDStr, 10, 64)
-	if err != nil || rid == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid room_id"))
+	var req RoomIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	rid := req.RoomID
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	list, err := c.RoomService.GetRoomMemberList(rid, uid.(uint64))
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// RoomMemberSearchReq 群成员搜索参数
+type RoomMemberSearchReq struct {
+	RoomID  uint64 `form:"room_id" binding:"required"`
+	Keyword string `form:"keyword"`
+	Limit   int    `form:"limit,default=20" binding:"min=1,max=50"`
+}
+
+// GinHandleSearchRoomMembers 群成员搜索（@ 提及补全）
+// @Summary 群成员搜索
+// @Description 按群昵称 / 好友备注 / 昵称 / 用户名模糊匹配群成员，返回已按 备注 > 群昵称 > 昵称 > 用户名 解析好的 display_name；不含自己，keyword 为空时按群主、管理员、入群先后返回
+// @Tags 房间
+// @Produce json
+// @Param room_id query uint64 true "房间ID"
+// @Param keyword query string false "关键字"
+// @Param limit query int false "数量，默认 20，最大 50"
+// @Success 200 {object} response.Response{data=[]service.RoomMemberListItemDTO} "成员列表"
+// @Failure 403 {object} response.Response "不是群成员"
+// @Security BearerAuth
+// @Router /room/member/search [get]
+func (c *ChatEngine) GinHandleSearchRoomMembers(ctx *gin.Context) {
+	var req RoomMemberSearchReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	list, err := c.RoomService.SearchRoomMembers(req.RoomID, uid.(uint64), req.Keyword, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -330,19 +436,18 @@ type SetMyGroupNicknameReq struct {
 // @Router /room/member/nickname [post]
 func (c *ChatEngine) GinHandleSetMyGroupNickname(ctx *gin.Context) {
 	var req SetMyGroupNicknameReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	if err := c.RoomService.SetMyGroupNickname(uid.(uint64), req.RoomID, req.Nickname); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -368,10 +473,16 @@ type SetGroupMuteReq struct {
 	DurationMinutes int    `json:"duration_minutes"` // 0 to cancel
 }
 
+type SetDisappearingReq struct {
+	RoomID     uint64 `json:"room_id" binding:"required"`
+	TTLSeconds int64  `json:"ttl_seconds"` // 0 to disable
+}
+
 type SetGroupMuteScheduledReq struct {
 	RoomID          uint64 `json:"room_id" binding:"required"`
 	StartTime       string `json:"start_time" binding:"required"` // HH:MM
 	DurationMinutes int    `json:"duration_minutes" binding:"required"`
+	Timezone        string `json:"timezone"` // IANA 时区名（如 Asia/Shanghai），空表示服务器本地时区
 }
 
 type SetUserMuteReq struct {
@@ -380,6 +491,17 @@ type SetUserMuteReq struct {
 	DurationMinutes int    `json:"duration_minutes"` // 0 to cancel
 }
 
+type SetGroupMuteAdminsReq struct {
+	RoomID     uint64 `json:"room_id" binding:"required"`
+	MuteAdmins bool   `json:"mute_admins"` // true：全员禁言对管理员也生效
+}
+
+type SetMuteExemptReq struct {
+	RoomID  uint64   `json:"room_id" binding:"required"`
+	UserIDs []uint64 `json:"user_ids" binding:"required,min=1,max=100"`
+	Exempt  bool     `json:"exempt"`
+}
+
 // GinHandleUpdateGroupInfo 更新群信息
 // @Summary 更新群信息
 // @Tags Room
@@ -391,22 +513,58 @@ type SetUserMuteReq struct {
 // @Router /room/group/update [post]
 func (c *ChatEngine) GinHandleUpdateGroupInfo(ctx *gin.Context) {
 	var req UpdateGroupInfoReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 	if err := c.RoomService.UpdateGroupInfo(uid.(uint64), req.RoomID, req.Name, req.Avatar); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(nil))
 }
 
+// GinHandleUploadGroupAvatar 上传群头像
+// @Summary 上传群头像
+// @Description 群主/管理员上传群头像，处理方式同 /user/avatar/upload；设置后不再随成员变动自动合成，并推送 room.group.info_updated
+// @Tags Room
+// @Accept multipart/form-data
+// @Produce json
+// @Param room_id formData int true "群 ID"
+// @Param file formData file true "图片文件"
+// @Param crop_x formData int false "裁剪区域左上角 x"
+// @Param crop_y formData int false "裁剪区域左上角 y"
+// @Param crop_size formData int false "裁剪区域边长，0 为居中最大正方形"
+// @Success 200 {object} response.Response{data=service.AvatarUploadDTO}
+// @Security BearerAuth
+// @Router /room/group/avatar/upload [post]
+func (c *ChatEngine) GinHandleUploadGroupAvatar(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	roomID, err := strconv.ParseUint(ctx.PostForm("room_id"), 10, 64)
+	if err != nil || roomID == 0 {
+		writeError(ctx, response.CodeParamError, response.Translate(requestLang(ctx), "valid.required", "room_id"))
+		return
+	}
+	data, crop, ok := readAvatarUpload(ctx)
+	if !ok {
+		return
+	}
+	dto, err := c.RoomService.UploadGroupAvatar(uid.(uint64), roomID, data, crop)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(dto))
+}
+
 // GinHandleSetGroupAdmin 设置管理员
 // @Summary 设置管理员
 // @Tags Room
@@ -418,17 +576,16 @@ func (c *ChatEngine) GinHandleUpdateGroupInfo(ctx *gin.Context) {
 // @Router /room/admin/set [post]
 func (c *ChatEngine) GinHandleSetGroupAdmin(ctx *gin.Context) {
 	var req SetGroupAdminReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 	if err := c.RoomService.SetGroupAdmin(uid.(uint64), req.RoomID, req.TargetUserID, req.IsAdmin); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(nil))
@@ -445,17 +602,43 @@ func (c *ChatEngine) GinHandleSetGroupAdmin(ctx *gin.Context) {
 // @Router /room/mute/group [post]
 func (c *ChatEngine) GinHandleSetGroupMute(ctx *gin.Context) {
 	var req SetGroupMuteReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 	if err := c.RoomService.SetGroupMuteCountdown(uid.(uint64), req.RoomID, req.DurationMinutes); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleSetRoomDisappearing 设置消息定时删除
+// @Summary 设置消息定时删除
+// @Description ttl_seconds 取值 86400（24小时）/ 604800（7天）/ 7776000（90天），0 关闭；群聊需管理员，私聊任一成员
+// @Tags Room
+// @Accept json
+// @Produce json
+// @Param req body SetDisappearingReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /room/disappearing [post]
+func (c *ChatEngine) GinHandleSetRoomDisappearing(ctx *gin.Context) {
+	var req SetDisappearingReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	if err := c.RoomService.SetDisappearingTTL(uid.(uint64), req.RoomID, time.Duration(req.TTLSeconds)*time.Second); err != nil {
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(nil))
@@ -463,6 +646,7 @@ func (c *ChatEngine) GinHandleSetGroupMute(ctx *gin.Context) {
 
 // GinHandleSetGroupMuteScheduled 设置群禁言（定时）
 // @Summary 设置群禁言（定时）
+// @Description 每日从 start_time 起禁言 duration_minutes 分钟，按 timezone 计算（可跨零点）；窗口开始/结束时推送 room.group.mute.window
 // @Tags Room
 // @Accept json
 // @Produce json
@@ -472,17 +656,16 @@ func (c *ChatEngine) GinHandleSetGroupMute(ctx *gin.Context) {
 // @Router /room/mute/group/scheduled [post]
 func (c *ChatEngine) GinHandleSetGroupMuteScheduled(ctx *gin.Context) {
 	var req SetGroupMuteScheduledReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
-	if err := c.RoomService.SetGroupMuteScheduled(uid.(uint64), req.RoomID, req.StartTime, req.DurationMinutes); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+	if err := c.RoomService.SetGroupMuteScheduled(uid.(uint64), req.RoomID, req.StartTime, req.DurationMinutes, req.Timezone); err != nil {
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(nil))
@@ -499,17 +682,126 @@ func (c *ChatEngine) GinHandleSetGroupMuteScheduled(ctx *gin.Context) {
 // @Router /room/mute/user [post]
 func (c *ChatEngine) GinHandleSetUserMute(ctx *gin.Context) {
 	var req SetUserMuteReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 	if err := c.RoomService.SetUserMute(uid.(uint64), req.RoomID, req.TargetUserID, req.DurationMinutes); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleGetMuteSettings 获取群禁言设置
+// @Summary 获取群禁言设置
+// @Description 全员禁言/每日定时禁言设置、是否对管理员生效、豁免成员，以及当前用户此刻的禁言状态（my_mute，可发言时为空）
+// @Tags Room
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "群ID"
+// @Success 200 {object} response.Response{data=service.MuteSettingsDTO} "禁言设置"
+// @Security BearerAuth
+// @Router /room/mute/settings [get]
+func (c *ChatEngine) GinHandleGetMuteSettings(ctx *gin.Context) {
+	var req RoomIDQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	settings, err := c.RoomService.GetMuteSettings(uid.(uint64), req.RoomID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(settings))
+}
+
+// GinHandleListMutedMembers 获取当前被禁言的成员
+// @Summary 获取当前被禁言的成员
+// @Description 仍在个人禁言期内的成员及解除时间，按解除时间升序。到期后由后台任务自动解除并推送 room.user.unmute
+// @Tags Room
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "群ID"
+// @Success 200 {object} response.Response{data=[]service.MutedMemberDTO} "禁言成员"
+// @Security BearerAuth
+// @Router /room/mute/list [get]
+func (c *ChatEngine) GinHandleListMutedMembers(ctx *gin.Context) {
+	var req RoomIDQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	list, err := c.RoomService.ListMutedMembers(uid.(uint64), req.RoomID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// GinHandleSetGroupMuteAdmins 设置全员禁言是否对管理员生效
+// @Summary 设置全员禁言是否对管理员生效
+// @Description 默认全员禁言（倒计时/每日定时）期间管理员仍可发言，mute_admins=true 后管理员也被禁言；群主始终可以发言。仅群主可设置
+// @Tags Room
+// @Accept json
+// @Produce json
+// @Param req body SetGroupMuteAdminsReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /room/mute/admins [post]
+func (c *ChatEngine) GinHandleSetGroupMuteAdmins(ctx *gin.Context) {
+	var req SetGroupMuteAdminsReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	if err := c.RoomService.SetGroupMuteAdmins(uid.(uint64), req.RoomID, req.MuteAdmins); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleSetMuteExempt 设置全员禁言豁免成员
+// @Summary 设置全员禁言豁免成员
+// @Description 豁免的成员在全员禁言与每日定时禁言期间仍可发言，个人禁言仍然生效。需管理员
+// @Tags Room
+// @Accept json
+// @Produce json
+// @Param req body SetMuteExemptReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /room/mute/exempt [post]
+func (c *ChatEngine) GinHandleSetMuteExempt(ctx *gin.Context) {
+	var req SetMuteExemptReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	if err := c.RoomService.SetMuteExempt(uid.(uint64), req.RoomID, req.UserIDs, req.Exempt); err != nil {
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(nil))
@@ -528,21 +820,47 @@ func (c *ChatEngine) GinHandleSetUserMute(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /room/group/info [get]
 func (c *ChatEngine) GinHandleGetGroupInfo(ctx *gin.Context) {
-	ridStr := ctx.Query("room_id")
-	rid, err := strconv.ParseUint(ridStr, 10, 64)
-	if err != nil || rid == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid room_id"))
+	var req RoomIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	rid := req.RoomID
 
 	info, err := c.RoomService.GetGroupInfo(rid)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(info))
 }
 
+// RoomOnlineQuery 房间在线成员参数
+type RoomOnlineQuery struct {
+	RoomID uint64 `form:"room_id" binding:"required"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+// GinHandleRoomOnline 房间在线成员
+// @Summary 房间在线成员
+// @Description 房间当前在线人数与在线成员（在线在前、离开在后，默认最多 200 个），隐身用户不计入；只统计本实例的 WS 连接。
+// @Description 开启 WithRoomOnlineFeed 后人数变化会推送 room_online，无需轮询
+// @Tags 房间
+// @Produce json
+// @Param room_id query uint64 true "房间ID"
+// @Param limit query int false "成员列表数量(默认200,最大200)"
+// @Success 200 {object} response.Response{data=RoomOnline} "在线成员"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 403 {object} response.Response "不是房间成员"
+// @Security BearerAuth
+// @Router /room/online [get]
+func (c *ChatEngine) GinHandleRoomOnline(ctx *gin.Context) {
+	var req RoomOnlineQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(c.WsServer.RoomOnline(req.RoomID, req.Limit)))
+}
+
 // GinHandleQuitGroup  退出群聊
 // @Summary 退出指定群聊
 // @Description
@@ -556,18 +874,50 @@ func (c *ChatEngine) GinHandleGetGroupInfo(ctx *gin.Context) {
 // @Security BearerAuth
 // @Router /room/group/quit [get]
 func (c *ChatEngine) GinHandleQuitGroup(ctx *gin.Context) {
-	ridStr := ctx.Query("room_id")
-	rid, err := strconv.ParseUint(ridStr, 10, 64)
-	if err != nil || rid == 0 {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid room_id"))
+	var req RoomIDQuery
+	if !bindQuery(ctx, &req) {
 		return
 	}
+	rid := req.RoomID
 	uidStr, _ := ctx.Get("user_id")
 	uid := uidStr.(uint64)
-	err = c.RoomService.QuitGroup(rid, uid)
+	err := c.RoomService.QuitGroup(rid, uid)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, response.Success(nil))
 }
+
+// GinHandleListRoomAudit 群设置变更记录
+// @Summary 群设置变更记录
+// @Description 群信息、管理员、禁言、踢人、入群验证、公开设置等变更的操作人与前后值，新的在前，游标翻页。仅群主/管理员
+// @Tags Room
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "群ID"
+// @Param action query string false "动作（与房间事件类型一致，如 room.user.mute）"
+// @Param cursor query uint64 false "上一页的 next_cursor"
+// @Param limit query int false "条数（默认 50，最大 200）"
+// @Success 200 {object} response.Response{data=service.RoomAuditPage} "变更记录"
+// @Security BearerAuth
+// @Router /room/audit [get]
+func (c *ChatEngine) GinHandleListRoomAudit(ctx *gin.Context) {
+	var req RoomAuditQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	page, err := c.RoomService.ListRoomAudit(uid.(uint64), service.RoomAuditQuery{
+		RoomID: req.RoomID, Action: req.Action, Cursor: req.Cursor, Limit: req.Limit,
+	})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(page))
+}
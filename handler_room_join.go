@@ -0,0 +1,250 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+)
+
+type RoomAccountQuery struct {
+	RoomAccount string `form:"room_account" binding:"required"`
+}
+
+// JoinByAccountReq 按群号加群
+type JoinByAccountReq struct {
+	RoomAccount string `json:"room_account" binding:"required"`
+	Answer      string `json:"answer"` // join_mode=2 时必填
+	Reason      string `json:"reason"` // join_mode=0 时作为申请理由
+}
+
+// SetJoinVerificationReq 设置加群验证
+type SetJoinVerificationReq struct {
+	RoomID   uint64 `json:"room_id" binding:"required"`
+	JoinMode uint8  `json:"join_mode"` // 0-需审批 1-直接加入 2-回答问题 3-不允许
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// HandleJoinRequestReq 处理加群申请
+type HandleJoinRequestReq struct {
+	RequestID uint64 `json:"request_id" binding:"required"`
+	Approve   bool   `json:"approve"`
+}
+
+// GinHandleLookupGroupByAccount 按群号查询群
+// @Summary 按群号查询群
+// @Description 返回群资料与加群方式（join_mode=2 时带 join_question），不返回答案
+// @Tags 房间
+// @Produce json
+// @Param room_account query string true "群号"
+// @Success 200 {object} response.Response{data=service.GroupJoinInfoDTO}
+// @Security BearerAuth
+// @Router /room/join/lookup [get]
+func (c *ChatEngine) GinHandleLookupGroupByAccount(ctx *gin.Context) {
+	var req RoomAccountQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	info, err := c.MemberService.LookupGroupByAccount(uid.(uint64), req.RoomAccount)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(info))
+}
+
+// GinHandleJoinByAccount 按群号加群
+// @Summary 按群号加群
+// @Description 按群设置直接加入 / 校验问题答案 / 提交申请等待审批；答错会通知群管理员
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param req body JoinByAccountReq true "请求参数"
+// @Success 200 {object} response.Response{data=service.JoinGroupResult}
+// @Security BearerAuth
+// @Router /room/join-by-account [post]
+func (c *ChatEngine) GinHandleJoinByAccount(ctx *gin.Context) {
+	var req JoinByAccountReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	res, err := c.MemberService.JoinGroupByAccount(uid.(uint64), req.RoomAccount, req.Answer, req.Reason)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	if res.SystemMessage != nil {
+		pushStoredMessage(res.SystemMessage)
+	}
+	ctx.JSON(http.StatusOK, response.Success(res))
+}
+
+// GinHandleSetJoinVerification 设置加群验证
+// @Summary 设置加群验证
+// @Description 群主/管理员设置按群号加群的方式；join_mode=2 时 question/answer 必填
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param req body SetJoinVerificationReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /room/join/verification [post]
+func (c *ChatEngine) GinHandleSetJoinVerification(ctx *gin.Context) {
+	var req SetJoinVerificationReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	if err := c.MemberService.SetJoinVerification(uid.(uint64), req.RoomID, req.JoinMode, req.Question, req.Answer); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleListJoinRequests 待处理的加群申请
+// @Summary 待处理的加群申请
+// @Tags 房间
+// @Produce json
+// @Param room_id query uint64 true "群ID(房间ID)"
+// @Success 200 {object} response.Response{data=[]service.JoinRequestDTO}
+// @Security BearerAuth
+// @Router /room/join/requests [get]
+func (c *ChatEngine) GinHandleListJoinRequests(ctx *gin.Context) {
+	var req RoomIDQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	list, err := c.MemberService.ListJoinRequests(uid.(uint64), req.RoomID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+// GinHandleHandleJoinRequest 同意/拒绝加群申请
+// @Summary 同意/拒绝加群申请
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param req body HandleJoinRequestReq true "请求参数"
+// @Success 200 {object} response.Response{data=service.JoinGroupResult}
+// @Security BearerAuth
+// @Router /room/join/handle [post]
+func (c *ChatEngine) GinHandleHandleJoinRequest(ctx *gin.Context) {
+	var req HandleJoinRequestReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	res, err := c.MemberService.HandleJoinRequest(uid.(uint64), req.RequestID, req.Approve)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	if res.SystemMessage != nil {
+		pushStoredMessage(res.SystemMessage)
+	}
+	ctx.JSON(http.StatusOK, response.Success(res))
+}
+
+// SetRoomDiscoveryReq 设置群公开/分类/标签
+type SetRoomDiscoveryReq struct {
+	RoomID   uint64   `json:"room_id" binding:"required"`
+	IsPublic bool     `json:"is_public"`
+	Category string   `json:"category" binding:"max=50"`
+	Tags     []string `json:"tags" binding:"max=10"`
+}
+
+// DiscoverGroupsReq 公开群搜索参数
+type DiscoverGroupsReq struct {
+	Keyword  string `form:"keyword" binding:"max=64"`
+	Category string `form:"category" binding:"max=50"`
+	Tag      string `form:"tag" binding:"max=20"`
+	PageQuery
+}
+
+// GinHandleSetRoomDiscovery 设置群公开/分类/标签
+// @Summary 设置群公开/分类/标签
+// @Description 群主/管理员设置是否出现在公开群目录，以及分类和标签（整体覆盖，最多 10 个）
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param req body SetRoomDiscoveryReq true "请求参数"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /room/discovery [post]
+func (c *ChatEngine) GinHandleSetRoomDiscovery(ctx *gin.Context) {
+	var req SetRoomDiscoveryReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	if err := c.RoomService.SetRoomDiscovery(uid.(uint64), req.RoomID, req.IsPublic, req.Category, req.Tags); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+// GinHandleDiscoverGroups 搜索公开群
+// @Summary 搜索公开群
+// @Description 只返回设置为公开的群；keyword 匹配群名或精确群号，可按分类、标签筛选
+// @Tags 房间
+// @Produce json
+// @Param keyword query string false "群名关键字或群号"
+// @Param category query string false "分类"
+// @Param tag query string false "标签"
+// @Param limit query int false "返回条数(默认20,最大100)"
+// @Param offset query int false "偏移量"
+// @Success 200 {object} response.Response{data=[]service.DiscoverGroupDTO}
+// @Security BearerAuth
+// @Router /room/discover [get]
+func (c *ChatEngine) GinHandleDiscoverGroups(ctx *gin.Context) {
+	var req DiscoverGroupsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	list, err := c.RoomService.DiscoverGroups(service.DiscoverQuery{
+		Keyword:  req.Keyword,
+		Category: req.Category,
+		Tag:      req.Tag,
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
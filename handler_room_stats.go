@@ -0,0 +1,96 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.RoomStatsDTO{}
+
+// -------------------- 群统计（RoomStats）相关接口 --------------------
+
+// RoomStatsReq 群统计参数
+type RoomStatsReq struct {
+	RoomID uint64 `form:"room_id" binding:"required"`
+	Days   int    `form:"days,default=7" binding:"min=1,max=90"`
+	Top    int    `form:"top,default=10" binding:"min=1,max=100"`
+}
+
+// RoomTopSendersReq 群发言排行参数
+type RoomTopSendersReq struct {
+	RoomID uint64 `form:"room_id" binding:"required"`
+	Days   int    `form:"days,default=7" binding:"min=1,max=90"`
+	Limit  int    `form:"limit,default=10" binding:"min=1,max=100"`
+}
+
+// GinHandleRoomStats 群统计概览（仅群主）
+// @Summary 群统计概览
+// @Description 最近 days 天的每日消息数/活跃人数、24 小时分布与高峰时段、发言排行（仅群主可查看）
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "房间ID"
+// @Param days query int false "天数，默认 7，最大 90"
+// @Param top query int false "发言排行数量，默认 10，最大 100"
+// @Success 200 {object} response.Response{data=service.RoomStatsDTO} "统计"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /room/stats [get]
+func (c *ChatEngine) GinHandleRoomStats(ctx *gin.Context) {
+	var req RoomStatsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	stats, err := c.RoomStatsService.GetStats(req.RoomID, uid.(uint64), req.Days, req.Top)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(stats))
+}
+
+// GinHandleRoomTopSenders 群发言排行（仅群主）
+// @Summary 群发言排行
+// @Description 最近 days 天发言数排行（仅群主可查看）
+// @Tags 房间
+// @Accept json
+// @Produce json
+// @Param room_id query uint64 true "房间ID"
+// @Param days query int false "天数，默认 7，最大 90"
+// @Param limit query int false "数量，默认 10，最大 100"
+// @Success 200 {object} response.Response{data=[]service.RoomSenderStat} "排行"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /room/stats/senders [get]
+func (c *ChatEngine) GinHandleRoomTopSenders(ctx *gin.Context) {
+	var req RoomTopSendersReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	list, err := c.RoomStatsService.TopSenders(req.RoomID, uid.(uint64), req.Days, req.Limit)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
@@ -0,0 +1,89 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.IPRuleDTO{}
+
+// -------------------- IP 访问控制（Admin）相关接口 --------------------
+
+// GinHandleAdminListIPRules IP 规则列表
+// @Summary IP 规则列表
+// @Description 全部 IP 访问规则（含已禁用），hits 为本实例启动以来的命中次数
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.IPRuleDTO} "规则列表"
+// @Security AdminToken
+// @Router /admin/security/rules [get]
+func (c *ChatEngine) GinHandleAdminListIPRules(ctx *gin.Context) {
+	list, err := c.SecurityService.ListRules()
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(list))
+}
+
+type AdminAddIPRuleReq struct {
+	Kind  string `json:"kind" binding:"required" example:"deny"`        // allow/deny/country
+	Value string `json:"value" binding:"required" example:"10.0.0.0/8"` // CIDR/单个 IP，或 2 位国家代码
+	Note  string `json:"note"`
+}
+
+// GinHandleAdminAddIPRule 新增 IP 规则
+// @Summary 新增 IP 规则
+// @Description kind: allow（配置任意白名单后仅白名单 IP 可访问）/ deny（黑名单）/ country（按国家屏蔽，需 WithGeoIPResolver）
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param req body AdminAddIPRuleReq true "规则"
+// @Success 200 {object} response.Response{data=service.IPRuleDTO} "规则"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/security/rule/add [post]
+func (c *ChatEngine) GinHandleAdminAddIPRule(ctx *gin.Context) {
+	var req AdminAddIPRuleReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	rule, err := c.SecurityService.AddRule(req.Kind, req.Value, req.Note)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(rule))
+}
+
+type AdminDeleteIPRuleReq struct {
+	ID uint64 `json:"id" binding:"required"`
+}
+
+// GinHandleAdminDeleteIPRule 删除 IP 规则
+// @Summary 删除 IP 规则
+// @Description 删除后立即生效（其他实例最多 1 分钟内同步）
+// @Tags 运维
+// @Accept json
+// @Produce json
+// @Param req body AdminDeleteIPRuleReq true "规则ID"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Security AdminToken
+// @Router /admin/security/rule/delete [post]
+func (c *ChatEngine) GinHandleAdminDeleteIPRule(ctx *gin.Context) {
+	var req AdminDeleteIPRuleReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if err := c.SecurityService.DeleteRule(req.ID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
@@ -1,6 +1,8 @@
 package chat_sdk
 
 import (
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,9 +18,14 @@ var _ = model.User{}
 
 // -------------------- 用户（User）相关接口 --------------------
 
+// UserIDQuery 可选 user_id 的查询参数（不传则为当前用户）
+type UserIDQuery struct {
+	UserID uint64 `form:"user_id"`
+}
+
 // GinHandleGetUserInfo 获取用户信息 (Gin 版本)
 // @Summary 获取用户信息
-// @Description 根据 user_id 查询用户详情，如果不传 user_id 则查询当前登录用户
+// @Description 根据 user_id 查询用户详情，如果不传 user_id 则查询当前登录用户；查看非好友时不返回手机号/邮箱，签名/最近活跃/动态数按对方隐私设置隐藏
 // @Tags 用户
 // @Accept json
 // @Produce json
@@ -30,46 +37,44 @@ var _ = model.User{}
 // @Router /user/info [get]
 func (c *ChatEngine) GinHandleGetUserInfo(ctx *gin.Context) {
 	// 1. 尝试从 Query 获取目标 user_id (查别人)
-	userIDStr := ctx.Query("user_id")
-	var targetUserID uint64
-
-	if userIDStr != "" {
-		// 如果传了 user_id，解析它
-		id, err := strconv.ParseUint(userIDStr, 10, 64)
-		if err != nil || id == 0 {
-			ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "invalid user_id"))
-			return
-		}
-		targetUserID = id
-	} else {
-		// 2. 如果没传 user_id，从 Context 获取当前用户 ID (查自己)
-		// 注意：这需要配合 GinAuthMiddleware 使用
-		currentUserID, exists := ctx.Get("user_id")
-		if !exists {
-			ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found in context"))
-			return
-		}
+	var req UserIDQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	targetUserID := req.UserID
 
-		// 类型断言
-		switch v := currentUserID.(type) {
-		case uint64:
-			targetUserID = v
-		case float64: // 有些 JSON 解析可能会变成 float64
-			targetUserID = uint64(v)
-		case int:
-			targetUserID = uint64(v)
-		default:
-			// 尝试转字符串再转数字，或者直接报错
-			ctx.JSON(http.StatusInternalServerError, response.Error(response.CodeInternalError, "invalid user_id type"))
-			return
-		}
+	// 2. 从 Context 获取当前用户 ID（查看者身份，决定对方资料的可见字段）
+	// 注意：这需要配合 GinAuthMiddleware 使用
+	currentUserID, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found in context")
+		return
+	}
+
+	// 类型断言
+	var viewerID uint64
+	switch v := currentUserID.(type) {
+	case uint64:
+		viewerID = v
+	case float64: // 有些 JSON 解析可能会变成 float64
+		viewerID = uint64(v)
+	case int:
+		viewerID = uint64(v)
+	default:
+		// 尝试转字符串再转数字，或者直接报错
+		writeError(ctx, response.CodeInternalError, "invalid user_id type")
+		return
+	}
+	if targetUserID == 0 {
+		// 没传 user_id 查自己
+		targetUserID = viewerID
 	}
 
 	// 3. 调用 Service 查询
-	u, err := c.UserService.GetUser(targetUserID)
+	u, err := c.UserService.GetUser(viewerID, targetUserID)
 	if err != nil {
 		// 区分一下错误类型可能更好，这里简单处理
-		ctx.JSON(http.StatusOK, response.Error(response.CodeUserNotFound, err.Error()))
+		writeError(ctx, response.CodeUserNotFound, err.Error())
 		return
 	}
 
@@ -79,40 +84,30 @@ func (c *ChatEngine) GinHandleGetUserInfo(ctx *gin.Context) {
 
 // GinHandleUserRegister 用户注册
 // @Summary 用户注册
-// @Description 创建新用户账号：username + (phone/email 二选一) + password + code + nickname
+// @Description 创建新用户账号：username + (phone/email 二选一) + password + code + nickname；开启人机验证时需带 captcha_token（否则返回 10011）
 // @Tags 用户
 // @Accept json
 // @Produce json
 // @Param req body service.RegisterReq true "注册信息"
 // @Success 200 {object} response.Response "注册成功"
 // @Failure 400 {object} response.Response "请求错误"
+// @Failure 409 {object} response.Response "用户已存在"
 // @Router /user/register [post]
 func (c *ChatEngine) GinHandleUserRegister(ctx *gin.Context) {
 	var req service.RegisterReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	if c.config == nil || c.config.RDB == nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeRedisNotConfigured, "r 服务暂未开启"))
+	if c.config == nil || c.config.KVStore == nil {
+		writeServiceError(ctx, service.ErrRedisNotConfigured)
 		return
 	}
 
+	req.ClientIP = ctx.ClientIP()
 	err := c.UserService.Register(ctx.Request.Context(), req)
 	if err != nil {
-		code := response.CodeInternalError
-		switch {
-		case strings.Contains(err.Error(), "required"), strings.Contains(err.Error(), "cannot"):
-			code = response.CodeParamError
-		case strings.Contains(err.Error(), "verification code"):
-			code = response.CodeVerifyCodeInvalid
-		case strings.Contains(err.Error(), "存在"):
-			code = response.CodeUserAlreadyExists
-		case strings.Contains(err.Error(), "redis"):
-			code = response.CodeRedisNotConfigured
-		}
-		ctx.JSON(http.StatusOK, response.Error(code, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -121,7 +116,7 @@ func (c *ChatEngine) GinHandleUserRegister(ctx *gin.Context) {
 
 // GinHandleUserLogin 用户登录
 // @Summary 用户登录
-// @Description 用户登录并返回 token（account 支持 username/phone/email；password 或 code 二选一）
+// @Description 用户登录并返回 token（account 支持 username/phone/email；password 或 code 二选一）；开启人机验证且连续失败后返回 10011，需带 captcha_token 重试
 // @Tags 用户
 // @Accept json
 // @Produce json
@@ -131,20 +126,17 @@ func (c *ChatEngine) GinHandleUserRegister(ctx *gin.Context) {
 // @Router /user/login [post]
 func (c *ChatEngine) GinHandleUserLogin(ctx *gin.Context) {
 	var req service.LoginReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
+	req.ClientIP = ctx.ClientIP()
+	if req.Device == "" {
+		req.Device = ctx.GetHeader("User-Agent")
+	}
 	resp, err := c.UserService.LoginWithToken(ctx.Request.Context(), req)
 	if err != nil {
-		code := response.CodePasswordError
-		if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "cannot") {
-			code = response.CodeParamError
-		} else if strings.Contains(err.Error(), "verification code") {
-			code = response.CodeVerifyCodeInvalid
-		}
-		ctx.JSON(http.StatusOK, response.Error(code, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -156,34 +148,43 @@ func (c *ChatEngine) GinHandleUserLogin(ctx *gin.Context) {
 type SendVerifyCodeReq struct {
 	Purpose    string `json:"purpose" binding:"required" example:"register"`       // register/forgot_password
 	Identifier string `json:"identifier" binding:"required" example:"13800138000"` // 手机号或邮箱
+	// CaptchaToken 人机验证凭证（开启 chat_sdk.WithCaptcha 时必填）
+	CaptchaToken string `json:"captcha_token"`
 }
 
-// GinHandleSendVerifyCode 发送验证码（写入 Redis；实际短信/邮件发送由调用方对接）
+// GinHandleSendVerifyCode 发送验证码（写入 KVStore；配置 WithCodeSender 时投递短信/邮件，否则由调用方对接）
 // @Summary 发送验证码
-// @Description 发送验证码到手机号/邮箱（identifier=手机号/邮箱），purpose=register/forgot_password
+// @Description 发送验证码到手机号/邮箱（identifier=手机号/邮箱），purpose=register/forgot_password；开启人机验证时需带 captcha_token
 // @Tags 用户
 // @Accept json
 // @Produce json
 // @Param req body SendVerifyCodeReq true "发送验证码请求"
 // @Success 200 {object} response.Response{data=service.SendCodeResult} "发送成功"
 // @Failure 400 {object} response.Response "请求错误"
+// @Failure 429 {object} response.Response{data=service.SendCodeQuotaError} "发送次数超限或被锁定"
 // @Router /user/code/send [post]
 func (c *ChatEngine) GinHandleSendVerifyCode(ctx *gin.Context) {
 	var req SendVerifyCodeReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
-	if c.config == nil || c.config.RDB == nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeRedisNotConfigured, "r 服务暂未开启"))
+	if c.config == nil || c.config.KVStore == nil {
+		writeServiceError(ctx, service.ErrRedisNotConfigured)
+		return
+	}
+
+	if err := c.UserService.VerifyCaptcha(ctx.Request.Context(), req.CaptchaToken, ctx.ClientIP()); err != nil {
+		writeServiceError(ctx, err)
 		return
 	}
 
 	purpose := service.VerifyCodePurpose(strings.TrimSpace(req.Purpose))
-	svc := service.NewVerifyCodeService(c.config.RDB)
-	ret, err := svc.SendCode(ctx.Request.Context(), purpose, req.Identifier)
+	svc := service.NewVerifyCodeServiceWithKV(c.config.KVStore)
+	svc.Sender = c.config.CodeSender
+	svc.Limits = c.config.SendCodeLimits
+	ret, err := svc.SendCodeFrom(ctx.Request.Context(), purpose, req.Identifier, ctx.ClientIP())
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 	// 非 Debug 环境不返回验证码
@@ -207,29 +208,17 @@ func (c *ChatEngine) GinHandleSendVerifyCode(ctx *gin.Context) {
 // @Router /user/password/forgot [post]
 func (c *ChatEngine) GinHandleForgotPassword(ctx *gin.Context) {
 	var req service.ForgotPasswordReq
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
-	if c.config == nil || c.config.RDB == nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeRedisNotConfigured, "r 服务暂未开启"))
+	if c.config == nil || c.config.KVStore == nil {
+		writeServiceError(ctx, service.ErrRedisNotConfigured)
 		return
 	}
 
 	err := c.UserService.ForgotPassword(ctx.Request.Context(), req)
 	if err != nil {
-		code := response.CodeInternalError
-		switch {
-		case strings.Contains(err.Error(), "required"):
-			code = response.CodeParamError
-		case strings.Contains(err.Error(), "verification code"):
-			code = response.CodeVerifyCodeInvalid
-		case strings.Contains(err.Error(), "not found"):
-			code = response.CodeUserNotFound
-		case strings.Contains(err.Error(), "redis"):
-			code = response.CodeRedisNotConfigured
-		}
-		ctx.JSON(http.StatusOK, response.Error(code, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -251,20 +240,19 @@ func (c *ChatEngine) GinHandleUpdateUserInfo(ctx *gin.Context) {
 
 	var req service.UpdateUserReq
 	// 对
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	u, err := c.UserService.UpdateUser(uid.(uint64), req)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -289,26 +277,96 @@ type UpdateUserAvatarReq struct {
 func (c *ChatEngine) GinHandleUpdateUserAvatar(ctx *gin.Context) {
 	var req UpdateUserAvatarReq
 
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "user_id not found"))
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
 		return
 	}
 
 	u, err := c.UserService.UpdateAvatar(uid.(uint64), req.Avatar)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(u))
 }
 
+// AvatarUploadForm 头像上传的裁剪参数（multipart 表单，文件字段为 file），crop_size=0 时居中裁成正方形
+type AvatarUploadForm struct {
+	CropX    int `form:"crop_x" binding:"min=0"`
+	CropY    int `form:"crop_y" binding:"min=0"`
+	CropSize int `form:"crop_size" binding:"min=0"`
+}
+
+// readAvatarUpload 读取 multipart 中的 file 与裁剪参数，失败时已输出错误并返回 false
+func readAvatarUpload(ctx *gin.Context) ([]byte, service.AvatarCrop, bool) {
+	var form AvatarUploadForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		writeError(ctx, response.CodeParamError, formatBindError(requestLang(ctx), &form, err))
+		return nil, service.AvatarCrop{}, false
+	}
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		writeError(ctx, response.CodeParamError, response.Translate(requestLang(ctx), "valid.required", "file"))
+		return nil, service.AvatarCrop{}, false
+	}
+	if fh.Size > service.AvatarUploadMaxBytes {
+		writeServiceError(ctx, service.ErrAvatarTooLarge)
+		return nil, service.AvatarCrop{}, false
+	}
+	f, err := fh.Open()
+	if err != nil {
+		writeError(ctx, response.CodeInternalError, err.Error())
+		return nil, service.AvatarCrop{}, false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, service.AvatarUploadMaxBytes+1))
+	if err != nil {
+		writeError(ctx, response.CodeInternalError, err.Error())
+		return nil, service.AvatarCrop{}, false
+	}
+	return data, service.AvatarCrop{X: form.CropX, Y: form.CropY, Size: form.CropSize}, true
+}
+
+// GinHandleUploadUserAvatar 上传头像
+// @Summary 上传用户头像
+// @Description 上传图片（JPEG/PNG/GIF，≤5MB），服务端按裁剪区域裁成正方形并生成 64/160/480 三个 JPEG 尺寸，资料头像更新为 160 尺寸，上一次上传的头像文件会被删除
+// @Tags 用户
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "图片文件"
+// @Param crop_x formData int false "裁剪区域左上角 x"
+// @Param crop_y formData int false "裁剪区域左上角 y"
+// @Param crop_size formData int false "裁剪区域边长，0 为居中最大正方形"
+// @Success 200 {object} response.Response{data=service.AvatarUploadDTO} "各尺寸头像 URL"
+// @Failure 400 {object} response.Response "请求错误"
+// @Security BearerAuth
+// @Router /user/avatar/upload [post]
+func (c *ChatEngine) GinHandleUploadUserAvatar(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	data, crop, ok := readAvatarUpload(ctx)
+	if !ok {
+		return
+	}
+
+	dto, err := c.UserService.UploadAvatar(uid.(uint64), data, crop)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Success(dto))
+}
+
 type UpdateUserPasswordReq struct {
 	OldPassword string `json:"old_password" binding:"required" example:"123456"`
 	NewPassword string `json:"new_password" binding:"required" example:"123456"`
@@ -328,29 +386,28 @@ type UpdateUserPasswordReq struct {
 func (c *ChatEngine) GinHandleUpdateUserPassword(ctx *gin.Context) {
 	var req UpdateUserPasswordReq
 
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, err.Error()))
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	uid, exists := ctx.Get("user_id")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, response.Error(response.CodeTokenInvalid, "用户未找到"))
+		writeError(ctx, response.CodeTokenInvalid, "用户未找到")
 		return
 	}
 
 	if strings.TrimSpace(req.NewPassword) == "" {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "新密码必填"))
+		writeError(ctx, response.CodeParamError, "新密码必填")
 		return
 	}
 
 	if strings.TrimSpace(req.OldPassword) == "" {
-		ctx.JSON(http.StatusBadRequest, response.Error(response.CodeParamError, "旧密码必填"))
+		writeError(ctx, response.CodeParamError, "旧密码必填")
 		return
 	}
 
 	if err := c.UserService.UpdatePassword(uid.(uint64), req.NewPassword, req.OldPassword); err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
@@ -359,6 +416,12 @@ func (c *ChatEngine) GinHandleUpdateUserPassword(ctx *gin.Context) {
 	}))
 }
 
+// SearchUsersReq 搜索用户参数
+type SearchUsersReq struct {
+	Keyword string `form:"keyword" binding:"max=64"`
+	PageQuery
+}
+
 // GinHandleSearchUsers 搜索用户
 // @Summary 搜索用户
 // @Description 按关键字搜索用户（username/nickname/uid），自动排除当前用户
@@ -366,30 +429,409 @@ func (c *ChatEngine) GinHandleUpdateUserPassword(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param keyword query string false "搜索关键字"
-// @Param limit query int false "返回条数"
+// @Param limit query int false "返回条数(默认20,最大100)"
 // @Param offset query int false "偏移量"
 // @Success 200 {object} response.Response{data=[]service.UserDTO} "用户列表"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Security BearerAuth
 // @Router /user/search [get]
 func (c *ChatEngine) GinHandleSearchUsers(ctx *gin.Context) {
-	keyword := ctx.Query("keyword")
-	limitStr := ctx.Query("limit")
-	offsetStr := ctx.Query("offset")
-
-	limit, _ := strconv.Atoi(limitStr)
-	offset, _ := strconv.Atoi(offsetStr)
+	var req SearchUsersReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
 
 	var excludeID uint64
 	if uid, exists := ctx.Get("user_id"); exists {
 		excludeID = uid.(uint64)
 	}
 
-	users, err := c.UserService.SearchUsers(keyword, excludeID, limit, offset)
+	users, err := c.UserService.SearchUsers(req.Keyword, excludeID, req.Limit, req.Offset)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		writeServiceError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, response.Success(users))
 }
+
+type UserBriefsReq struct {
+	IDs string `form:"ids" binding:"required" example:"1001,1002"` // 逗号分隔的用户ID
+}
+
+// GinHandleGetUserBriefs 批量获取用户昵称头像
+// @Summary 批量获取用户昵称头像
+// @Description 客户端收到只带 user_id 的事件（@提及、表情回应、通知等）时批量补全展示信息，按传入顺序返回（去重），单次最多 100 个
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param ids query string true "逗号分隔的用户ID"
+// @Success 200 {object} response.Response{data=[]model.UserBrief} "用户展示信息"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /user/briefs [get]
+func (c *ChatEngine) GinHandleGetUserBriefs(ctx *gin.Context) {
+	var req UserBriefsReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	parts := strings.Split(req.IDs, ",")
+	ids := make([]uint64, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			writeError(ctx, response.CodeParamError, "ids 格式错误")
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	briefs, err := c.UserService.GetUserBriefs(ids)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(briefs))
+}
+
+// --- 封禁申诉 ---
+
+type SubmitAppealReq struct {
+	Account  string `json:"account" binding:"required" example:"13800138000"` // username/phone/email
+	Password string `json:"password" binding:"required"`
+	Content  string `json:"content" binding:"required" example:"误封，请核实"`
+}
+
+// GinHandleSubmitAppeal 提交封禁申诉
+// @Summary 提交封禁申诉
+// @Description 被暂停/封禁的账号无法登录，凭账号密码提交申诉（同一时间只能有一条待处理申诉），运维处理后通过会自动解封
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param req body SubmitAppealReq true "申诉"
+// @Success 200 {object} response.Response{data=service.AppealDTO} "申诉"
+// @Failure 400 {object} response.Response "参数错误"
+// @Router /user/appeal [post]
+func (c *ChatEngine) GinHandleSubmitAppeal(ctx *gin.Context) {
+	var req SubmitAppealReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	appeal, err := c.AccountService.SubmitAppeal(req.Account, req.Password, req.Content)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(appeal))
+}
+
+// GinHandleGetPrivacy 获取隐私设置
+// @Summary 获取隐私设置
+// @Description 获取当前用户“如何找到/添加我”的设置（搜索可见范围、手机号搜索、各来源是否允许添加）
+// @Tags 用户
+// @Produce json
+// @Success 200 {object} response.Response{data=service.UserPrivacyDTO} "隐私设置"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/privacy [get]
+func (c *ChatEngine) GinHandleGetPrivacy(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	p, err := c.UserService.GetPrivacy(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(p))
+}
+
+// GinHandleUpdatePrivacy 更新隐私设置
+// @Summary 更新隐私设置
+// @Description search_mode: 0-可模糊搜索 1-仅 UID/用户名精确搜索 2-不可搜索；只更新传入的字段
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param req body service.UpdatePrivacyReq true "隐私设置（可选字段）"
+// @Success 200 {object} response.Response{data=service.UserPrivacyDTO} "更新后的隐私设置"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/privacy [post]
+func (c *ChatEngine) GinHandleUpdatePrivacy(ctx *gin.Context) {
+	var req service.UpdatePrivacyReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	p, err := c.UserService.UpdatePrivacy(uid.(uint64), req)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(p))
+}
+
+// GinHandleGetUserSettings 获取个人设置
+// @Summary 获取个人设置
+// @Description 获取当前用户设置（隐身等）
+// @Tags 用户
+// @Produce json
+// @Success 200 {object} response.Response{data=service.UserSettingsDTO} "个人设置"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/settings [get]
+func (c *ChatEngine) GinHandleGetUserSettings(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	st, err := c.UserService.GetSettings(uid.(uint64))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(st))
+}
+
+// GinHandleUpdateUserSettings 更新个人设置
+// @Summary 更新个人设置
+// @Description appear_offline=true 时他人（资料、好友列表）看到的在线状态始终为离线，WS 连接与消息收发不受影响
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param req body service.UpdateUserSettingsReq true "个人设置（可选字段）"
+// @Success 200 {object} response.Response{data=service.UserSettingsDTO} "更新后的设置"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/settings [post]
+func (c *ChatEngine) GinHandleUpdateUserSettings(ctx *gin.Context) {
+	var req service.UpdateUserSettingsReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	st, err := c.UserService.UpdateSettings(uid.(uint64), req)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	c.WsServer.SetAppearOffline(uid.(uint64), st.AppearOffline)
+	ctx.JSON(http.StatusOK, response.Success(st))
+}
+
+// PrefsQuery 自定义设置查询参数
+type PrefsQuery struct {
+	// Namespace 逗号分隔的命名空间，为空返回全部
+	Namespace string `form:"namespace" example:"chat.appearance,chat.font"`
+}
+
+// GinHandleGetPrefs 获取自定义设置
+// @Summary 获取自定义设置
+// @Description 按命名空间获取当前用户的自定义设置（客户端偏好，JSON 对象 + 版本号），不传 namespace 返回全部
+// @Tags 用户
+// @Produce json
+// @Param namespace query string false "逗号分隔的命名空间"
+// @Success 200 {object} response.Response{data=[]service.UserPrefsDTO} "自定义设置"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/prefs [get]
+func (c *ChatEngine) GinHandleGetPrefs(ctx *gin.Context) {
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var req PrefsQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	var namespaces []string
+	for _, ns := range strings.Split(req.Namespace, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	out, err := c.UserService.GetPrefs(uid.(uint64), namespaces...)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
+// GinHandlePatchPrefs 修改自定义设置
+// @Summary 修改自定义设置
+// @Description 按 JSON Merge Patch 合并（replace=true 整体替换）一个命名空间的设置，版本号加 1 并推送 prefs_updated 给本人所有设备；
+// @Description 传 base_version 且与服务端不一致时返回冲突，客户端应重新拉取后再改
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param req body service.PatchPrefsReq true "修改内容"
+// @Success 200 {object} response.Response{data=service.UserPrefsDTO} "修改后的设置"
+// @Failure 400 {object} response.Response "参数错误/版本冲突"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/prefs [post]
+func (c *ChatEngine) GinHandlePatchPrefs(ctx *gin.Context) {
+	var req service.PatchPrefsReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	out, err := c.UserService.PatchPrefs(uid.(uint64), req)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(out))
+}
+
+// --- 登录态 ---
+
+// requestToken 当前请求的 token（鉴权中间件写入，没有时从请求头/query 解析）
+func (c *ChatEngine) requestToken(ctx *gin.Context) string {
+	if token := ctx.GetString("token"); token != "" {
+		return token
+	}
+	return c.AuthService.ExtractToken(ctx.Request)
+}
+
+// GinHandleIntrospectToken 查询当前 token
+// @Summary 查询当前登录态
+// @Description 返回当前 token 的用户、过期时间、登录设备/IP，以及本实例内绑定到它的 WS 连接数
+// @Tags 用户
+// @Produce json
+// @Success 200 {object} response.Response{data=service.TokenInfo} "token 详情"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/token [get]
+func (c *ChatEngine) GinHandleIntrospectToken(ctx *gin.Context) {
+	info, err := c.AuthService.Introspect(ctx.Request.Context(), c.requestToken(ctx))
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(info))
+}
+
+type LogoutReq struct {
+	All bool `form:"all" example:"false"` // true 时注销该用户全部 token（所有设备下线）
+	// Device true 时注销当前设备上登录的所有账号（需登录时携带 device_id）
+	Device bool `form:"device" example:"false"`
+}
+
+// GinHandleLogout 退出登录
+// @Summary 退出登录
+// @Description 注销当前 token（all=true 时注销该用户全部 token，device=true 时注销当前设备上的所有账号），绑定到这些 token 的 WS 连接立即断开（close code 4002）
+// @Tags 用户
+// @Produce json
+// @Param all query bool false "是否注销全部设备"
+// @Param device query bool false "是否注销当前设备上的所有账号"
+// @Success 200 {object} response.Response "成功"
+// @Failure 401 {object} response.Response "未登录"
+// @Security BearerAuth
+// @Router /user/logout [post]
+func (c *ChatEngine) GinHandleLogout(ctx *gin.Context) {
+	var req LogoutReq
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	var err error
+	if req.All {
+		err = c.AuthService.RevokeAllTokensByUser(ctx.Request.Context(), uid.(uint64))
+	} else if req.Device {
+		var info *service.TokenInfo
+		if info, err = c.AuthService.Introspect(ctx.Request.Context(), c.requestToken(ctx)); err == nil {
+			if info.DeviceID == "" {
+				err = c.AuthService.RevokeToken(ctx.Request.Context(), c.requestToken(ctx))
+			} else {
+				err = c.AuthService.RevokeDeviceTokens(ctx.Request.Context(), info.DeviceID)
+			}
+		}
+	} else {
+		err = c.AuthService.RevokeToken(ctx.Request.Context(), c.requestToken(ctx))
+	}
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(nil))
+}
+
+type SwitchAccountReq struct {
+	FromToken string `json:"from_token" binding:"required"` // 切换前账号的 token（同一设备登录）
+}
+
+// GinHandleSwitchAccount 切换账号
+// @Summary 同一设备切换账号
+// @Description 请求使用目标账号的 token 鉴权，body 带切换前账号的 token；两个 token 须由同一 device_id 登录。
+// @Description 成功后旧账号在该设备上的 WS 连接以 close code 4003 断开（客户端用新 token 重连），旧 token 仍有效可切回；目标账号标记为在线。
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param req body SwitchAccountReq true "切换前账号的 token"
+// @Success 200 {object} response.Response{data=service.AccountSwitchResp} "切换结果"
+// @Failure 400 {object} response.Response "不是同一设备或同一账号"
+// @Failure 401 {object} response.Response "token 无效"
+// @Security BearerAuth
+// @Router /user/account/switch [post]
+func (c *ChatEngine) GinHandleSwitchAccount(ctx *gin.Context) {
+	var req SwitchAccountReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	if _, exists := ctx.Get("user_id"); !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	ev, err := c.AuthService.SwitchAccount(ctx.Request.Context(), req.FromToken, c.requestToken(ctx), ctx.ClientIP())
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	// 在线状态：目标账号同登录一样标记在线；旧账号在本实例没有其他连接时标记离线，
+	// 有连接时由被断开连接的注销流程（或其他设备上的连接）决定
+	if err := c.UserService.SetOnlineStatus(ev.ToUserID, model.OnlineStatusOnline, ev.At); err != nil {
+		log.Printf("switch account: update online status user=%d err=%v", ev.ToUserID, err)
+	}
+	if len(c.WsServer.Connections(ev.FromUserID)) == 0 {
+		if err := c.UserService.SetOnlineStatus(ev.FromUserID, model.OnlineStatusOffline, ev.At); err != nil {
+			log.Printf("switch account: update online status user=%d err=%v", ev.FromUserID, err)
+		}
+	}
+
+	user, err := c.UserService.GetUser(ev.ToUserID, ev.ToUserID)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(service.AccountSwitchResp{AccountSwitchEvent: *ev, User: user}))
+}
@@ -0,0 +1,185 @@
+package chat_sdk
+
+import (
+	"net/http"
+
+	"github.com/cydxin/chat-sdk/service"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+var _ = service.PollDTO{}
+
+// -------------------- 投票（Poll）相关接口 --------------------
+// 说明：文件名用 vote 以区分 ws_poll.go（长轮询）。
+
+type CreatePollReq struct {
+	RoomID          uint64   `json:"room_id" binding:"required"`
+	Question        string   `json:"question" binding:"required"`
+	Options         []string `json:"options" binding:"required"`
+	MultiChoice     bool     `json:"multi_choice"`
+	MaxChoices      int      `json:"max_choices"`
+	Anonymous       bool     `json:"anonymous"`
+	DeadlineMinutes int      `json:"deadline_minutes"`
+}
+
+type VotePollReq struct {
+	PollID    uint64   `json:"poll_id" binding:"required"`
+	OptionIDs []uint64 `json:"option_ids" binding:"required"`
+}
+
+type ClosePollReq struct {
+	PollID uint64 `json:"poll_id" binding:"required"`
+}
+
+// GinHandleCreatePoll 发起投票
+// @Summary 发起投票
+// @Description 在房间发起投票（消息 msg_type=11，extra.poll 带 poll_id），支持单选/多选、匿名、截止时间
+// @Tags 投票
+// @Accept json
+// @Produce json
+// @Param req body CreatePollReq true "投票参数"
+// @Success 200 {object} response.Response{data=service.MessageDTO} "投票消息"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /poll/create [post]
+func (c *ChatEngine) GinHandleCreatePoll(ctx *gin.Context) {
+	var req CreatePollReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+	creatorID := uid.(uint64)
+
+	room, err := c.RoomService.GetRoomByID(req.RoomID)
+	if err != nil {
+		writeError(ctx, response.CodeParamError, "房间不存在")
+		return
+	}
+	_, msg, err := c.PollService.CreatePoll(creatorID, service.CreatePollReq{
+		RoomID:          room.ID,
+		Question:        req.Question,
+		Options:         req.Options,
+		MultiChoice:     req.MultiChoice,
+		MaxChoices:      req.MaxChoices,
+		Anonymous:       req.Anonymous,
+		DeadlineMinutes: req.DeadlineMinutes,
+	})
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+
+	sender := c.UserService.UserBrief(creatorID)
+	pushRoomMessage(room, msg, "", sender.Nickname, sender.Avatar)
+
+	ctx.JSON(http.StatusOK, response.Success(service.ToMessageDTO(msg)))
+}
+
+// GinHandleVotePoll 投票
+// @Summary 投票
+// @Description 提交投票（每人一次），成功后向房间成员推送 poll_update
+// @Tags 投票
+// @Accept json
+// @Produce json
+// @Param req body VotePollReq true "投票选项"
+// @Success 200 {object} response.Response{data=service.PollDTO} "最新结果"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /poll/vote [post]
+func (c *ChatEngine) GinHandleVotePoll(ctx *gin.Context) {
+	var req VotePollReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	dto, err := c.PollService.Vote(uid.(uint64), req.PollID, req.OptionIDs)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(dto))
+}
+
+// GinHandleClosePoll 结束投票
+// @Summary 结束投票
+// @Description 发起人手动结束投票（到达截止时间会自动结束）
+// @Tags 投票
+// @Accept json
+// @Produce json
+// @Param req body ClosePollReq true "投票ID"
+// @Success 200 {object} response.Response "成功响应"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /poll/close [post]
+func (c *ChatEngine) GinHandleClosePoll(ctx *gin.Context) {
+	var req ClosePollReq
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	if err := c.PollService.ClosePoll(uid.(uint64), req.PollID); err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(map[string]any{"message": "ok"}))
+}
+
+// PollIDQuery 投票详情参数
+type PollIDQuery struct {
+	PollID uint64 `form:"poll_id" binding:"required"`
+}
+
+// GinHandleGetPoll 投票详情
+// @Summary 投票详情
+// @Description 获取投票结果，匿名投票不返回投票人；my_option_ids 为当前用户的选择
+// @Tags 投票
+// @Accept json
+// @Produce json
+// @Param poll_id query uint64 true "投票ID"
+// @Success 200 {object} response.Response{data=service.PollDTO} "投票详情"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Security BearerAuth
+// @Router /poll/detail [get]
+func (c *ChatEngine) GinHandleGetPoll(ctx *gin.Context) {
+	var req PollIDQuery
+	if !bindQuery(ctx, &req) {
+		return
+	}
+	id := req.PollID
+
+	uid, exists := ctx.Get("user_id")
+	if !exists {
+		writeError(ctx, response.CodeTokenInvalid, "user_id not found")
+		return
+	}
+
+	dto, err := c.PollService.GetPoll(uid.(uint64), id)
+	if err != nil {
+		writeServiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Success(dto))
+}
@@ -0,0 +1,347 @@
+package message
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 消息类型（models.Message.Type / Req.SendType）
+const (
+	TypeText      uint8 = 1
+	TypeImage     uint8 = 2
+	TypeVoice     uint8 = 3
+	TypeVideo     uint8 = 4
+	TypeFile      uint8 = 5
+	TypeLocation  uint8 = 6
+	TypeQuote     uint8 = 7
+	TypeMention   uint8 = 8
+	TypeRedPacket uint8 = 10
+	TypePoll      uint8 = 11
+)
+
+// Extra 中与消息类型绑定的字段（json 名）：只能出现在 schema 声明了该字段的消息类型上
+const (
+	ExtraFieldLocation    = "location"
+	ExtraFieldFileInfo    = "file_info"
+	ExtraFieldVoice       = "voice"
+	ExtraFieldRedPacket   = "red_packet"
+	ExtraFieldPoll        = "poll"
+	ExtraFieldSystem      = "system"
+	ExtraFieldLinkPreview = "link_preview"
+)
+
+// ExtraError 的原因
+const (
+	ExtraRequired   = "required"     // 必填字段缺失
+	ExtraNotAllowed = "not_allowed"  // 该消息类型不允许携带
+	ExtraOutOfRange = "out_of_range" // 数值越界
+	ExtraTooLong    = "too_long"     // 字符串/列表过长
+	ExtraInvalid    = "invalid"      // 其他格式错误
+)
+
+const (
+	// ExtraMaxTextLen 文件名、地址等文本字段的最大字符数
+	ExtraMaxTextLen = 255
+	// ExtraMaxMentions 一条消息最多 @ 的人数
+	ExtraMaxMentions = 100
+)
+
+// ExtraError Extra 校验失败，Field 为出错字段的 json 路径（如 location.lat）
+type ExtraError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ExtraError) Error() string {
+	switch e.Reason {
+	case ExtraRequired:
+		return "消息扩展缺少 " + e.Field
+	case ExtraNotAllowed:
+		return "该消息类型不支持扩展字段 " + e.Field
+	case ExtraOutOfRange:
+		return "消息扩展 " + e.Field + " 超出范围"
+	case ExtraTooLong:
+		return "消息扩展 " + e.Field + " 过长"
+	}
+	return "消息扩展 " + e.Field + " 格式错误"
+}
+
+func extraErr(field, reason string) error {
+	return &ExtraError{Field: field, Reason: reason}
+}
+
+// ExtraSchema 某一消息类型的 Extra 约束
+type ExtraSchema struct {
+	// Fields 允许携带的类型绑定字段（ExtraField*）；未列出的绑定字段出现即拒绝，
+	// message_id / user_id / mentioned_users / view_once 等通用字段不受限制
+	Fields []string
+	// Validate 字段级校验，可修正 e（如统一大小写）；content 为消息内容
+	Validate func(content string, e *Extra) error
+}
+
+var (
+	extraSchemaMu sync.RWMutex
+	extraSchemas  = map[uint8]ExtraSchema{
+		TypeText:      {Validate: validateTextExtra},
+		TypeImage:     {Fields: []string{ExtraFieldFileInfo}, Validate: validateMediaExtra},
+		TypeVoice:     {Fields: []string{ExtraFieldVoice}, Validate: validateVoiceExtra},
+		TypeVideo:     {Fields: []string{ExtraFieldFileInfo}, Validate: validateMediaExtra},
+		TypeFile:      {Fields: []string{ExtraFieldFileInfo}, Validate: validateMediaExtra},
+		TypeLocation:  {Fields: []string{ExtraFieldLocation}, Validate: validateLocationExtra},
+		TypeQuote:     {Validate: validateQuoteExtra},
+		TypeMention:   {Validate: validateMentionExtra},
+		TypeRedPacket: {Fields: []string{ExtraFieldRedPacket}, Validate: requireField(ExtraFieldRedPacket, func(e *Extra) bool { return e.RedPacket != nil })},
+		TypePoll:      {Fields: []string{ExtraFieldPoll}, Validate: requireField(ExtraFieldPoll, func(e *Extra) bool { return e.Poll != nil })},
+	}
+)
+
+// RegisterExtraSchema 注册/覆盖某一消息类型的 Extra 约束（业务自定义消息类型），应在创建 Engine 前调用
+func RegisterExtraSchema(msgType uint8, schema ExtraSchema) {
+	extraSchemaMu.Lock()
+	extraSchemas[msgType] = schema
+	extraSchemaMu.Unlock()
+}
+
+// LookupExtraSchema 查询消息类型的 Extra 约束
+func LookupExtraSchema(msgType uint8) (ExtraSchema, bool) {
+	extraSchemaMu.RLock()
+	s, ok := extraSchemas[msgType]
+	extraSchemaMu.RUnlock()
+	return s, ok
+}
+
+// ValidateExtra 按消息类型校验 Extra：先拒绝不属于该类型的绑定字段，再执行字段级校验。
+// 未注册 schema 的消息类型不做约束。
+func ValidateExtra(msgType uint8, content string, e *Extra) error {
+	schema, ok := LookupExtraSchema(msgType)
+	if !ok {
+		return nil
+	}
+	for _, field := range e.boundFields() {
+		allowed := false
+		for _, f := range schema.Fields {
+			if f == field {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return extraErr(field, ExtraNotAllowed)
+		}
+	}
+	if schema.Validate != nil {
+		return schema.Validate(content, e)
+	}
+	return nil
+}
+
+// boundFields e 中已设置的类型绑定字段
+func (e *Extra) boundFields() []string {
+	var fields []string
+	if e.Location != nil {
+		fields = append(fields, ExtraFieldLocation)
+	}
+	if e.FileInfo != nil {
+		fields = append(fields, ExtraFieldFileInfo)
+	}
+	if e.Voice != nil {
+		fields = append(fields, ExtraFieldVoice)
+	}
+	if e.RedPacket != nil {
+		fields = append(fields, ExtraFieldRedPacket)
+	}
+	if e.Poll != nil {
+		fields = append(fields, ExtraFieldPoll)
+	}
+	if e.System != nil {
+		fields = append(fields, ExtraFieldSystem)
+	}
+	if e.LinkPreview != nil {
+		fields = append(fields, ExtraFieldLinkPreview)
+	}
+	return fields
+}
+
+func requireField(field string, present func(e *Extra) bool) func(string, *Extra) error {
+	return func(_ string, e *Extra) error {
+		if !present(e) {
+			return extraErr(field, ExtraRequired)
+		}
+		return nil
+	}
+}
+
+// validateMediaExtra 图片/视频/文件：内容为文件地址；file_info 可选，携带时校验名称与大小
+func validateMediaExtra(content string, e *Extra) error {
+	if strings.TrimSpace(content) == "" {
+		return extraErr("content", ExtraRequired)
+	}
+	f := e.FileInfo
+	if f == nil {
+		return nil
+	}
+	if f.Size < 0 {
+		return extraErr("file_info.size", ExtraOutOfRange)
+	}
+	if utf8.RuneCountInString(f.Name) > ExtraMaxTextLen {
+		return extraErr("file_info.name", ExtraTooLong)
+	}
+	if strings.ContainsAny(f.Name, "/\\\x00") {
+		return extraErr("file_info.name", ExtraInvalid)
+	}
+	f.Ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(f.Ext), "."))
+	if len(f.Ext) > 16 {
+		return extraErr("file_info.ext", ExtraTooLong)
+	}
+	return nil
+}
+
+// validateVoiceExtra 语音：voice 必填、波形合法（编码与时长上限由 service 按配置校验）
+func validateVoiceExtra(_ string, e *Extra) error {
+	v := e.Voice
+	if v == nil {
+		return extraErr(ExtraFieldVoice, ExtraRequired)
+	}
+	if len(v.Waveform) > VoiceMaxWaveformSamples {
+		return extraErr("voice.waveform", ExtraTooLong)
+	}
+	for _, w := range v.Waveform {
+		if w < 0 || w > 255 {
+			return extraErr("voice.waveform", ExtraOutOfRange)
+		}
+	}
+	return nil
+}
+
+// validateLocationExtra 位置：location 必填，经纬度在合法范围内
+func validateLocationExtra(_ string, e *Extra) error {
+	l := e.Location
+	if l == nil {
+		return extraErr(ExtraFieldLocation, ExtraRequired)
+	}
+	if math.IsNaN(l.Latitude) || l.Latitude < -90 || l.Latitude > 90 {
+		return extraErr("location.lat", ExtraOutOfRange)
+	}
+	if math.IsNaN(l.Longitude) || l.Longitude < -180 || l.Longitude > 180 {
+		return extraErr("location.lng", ExtraOutOfRange)
+	}
+	if utf8.RuneCountInString(l.Address) > ExtraMaxTextLen {
+		return extraErr("location.address", ExtraTooLong)
+	}
+	return nil
+}
+
+// validateTextExtra 文本：可选 @ 列表
+func validateTextExtra(_ string, e *Extra) error {
+	return validateMentions(e, false)
+}
+
+// validateQuoteExtra 引用：必须指明被引用的消息
+func validateQuoteExtra(_ string, e *Extra) error {
+	if e.MessageID == 0 {
+		return extraErr("message_id", ExtraRequired)
+	}
+	return validateMentions(e, false)
+}
+
+// validateMentionExtra @：至少 @ 一人（可同时引用消息）
+func validateMentionExtra(_ string, e *Extra) error {
+	return validateMentions(e, true)
+}
+
+func validateMentions(e *Extra, required bool) error {
+	if len(e.MentionedUsers) == 0 {
+		if required {
+			return extraErr("mentioned_users", ExtraRequired)
+		}
+		return nil
+	}
+	if len(e.MentionedUsers) > ExtraMaxMentions {
+		return extraErr("mentioned_users", ExtraTooLong)
+	}
+	for _, id := range e.MentionedUsers {
+		if id == 0 {
+			return extraErr("mentioned_users", ExtraInvalid)
+		}
+	}
+	return nil
+}
+
+// DecodeExtra 解析落库的 Extra，空值返回零值
+func DecodeExtra(raw []byte) (Extra, error) {
+	var e Extra
+	if len(raw) == 0 {
+		return e, nil
+	}
+	err := json.Unmarshal(raw, &e)
+	return e, err
+}
+
+// VoiceOf 语音元数据（仅 type=3）
+func VoiceOf(msgType uint8, raw []byte) *VoiceInfo {
+	if msgType != TypeVoice {
+		return nil
+	}
+	e, err := DecodeExtra(raw)
+	if err != nil {
+		return nil
+	}
+	return e.Voice
+}
+
+// FileOf 文件信息（仅图片/视频/文件）
+func FileOf(msgType uint8, raw []byte) *FileInfo {
+	if msgType != TypeImage && msgType != TypeVideo && msgType != TypeFile {
+		return nil
+	}
+	e, err := DecodeExtra(raw)
+	if err != nil {
+		return nil
+	}
+	return e.FileInfo
+}
+
+// LocationOf 位置信息（仅 type=6）
+func LocationOf(msgType uint8, raw []byte) *LocationInfo {
+	if msgType != TypeLocation {
+		return nil
+	}
+	e, err := DecodeExtra(raw)
+	if err != nil {
+		return nil
+	}
+	return e.Location
+}
+
+// MentionsOf 被 @ 的用户（任意消息类型，落库时已过滤为房间成员）
+func MentionsOf(raw []byte) []uint64 {
+	e, err := DecodeExtra(raw)
+	if err != nil {
+		return nil
+	}
+	return e.MentionedUsers
+}
+
+// QuoteOf 被引用的消息 ID，没有引用时返回 0
+func QuoteOf(raw []byte) uint64 {
+	e, err := DecodeExtra(raw)
+	if err != nil {
+		return 0
+	}
+	return e.MessageID
+}
+
+// MergeForwardOf 合并转发消息的聊天记录（extra.type=merge_forward），不是合并转发时返回 nil
+func MergeForwardOf(raw []byte) *MergeForwardEvent {
+	if len(raw) == 0 {
+		return nil
+	}
+	var m MergeForwardEvent
+	if err := json.Unmarshal(raw, &m); err != nil || m.Type != WsEventMergeForward {
+		return nil
+	}
+	return &m
+}
@@ -10,12 +10,18 @@ type Req struct {
 }
 
 type Extra struct {
-	MessageID      uint64        `json:"message_id,omitempty"`      // 被引用的消息 ID
-	UserID         uint64        `json:"user_id,omitempty"`         // 相关用户 ID
-	MessageContent string        `json:"message_content,omitempty"` // 被引用的消息内容
-	MentionedUsers []uint64      `json:"mentioned_users,omitempty"` // 被@的用户列表
-	Location       *LocationInfo `json:"location,omitempty"`        // 位置信息
-	FileInfo       *FileInfo     `json:"file_info,omitempty"`       // 文件信息 用不上 直接文件地址实现
+	MessageID      uint64         `json:"message_id,omitempty"`      // 被引用的消息 ID
+	UserID         uint64         `json:"user_id,omitempty"`         // 相关用户 ID
+	MessageContent string         `json:"message_content,omitempty"` // 被引用的消息内容
+	MentionedUsers []uint64       `json:"mentioned_users,omitempty"` // 被@的用户列表
+	Location       *LocationInfo  `json:"location,omitempty"`        // 位置信息
+	FileInfo       *FileInfo      `json:"file_info,omitempty"`       // 文件信息 用不上 直接文件地址实现
+	LinkPreview    *LinkPreview   `json:"link_preview,omitempty"`    // 链接预览（服务端异步抓取后写入）
+	Voice          *VoiceInfo     `json:"voice,omitempty"`           // 语音信息（send_type=3 必填）
+	RedPacket      *RedPacketInfo `json:"red_packet,omitempty"`      // 红包信息（服务端生成）
+	Poll           *PollInfo      `json:"poll,omitempty"`            // 投票信息（服务端生成）
+	System         *SystemInfo    `json:"system,omitempty"`          // 系统消息结构化内容（服务端生成）
+	ViewOnce       bool           `json:"view_once,omitempty"`       // 阅后即焚（仅私聊）
 }
 
 type LocationInfo struct {
@@ -30,3 +36,54 @@ type FileInfo struct {
 	URL  string `json:"url"`
 	Ext  string `json:"ext"`
 }
+
+// LinkPreview 链接卡片（Open Graph）
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// VoiceInfo 语音消息元数据。Content 为语音文件地址。
+type VoiceInfo struct {
+	DurationMs int    `json:"duration_ms"`        // 时长（毫秒）
+	Waveform   []int  `json:"waveform,omitempty"` // 波形采样（0-255），最多 VoiceMaxWaveformSamples 个
+	Codec      string `json:"codec"`              // 编码：opus/aac/amr/mp3/speex
+}
+
+// VoiceMaxWaveformSamples 波形最多采样点数
+const VoiceMaxWaveformSamples = 128
+
+// VoiceCodecs 支持的语音编码
+var VoiceCodecs = map[string]struct{}{
+	"opus":  {},
+	"aac":   {},
+	"amr":   {},
+	"mp3":   {},
+	"speex": {},
+}
+
+// RedPacketInfo 红包消息扩展
+type RedPacketInfo struct {
+	RedPacketID uint64 `json:"red_packet_id"`
+	Type        uint8  `json:"type"` // 1-拼手气 2-普通
+	Greeting    string `json:"greeting"`
+}
+
+// PollInfo 投票消息扩展
+type PollInfo struct {
+	PollID      uint64 `json:"poll_id"`
+	Question    string `json:"question"`
+	MultiChoice bool   `json:"multi_choice"`
+	Anonymous   bool   `json:"anonymous"`
+}
+
+// SystemInfo 系统消息结构化内容：content 是默认中文文案，客户端可按 event + 参数自行本地化
+type SystemInfo struct {
+	Event     string            `json:"event"`                // 事件类型，同房间通知 event_type（如 room.member.added）
+	ActorID   uint64            `json:"actor_id"`             // 操作者
+	TargetIDs []uint64          `json:"target_ids,omitempty"` // 被操作的用户
+	Params    map[string]string `json:"params,omitempty"`     // 其他参数（群名、时长等）
+}
@@ -0,0 +1,65 @@
+package message
+
+// WS error 事件（type=error）的错误码，取值与 HTTP 业务码 response.Code* 一致，客户端可共用一套处理
+const (
+	WsErrInvalidPayload = 10001 // 请求格式/参数错误、房间不存在（同 response.CodeParamError）
+	WsErrForbidden      = 10005 // 无权发送：非成员、被拉黑、客服会话已结束（同 response.CodePermissionDeny）
+	WsErrSuspended      = 10009 // 账号已被暂停/封禁（同 response.CodeAccountSuspended）
+	WsErrRateLimited    = 10010 // 发送过于频繁（同 response.CodeRateLimited）
+	WsErrServerBusy     = 10013 // 服务器繁忙（同 response.CodeServerBusy）
+	WsErrMuted          = 10014 // 已被禁言，data 为禁言详情（同 response.CodeMuted）
+	WsErrInternal       = 99999 // 内部错误（同 response.CodeInternalError）
+)
+
+// WS error 事件的 reason：同一错误码下的细分原因，客户端据此展示不同提示
+const (
+	WsErrReasonInvalidPayload = "invalid_payload" // JSON 解析失败或缺少必填字段
+	WsErrReasonInvalidExtra   = "invalid_extra"   // 消息内容/extra 未通过校验
+	WsErrReasonRoomNotFound   = "room_not_found"
+	WsErrReasonNotMember      = "not_member"
+	WsErrReasonBlocked        = "blocked"        // 私聊一方拉黑了另一方
+	WsErrReasonSessionClosed  = "session_closed" // 客服会话已结束
+	WsErrReasonForbidden      = "forbidden"      // 其他无权限
+	WsErrReasonSuspended      = "suspended"
+	WsErrReasonRateLimited    = "rate_limited"
+	WsErrReasonServerBusy     = "server_busy"
+	WsErrReasonMuted          = "muted"
+	WsErrReasonInternal       = "internal"
+)
+
+// wsErrDefaultReason 错误码对应的默认 reason（没有更细分的原因时使用）
+var wsErrDefaultReason = map[int]string{
+	WsErrInvalidPayload: WsErrReasonInvalidPayload,
+	WsErrForbidden:      WsErrReasonForbidden,
+	WsErrSuspended:      WsErrReasonSuspended,
+	WsErrRateLimited:    WsErrReasonRateLimited,
+	WsErrServerBusy:     WsErrReasonServerBusy,
+	WsErrMuted:          WsErrReasonMuted,
+	WsErrInternal:       WsErrReasonInternal,
+}
+
+// WsErrorReason 错误码的默认 reason，未登记的错误码视为 internal
+func WsErrorReason(code int) string {
+	if r, ok := wsErrDefaultReason[code]; ok {
+		return r
+	}
+	return WsErrReasonInternal
+}
+
+// WsErrorRetryable 同样的请求稍后重发是否可能成功：限流、繁忙、内部错误可以重试；
+// 禁言在 retry_after 秒后可重试；格式错误、无权限、封禁重发无意义
+func WsErrorRetryable(code int) bool {
+	switch code {
+	case WsErrRateLimited, WsErrServerBusy, WsErrMuted, WsErrInternal:
+		return true
+	}
+	return false
+}
+
+// NewErrorEvent 按错误码构造 error 事件，reason 为空时取默认值，并填好 retryable
+func NewErrorEvent(code int, reason, msg, packetID string) *ErrorEvent {
+	if reason == "" {
+		reason = WsErrorReason(code)
+	}
+	return &ErrorEvent{Message: msg, PacketID: packetID, Code: code, Reason: reason, Retryable: WsErrorRetryable(code)}
+}
@@ -0,0 +1,359 @@
+package message
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WS 下行事件类型（server -> client，JSON 顶层 type 字段）
+const (
+	WsEventSession          = "session"               // 建连握手（第一条消息）
+	WsEventMessage          = "message"               // 房间消息
+	WsEventNotification     = "notification"          // 房间事件（event_type 见 service.Event*）
+	WsEventError            = "error"                 // 上行请求被拒绝
+	WsEventHeartbeat        = "heartbeat"             // 应用层心跳回包
+	WsEventForward          = "forward"               // 逐条转发的新消息
+	WsEventMergeForward     = "merge_forward"         // 合并转发的新消息
+	WsEventRecall           = "recall"                // 消息撤回（未启用房间事件落库时）
+	WsEventFriendRequest    = "friend_request"        // 收到好友申请
+	WsEventFriendAccepted   = "friend_accepted"       // 好友申请被同意
+	WsEventFriendRejected   = "friend_rejected"       // 好友申请被拒绝
+	WsEventFriendExpired    = "friend_expired"        // 好友申请超过有效期未处理
+	WsEventFriendDeleted    = "friend_deleted"        // 好友关系解除
+	WsEventMessageUpdated   = "message_update"        // 消息内容/扩展更新（如链接预览）
+	WsEventPollUpdated      = "poll_update"           // 投票结果更新
+	WsEventAccountStatus    = "account_status"        // 账号被暂停/封禁/恢复
+	WsEventMessageStatus    = "message_status"        // 消息状态推进（已送达/已读），只推给发送者
+	WsEventConversationRead = "conversation_read"     // 会话已读游标前进，推给同一用户的其他设备
+	WsEventTyping           = "typing"                // 房间成员正在输入，推给其他成员
+	WsEventViewOnceViewed   = "view_once_viewed"      // 阅后即焚消息已被查看、内容已清除
+	WsEventPrefsUpdated     = "prefs_updated"         // 用户自定义设置变更，推给同一用户的所有设备
+	WsEventConvSettings     = "conversation_settings" // 会话背景/别名变更，推给同一用户的所有设备
+	WsEventRoomOnline       = "room_online"           // 房间在线人数变化（合并推送给房间内在线成员）
+)
+
+// Event 类型化的 WS 下行事件，用 EncodeEvent 序列化（自动填写 type 字段）。
+// 只有本包定义的事件实现该接口，新增事件需同时加入 EventCatalog。
+type Event interface {
+	WsType() string
+	setType(t string)
+}
+
+// EventHeader 所有下行事件共有的 type 字段，嵌入到各事件结构体中
+type EventHeader struct {
+	Type string `json:"type"`
+}
+
+func (h *EventHeader) setType(t string) { h.Type = t }
+
+// EncodeEvent 按事件类型填写 type 后序列化
+func EncodeEvent(e Event) ([]byte, error) {
+	e.setType(e.WsType())
+	return json.Marshal(e)
+}
+
+// SessionEvent 建连握手：客户端保存 resume_token 与最后收到的 seq，
+// 重连时以 ?resume_token=...&last_event_id=... 续传；resumed=false 表示无法续传，需要走 HTTP 全量同步。
+type SessionEvent struct {
+	EventHeader
+	ResumeToken string `json:"resume_token"`
+	LastEventID uint64 `json:"last_event_id"` // 当前最新的 seq
+	Resumed     bool   `json:"resumed"`
+	Replayed    int    `json:"replayed"`
+}
+
+func (*SessionEvent) WsType() string { return WsEventSession }
+
+// RoomMessageEvent 房间消息推送（WS / 长轮询 / 机器人 Webhook 共用），发送者收到的回显带 packet_id
+type RoomMessageEvent struct {
+	EventHeader
+	PacketID       string          `json:"packet_id"`
+	ID             uint64          `json:"id"`
+	RoomID         uint64          `json:"room_id"`
+	RoomType       uint8           `json:"room_type"`
+	SenderID       uint64          `json:"sender_id"`
+	SenderNickname string          `json:"sender_nickname"`
+	SenderAvatar   string          `json:"sender_avatar"`
+	MsgType        uint8           `json:"msg_type"`
+	Content        string          `json:"content"`
+	Extra          json.RawMessage `json:"extra,omitempty"`
+	IsSystem       bool            `json:"is_system,omitempty"`
+	ViewOnce       bool            `json:"view_once,omitempty"` // 阅后即焚：不推送内容，接收方通过查看接口获取
+	RoomSeq        uint64          `json:"room_seq"`            // 房间内序号（与顶层按用户编号的 seq 无关），同房间消息按它排序
+	SendTime       int64           `json:"send_time"`           // 服务端落库时间（毫秒）
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+func (*RoomMessageEvent) WsType() string { return WsEventMessage }
+
+// NotificationEvent 房间事件（入群、禁言、撤回等），event_id 用于标记已读
+type NotificationEvent struct {
+	EventHeader
+	EventID   uint64          `json:"event_id"` // 只推送不落库时为 0
+	RoomID    uint64          `json:"room_id"`
+	ActorID   uint64          `json:"actor_id"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (*NotificationEvent) WsType() string { return WsEventNotification }
+
+// ErrorEvent 上行请求被拒绝，packet_id 对应请求；code/reason 见 WsErr*（ws_errors.go），被禁言时 data 为禁言详情（scope / until / retry_after）
+type ErrorEvent struct {
+	EventHeader
+	Message  string `json:"message"`
+	PacketID string `json:"packet_id"`
+	Code     int    `json:"code,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Retryable 稍后重发同一请求是否可能成功；RetryAfter>0 时为建议等待的秒数
+	Retryable  bool  `json:"retryable"`
+	RetryAfter int64 `json:"retry_after,omitempty"`
+	Data       any   `json:"data,omitempty"`
+}
+
+func (*ErrorEvent) WsType() string { return WsEventError }
+
+// HeartbeatEvent 应用层心跳回包
+type HeartbeatEvent struct {
+	EventHeader
+	ServerTime int64 `json:"server_time"` // 毫秒时间戳
+}
+
+func (*HeartbeatEvent) WsType() string { return WsEventHeartbeat }
+
+// ForwardEvent 逐条转发到房间的新消息
+type ForwardEvent struct {
+	EventHeader
+	RoomID    uint64 `json:"room_id"`
+	MessageID uint64 `json:"message_id"`
+}
+
+func (*ForwardEvent) WsType() string { return WsEventForward }
+
+// MergeForwardItem 合并转发中的一条原消息
+type MergeForwardItem struct {
+	ID        uint64          `json:"id"`
+	RoomID    uint64          `json:"room_id"`
+	SenderID  uint64          `json:"sender_id"`
+	Type      uint8           `json:"type"` // 消息类型
+	Content   string          `json:"content"`
+	Extra     json.RawMessage `json:"extra"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// MergeForwardEvent 合并转发：同时作为合并消息的 extra 落库，推送时带上新消息 ID
+type MergeForwardEvent struct {
+	EventHeader
+	MessageID uint64             `json:"message_id"`
+	Title     string             `json:"title"`
+	From      uint64             `json:"from"`
+	Count     int                `json:"count"`
+	Items     []MergeForwardItem `json:"items"`
+	Comment   string             `json:"comment,omitempty"`
+}
+
+func (*MergeForwardEvent) WsType() string { return WsEventMergeForward }
+
+// RecallEvent 消息撤回（启用房间事件落库时改为 notification，event_type=recall）
+type RecallEvent struct {
+	EventHeader
+	RecallType  uint8    `json:"recall_type"` // 撤回后的消息状态：撤回 / 对所有人删除
+	MessageIDs  []uint64 `json:"message_ids"`
+	RoomID      uint64   `json:"room_id"`
+	UserID      uint64   `json:"user_id"`     // 操作人
+	Placeholder bool     `json:"placeholder"` // 是否保留“消息已撤回”占位
+}
+
+func (*RecallEvent) WsType() string { return WsEventRecall }
+
+// FriendRequestEvent 收到好友申请
+type FriendRequestEvent struct {
+	EventHeader
+	RequestID uint64 `json:"request_id"`
+	FromUser  uint64 `json:"from_user"`
+	Message   string `json:"message"`
+	Source    string `json:"source"`  // 来源：search / qrcode / room 等
+	RoomID    uint64 `json:"room_id"` // 从群里添加时的群 ID
+}
+
+func (*FriendRequestEvent) WsType() string { return WsEventFriendRequest }
+
+// FriendAcceptedEvent 好友申请被同意（推给申请人）
+type FriendAcceptedEvent struct {
+	EventHeader
+	RequestID uint64 `json:"request_id"`
+	UserID    uint64 `json:"user_id"` // 同意的一方
+}
+
+func (*FriendAcceptedEvent) WsType() string { return WsEventFriendAccepted }
+
+// FriendRejectedEvent 好友申请被拒绝（推给申请人）
+type FriendRejectedEvent struct {
+	EventHeader
+	RequestID uint64 `json:"request_id"`
+	UserID    uint64 `json:"user_id"` // 拒绝的一方
+}
+
+func (*FriendRejectedEvent) WsType() string { return WsEventFriendRejected }
+
+// FriendExpiredEvent 好友申请超过有效期未处理，已自动过期（推给申请人）
+type FriendExpiredEvent struct {
+	EventHeader
+	RequestID uint64 `json:"request_id"`
+	UserID    uint64 `json:"user_id"` // 被申请的一方
+}
+
+func (*FriendExpiredEvent) WsType() string { return WsEventFriendExpired }
+
+// FriendDeletedEvent 好友关系解除（双方都会收到）
+type FriendDeletedEvent struct {
+	EventHeader
+	UserID             uint64 `json:"user_id"` // 对方
+	RoomID             uint64 `json:"room_id"` // 私聊房间
+	ConversationHidden bool   `json:"conversation_hidden"`
+	SendBlocked        bool   `json:"send_blocked"`
+}
+
+func (*FriendDeletedEvent) WsType() string { return WsEventFriendDeleted }
+
+// MessageUpdatedEvent 消息扩展更新（如链接预览抓取完成），客户端用新的 extra 替换
+type MessageUpdatedEvent struct {
+	EventHeader
+	RoomID    uint64          `json:"room_id"`
+	MessageID uint64          `json:"message_id"`
+	Extra     json.RawMessage `json:"extra"`
+}
+
+func (*MessageUpdatedEvent) WsType() string { return WsEventMessageUpdated }
+
+// PollUpdatedEvent 投票结果更新
+type PollUpdatedEvent struct {
+	EventHeader
+	RoomID uint64 `json:"room_id"`
+	Poll   any    `json:"poll"` // service.PollDTO
+}
+
+func (*PollUpdatedEvent) WsType() string { return WsEventPollUpdated }
+
+// AccountStatusEvent 账号状态变更（推给本人）
+type AccountStatusEvent struct {
+	EventHeader
+	UserID       uint64     `json:"user_id"`
+	Status       uint8      `json:"status"` // 0-正常 1-暂停 2-永久封禁
+	SuspendUntil *time.Time `json:"suspend_until"`
+	Reason       string     `json:"reason"`
+}
+
+func (*AccountStatusEvent) WsType() string { return WsEventAccountStatus }
+
+// MessageStatusEvent 消息已送达/已读（推给发送者）
+type MessageStatusEvent struct {
+	EventHeader
+	RoomID     uint64   `json:"room_id"`
+	MessageIDs []uint64 `json:"message_ids"`
+	Status     uint8    `json:"status"`
+	UserID     uint64   `json:"user_id"` // 送达/已读的接收方
+}
+
+func (*MessageStatusEvent) WsType() string { return WsEventMessageStatus }
+
+// ConversationReadEvent 会话已读游标前进（推给同一用户的其他设备）
+type ConversationReadEvent struct {
+	EventHeader
+	RoomID        uint64 `json:"room_id"`
+	LastReadMsgID uint64 `json:"last_read_msg_id"`
+}
+
+func (*ConversationReadEvent) WsType() string { return WsEventConversationRead }
+
+// TypingEvent 房间成员正在输入
+type TypingEvent struct {
+	EventHeader
+	RoomID uint64 `json:"room_id"`
+	UserID uint64 `json:"user_id"`
+}
+
+func (*TypingEvent) WsType() string { return WsEventTyping }
+
+// ViewOnceViewedEvent 阅后即焚消息已被查看、内容已清除
+type ViewOnceViewedEvent struct {
+	EventHeader
+	RoomID    uint64 `json:"room_id"`
+	MessageID uint64 `json:"message_id"`
+	ViewerID  uint64 `json:"viewer_id"`
+}
+
+func (*ViewOnceViewedEvent) WsType() string { return WsEventViewOnceViewed }
+
+// DataEvent 运维事件与全员广播：{"type": 事件, "data": 数据}，type 由调用方指定（如 admin.appeal）
+type DataEvent struct {
+	EventHeader
+	Data any `json:"data"`
+}
+
+// NewDataEvent 创建指定类型的 DataEvent
+func NewDataEvent(eventType string, data any) *DataEvent {
+	return &DataEvent{EventHeader: EventHeader{Type: eventType}, Data: data}
+}
+
+func (e *DataEvent) WsType() string { return e.Type }
+
+// PrefsUpdatedEvent 用户自定义设置（某个命名空间）已更新，data 为更新后的完整内容；
+// 客户端本地 version 不小于事件中的 version 时忽略（发起修改的设备已从接口拿到结果）
+type PrefsUpdatedEvent struct {
+	EventHeader
+	Namespace string          `json:"namespace"`
+	Version   uint64          `json:"version"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (*PrefsUpdatedEvent) WsType() string { return WsEventPrefsUpdated }
+
+// ConversationSettingsEvent 会话的客户端设置（背景、别名）已更新，带完整的最新值
+type ConversationSettingsEvent struct {
+	EventHeader
+	RoomID     uint64 `json:"room_id"`
+	Background string `json:"background"`
+	Alias      string `json:"alias"`
+	Version    uint64 `json:"version"`
+}
+
+func (*ConversationSettingsEvent) WsType() string { return WsEventConvSettings }
+
+// RoomOnlineEvent 房间在线人数变化（按 WithRoomOnlineFeed 的间隔合并，只推给房间内在线的连接）；
+// 成员列表通过 /room/online 获取
+type RoomOnlineEvent struct {
+	EventHeader
+	RoomID uint64 `json:"room_id"`
+	Online int    `json:"online"`
+}
+
+func (*RoomOnlineEvent) WsType() string { return WsEventRoomOnline }
+
+// EventCatalog 全部固定类型的下行事件（DataEvent 的 type 由调用方决定，不在其中），用于生成 JSON Schema
+var EventCatalog = []Event{
+	&SessionEvent{},
+	&RoomMessageEvent{},
+	&NotificationEvent{},
+	&ErrorEvent{},
+	&HeartbeatEvent{},
+	&ForwardEvent{},
+	&MergeForwardEvent{},
+	&RecallEvent{},
+	&FriendRequestEvent{},
+	&FriendAcceptedEvent{},
+	&FriendRejectedEvent{},
+	&FriendExpiredEvent{},
+	&FriendDeletedEvent{},
+	&MessageUpdatedEvent{},
+	&PollUpdatedEvent{},
+	&AccountStatusEvent{},
+	&MessageStatusEvent{},
+	&ConversationReadEvent{},
+	&TypingEvent{},
+	&ViewOnceViewedEvent{},
+	&PrefsUpdatedEvent{},
+	&ConversationSettingsEvent{},
+	&RoomOnlineEvent{},
+}
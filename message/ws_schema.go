@@ -0,0 +1,96 @@
+package message
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// EventSchemas 生成 EventCatalog 中各事件的 JSON Schema（draft-07），key 为事件类型。
+// 字段按 json tag 命名，未标 omitempty 的字段为 required；json.RawMessage / any 字段不限制类型。
+func EventSchemas() map[string]map[string]any {
+	out := make(map[string]map[string]any, len(EventCatalog))
+	for _, e := range EventCatalog {
+		s := typeSchema(reflect.TypeOf(e))
+		s["$schema"] = "http://json-schema.org/draft-07/schema#"
+		s["title"] = reflect.TypeOf(e).Elem().Name()
+		s["properties"].(map[string]any)["type"] = map[string]any{"const": e.WsType()}
+		out[e.WsType()] = s
+	}
+	return out
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		s := typeSchema(t.Elem())
+		// 指针字段可能为 null（事件本身除外）
+		if typ, ok := s["type"].(string); ok && typ != "object" {
+			s["type"] = []string{typ, "null"}
+		}
+		return s
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		var required []string
+		addStructFields(t, props, &required)
+		s := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default: // interface 等
+		return map[string]any{}
+	}
+}
+
+// addStructFields 展开字段（含匿名嵌入的结构体，与 encoding/json 一致）
+func addStructFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addStructFields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = typeSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package message
 
 // WS 上行消息类型
 const (
-	WsTypeMessage = "message"  // 默认：发送消息
-	WsTypeReadAck = "read_ack" // 已读回执（client -> server）
+	WsTypeMessage     = "message"      // 默认：发送消息
+	WsTypeReadAck     = "read_ack"     // 已读回执（client -> server）
+	WsTypeDeliveryAck = "delivery_ack" // 送达回执（client -> server）
+	WsTypeTyping      = "typing"       // 正在输入（client -> server，转发给房间其他成员，不落库）
+	WsTypeHeartbeat   = "heartbeat"    // 应用层心跳（client -> server，计入活跃并原样回一条 heartbeat）
 )
 
 // ReadAckReq 已读回执：表示当前用户在某房间已读到某条消息。
@@ -14,3 +17,16 @@ type ReadAckReq struct {
 	LastReadMsgID uint64 `json:"last_read_msg_id"` // 最后已读消息 ID
 	PacketID      string `json:"packet_id"`        // 可选：客户端匹配 ack
 }
+
+// DeliveryAckReq 送达回执：客户端收到房间消息推送后上报（可批量）。
+type DeliveryAckReq struct {
+	Type       string   `json:"type"`        // delivery_ack
+	RoomID     uint64   `json:"room_id"`     // 房间 ID
+	MessageIDs []uint64 `json:"message_ids"` // 已收到的消息 ID
+}
+
+// TypingReq 正在输入：客户端输入时节流上报（建议每 3 秒最多一次）。
+type TypingReq struct {
+	Type   string `json:"type"`    // typing
+	RoomID uint64 `json:"room_id"` // 房间 ID
+}
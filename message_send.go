@@ -0,0 +1,77 @@
+package chat_sdk
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/service"
+	"gorm.io/gorm"
+)
+
+// sendUserMessage 用户发消息的完整流程，WS 与 HTTP（POST /message/send）共用：
+// 房间存在 -> 发送者是成员 -> 私聊未拉黑 -> 客服会话未结束 -> 保存（内部处理禁言、extra 校验）-> 推进发送者已读 -> 扇出、SLA 统计、自动回复。
+// nickname/avatar 为推送给成员的发送者资料；received 为收到请求的时间（SLA 统计起点）。
+func sendUserMessage(senderID uint64, req message.Req, nickname, avatar string, received time.Time) (*models.Message, error) {
+	room, err := Instance.RoomService.GetRoomByID(req.SendTo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrRoomNotFound
+		}
+		return nil, err
+	}
+	// 成员存在性校验（防止退群/被踢/被转接还继续发）
+	ok, err := isRoomMember(room.ID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, service.ErrNotRoomMember
+	}
+	// 私聊拉黑校验（基于 friend.status=2）
+	if room.Type == 1 {
+		blocked, err := isBlockedPrivate(room.ID, senderID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, service.ErrPrivateBlocked
+		}
+	}
+	// 客服会话结束后不能再发
+	if room.Type == models.RoomTypeHelpDesk {
+		open, err := Instance.HelpDeskService.IsSessionOpen(room.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !open {
+			return nil, service.ErrHelpDeskClosed
+		}
+	}
+	savedMsg, err := Instance.MsgService.SaveMessage(room.ID, senderID, req.SendContent, req.SendType, req.Extra)
+	if err != nil {
+		return nil, err
+	}
+	persisted := time.Now()
+
+	// 自己发的消息视为已读（写入 session，由周期/断线 flush 落库）
+	if sess := Instance.WsServer.userSession(senderID); sess != nil {
+		sess.mergeRead(room.ID, savedMsg.ID)
+	}
+	// 无论私聊/群聊都带上 sender 昵称/头像，客户端无需再查
+	recipients := pushRoomMessage(room, savedMsg, req.PacketID, nickname, avatar)
+	Instance.DeliverySLA.Observe(service.DeliveryTimings{
+		MessageID: savedMsg.ID, RoomID: room.ID, SenderID: senderID, Recipients: recipients,
+		Received: received, Persisted: persisted, FannedOut: time.Now(),
+	})
+	go runAutoReply(room, savedMsg)
+	return savedMsg, nil
+}
+
+// userSession 用户的 session（在线或仍在断线保留窗口内），没有返回 nil
+func (h *WsServer) userSession(userID uint64) *UserSession {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Sessions[userID]
+}
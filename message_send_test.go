@@ -0,0 +1,60 @@
+package chat_sdk
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+// 推送的 extra 取自落库后的消息：@ 列表中的非成员被剔除，与历史消息一致
+func TestSendUserMessage_PushesNormalizedExtra(t *testing.T) {
+	e, err := NewTestEngine()
+	if err != nil {
+		t.Fatalf("NewTestEngine: %v", err)
+	}
+	defer e.Close()
+	report, err := e.ProvisionService.Provision(service.ProvisionRequest{Users: []service.ProvisionUser{
+		{Username: "alice"}, {Username: "bob"}, {Username: "carol"},
+	}}, false)
+	if err != nil || report.Created != 3 {
+		t.Fatalf("provision: %+v %v", report, err)
+	}
+	aliceID, bobID, carolID := report.Rows[0].ID, report.Rows[1].ID, report.Rows[2].ID
+	room, err := e.RoomService.CreateGroupRoom("g", aliceID, []uint64{bobID})
+	if err != nil {
+		t.Fatalf("CreateGroupRoom: %v", err)
+	}
+
+	saved, err := sendUserMessage(aliceID, message.Req{
+		Type:        message.WsTypeMessage,
+		SendTo:      room.ID,
+		SendType:    1,
+		SendContent: "@bob @carol",
+		Extra:       message.Extra{MentionedUsers: []uint64{bobID, carolID}},
+	}, "alice", "", time.Now())
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	var stored message.Extra
+	_ = json.Unmarshal(saved.Extra, &stored)
+	pushed := e.Pushed(bobID)
+	if len(pushed) == 0 {
+		t.Fatal("nothing pushed to bob")
+	}
+	var ev message.RoomMessageEvent
+	if err := json.Unmarshal(pushed[len(pushed)-1], &ev); err != nil {
+		t.Fatalf("decode push: %v", err)
+	}
+	var live message.Extra
+	_ = json.Unmarshal(ev.Extra, &live)
+	if len(live.MentionedUsers) != 1 || live.MentionedUsers[0] != bobID {
+		t.Fatalf("pushed mentions = %v, want [%d]", live.MentionedUsers, bobID)
+	}
+	if len(stored.MentionedUsers) != 1 || stored.MentionedUsers[0] != bobID {
+		t.Fatalf("stored mentions = %v", stored.MentionedUsers)
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader 运维/管理接口令牌请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// ComplianceTokenHeader 合规导出接口令牌请求头
+const ComplianceTokenHeader = "X-Compliance-Token"
+
+/*
+	GinAdminAuthMiddleware 管理接口鉴权中间件：
+
+- 从 X-Admin-Token 读取令牌，与配置的 token 常量时间比较
+- token 未配置时管理接口一律拒绝
+
+使用：adminGroup.Use(middleware.GinAdminAuthMiddleware(token))
+*/
+func GinAdminAuthMiddleware(token string) gin.HandlerFunc {
+	return staticTokenMiddleware(AdminTokenHeader, token, "admin")
+}
+
+// GinComplianceAuthMiddleware 合规导出接口鉴权中间件（X-Compliance-Token），规则同 GinAdminAuthMiddleware；
+// 合规角色与运维角色使用不同令牌，管理员令牌不能导出归档。
+func GinComplianceAuthMiddleware(token string) gin.HandlerFunc {
+	return staticTokenMiddleware(ComplianceTokenHeader, token, "compliance")
+}
+
+func staticTokenMiddleware(header, token, role string) gin.HandlerFunc {
+	token = strings.TrimSpace(token)
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodePermissionDeny), response.Response{
+				Code: response.CodePermissionDeny,
+				Msg:  role + " api disabled",
+			})
+			return
+		}
+		got := strings.TrimSpace(c.GetHeader(header))
+		if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeTokenInvalid), response.Response{
+				Code: response.CodeTokenInvalid,
+				Msg:  "invalid " + role + " token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ContextBotIDKey gin context 里保存机器人 id 的 key
+	ContextBotIDKey = "bot_id"
+	// BotKeyHeader 机器人 API Key 请求头
+	BotKeyHeader = "X-Bot-Key"
+)
+
+/*
+	GinBotAuthMiddleware 机器人鉴权中间件：
+
+- 优先从 X-Bot-Key: <api_key> 读取
+- 如果没有，再从 Authorization: Bot <api_key> 读取
+- 校验成功后写入 user_id（机器人对应的用户ID）与 bot_id，后续 handler 与普通用户一致
+
+使用：botGroup.Use(middleware.GinBotAuthMiddleware(botService))
+*/
+func GinBotAuthMiddleware(bot *service.BotService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if bot == nil {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeInternalError), response.Response{
+				Code: response.CodeInternalError,
+				Msg:  "bot service is nil",
+			})
+			return
+		}
+
+		key := strings.TrimSpace(c.GetHeader(BotKeyHeader))
+		if key == "" {
+			parts := strings.SplitN(strings.TrimSpace(c.GetHeader("Authorization")), " ", 2)
+			if len(parts) == 2 && strings.EqualFold(parts[0], "Bot") {
+				key = strings.TrimSpace(parts[1])
+			}
+		}
+		if key == "" {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeTokenInvalid), response.Response{
+				Code: response.CodeTokenInvalid,
+				Msg:  "missing api key",
+			})
+			return
+		}
+
+		b, err := bot.AuthenticateAPIKey(key)
+		if err != nil {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeTokenInvalid), response.Response{
+				Code: response.CodeTokenInvalid,
+				Msg:  err.Error(),
+			})
+			return
+		}
+
+		c.Set(ContextUserIDKey, b.UserID)
+		c.Set(ContextBotIDKey, b.ID)
+		c.Next()
+	}
+}
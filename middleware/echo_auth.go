@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/labstack/echo/v4"
+)
+
+/*
+	EchoAuthMiddleware Echo 鉴权中间件，行为同 GinAuthMiddleware：
+
+- 优先从 Authorization: Bearer <token> 读取，其次 query（默认 token=xxx）
+- 校验通过后 c.Set(user_id, uint64) / c.Set(token, string)
+
+使用：e.Use(middleware.EchoAuthMiddleware(authService, nil))
+*/
+func EchoAuthMiddleware(auth *service.AuthService, opt *AuthOptions) echo.MiddlewareFunc {
+	cfg := opt.withDefaults()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if auth == nil {
+				return c.JSON(response.HTTPStatus(response.CodeInternalError), response.Error(response.CodeInternalError, "auth service is nil"))
+			}
+
+			token := bearerToken(c.Request().Header.Get(cfg.HeaderKey))
+			if token == "" {
+				token = strings.TrimSpace(c.QueryParam(cfg.QueryKey))
+			}
+			if token == "" {
+				return c.JSON(response.HTTPStatus(response.CodeTokenInvalid), response.Error(response.CodeTokenInvalid, "missing token"))
+			}
+
+			uid, err := auth.Authenticate(c.Request().Context(), token)
+			if err != nil {
+				return c.JSON(response.HTTPStatus(response.CodeTokenInvalid), response.Error(response.CodeTokenInvalid, err.Error()))
+			}
+
+			c.Set(cfg.UserIDKey, uid)
+			c.Set(cfg.TokenKey, token)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+	FiberAuthMiddleware Fiber 鉴权中间件，行为同 GinAuthMiddleware：
+
+- 优先从 Authorization: Bearer <token> 读取，其次 query（默认 token=xxx）
+- 校验通过后 c.Locals(user_id, uint64) / c.Locals(token, string)
+
+使用：app.Use(middleware.FiberAuthMiddleware(authService, nil))
+*/
+func FiberAuthMiddleware(auth *service.AuthService, opt *AuthOptions) fiber.Handler {
+	cfg := opt.withDefaults()
+
+	return func(c *fiber.Ctx) error {
+		if auth == nil {
+			return c.Status(response.HTTPStatus(response.CodeInternalError)).JSON(response.Error(response.CodeInternalError, "auth service is nil"))
+		}
+
+		token := bearerToken(c.Get(cfg.HeaderKey))
+		if token == "" {
+			token = strings.TrimSpace(c.Query(cfg.QueryKey))
+		}
+		if token == "" {
+			return c.Status(response.HTTPStatus(response.CodeTokenInvalid)).JSON(response.Error(response.CodeTokenInvalid, "missing token"))
+		}
+
+		uid, err := auth.Authenticate(c.UserContext(), token)
+		if err != nil {
+			return c.Status(response.HTTPStatus(response.CodeTokenInvalid)).JSON(response.Error(response.CodeTokenInvalid, err.Error()))
+		}
+
+		c.Locals(cfg.UserIDKey, uid)
+		c.Locals(cfg.TokenKey, token)
+		return c.Next()
+	}
+}
@@ -1,8 +1,6 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/cydxin/chat-sdk/response"
@@ -63,7 +61,7 @@ func GinAuthMiddleware(auth *service.AuthService, opt *AuthOptions) gin.HandlerF
 	return func(c *gin.Context) {
 		if auth == nil {
 			c.Header("Content-Type", "application/json")
-			c.AbortWithStatusJSON(http.StatusInternalServerError, response.Response{
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeInternalError), response.Response{
 				Code: response.CodeInternalError,
 				Msg:  "auth service is nil",
 			})
@@ -71,15 +69,7 @@ func GinAuthMiddleware(auth *service.AuthService, opt *AuthOptions) gin.HandlerF
 		}
 
 		// 1) header bearer
-		token := ""
-		ah := strings.TrimSpace(c.GetHeader(cfg.HeaderKey))
-		if ah != "" {
-			parts := strings.SplitN(ah, " ", 2)
-			if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
-				fmt.Println(parts[1])
-				token = strings.TrimSpace(parts[1])
-			}
-		}
+		token := bearerToken(c.GetHeader(cfg.HeaderKey))
 
 		// 2) query fallback
 		if token == "" {
@@ -88,7 +78,7 @@ func GinAuthMiddleware(auth *service.AuthService, opt *AuthOptions) gin.HandlerF
 
 		if token == "" {
 			c.Header("Content-Type", "application/json")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, response.Response{
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeTokenInvalid), response.Response{
 				Code: response.CodeTokenInvalid,
 				Msg:  "missing token",
 			})
@@ -98,7 +88,7 @@ func GinAuthMiddleware(auth *service.AuthService, opt *AuthOptions) gin.HandlerF
 		uid, err := auth.Authenticate(c.Request.Context(), token)
 		if err != nil {
 			c.Header("Content-Type", "application/json")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, response.Response{
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeTokenInvalid), response.Response{
 				Code: response.CodeTokenInvalid,
 				Msg:  err.Error(),
 			})
@@ -110,3 +100,12 @@ func GinAuthMiddleware(auth *service.AuthService, opt *AuthOptions) gin.HandlerF
 		c.Next()
 	}
 }
+
+// bearerToken 解析 "Bearer <token>"，格式不对返回空串
+func bearerToken(header string) string {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+		return strings.TrimSpace(parts[1])
+	}
+	return ""
+}
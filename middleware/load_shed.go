@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	GinLoadShedMiddleware 过载保护中间件，挂在非核心接口（动态、搜索、统计等）上：
+
+- 过载检测（service.LoadShedder）判定过载期间直接返回 503 + Retry-After（秒），不进入 handler
+- ls 为 nil（未开启 WithLoadShedding）时直接放行
+
+使用：momentAPI.Use(middleware.GinLoadShedMiddleware(loadShedder))
+*/
+func GinLoadShedMiddleware(ls *service.LoadShedder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		shedding, retryAfter := ls.Shedding()
+		if !shedding {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.AbortWithStatusJSON(response.HTTPStatus(response.CodeServerBusy), response.Response{
+			Code: response.CodeServerBusy,
+			Msg:  "server busy, retry later",
+		})
+	}
+}
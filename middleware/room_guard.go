@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ContextRoomIDKey gin context 里保存已校验的 room id 的 key
+	ContextRoomIDKey = "room_id"
+	// ContextRoomRoleKey gin context 里保存调用方在该房间角色的 key（models.RoomRole*）
+	ContextRoomRoleKey = "room_role"
+)
+
+/*
+	GinRoomMemberGuard 房间权限中间件（需挂在 GinAuthMiddleware 之后）：
+
+- 从 query ?room_id= 读取房间，没有时再从 JSON body 或表单（含 multipart）的 room_id 读取（body 会回填，handler 可照常绑定）
+- 调用方不是房间成员或角色低于 minRole（models.RoomRole*）时拒绝
+- 校验通过后写入 room_id 与 room_role
+
+使用：roomGroup.GET("/group/info", middleware.GinRoomMemberGuard(roomService, models.RoomRoleMember), handler)
+*/
+func GinRoomMemberGuard(rooms *service.RoomService, minRole uint8) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rooms == nil {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeInternalError), response.Response{
+				Code: response.CodeInternalError,
+				Msg:  "room service is nil",
+			})
+			return
+		}
+		uid, ok := c.Get(ContextUserIDKey)
+		userID, _ := uid.(uint64)
+		if !ok || userID == 0 {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeTokenInvalid), response.Response{
+				Code: response.CodeTokenInvalid,
+				Msg:  "user_id not found",
+			})
+			return
+		}
+
+		roomID := requestRoomID(c)
+		if roomID == 0 {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodeParamError), response.Response{
+				Code: response.CodeParamError,
+				Msg:  "room_id is required",
+			})
+			return
+		}
+
+		role, err := rooms.RequireRole(roomID, userID, minRole)
+		if err != nil {
+			code, msg := response.CodeInternalError, err.Error()
+			var se *service.Error
+			if errors.As(err, &se) {
+				code = se.Code
+				if lang := response.ParseAcceptLanguage(c.GetHeader("Accept-Language")); lang != response.DefaultLang {
+					msg = se.Localize(lang)
+				}
+			}
+			c.AbortWithStatusJSON(response.HTTPStatus(code), response.Response{Code: code, Msg: msg})
+			return
+		}
+
+		c.Set(ContextRoomIDKey, roomID)
+		c.Set(ContextRoomRoleKey, role)
+		c.Next()
+	}
+}
+
+// requestRoomID 依次从 query、JSON / 表单 body 解析 room_id，解析失败返回 0
+func requestRoomID(c *gin.Context) uint64 {
+	if v := strings.TrimSpace(c.Query("room_id")); v != "" {
+		id, _ := strconv.ParseUint(v, 10, 64)
+		return id
+	}
+	if c.Request.Body == nil {
+		return 0
+	}
+	switch c.ContentType() {
+	case "multipart/form-data", "application/x-www-form-urlencoded":
+		// 表单会被 gin 解析并缓存，handler 仍可读取
+		id, _ := strconv.ParseUint(strings.TrimSpace(c.PostForm("room_id")), 10, 64)
+		return id
+	case "application/json":
+		// 下面读取后回填
+	default:
+		return 0
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0
+	}
+	var req struct {
+		RoomID uint64 `json:"room_id"`
+	}
+	_ = json.Unmarshal(body, &req)
+	return req.RoomID
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net"
+
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	GinSecurityMiddleware IP 访问控制中间件（CIDR 黑白名单 + 按国家屏蔽）：
+
+- 客户端 IP 取 gin 的 ClientIP()（受 gin 的 TrustedProxies 配置影响）
+- 被拒绝时返回 403
+
+使用：r.Use(middleware.GinSecurityMiddleware(securityService))
+*/
+func GinSecurityMiddleware(sec *service.SecurityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sec == nil {
+			c.Next()
+			return
+		}
+		if !sec.Check(net.ParseIP(c.ClientIP()), c.Request.URL.Path) {
+			c.AbortWithStatusJSON(response.HTTPStatus(response.CodePermissionDeny), response.Response{
+				Code: response.CodePermissionDeny,
+				Msg:  "access denied",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// 账号状态（User.Status）
+const (
+	UserStatusActive    = 0 // 正常
+	UserStatusSuspended = 1 // 暂停（到期自动恢复）
+	UserStatusBanned    = 2 // 永久封禁
+)
+
+// 申诉状态
+const (
+	AppealStatusPending  = 0
+	AppealStatusApproved = 1
+	AppealStatusRejected = 2
+)
+
+// UserAppeal 封禁申诉
+type UserAppeal struct {
+	ID        uint64 `gorm:"primarykey"`
+	UserID    uint64 `gorm:"index;not null"`
+	Content   string `gorm:"size:1000;not null"`           // 申诉内容
+	Status    uint8  `gorm:"type:tinyint;index;default:0"` // 0-待处理 1-通过(解封) 2-驳回
+	Reply     string `gorm:"size:500"`                     // 处理意见
+	HandledAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (UserAppeal) TableName() string { return prefix + "user_appeal" }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+	AutoReplyMatchContains = 1 // 包含关键字
+	AutoReplyMatchExact    = 2 // 完全匹配
+	AutoReplyMatchRegex    = 3 // 正则
+)
+
+// AutoReplyRule 自动回复规则
+// - 房间规则：RoomID>0 且 BotID=0，由群主/管理员配置，回复以 ResponderID（配置人）身份发送
+// - 机器人规则：BotID>0，RoomID=0 表示机器人所在的所有房间生效，回复以机器人身份发送
+type AutoReplyRule struct {
+	ID          uint64 `gorm:"primarykey"`
+	RoomID      uint64 `gorm:"index;default:0"`        // 房间 ID，0 表示不限房间（仅机器人规则）
+	BotID       uint64 `gorm:"index;default:0"`        // 机器人 ID，0 表示房间规则
+	ResponderID uint64 `gorm:"index;not null"`         // 回复消息的发送者（机器人用户ID/配置人）
+	CreatorID   uint64 `gorm:"index;not null"`         // 创建者
+	MatchType   uint8  `gorm:"type:tinyint;default:1"` // 匹配方式: 1-包含 2-完全匹配 3-正则
+	Pattern     string `gorm:"size:500;not null"`      // 关键字/正则
+	Reply       string `gorm:"type:text;not null"`     // 回复内容
+	ReplyType   uint8  `gorm:"type:tinyint;default:1"` // 回复消息类型（同 Message.Type）
+	Priority    int    `gorm:"default:0"`              // 优先级，越大越先匹配
+	CooldownSec int    `gorm:"default:3"`              // 同一房间内两次触发的最小间隔（秒）
+	Enabled     bool   `gorm:"default:true"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (AutoReplyRule) TableName() string { return prefix + "auto_reply_rule" }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Bot 机器人账号表
+// 机器人本身也是一个 User（IsBot=true），这样可以复用房间成员/消息/会话等全部能力；
+// 本表只保存机器人专属的信息：归属人、API Key、Webhook 投递配置。
+type Bot struct {
+	ID      uint64 `gorm:"primarykey"`
+	UserID  uint64 `gorm:"uniqueIndex;not null"` // 对应 User.ID
+	OwnerID uint64 `gorm:"index;not null"`       // 创建者
+	Name    string `gorm:"size:100;not null"`
+
+	// APIKeyHash API Key 的 sha256（不落明文）
+	APIKeyHash string `gorm:"size:64;uniqueIndex;not null"`
+	// APIKeyPrefix API Key 前几位，便于用户在列表里辨认
+	APIKeyPrefix string `gorm:"size:16"`
+
+	// WebhookURL 房间内有新消息时 POST 到该地址（为空则只能通过 WS 接收）
+	WebhookURL string `gorm:"size:500"`
+	// WebhookSecret 用于 HMAC-SHA256 签名，放在 X-Chat-Signature 头
+	WebhookSecret string `gorm:"size:64"`
+
+	IsActive  bool `gorm:"default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (Bot) TableName() string { return prefix + "bot" }
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// RoomCheckIn 群签到记录（每人每天一条）
+type RoomCheckIn struct {
+	ID        uint64 `gorm:"primarykey"`
+	RoomID    uint64 `gorm:"index:idx_room_user_date,unique;not null"`
+	UserID    uint64 `gorm:"index:idx_room_user_date,unique;not null"`
+	Date      string `gorm:"index:idx_room_user_date,unique;size:10;not null"` // 签到日期 YYYY-MM-DD
+	Streak    int    `gorm:"default:1"`                                        // 当天签到后的连续天数
+	CreatedAt time.Time
+}
+
+func (RoomCheckIn) TableName() string { return prefix + "room_checkin" }
+
+// RoomCheckInStat 群成员签到统计（排行榜用）
+type RoomCheckInStat struct {
+	ID            uint64 `gorm:"primarykey"`
+	RoomID        uint64 `gorm:"index:idx_checkin_stat_room_user,unique;not null"`
+	UserID        uint64 `gorm:"index:idx_checkin_stat_room_user,unique;not null"`
+	TotalDays     int    `gorm:"default:0"` // 累计签到天数
+	CurrentStreak int    `gorm:"default:0"` // 当前连续天数
+	MaxStreak     int    `gorm:"default:0"` // 历史最长连续
+	LastDate      string `gorm:"size:10"`   // 最后签到日期 YYYY-MM-DD
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (RoomCheckInStat) TableName() string { return prefix + "room_checkin_stat" }
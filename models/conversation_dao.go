@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConversationDAO 封装 Conversation 相关的数据库操作
+type ConversationDAO struct {
+	db *gorm.DB
+}
+
+// NewConversationDAO 创建 ConversationDAO 实例
+func NewConversationDAO(db *gorm.DB) *ConversationDAO {
+	return &ConversationDAO{db: db}
+}
+
+// UpdateLastReadMsgID 更新已读游标：
+// - last_read_msg_id 取更大值（避免乱序回执/多实例 flush 覆盖）
+// - unread_count 按已读游标之后的消息数重算（flush 前到达的新消息仍计为未读）
+func (dao *ConversationDAO) UpdateLastReadMsgID(userID, roomID, lastRead uint64) error {
+	return dao.db.Model(&Conversation{}).
+		Where("user_id = ? AND room_id = ?", userID, roomID).
+		Updates(map[string]any{
+			"last_read_msg_id": gorm.Expr("CASE WHEN last_read_msg_id IS NULL OR last_read_msg_id < ? THEN ? ELSE last_read_msg_id END", lastRead, lastRead),
+			"unread_count": gorm.Expr("(SELECT COUNT(1) FROM "+Message{}.TableName()+
+				" m WHERE m.room_id = ? AND m.id > GREATEST(COALESCE(last_read_msg_id, 0), ?) AND m.deleted_at IS NULL)", roomID, lastRead),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// FindLastReads 返回用户可见会话的已读游标（room_id -> last_read_msg_id，NULL 记为 0）
+func (dao *ConversationDAO) FindLastReads(userID uint64) (map[uint64]uint64, error) {
+	type row struct {
+		RoomID        uint64
+		LastReadMsgID *uint64
+	}
+	var rows []row
+	if err := dao.db.Model(&Conversation{}).
+		Select("room_id, last_read_msg_id").
+		Where("user_id = ? AND is_visible = ?", userID, true).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint64]uint64, len(rows))
+	for _, r := range rows {
+		if r.RoomID == 0 {
+			continue
+		}
+		if r.LastReadMsgID == nil {
+			out[r.RoomID] = 0
+			continue
+		}
+		out[r.RoomID] = *r.LastReadMsgID
+	}
+	return out, nil
+}
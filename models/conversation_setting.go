@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ConversationSetting 用户对某个会话的客户端设置（聊天背景、对方/群的自定义别名），单独成表，不参与会话列表与消息写入。
+// Version 每次写入加 1，用于多设备同步与并发修改检测。
+type ConversationSetting struct {
+	ID         uint64 `gorm:"primarykey"`
+	UserID     uint64 `gorm:"uniqueIndex:idx_conv_setting;not null"`
+	RoomID     uint64 `gorm:"uniqueIndex:idx_conv_setting;not null"`
+	Background string `gorm:"size:500"` // 聊天背景（图片 URL 或客户端内置背景标识）
+	Alias      string `gorm:"size:100"` // 自定义别名（私聊为对方，群聊为群），只对自己生效
+	Version    uint64 `gorm:"not null;default:0"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (ConversationSetting) TableName() string { return prefix + "conversation_setting" }
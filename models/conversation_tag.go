@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ConversationTag 用户给会话打的标签（工作、家人、重要未读等），只对自己可见；一个会话可以有多个标签。
+// 标签没有单独的定义表：重命名/删除即批量修改该用户下同名的行。
+type ConversationTag struct {
+	ID        uint64 `gorm:"primarykey"`
+	UserID    uint64 `gorm:"uniqueIndex:idx_conv_tag,priority:1;index:idx_conv_tag_user,priority:1;not null"`
+	RoomID    uint64 `gorm:"uniqueIndex:idx_conv_tag,priority:2;not null"`
+	Tag       string `gorm:"size:20;uniqueIndex:idx_conv_tag,priority:3;index:idx_conv_tag_user,priority:2;not null"`
+	CreatedAt time.Time
+}
+
+func (ConversationTag) TableName() string { return prefix + "conversation_tag" }
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// RoomTypeHelpDesk 客服会话房间类型（Room.Type）
+const RoomTypeHelpDesk = 3
+
+const (
+	HelpDeskStatusWaiting = 1 // 排队中（暂无可用客服）
+	HelpDeskStatusActive  = 2 // 接待中
+	HelpDeskStatusClosed  = 3 // 已结束
+)
+
+// HelpDeskAgent 客服坐席
+type HelpDeskAgent struct {
+	ID          uint64 `gorm:"primarykey"`
+	UserID      uint64 `gorm:"uniqueIndex;not null"` // 客服对应的用户 ID
+	Enabled     bool   `gorm:"default:true"`         // 是否接待
+	MaxSessions int    `gorm:"default:0"`            // 同时接待上限，0 表示不限
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (HelpDeskAgent) TableName() string { return prefix + "helpdesk_agent" }
+
+// HelpDeskSession 客服会话（一次访客咨询对应一个 type=3 的房间）
+type HelpDeskSession struct {
+	ID            uint64     `gorm:"primarykey"`
+	RoomID        uint64     `gorm:"uniqueIndex;not null"`         // 房间 ID (对应 Room.ID)
+	VisitorID     uint64     `gorm:"index;not null"`               // 访客用户 ID
+	AgentID       uint64     `gorm:"index;default:0"`              // 当前接待客服，0 表示排队中
+	Status        uint8      `gorm:"type:tinyint;default:1;index"` // 状态: 1-排队 2-接待中 3-已结束
+	Rating        uint8      `gorm:"type:tinyint;default:0"`       // 评分 1-5，0 表示未评价
+	RatingComment string     `gorm:"size:500"`                     // 评价内容
+	ClosedBy      uint64     `gorm:"default:0"`                    // 结束操作人
+	ClosedAt      *time.Time // 结束时间
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (HelpDeskSession) TableName() string { return prefix + "helpdesk_session" }
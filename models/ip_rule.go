@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// IP 访问规则类型
+const (
+	IPRuleAllow   = "allow"   // 白名单 CIDR（配置了任意白名单后，只允许白名单内 IP）
+	IPRuleDeny    = "deny"    // 黑名单 CIDR
+	IPRuleCountry = "country" // 按国家/地区屏蔽（ISO 3166-1 alpha-2，需要注入 GeoIP 解析器）
+)
+
+// IPRule IP 访问规则
+type IPRule struct {
+	ID        uint64 `gorm:"primarykey"`
+	Kind      string `gorm:"size:16;index;not null"` // allow/deny/country
+	Value     string `gorm:"size:64;not null"`       // CIDR（单个 IP 也可）或国家代码
+	Note      string `gorm:"size:255"`               // 备注
+	Enabled   bool   `gorm:"default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (IPRule) TableName() string { return prefix + "ip_rule" }
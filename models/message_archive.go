@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// MessageArchive 合规归档（只追加）：消息写入、撤回、删除、销毁前的完整快照，业务代码从不修改或删除。
+// 同一条消息会有多条记录（created、recalled、purged 等），按 id 顺序即事件顺序。
+type MessageArchive struct {
+	ID           uint64 `gorm:"primarykey"`
+	MessageID    uint64 `gorm:"index;not null"`
+	RoomID       uint64 `gorm:"index:idx_archive_room;not null"`
+	SenderID     uint64 `gorm:"index;not null"`
+	Event        string `gorm:"size:32;not null"` // created / imported / recalled / deleted / view_once_cleared / expired / purged
+	OperatorID   uint64 `gorm:"default:0"`        // 触发事件的用户，0 为系统（定时清理等）
+	ReplyToMsgID *uint64
+	Type         uint8          `gorm:"type:tinyint"`
+	Content      string         `gorm:"type:text;not null"`
+	Extra        datatypes.JSON `gorm:"type:json"`
+	IsSystem     bool
+	Status       uint8     `gorm:"type:tinyint"` // 事件发生前的消息状态
+	MessageAt    time.Time // 消息的原始发送时间
+	CreatedAt    time.Time `gorm:"index:idx_archive_room"` // 归档时间
+}
+
+func (MessageArchive) TableName() string { return prefix + "message_archive" }
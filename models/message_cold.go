@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// MessageCold 冷消息表（WithMessageColdStorage 开启）：超过冷却期的消息整行从 message 表搬到这里，保持原 ID。
+// 与 message 表结构一致，只读：历史消息、详情、会话内搜索、定位等读接口在热表不足时透明地查询冷表，
+// 撤回、编辑等写操作不再作用于冷消息。（message_archive 为合规归档表，与此无关）
+type MessageCold struct {
+	ID           uint64         `gorm:"primarykey;autoIncrement:false"`
+	RoomID       uint64         `gorm:"index:idx_cold_room_created,priority:1;not null"`
+	SenderID     uint64         `gorm:"index;not null"`
+	Seq          uint64         `gorm:"default:0"`
+	ReplyToMsgID *uint64        `gorm:"index"`
+	Type         uint8          `gorm:"type:tinyint;default:1"`
+	Content      string         `gorm:"type:text;not null"`
+	Extra        datatypes.JSON `gorm:"column:extra;type:json"`
+	IsSystem     bool           `gorm:"default:false"`
+	IsEncrypted  bool           `gorm:"default:false"`
+	IsViewOnce   bool           `gorm:"default:false"`
+	Status       uint8          `gorm:"type:tinyint;default:0"`
+	CreatedAt    time.Time      `gorm:"index:idx_cold_room_created,priority:2"`
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+
+	Sender User `gorm:"foreignKey:SenderID"`
+}
+
+func (MessageCold) TableName() string {
+	return prefix + "message_cold"
+}
+
+// NewMessageCold 把热表消息转为冷表行（原样保留 ID 与时间）
+func NewMessageCold(m *Message) MessageCold {
+	return MessageCold{
+		ID: m.ID, RoomID: m.RoomID, SenderID: m.SenderID, Seq: m.Seq, ReplyToMsgID: m.ReplyToMsgID, Type: m.Type,
+		Content: m.Content, Extra: m.Extra, IsSystem: m.IsSystem, IsEncrypted: m.IsEncrypted, IsViewOnce: m.IsViewOnce,
+		Status: m.Status, CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt, DeletedAt: m.DeletedAt,
+	}
+}
+
+// ToMessage 转回 Message，供与热表共用的读取与 DTO 转换
+func (m *MessageCold) ToMessage() Message {
+	return Message{
+		ID: m.ID, RoomID: m.RoomID, SenderID: m.SenderID, Seq: m.Seq, ReplyToMsgID: m.ReplyToMsgID, Type: m.Type,
+		Content: m.Content, Extra: m.Extra, IsSystem: m.IsSystem, IsEncrypted: m.IsEncrypted, IsViewOnce: m.IsViewOnce,
+		Status: m.Status, CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt, DeletedAt: m.DeletedAt, Sender: m.Sender,
+	}
+}
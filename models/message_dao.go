@@ -58,9 +58,14 @@ func (dao *MessageDAO) DeleteForUser(userID, messageID uint64) error {
 	err := dao.db.Where("user_id = ? AND message_id = ?", userID, messageID).First(&status).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var roomID uint64
+			if err := dao.db.Model(&Message{}).Where("id = ?", messageID).Pluck("room_id", &roomID).Error; err != nil {
+				return err
+			}
 			status = MessageStatus{
-				UserID:    (userID),
-				MessageID: (messageID),
+				UserID:    userID,
+				MessageID: messageID,
+				RoomID:    roomID,
 				IsDeleted: true,
 			}
 			return dao.db.Create(&status).Error
@@ -75,16 +80,14 @@ func (dao *MessageDAO) DeleteForEveryone(messageID uint64) error {
 	return dao.UpdateStatus(messageID, MessageStatusBothDeleted)
 }
 
-// FindByRoomIDForUser 获取房间消息列表 (过滤掉用户已删除的消息)
+// FindByRoomIDForUser 获取房间消息列表 (过滤掉双删与用户已单删的消息)
 func (dao *MessageDAO) FindByRoomIDForUser(roomID, userID uint64, limit, offset int) ([]Message, error) {
 	var messages []Message
-	err := dao.db.Table("message").
-		Select("message.*").
-		Joins("LEFT JOIN message_statuses ON message_statuses.message_id = message.id AND message_statuses.user_id = ?", userID).
-		Where("message.room_id = ?", roomID).
-		Where("message.status != ?", MessageStatusBothDeleted).
-		Where("message_statuses.is_deleted IS NULL OR message_statuses.is_deleted = ?", false).
-		Order("message.created_at DESC").
+	msgTable := Message{}.TableName()
+	err := dao.db.Model(&Message{}).
+		Where("room_id = ? AND status <> ?", roomID, MessageStatusBothDeleted).
+		Where("NOT EXISTS (SELECT 1 FROM "+MessageStatus{}.TableName()+" AS ms WHERE ms.message_id = "+msgTable+".id AND ms.user_id = ? AND ms.is_deleted = ?)", userID, true).
+		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&messages).Error
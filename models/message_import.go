@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MessageImportRef 导入消息的外部 ID 映射：同一房间内按原系统消息 ID 去重，重复导入直接跳过。
+type MessageImportRef struct {
+	ID         uint64 `gorm:"primarykey"`
+	RoomID     uint64 `gorm:"uniqueIndex:idx_room_external;not null"`
+	ExternalID string `gorm:"uniqueIndex:idx_room_external;size:128;not null"` // 原系统消息 ID
+	MessageID  uint64 `gorm:"index;not null"`
+	CreatedAt  time.Time
+}
+
+func (MessageImportRef) TableName() string { return prefix + "message_import_ref" }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// MessageReminder 消息提醒（“稍后提醒我”）：用户在会话中标记某条消息，到 RemindAt 时给自己发一条通知。
+// 同一用户对同一消息只保留一条，重复设置会更新时间并重新等待触发。
+type MessageReminder struct {
+	ID        uint64     `gorm:"primarykey"`
+	UserID    uint64     `gorm:"uniqueIndex:idx_user_msg;not null"`
+	MessageID uint64     `gorm:"uniqueIndex:idx_user_msg;not null"`
+	RoomID    uint64     `gorm:"index;not null"`
+	Note      string     `gorm:"size:255"`      // 备注
+	RemindAt  time.Time  `gorm:"index:idx_due"` // 提醒时间
+	FiredAt   *time.Time `gorm:"index:idx_due"` // 已提醒时间，为空表示待提醒
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (MessageReminder) TableName() string { return prefix + "message_reminder" }
@@ -46,3 +46,24 @@ type RoomNotificationDelivery struct {
 }
 
 func (RoomNotificationDelivery) TableName() string { return prefix + "room_notification_delivery" }
+
+// NotificationPreference 用户屏蔽的房间事件类型（有记录即不投递、不推送）
+// RoomID = 0 表示对所有房间生效；唯一索引 (user_id, room_id, event_type)。
+type NotificationPreference struct {
+	ID        uint64 `gorm:"primarykey"`
+	UserID    uint64 `gorm:"not null;uniqueIndex:idx_user_room_event,priority:1"`
+	RoomID    uint64 `gorm:"not null;default:0;uniqueIndex:idx_user_room_event,priority:2"`
+	EventType string `gorm:"size:64;not null;uniqueIndex:idx_user_room_event,priority:3"`
+	CreatedAt time.Time
+}
+
+func (NotificationPreference) TableName() string { return prefix + "notification_preference" }
+
+// NotificationCounter 用户未读通知数（投递时 +1，标记已读 / 清理时扣减），避免每次 COUNT 投递表
+type NotificationCounter struct {
+	UserID    uint64 `gorm:"primarykey;autoIncrement:false"`
+	Unread    int64  `gorm:"not null;default:0"`
+	UpdatedAt time.Time
+}
+
+func (NotificationCounter) TableName() string { return prefix + "notification_counter" }
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Department 组织架构中的部门（树形，ParentID=0 为顶级部门）
+type Department struct {
+	ID       uint64 `gorm:"primarykey"`
+	ParentID uint64 `gorm:"index;default:0"`
+	Name     string `gorm:"size:100;not null"`
+	Code     string `gorm:"size:64;uniqueIndex;default:null"` // 外部系统（HR）的部门编码，可空
+	Sort     int    `gorm:"default:0"`                        // 同级排序，越小越靠前
+	// RoomID 部门群，0 表示未开启；开启后群成员随部门成员自动同步
+	RoomID    uint64 `gorm:"default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Department) TableName() string { return prefix + "department" }
+
+// DepartmentMember 部门成员（一个用户可属于多个部门）
+type DepartmentMember struct {
+	ID           uint64 `gorm:"primarykey"`
+	DepartmentID uint64 `gorm:"uniqueIndex:idx_dept_user;not null"`
+	UserID       uint64 `gorm:"uniqueIndex:idx_dept_user;index;not null"`
+	Title        string `gorm:"size:64"` // 职位
+	IsLeader     bool   `gorm:"default:false"`
+	Sort         int    `gorm:"default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (DepartmentMember) TableName() string { return prefix + "department_member" }
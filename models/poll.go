@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// MessageTypePoll 投票消息类型（Message.Type）
+const MessageTypePoll = 11
+
+const (
+	PollStatusOpen   = 1 // 进行中
+	PollStatusClosed = 2 // 已结束
+)
+
+// Poll 群投票
+type Poll struct {
+	ID          uint64     `gorm:"primarykey"`
+	RoomID      uint64     `gorm:"index;not null"`         // 房间 ID
+	CreatorID   uint64     `gorm:"index;not null"`         // 发起人
+	MessageID   uint64     `gorm:"index;default:0"`        // 对应的投票消息
+	Question    string     `gorm:"size:200;not null"`      // 投票标题
+	MultiChoice bool       `gorm:"default:false"`          // 是否多选
+	MaxChoices  int        `gorm:"default:1"`              // 多选时最多选几项
+	Anonymous   bool       `gorm:"default:false"`          // 是否匿名（不返回投票人）
+	Status      uint8      `gorm:"type:tinyint;default:1"` // 1-进行中 2-已结束
+	Deadline    *time.Time `gorm:"index"`                  // 截止时间，空表示手动结束
+	ClosedAt    *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	Options []PollOption `gorm:"foreignKey:PollID"`
+}
+
+func (Poll) TableName() string { return prefix + "poll" }
+
+// PollOption 投票选项
+type PollOption struct {
+	ID        uint64 `gorm:"primarykey"`
+	PollID    uint64 `gorm:"index;not null"`
+	Text      string `gorm:"size:100;not null"`
+	Sort      int    `gorm:"default:0"`
+	VoteCount int    `gorm:"default:0"` // 冗余计数，避免每次聚合
+}
+
+func (PollOption) TableName() string { return prefix + "poll_option" }
+
+// PollVote 投票记录
+type PollVote struct {
+	ID        uint64 `gorm:"primarykey"`
+	PollID    uint64 `gorm:"index:idx_poll_user_option,unique;not null"`
+	UserID    uint64 `gorm:"index:idx_poll_user_option,unique;not null"`
+	OptionID  uint64 `gorm:"index:idx_poll_user_option,unique;not null"`
+	CreatedAt time.Time
+}
+
+func (PollVote) TableName() string { return prefix + "poll_vote" }
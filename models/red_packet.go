@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// MessageTypeRedPacket 红包消息类型（Message.Type）
+const MessageTypeRedPacket = 10
+
+const (
+	RedPacketTypeRandom = 1 // 拼手气
+	RedPacketTypeFixed  = 2 // 普通（均分）
+
+	RedPacketStatusActive    = 1 // 可领取
+	RedPacketStatusFinished  = 2 // 已领完
+	RedPacketStatusRefunded  = 3 // 已过期退回
+	RedPacketStatusRefunding = 4 // 已过期，剩余金额退回入账中（入账成功后置为 3）
+)
+
+// RedPacket 红包（金额单位：分）
+type RedPacket struct {
+	ID           uint64    `gorm:"primarykey"`
+	RoomID       uint64    `gorm:"index;not null"`               // 房间 ID
+	SenderID     uint64    `gorm:"index;not null"`               // 发送者
+	MessageID    uint64    `gorm:"index;default:0"`              // 对应的红包消息
+	Type         uint8     `gorm:"type:tinyint;default:1"`       // 1-拼手气 2-普通
+	TotalAmount  int64     `gorm:"not null"`                     // 总金额（分）
+	TotalCount   int       `gorm:"not null"`                     // 总个数
+	RemainAmount int64     `gorm:"not null"`                     // 剩余金额（分）
+	RemainCount  int       `gorm:"not null"`                     // 剩余个数
+	Greeting     string    `gorm:"size:100"`                     // 祝福语
+	Status       uint8     `gorm:"type:tinyint;default:1;index"` // 1-可领取 2-已领完 3-已过期退回 4-退回入账中
+	ExpireAt     time.Time `gorm:"index"`                        // 过期时间
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (RedPacket) TableName() string { return prefix + "red_packet" }
+
+// RedPacketClaim 红包领取记录
+type RedPacketClaim struct {
+	ID          uint64 `gorm:"primarykey"`
+	RedPacketID uint64 `gorm:"index:idx_packet_user,unique;not null"`
+	UserID      uint64 `gorm:"index:idx_packet_user,unique;not null"`
+	Amount      int64  `gorm:"not null"` // 领取金额（分）
+	// CreditPending 已记账、尚未入账到领取人钱包；入账失败时保持 true，由定时任务重试
+	CreditPending bool `gorm:"not null;default:false;index"`
+	CreatedAt     time.Time
+}
+
+func (RedPacketClaim) TableName() string { return prefix + "red_packet_claim" }
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// RoomAuditLog 群设置变更记录（群信息、管理员、禁言、踢人、入群验证等），只追加。
+// Action 与对应的房间事件类型一致（如 room.group.info_updated），Before/After 为变更涉及字段的前后值。
+type RoomAuditLog struct {
+	ID        uint64         `gorm:"primarykey"`
+	RoomID    uint64         `gorm:"index:idx_audit_room;not null"`
+	ActorID   uint64         `gorm:"index;default:0"` // 操作人，0 为系统（禁言到期自动解除等）
+	Action    string         `gorm:"size:64;not null"`
+	TargetID  uint64         `gorm:"default:0"` // 被操作的成员，群级设置为 0
+	Before    datatypes.JSON `gorm:"type:json"`
+	After     datatypes.JSON `gorm:"type:json"`
+	CreatedAt time.Time      `gorm:"index:idx_audit_room"`
+}
+
+func (RoomAuditLog) TableName() string { return prefix + "room_audit_log" }
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// 按群号加群的验证方式（Room.JoinMode）
+const (
+	RoomJoinApproval = 0 // 需管理员审批（默认）
+	RoomJoinFree     = 1 // 直接加入
+	RoomJoinQuestion = 2 // 回答问题，答对直接加入
+	RoomJoinDisabled = 3 // 不允许按群号加入
+)
+
+// 加群申请状态
+const (
+	RoomJoinRequestPending  = 0
+	RoomJoinRequestApproved = 1
+	RoomJoinRequestRejected = 2
+)
+
+// RoomJoinRequest 按群号加群的申请（JoinMode=审批时产生），同一用户对同一群只保留一条待处理申请
+type RoomJoinRequest struct {
+	ID        uint64 `gorm:"primarykey"`
+	RoomID    uint64 `gorm:"index:idx_join_room_status;not null"`
+	UserID    uint64 `gorm:"index;not null"`
+	Reason    string `gorm:"size:255"`                                          // 申请理由
+	Status    uint8  `gorm:"type:tinyint;index:idx_join_room_status;default:0"` // 0-待处理 1-同意 2-拒绝
+	HandlerID uint64 // 处理人
+	HandledAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (RoomJoinRequest) TableName() string { return prefix + "room_join_request" }
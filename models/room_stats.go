@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 群统计（消息落库时增量累加，查询时只扫统计表，不扫消息表）
+
+// RoomStatDaily 房间每日消息数
+type RoomStatDaily struct {
+	ID           uint64 `gorm:"primarykey"`
+	RoomID       uint64 `gorm:"index:idx_room_stat_daily,unique;not null"`
+	Day          string `gorm:"index:idx_room_stat_daily,unique;size:10;not null"` // YYYY-MM-DD
+	MessageCount int64  `gorm:"default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (RoomStatDaily) TableName() string { return prefix + "room_stat_daily" }
+
+// RoomStatHourly 房间每日分小时消息数（高峰时段统计用）
+type RoomStatHourly struct {
+	ID           uint64 `gorm:"primarykey"`
+	RoomID       uint64 `gorm:"index:idx_room_stat_hourly,unique;not null"`
+	Day          string `gorm:"index:idx_room_stat_hourly,unique;size:10;not null"`
+	Hour         uint8  `gorm:"index:idx_room_stat_hourly,unique;type:tinyint;not null"` // 0-23
+	MessageCount int64  `gorm:"default:0"`
+}
+
+func (RoomStatHourly) TableName() string { return prefix + "room_stat_hourly" }
+
+// RoomStatMember 房间成员每日发言数（活跃人数、发言排行用）
+type RoomStatMember struct {
+	ID           uint64 `gorm:"primarykey"`
+	RoomID       uint64 `gorm:"index:idx_room_stat_member,unique;not null"`
+	Day          string `gorm:"index:idx_room_stat_member,unique;size:10;not null"`
+	UserID       uint64 `gorm:"index:idx_room_stat_member,unique;not null"`
+	MessageCount int64  `gorm:"default:0"`
+}
+
+func (RoomStatMember) TableName() string { return prefix + "room_stat_member" }
@@ -0,0 +1,10 @@
+package models
+
+// RoomTag 群标签（公开群目录按标签检索）
+type RoomTag struct {
+	ID     uint64 `gorm:"primarykey"`
+	RoomID uint64 `gorm:"uniqueIndex:idx_room_tag;not null"`
+	Tag    string `gorm:"size:20;uniqueIndex:idx_room_tag;index;not null"`
+}
+
+func (RoomTag) TableName() string { return prefix + "room_tag" }
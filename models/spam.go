@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SpamViolation 反垃圾违规记录（超出好友申请/建群/拉人频率限制）
+type SpamViolation struct {
+	ID        uint64 `gorm:"primarykey"`
+	UserID    uint64 `gorm:"index;not null"`
+	Action    string `gorm:"size:32;index;not null"` // friend_request/create_group/invite
+	Limit     int    // 当时的上限
+	Attempted int    // 加上本次后的数量
+	CreatedAt time.Time
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (SpamViolation) TableName() string { return prefix + "spam_violation" }
@@ -24,7 +24,14 @@ type User struct {
 	Gender       uint8      `gorm:"type:tinyint;default:0"`            // 性别: 0-未知 1-男 2-女
 	Birthday     *time.Time // 生日
 	Signature    string     `gorm:"size:255"`               // 个性签名
-	OnlineStatus uint8      `gorm:"type:tinyint;default:0"` // 在线状态: 0-离线 1-在线
+	OnlineStatus uint8      `gorm:"type:tinyint;default:0"` // 在线状态: 0-离线 1-在线 2-离开
+	Invisible    bool       `gorm:"default:false"`          // 隐身：对他人显示离线（WS 收发不受影响）
+	IsBot        bool       `gorm:"default:false"`          // 是否机器人账号
+	IsVisitor    bool       `gorm:"default:false"`          // 是否访客（客服系统匿名访客）
+	Status       uint8      `gorm:"type:tinyint;default:0"` // 账号状态: 0-正常 1-暂停(到期自动恢复) 2-永久封禁
+	SuspendedAt  *time.Time // 封禁时间
+	SuspendUntil *time.Time // 暂停截止时间（永久封禁为空）
+	SuspendNote  string     `gorm:"size:255"` // 封禁原因
 	LastLoginAt  *time.Time // 最后登录时间
 	LastActiveAt *time.Time // 最后活跃时间
 	CreatedAt    time.Time
@@ -42,11 +49,26 @@ func (User) TableName() string {
 	return prefix + "user"
 }
 
+// 在线状态（User.OnlineStatus），由 WS 连接与空闲策略维护
+const (
+	OnlineStatusOffline = 0
+	OnlineStatusOnline  = 1
+	OnlineStatusAway    = 2
+)
+
+// 群成员角色（RoomUser.Role）
+const (
+	RoomRoleMember = 0
+	RoomRoleAdmin  = 1
+	RoomRoleOwner  = 2
+)
+
 // 请求状态
 const (
 	StatusPending = 0
 	StatusAgreed  = 1
 	StatusRefused = 2
+	StatusExpired = 3 // 好友申请超过有效期未处理
 )
 
 // Friend 好友关系表
@@ -58,6 +80,7 @@ type Friend struct {
 	GroupName string `gorm:"size:50"`                // 分组名
 	IsStar    bool   `gorm:"default:false"`          // 是否星标好友
 	IsMuted   bool   `gorm:"default:false"`          // 是否免打扰
+	NameIndex string `gorm:"size:1"`                 // 通讯录索引字母（A–Z/#，按备注或好友昵称计算，空表示待计算）
 	Status    uint8  `gorm:"type:tinyint;default:1"` // 状态: 1-正常 2-拉黑
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -81,12 +104,16 @@ type FriendApply struct {
 	ToUserID    uint64 `gorm:"not null;index:idx_to" json:"to_user"`     // 目标用户 ID
 	Reason      string `gorm:"size:255"`                                 // 申请理由
 	Remark      string `gorm:"size:100"`                                 // 备注
-	Status      uint8  `gorm:"type:tinyint;index:idx_status;default:0"`  // 状态: 0-待处理 1-同意 2-拒绝
+	Status      uint8  `gorm:"type:tinyint;index:idx_status;default:0"`  // 状态: 0-待处理 1-同意 2-拒绝 3-已过期
 	Reply       string `gorm:"size:255"`                                 // 回复消息
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	ProcessedAt *time.Time // 处理时间
 
+	// 申请来源，见 FriendSource* 常量（空视为 search）；SourceRoomID 为来源群（source=group 时）
+	Source       string `gorm:"size:20"`
+	SourceRoomID uint64
+
 	// 关联关系
 	FromUser User `gorm:"foreignKey:FromUserID"`
 	ToUser   User `gorm:"foreignKey:ToUserID"`
@@ -106,18 +133,38 @@ type Room struct {
 
 	Name          string  `gorm:"size:100"`               // 房间名称
 	Avatar        string  `gorm:"size:500"`               // 房间头像
-	Type          uint8   `gorm:"type:tinyint;default:1"` // 类型: 1-私聊 2-群聊
+	AvatarAuto    bool    `gorm:"default:false"`          // 头像由成员头像自动合成（手动设置头像后为 false，不再自动更新）
+	Type          uint8   `gorm:"type:tinyint;default:1"` // 类型: 1-私聊 2-群聊 3-客服
 	CreatorID     uint64  `gorm:"index"`                  // 创建者 ID
 	Description   string  `gorm:"size:500"`               // 描述
 	MemberLimit   int     `gorm:"default:200"`            // 成员上限
 	IsEncrypted   bool    `gorm:"default:false"`          // 是否端到端加密
 	LastMessageID *uint64 `gorm:"index"`                  // 最后一条消息 ID
+	MessageSeq    uint64  `gorm:"default:0"`              // 已分配的最大消息序号（Message.Seq）
+	// IsOrphaned 私聊双方已解除好友且禁止继续发送（见 FriendDeletePolicy），重新加好友后清除
+	IsOrphaned bool `gorm:"default:false"`
 
 	// 新增禁言相关字段
 	IsMute             bool       `gorm:"default:false"` // 全员禁言开关
 	MuteUntil          *time.Time `gorm:"default:null"`  // 全员禁言截止时间（倒计时模式）
 	MuteDailyStartTime string     `gorm:"size:5"`        // 每日禁言开始时间 "HH:MM"
 	MuteDailyDuration  int        `gorm:"default:0"`     // 每日禁言持续时长（分钟）
+	MuteTimezone       string     `gorm:"size:64"`       // 每日禁言按该时区计算（IANA 名称，如 Asia/Shanghai），空表示服务器本地时区
+	MuteWindowActive   bool       `gorm:"default:false"` // 调度器记录的每日禁言窗口状态，用于开始/结束通知（发言检查不依赖它）
+	MuteAdmins         bool       `gorm:"default:false"` // 全员禁言是否对管理员生效（群主始终可发言）
+
+	// 消息定时删除：开启后 DisappearingSince 之后发送的消息在 DisappearingSeconds 秒后对所有成员删除
+	DisappearingSeconds int64      `gorm:"default:0"` // 0 表示关闭
+	DisappearingSince   *time.Time // 开启时间（修改时长不重置，关闭时清空）
+
+	// 按群号加群的验证（见 RoomJoin* 常量），JoinAnswer 不对外返回
+	JoinMode     uint8  `gorm:"type:tinyint;default:0"`
+	JoinQuestion string `gorm:"size:200"`
+	JoinAnswer   string `gorm:"size:100"`
+
+	// 公开群目录：IsPublic 的群可被 /room/discover 搜到，标签见 RoomTag
+	IsPublic bool   `gorm:"default:false;index"`
+	Category string `gorm:"size:50;index"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -136,12 +183,14 @@ func (Room) TableName() string {
 // RoomUser 房间成员表
 type RoomUser struct {
 	ID         uint64     `gorm:"primarykey"`
-	RoomID     uint64     `gorm:"index:idx_room_user,unique;not null"` // 房间 ID (对应 Room.ID)
-	UserID     uint64     `gorm:"index:idx_room_user,unique;not null"` // 用户 ID
-	Role       uint8      `gorm:"type:tinyint;default:0"`              // 角色: 0-普通成员 1-管理员 2-群主
-	Nickname   string     `gorm:"size:100"`                            // 在群里的昵称
-	IsMuted    bool       `gorm:"default:false"`                       // 是否被禁言
+	RoomID     uint64     `gorm:"index:idx_room_user,unique;index:idx_room_nick,priority:1;not null"`                         // 房间 ID (对应 Room.ID)
+	UserID     uint64     `gorm:"index:idx_room_user,unique;index:idx_room_nick,priority:3;index:idx_room_user_uid;not null"` // 用户 ID（idx_room_user_uid 用于按用户查所在房间）
+	Role       uint8      `gorm:"type:tinyint;default:0"`                                                                     // 角色: 0-普通成员 1-管理员 2-群主
+	Nickname   string     `gorm:"size:100;index:idx_room_nick,priority:2"`                                                    // 在群里的昵称（idx_room_nick 为成员搜索的覆盖索引）
+	IsMuted    bool       `gorm:"default:false"`                                                                              // 是否被禁言
+	IsSaved    bool       `gorm:"default:false"`                                                                              // 是否保存到通讯录（群聊，仅影响自己）
 	MutedUntil *time.Time // 禁言截止时间
+	MuteExempt bool       `gorm:"default:false"`             // 全员禁言豁免（个人禁言仍生效）
 	JoinSource string     `gorm:"size:50"`                   // 加入来源
 	JoinTime   time.Time  `gorm:"default:CURRENT_TIMESTAMP"` // 加入时间
 	CreatedAt  time.Time
@@ -160,14 +209,16 @@ func (RoomUser) TableName() string {
 type Message struct {
 	ID uint64 `gorm:"primarykey"`
 	//MessageUUID  string         `gorm:"size:36;uniqueIndex;not null"` // 对外消息 ID
-	RoomID       uint64         `gorm:"index;not null"`         // 房间 ID (对应 Room.ID)
-	SenderID     uint64         `gorm:"index;not null"`         // 发送者 ID
-	ReplyToMsgID *uint64        `gorm:"index"`                  // 回复的消息 ID
-	Type         uint8          `gorm:"type:tinyint;default:1"` // 消息类型: 1-文本 2-图片 3-语音 4-视频 5-文件 6-位置
-	Content      string         `gorm:"type:text;not null"`     // 消息内容
+	RoomID       uint64         `gorm:"index;index:idx_message_room_seq,priority:1;not null"` // 房间 ID (对应 Room.ID)
+	SenderID     uint64         `gorm:"index;not null"`                                       // 发送者 ID
+	Seq          uint64         `gorm:"index:idx_message_room_seq,priority:2;default:0"`      // 房间内序号（服务端分配，从 1 连续递增），引入前的历史消息为 0
+	ReplyToMsgID *uint64        `gorm:"index"`                                                // 回复的消息 ID
+	Type         uint8          `gorm:"type:tinyint;default:1"`                               // 消息类型: 1-文本 2-图片 3-语音 4-视频 5-文件 6-位置
+	Content      string         `gorm:"type:text;not null"`                                   // 消息内容
 	Extra        datatypes.JSON `gorm:"column:extra;type:json"`
 	IsSystem     bool           `gorm:"default:false"`          // 是否为系统消息
 	IsEncrypted  bool           `gorm:"default:false"`          // 是否加密
+	IsViewOnce   bool           `gorm:"default:false"`          // 阅后即焚：接收方只能查看一次，查看后内容清除
 	Status       uint8          `gorm:"type:tinyint;default:0"` // 状态: 0-发送中 1-已发送 2-已送达 3-已读 4-撤回（会在聊天窗口留下痕迹） 5-删除（自己不可见） 6/7-双删（Sender/非Sender删除)在私聊中互相可以删除，但在群中你只能删除自己的，已经管理员进行删除
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
@@ -195,15 +246,18 @@ const (
 
 // MessageStatus 消息状态表（记录每个用户的已读状态）
 type MessageStatus struct {
-	ID        uint64     `gorm:"primarykey"`
-	MessageID uint64     `gorm:"index:idx_msg_user,unique;not null"` // 消息 ID
-	UserID    uint64     `gorm:"index:idx_msg_user,unique;not null"` // 用户 ID
-	RoomID    uint64     `gorm:"index:idx_msg_user,unique;not null"` // 房间 ID
-	IsRead    bool       `gorm:"default:false"`                      // 是否已读
-	IsDeleted bool       `gorm:"default:false"`                      // 是否删除
-	ReadAt    *time.Time // 阅读时间
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          uint64     `gorm:"primarykey"`
+	MessageID   uint64     `gorm:"index:idx_msg_user,unique;not null"` // 消息 ID
+	UserID      uint64     `gorm:"index:idx_msg_user,unique;not null"` // 用户 ID
+	RoomID      uint64     `gorm:"index:idx_msg_user,unique;not null"` // 房间 ID
+	IsRead      bool       `gorm:"default:false"`                      // 是否已读
+	IsDelivered bool       `gorm:"default:false"`                      // 是否已送达（WS delivery_ack / 已读隐含送达）
+	IsDeleted   bool       `gorm:"default:false"`                      // 是否删除
+	ReadAt      *time.Time // 阅读时间
+	DeliveredAt *time.Time // 送达时间
+	ViewedAt    *time.Time // 阅后即焚消息的查看时间（只能查看一次）
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 
 	// 关联关系
 	Message Message `gorm:"foreignKey:MessageID"`
@@ -217,13 +271,14 @@ func (MessageStatus) TableName() string {
 // Conversation 会话表（每个用户的聊天会话列表）
 type Conversation struct {
 	ID     uint64 `gorm:"primarykey"`
-	UserID uint64 `gorm:"index:idx_user_room,unique;not null"` // 用户 ID
-	RoomID uint64 `gorm:"index:idx_user_room,unique;not null"` // 房间 ID (对应 Room.ID)
+	UserID uint64 `gorm:"index:idx_user_room,unique;not null"`                     // 用户 ID
+	RoomID uint64 `gorm:"index:idx_user_room,unique;index:idx_conv_room;not null"` // 房间 ID (对应 Room.ID)
 	//LastMessageID *uint64 `gorm:"index"`                               // 最后一条消息 ID
-	//UnreadCount   uint64  `gorm:"default:0"`     // 未读消息数
+	UnreadCount   uint64  `gorm:"default:0"`     // 未读消息数（写消息时 +1，已读回执落库时重算）
 	IsMuted       bool    `gorm:"default:false"` // 是否免打扰
 	IsPinned      bool    `gorm:"default:false"` // 是否置顶
 	IsVisible     bool    `gorm:"default:true"`  // 是否在消息列表展示（用户维度）
+	IsArchived    bool    `gorm:"default:false"` // 是否归档（单独列表展示，不计入总未读；与隐藏、免打扰互相独立）
 	LastReadMsgID *uint64 `gorm:"index"`         // 最后阅读的消息 ID
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
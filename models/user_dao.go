@@ -123,10 +123,7 @@ func (dao *UserDAO) SearchUsers(keyword string, excludeUserID uint64, limit, off
 	if excludeUserID > 0 {
 		q = q.Where("id <> ?", excludeUserID)
 	}
-	if keyword != "" {
-		like := "%" + keyword + "%"
-		q = q.Where("username LIKE ? OR nickname LIKE ? OR uid LIKE ?", like, like, like)
-	}
+	q = q.Scopes(UserSearchScope(keyword))
 
 	var users []User
 	err := q.Order("id DESC").Limit(limit).Offset(offset).Find(&users).Error
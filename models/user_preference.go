@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// UserPreference 用户自定义设置：按命名空间存一个 JSON 对象（字体、气泡样式等客户端偏好），服务端不解析内容。
+// Version 每次写入加 1，用于多设备同步与并发修改检测。
+type UserPreference struct {
+	ID        uint64         `gorm:"primarykey"`
+	UserID    uint64         `gorm:"uniqueIndex:idx_user_ns;not null"`
+	Namespace string         `gorm:"uniqueIndex:idx_user_ns;size:64;not null"`
+	Data      datatypes.JSON `gorm:"type:json"`
+	Version   uint64         `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (UserPreference) TableName() string { return prefix + "user_preference" }
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 搜索可见性（UserPrivacy.SearchMode）
+const (
+	SearchModeDefault = 0 // 昵称/用户名/UID 模糊搜索可见
+	SearchModeUIDOnly = 1 // 只能通过 UID/用户名精确搜到
+	SearchModeHidden  = 2 // 不能被搜到
+)
+
+// 好友申请来源（FriendApply.Source）
+const (
+	FriendSourceSearch = "search" // 搜索
+	FriendSourceGroup  = "group"  // 群聊成员名片
+	FriendSourceQRCode = "qrcode" // 扫码
+	FriendSourceNearby = "nearby" // 附近的人
+)
+
+// UserPrivacy 用户隐私设置（“如何找到/添加我”、资料可见性），没有记录时全部取默认值（零值）
+type UserPrivacy struct {
+	UserID           uint64 `gorm:"primarykey;autoIncrement:false"`
+	SearchMode       uint8  `gorm:"type:tinyint;default:0"`                // 见 SearchMode* 常量
+	AllowPhoneSearch bool   `gorm:"default:false"`                         // 允许通过手机号精确搜到
+	DenyFromGroup    bool   `gorm:"default:false"`                         // 不允许通过群聊添加
+	DenyFromQRCode   bool   `gorm:"column:deny_from_qrcode;default:false"` // 不允许扫码添加
+	DenyFromNearby   bool   `gorm:"default:false"`                         // 不允许通过附近的人添加
+	DenyFriendApply  bool   `gorm:"default:false"`                         // 关闭好友申请（任何来源）
+
+	// 资料对非好友的可见性（手机号/邮箱对非好友始终隐藏）
+	HideSignature   bool `gorm:"default:false"` // 隐藏个性签名
+	HideLastActive  bool `gorm:"default:false"` // 隐藏最近登录/活跃时间
+	HideMomentCount bool `gorm:"default:false"` // 隐藏动态数
+	UpdatedAt       time.Time
+}
+
+func (UserPrivacy) TableName() string { return prefix + "user_privacy" }
+
+// UserSearchScope 用户搜索条件（按隐私设置过滤）：
+// 模糊匹配 username/nickname/uid 只返回 SearchMode=默认 的用户；精确 UID/用户名 命中 SearchMode<隐藏 的用户；
+// 精确手机号只命中开启了 AllowPhoneSearch 的用户。keyword 为空时只返回默认可见的用户。
+func UserSearchScope(keyword string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		privacy := UserPrivacy{}.TableName()
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			return db.Where("id NOT IN (SELECT user_id FROM "+privacy+" WHERE search_mode <> ?)", SearchModeDefault)
+		}
+		like := "%" + keyword + "%"
+		return db.Where(
+			"((username LIKE ? OR nickname LIKE ? OR uid LIKE ?) AND id NOT IN (SELECT user_id FROM "+privacy+" WHERE search_mode <> ?))"+
+				" OR ((uid = ? OR username = ?) AND id NOT IN (SELECT user_id FROM "+privacy+" WHERE search_mode = ?))"+
+				" OR (phone = ? AND id IN (SELECT user_id FROM "+privacy+" WHERE allow_phone_search = ? AND search_mode <> ?))",
+			like, like, like, SearchModeDefault,
+			keyword, keyword, SearchModeHidden,
+			keyword, true, SearchModeHidden,
+		)
+	}
+}
@@ -1,8 +1,9 @@
 package chat_sdk
 
 import "gorm.io/gorm"
-import "github.com/go-redis/redis/v8"
+import "github.com/redis/go-redis/v9"
 import "time"
+import "github.com/cydxin/chat-sdk/service"
 
 type ServiceConfig struct {
 	Debug bool
@@ -10,12 +11,137 @@ type ServiceConfig struct {
 
 type Config struct {
 	DB          *gorm.DB
-	RDB         *redis.Client
+	RDB         redis.UniversalClient
 	TablePrefix string
 	Service     ServiceConfig
 
-	// GroupAvatarMerge 群头像合成配置（创建群时生成微信群风格拼图头像）
+	// DBReplicas 只读副本，重读接口（会话列表、历史消息、搜索、动态）轮询使用，写入与其他查询走 DB
+	DBReplicas []*gorm.DB
+	// PrimaryReads 即使配置了副本也读主库的读场景（service.ReadScope*）
+	PrimaryReads []string
+	// TxRetry 事务遇到死锁/锁等待超时时的重试策略，默认 service.DefaultTxRetryPolicy
+	TxRetry service.TxRetryPolicy
+
+	// KVStore token / 验证码存储，为空时使用基于 RDB 的 Redis 实现；无 Redis 时可注入 service.NewMemoryKVStore()
+	KVStore service.KVStore
+
+	// SessionStore 在线昵称/头像与已读游标快照的来源，为空时使用 WsServer 的内存 session
+	SessionStore service.SessionStore
+
+	// NotificationPushOnly 房间通知只推送不落库（见 WithNotificationService）
+	NotificationPushOnly bool
+	// AnnouncementRate 系统公告分批投递的速率，零值使用 service.DefaultAnnouncementRate
+	AnnouncementRate service.AnnouncementRate
+
+	// AutoUnarchive 新消息是否自动取消会话归档（见 WithAutoUnarchive）
+	AutoUnarchive bool
+
+	// GroupAvatarMerge 群头像合成配置（建群/成员变动时生成微信群风格拼图头像）
 	GroupAvatarMerge GroupAvatarMergeConfig
+	// FileStorage 生成/上传文件的存储后端，为 nil 时写本地目录
+	FileStorage service.FileStorage
+
+	// HelpDeskStrategy 客服分配策略：least_active（默认）/ round_robin
+	HelpDeskStrategy string
+
+	// LinkPreviewEnabled 文本消息含链接时是否异步抓取 Open Graph 预览
+	LinkPreviewEnabled bool
+
+	// VoiceMaxDuration 语音消息最大时长，默认 60s
+	VoiceMaxDuration time.Duration
+
+	// Wallet 钱包实现（红包功能依赖），不配置则红包接口不可用
+	Wallet service.Wallet
+
+	// NamecardSecret 二维码名片加好友令牌的签名密钥，为空则启动时随机生成（重启后旧二维码失效）
+	NamecardSecret string
+
+	// AdminToken 管理接口（/admin/*）令牌，为空则管理接口不可用
+	AdminToken string
+
+	// AdminUserIDs 运维账号，封禁申诉等运维事件会通过 WS 推送给这些用户
+	AdminUserIDs []uint64
+
+	// AntiSpamLimits 反垃圾频率限制（需要 Redis），默认 service.DefaultAntiSpamLimits
+	AntiSpamLimits service.AntiSpamLimits
+
+	// GeoIPResolver IP 归属国家解析器，配置后按国家屏蔽的规则才生效
+	GeoIPResolver service.GeoIPResolver
+
+	// TrustProxyHeaders WS 升级时是否信任 X-Forwarded-For / X-Real-IP（仅部署在可信反向代理之后开启）
+	TrustProxyHeaders bool
+
+	// Captcha 人机验证（hCaptcha/reCAPTCHA/极验），为空则关闭
+	Captcha service.Captcha
+	// CaptchaLoginFailures 同一账号连续登录失败多少次后要求人机验证，默认 3
+	CaptchaLoginFailures int
+
+	// CodeSender 验证码投递通道（短信/邮件），为空时 /user/code/send 只生成验证码，由调用方发送
+	CodeSender service.CodeSender
+	// SendCodeLimits 发送验证码的每日配额与可疑行为锁定，默认 service.DefaultSendCodeLimits
+	SendCodeLimits service.SendCodeLimits
+
+	// RecallPolicy 撤回策略（时限、管理员撤回他人消息、是否保留占位），默认 service.DefaultRecallPolicy
+	RecallPolicy service.RecallPolicy
+
+	// FriendDeletePolicy 删除好友后私聊的处理（隐藏会话、禁止发送），默认 service.DefaultFriendDeletePolicy
+	FriendDeletePolicy service.FriendDeletePolicy
+
+	// Language 错误文案默认语言（response.LangZH / response.LangEN），请求头 Accept-Language 优先，默认中文
+	Language string
+
+	// LegacyHTTPStatus 所有响应统一返回 HTTP 200（仅靠 body.code 区分），兼容老客户端
+	LegacyHTTPStatus bool
+
+	// TokenTTL 登录 token 有效期，默认 7 天
+	TokenTTL time.Duration
+
+	// WsLimits WebSocket 单条消息大小、心跳超时、发送缓冲，默认 DefaultWsLimits
+	WsLimits WsLimits
+
+	// WsIdlePolicy 连接空闲策略（离开/踢出），默认关闭
+	WsIdlePolicy WsIdlePolicy
+	// RoomOnlineFeed 房间在线人数推送（room_online）的合并间隔，<=0 不推送
+	RoomOnlineFeed time.Duration
+	// PresenceHook 用户在线状态变化（online/away/offline）回调，可选
+	PresenceHook func(PresenceEvent)
+	// AccountSwitchHook 同一设备账号切换的审计回调，可选
+	AccountSwitchHook func(service.AccountSwitchEvent)
+
+	// OrgDirectoryEnabled 是否开启组织架构（部门树、同事私聊、部门群）
+	OrgDirectoryEnabled bool
+
+	// ComplianceArchiveEnabled 是否开启合规归档（消息写入及撤回/删除/销毁/清理前追加到 message_archive）
+	ComplianceArchiveEnabled bool
+	// ArchiveSink 合规归档的外部存储（与归档表同步写入），可选
+	ArchiveSink service.ArchiveSink
+	// ComplianceToken 合规导出接口（/compliance/*）令牌，为空则导出接口不可用
+	ComplianceToken string
+
+	// SlowPersistThreshold / SlowFanoutThreshold 慢消息阈值（落库 / 扇出），<=0 使用 service.DefaultSlowPersist / DefaultSlowFanout
+	SlowPersistThreshold time.Duration
+	SlowFanoutThreshold  time.Duration
+
+	// LoadShedding 过载保护配置，为 nil 时不开启
+	LoadShedding *service.LoadShedConfig
+
+	// MessageRetention 历史消息保留时长，超过的消息每小时物理删除一批；<=0 永久保留
+	MessageRetention time.Duration
+
+	// NotificationRetention 通知投递保留时长，超过的投递与事件每小时物理删除一批；<=0 永久保留
+	NotificationRetention time.Duration
+
+	// MessageColdAfter 消息冷却期，超过的消息每小时搬一批到 message_cold 表；<=0 不开启
+	MessageColdAfter time.Duration
+
+	// MessageStore 消息基础读写的存储后端，为空时使用 GORM（同 DB）
+	MessageStore service.MessageStore
+
+	// FriendRequestTTL 待处理好友申请的有效期，超过后每分钟批量标记为已过期并通知申请人；<=0 永不过期
+	FriendRequestTTL time.Duration
+
+	// PinyinConverter 好友通讯录索引字母（A–Z/#）的计算方式，为空时用内置的常用汉字表
+	PinyinConverter service.PinyinConverter
 }
 
 // GroupAvatarMergeConfig 群头像合成配置（Engine级别）。
@@ -32,6 +158,9 @@ type GroupAvatarMergeConfig struct {
 	// 例："uploads/auto_avatar" 或 "/uploads/auto_avatar" 或 "https://cdn.xxx.com/uploads/auto_avatar"。
 	// 为空时将使用 OutputDir（去掉 file:// 的逻辑已移除）。
 	URLPrefix string
+
+	// Debounce 成员变动后延迟多久重新合成（期间的变动合并为一次），<=0 使用 service.DefaultGroupAvatarDebounce
+	Debounce time.Duration
 }
 
 type Option func(*Config)
@@ -48,12 +177,20 @@ func WithTablePrefix(prefix string) Option {
 	}
 }
 
-func WithRDB(RDB *redis.Client) Option {
+// WithRDB 配置 Redis（*redis.Client / *redis.ClusterClient / redis.NewUniversalClient 创建的 Sentinel 客户端均可）。
+func WithRDB(RDB redis.UniversalClient) Option {
 	return func(c *Config) {
 		c.RDB = RDB
 	}
 }
 
+// WithKVStore 配置 token / 验证码存储（如 service.NewMemoryKVStore()，多实例部署需共享存储）。
+func WithKVStore(kv service.KVStore) Option {
+	return func(c *Config) {
+		c.KVStore = kv
+	}
+}
+
 func WithServiceDebug(debug bool) Option {
 	return func(c *Config) {
 		c.Service.Debug = debug
@@ -66,3 +203,315 @@ func WithGroupAvatarMergeConfig(cfg GroupAvatarMergeConfig) Option {
 		c.GroupAvatarMerge = cfg
 	}
 }
+
+// WithFileStorage 配置服务端生成/上传文件（自动群头像、头像上传）的存储后端，如 &service.S3FileStorage{...}
+// 上传到 S3/OSS/MinIO 后返回远程 URL；不配置时写本地目录（GroupAvatarMergeConfig.OutputDir / URLPrefix）。
+func WithFileStorage(storage service.FileStorage) Option {
+	return func(c *Config) {
+		c.FileStorage = storage
+	}
+}
+
+// WithHelpDeskStrategy 配置客服分配策略（service.HelpDeskStrategyLeastActive / HelpDeskStrategyRoundRobin）。
+func WithHelpDeskStrategy(strategy string) Option {
+	return func(c *Config) {
+		c.HelpDeskStrategy = strategy
+	}
+}
+
+// WithLinkPreview 开启/关闭链接预览抓取（默认开启）。
+func WithLinkPreview(enabled bool) Option {
+	return func(c *Config) {
+		c.LinkPreviewEnabled = enabled
+	}
+}
+
+// WithVoiceMaxDuration 配置语音消息最大时长。
+func WithVoiceMaxDuration(d time.Duration) Option {
+	return func(c *Config) {
+		c.VoiceMaxDuration = d
+	}
+}
+
+// WithWallet 注入钱包实现（红包扣款/入账/退回）。
+func WithWallet(w service.Wallet) Option {
+	return func(c *Config) {
+		c.Wallet = w
+	}
+}
+
+// WithNamecardSecret 配置二维码名片令牌签名密钥（多实例部署时需一致）。
+func WithNamecardSecret(secret string) Option {
+	return func(c *Config) {
+		c.NamecardSecret = secret
+	}
+}
+
+// WithAdminToken 配置管理接口令牌（请求头 X-Admin-Token）。
+func WithAdminToken(token string) Option {
+	return func(c *Config) {
+		c.AdminToken = token
+	}
+}
+
+// WithAdminUserIDs 配置接收运维事件（申诉等）推送的用户。
+func WithAdminUserIDs(ids ...uint64) Option {
+	return func(c *Config) {
+		c.AdminUserIDs = ids
+	}
+}
+
+// WithAntiSpamLimits 配置好友申请/建群/拉人频率限制（字段 <=0 表示不限制）。
+func WithAntiSpamLimits(limits service.AntiSpamLimits) Option {
+	return func(c *Config) {
+		c.AntiSpamLimits = limits
+	}
+}
+
+// WithGeoIPResolver 注入 IP 归属国家解析器（如基于 MaxMind GeoLite2 的实现）。
+func WithGeoIPResolver(r service.GeoIPResolver) Option {
+	return func(c *Config) {
+		c.GeoIPResolver = r
+	}
+}
+
+// WithTrustProxyHeaders 信任反向代理传递的客户端 IP 头（X-Forwarded-For / X-Real-IP）。
+func WithTrustProxyHeaders(trust bool) Option {
+	return func(c *Config) {
+		c.TrustProxyHeaders = trust
+	}
+}
+
+// WithCaptcha 开启人机验证（service.HCaptcha / service.ReCaptcha / service.Geetest 或自定义实现）。
+// 开启后注册、发送验证码必须携带 captcha_token，登录连续失败 loginFailures 次后需要（<=0 使用默认 3 次）。
+func WithCaptcha(c service.Captcha, loginFailures int) Option {
+	return func(cfg *Config) {
+		cfg.Captcha = c
+		cfg.CaptchaLoginFailures = loginFailures
+	}
+}
+
+// WithCodeSender 配置验证码投递通道：service.SMTPCodeSender / AliyunSMSSender / TwilioSMSSender，
+// 多个通道用 service.FailoverCodeSender 组合（按顺序失败切换）。
+func WithCodeSender(sender service.CodeSender) Option {
+	return func(c *Config) {
+		c.CodeSender = sender
+	}
+}
+
+// WithSendCodeLimits 配置发送验证码的配额：每个手机号/邮箱、每个 IP 的每日次数，以及异常模式锁定（字段 <=0 表示不限制）。
+func WithSendCodeLimits(limits service.SendCodeLimits) Option {
+	return func(c *Config) {
+		c.SendCodeLimits = limits
+	}
+}
+
+// WithRecallPolicy 配置消息撤回策略。
+func WithRecallPolicy(p service.RecallPolicy) Option {
+	return func(c *Config) {
+		c.RecallPolicy = p
+	}
+}
+
+// WithFriendDeletePolicy 配置删除好友后私聊会话是否隐藏、是否禁止继续发送。
+func WithFriendDeletePolicy(p service.FriendDeletePolicy) Option {
+	return func(c *Config) {
+		c.FriendDeletePolicy = p
+	}
+}
+
+// WithLanguage 配置错误文案默认语言；新增语言或覆盖文案用 response.RegisterMessages。
+func WithLanguage(lang string) Option {
+	return func(c *Config) {
+		c.Language = lang
+	}
+}
+
+// WithLegacyHTTPStatus 开启后错误响应也返回 HTTP 200，默认按业务码映射（400/401/403/404/409/429/500/503）。
+func WithLegacyHTTPStatus(legacy bool) Option {
+	return func(c *Config) {
+		c.LegacyHTTPStatus = legacy
+	}
+}
+
+// WithTokenTTL 配置登录 token 有效期。
+func WithTokenTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.TokenTTL = ttl
+	}
+}
+
+// WithWsLimits 配置 WebSocket 连接参数（字段 <=0 使用默认值）。
+func WithWsLimits(limits WsLimits) Option {
+	return func(c *Config) {
+		c.WsLimits = limits
+	}
+}
+
+// WithWsIdlePolicy 配置连接空闲策略：awayAfter 无上行消息后标记为离开，kickAfter 后断开连接（<=0 关闭）。
+func WithWsIdlePolicy(p WsIdlePolicy) Option {
+	return func(c *Config) {
+		c.WsIdlePolicy = p
+	}
+}
+
+// WithPresenceHook 订阅用户在线状态变化（本实例的 WS 连接/空闲策略触发，单协程串行回调）。
+// 引擎会先把状态写入 user.online_status / last_active_at 再回调，回调中不要长时间阻塞。
+func WithPresenceHook(fn func(PresenceEvent)) Option {
+	return func(c *Config) {
+		c.PresenceHook = fn
+	}
+}
+
+// WithAccountSwitchHook 订阅同一设备上的账号切换（审计落库、风控等），在切换请求中同步回调。
+func WithAccountSwitchHook(fn func(service.AccountSwitchEvent)) Option {
+	return func(c *Config) {
+		c.AccountSwitchHook = fn
+	}
+}
+
+// WithMessageRetention 配置历史消息保留时长（如 180 天），过期消息及其回执会被物理删除。
+func WithMessageRetention(d time.Duration) Option {
+	return func(c *Config) {
+		c.MessageRetention = d
+	}
+}
+
+// WithNotificationRetention 配置通知保留时长（如 30 天），过期的通知投递与事件会被物理删除，未读数同步扣减。
+func WithNotificationRetention(d time.Duration) Option {
+	return func(c *Config) {
+		c.NotificationRetention = d
+	}
+}
+
+// WithSessionStore 替换在线会话状态来源（默认 WsServer 内存 session）。
+func WithSessionStore(store service.SessionStore) Option {
+	return func(c *Config) {
+		c.SessionStore = store
+	}
+}
+
+// WithNotificationService 是否落库房间通知（默认开启）。
+// 关闭后只做 WS 推送：不写事件/投递/未读数，HTTP 拉取接口返回空，离线期间的通知不再补发。
+func WithNotificationService(enabled bool) Option {
+	return func(c *Config) {
+		c.NotificationPushOnly = !enabled
+	}
+}
+
+// WithAutoUnarchive 已归档的会话收到新消息时是否自动移出归档（默认不移出；免打扰的会话始终保持归档）。
+func WithAutoUnarchive(enabled bool) Option {
+	return func(c *Config) {
+		c.AutoUnarchive = enabled
+	}
+}
+
+// WithOrgDirectory 开启组织架构（默认关闭）：部门/部门成员表、架构浏览与同事私聊接口、自动同步成员的部门群。
+func WithOrgDirectory(enabled bool) Option {
+	return func(c *Config) {
+		c.OrgDirectoryEnabled = enabled
+	}
+}
+
+// WithComplianceArchive 开启合规归档（默认关闭）：每条消息写入时、以及撤回/删除/阅后即焚清除/定时删除/保留期清理前，
+// 把完整内容追加到 message_archive 表；sink 非 nil 时同步写入外部存储，写入失败则该变更失败。
+func WithComplianceArchive(sink service.ArchiveSink) Option {
+	return func(c *Config) {
+		c.ComplianceArchiveEnabled = true
+		c.ArchiveSink = sink
+	}
+}
+
+// WithComplianceToken 配置合规导出接口令牌（请求头 X-Compliance-Token），应与 WithAdminToken 不同，只交给合规人员。
+func WithComplianceToken(token string) Option {
+	return func(c *Config) {
+		c.ComplianceToken = token
+	}
+}
+
+// WithSlowMessageThresholds 配置慢消息阈值：WS 消息落库或扇出耗时超过阈值时打印 slow message 日志并计入 /admin/delivery/sla。
+func WithSlowMessageThresholds(persist, fanout time.Duration) Option {
+	return func(c *Config) {
+		c.SlowPersistThreshold = persist
+		c.SlowFanoutThreshold = fanout
+	}
+}
+
+// WithLoadShedding 开启过载保护：定时检测 WS 发送队列深度与数据库延迟，超过阈值时非核心接口（动态、搜索、统计等）
+// 返回 503 + Retry-After，消息收发不受影响。cfg 零值字段使用默认值（队列占用 70%、数据库 500ms、拒绝 30s、每 2s 检测）。
+func WithLoadShedding(cfg service.LoadShedConfig) Option {
+	return func(c *Config) {
+		c.LoadShedding = &cfg
+	}
+}
+
+// WithMessageColdStorage 开启冷消息分表：发送超过 after（如 90 天）的消息每小时分批搬到 message_cold 表，
+// 热表只保留近期消息；历史消息、详情、会话内搜索与定位在热表不足时透明地查询冷表。冷表中的消息只读。
+func WithMessageColdStorage(after time.Duration) Option {
+	return func(c *Config) {
+		c.MessageColdAfter = after
+	}
+}
+
+// WithMessageStore 替换消息基础读写的存储后端（实现 service.MessageStore，如基于 MongoDB / ClickHouse 的实现）。
+// 发送、撤回/删除与按 ID / 按房间读取都经由该后端；转发、回执、搜索等直接查消息表的功能仍依赖 DB，详见 service.MessageStore。
+func WithMessageStore(store service.MessageStore) Option {
+	return func(c *Config) {
+		c.MessageStore = store
+	}
+}
+
+// WithDBReplicas 配置只读副本（已连接好的 *gorm.DB，与 WithDB 一致由调用方创建），读写分离：
+// 会话列表、历史消息、会话内搜索、用户/群成员/公开群搜索、朋友圈列表轮询走副本，写入、事务与其他查询仍走主库。
+func WithDBReplicas(replicas ...*gorm.DB) Option {
+	return func(c *Config) {
+		c.DBReplicas = append(c.DBReplicas, replicas...)
+	}
+}
+
+// WithPrimaryReads 指定仍读主库的读场景（service.ReadScopeConversation / ReadScopeMessage / ReadScopeSearch / ReadScopeMoment），
+// 用于不能容忍复制延迟的场景，例如发送消息后客户端立即拉取历史。
+func WithPrimaryReads(scopes ...string) Option {
+	return func(c *Config) {
+		c.PrimaryReads = append(c.PrimaryReads, scopes...)
+	}
+}
+
+// WithTxRetry 配置事务死锁/锁等待超时/序列化失败时的自动重试（次数与退避），MaxAttempts<=1 关闭重试。
+func WithTxRetry(p service.TxRetryPolicy) Option {
+	return func(c *Config) {
+		c.TxRetry = p
+	}
+}
+
+// WithFriendRequestExpiry 待处理的好友申请超过 ttl（如 7 天）未处理时自动过期：申请人收到 friend_expired 推送，
+// 申请列表不再返回，之后可以重新发起申请。
+func WithFriendRequestExpiry(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.FriendRequestTTL = ttl
+	}
+}
+
+// WithPinyinConverter 替换好友通讯录索引字母的计算方式（如接入完整拼音库）。已存的索引字母不会自动重算，
+// 修改备注/昵称后才按新方式计算。
+func WithPinyinConverter(pc service.PinyinConverter) Option {
+	return func(c *Config) {
+		c.PinyinConverter = pc
+	}
+}
+
+// WithRoomOnlineFeed 开启房间在线人数推送：成员上下线、隐身切换后，每 interval 合并一次，人数有变化的房间向其在线成员推送 room_online。
+// 只统计本实例的 WS 连接；在线成员列表通过 /room/online 获取。
+func WithRoomOnlineFeed(interval time.Duration) Option {
+	return func(c *Config) {
+		c.RoomOnlineFeed = interval
+	}
+}
+
+// WithAnnouncementRate 系统公告（/admin/announcement）的投递速率：每批写入并推送 BatchSize 个用户，批间等待 Interval，
+// 避免大范围公告瞬间占满数据库与 WS 发送队列。默认每批 500 人、间隔 200ms。
+func WithAnnouncementRate(r service.AnnouncementRate) Option {
+	return func(c *Config) {
+		c.AnnouncementRate = r
+	}
+}
@@ -0,0 +1,529 @@
+package response
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 内置语言
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+// DefaultLang 请求未带 Accept-Language 或语言不受支持时使用（可通过 chat_sdk.WithLanguage 配置）
+var DefaultLang = LangZH
+
+var (
+	catalogMu sync.RWMutex
+	// catalog 文案目录：lang -> key -> 模板（fmt 格式）
+	// key 约定：code.<业务码> 为通用文案，err.<name> 为 service 层错误（见 service.Error），valid.<tag> 为参数校验文案
+	catalog = map[string]map[string]string{
+		LangZH: {
+			CodeKey(CodeSuccess):            "成功",
+			CodeKey(CodeParamError):         "参数错误",
+			CodeKey(CodeUserNotFound):       "用户不存在",
+			CodeKey(CodePasswordError):      "账户或密码无效",
+			CodeKey(CodeTokenInvalid):       "登录已失效，请重新登录",
+			CodeKey(CodePermissionDeny):     "权限不足",
+			CodeKey(CodeVerifyCodeInvalid):  "验证码错误或已过期",
+			CodeKey(CodeRedisNotConfigured): "r 服务暂未开启",
+			CodeKey(CodeUserAlreadyExists):  "用户已存在",
+			CodeKey(CodeAccountSuspended):   "账号已被封禁",
+			CodeKey(CodeRateLimited):        "操作过于频繁",
+			CodeKey(CodeCaptchaRequired):    "需要人机验证",
+			CodeKey(CodeRoomFull):           "群成员已达上限",
+			CodeKey(CodeServerBusy):         "服务器繁忙，请稍后重试",
+			CodeKey(CodeMuted):              "你已被禁言",
+			CodeKey(CodeInternalError):      "服务器内部错误",
+
+			"err.rate_limited":                    "操作过于频繁",
+			"err.account_disabled":                "账号已被封禁",
+			"err.captcha_required":                "需要人机验证",
+			"err.captcha_invalid":                 "人机验证未通过",
+			"err.room_full":                       "群成员已达上限",
+			"err.redis_not_configured":            "r 服务暂未开启",
+			"err.username_required":               "输入账号",
+			"err.password_required":               "输入密码",
+			"err.nickname_required":               "输入昵称",
+			"err.code_required":                   "输入验证码",
+			"err.phone_or_email":                  "请通过电话或电子邮件",
+			"err.phone_and_email":                 "电话和电子邮件不可同时提供",
+			"err.account_required":                "需要账户",
+			"err.password_or_code":                "需要密码或验证码",
+			"err.password_and_code":               "密码和代码不能同时提供",
+			"err.identifier_required":             "需要标识符",
+			"err.purpose_required":                "需要验证码用途",
+			"err.new_password_required":           "输入新密码",
+			"err.old_password_required":           "输入旧密码",
+			"err.old_password_wrong":              "旧密码不正确",
+			"err.invalid_credentials":             "账户或密码无效",
+			"err.verify_code_invalid":             "验证码错误或已过期",
+			"err.user_not_found":                  "用户不存在",
+			"err.user_exists":                     "用户已存在",
+			"err.username_exists":                 "用户名已存在: %s",
+			"err.phone_exists":                    "手机号已存在: %s",
+			"err.email_exists":                    "邮箱已存在: %s",
+			"err.permission_denied":               "权限不足",
+			"err.notification_event_type":         "该通知类型不支持屏蔽",
+			"err.conversation_not_found":          "会话不存在",
+			"err.disappearing_ttl":                "不支持的消息定时删除时长",
+			"err.message_not_found":               "消息不存在",
+			"err.view_once_private_only":          "阅后即焚仅支持私聊",
+			"err.view_once_viewed":                "该消息已查看过",
+			"err.group_not_found":                 "群不存在",
+			"err.join_mode_invalid":               "不支持的加群方式",
+			"err.join_disabled":                   "该群不允许通过群号加入",
+			"err.join_answer_wrong":               "验证问题回答错误",
+			"err.join_question_required":          "请设置验证问题和答案",
+			"err.join_request_handled":            "该申请已处理",
+			"err.room_tags_invalid":               "标签最多 10 个，每个不超过 20 个字符",
+			"err.privacy_search_mode":             "不支持的搜索可见范围",
+			"err.friend_source_invalid":           "不支持的好友申请来源",
+			"err.friend_source_room":              "双方需在同一群聊中",
+			"err.friend_apply_disabled":           "对方已关闭好友申请",
+			"err.friend_source_denied":            "对方不允许通过该方式添加好友",
+			"err.reminder_time":                   "提醒时间需晚于当前时间",
+			"err.reminder_limit":                  "待提醒的消息已达上限",
+			"err.user_brief_batch":                "单次查询的用户数过多",
+			"err.not_room_member":                 "你不是该房间成员",
+			"err.avatar_format":                   "图片格式不支持，仅支持 JPEG/PNG/GIF",
+			"err.avatar_too_large":                "图片过大",
+			"err.avatar_crop":                     "裁剪区域无效",
+			"err.avatar_changed":                  "头像已被修改，请重试",
+			"err.storage_unavailable":             "文件存储未配置",
+			"err.code_delivery":                   "验证码发送失败，请稍后重试",
+			"err.identifier_unsupported":          "暂不支持向该手机号/邮箱发送验证码",
+			"err.send_code_quota":                 "验证码发送次数已达今日上限",
+			"err.send_code_locked":                "请求异常，已暂时限制发送验证码",
+			"err.token_invalid":                   "登录凭证无效或已过期",
+			"err.account_switch_device":           "两个账号需在同一设备登录（device_id 一致）才能切换",
+			"err.account_switch_same":             "已是当前账号",
+			"err.import_too_many":                 "单次最多导入 %d 条消息",
+			"err.provision_too_many":              "单次最多导入 %d 行",
+			"err.department_not_found":            "部门不存在",
+			"err.department_not_empty":            "部门下还有子部门或成员，不能删除",
+			"err.not_colleague":                   "只能与组织架构中的同事发起私聊",
+			"err.muted_user":                      "你已被禁言至 %s",
+			"err.muted_group":                     "群已开启全员禁言至 %s",
+			"err.muted_scheduled":                 "群每日定时禁言中，%s 解除",
+			"err.extra_required":                  "消息扩展缺少 %s",
+			"err.extra_not_allowed":               "该消息类型不支持扩展字段 %s",
+			"err.extra_out_of_range":              "消息扩展 %s 超出范围",
+			"err.extra_too_long":                  "消息扩展 %s 过长",
+			"err.extra_invalid":                   "消息扩展 %s 格式错误",
+			"err.prefs_namespace_invalid":         "设置命名空间格式错误（小写字母、数字、.-_，最长 64）",
+			"err.prefs_data_invalid":              "设置内容必须是 JSON 对象",
+			"err.prefs_too_large":                 "设置内容超过 %d 字节",
+			"err.prefs_too_many":                  "设置命名空间最多 %d 个",
+			"err.prefs_version_conflict":          "设置已在其他设备上修改，请刷新后重试",
+			"err.conv_setting_too_long":           "%s 最长 %d 个字符",
+			"err.announcement_empty":              "公告标题和内容不能为空",
+			"err.announcement_target_invalid":     "公告接收范围只能是 all、rooms 或 online",
+			"err.announcement_rooms_invalid":      "按房间发送公告需指定 1~%d 个房间",
+			"err.conv_tag_invalid":                "标签不能为空，最长 %d 个字符",
+			"err.conv_tag_too_many":               "每个会话最多 %d 个标签，最多使用 %d 个不同标签",
+			"err.conv_tag_not_found":              "标签不存在",
+			"err.conversation_cursor_invalid":     "会话列表游标无效，请重新拉取",
+			"err.room_not_found":                  "房间不存在",
+			"err.private_blocked":                 "你们已互相拉黑/被对方拉黑，无法发送消息",
+			"err.helpdesk_closed":                 "会话已结束",
+			"err.webhook_url_invalid":             "Webhook 地址无效：仅支持公网 http/https 地址，端口限 80/443/8080/8443",
+			"err.user_id_required":                "缺少用户 ID",
+			"err.room_id_required":                "缺少房间 ID",
+			"err.content_required":                "消息内容不能为空",
+			"err.red_packet_type_invalid":         "不支持的红包类型",
+			"err.red_packet_count":                "红包个数需在 1-%d 之间",
+			"err.red_packet_amount":               "红包金额无效",
+			"err.red_packet_fixed_amount":         "普通红包总金额需能被个数整除",
+			"err.red_packet_not_found":            "红包不存在",
+			"err.red_packet_own":                  "不能领取自己发的红包",
+			"err.red_packet_claimed":              "你已经领过该红包",
+			"err.red_packet_expired":              "红包已过期",
+			"err.red_packet_empty":                "红包已被领完",
+			"err.poll_question_required":          "输入投票问题",
+			"err.poll_options":                    "选项数量需在 %d-%d 之间",
+			"err.poll_choice_required":            "请选择选项",
+			"err.poll_not_found":                  "投票不存在",
+			"err.poll_closed":                     "投票已结束",
+			"err.poll_single_choice":              "该投票为单选",
+			"err.poll_max_choices":                "最多选择 %d 项",
+			"err.poll_voted":                      "你已经投过票了",
+			"err.poll_option_invalid":             "选项无效",
+			"err.poll_close_denied":               "只有发起人可以结束投票",
+			"err.helpdesk_not_agent":              "你不是客服",
+			"err.helpdesk_session_not_found":      "会话不存在",
+			"err.helpdesk_transfer_denied":        "只有当前接待客服可以转接",
+			"err.helpdesk_no_agent":               "暂无其他可用客服",
+			"err.helpdesk_transfer_self":          "不能转接给自己",
+			"err.helpdesk_target_not_agent":       "目标用户不是客服",
+			"err.helpdesk_close_denied":           "无权结束该会话",
+			"err.helpdesk_rating":                 "评分需在 1-5 之间",
+			"err.helpdesk_rate_denied":            "无权评价该会话",
+			"err.helpdesk_not_closed":             "会话未结束",
+			"err.helpdesk_rated":                  "已经评价过了",
+			"err.bot_name_required":               "输入机器人名称",
+			"err.bot_not_found":                   "机器人不存在",
+			"err.bot_api_key_missing":             "缺少 API Key",
+			"err.bot_api_key_invalid":             "API Key 无效",
+			"err.bot_disabled":                    "机器人已停用",
+			"err.bot_not_room_member":             "机器人不是该房间成员",
+			"err.auto_reply_pattern_required":     "输入匹配关键词",
+			"err.auto_reply_reply_required":       "输入回复内容",
+			"err.auto_reply_regex":                "正则表达式错误: %s",
+			"err.auto_reply_match_type":           "不支持的匹配方式",
+			"err.auto_reply_target_required":      "需指定房间或机器人",
+			"err.auto_reply_denied":               "仅群主/管理员可以配置自动回复",
+			"err.auto_reply_rule_not_found":       "规则不存在",
+			"err.checkin_group_only":              "仅群聊支持签到",
+			"err.geo_latlng_invalid":              "经纬度无效",
+			"err.geo_location_required":           "请先开启并上报位置",
+			"err.qrcode_invalid":                  "二维码无效",
+			"err.qrcode_expired":                  "二维码已过期",
+			"err.room_stats_owner_only":           "只有群主可以查看群统计",
+			"err.ip_rule_cidr_invalid":            "无效的 IP/CIDR: %s",
+			"err.ip_rule_country_invalid":         "国家代码需为 2 位 ISO 代码",
+			"err.ip_rule_kind_invalid":            "kind 只能是 allow/deny/country",
+			"err.ip_rule_not_found":               "规则不存在",
+			"err.department_name_required":        "部门名称不能为空",
+			"err.department_cycle":                "不能把部门移动到自己的下级部门",
+			"err.chat_self":                       "不能和自己发起私聊",
+			"err.provision_kind_invalid":          "不支持的数据类别 %s",
+			"err.provision_csv_column":            "%s.csv 缺少 %s 列",
+			"err.provision_csv_invalid":           "%s.csv 第 %d 行格式错误: %v",
+			"err.provision_user_not_found":        "用户 %s 不存在",
+			"err.provision_user_duplicate":        "用户 %s 在本批次中重复",
+			"err.provision_friend_self":           "%s 不能添加自己为好友",
+			"err.provision_room_account_required": "缺少 room_account",
+			"err.provision_group_owner_required":  "新建群 %s 缺少 owner",
+			"err.provision_not_group":             "%s 不是群聊",
+			"err.import_external_id":              "第 %d 条消息 external_id 为空或超过 128 字节",
+			"err.import_external_id_duplicate":    "external_id %s 重复",
+			"err.import_sender_required":          "第 %d 条消息缺少 sender_id",
+			"err.import_sent_at_required":         "第 %d 条消息缺少 sent_at",
+			"err.import_sender_not_found":         "发送者 %d 不存在",
+			"err.some_not_room_member":            "部分用户不是群成员",
+			"err.voice_required":                  "语音消息缺少 voice 信息",
+			"err.voice_duration_invalid":          "语音时长无效",
+			"err.voice_too_long":                  "语音时长不能超过 %d 秒",
+			"err.voice_waveform_too_long":         "波形采样点不能超过 %d 个",
+			"err.voice_waveform_value":            "波形采样值需在 0-255 之间",
+			"err.voice_codec":                     "不支持的语音编码: %s",
+			"err.private_orphaned":                "对方已不是你的好友，无法发送消息",
+			"err.wallet_not_configured":           "未配置钱包",
+			"err.forward_target_required":         "请选择转发目标",
+			"err.forward_items_required":          "请选择要转发的消息",
+			"err.forward_mode_invalid":            "不支持的转发方式",
+			"err.suspend_reason_required":         "请填写封禁原因",
+			"err.appeal_content_required":         "请填写申诉内容",
+			"err.appeal_too_long":                 "申诉内容不能超过 %d 个字",
+			"err.appeal_not_needed":               "账号状态正常，无需申诉",
+			"err.appeal_pending":                  "已有待处理的申诉，请耐心等待",
+			"err.appeal_not_found":                "申诉不存在",
+			"err.appeal_handled":                  "申诉已处理",
+
+			"valid.required":         "%s 不能为空",
+			"valid.required_without": "%s 与 %s 至少传一个",
+			"valid.min":              "%s 不能小于 %s",
+			"valid.max":              "%s 不能大于 %s",
+			"valid.gte":              "%s 不能小于 %s",
+			"valid.lte":              "%s 不能大于 %s",
+			"valid.min_len":          "%s 长度不能小于 %s",
+			"valid.max_len":          "%s 长度不能大于 %s",
+			"valid.gte_len":          "%s 长度不能小于 %s",
+			"valid.lte_len":          "%s 长度不能大于 %s",
+			"valid.oneof":            "%s 必须是 [%s] 之一",
+			"valid.invalid":          "%s 格式错误",
+			"valid.type":             "%s 类型错误",
+			"valid.number":           "参数格式错误: %s",
+		},
+		LangEN: {
+			CodeKey(CodeSuccess):            "success",
+			CodeKey(CodeParamError):         "Invalid parameters",
+			CodeKey(CodeUserNotFound):       "User not found",
+			CodeKey(CodePasswordError):      "Invalid account or password",
+			CodeKey(CodeTokenInvalid):       "Session expired, please sign in again",
+			CodeKey(CodePermissionDeny):     "Permission denied",
+			CodeKey(CodeVerifyCodeInvalid):  "Verification code is invalid or expired",
+			CodeKey(CodeRedisNotConfigured): "Redis is not configured",
+			CodeKey(CodeUserAlreadyExists):  "User already exists",
+			CodeKey(CodeAccountSuspended):   "Account is suspended",
+			CodeKey(CodeRateLimited):        "Too many requests, please try again later",
+			CodeKey(CodeCaptchaRequired):    "Captcha verification required",
+			CodeKey(CodeRoomFull):           "The group is full",
+			CodeKey(CodeServerBusy):         "Server is busy, please try again later",
+			CodeKey(CodeMuted):              "You are muted",
+			CodeKey(CodeInternalError):      "Internal server error",
+
+			"err.rate_limited":                    "Too many requests, please try again later",
+			"err.account_disabled":                "Account is suspended",
+			"err.captcha_required":                "Captcha verification required",
+			"err.captcha_invalid":                 "Captcha verification failed",
+			"err.room_full":                       "The group is full",
+			"err.redis_not_configured":            "Redis is not configured",
+			"err.username_required":               "Username is required",
+			"err.password_required":               "Password is required",
+			"err.nickname_required":               "Nickname is required",
+			"err.code_required":                   "Verification code is required",
+			"err.phone_or_email":                  "Phone or email is required",
+			"err.phone_and_email":                 "Provide either phone or email, not both",
+			"err.account_required":                "Account is required",
+			"err.password_or_code":                "Password or verification code is required",
+			"err.password_and_code":               "Provide either password or verification code, not both",
+			"err.identifier_required":             "Identifier is required",
+			"err.purpose_required":                "Purpose is required",
+			"err.new_password_required":           "New password is required",
+			"err.old_password_required":           "Old password is required",
+			"err.old_password_wrong":              "Old password is incorrect",
+			"err.invalid_credentials":             "Invalid account or password",
+			"err.verify_code_invalid":             "Verification code is invalid or expired",
+			"err.user_not_found":                  "User not found",
+			"err.user_exists":                     "User already exists",
+			"err.username_exists":                 "Username already exists: %s",
+			"err.phone_exists":                    "Phone number already exists: %s",
+			"err.email_exists":                    "Email already exists: %s",
+			"err.permission_denied":               "Permission denied",
+			"err.notification_event_type":         "This notification type cannot be muted",
+			"err.conversation_not_found":          "Conversation not found",
+			"err.disappearing_ttl":                "Unsupported disappearing message duration",
+			"err.message_not_found":               "Message not found",
+			"err.view_once_private_only":          "View-once messages are only supported in private chats",
+			"err.view_once_viewed":                "This message has already been viewed",
+			"err.group_not_found":                 "Group not found",
+			"err.join_mode_invalid":               "Unsupported join mode",
+			"err.join_disabled":                   "This group cannot be joined by account",
+			"err.join_answer_wrong":               "Wrong answer to the verification question",
+			"err.join_question_required":          "Verification question and answer are required",
+			"err.join_request_handled":            "This request has already been handled",
+			"err.room_tags_invalid":               "At most 10 tags, each up to 20 characters",
+			"err.privacy_search_mode":             "Unsupported search visibility",
+			"err.friend_source_invalid":           "Unsupported friend request source",
+			"err.friend_source_room":              "Both users must be in the same group",
+			"err.friend_apply_disabled":           "This user does not accept friend requests",
+			"err.friend_source_denied":            "This user does not accept friend requests from this source",
+			"err.reminder_time":                   "Reminder time must be in the future",
+			"err.reminder_limit":                  "Too many pending message reminders",
+			"err.user_brief_batch":                "Too many user IDs in one request",
+			"err.not_room_member":                 "You are not a member of this room",
+			"err.avatar_format":                   "Unsupported image format, only JPEG/PNG/GIF are allowed",
+			"err.avatar_too_large":                "Image is too large",
+			"err.avatar_crop":                     "Invalid crop area",
+			"err.avatar_changed":                  "Avatar was changed concurrently, please retry",
+			"err.storage_unavailable":             "File storage is not configured",
+			"err.code_delivery":                   "Failed to deliver the verification code, please retry later",
+			"err.identifier_unsupported":          "Verification codes cannot be delivered to this phone number or email",
+			"err.send_code_quota":                 "Daily verification code limit reached",
+			"err.send_code_locked":                "Verification codes are temporarily blocked due to suspicious activity",
+			"err.token_invalid":                   "Token is invalid or expired",
+			"err.account_switch_device":           "Both accounts must be signed in on the same device (device_id)",
+			"err.account_switch_same":             "Already signed in as this account",
+			"err.import_too_many":                 "At most %d messages can be imported per request",
+			"err.provision_too_many":              "At most %d rows can be provisioned per request",
+			"err.department_not_found":            "Department not found",
+			"err.department_not_empty":            "The department still has sub-departments or members",
+			"err.not_colleague":                   "You can only start chats with members of the organization",
+			"err.muted_user":                      "You are muted until %s",
+			"err.muted_group":                     "All members are muted until %s",
+			"err.muted_scheduled":                 "Scheduled group mute is in effect until %s",
+			"err.extra_required":                  "Message extra is missing %s",
+			"err.extra_not_allowed":               "Extra field %s is not allowed for this message type",
+			"err.extra_out_of_range":              "Message extra %s is out of range",
+			"err.extra_too_long":                  "Message extra %s is too long",
+			"err.extra_invalid":                   "Message extra %s is malformed",
+			"err.prefs_namespace_invalid":         "Invalid settings namespace (lowercase letters, digits, '.', '-', '_', up to 64)",
+			"err.prefs_data_invalid":              "Settings data must be a JSON object",
+			"err.prefs_too_large":                 "Settings data exceeds %d bytes",
+			"err.prefs_too_many":                  "At most %d settings namespaces are allowed",
+			"err.prefs_version_conflict":          "Settings were changed on another device; reload and retry",
+			"err.conv_setting_too_long":           "%s must be at most %d characters",
+			"err.announcement_empty":              "Announcement title and content are required",
+			"err.announcement_target_invalid":     "Announcement target must be all, rooms or online",
+			"err.announcement_rooms_invalid":      "Room announcements require 1 to %d rooms",
+			"err.conv_tag_invalid":                "Tag must be 1 to %d characters",
+			"err.conv_tag_too_many":               "At most %d tags per conversation and %d distinct tags are allowed",
+			"err.conv_tag_not_found":              "Tag not found",
+			"err.conversation_cursor_invalid":     "Invalid conversation cursor; reload the list",
+			"err.room_not_found":                  "Room not found",
+			"err.private_blocked":                 "You cannot message this user because one of you has blocked the other",
+			"err.helpdesk_closed":                 "This support session has ended",
+			"err.webhook_url_invalid":             "Invalid webhook URL: only public http/https addresses on ports 80/443/8080/8443 are allowed",
+			"err.user_id_required":                "User ID is required",
+			"err.room_id_required":                "Room ID is required",
+			"err.content_required":                "Message content is required",
+			"err.red_packet_type_invalid":         "Unsupported red packet type",
+			"err.red_packet_count":                "Red packet count must be between 1 and %d",
+			"err.red_packet_amount":               "Invalid red packet amount",
+			"err.red_packet_fixed_amount":         "The total of a fixed red packet must be divisible by its count",
+			"err.red_packet_not_found":            "Red packet not found",
+			"err.red_packet_own":                  "You cannot claim your own red packet",
+			"err.red_packet_claimed":              "You have already claimed this red packet",
+			"err.red_packet_expired":              "This red packet has expired",
+			"err.red_packet_empty":                "This red packet has been fully claimed",
+			"err.poll_question_required":          "Poll question is required",
+			"err.poll_options":                    "A poll needs between %d and %d options",
+			"err.poll_choice_required":            "Please choose an option",
+			"err.poll_not_found":                  "Poll not found",
+			"err.poll_closed":                     "This poll has ended",
+			"err.poll_single_choice":              "This poll allows only one choice",
+			"err.poll_max_choices":                "You can choose at most %d options",
+			"err.poll_voted":                      "You have already voted",
+			"err.poll_option_invalid":             "Invalid option",
+			"err.poll_close_denied":               "Only the creator can end this poll",
+			"err.helpdesk_not_agent":              "You are not a support agent",
+			"err.helpdesk_session_not_found":      "Support session not found",
+			"err.helpdesk_transfer_denied":        "Only the current agent can transfer this session",
+			"err.helpdesk_no_agent":               "No other agent is available",
+			"err.helpdesk_transfer_self":          "You cannot transfer a session to yourself",
+			"err.helpdesk_target_not_agent":       "The target user is not a support agent",
+			"err.helpdesk_close_denied":           "You cannot close this session",
+			"err.helpdesk_rating":                 "Rating must be between 1 and 5",
+			"err.helpdesk_rate_denied":            "You cannot rate this session",
+			"err.helpdesk_not_closed":             "The session has not ended yet",
+			"err.helpdesk_rated":                  "This session has already been rated",
+			"err.bot_name_required":               "Bot name is required",
+			"err.bot_not_found":                   "Bot not found",
+			"err.bot_api_key_missing":             "Missing API key",
+			"err.bot_api_key_invalid":             "Invalid API key",
+			"err.bot_disabled":                    "This bot is disabled",
+			"err.bot_not_room_member":             "The bot is not a member of this room",
+			"err.auto_reply_pattern_required":     "Match pattern is required",
+			"err.auto_reply_reply_required":       "Reply content is required",
+			"err.auto_reply_regex":                "Invalid regular expression: %s",
+			"err.auto_reply_match_type":           "Unsupported match type",
+			"err.auto_reply_target_required":      "A room or bot is required",
+			"err.auto_reply_denied":               "Only the group owner or admins can configure auto replies",
+			"err.auto_reply_rule_not_found":       "Auto reply rule not found",
+			"err.checkin_group_only":              "Check-in is only available in groups",
+			"err.geo_latlng_invalid":              "Invalid latitude/longitude",
+			"err.geo_location_required":           "Please enable and report your location first",
+			"err.qrcode_invalid":                  "Invalid QR code",
+			"err.qrcode_expired":                  "This QR code has expired",
+			"err.room_stats_owner_only":           "Only the group owner can view group statistics",
+			"err.ip_rule_cidr_invalid":            "Invalid IP/CIDR: %s",
+			"err.ip_rule_country_invalid":         "Country code must be a 2-letter ISO code",
+			"err.ip_rule_kind_invalid":            "kind must be allow, deny or country",
+			"err.ip_rule_not_found":               "IP rule not found",
+			"err.department_name_required":        "Department name is required",
+			"err.department_cycle":                "A department cannot be moved under its own sub-department",
+			"err.chat_self":                       "You cannot start a chat with yourself",
+			"err.provision_kind_invalid":          "Unsupported data kind %s",
+			"err.provision_csv_column":            "%s.csv is missing the %s column",
+			"err.provision_csv_invalid":           "%s.csv line %d is malformed: %v",
+			"err.provision_user_not_found":        "User %s does not exist",
+			"err.provision_user_duplicate":        "User %s appears more than once in this batch",
+			"err.provision_friend_self":           "%s cannot be their own friend",
+			"err.provision_room_account_required": "room_account is required",
+			"err.provision_group_owner_required":  "New group %s requires an owner",
+			"err.provision_not_group":             "%s is not a group",
+			"err.import_external_id":              "Message %d: external_id is empty or longer than 128 bytes",
+			"err.import_external_id_duplicate":    "Duplicate external_id %s",
+			"err.import_sender_required":          "Message %d: sender_id is required",
+			"err.import_sent_at_required":         "Message %d: sent_at is required",
+			"err.import_sender_not_found":         "Sender %d does not exist",
+			"err.some_not_room_member":            "Some users are not members of this group",
+			"err.voice_required":                  "Voice message is missing voice info",
+			"err.voice_duration_invalid":          "Invalid voice duration",
+			"err.voice_too_long":                  "Voice message must be at most %d seconds",
+			"err.voice_waveform_too_long":         "Waveform must have at most %d samples",
+			"err.voice_waveform_value":            "Waveform samples must be between 0 and 255",
+			"err.voice_codec":                     "Unsupported voice codec: %s",
+			"err.private_orphaned":                "You are no longer friends with this user and cannot send messages",
+			"err.wallet_not_configured":           "Wallet is not configured",
+			"err.forward_target_required":         "Forward target rooms are required",
+			"err.forward_items_required":          "Messages to forward are required",
+			"err.forward_mode_invalid":            "Unsupported forward mode",
+			"err.suspend_reason_required":         "Suspension reason is required",
+			"err.appeal_content_required":         "Appeal content is required",
+			"err.appeal_too_long":                 "Appeal content must be at most %d characters",
+			"err.appeal_not_needed":               "The account is active and does not need an appeal",
+			"err.appeal_pending":                  "An appeal is already pending",
+			"err.appeal_not_found":                "Appeal not found",
+			"err.appeal_handled":                  "This appeal has already been handled",
+
+			"valid.required":         "%s is required",
+			"valid.required_without": "either %s or %s is required",
+			"valid.min":              "%s must be at least %s",
+			"valid.max":              "%s must be at most %s",
+			"valid.gte":              "%s must be at least %s",
+			"valid.lte":              "%s must be at most %s",
+			"valid.min_len":          "%s must contain at least %s characters/items",
+			"valid.max_len":          "%s must contain at most %s characters/items",
+			"valid.gte_len":          "%s must contain at least %s characters/items",
+			"valid.lte_len":          "%s must contain at most %s characters/items",
+			"valid.oneof":            "%s must be one of [%s]",
+			"valid.invalid":          "%s is invalid",
+			"valid.type":             "%s has the wrong type",
+			"valid.number":           "Invalid number: %s",
+		},
+	}
+)
+
+// CodeKey 业务码对应的通用文案 key
+func CodeKey(code int) string {
+	return "code." + strconv.Itoa(code)
+}
+
+// RegisterMessages 注册/覆盖某种语言的文案（可用于新增语言，key 同内置目录）
+func RegisterMessages(lang string, msgs map[string]string) {
+	lang = normalizeLang(lang)
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	m := catalog[lang]
+	if m == nil {
+		m = make(map[string]string, len(msgs))
+		catalog[lang] = m
+	}
+	for k, v := range msgs {
+		m[k] = v
+	}
+}
+
+// Translate 按语言渲染文案：缺失时回退到 DefaultLang，再回退到 key 本身
+func Translate(lang, key string, args ...any) string {
+	catalogMu.RLock()
+	tpl, ok := catalog[normalizeLang(lang)][key]
+	if !ok {
+		tpl, ok = catalog[DefaultLang][key]
+	}
+	catalogMu.RUnlock()
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return tpl
+	}
+	return fmt.Sprintf(tpl, args...)
+}
+
+// SupportsLang 是否已有该语言的文案
+func SupportsLang(lang string) bool {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	_, ok := catalog[normalizeLang(lang)]
+	return ok
+}
+
+// ParseAcceptLanguage 按 Accept-Language 的顺序挑出第一个受支持的语言（忽略 q 权重），都不支持时返回 DefaultLang
+func ParseAcceptLanguage(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		if lang := normalizeLang(tag); SupportsLang(lang) {
+			return lang
+		}
+	}
+	return DefaultLang
+}
+
+// normalizeLang "zh-CN" / "en_US" -> "zh" / "en"
+func normalizeLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
@@ -14,9 +14,7 @@ type Response struct {
 }
 
 // 业务状态码定义
-// 使用说明：
-// - 中间件层：使用 HTTP 状态码（401/403/500）
-// - 业务层：HTTP 200 + 业务状态码
+// HTTP 状态码由业务码映射得到（见 HTTPStatus），开启 LegacyHTTPStatus 后统一返回 200
 const (
 	CodeSuccess        = 0     // 成功
 	CodeParamError     = 10001 // 参数错误
@@ -28,6 +26,12 @@ const (
 	CodeVerifyCodeInvalid  = 10006 // 验证码错误/过期
 	CodeRedisNotConfigured = 10007 // 未配置 Redis
 	CodeUserAlreadyExists  = 10008 // 用户已存在（username/phone/email 冲突）
+	CodeAccountSuspended   = 10009 // 账号已被暂停/封禁
+	CodeRateLimited        = 10010 // 操作过于频繁（反垃圾限制）
+	CodeCaptchaRequired    = 10011 // 需要人机验证/人机验证未通过
+	CodeRoomFull           = 10012 // 群成员已达上限
+	CodeServerBusy         = 10013 // 服务器繁忙（过载保护），稍后重试
+	CodeMuted              = 10014 // 已被禁言（个人/全员/定时），data 中带截止时间
 
 	CodeInternalError = 99999 // 内部错误
 )
@@ -45,25 +49,27 @@ func Success(data interface{}, args ...string) *Response {
 	}
 }
 
-// Error 错误响应
+// Error 错误响应（msg 为空时使用 DefaultLang 下该业务码的通用文案）
 func Error(code int, msg string) *Response {
+	if msg == "" {
+		msg = Translate(DefaultLang, CodeKey(code))
+	}
 	return &Response{
 		Code: code,
 		Msg:  msg,
 	}
 }
 
-// WriteJSON 写入 JSON 响应（默认 HTTP 200）
+// WriteJSON 写入 JSON 响应（HTTP 状态码按业务码映射）
 func (r *Response) WriteJSON(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK) // 业务层统一返回 200
+	w.WriteHeader(HTTPStatus(r.Code))
 	if err := json.NewEncoder(w).Encode(r); err != nil {
 		log.Printf("Failed to encode response: %v", err)
 	}
 }
 
-// WriteJSONWithStatus 写入 JSON 响应（指定 HTTP 状态码）
-// 用于中间件层面的鉴权失败等场景（如 401）
+// WriteJSONWithStatus 写入 JSON 响应（指定 HTTP 状态码，不受 LegacyHTTPStatus 影响）
 func (r *Response) WriteJSONWithStatus(w http.ResponseWriter, httpStatus int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(httpStatus)
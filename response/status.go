@@ -0,0 +1,37 @@
+package response
+
+import "net/http"
+
+// LegacyHTTPStatus 为 true 时所有响应都返回 HTTP 200，仅靠 body.code 区分结果（兼容老客户端，见 chat_sdk.WithLegacyHTTPStatus）
+var LegacyHTTPStatus bool
+
+// httpStatus 业务码 -> HTTP 状态码
+var httpStatus = map[int]int{
+	CodeSuccess:            http.StatusOK,
+	CodeParamError:         http.StatusBadRequest,
+	CodeUserNotFound:       http.StatusNotFound,
+	CodePasswordError:      http.StatusUnauthorized,
+	CodeTokenInvalid:       http.StatusUnauthorized,
+	CodePermissionDeny:     http.StatusForbidden,
+	CodeVerifyCodeInvalid:  http.StatusBadRequest,
+	CodeRedisNotConfigured: http.StatusServiceUnavailable,
+	CodeUserAlreadyExists:  http.StatusConflict,
+	CodeAccountSuspended:   http.StatusForbidden,
+	CodeRateLimited:        http.StatusTooManyRequests,
+	CodeCaptchaRequired:    http.StatusForbidden,
+	CodeRoomFull:           http.StatusConflict,
+	CodeServerBusy:         http.StatusServiceUnavailable,
+	CodeMuted:              http.StatusForbidden,
+	CodeInternalError:      http.StatusInternalServerError,
+}
+
+// HTTPStatus 返回业务码对应的 HTTP 状态码；未登记的错误码按 500 处理
+func HTTPStatus(code int) int {
+	if LegacyHTTPStatus {
+		return http.StatusOK
+	}
+	if s, ok := httpStatus[code]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,335 @@
+package chat_sdk
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cydxin/chat-sdk/middleware"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RouteOptions RegisterRoutes / RegisterGinRoutes 的配置
+type RouteOptions struct {
+	// Prefix 接口前缀，默认 /api/v1
+	Prefix string
+	// WSPath WebSocket 路径（token 鉴权，支持 ?token=），默认 /ws；设为 "-" 不注册
+	WSPath string
+	// Auth 用户鉴权配置（HeaderKey/QueryKey），UserIDKey 需保持默认 user_id
+	Auth *middleware.AuthOptions
+	// DisableSecurity 不挂 IP 黑白名单中间件
+	DisableSecurity bool
+}
+
+func (o *RouteOptions) withDefaults() RouteOptions {
+	var out RouteOptions
+	if o != nil {
+		out = *o
+	}
+	if out.Prefix == "" {
+		out.Prefix = "/api/v1"
+	}
+	out.Prefix = "/" + strings.Trim(out.Prefix, "/")
+	if out.WSPath == "" {
+		out.WSPath = "/ws"
+	}
+	return out
+}
+
+// RegisterRoutes 把完整 API（与 Gin handler 同一套实现）挂到标准库 ServeMux，供不使用 Gin 的项目直接接入。
+// 用户接口已挂 token 鉴权，/admin/* 使用 X-Admin-Token，/bot/send 使用机器人 API Key。
+//
+// 使用示例:
+//
+//	mux := http.NewServeMux()
+//	engine.RegisterRoutes(mux, nil)
+//	http.ListenAndServe(":8080", mux)
+func (c *ChatEngine) RegisterRoutes(mux *http.ServeMux, opt *RouteOptions) {
+	cfg := opt.withDefaults()
+	h := c.Handler(&cfg)
+	mux.Handle(cfg.Prefix+"/", h)
+	if cfg.WSPath != "-" {
+		mux.Handle(cfg.WSPath, h)
+	}
+}
+
+// Handler 返回挂好完整 API 的 http.Handler（可再包一层自己的中间件，或交给其他框架的 http.Handler 适配器）
+func (c *ChatEngine) Handler(opt *RouteOptions) http.Handler {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	c.RegisterGinRoutes(r, opt)
+	return r
+}
+
+// RegisterGinRoutes 在已有 Gin 路由上注册完整 API（含各类鉴权中间件），路由与 example/main.go 一致
+func (c *ChatEngine) RegisterGinRoutes(r gin.IRouter, opt *RouteOptions) {
+	cfg := opt.withDefaults()
+	if !cfg.DisableSecurity {
+		r = r.Group("", c.GinSecurityMiddleware())
+	}
+	auth := c.GinAuthMiddleware(cfg.Auth)
+
+	if cfg.WSPath != "-" {
+		r.GET(cfg.WSPath, auth, c.ginHandleWS)
+	}
+
+	api := r.Group(cfg.Prefix)
+
+	// 免登录接口
+	public := api.Group("")
+	{
+		public.POST("/user/register", c.GinHandleUserRegister)
+		public.POST("/user/login", c.GinHandleUserLogin)
+		public.POST("/user/code/send", c.GinHandleSendVerifyCode)
+		public.POST("/user/password/forgot", c.GinHandleForgotPassword)
+		public.POST("/user/appeal", c.GinHandleSubmitAppeal)
+		public.POST("/helpdesk/visitor", c.GinHandleCreateVisitor)
+		public.GET("/time", c.GinHandleServerTime)
+	}
+
+	// 机器人发消息（API Key 鉴权）
+	api.POST("/bot/send", c.GinBotAuthMiddleware(), c.GinHandleBotSendMessage)
+
+	// 运维管理（X-Admin-Token 鉴权）
+	admin := api.Group("/admin", c.GinAdminAuthMiddleware())
+	{
+		admin.GET("/stats", c.GinHandleAdminStats)
+		admin.GET("/delivery/sla", c.GinHandleAdminDeliverySLA)
+		if c.LoadShedder != nil {
+			admin.GET("/load", c.GinHandleAdminLoadStatus)
+		}
+		admin.POST("/user/suspend", c.GinHandleAdminSuspendUser)
+		admin.POST("/user/reinstate", c.GinHandleAdminReinstateUser)
+		admin.GET("/appeals", c.GinHandleAdminListAppeals)
+		admin.POST("/appeal/handle", c.GinHandleAdminHandleAppeal)
+		admin.GET("/spam/violations", c.GinHandleAdminSpamViolations)
+		admin.GET("/ws/connections", c.GinHandleAdminWsConnections)
+		admin.GET("/security/rules", c.GinHandleAdminListIPRules)
+		admin.POST("/security/rule/add", c.GinHandleAdminAddIPRule)
+		admin.POST("/security/rule/delete", c.GinHandleAdminDeleteIPRule)
+		admin.POST("/message/import", c.GinHandleAdminImportMessages)
+		admin.POST("/announcement", c.GinHandleAdminAnnouncement)
+		admin.POST("/provision", c.GinHandleAdminProvision)
+		admin.GET("/provision/export", c.GinHandleAdminProvisionExport)
+		if c.OrgService != nil {
+			admin.POST("/org/department/create", c.GinHandleAdminCreateDepartment)
+			admin.POST("/org/department/update", c.GinHandleAdminUpdateDepartment)
+			admin.POST("/org/department/delete", c.GinHandleAdminDeleteDepartment)
+			admin.POST("/org/department/group", c.GinHandleAdminSetDepartmentGroup)
+			admin.POST("/org/department/sync", c.GinHandleAdminSyncDepartmentGroup)
+			admin.POST("/org/member/add", c.GinHandleAdminAddOrgMembers)
+			admin.POST("/org/member/remove", c.GinHandleAdminRemoveOrgMembers)
+		}
+	}
+
+	// 合规归档导出（X-Compliance-Token 鉴权，WithComplianceArchive 开启后才有）
+	if c.ComplianceService != nil {
+		api.GET("/compliance/archive", c.GinComplianceAuthMiddleware(), c.GinHandleComplianceExportArchive)
+	}
+
+	// 以下需要用户 token
+	user := api.Group("", auth)
+	// 房间级权限：按 room_id 校验成员身份/角色，需在用户鉴权之后
+	memberOnly := c.GinRoomMemberGuard(models.RoomRoleMember)
+	adminOnly := c.GinRoomMemberGuard(models.RoomRoleAdmin)
+	ownerOnly := c.GinRoomMemberGuard(models.RoomRoleOwner)
+	// 非核心接口（动态、搜索、统计等），过载时返回 503，见 WithLoadShedding
+	shed := c.GinLoadShedMiddleware()
+
+	messageAPI := user.Group("/message")
+	{
+		messageAPI.GET("/conversations", c.GinHandleGetMessageConversations)
+		messageAPI.GET("/conversations/page", c.GinHandleGetConversationPage)
+		messageAPI.GET("/conversations/updates", c.GinHandleGetConversationUpdates)
+		messageAPI.POST("/conversation/hide", c.GinHandleHideConversation)
+		messageAPI.GET("/conversations/archived", c.GinHandleGetArchivedConversations)
+		messageAPI.GET("/conversations/unread-total", c.GinHandleConversationUnreadTotal)
+		messageAPI.POST("/conversation/archive", c.GinHandleArchiveConversation)
+		messageAPI.POST("/conversation/mute", c.GinHandleMuteConversation)
+		messageAPI.GET("/conversation/settings", c.GinHandleGetConversationSettings)
+		messageAPI.POST("/conversation/settings", c.GinHandleUpdateConversationSettings)
+		messageAPI.GET("/conversation/tags", c.GinHandleListConversationTags)
+		messageAPI.POST("/conversation/tags", c.GinHandleSetConversationTags)
+		messageAPI.POST("/conversation/tag/rename", c.GinHandleRenameConversationTag)
+		messageAPI.POST("/conversation/tag/delete", c.GinHandleDeleteConversationTag)
+		messageAPI.POST("/view-once/open", c.GinHandleOpenViewOnceMessage)
+		messageAPI.POST("/reminder", c.GinHandleSetMessageReminder)
+		messageAPI.POST("/reminder/cancel", c.GinHandleCancelMessageReminder)
+		messageAPI.GET("/reminders", c.GinHandleListMessageReminders)
+		messageAPI.GET("/list", memberOnly, c.GinHandleGetRoomMessages)
+		messageAPI.GET("/search-in-room", shed, memberOnly, c.GinHandleSearchInRoom)
+		messageAPI.GET("/context", memberOnly, c.GinHandleGetMessageContext)
+		messageAPI.GET("/detail", c.GinHandleGetMessageByID)
+		messageAPI.GET("/receipts", c.GinHandleGetMessageReceipts)
+		messageAPI.POST("/send", c.GinHandleSendMessage)
+		messageAPI.POST("/recall", c.GinHandleRecallMessage)
+		messageAPI.POST("/forward", c.GinHandleForwardMessages)
+		messageAPI.GET("/poll", c.GinHandlePollMessages)
+	}
+
+	userAPI := user.Group("/user")
+	{
+		userAPI.GET("/info", c.GinHandleGetUserInfo)
+		userAPI.POST("/update", c.GinHandleUpdateUserInfo)
+		userAPI.POST("/avatar", c.GinHandleUpdateUserAvatar)
+		userAPI.POST("/avatar/upload", c.GinHandleUploadUserAvatar)
+		userAPI.POST("/password", c.GinHandleUpdateUserPassword)
+		userAPI.GET("/search", shed, c.GinHandleSearchUsers)
+		userAPI.GET("/briefs", c.GinHandleGetUserBriefs)
+		userAPI.POST("/location", c.GinHandleUpdateLocation)
+		userAPI.POST("/location/clear", c.GinHandleClearLocation)
+		userAPI.GET("/nearby", shed, c.GinHandleNearbyUsers)
+		userAPI.GET("/common-groups", c.GinHandleCommonGroups)
+		userAPI.GET("/qrcode", c.GinHandleUserQRCode)
+		userAPI.GET("/namecard", c.GinHandleUserNamecard)
+		userAPI.GET("/privacy", c.GinHandleGetPrivacy)
+		userAPI.POST("/privacy", c.GinHandleUpdatePrivacy)
+		userAPI.GET("/settings", c.GinHandleGetUserSettings)
+		userAPI.POST("/settings", c.GinHandleUpdateUserSettings)
+		userAPI.GET("/prefs", c.GinHandleGetPrefs)
+		userAPI.POST("/prefs", c.GinHandlePatchPrefs)
+		userAPI.GET("/token", c.GinHandleIntrospectToken)
+		userAPI.POST("/logout", c.GinHandleLogout)
+		userAPI.POST("/account/switch", c.GinHandleSwitchAccount)
+	}
+	user.GET("/member/search", shed, c.GinHandleMemberSearchUsers)
+
+	friendAPI := user.Group("/friend")
+	{
+		friendAPI.POST("/request", c.GinHandleSendFriendRequest)
+		friendAPI.POST("/accept", c.GinHandleAcceptFriendRequest)
+		friendAPI.POST("/reject", c.GinHandleRejectFriendRequest)
+		friendAPI.POST("/delete", c.GinHandleDeleteFriend)
+		friendAPI.POST("/remark", c.GinHandleSetFriendRemark)
+		friendAPI.POST("/star", c.GinHandleSetFriendStar)
+		friendAPI.GET("/list", c.GinHandleGetFriendList)
+		friendAPI.GET("/starred", c.GinHandleGetStarredFriends)
+		friendAPI.GET("/sections", c.GinHandleGetFriendSections)
+		friendAPI.GET("/pending", c.GinHandleGetPendingRequests)
+		friendAPI.GET("/check", c.GinHandleCheckFriendship)
+		friendAPI.GET("/mutual", c.GinHandleMutualFriends)
+		friendAPI.POST("/add-by-qr", c.GinHandleAddFriendByQR)
+	}
+
+	momentAPI := user.Group("/moment", shed)
+	{
+		momentAPI.POST("/create", c.GinHandleCreateMoment)
+		momentAPI.GET("/list", c.GinHandleListFriendMoments)
+		momentAPI.POST("/comment", c.GinHandleCommentMoment)
+		momentAPI.GET("/comment/list", c.GinHandleListMomentComments)
+	}
+
+	notifyAPI := user.Group("/notification")
+	{
+		notifyAPI.GET("/list", c.GinHandleListNotifications)
+		notifyAPI.POST("/read", c.GinHandleMarkNotificationsRead)
+		notifyAPI.POST("/read-all", c.GinHandleMarkAllNotificationsRead)
+		notifyAPI.GET("/unread-count", c.GinHandleNotificationUnreadCount)
+	}
+
+	roomAPI := user.Group("/room")
+	{
+		roomAPI.POST("/private", c.GinHandleCreatePrivateRoom)
+		roomAPI.POST("/group", c.GinHandleCreateGroupRoom)
+		roomAPI.GET("/group/info", memberOnly, c.GinHandleGetGroupInfo)
+		roomAPI.GET("/online", memberOnly, c.GinHandleRoomOnline)
+		roomAPI.POST("/group/update", adminOnly, c.GinHandleUpdateGroupInfo)
+		roomAPI.POST("/group/avatar/upload", adminOnly, c.GinHandleUploadGroupAvatar)
+		roomAPI.GET("/group/quit", c.GinHandleQuitGroup)
+		roomAPI.GET("/list", c.GinHandleGetUserRooms)
+		roomAPI.GET("/group/list", c.GinHandleGetGroupRooms)
+		roomAPI.POST("/group/save", c.GinHandleSaveGroup)
+		roomAPI.GET("/group/saved", c.GinHandleGetSavedGroups)
+		roomAPI.GET("/member/list", memberOnly, c.GinHandleGetRoomMemberList)
+		roomAPI.GET("/member/search", shed, memberOnly, c.GinHandleSearchRoomMembers)
+		roomAPI.GET("/member/check", c.GinHandleCheckRoomMember)
+		roomAPI.POST("/member/nickname", c.GinHandleSetMyGroupNickname)
+		roomAPI.POST("/member/add", c.GinHandleAddRoomMember)
+		roomAPI.POST("/member/remove", c.GinHandleRemoveRoomMember)
+		roomAPI.POST("/admin/set", ownerOnly, c.GinHandleSetGroupAdmin)
+		roomAPI.POST("/mute/group", adminOnly, c.GinHandleSetGroupMute)
+		roomAPI.POST("/mute/group/scheduled", adminOnly, c.GinHandleSetGroupMuteScheduled)
+		roomAPI.POST("/mute/user", c.GinHandleSetUserMute)
+		roomAPI.GET("/mute/settings", memberOnly, c.GinHandleGetMuteSettings)
+		roomAPI.GET("/mute/list", memberOnly, c.GinHandleListMutedMembers)
+		roomAPI.GET("/audit", adminOnly, c.GinHandleListRoomAudit)
+		roomAPI.POST("/mute/admins", ownerOnly, c.GinHandleSetGroupMuteAdmins)
+		roomAPI.POST("/mute/exempt", adminOnly, c.GinHandleSetMuteExempt)
+		roomAPI.POST("/disappearing", c.GinHandleSetRoomDisappearing)
+		roomAPI.GET("/join/lookup", c.GinHandleLookupGroupByAccount)
+		roomAPI.POST("/join-by-account", c.GinHandleJoinByAccount)
+		roomAPI.POST("/join/verification", c.GinHandleSetJoinVerification)
+		roomAPI.GET("/join/requests", c.GinHandleListJoinRequests)
+		roomAPI.POST("/join/handle", c.GinHandleHandleJoinRequest)
+		roomAPI.POST("/discovery", c.GinHandleSetRoomDiscovery)
+		roomAPI.GET("/discover", shed, c.GinHandleDiscoverGroups)
+		roomAPI.POST("/checkin", c.GinHandleRoomCheckIn)
+		roomAPI.GET("/checkin/leaderboard", shed, memberOnly, c.GinHandleRoomCheckInLeaderboard)
+		roomAPI.GET("/stats", shed, ownerOnly, c.GinHandleRoomStats)
+		roomAPI.GET("/stats/senders", shed, ownerOnly, c.GinHandleRoomTopSenders)
+	}
+
+	botAPI := user.Group("/bot")
+	{
+		botAPI.POST("/create", c.GinHandleCreateBot)
+		botAPI.GET("/list", c.GinHandleListBots)
+		botAPI.POST("/key/reset", c.GinHandleResetBotKey)
+		botAPI.POST("/webhook", c.GinHandleUpdateBotWebhook)
+	}
+
+	autoReplyAPI := user.Group("/autoreply")
+	{
+		autoReplyAPI.POST("/create", c.GinHandleCreateAutoReplyRule)
+		autoReplyAPI.POST("/update", c.GinHandleUpdateAutoReplyRule)
+		autoReplyAPI.POST("/delete", c.GinHandleDeleteAutoReplyRule)
+		autoReplyAPI.GET("/list", c.GinHandleListAutoReplyRules)
+	}
+
+	helpDeskAPI := user.Group("/helpdesk")
+	{
+		helpDeskAPI.POST("/open", c.GinHandleOpenHelpDeskSession)
+		helpDeskAPI.POST("/claim", c.GinHandleClaimHelpDeskSession)
+		helpDeskAPI.POST("/transfer", c.GinHandleTransferHelpDeskSession)
+		helpDeskAPI.POST("/close", c.GinHandleCloseHelpDeskSession)
+		helpDeskAPI.POST("/rate", c.GinHandleRateHelpDeskSession)
+		helpDeskAPI.GET("/sessions", c.GinHandleListHelpDeskSessions)
+	}
+
+	redPacketAPI := user.Group("/redpacket")
+	{
+		redPacketAPI.POST("/send", c.GinHandleSendRedPacket)
+		redPacketAPI.POST("/claim", c.GinHandleClaimRedPacket)
+		redPacketAPI.GET("/detail", c.GinHandleGetRedPacket)
+	}
+
+	pollAPI := user.Group("/poll")
+	{
+		pollAPI.POST("/create", c.GinHandleCreatePoll)
+		pollAPI.POST("/vote", c.GinHandleVotePoll)
+		pollAPI.POST("/close", c.GinHandleClosePoll)
+		pollAPI.GET("/detail", c.GinHandleGetPoll)
+	}
+
+	// 组织架构（WithOrgDirectory(true) 开启后注册）
+	if c.OrgService != nil {
+		orgAPI := user.Group("/org")
+		orgAPI.GET("/tree", c.GinHandleOrgTree)
+		orgAPI.GET("/department/members", c.GinHandleOrgMembers)
+		orgAPI.GET("/my", c.GinHandleMyDepartments)
+		orgAPI.POST("/chat", c.GinHandleOrgStartChat)
+	}
+}
+
+// ginHandleWS token 鉴权后的 WebSocket 升级（user_id 由鉴权中间件写入）
+func (c *ChatEngine) ginHandleWS(ctx *gin.Context) {
+	uid, exists := ctx.Get(middleware.ContextUserIDKey)
+	if !exists {
+		return
+	}
+	// 连接绑定到登录 token，登出/封禁时立即断开
+	if token := ctx.GetString(middleware.ContextTokenKey); token != "" {
+		c.ServeWSWithToken(ctx.Writer, ctx.Request, token, uid.(uint64), ctx.Query("name"))
+		return
+	}
+	c.ServeWS(ctx.Writer, ctx.Request, uid.(uint64), ctx.Query("name"))
+}
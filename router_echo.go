@@ -0,0 +1,27 @@
+package chat_sdk
+
+import (
+	"github.com/cydxin/chat-sdk/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterEcho 在 Echo 上注册完整 API（与 RegisterGinRoutes 同一套 handler、鉴权与路由）
+//
+// 使用示例:
+//
+//	e := echo.New()
+//	engine.RegisterEcho(e, nil)
+//	e.Start(":8080")
+func (c *ChatEngine) RegisterEcho(e *echo.Echo, opt *RouteOptions) {
+	cfg := opt.withDefaults()
+	h := echo.WrapHandler(c.Handler(&cfg))
+	e.Any(cfg.Prefix+"/*", h)
+	if cfg.WSPath != "-" {
+		e.GET(cfg.WSPath, h)
+	}
+}
+
+// EchoAuthMiddleware 返回配置好的 Echo 鉴权中间件，供自定义路由使用（user_id 通过 c.Get 读取）
+func (c *ChatEngine) EchoAuthMiddleware(opt *middleware.AuthOptions) echo.MiddlewareFunc {
+	return middleware.EchoAuthMiddleware(c.AuthService, opt)
+}
@@ -0,0 +1,27 @@
+package chat_sdk
+
+import (
+	"github.com/cydxin/chat-sdk/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// RegisterFiber 在 Fiber 上注册完整 API（与 RegisterGinRoutes 同一套 handler、鉴权与路由）。
+// fasthttp 不支持 net/http 的连接劫持，WebSocket 不经过 Fiber：请另起 net/http 监听挂 RegisterRoutes，
+// 或让客户端降级到 /message/poll 长轮询。
+//
+// 使用示例:
+//
+//	app := fiber.New()
+//	engine.RegisterFiber(app, nil)
+//	app.Listen(":8080")
+func (c *ChatEngine) RegisterFiber(app *fiber.App, opt *RouteOptions) {
+	cfg := opt.withDefaults()
+	cfg.WSPath = "-"
+	app.All(cfg.Prefix+"/*", adaptor.HTTPHandler(c.Handler(&cfg)))
+}
+
+// FiberAuthMiddleware 返回配置好的 Fiber 鉴权中间件，供自定义路由使用（user_id 通过 c.Locals 读取）
+func (c *ChatEngine) FiberAuthMiddleware(opt *middleware.AuthOptions) fiber.Handler {
+	return middleware.FiberAuthMiddleware(c.AuthService, opt)
+}
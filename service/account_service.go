@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrAccountDisabled 账号被暂停/封禁（登录、WS 建连、发消息时返回，可用 errors.Is 判断）
+var ErrAccountDisabled = newError(response.CodeAccountSuspended, "err.account_disabled")
+
+// 封禁与申诉错误
+var (
+	ErrSuspendReasonRequired = newError(response.CodeParamError, "err.suspend_reason_required")
+	ErrAppealContentRequired = newError(response.CodeParamError, "err.appeal_content_required")
+	ErrAppealNotNeeded       = newError(response.CodeParamError, "err.appeal_not_needed")
+	ErrAppealPending         = newError(response.CodeParamError, "err.appeal_pending")
+	ErrAppealNotFound        = newError(response.CodeParamError, "err.appeal_not_found")
+	ErrAppealHandled         = newError(response.CodeParamError, "err.appeal_handled")
+)
+
+// appealMaxLen 申诉内容最大字数
+const appealMaxLen = 1000
+
+// AccountService 账号封禁与申诉：
+// - 暂停（到期自动恢复）/ 永久封禁 / 手动恢复，封禁时吊销该用户全部 token；
+// - 登录、WS 建连、发消息时校验账号状态；
+// - 被封禁用户凭账号密码提交申诉，推送给 AdminUserIDs，运维处理后可直接解封。
+type AccountService struct {
+	*Service
+	userDao      *models.UserDAO
+	tokenService *TokenService
+	// Auth 设置后封禁经由它吊销 token，同时断开绑定了 token 的 WS 连接（引擎自动注入）
+	Auth *AuthService
+}
+
+func NewAccountService(s *Service) *AccountService {
+	log.Println("NewAccountService")
+	return &AccountService{
+		Service:      s,
+		userDao:      models.NewUserDAO(s.DB),
+		tokenService: NewTokenServiceWithKV(s.KV),
+	}
+}
+
+// AccountStatusDTO 账号状态
+type AccountStatusDTO struct {
+	UserID       uint64     `json:"user_id"`
+	Status       uint8      `json:"status"` // 0-正常 1-暂停 2-永久封禁
+	SuspendUntil *time.Time `json:"suspend_until,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+}
+
+// AppealDTO 申诉
+type AppealDTO struct {
+	ID           uint64     `json:"id"`
+	UserID       uint64     `json:"user_id"`
+	Nickname     string     `json:"nickname"`
+	Content      string     `json:"content"`
+	Status       uint8      `json:"status"`
+	Reply        string     `json:"reply"`
+	UserStatus   uint8      `json:"user_status"`
+	SuspendUntil *time.Time `json:"suspend_until,omitempty"`
+	SuspendNote  string     `json:"suspend_note"`
+	HandledAt    *time.Time `json:"handled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// checkAccountStatus 账号不可用时返回 ErrAccountDisabled；暂停已到期的顺带恢复。
+func checkAccountStatus(db *gorm.DB, u *models.User, now time.Time) error {
+	switch u.Status {
+	case models.UserStatusBanned:
+		return fmt.Errorf("%w（永久）：%s", ErrAccountDisabled, u.SuspendNote)
+	case models.UserStatusSuspended:
+		if u.SuspendUntil != nil && !now.Before(*u.SuspendUntil) {
+			if err := db.Model(&models.User{}).
+				Where("id = ? AND status = ?", u.ID, models.UserStatusSuspended).
+				Updates(reinstateFields()).Error; err != nil {
+				return err
+			}
+			u.Status = models.UserStatusActive
+			return nil
+		}
+		until := "-"
+		if u.SuspendUntil != nil {
+			until = u.SuspendUntil.Format("2006-01-02 15:04:05")
+		}
+		return fmt.Errorf("%w至 %s：%s", ErrAccountDisabled, until, u.SuspendNote)
+	}
+	return nil
+}
+
+func reinstateFields() map[string]any {
+	return map[string]any{"status": models.UserStatusActive, "suspended_at": nil, "suspend_until": nil, "suspend_note": ""}
+}
+
+// CheckActive 校验账号状态（WS 建连、发消息前调用）
+func (s *AccountService) CheckActive(userID uint64) error {
+	var u models.User
+	if err := s.DB.Select("id", "status", "suspend_until", "suspend_note").Where("id = ?", userID).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return checkAccountStatus(s.DB, &u, time.Now())
+}
+
+// Suspend 暂停账号；duration<=0 表示永久封禁。
+func (s *AccountService) Suspend(ctx context.Context, userID uint64, reason string, duration time.Duration) (*AccountStatusDTO, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrSuspendReasonRequired
+	}
+	now := time.Now()
+	out := &AccountStatusDTO{UserID: userID, Status: models.UserStatusBanned, Reason: reason}
+	fields := map[string]any{"status": models.UserStatusBanned, "suspended_at": &now, "suspend_until": nil, "suspend_note": reason}
+	if duration > 0 {
+		until := now.Add(duration)
+		out.Status = models.UserStatusSuspended
+		out.SuspendUntil = &until
+		fields["status"] = models.UserStatusSuspended
+		fields["suspend_until"] = &until
+	}
+	res := s.DB.Model(&models.User{}).Where("id = ?", userID).Updates(fields)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrUserNotFound
+	}
+	// 吊销登录态（KV 未配置时跳过）
+	if s.KV != nil {
+		revoke := s.tokenService.RevokeAllTokensByUser
+		if s.Auth != nil {
+			revoke = s.Auth.RevokeAllTokensByUser
+		}
+		if err := revoke(ctx, userID); err != nil {
+			log.Printf("AccountService.Suspend revoke tokens user=%d: %v", userID, err)
+		}
+	}
+	s.pushStatus(out)
+	return out, nil
+}
+
+// Reinstate 恢复账号
+func (s *AccountService) Reinstate(userID uint64) error {
+	res := s.DB.Model(&models.User{}).Where("id = ?", userID).Updates(reinstateFields())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	s.pushStatus(&AccountStatusDTO{UserID: userID, Status: models.UserStatusActive})
+	return nil
+}
+
+// ReinstateExpired 批量恢复暂停已到期的账号
+func (s *AccountService) ReinstateExpired() (int64, error) {
+	res := s.DB.Model(&models.User{}).
+		Where("status = ? AND suspend_until IS NOT NULL AND suspend_until <= ?", models.UserStatusSuspended, time.Now()).
+		Updates(reinstateFields())
+	return res.RowsAffected, res.Error
+}
+
+// RunReinstateLoop 定时恢复到期的暂停账号（登录/建连时也会惰性恢复）
+func (s *AccountService) RunReinstateLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.ReinstateExpired(); err != nil {
+			log.Printf("account reinstate loop: %v", err)
+		}
+	}
+}
+
+func (s *AccountService) pushStatus(st *AccountStatusDTO) {
+	s.Emit([]uint64{st.UserID}, &message.AccountStatusEvent{
+		UserID:       st.UserID,
+		Status:       st.Status,
+		SuspendUntil: st.SuspendUntil,
+		Reason:       st.Reason,
+	})
+}
+
+// SubmitAppeal 提交申诉。被封禁用户无法登录，因此凭账号密码提交。
+func (s *AccountService) SubmitAppeal(account, password, content string) (*AppealDTO, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrAppealContentRequired
+	}
+	if len([]rune(content)) > appealMaxLen {
+		return nil, newError(response.CodeParamError, "err.appeal_too_long", appealMaxLen)
+	}
+	u, err := s.userDao.FindByAccount(normalizeAccount(account))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(strings.TrimSpace(password))); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := checkAccountStatus(s.DB, u, time.Now()); err == nil {
+		return nil, ErrAppealNotNeeded
+	} else if !errors.Is(err, ErrAccountDisabled) {
+		return nil, err
+	}
+
+	var pending int64
+	if err := s.DB.Model(&models.UserAppeal{}).
+		Where("user_id = ? AND status = ?", u.ID, models.AppealStatusPending).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrAppealPending
+	}
+
+	appeal := &models.UserAppeal{UserID: u.ID, Content: content, Status: models.AppealStatusPending}
+	if err := s.DB.Create(appeal).Error; err != nil {
+		return nil, err
+	}
+	appeal.User = *u
+	dto := toAppealDTO(appeal)
+	s.NotifyAdmins(EventAdminAppeal, dto)
+	return &dto, nil
+}
+
+// ListAppeals 申诉列表（status<0 表示全部）
+func (s *AccountService) ListAppeals(status int, limit, offset int) ([]AppealDTO, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	q := s.DB.Preload("User").Order("id DESC").Limit(limit).Offset(offset)
+	if status >= 0 {
+		q = q.Where("status = ?", status)
+	}
+	var rows []models.UserAppeal
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]AppealDTO, 0, len(rows))
+	for i := range rows {
+		out = append(out, toAppealDTO(&rows[i]))
+	}
+	return out, nil
+}
+
+// HandleAppeal 处理申诉；approve=true 时同时恢复账号
+func (s *AccountService) HandleAppeal(appealID uint64, approve bool, reply string) error {
+	var appeal models.UserAppeal
+	if err := s.DB.Where("id = ?", appealID).First(&appeal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAppealNotFound
+		}
+		return err
+	}
+	if appeal.Status != models.AppealStatusPending {
+		return ErrAppealHandled
+	}
+	status := models.AppealStatusRejected
+	if approve {
+		status = models.AppealStatusApproved
+	}
+	now := time.Now()
+	err := s.Tx(func(tx *gorm.DB) error {
+		res := tx.Model(&models.UserAppeal{}).
+			Where("id = ? AND status = ?", appealID, models.AppealStatusPending).
+			Updates(map[string]any{"status": status, "reply": strings.TrimSpace(reply), "handled_at": &now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrAppealHandled
+		}
+		if approve {
+			return tx.Model(&models.User{}).Where("id = ?", appeal.UserID).Updates(reinstateFields()).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if approve {
+		s.pushStatus(&AccountStatusDTO{UserID: appeal.UserID, Status: models.UserStatusActive})
+	}
+	return nil
+}
+
+func toAppealDTO(a *models.UserAppeal) AppealDTO {
+	return AppealDTO{
+		ID:           a.ID,
+		UserID:       a.UserID,
+		Nickname:     a.User.Nickname,
+		Content:      a.Content,
+		Status:       a.Status,
+		Reply:        a.Reply,
+		UserStatus:   a.User.Status,
+		SuspendUntil: a.User.SuspendUntil,
+		SuspendNote:  a.User.SuspendNote,
+		HandledAt:    a.HandledAt,
+		CreatedAt:    a.CreatedAt,
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestCheckAccountStatus(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	now := time.Now()
+
+	if err := checkAccountStatus(gormDB, &models.User{ID: 1}, now); err != nil {
+		t.Fatalf("active user: %v", err)
+	}
+	err := checkAccountStatus(gormDB, &models.User{ID: 1, Status: models.UserStatusBanned, SuspendNote: "spam"}, now)
+	if !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("banned user: %v", err)
+	}
+	until := now.Add(time.Hour)
+	err = checkAccountStatus(gormDB, &models.User{ID: 1, Status: models.UserStatusSuspended, SuspendUntil: &until}, now)
+	if !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("suspended user: %v", err)
+	}
+
+	// 暂停已到期：自动恢复
+	expired := now.Add(-time.Minute)
+	mock.ExpectExec("UPDATE `im_user` SET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	u := &models.User{ID: 1, Status: models.UserStatusSuspended, SuspendUntil: &expired}
+	if err := checkAccountStatus(gormDB, u, now); err != nil {
+		t.Fatalf("expired suspension: %v", err)
+	}
+	if u.Status != models.UserStatusActive {
+		t.Fatalf("expected status reset, got %d", u.Status)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAccountService_HandleAppeal(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewAccountService(&Service{DB: gormDB})
+
+	mock.ExpectQuery("SELECT \\* FROM `im_user_appeal` WHERE id = ?").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(5, 9, models.AppealStatusPending))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_user_appeal` SET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `im_user` SET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := svc.HandleAppeal(5, true, "核实误封"); err != nil {
+		t.Fatalf("HandleAppeal: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+)
+
+// 同一设备多账号：登录时携带 device_id，token 的附加信息记录设备并加入 im:device_tokens:{device_id}。
+// 切换时客户端同时出示两个账号的 token，校验属于同一设备后断开旧账号在本实例绑定的长连接，由客户端用新 token 重连；
+// 旧 token 不注销，可随时切回。
+
+var (
+	// ErrAccountSwitchDevice 两个 token 不是同一设备登录的
+	ErrAccountSwitchDevice = newError(response.CodeParamError, "err.account_switch_device")
+	// ErrAccountSwitchSame 切换前后是同一个账号
+	ErrAccountSwitchSame = newError(response.CodeParamError, "err.account_switch_same")
+)
+
+// AccountSwitchEvent 账号切换记录（审计）
+type AccountSwitchEvent struct {
+	DeviceID   string    `json:"device_id"`
+	FromUserID uint64    `json:"from_user_id"`
+	ToUserID   uint64    `json:"to_user_id"`
+	IP         string    `json:"ip,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// AccountSwitchResp 账号切换接口的返回
+type AccountSwitchResp struct {
+	AccountSwitchEvent
+	User *UserDTO `json:"user"` // 切换后的当前用户
+}
+
+// SwitchAccount 设备从 fromToken 的账号切换到 toToken 的账号：两个 token 都必须有效，且登录时携带了相同的 device_id。
+// 成功后关闭绑定到 fromToken 的连接（ConnCloseSwitched），写审计日志并回调 OnAccountSwitch。
+func (a *AuthService) SwitchAccount(ctx context.Context, fromToken, toToken, clientIP string) (*AccountSwitchEvent, error) {
+	fromToken, toToken = strings.TrimSpace(fromToken), strings.TrimSpace(toToken)
+	fromUID, fromMeta, err := a.deviceToken(ctx, fromToken)
+	if err != nil {
+		return nil, err
+	}
+	toUID, toMeta, err := a.deviceToken(ctx, toToken)
+	if err != nil {
+		return nil, err
+	}
+	if fromMeta.DeviceID == "" || fromMeta.DeviceID != toMeta.DeviceID {
+		return nil, ErrAccountSwitchDevice
+	}
+	if fromUID == toUID {
+		return nil, ErrAccountSwitchSame
+	}
+
+	ev := AccountSwitchEvent{DeviceID: toMeta.DeviceID, FromUserID: fromUID, ToUserID: toUID, IP: clientIP, At: time.Now()}
+	a.closeBindings(ConnCloseSwitched, 0, fromToken)
+	log.Printf("audit: account switch device=%s from=%d to=%d ip=%s", ev.DeviceID, ev.FromUserID, ev.ToUserID, ev.IP)
+	if a.OnAccountSwitch != nil {
+		a.OnAccountSwitch(ev)
+	}
+	return &ev, nil
+}
+
+// deviceToken 校验 token 并读取附加信息（没有附加信息时返回空 TokenMeta）
+func (a *AuthService) deviceToken(ctx context.Context, token string) (uint64, *TokenMeta, error) {
+	if token == "" {
+		return 0, nil, ErrTokenInvalid
+	}
+	uid, err := a.token.GetUserIDByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrKVNotFound) {
+			return 0, nil, ErrTokenInvalid
+		}
+		return 0, nil, err
+	}
+	meta, err := a.token.GetTokenMeta(ctx, token)
+	if errors.Is(err, ErrKVNotFound) {
+		return uid, &TokenMeta{}, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return uid, meta, nil
+}
+
+// RevokeDeviceTokens 注销设备上所有账号的 token（该设备全部退出登录），并关闭绑定的连接
+func (a *AuthService) RevokeDeviceTokens(ctx context.Context, deviceID string) error {
+	deviceID = strings.TrimSpace(deviceID)
+	if deviceID == "" {
+		return nil
+	}
+	tokens, err := a.token.ListDeviceTokens(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if err := a.RevokeToken(ctx, t); err != nil {
+			return err
+		}
+	}
+	log.Printf("audit: device logout device=%s tokens=%d", deviceID, len(tokens))
+	return nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 系统公告的接收范围（AnnouncementReq.Target）
+const (
+	AnnouncementTargetAll    = "all"    // 全部用户（不含机器人、访客与永久封禁账号）
+	AnnouncementTargetRooms  = "rooms"  // 指定房间的全部成员（多个房间去重）
+	AnnouncementTargetOnline = "online" // 当前在线/离开的用户（user.online_status，跨实例）
+)
+
+// announcementMaxRooms 按房间发送公告时最多指定的房间数
+const announcementMaxRooms = 100
+
+var (
+	ErrAnnouncementEmpty  = newError(response.CodeParamError, "err.announcement_empty")
+	ErrAnnouncementTarget = newError(response.CodeParamError, "err.announcement_target_invalid")
+	ErrAnnouncementRooms  = newError(response.CodeParamError, "err.announcement_rooms_invalid", announcementMaxRooms)
+)
+
+// AnnouncementRate 公告分批投递的速率：每批落库并推送 BatchSize 个用户，批与批之间等待 Interval
+type AnnouncementRate struct {
+	BatchSize int
+	Interval  time.Duration
+}
+
+// DefaultAnnouncementRate 默认每批 500 人、间隔 200ms（约 2500 人/秒）
+var DefaultAnnouncementRate = AnnouncementRate{BatchSize: 500, Interval: 200 * time.Millisecond}
+
+func (r AnnouncementRate) withDefaults() AnnouncementRate {
+	if r.BatchSize <= 0 {
+		r.BatchSize = DefaultAnnouncementRate.BatchSize
+	}
+	if r.Interval < 0 {
+		r.Interval = 0
+	}
+	return r
+}
+
+// AnnouncementReq 发布系统公告
+type AnnouncementReq struct {
+	Title    string `json:"title" binding:"required,max=100" example:"系统维护通知"`
+	Content  string `json:"content" binding:"required,max=2000" example:"今晚 23:00-24:00 停机维护"`
+	Link     string `json:"link" binding:"omitempty,max=500" example:"https://example.com/notice/1"`
+	Category string `json:"category" binding:"omitempty,max=32" example:"maintenance"` // 客户端自定义分类（维护、活动等）
+	// Target 接收范围：all / rooms / online
+	Target  string   `json:"target" binding:"required,oneof=all rooms online" example:"rooms"`
+	RoomIDs []uint64 `json:"room_ids" example:"1,2"` // target=rooms 时必填
+}
+
+// AnnouncementPayload 公告通知的 payload（event_type=system.announcement）
+type AnnouncementPayload struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Link     string `json:"link,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// AnnouncementDTO 已受理的公告（投递在后台分批进行）
+type AnnouncementDTO struct {
+	EventID   uint64    `json:"event_id"` // 只推送不落库时为 0
+	Target    string    `json:"target"`
+	RoomIDs   []uint64  `json:"room_ids,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AnnouncementResult 公告投递完成后推送给 AdminUserIDs 的结果（admin.announcement）
+type AnnouncementResult struct {
+	EventID    uint64 `json:"event_id"`
+	Recipients int    `json:"recipients"`
+	Batches    int    `json:"batches"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PublishAnnouncement 发布系统公告：先写入一条通知事件（room_id=0），再在后台按 AnnouncementRate 分批写入投递记录并推送 notification。
+// 同一实例的多条公告依次投递，不会叠加速率；投递结束后把 AnnouncementResult 推送给 AdminUserIDs。
+func (s *NotificationService) PublishAnnouncement(req AnnouncementReq) (*AnnouncementDTO, error) {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Title == "" || req.Content == "" {
+		return nil, ErrAnnouncementEmpty
+	}
+	switch req.Target {
+	case AnnouncementTargetAll, AnnouncementTargetOnline:
+		req.RoomIDs = nil
+	case AnnouncementTargetRooms:
+		req.RoomIDs = uniqueUint64s(req.RoomIDs)
+		if len(req.RoomIDs) == 0 || len(req.RoomIDs) > announcementMaxRooms {
+			return nil, ErrAnnouncementRooms
+		}
+	default:
+		return nil, ErrAnnouncementTarget
+	}
+
+	pl, err := json.Marshal(AnnouncementPayload{Title: req.Title, Content: req.Content, Link: req.Link, Category: req.Category})
+	if err != nil {
+		return nil, err
+	}
+	evt := &models.RoomNotification{EventType: EventSystemAnnouncement, Payload: datatypes.JSON(pl), CreatedAt: time.Now()}
+	if !s.PushOnly {
+		if err := s.DB.Create(evt).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	go func() {
+		s.announceMu.Lock()
+		defer s.announceMu.Unlock()
+		start := time.Now()
+		res, err := s.deliverAnnouncement(evt, req)
+		res.DurationMs = time.Since(start).Milliseconds()
+		if err != nil {
+			res.Error = err.Error()
+			log.Printf("announcement %d stopped after %d recipients: %v", evt.ID, res.Recipients, err)
+		}
+		s.NotifyAdmins(EventAdminAnnouncement, res)
+	}()
+
+	return &AnnouncementDTO{EventID: evt.ID, Target: req.Target, RoomIDs: req.RoomIDs, CreatedAt: evt.CreatedAt}, nil
+}
+
+// deliverAnnouncement 按用户 ID 升序分批取接收者，每批写入投递记录（同一事务内增加未读数）后推送
+func (s *NotificationService) deliverAnnouncement(evt *models.RoomNotification, req AnnouncementReq) (AnnouncementResult, error) {
+	rate := s.AnnouncementRate.withDefaults()
+	res := AnnouncementResult{EventID: evt.ID}
+	var after uint64
+	for {
+		ids, err := s.announcementRecipients(req, after, rate.BatchSize)
+		if err != nil || len(ids) == 0 {
+			return res, err
+		}
+		after = ids[len(ids)-1]
+
+		if !s.PushOnly {
+			rows := make([]models.RoomNotificationDelivery, 0, len(ids))
+			for _, uid := range ids {
+				rows = append(rows, models.RoomNotificationDelivery{UserID: uid, EventID: evt.ID, CreatedAt: evt.CreatedAt})
+			}
+			err := s.Tx(func(tx *gorm.DB) error {
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+					return err
+				}
+				return incrUnread(tx, ids)
+			})
+			if err != nil {
+				return res, err
+			}
+		}
+		s.pushRoomEventToUsers(evt, ids)
+		res.Recipients += len(ids)
+		res.Batches++
+
+		if len(ids) < rate.BatchSize {
+			return res, nil
+		}
+		time.Sleep(rate.Interval)
+	}
+}
+
+// announcementRecipients 取 user_id > after 的下一批接收者（升序，最多 limit 个）
+func (s *NotificationService) announcementRecipients(req AnnouncementReq, after uint64, limit int) ([]uint64, error) {
+	var ids []uint64
+	if req.Target == AnnouncementTargetRooms {
+		err := s.DB.Model(&models.RoomUser{}).
+			Where("room_id IN ? AND user_id > ?", req.RoomIDs, after).
+			Distinct("user_id").Order("user_id ASC").Limit(limit).
+			Pluck("user_id", &ids).Error
+		return ids, err
+	}
+	q := s.DB.Model(&models.User{}).
+		Where("id > ? AND is_bot = ? AND is_visitor = ? AND status <> ?", after, false, false, models.UserStatusBanned)
+	if req.Target == AnnouncementTargetOnline {
+		q = q.Where("online_status IN ?", []int{models.OnlineStatusOnline, models.OnlineStatusAway})
+	}
+	err := q.Order("id ASC").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestNotificationService_Announcement(t *testing.T) {
+	dsn := fmt.Sprintf("file:announcement_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.RoomUser{}, &models.RoomNotification{}, &models.RoomNotificationDelivery{}, &models.NotificationCounter{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// 1~5 普通用户（2、3 在线），6 机器人，7 永久封禁
+	for i := uint64(1); i <= 7; i++ {
+		u := models.User{ID: i, UID: fmt.Sprint(i), Username: fmt.Sprint("u", i), Nickname: "n", Password: "x"}
+		switch i {
+		case 2, 3:
+			u.OnlineStatus = models.OnlineStatusOnline
+		case 6:
+			u.IsBot = true
+		case 7:
+			u.Status = models.UserStatusBanned
+		}
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	for _, ru := range []models.RoomUser{{RoomID: 1, UserID: 1}, {RoomID: 1, UserID: 4}, {RoomID: 2, UserID: 4}, {RoomID: 2, UserID: 5}} {
+		if err := db.Create(&ru).Error; err != nil {
+			t.Fatalf("create member: %v", err)
+		}
+	}
+
+	var batches [][]uint64
+	ns := NewNotificationService(&Service{DB: db, WsBatchNotifier: func(userIDs []uint64, b []byte) { batches = append(batches, userIDs) }})
+	ns.AnnouncementRate = AnnouncementRate{BatchSize: 2}
+
+	if _, err := ns.PublishAnnouncement(AnnouncementReq{Title: "t", Content: "c", Target: AnnouncementTargetRooms}); !errors.Is(err, ErrAnnouncementRooms) {
+		t.Fatalf("rooms required: %v", err)
+	}
+	if _, err := ns.PublishAnnouncement(AnnouncementReq{Title: " ", Content: "c", Target: AnnouncementTargetAll}); !errors.Is(err, ErrAnnouncementEmpty) {
+		t.Fatalf("empty title: %v", err)
+	}
+
+	cases := []struct {
+		req  AnnouncementReq
+		want int
+	}{
+		{AnnouncementReq{Target: AnnouncementTargetAll}, 5},
+		{AnnouncementReq{Target: AnnouncementTargetOnline}, 2},
+		{AnnouncementReq{Target: AnnouncementTargetRooms, RoomIDs: []uint64{1, 2}}, 3},
+	}
+	for i, c := range cases {
+		batches = nil
+		evt := &models.RoomNotification{EventType: EventSystemAnnouncement, CreatedAt: time.Now()}
+		if err := db.Create(evt).Error; err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		res, err := ns.deliverAnnouncement(evt, c.req)
+		if err != nil || res.Recipients != c.want || res.Batches != (c.want+1)/2 || len(batches) != res.Batches {
+			t.Fatalf("case %d: %+v %v batches=%v", i, res, err, batches)
+		}
+		var n int64
+		db.Model(&models.RoomNotificationDelivery{}).Where("event_id = ?", evt.ID).Count(&n)
+		if n != int64(c.want) {
+			t.Fatalf("case %d deliveries: %d", i, n)
+		}
+	}
+
+	// 用户 4 不在线：收到全员与按房间两条公告
+	var cnt models.NotificationCounter
+	if err := db.First(&cnt, "user_id = ?", 4).Error; err != nil || cnt.Unread != 2 {
+		t.Fatalf("unread: %+v %v", cnt, err)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRateLimited 超出反垃圾频率限制（可用 errors.Is 判断，handler 返回 CodeRateLimited）
+var ErrRateLimited = newError(response.CodeRateLimited, "err.rate_limited")
+
+// 反垃圾限制的动作
+const (
+	SpamActionFriendRequest = "friend_request" // 发好友申请（按天）
+	SpamActionCreateGroup   = "create_group"   // 建群（按天）
+	SpamActionInvite        = "invite"         // 拉人进群（按小时，按人数计）
+	SpamActionMessage       = "message"        // 发消息（按分钟，转发不计）
+)
+
+// AntiSpamLimits 反垃圾频率限制，<=0 表示不限制
+type AntiSpamLimits struct {
+	FriendRequestsPerDay int // 每天最多发出的好友申请数
+	GroupsCreatedPerDay  int // 每天最多创建的群数
+	InvitesPerHour       int // 每小时最多拉进群的人数
+	MessagesPerMinute    int // 每分钟最多发送的消息数（默认不限制）
+}
+
+// DefaultAntiSpamLimits 默认限制
+var DefaultAntiSpamLimits = AntiSpamLimits{
+	FriendRequestsPerDay: 50,
+	GroupsCreatedPerDay:  10,
+	InvitesPerHour:       200,
+}
+
+// AntiSpamService 反垃圾：按用户统计好友申请/建群/拉人频率（Redis 计数，未配置 Redis 时不限制），
+// 超限时记录违规（落库 + 日志），并在每个时间窗口内首次违规时推送给运维账号。
+//
+// Redis Key:
+// - im:spam:{action}:{uid}:{window}      计数
+// - im:spam:flag:{action}:{uid}:{window} 本窗口已通知运维
+type AntiSpamService struct {
+	*Service
+	Limits AntiSpamLimits
+}
+
+func NewAntiSpamService(s *Service, limits AntiSpamLimits) *AntiSpamService {
+	log.Println("NewAntiSpamService")
+	return &AntiSpamService{Service: s, Limits: limits}
+}
+
+// SpamViolationDTO 违规记录
+type SpamViolationDTO struct {
+	ID        uint64    `json:"id"`
+	UserID    uint64    `json:"user_id"`
+	Nickname  string    `json:"nickname"`
+	Action    string    `json:"action"`
+	Limit     int       `json:"limit"`
+	Attempted int       `json:"attempted"` // 加上本次后的数量
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *AntiSpamService) rule(action string) (limit int, window time.Duration, label string) {
+	switch action {
+	case SpamActionFriendRequest:
+		return s.Limits.FriendRequestsPerDay, 24 * time.Hour, "今天发送的好友申请"
+	case SpamActionCreateGroup:
+		return s.Limits.GroupsCreatedPerDay, 24 * time.Hour, "今天创建的群"
+	case SpamActionInvite:
+		return s.Limits.InvitesPerHour, time.Hour, "一小时内邀请的人数"
+	case SpamActionMessage:
+		return s.Limits.MessagesPerMinute, time.Minute, "一分钟内发送的消息"
+	}
+	return 0, 0, ""
+}
+
+// Check 校验并累加计数；n 为本次数量（拉人时为人数）。
+func (s *AntiSpamService) Check(ctx context.Context, userID uint64, action string, n int) error {
+	if s == nil || s.RDB == nil || n <= 0 {
+		return nil
+	}
+	limit, window, label := s.rule(action)
+	if limit <= 0 {
+		return nil
+	}
+	now := time.Now()
+	bucket := now.Truncate(window).Unix()
+	key := fmt.Sprintf("im:spam:%s:%d:%d", action, userID, bucket)
+
+	cur, err := s.RDB.Get(ctx, key).Int()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if cur+n > limit {
+		s.recordViolation(ctx, userID, action, limit, cur+n, bucket, window)
+		return fmt.Errorf("%w：%s已达上限 %d", ErrRateLimited, label, limit)
+	}
+	pipe := s.RDB.TxPipeline()
+	pipe.IncrBy(ctx, key, int64(n))
+	pipe.Expire(ctx, key, window+time.Minute)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *AntiSpamService) recordViolation(ctx context.Context, userID uint64, action string, limit, attempted int, bucket int64, window time.Duration) {
+	log.Printf("anti-spam violation user=%d action=%s limit=%d attempted=%d", userID, action, limit, attempted)
+	row := &models.SpamViolation{UserID: userID, Action: action, Limit: limit, Attempted: attempted}
+	if err := s.DB.Create(row).Error; err != nil {
+		log.Printf("anti-spam record violation failed: %v", err)
+		return
+	}
+	// 每个窗口只通知一次，避免刷屏
+	flag := fmt.Sprintf("im:spam:flag:%s:%d:%d", action, userID, bucket)
+	if ok, err := s.RDB.SetNX(ctx, flag, 1, window+time.Minute).Result(); err != nil || !ok {
+		return
+	}
+	s.NotifyAdmins(EventAdminSpamViolation, SpamViolationDTO{
+		ID:        row.ID,
+		UserID:    userID,
+		Action:    action,
+		Limit:     limit,
+		Attempted: attempted,
+		CreatedAt: row.CreatedAt,
+	})
+}
+
+// ListViolations 违规记录（userID=0 表示全部）
+func (s *AntiSpamService) ListViolations(userID uint64, limit, offset int) ([]SpamViolationDTO, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	q := s.DB.Preload("User").Order("id DESC").Limit(limit).Offset(offset)
+	if userID > 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	var rows []models.SpamViolation
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]SpamViolationDTO, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, SpamViolationDTO{
+			ID:        r.ID,
+			UserID:    r.UserID,
+			Nickname:  r.User.Nickname,
+			Action:    r.Action,
+			Limit:     r.Limit,
+			Attempted: r.Attempted,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return out, nil
+}
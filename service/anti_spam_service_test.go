@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAntiSpamService_Check(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	var pushed []string
+	base := &Service{DB: gormDB, RDB: rdb, AdminUserIDs: []uint64{100}}
+	base.WsNotifier = func(userID uint64, msg []byte) {
+		var m map[string]any
+		_ = json.Unmarshal(msg, &m)
+		pushed = append(pushed, m["type"].(string))
+	}
+	svc := NewAntiSpamService(base, AntiSpamLimits{InvitesPerHour: 5})
+	ctx := context.Background()
+
+	if err := svc.Check(ctx, 1, SpamActionInvite, 3); err != nil {
+		t.Fatalf("first invite: %v", err)
+	}
+	// 未配置的动作不限制
+	if err := svc.Check(ctx, 1, SpamActionFriendRequest, 1000); err != nil {
+		t.Fatalf("unlimited action: %v", err)
+	}
+
+	mock.ExpectExec("INSERT INTO `im_spam_violation`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `im_spam_violation`").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	for i := 0; i < 2; i++ {
+		if err := svc.Check(ctx, 1, SpamActionInvite, 3); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected ErrRateLimited, got %v", err)
+		}
+	}
+	// 同一窗口只通知运维一次
+	if len(pushed) != 1 || pushed[0] != EventAdminSpamViolation {
+		t.Fatalf("unexpected admin pushes: %v", pushed)
+	}
+	// 未超限的部分仍可继续
+	if err := svc.Check(ctx, 1, SpamActionInvite, 2); err != nil {
+		t.Fatalf("remaining quota: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	var nilSvc *AntiSpamService
+	if err := nilSvc.Check(ctx, 1, SpamActionInvite, 100); err != nil {
+		t.Fatalf("nil service should not limit: %v", err)
+	}
+}
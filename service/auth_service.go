@@ -2,26 +2,62 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/redis/go-redis/v9"
 )
 
+// ErrTokenInvalid token 不存在或已过期/注销
+var ErrTokenInvalid = newError(response.CodeTokenInvalid, "err.token_invalid")
+
 // AuthService 提供“鉴权核心能力”，供调用方自建中间件/拦截器使用。
 // - 解析 token（Bearer 优先，其次 query）
-// - 校验 token -> userID（Redis）
+// - 校验 token -> userID（KVStore，默认 Redis）
 // - 注销 token / 注销用户全部 token
+// - 查询 token 详情（用户、过期时间、登录设备）
+// - 把长连接绑定到 token，token 注销时立即关闭（仅限本实例内绑定的连接）
+// - 同一设备上多个账号之间切换（见 account_switch.go）
 //
 // Gin 等框架的中间件建议作为单独适配层，内部调用该 service。
 type AuthService struct {
 	token *TokenService
+	// OnAccountSwitch 账号切换成功后的回调（审计），引擎按 WithAccountSwitchHook 注入
+	OnAccountSwitch func(AccountSwitchEvent)
+
+	mu       sync.Mutex
+	bindSeq  uint64
+	bindings map[string]map[uint64]tokenBinding // token -> 绑定 id -> 连接
 }
 
-func NewAuthService(rdb *redis.Client) *AuthService {
-	return &AuthService{token: NewTokenService(rdb)}
+// ConnCloseReason 绑定的连接被关闭的原因
+type ConnCloseReason string
+
+const (
+	// ConnCloseRevoked token 已注销，连接不应再用它重连
+	ConnCloseRevoked ConnCloseReason = "revoked"
+	// ConnCloseSwitched 设备切换到了其他账号，token 仍有效，客户端用新账号的 token 重连
+	ConnCloseSwitched ConnCloseReason = "switched"
+)
+
+// tokenBinding 绑定到 token 的一个连接
+type tokenBinding struct {
+	userID uint64
+	close  func(ConnCloseReason)
+}
+
+func NewAuthService(rdb redis.UniversalClient) *AuthService {
+	return NewAuthServiceWithKV(kvFromRedis(rdb))
+}
+
+// NewAuthServiceWithKV token 存储使用任意 KVStore（如 NewMemoryKVStore）
+func NewAuthServiceWithKV(kv KVStore) *AuthService {
+	return &AuthService{token: NewTokenServiceWithKV(kv), bindings: make(map[string]map[uint64]tokenBinding)}
 }
 
 // ExtractToken 从 HTTP 请求中提取 token：优先 Authorization: Bearer，其次 query: token。
@@ -60,7 +96,126 @@ func (a *AuthService) AuthenticateRequest(ctx context.Context, r *http.Request)
 	return uid, t, err
 }
 
-// RevokeToken 注销单个 token。
+// TokenInfo token 详情（见 Introspect）
+type TokenInfo struct {
+	UserID    uint64     `json:"user_id"`
+	DeviceID  string     `json:"device_id,omitempty"`  // 登录时上报的设备标识（多账号切换）
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // 未设置过期时为空
+	ExpiresIn int64      `json:"expires_in"`           // 剩余秒数，未设置过期时为 0
+	Device    string     `json:"device,omitempty"`     // 登录时上报的设备
+	IP        string     `json:"ip,omitempty"`         // 登录 IP
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`  // 签发时间（早期签发的 token 没有）
+	// Connections 本实例内绑定到该 token 的长连接数
+	Connections int `json:"connections"`
+}
+
+// Introspect 查询 token 详情；token 不存在或已过期返回 ErrTokenInvalid
+func (a *AuthService) Introspect(ctx context.Context, token string) (*TokenInfo, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrTokenInvalid
+	}
+	uid, err := a.token.GetUserIDByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrKVNotFound) {
+			return nil, ErrTokenInvalid
+		}
+		return nil, err
+	}
+	info := &TokenInfo{UserID: uid}
+	if ttl, err := a.token.TokenTTL(ctx, token); err != nil {
+		return nil, err
+	} else if ttl > 0 {
+		at := time.Now().Add(ttl).Truncate(time.Second)
+		info.ExpiresAt = &at
+		info.ExpiresIn = int64(ttl / time.Second)
+	}
+	meta, err := a.token.GetTokenMeta(ctx, token)
+	if err != nil && !errors.Is(err, ErrKVNotFound) {
+		return nil, err
+	}
+	if meta != nil {
+		info.DeviceID = meta.DeviceID
+		info.Device = meta.Device
+		info.IP = meta.IP
+		if !meta.CreatedAt.IsZero() {
+			info.IssuedAt = &meta.CreatedAt
+		}
+	}
+	a.mu.Lock()
+	info.Connections = len(a.bindings[token])
+	a.mu.Unlock()
+	return info, nil
+}
+
+// BindConnection 把一个长连接（如 WebSocket）绑定到 token：token 被 RevokeToken/RevokeAllTokensByUser 注销、或设备切换到其他账号时调用 closeFn。
+// token 无效时返回错误且不绑定；连接断开时调用返回的 unbind 解除绑定。
+func (a *AuthService) BindConnection(ctx context.Context, token string, closeFn func(ConnCloseReason)) (unbind func(), err error) {
+	uid, err := a.Authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	token = strings.TrimSpace(token)
+
+	a.mu.Lock()
+	a.bindSeq++
+	id := a.bindSeq
+	if a.bindings[token] == nil {
+		a.bindings[token] = make(map[uint64]tokenBinding)
+	}
+	a.bindings[token][id] = tokenBinding{userID: uid, close: closeFn}
+	a.mu.Unlock()
+
+	unbind = func() {
+		a.mu.Lock()
+		if m := a.bindings[token]; m != nil {
+			delete(m, id)
+			if len(m) == 0 {
+				delete(a.bindings, token)
+			}
+		}
+		a.mu.Unlock()
+	}
+	// 注册后再确认一次：与并发的注销竞争时，要么这里发现已注销，要么注销方能看到这次绑定
+	if _, err := a.token.GetUserIDByToken(ctx, token); err != nil {
+		unbind()
+		return nil, err
+	}
+	return unbind, nil
+}
+
+// closeBindings 摘除并关闭绑定到 tokens 或 userID（不为 0 时）的连接
+func (a *AuthService) closeBindings(reason ConnCloseReason, userID uint64, tokens ...string) {
+	var closers []func(ConnCloseReason)
+	a.mu.Lock()
+	for _, t := range tokens {
+		for _, b := range a.bindings[t] {
+			closers = append(closers, b.close)
+		}
+		delete(a.bindings, t)
+	}
+	if userID != 0 {
+		for t, m := range a.bindings {
+			for id, b := range m {
+				if b.userID == userID {
+					closers = append(closers, b.close)
+					delete(m, id)
+				}
+			}
+			if len(m) == 0 {
+				delete(a.bindings, t)
+			}
+		}
+	}
+	a.mu.Unlock()
+	for _, fn := range closers {
+		if fn != nil {
+			fn(reason)
+		}
+	}
+}
+
+// RevokeToken 注销单个 token，并关闭绑定到它的连接。
 func (a *AuthService) RevokeToken(ctx context.Context, token string) error {
 	token = strings.TrimSpace(token)
 	if token == "" {
@@ -70,12 +225,20 @@ func (a *AuthService) RevokeToken(ctx context.Context, token string) error {
 	if err == nil {
 		_ = a.token.RemoveUserToken(ctx, uid, token)
 	}
-	return a.token.RevokeToken(ctx, token)
+	if err := a.token.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+	a.closeBindings(ConnCloseRevoked, 0, token)
+	return nil
 }
 
-// RevokeAllTokensByUser 注销用户全部 token。
+// RevokeAllTokensByUser 注销用户全部 token，并关闭该用户绑定了 token 的连接。
 func (a *AuthService) RevokeAllTokensByUser(ctx context.Context, userID uint64) error {
-	return a.token.RevokeAllTokensByUser(ctx, userID)
+	if err := a.token.RevokeAllTokensByUser(ctx, userID); err != nil {
+		return err
+	}
+	a.closeBindings(ConnCloseRevoked, userID)
+	return nil
 }
 
 // RefreshTokenTTL 对 token 续期（可选能力，用于滑动过期）。
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestAuthService_ExtractToken_BearerFirst(t *testing.T) {
@@ -29,3 +32,129 @@ func TestAuthService_ExtractToken_QueryFallback(t *testing.T) {
 		t.Fatalf("expected queryToken, got %q", got)
 	}
 }
+
+func TestAuthService_IntrospectAndBind(t *testing.T) {
+	ctx := context.Background()
+	kv := NewMemoryKVStore()
+	a := NewAuthServiceWithKV(kv)
+	ts := NewTokenServiceWithKV(kv)
+	for _, tok := range []string{"t1", "t2", "t3"} {
+		if err := ts.StoreToken(ctx, tok, 7, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = ts.StoreTokenMeta(ctx, "t1", TokenMeta{Device: "iPhone", IP: "1.2.3.4", CreatedAt: time.Now()}, time.Hour)
+
+	closed := map[string]int{}
+	bind := func(tok string) func() {
+		unbind, err := a.BindConnection(ctx, tok, func(ConnCloseReason) { closed[tok]++ })
+		if err != nil {
+			t.Fatalf("bind %s: %v", tok, err)
+		}
+		return unbind
+	}
+	bind("t1")
+	bind("t1")
+	unbind2 := bind("t2")
+	bind("t3")
+	if _, err := a.BindConnection(ctx, "nope", func(ConnCloseReason) {}); err == nil {
+		t.Fatal("bind invalid token should fail")
+	}
+
+	info, err := a.Introspect(ctx, "t1")
+	if err != nil || info.UserID != 7 || info.Device != "iPhone" || info.IP != "1.2.3.4" || info.Connections != 2 ||
+		info.ExpiresIn <= 0 || info.ExpiresIn > 3600 || info.IssuedAt == nil {
+		t.Fatalf("introspect: %+v err=%v", info, err)
+	}
+	if _, err := a.Introspect(ctx, "nope"); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected ErrTokenInvalid, got %v", err)
+	}
+
+	// 注销单个 token 只断开它的连接；已解绑的连接不受影响
+	unbind2()
+	if err := a.RevokeToken(ctx, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if closed["t1"] != 2 || closed["t3"] != 0 {
+		t.Fatalf("closed after revoke: %v", closed)
+	}
+	if _, err := a.Introspect(ctx, "t1"); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("revoked token introspect: %v", err)
+	}
+	if _, err := kv.Get(ctx, "im:token_meta:t1"); !errors.Is(err, ErrKVNotFound) {
+		t.Fatalf("token meta not deleted: %v", err)
+	}
+
+	if err := a.RevokeAllTokensByUser(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if closed["t2"] != 0 || closed["t3"] != 1 {
+		t.Fatalf("closed after revoke all: %v", closed)
+	}
+	if _, err := a.Authenticate(ctx, "t2"); err == nil {
+		t.Fatal("t2 should be revoked")
+	}
+}
+
+func TestAuthService_SwitchAccount(t *testing.T) {
+	ctx := context.Background()
+	kv := NewMemoryKVStore()
+	a := NewAuthServiceWithKV(kv)
+	ts := NewTokenServiceWithKV(kv)
+	login := func(tok string, uid uint64, deviceID string) {
+		if err := ts.StoreToken(ctx, tok, uid, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.StoreTokenMeta(ctx, tok, TokenMeta{DeviceID: deviceID, CreatedAt: time.Now()}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	login("a", 1, "dev1")
+	login("b", 2, "dev1")
+	login("a2", 1, "dev1")
+	login("c", 3, "dev2")
+
+	var events []AccountSwitchEvent
+	a.OnAccountSwitch = func(ev AccountSwitchEvent) { events = append(events, ev) }
+	var reasons []ConnCloseReason
+	if _, err := a.BindConnection(ctx, "a", func(r ConnCloseReason) { reasons = append(reasons, r) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.SwitchAccount(ctx, "a", "c", ""); !errors.Is(err, ErrAccountSwitchDevice) {
+		t.Fatalf("different device: %v", err)
+	}
+	if _, err := a.SwitchAccount(ctx, "a", "a2", ""); !errors.Is(err, ErrAccountSwitchSame) {
+		t.Fatalf("same account: %v", err)
+	}
+	if _, err := a.SwitchAccount(ctx, "a", "nope", ""); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("invalid token: %v", err)
+	}
+	ev, err := a.SwitchAccount(ctx, "a", "b", "1.2.3.4")
+	if err != nil || ev.DeviceID != "dev1" || ev.FromUserID != 1 || ev.ToUserID != 2 || ev.IP != "1.2.3.4" {
+		t.Fatalf("switch: %+v err=%v", ev, err)
+	}
+	if len(events) != 1 || len(reasons) != 1 || reasons[0] != ConnCloseSwitched {
+		t.Fatalf("events=%v reasons=%v", events, reasons)
+	}
+	// 切换不注销旧 token
+	if uid, err := a.Authenticate(ctx, "a"); err != nil || uid != 1 {
+		t.Fatalf("from token revoked: uid=%d err=%v", uid, err)
+	}
+
+	// 设备退出登录只影响该设备
+	if err := a.RevokeDeviceTokens(ctx, "dev1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range []string{"a", "b", "a2"} {
+		if _, err := a.Authenticate(ctx, tok); err == nil {
+			t.Fatalf("%s should be revoked", tok)
+		}
+	}
+	if _, err := a.Authenticate(ctx, "c"); err != nil {
+		t.Fatalf("other device revoked: %v", err)
+	}
+	if tokens, err := ts.ListDeviceTokens(ctx, "dev1"); err != nil || len(tokens) != 0 {
+		t.Fatalf("device tokens: %v err=%v", tokens, err)
+	}
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+)
+
+// 自动回复错误
+var (
+	ErrAutoReplyPatternRequired = newError(response.CodeParamError, "err.auto_reply_pattern_required")
+	ErrAutoReplyReplyRequired   = newError(response.CodeParamError, "err.auto_reply_reply_required")
+	ErrAutoReplyMatchType       = newError(response.CodeParamError, "err.auto_reply_match_type")
+	ErrAutoReplyTargetRequired  = newError(response.CodeParamError, "err.auto_reply_target_required")
+	ErrAutoReplyDenied          = newError(response.CodePermissionDeny, "err.auto_reply_denied")
+	ErrAutoReplyRuleNotFound    = newError(response.CodeParamError, "err.auto_reply_rule_not_found")
+)
+
+// AutoReplyService 关键字/正则自动回复。
+// 说明：规则按 Priority DESC, ID ASC 依次匹配，一条消息最多触发一条规则；
+// 触发后同一 (规则, 房间) 在 CooldownSec 内不会再次触发，避免刷屏。
+type AutoReplyService struct {
+	*Service
+	messageService *MessageService
+
+	mu       sync.Mutex
+	lastFire map[[2]uint64]time.Time // key: {ruleID, roomID}
+	regexps  sync.Map                // pattern -> *regexp.Regexp
+}
+
+func NewAutoReplyService(s *Service) *AutoReplyService {
+	log.Println("NewAutoReplyService")
+	return &AutoReplyService{
+		Service:        s,
+		messageService: NewMessageService(s),
+		lastFire:       make(map[[2]uint64]time.Time),
+	}
+}
+
+// AutoReplyRuleReq 创建/修改规则参数
+type AutoReplyRuleReq struct {
+	RoomID      uint64 `json:"room_id"`
+	BotID       uint64 `json:"bot_id"`
+	MatchType   uint8  `json:"match_type"`
+	Pattern     string `json:"pattern"`
+	Reply       string `json:"reply"`
+	ReplyType   uint8  `json:"reply_type"`
+	Priority    int    `json:"priority"`
+	CooldownSec int    `json:"cooldown_sec"`
+}
+
+// AutoReplyRuleDTO 规则返回结构
+type AutoReplyRuleDTO struct {
+	ID          uint64    `json:"id"`
+	RoomID      uint64    `json:"room_id"`
+	BotID       uint64    `json:"bot_id"`
+	ResponderID uint64    `json:"responder_id"`
+	MatchType   uint8     `json:"match_type"`
+	Pattern     string    `json:"pattern"`
+	Reply       string    `json:"reply"`
+	ReplyType   uint8     `json:"reply_type"`
+	Priority    int       `json:"priority"`
+	CooldownSec int       `json:"cooldown_sec"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func toAutoReplyRuleDTO(r *models.AutoReplyRule) AutoReplyRuleDTO {
+	return AutoReplyRuleDTO{
+		ID:          r.ID,
+		RoomID:      r.RoomID,
+		BotID:       r.BotID,
+		ResponderID: r.ResponderID,
+		MatchType:   r.MatchType,
+		Pattern:     r.Pattern,
+		Reply:       r.Reply,
+		ReplyType:   r.ReplyType,
+		Priority:    r.Priority,
+		CooldownSec: r.CooldownSec,
+		Enabled:     r.Enabled,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+func (s *AutoReplyService) validate(req *AutoReplyRuleReq) error {
+	req.Pattern = strings.TrimSpace(req.Pattern)
+	if req.Pattern == "" {
+		return ErrAutoReplyPatternRequired
+	}
+	if strings.TrimSpace(req.Reply) == "" {
+		return ErrAutoReplyReplyRequired
+	}
+	if req.MatchType == 0 {
+		req.MatchType = models.AutoReplyMatchContains
+	}
+	switch req.MatchType {
+	case models.AutoReplyMatchContains, models.AutoReplyMatchExact:
+	case models.AutoReplyMatchRegex:
+		if _, err := regexp.Compile(req.Pattern); err != nil {
+			return newError(response.CodeParamError, "err.auto_reply_regex", err.Error())
+		}
+	default:
+		return ErrAutoReplyMatchType
+	}
+	if req.ReplyType == 0 {
+		req.ReplyType = 1
+	}
+	if req.CooldownSec < 0 {
+		req.CooldownSec = 0
+	}
+	return nil
+}
+
+// checkManage 校验操作人能否管理 (roomID, botID) 维度的规则，返回回复者 ID。
+// 机器人规则：必须是机器人的创建者；房间规则：必须是群主/管理员。
+func (s *AutoReplyService) checkManage(operatorID, roomID, botID uint64) (uint64, error) {
+	if botID > 0 {
+		var bot models.Bot
+		if err := s.DB.Where("id = ? AND owner_id = ?", botID, operatorID).First(&bot).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, ErrBotNotFound
+			}
+			return 0, err
+		}
+		return bot.UserID, nil
+	}
+	if roomID == 0 {
+		return 0, ErrAutoReplyTargetRequired
+	}
+	var member models.RoomUser
+	if err := s.DB.Where("room_id = ? AND user_id = ?", roomID, operatorID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrNotRoomMember
+		}
+		return 0, err
+	}
+	if member.Role < models.RoomRoleAdmin {
+		return 0, ErrAutoReplyDenied
+	}
+	return operatorID, nil
+}
+
+// CreateRule 创建规则
+func (s *AutoReplyService) CreateRule(operatorID uint64, req AutoReplyRuleReq) (*AutoReplyRuleDTO, error) {
+	if err := s.validate(&req); err != nil {
+		return nil, err
+	}
+	responderID, err := s.checkManage(operatorID, req.RoomID, req.BotID)
+	if err != nil {
+		return nil, err
+	}
+	rule := &models.AutoReplyRule{
+		RoomID:      req.RoomID,
+		BotID:       req.BotID,
+		ResponderID: responderID,
+		CreatorID:   operatorID,
+		MatchType:   req.MatchType,
+		Pattern:     req.Pattern,
+		Reply:       req.Reply,
+		ReplyType:   req.ReplyType,
+		Priority:    req.Priority,
+		CooldownSec: req.CooldownSec,
+		Enabled:     true,
+	}
+	if err := s.DB.Create(rule).Error; err != nil {
+		return nil, err
+	}
+	dto := toAutoReplyRuleDTO(rule)
+	return &dto, nil
+}
+
+func (s *AutoReplyService) getManagedRule(operatorID, ruleID uint64) (*models.AutoReplyRule, error) {
+	var rule models.AutoReplyRule
+	if err := s.DB.First(&rule, ruleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAutoReplyRuleNotFound
+		}
+		return nil, err
+	}
+	if _, err := s.checkManage(operatorID, rule.RoomID, rule.BotID); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateRule 修改规则（room_id/bot_id 不可修改）
+func (s *AutoReplyService) UpdateRule(operatorID, ruleID uint64, req AutoReplyRuleReq, enabled bool) error {
+	rule, err := s.getManagedRule(operatorID, ruleID)
+	if err != nil {
+		return err
+	}
+	if err := s.validate(&req); err != nil {
+		return err
+	}
+	return s.DB.Model(&models.AutoReplyRule{}).Where("id = ?", rule.ID).Updates(map[string]any{
+		"match_type":   req.MatchType,
+		"pattern":      req.Pattern,
+		"reply":        req.Reply,
+		"reply_type":   req.ReplyType,
+		"priority":     req.Priority,
+		"cooldown_sec": req.CooldownSec,
+		"enabled":      enabled,
+		"updated_at":   time.Now(),
+	}).Error
+}
+
+// DeleteRule 删除规则
+func (s *AutoReplyService) DeleteRule(operatorID, ruleID uint64) error {
+	rule, err := s.getManagedRule(operatorID, ruleID)
+	if err != nil {
+		return err
+	}
+	return s.DB.Delete(&models.AutoReplyRule{}, rule.ID).Error
+}
+
+// ListRules 列出房间或机器人的规则
+func (s *AutoReplyService) ListRules(operatorID, roomID, botID uint64) ([]AutoReplyRuleDTO, error) {
+	if _, err := s.checkManage(operatorID, roomID, botID); err != nil {
+		return nil, err
+	}
+	q := s.DB.Model(&models.AutoReplyRule{})
+	if botID > 0 {
+		q = q.Where("bot_id = ?", botID)
+	} else {
+		q = q.Where("room_id = ? AND bot_id = 0", roomID)
+	}
+	var rules []models.AutoReplyRule
+	if err := q.Order("priority DESC, id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	out := make([]AutoReplyRuleDTO, 0, len(rules))
+	for i := range rules {
+		out = append(out, toAutoReplyRuleDTO(&rules[i]))
+	}
+	return out, nil
+}
+
+func (s *AutoReplyService) compile(pattern string) *regexp.Regexp {
+	if v, ok := s.regexps.Load(pattern); ok {
+		return v.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	s.regexps.Store(pattern, re)
+	return re
+}
+
+// matchRule 判断文本是否命中规则
+func (s *AutoReplyService) matchRule(rule *models.AutoReplyRule, content string) bool {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return false
+	}
+	switch rule.MatchType {
+	case models.AutoReplyMatchExact:
+		return strings.EqualFold(content, rule.Pattern)
+	case models.AutoReplyMatchRegex:
+		re := s.compile(rule.Pattern)
+		return re != nil && re.MatchString(content)
+	default:
+		return strings.Contains(strings.ToLower(content), strings.ToLower(rule.Pattern))
+	}
+}
+
+// allow 冷却判断，通过则记录本次触发时间
+func (s *AutoReplyService) allow(rule *models.AutoReplyRule, roomID uint64, now time.Time) bool {
+	key := [2]uint64{rule.ID, roomID}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.lastFire[key]; ok && now.Sub(last) < time.Duration(rule.CooldownSec)*time.Second {
+		return false
+	}
+	s.lastFire[key] = now
+	return true
+}
+
+// HandleInbound 对一条入站文本消息执行规则匹配，命中则以回复者身份落库并返回回复消息。
+// 未命中返回 (nil, nil)。调用方负责推送。
+func (s *AutoReplyService) HandleInbound(roomID, senderID uint64, msgType uint8, content string) (*models.Message, error) {
+	if msgType != 1 || strings.TrimSpace(content) == "" {
+		return nil, nil
+	}
+	memberSub := s.DB.Model(&models.RoomUser{}).Select("user_id").Where("room_id = ?", roomID)
+	var rules []models.AutoReplyRule
+	if err := s.DB.Where("enabled = ?", true).
+		Where("room_id = ? OR (room_id = 0 AND bot_id > 0 AND responder_id IN (?))", roomID, memberSub).
+		Order("priority DESC, id ASC").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range rules {
+		rule := &rules[i]
+		// 不回复自己，避免机器人之间互相触发
+		if rule.ResponderID == senderID || !s.matchRule(rule, content) {
+			continue
+		}
+		if !s.allow(rule, roomID, now) {
+			return nil, nil
+		}
+		return s.messageService.SaveMessage(roomID, rule.ResponderID, rule.Reply, rule.ReplyType, message.Extra{})
+	}
+	return nil, nil
+}
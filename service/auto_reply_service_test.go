@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestAutoReplyService_MatchRule(t *testing.T) {
+	s := NewAutoReplyService(&Service{})
+
+	cases := []struct {
+		matchType uint8
+		pattern   string
+		content   string
+		want      bool
+	}{
+		{models.AutoReplyMatchContains, "价格", "请问价格多少", true},
+		{models.AutoReplyMatchContains, "Help", "need help!", true},
+		{models.AutoReplyMatchContains, "价格", "你好", false},
+		{models.AutoReplyMatchExact, "hi", " HI ", true},
+		{models.AutoReplyMatchExact, "hi", "hi there", false},
+		{models.AutoReplyMatchRegex, `^订单\d+$`, "订单123", true},
+		{models.AutoReplyMatchRegex, `^订单\d+$`, "订单abc", false},
+		{models.AutoReplyMatchRegex, `(`, "(", false},
+		{models.AutoReplyMatchContains, "x", "   ", false},
+	}
+	for _, c := range cases {
+		rule := &models.AutoReplyRule{MatchType: c.matchType, Pattern: c.pattern}
+		if got := s.matchRule(rule, c.content); got != c.want {
+			t.Errorf("matchRule(%d, %q, %q) = %v, want %v", c.matchType, c.pattern, c.content, got, c.want)
+		}
+	}
+}
+
+func TestAutoReplyService_Cooldown(t *testing.T) {
+	s := NewAutoReplyService(&Service{})
+	rule := &models.AutoReplyRule{ID: 1, CooldownSec: 10}
+	now := time.Now()
+
+	if !s.allow(rule, 100, now) {
+		t.Fatalf("first trigger should be allowed")
+	}
+	if s.allow(rule, 100, now.Add(5*time.Second)) {
+		t.Fatalf("trigger within cooldown should be throttled")
+	}
+	if !s.allow(rule, 200, now.Add(5*time.Second)) {
+		t.Fatalf("cooldown is per room")
+	}
+	if !s.allow(rule, 100, now.Add(11*time.Second)) {
+		t.Fatalf("trigger after cooldown should be allowed")
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
@@ -19,8 +20,8 @@ import (
 )
 
 // MergeAvatarsConfig 合成群头像配置。
-// 说明：本项目没有对象存储/静态资源服务的统一约束，因此这里默认落盘到 outputDir，返回一个 file:// URL。
-// 如果你有 CDN/OSS，可把 outputDir 替换成上传逻辑，然后返回远程 URL。
+// 说明：合成的 PNG 交给 Uploader（如 S3FileStorage）上传，未配置时落盘到 OutputDir；
+// 自动群头像（GroupAvatarService）使用 Service.Storage。
 type MergeAvatarsConfig struct {
 	CanvasSize int           // 画布大小（正方形，像素）
 	Padding    int           // 外边距
@@ -32,6 +33,9 @@ type MergeAvatarsConfig struct {
 	// - 为空：默认使用 OutputDir 作为前缀（会移除 file://，并去掉前导 /，生成相对路径）
 	// - 非空：直接用该前缀拼 filename（会自动处理斜杠）
 	URLPrefix string
+
+	// Uploader 合成后上传到对象存储（S3/OSS/MinIO 等），返回的 URL 直接写库；为 nil 时写本地 OutputDir
+	Uploader FileStorage
 }
 
 func (c MergeAvatarsConfig) withDefaults() MergeAvatarsConfig {
@@ -57,14 +61,37 @@ func (c MergeAvatarsConfig) withDefaults() MergeAvatarsConfig {
 // MergeAvatarResult 合成结果。
 type MergeAvatarResult struct {
 	URL      string
-	FilePath string
+	FilePath string // 本地文件路径，上传到 Uploader 时为空
 }
 
-// MergeMembersAvatar 以微信风格将多张头像拼成一张。
+// MergeMembersAvatar 以微信风格将多张头像拼成一张，上传到 cfg.Uploader（未配置时写入 cfg.OutputDir）。
 // - 取自己+前若干（调用方控制顺序/截断），建议最多 9 张。
 // - 输入 avatarURLs 允许为空字符串，会用灰色占位。
 func MergeMembersAvatar(avatarURLs []string, cfg MergeAvatarsConfig) (*MergeAvatarResult, error) {
 	cfg = cfg.withDefaults()
+	name, data, err := renderMembersAvatar(avatarURLs, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if cfg.Uploader != nil {
+		url, err := cfg.Uploader.Put(ctx, name, data, "image/png")
+		if err != nil {
+			return nil, err
+		}
+		return &MergeAvatarResult{URL: url}, nil
+	}
+	local := &LocalFileStorage{Dir: cfg.OutputDir, URLPrefix: cfg.URLPrefix}
+	url, err := local.Put(ctx, name, data, "image/png")
+	if err != nil {
+		return nil, err
+	}
+	return &MergeAvatarResult{URL: url, FilePath: filepath.Join(cfg.OutputDir, name)}, nil
+}
+
+// renderMembersAvatar 合成拼图头像（cfg 需已 withDefaults），返回稳定文件名与 PNG 数据
+func renderMembersAvatar(avatarURLs []string, cfg MergeAvatarsConfig) (string, []byte, error) {
 
 	// 规范化：最多 9 张
 	urls := make([]string, 0, len(avatarURLs))
@@ -119,11 +146,6 @@ func MergeMembersAvatar(avatarURLs []string, cfg MergeAvatarsConfig) (*MergeAvat
 		draw.Draw(canvas, image.Rect(x, y, x+cellSize, y+cellSize), thumb, image.Point{}, draw.Over)
 	}
 
-	// 输出文件
-	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
-		return nil, err
-	}
-
 	// 生成稳定文件名：对 url 列表 hash
 	h := sha1.New()
 	// 为了稳定性，按原顺序合成，但 hash 用排序后的保证同一组用户拿到同一头像
@@ -134,34 +156,12 @@ func MergeMembersAvatar(avatarURLs []string, cfg MergeAvatarsConfig) (*MergeAvat
 		_, _ = io.WriteString(h, "|")
 	}
 	name := hex.EncodeToString(h.Sum(nil)) + ".png"
-	outPath := filepath.Join(cfg.OutputDir, name)
 
 	var buf bytes.Buffer
 	if err := png.Encode(&buf, canvas); err != nil {
-		return nil, err
-	}
-	if err := os.WriteFile(outPath, buf.Bytes(), 0o644); err != nil {
-		return nil, err
+		return "", nil, err
 	}
-
-	// 生成写库/访问 URL
-	prefix := strings.TrimSpace(cfg.URLPrefix)
-	if prefix == "" {
-		prefix = strings.TrimSpace(cfg.OutputDir)
-		prefix = strings.TrimPrefix(prefix, "file://")
-		prefix = strings.ReplaceAll(prefix, "\\", "/")
-		prefix = strings.TrimPrefix(prefix, "/")
-		prefix = strings.TrimSuffix(prefix, "/")
-	} else {
-		prefix = strings.TrimSuffix(prefix, "/")
-	}
-
-	url := name
-	if prefix != "" {
-		url = prefix + "/" + name
-	}
-
-	return &MergeAvatarResult{URL: url, FilePath: outPath}, nil
+	return name, buf.Bytes(), nil
 }
 
 type gridLayout struct{ rows, cols int }
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码（取首帧）
+	"image/jpeg"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+)
+
+const (
+	// AvatarUploadMaxBytes 头像上传文件大小上限
+	AvatarUploadMaxBytes = 5 << 20
+	// avatarMaxPixels 解码前按图片头校验的宽高上限，防止解压炸弹
+	avatarMaxPixels = 8000
+	// avatarMinCrop 裁剪区域最小边长
+	avatarMinCrop = 16
+	// avatarJPEGQuality 各尺寸统一转为 JPEG 的质量
+	avatarJPEGQuality = 85
+	// avatarStoreTimeout 写入/删除存储的超时
+	avatarStoreTimeout = 30 * time.Second
+)
+
+// avatarVariants 头像尺寸规格，资料里保存 medium，其余尺寸把 URL 中的 _medium.jpg 换成 _small.jpg / _large.jpg
+var avatarVariants = []struct {
+	name string
+	size int
+}{
+	{"small", 64},
+	{"medium", 160},
+	{"large", 480},
+}
+
+var (
+	// ErrAvatarFormat 无法识别的图片（仅支持 JPEG/PNG/GIF）
+	ErrAvatarFormat = newError(response.CodeParamError, "err.avatar_format")
+	// ErrAvatarTooLarge 文件或分辨率超出上限
+	ErrAvatarTooLarge = newError(response.CodeParamError, "err.avatar_too_large")
+	// ErrAvatarCrop 裁剪区域超出图片范围
+	ErrAvatarCrop = newError(response.CodeParamError, "err.avatar_crop")
+	// ErrAvatarChanged 上传期间头像被其他请求修改
+	ErrAvatarChanged = newError(response.CodeParamError, "err.avatar_changed")
+	// ErrStorageUnavailable 未配置文件存储
+	ErrStorageUnavailable = newError(response.CodeInternalError, "err.storage_unavailable")
+)
+
+// AvatarCrop 裁剪区域（原图像素坐标的正方形），Size=0 表示居中裁成最大正方形
+type AvatarCrop struct {
+	X    int
+	Y    int
+	Size int
+}
+
+// AvatarUploadDTO 头像上传结果
+type AvatarUploadDTO struct {
+	Avatar string `json:"avatar"` // 写入资料的头像（同 medium）
+	Small  string `json:"small"`  // 64x64
+	Medium string `json:"medium"` // 160x160
+	Large  string `json:"large"`  // 480x480
+}
+
+// processAvatar 解码（JPEG/PNG/GIF）→ 裁成正方形 → 按 avatarVariants 缩放并统一编码为 JPEG
+func processAvatar(data []byte, crop AvatarCrop) (map[string][]byte, error) {
+	if len(data) > AvatarUploadMaxBytes {
+		return nil, ErrAvatarTooLarge
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrAvatarFormat
+	}
+	if cfg.Width > avatarMaxPixels || cfg.Height > avatarMaxPixels {
+		return nil, ErrAvatarTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrAvatarFormat
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if crop.Size == 0 {
+		crop.Size = min(w, h)
+		crop.X, crop.Y = (w-crop.Size)/2, (h-crop.Size)/2
+	}
+	if crop.Size < avatarMinCrop || crop.X < 0 || crop.Y < 0 || crop.X+crop.Size > w || crop.Y+crop.Size > h {
+		return nil, ErrAvatarCrop
+	}
+	rect := image.Rect(b.Min.X+crop.X, b.Min.Y+crop.Y, b.Min.X+crop.X+crop.Size, b.Min.Y+crop.Y+crop.Size)
+	square := image.NewRGBA(image.Rect(0, 0, crop.Size, crop.Size))
+	for y := 0; y < crop.Size; y++ {
+		for x := 0; x < crop.Size; x++ {
+			square.Set(x, y, img.At(rect.Min.X+x, rect.Min.Y+y))
+		}
+	}
+
+	out := make(map[string][]byte, len(avatarVariants))
+	for _, v := range avatarVariants {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resizeNearest(square, v.size, v.size), &jpeg.Options{Quality: avatarJPEGQuality}); err != nil {
+			return nil, err
+		}
+		out[v.name] = buf.Bytes()
+	}
+	return out, nil
+}
+
+// avatarDir 上传头像的存储目录，如 avatar/user/1/
+func avatarDir(kind string, id uint64) string {
+	return fmt.Sprintf("avatar/%s/%d/", kind, id)
+}
+
+// storeAvatar 把各尺寸写入 Storage（avatar/<kind>/<id>/<hash>_<size>.jpg），任一失败时清理已写入的文件
+func (s *Service) storeAvatar(ctx context.Context, kind string, id uint64, original []byte, variants map[string][]byte) (*AvatarUploadDTO, error) {
+	sum := sha1.Sum(original)
+	base := avatarDir(kind, id) + hex.EncodeToString(sum[:8])
+
+	urls := make(map[string]string, len(variants))
+	for _, v := range avatarVariants {
+		url, err := s.Storage.Put(ctx, base+"_"+v.name+".jpg", variants[v.name], "image/jpeg")
+		if err != nil {
+			for _, u := range urls {
+				_ = s.Storage.Delete(ctx, u)
+			}
+			return nil, err
+		}
+		urls[v.name] = url
+	}
+	return &AvatarUploadDTO{Avatar: urls["medium"], Small: urls["small"], Medium: urls["medium"], Large: urls["large"]}, nil
+}
+
+// deleteAvatarFiles 删除头像 avatarURL 的各尺寸文件（尽力而为）。
+// 只处理本功能上传到 avatar/<kind>/<id>/ 下的文件，外部 URL、自动合成的群头像（多个群可能共用）不删。
+func (s *Service) deleteAvatarFiles(ctx context.Context, kind string, id uint64, avatarURL string) {
+	stem, ok := strings.CutSuffix(avatarURL, "_medium.jpg")
+	if !ok || s.Storage == nil || !strings.Contains(stem, avatarDir(kind, id)) {
+		return
+	}
+	for _, v := range avatarVariants {
+		if err := s.Storage.Delete(ctx, stem+"_"+v.name+".jpg"); err != nil {
+			log.Printf("delete avatar %s_%s: %v", stem, v.name, err)
+		}
+	}
+}
+
+// replaceAvatar 处理并存储新头像，再以旧值做条件更新（update 需带 avatar = 旧值 的条件），成功后清理旧文件，失败时清理新文件
+func (s *Service) replaceAvatar(kind string, id uint64, data []byte, crop AvatarCrop, old string, update func(newURL string) (int64, error)) (*AvatarUploadDTO, error) {
+	if s.Storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+	variants, err := processAvatar(data, crop)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), avatarStoreTimeout)
+	defer cancel()
+	dto, err := s.storeAvatar(ctx, kind, id, data, variants)
+	if err != nil {
+		return nil, err
+	}
+	if dto.Avatar == old {
+		// 同一张图重复上传，文件已覆盖写入
+		return dto, nil
+	}
+
+	rows, err := update(dto.Avatar)
+	if err == nil && rows == 0 {
+		err = ErrAvatarChanged
+	}
+	if err != nil {
+		s.deleteAvatarFiles(ctx, kind, id, dto.Avatar)
+		return nil, err
+	}
+	s.deleteAvatarFiles(ctx, kind, id, old)
+	return dto, nil
+}
+
+// UploadAvatar 上传头像：裁剪、生成 small/medium/large 三个尺寸写入存储，资料保存 medium，并删除上一次上传的头像文件
+func (s *UserService) UploadAvatar(userID uint64, data []byte, crop AvatarCrop) (*AvatarUploadDTO, error) {
+	var u models.User
+	if err := s.DB.Select("id, avatar").Where("id = ?", userID).Find(&u).Error; err != nil {
+		return nil, err
+	}
+	if u.ID == 0 {
+		return nil, ErrUserNotFound
+	}
+	dto, err := s.replaceAvatar("user", userID, data, crop, u.Avatar, func(newURL string) (int64, error) {
+		res := s.DB.Model(&models.User{}).
+			Where("id = ? AND avatar = ?", userID, u.Avatar).
+			Update("avatar", newURL)
+		return res.RowsAffected, res.Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateUserBrief(userID)
+	return dto, nil
+}
+
+// UploadGroupAvatar 上传群头像（群主/管理员）：同 UploadAvatar，设置后不再随成员变动自动合成，并推送群信息更新
+func (s *RoomService) UploadGroupAvatar(operatorID, roomID uint64, data []byte, crop AvatarCrop) (*AvatarUploadDTO, error) {
+	if _, err := s.RequireRole(roomID, operatorID, models.RoomRoleAdmin); err != nil {
+		return nil, err
+	}
+	var room models.Room
+	if err := s.DB.Select("id, avatar").Where("id = ?", roomID).Find(&room).Error; err != nil {
+		return nil, err
+	}
+	if room.ID == 0 {
+		return nil, ErrGroupNotFound
+	}
+	dto, err := s.replaceAvatar("room", roomID, data, crop, room.Avatar, func(newURL string) (int64, error) {
+		res := s.DB.Model(&models.Room{}).
+			Where("id = ? AND avatar = ?", roomID, room.Avatar).
+			Updates(map[string]any{"avatar": newURL, "avatar_auto": false})
+		return res.RowsAffected, res.Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dto.Avatar != room.Avatar {
+		s.publishGroupInfoUpdated(operatorID, roomID, "", dto.Avatar)
+	}
+	return dto, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessAvatar(t *testing.T) {
+	data := testPNG(t, 200, 100)
+
+	out, err := processAvatar(data, AvatarCrop{})
+	if err != nil {
+		t.Fatalf("center crop: %v", err)
+	}
+	for _, v := range avatarVariants {
+		img, format, err := image.Decode(bytes.NewReader(out[v.name]))
+		if err != nil || format != "jpeg" {
+			t.Fatalf("%s: format=%q err=%v", v.name, format, err)
+		}
+		if b := img.Bounds(); b.Dx() != v.size || b.Dy() != v.size {
+			t.Fatalf("%s: size=%v", v.name, b)
+		}
+	}
+
+	if _, err := processAvatar(data, AvatarCrop{X: 150, Y: 0, Size: 80}); !errors.Is(err, ErrAvatarCrop) {
+		t.Fatalf("out of bounds: %v", err)
+	}
+	if _, err := processAvatar([]byte("not an image"), AvatarCrop{}); !errors.Is(err, ErrAvatarFormat) {
+		t.Fatalf("bad format: %v", err)
+	}
+}
+
+func TestUserService_UploadAvatar(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	store := &memFileStorage{files: map[string][]byte{
+		"avatar/user/1/old_small.jpg":  {1},
+		"avatar/user/1/old_medium.jpg": {1},
+		"avatar/user/1/old_large.jpg":  {1},
+	}}
+	s := NewUserService(&Service{DB: db, TablePrefix: "im_", Storage: store})
+	oldURL := "https://cdn.example.com/avatar/user/1/old_medium.jpg"
+
+	mock.ExpectQuery("SELECT id, avatar FROM `im_user` WHERE id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "avatar"}).AddRow(1, oldURL))
+	mock.ExpectExec("UPDATE `im_user` SET `avatar`=\\?,`updated_at`=\\? WHERE \\(id = \\? AND avatar = \\?\\)").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, oldURL).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dto, err := s.UploadAvatar(1, testPNG(t, 64, 64), AvatarCrop{})
+	if err != nil {
+		t.Fatalf("UploadAvatar: %v", err)
+	}
+	if dto.Avatar != dto.Medium || !strings.HasSuffix(dto.Large, "_large.jpg") {
+		t.Fatalf("unexpected dto: %+v", dto)
+	}
+	// 旧文件已删除，只剩新上传的三个尺寸
+	if len(store.files) != 3 {
+		t.Fatalf("files=%v", store.files)
+	}
+	for name := range store.files {
+		if strings.Contains(name, "old_") {
+			t.Fatalf("old file kept: %s", name)
+		}
+	}
+
+	// 并发修改：条件更新 0 行时清理新文件
+	mock.ExpectQuery("SELECT id, avatar FROM `im_user` WHERE id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "avatar"}).AddRow(1, dto.Avatar))
+	mock.ExpectExec("UPDATE `im_user` SET `avatar`=\\?").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := s.UploadAvatar(1, testPNG(t, 80, 80), AvatarCrop{}); !errors.Is(err, ErrAvatarChanged) {
+		t.Fatalf("expected ErrAvatarChanged, got %v", err)
+	}
+	if len(store.files) != 3 {
+		t.Fatalf("new files not cleaned: %v", store.files)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,21 +1,47 @@
 package service
 
 import (
+	"log"
+	"sync/atomic"
 	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 // Service 基础服务，包含数据库和配置
 type Service struct {
-	DB          *gorm.DB
-	RDB         *redis.Client
+	DB *gorm.DB
+	// Replicas 只读副本（WithDBReplicas），会话列表、历史消息、搜索、动态等重读接口轮询使用，为空时读主库
+	Replicas []*gorm.DB
+	// PrimaryReads 强制读主库的读场景（ReadScope*，WithPrimaryReads），用于需要立即读到自己写入的场景
+	PrimaryReads map[string]bool
+	replicaSeq   atomic.Uint64
+	// TxRetry 事务遇到死锁等可重试错误时的重试策略（Tx / TxContext 使用），零值不重试
+	TxRetry TxRetryPolicy
+
+	RDB redis.UniversalClient
+	// KV token / 验证码存储（默认基于 RDB 的 Redis 实现，可注入内存实现），为 nil 时登录不签发 token、验证码不可用
+	KV          KVStore
 	TablePrefix string
 	// WsNotifier 用于发送 WebSocket 通知的回调函数
 	// 避免循环依赖，通过函数注入的方式
 	WsNotifier func(userID uint64, message []byte)
 
+	// WsBatchNotifier 批量推送（一次加锁扇出给多个用户），为 nil 时逐个调用 WsNotifier
+	WsBatchNotifier func(userIDs []uint64, message []byte)
+
+	// WsBroadcaster 向所有在线连接广播（有界队列，满时返回 false），可选
+	WsBroadcaster func(message []byte) bool
+
+	// RoomMembersChanged 成员变动提交后回调（joined=false 表示离开），engine 用它维护 WS 房间订阅，可选
+	RoomMembersChanged func(roomID uint64, userIDs []uint64, joined bool)
+
+	// Archive 合规归档（WithComplianceArchive），未开启时为 nil
+	Archive *ComplianceArchive
+
 	// Notify 通知服务（统一落库 + WS 推送 + HTTP 拉取）
 	Notify *NotificationService
 
@@ -25,6 +51,9 @@ type Service struct {
 	// SessionBootstrap WS 建连时加载会话状态（如已读游标）
 	SessionBootstrap *SessionBootstrapService
 
+	// DisplayNames 展示名解析（好友备注 > 群昵称 > 昵称 > 用户名，带缓存），为 nil 时不返回 sender_name
+	DisplayNames *DisplayNameResolver
+
 	// OnlineUserGetter 用于获取在线用户信息（可选）。
 	// 只用于读昵称/头像等展示字段，避免 service 层直接引用 WsServer。
 	OnlineUserGetter func(userID uint64) (nickname string, avatar string, ok bool)
@@ -35,6 +64,100 @@ type Service struct {
 
 	// GroupAvatarMergeConfig 群头像合成配置（由 engine 注入，可选）
 	GroupAvatarMergeConfig *GroupAvatarMergeConfig
+
+	// Storage 合成/上传文件（自动群头像、头像上传）的存储后端（engine 默认注入本地目录实现），为 nil 时头像上传不可用
+	Storage FileStorage
+
+	// VoiceMaxDuration 语音消息最大时长（<=0 使用 DefaultVoiceMaxDuration）
+	VoiceMaxDuration time.Duration
+
+	// AutoUnarchive 新消息是否把已归档的会话移出归档（免打扰的会话保持归档）
+	AutoUnarchive bool
+
+	// AdminUserIDs 运维账号（申诉等运维事件通过 WS 推送给这些用户，可选）
+	AdminUserIDs []uint64
+
+	// AntiSpam 反垃圾频率限制（好友申请/建群/拉人），为 nil 时不限制
+	AntiSpam *AntiSpamService
+
+	// GroupAvatar 成员变动后自动重新合成群头像，为 nil 时不合成
+	GroupAvatar *GroupAvatarService
+
+	// SystemMsg 成员变动/群设置变更写入聊天记录的系统消息，为 nil 时不写
+	SystemMsg *SystemMessageService
+
+	// RoomMessagePusher 把服务端生成并已落库的消息推送给房间成员（由 engine 注入，可选）
+	RoomMessagePusher func(msg *models.Message)
+}
+
+// SessionStore 在线会话状态来源，engine 用它填充 OnlineUserGetter / SessionReadGetter。
+// 默认由 WsServer 的内存 session 实现，多实例部署可通过 chat_sdk.WithSessionStore 替换为共享存储。
+type SessionStore interface {
+	// OnlineUser 在线用户的昵称/头像，不在线返回 ok=false
+	OnlineUser(userID uint64) (nickname, avatar string, ok bool)
+	// ReadSnapshot 已读游标快照（room_id -> last_read_msg_id），无数据返回 nil
+	ReadSnapshot(userID uint64) map[uint64]uint64
+}
+
+// DefaultVoiceMaxDuration 语音消息默认最大时长
+const DefaultVoiceMaxDuration = 60 * time.Second
+
+// Emit 把类型化的 WS 事件推送给 userIDs（type 按事件自动填写），所有服务端主动推送都经过这里
+func (s *Service) Emit(userIDs []uint64, evt message.Event) {
+	if (s.WsNotifier == nil && s.WsBatchNotifier == nil) || len(userIDs) == 0 {
+		return
+	}
+	b, err := message.EncodeEvent(evt)
+	if err != nil {
+		log.Printf("emit %s: %v", evt.WsType(), err)
+		return
+	}
+	s.notifyUsers(userIDs, b)
+}
+
+// NotifyAdmins 把运维事件推送给 AdminUserIDs
+func (s *Service) NotifyAdmins(event string, data any) {
+	s.Emit(s.AdminUserIDs, message.NewDataEvent(event, data))
+}
+
+// Broadcast 向所有在线连接广播 {"type": event, "data": data}（不落库、不补发），返回是否进入发送队列
+func (s *Service) Broadcast(event string, data any) bool {
+	if s.WsBroadcaster == nil {
+		return false
+	}
+	b, err := message.EncodeEvent(message.NewDataEvent(event, data))
+	if err != nil {
+		return false
+	}
+	return s.WsBroadcaster(b)
+}
+
+// notifyUsers 把同一条消息推送给多个用户，优先走 WsBatchNotifier
+func (s *Service) notifyUsers(userIDs []uint64, b []byte) {
+	if len(userIDs) == 0 {
+		return
+	}
+	if s.WsBatchNotifier != nil {
+		s.WsBatchNotifier(userIDs, b)
+		return
+	}
+	if s.WsNotifier == nil {
+		return
+	}
+	for _, uid := range userIDs {
+		s.WsNotifier(uid, b)
+	}
+}
+
+// roomMembersChanged 成员变动（已提交）后通知订阅方
+func (s *Service) roomMembersChanged(roomID uint64, userIDs []uint64, joined bool) {
+	if roomID == 0 || len(userIDs) == 0 {
+		return
+	}
+	s.GroupAvatar.Schedule(roomID)
+	if s.RoomMembersChanged != nil {
+		s.RoomMembersChanged(roomID, userIDs, joined)
+	}
 }
 
 // Table 获取带前缀的表名
@@ -51,4 +174,7 @@ type GroupAvatarMergeConfig struct {
 	Timeout    time.Duration
 	OutputDir  string
 	URLPrefix  string
+
+	// Debounce 成员变动后延迟多久重新合成（期间的变动合并为一次），<=0 使用 DefaultGroupAvatarDebounce
+	Debounce time.Duration
 }
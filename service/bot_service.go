@@ -0,0 +1,352 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// 机器人错误
+var (
+	ErrBotNameRequired  = newError(response.CodeParamError, "err.bot_name_required")
+	ErrBotNotFound      = newError(response.CodeParamError, "err.bot_not_found")
+	ErrBotAPIKeyMissing = newError(response.CodeTokenInvalid, "err.bot_api_key_missing")
+	ErrBotAPIKeyInvalid = newError(response.CodeTokenInvalid, "err.bot_api_key_invalid")
+	ErrBotDisabled      = newError(response.CodePermissionDeny, "err.bot_disabled")
+	ErrBotNotRoomMember = newError(response.CodePermissionDeny, "err.bot_not_room_member")
+)
+
+// BotService 机器人账号：创建/管理 API Key、机器人发消息、Webhook 投递。
+// 机器人本身是一个 IsBot=true 的 User，被拉进房间后即可收发消息：
+// - 发消息：POST /bot/send（API Key 鉴权）
+// - 收消息：WS 连接（与普通用户一致）或 Webhook（房间内有新消息时 POST 到 WebhookURL）
+type BotService struct {
+	*Service
+	messageService *MessageService
+	httpClient     *http.Client
+}
+
+func NewBotService(s *Service) *BotService {
+	log.Println("NewBotService")
+	return &BotService{
+		Service:        s,
+		messageService: NewMessageService(s),
+		httpClient:     newPublicHTTPClient(5 * time.Second),
+	}
+}
+
+// BotDTO 机器人信息（不含 API Key 明文）
+type BotDTO struct {
+	ID           uint64    `json:"id"`
+	UserID       uint64    `json:"user_id"`
+	OwnerID      uint64    `json:"owner_id"`
+	Name         string    `json:"name"`
+	Avatar       string    `json:"avatar"`
+	APIKeyPrefix string    `json:"api_key_prefix"`
+	WebhookURL   string    `json:"webhook_url"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreateBotReq 创建机器人请求
+type CreateBotReq struct {
+	Name       string `json:"name"`
+	Avatar     string `json:"avatar"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// CreateBotResp 创建/重置 Key 的返回。APIKey 明文只在此时返回一次。
+type CreateBotResp struct {
+	Bot           BotDTO `json:"bot"`
+	APIKey        string `json:"api_key"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+func toBotDTO(b *models.Bot) BotDTO {
+	return BotDTO{
+		ID:           b.ID,
+		UserID:       b.UserID,
+		OwnerID:      b.OwnerID,
+		Name:         b.Name,
+		Avatar:       b.User.Avatar,
+		APIKeyPrefix: b.APIKeyPrefix,
+		WebhookURL:   b.WebhookURL,
+		IsActive:     b.IsActive,
+		CreatedAt:    b.CreatedAt,
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKey() (key, hash, keyPrefix string, err error) {
+	r, err := randomHex(24)
+	if err != nil {
+		return "", "", "", err
+	}
+	key = "bk_" + r
+	return key, hashAPIKey(key), key[:10], nil
+}
+
+// CreateBot 创建机器人（同时创建对应的 User）。
+func (s *BotService) CreateBot(ownerID uint64, req CreateBotReq) (*CreateBotResp, error) {
+	name := strings.TrimSpace(req.Name)
+	if ownerID == 0 {
+		return nil, ErrUserIDRequired
+	}
+	if name == "" {
+		return nil, ErrBotNameRequired
+	}
+	webhookURL, err := normalizeWebhookURL(req.WebhookURL)
+	if err != nil {
+		return nil, err
+	}
+
+	key, keyHash, keyPrefix, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	// 机器人不允许密码登录：随机密码即可
+	pwd, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	uid := uuid.New().String()
+	user := &models.User{
+		UID:       uid,
+		Username:  "bot_" + strings.ReplaceAll(uid, "-", "")[:16],
+		Nickname:  name,
+		Password:  string(hash),
+		Avatar:    strings.TrimSpace(req.Avatar),
+		IsBot:     true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	bot := &models.Bot{
+		OwnerID:       ownerID,
+		Name:          name,
+		APIKeyHash:    keyHash,
+		APIKeyPrefix:  keyPrefix,
+		WebhookURL:    webhookURL,
+		WebhookSecret: secret,
+		IsActive:      true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	err = s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		bot.UserID = user.ID
+		return tx.Create(bot).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	bot.User = *user
+
+	return &CreateBotResp{Bot: toBotDTO(bot), APIKey: key, WebhookSecret: secret}, nil
+}
+
+// ListBots 列出用户创建的机器人
+func (s *BotService) ListBots(ownerID uint64) ([]BotDTO, error) {
+	var bots []models.Bot
+	if err := s.DB.Preload("User").
+		Where("owner_id = ?", ownerID).
+		Order("id DESC").
+		Find(&bots).Error; err != nil {
+		return nil, err
+	}
+	out := make([]BotDTO, 0, len(bots))
+	for i := range bots {
+		out = append(out, toBotDTO(&bots[i]))
+	}
+	return out, nil
+}
+
+// ResetAPIKey 重置机器人 API Key（旧 Key 立即失效）
+func (s *BotService) ResetAPIKey(ownerID, botID uint64) (*CreateBotResp, error) {
+	var bot models.Bot
+	if err := s.DB.Preload("User").Where("id = ? AND owner_id = ?", botID, ownerID).First(&bot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBotNotFound
+		}
+		return nil, err
+	}
+
+	key, keyHash, keyPrefix, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(&models.Bot{}).Where("id = ?", bot.ID).
+		Updates(map[string]any{"api_key_hash": keyHash, "api_key_prefix": keyPrefix, "updated_at": time.Now()}).Error; err != nil {
+		return nil, err
+	}
+	bot.APIKeyPrefix = keyPrefix
+	return &CreateBotResp{Bot: toBotDTO(&bot), APIKey: key}, nil
+}
+
+// UpdateWebhook 修改机器人 Webhook 地址（传空表示关闭 Webhook 投递）
+func (s *BotService) UpdateWebhook(ownerID, botID uint64, webhookURL string) error {
+	webhookURL, err := normalizeWebhookURL(webhookURL)
+	if err != nil {
+		return err
+	}
+	res := s.DB.Model(&models.Bot{}).
+		Where("id = ? AND owner_id = ?", botID, ownerID).
+		Updates(map[string]any{"webhook_url": webhookURL, "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBotNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey 校验 API Key，返回对应机器人。
+func (s *BotService) AuthenticateAPIKey(apiKey string) (*models.Bot, error) {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return nil, ErrBotAPIKeyMissing
+	}
+	var bot models.Bot
+	if err := s.DB.Where("api_key_hash = ?", hashAPIKey(apiKey)).First(&bot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBotAPIKeyInvalid
+		}
+		return nil, err
+	}
+	if !bot.IsActive {
+		return nil, ErrBotDisabled
+	}
+	return &bot, nil
+}
+
+// SendMessage 机器人向房间发消息（机器人必须已是房间成员），只落库不推送；
+// HTTP /bot/send 走与用户发消息相同的完整流程（推送、SLA 统计、自动回复），不经过这里。
+func (s *BotService) SendMessage(botUserID, roomID uint64, content string, msgType uint8, extra message.Extra) (*models.Message, error) {
+	if roomID == 0 {
+		return nil, ErrRoomIDRequired
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrContentRequired
+	}
+	if msgType == 0 {
+		msgType = 1
+	}
+	var count int64
+	if err := s.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, botUserID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrBotNotRoomMember
+	}
+	return s.messageService.SaveMessage(roomID, botUserID, content, msgType, extra)
+}
+
+// DispatchToBots 把房间消息通过 Webhook 投递给房间内的机器人成员（异步，尽力而为）。
+// members 为房间成员，senderID 不会收到自己的消息；调用方之后不能再修改 members / payload。
+// 每条房间消息都会调用：查询机器人也放到 goroutine 里，不占推送路径。
+func (s *BotService) DispatchToBots(members []uint64, senderID uint64, payload []byte) {
+	if len(members) == 0 || len(payload) == 0 {
+		return
+	}
+	go s.dispatchToBots(members, senderID, payload)
+}
+
+func (s *BotService) dispatchToBots(members []uint64, senderID uint64, payload []byte) {
+	var bots []models.Bot
+	if err := s.DB.Model(&models.Bot{}).
+		Select("user_id, webhook_url, webhook_secret").
+		Where("user_id IN ? AND user_id <> ? AND is_active = ? AND webhook_url <> ''", members, senderID, true).
+		Find(&bots).Error; err != nil {
+		log.Printf("DispatchToBots query failed: %v", err)
+		return
+	}
+	for _, b := range bots {
+		go s.postWebhook(b.WebhookURL, b.WebhookSecret, payload)
+	}
+}
+
+// normalizeWebhookURL 校验 Webhook 地址（规则同链接预览：公网 http/https、常用端口），空串表示关闭 Webhook
+func normalizeWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || checkPreviewURL(u) != nil {
+		return "", ErrWebhookURLInvalid
+	}
+	return raw, nil
+}
+
+// postWebhook 通过只连公网的 httpClient 投递；校验前写入的旧地址在这里再拦一次
+func (s *BotService) postWebhook(webhookURL, secret string, payload []byte) {
+	if _, err := normalizeWebhookURL(webhookURL); err != nil {
+		log.Printf("bot webhook %s rejected: %v", webhookURL, err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("bot webhook build request failed: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Chat-Signature", "sha256="+SignWebhookPayload(secret, payload))
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		log.Printf("bot webhook post failed: %v", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("bot webhook %s returned %d", webhookURL, resp.StatusCode)
+	}
+}
+
+// SignWebhookPayload 计算 Webhook 签名（HMAC-SHA256，hex），接收方可用同样方式校验。
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
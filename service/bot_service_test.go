@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNormalizeWebhookURL(t *testing.T) {
+	for _, raw := range []string{"", "  ", "https://example.com/hook", "http://93.184.216.34:8080/cb"} {
+		if _, err := normalizeWebhookURL(raw); err != nil {
+			t.Errorf("%q should be allowed: %v", raw, err)
+		}
+	}
+	for _, raw := range []string{
+		"ftp://example.com/hook",
+		"http://127.0.0.1/hook",
+		"http://10.0.0.8:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"https://example.com:6379/",
+		"://bad",
+	} {
+		if _, err := normalizeWebhookURL(raw); !errors.Is(err, ErrWebhookURLInvalid) {
+			t.Errorf("%q should be rejected, got %v", raw, err)
+		}
+	}
+}
+
+// 投递在 goroutine 里查询机器人，慢查询不阻塞推送路径
+func TestBotService_DispatchToBotsAsync(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewBotService(&Service{DB: gormDB})
+
+	mock.ExpectQuery("SELECT user_id, webhook_url, webhook_secret FROM `im_bot`").
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "webhook_url", "webhook_secret"}))
+
+	start := time.Now()
+	svc.DispatchToBots([]uint64{1, 2}, 1, []byte(`{"type":"message"}`))
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("DispatchToBots blocked for %v", elapsed)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("bot lookup not run: %v", mock.ExpectationsWereMet())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
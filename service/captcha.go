@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+)
+
+var (
+	// ErrCaptchaRequired 需要人机验证（客户端应弹出验证码后携带 captcha_token 重试）
+	ErrCaptchaRequired = newError(response.CodeCaptchaRequired, "err.captcha_required")
+	// ErrCaptchaInvalid 人机验证未通过
+	ErrCaptchaInvalid = newError(response.CodeCaptchaRequired, "err.captcha_invalid")
+)
+
+// Captcha 人机验证（服务端二次校验），由 chat_sdk.WithCaptcha 注入。
+// token 为客户端组件返回的凭证，remoteIP 为客户端 IP（可为空）。
+type Captcha interface {
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+var captchaHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+func postCaptchaForm(ctx context.Context, client *http.Client, endpoint string, form url.Values, out any) error {
+	if client == nil {
+		client = captchaHTTPClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verify http status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// HCaptcha hCaptcha 校验
+type HCaptcha struct {
+	Secret     string
+	SiteKey    string // 可选：校验 token 是否属于该站点
+	VerifyURL  string // 默认 https://api.hcaptcha.com/siteverify
+	HTTPClient *http.Client
+}
+
+func (c *HCaptcha) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	endpoint := c.VerifyURL
+	if endpoint == "" {
+		endpoint = "https://api.hcaptcha.com/siteverify"
+	}
+	form := url.Values{"secret": {c.Secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	if c.SiteKey != "" {
+		form.Set("sitekey", c.SiteKey)
+	}
+	var out struct {
+		Success bool `json:"success"`
+	}
+	if err := postCaptchaForm(ctx, c.HTTPClient, endpoint, form, &out); err != nil {
+		return false, err
+	}
+	return out.Success, nil
+}
+
+// ReCaptcha Google reCAPTCHA 校验（v2 / v3；v3 时可设置 MinScore 与 Action）
+type ReCaptcha struct {
+	Secret     string
+	MinScore   float64 // v3 最低分数，0 表示不校验分数
+	Action     string  // v3 期望的 action，为空不校验
+	VerifyURL  string  // 默认 https://www.google.com/recaptcha/api/siteverify
+	HTTPClient *http.Client
+}
+
+func (c *ReCaptcha) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	endpoint := c.VerifyURL
+	if endpoint == "" {
+		endpoint = "https://www.google.com/recaptcha/api/siteverify"
+	}
+	form := url.Values{"secret": {c.Secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	var out struct {
+		Success bool    `json:"success"`
+		Score   float64 `json:"score"`
+		Action  string  `json:"action"`
+	}
+	if err := postCaptchaForm(ctx, c.HTTPClient, endpoint, form, &out); err != nil {
+		return false, err
+	}
+	if !out.Success {
+		return false, nil
+	}
+	if c.MinScore > 0 && out.Score < c.MinScore {
+		return false, nil
+	}
+	if c.Action != "" && out.Action != c.Action {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Geetest 极验 v4 校验。
+// 客户端把 getValidate() 的结果 JSON 序列化后作为 token 提交：
+// {"lot_number":"...","captcha_output":"...","pass_token":"...","gen_time":"..."}
+type Geetest struct {
+	CaptchaID  string
+	CaptchaKey string
+	VerifyURL  string // 默认 https://gcaptcha4.geetest.com/validate
+	HTTPClient *http.Client
+}
+
+func (c *Geetest) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	var p struct {
+		LotNumber     string `json:"lot_number"`
+		CaptchaOutput string `json:"captcha_output"`
+		PassToken     string `json:"pass_token"`
+		GenTime       string `json:"gen_time"`
+	}
+	if err := json.Unmarshal([]byte(token), &p); err != nil || p.LotNumber == "" {
+		return false, nil
+	}
+	endpoint := c.VerifyURL
+	if endpoint == "" {
+		endpoint = "https://gcaptcha4.geetest.com/validate"
+	}
+	mac := hmac.New(sha256.New, []byte(c.CaptchaKey))
+	mac.Write([]byte(p.LotNumber))
+	form := url.Values{
+		"lot_number":     {p.LotNumber},
+		"captcha_output": {p.CaptchaOutput},
+		"pass_token":     {p.PassToken},
+		"gen_time":       {p.GenTime},
+		"sign_token":     {hex.EncodeToString(mac.Sum(nil))},
+	}
+	var out struct {
+		Result string `json:"result"`
+	}
+	if err := postCaptchaForm(ctx, c.HTTPClient, endpoint+"?captcha_id="+url.QueryEscape(c.CaptchaID), form, &out); err != nil {
+		return false, err
+	}
+	return out.Result == "success", nil
+}
+
+// verifyCaptcha 校验 token；未配置 Captcha 时直接通过
+func verifyCaptcha(ctx context.Context, c Captcha, token, remoteIP string) error {
+	if c == nil {
+		return nil
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrCaptchaRequired
+	}
+	ok, err := c.Verify(ctx, token, remoteIP)
+	if err != nil {
+		return fmt.Errorf("人机验证服务异常: %w", err)
+	}
+	if !ok {
+		return ErrCaptchaInvalid
+	}
+	return nil
+}
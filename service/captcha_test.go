@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type stubCaptcha struct{ calls int }
+
+func (c *stubCaptcha) Verify(_ context.Context, token, _ string) (bool, error) {
+	c.calls++
+	return token == "ok", nil
+}
+
+func TestCaptchaProviders(t *testing.T) {
+	var gotQuery, gotSign string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotQuery = r.URL.Query().Get("captcha_id")
+		gotSign = r.PostForm.Get("sign_token")
+		switch r.URL.Path {
+		case "/hcaptcha":
+			_, _ = w.Write([]byte(`{"success": ` + map[bool]string{true: "true", false: "false"}[r.PostForm.Get("response") == "good"] + `}`))
+		case "/recaptcha":
+			_, _ = w.Write([]byte(`{"success": true, "score": 0.3, "action": "login"}`))
+		case "/geetest":
+			_, _ = w.Write([]byte(`{"result": "success"}`))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	h := &HCaptcha{Secret: "s", VerifyURL: srv.URL + "/hcaptcha"}
+	if ok, err := h.Verify(ctx, "good", "1.2.3.4"); err != nil || !ok {
+		t.Fatalf("hcaptcha good: %v %v", ok, err)
+	}
+	if ok, _ := h.Verify(ctx, "bad", ""); ok {
+		t.Fatalf("hcaptcha bad should fail")
+	}
+
+	rc := &ReCaptcha{Secret: "s", VerifyURL: srv.URL + "/recaptcha"}
+	if ok, _ := rc.Verify(ctx, "t", ""); !ok {
+		t.Fatalf("recaptcha without min score should pass")
+	}
+	rc.MinScore = 0.5
+	if ok, _ := rc.Verify(ctx, "t", ""); ok {
+		t.Fatalf("recaptcha low score should fail")
+	}
+
+	g := &Geetest{CaptchaID: "cid", CaptchaKey: "key", VerifyURL: srv.URL + "/geetest"}
+	if ok, _ := g.Verify(ctx, "not json", ""); ok {
+		t.Fatalf("geetest invalid token should fail")
+	}
+	ok, err := g.Verify(ctx, `{"lot_number":"lot","captcha_output":"o","pass_token":"p","gen_time":"1"}`, "")
+	if err != nil || !ok {
+		t.Fatalf("geetest: %v %v", ok, err)
+	}
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("lot"))
+	if gotQuery != "cid" || gotSign != hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("geetest request: id=%s sign=%s", gotQuery, gotSign)
+	}
+}
+
+func TestUserService_LoginCaptcha(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	us := NewUserService(&Service{RDB: rdb})
+	us.Captcha = &stubCaptcha{}
+	us.CaptchaLoginFailures = 2
+	ctx := context.Background()
+
+	// 未达阈值不需要验证
+	if err := us.checkLoginCaptcha(ctx, "alice", "", ""); err != nil {
+		t.Fatalf("below threshold: %v", err)
+	}
+	us.recordLoginFailure(ctx, "alice")
+	us.recordLoginFailure(ctx, "alice")
+	if err := us.checkLoginCaptcha(ctx, "alice", "", ""); !errors.Is(err, ErrCaptchaRequired) {
+		t.Fatalf("want ErrCaptchaRequired, got %v", err)
+	}
+	if err := us.checkLoginCaptcha(ctx, "alice", "wrong", ""); !errors.Is(err, ErrCaptchaInvalid) {
+		t.Fatalf("want ErrCaptchaInvalid, got %v", err)
+	}
+	if err := us.checkLoginCaptcha(ctx, "alice", "ok", ""); err != nil {
+		t.Fatalf("valid captcha: %v", err)
+	}
+	// 其他账号不受影响；登录成功后清零
+	if err := us.checkLoginCaptcha(ctx, "bob", "", ""); err != nil {
+		t.Fatalf("other account: %v", err)
+	}
+	us.clearLoginFailures(ctx, "alice")
+	if err := us.checkLoginCaptcha(ctx, "alice", "", ""); err != nil {
+		t.Fatalf("after clear: %v", err)
+	}
+
+	// 注册/发验证码始终需要
+	if err := us.VerifyCaptcha(ctx, "", ""); !errors.Is(err, ErrCaptchaRequired) {
+		t.Fatalf("VerifyCaptcha empty: %v", err)
+	}
+	// 未开启时直接通过
+	us.Captcha = nil
+	if err := us.VerifyCaptcha(ctx, "", ""); err != nil {
+		t.Fatalf("disabled: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCheckInGroupOnly 签到仅支持群聊
+var ErrCheckInGroupOnly = newError(response.CodeParamError, "err.checkin_group_only")
+
+const checkInDateLayout = "2006-01-02"
+
+// checkInMilestones 连续签到达到这些天数时在群里发系统消息
+var checkInMilestones = map[int]struct{}{7: {}, 30: {}, 100: {}, 365: {}}
+
+// CheckInService 群签到：每日签到、连续天数、排行榜、里程碑系统消息。
+type CheckInService struct {
+	*Service
+	messageService *MessageService
+}
+
+func NewCheckInService(s *Service) *CheckInService {
+	log.Println("NewCheckInService")
+	return &CheckInService{Service: s, messageService: NewMessageService(s)}
+}
+
+// CheckInResult 签到结果
+type CheckInResult struct {
+	AlreadyChecked bool   `json:"already_checked"` // 今天已签过
+	Date           string `json:"date"`
+	CurrentStreak  int    `json:"current_streak"`
+	MaxStreak      int    `json:"max_streak"`
+	TotalDays      int    `json:"total_days"`
+	Milestone      int    `json:"milestone,omitempty"` // 本次达成的里程碑天数
+	// SystemMessage 达成里程碑时生成的系统消息（由调用方推送）
+	SystemMessage *models.Message `json:"-"`
+}
+
+// CheckInRankItem 排行榜条目
+type CheckInRankItem struct {
+	Rank          int    `json:"rank"`
+	UserID        uint64 `json:"user_id"`
+	Nickname      string `json:"nickname"`
+	Avatar        string `json:"avatar"`
+	CurrentStreak int    `json:"current_streak"`
+	MaxStreak     int    `json:"max_streak"`
+	TotalDays     int    `json:"total_days"`
+	LastDate      string `json:"last_date"`
+}
+
+// nextStreak 根据上次签到日期计算今天签到后的连续天数
+func nextStreak(lastDate string, current int, today time.Time) int {
+	if lastDate == today.AddDate(0, 0, -1).Format(checkInDateLayout) {
+		return current + 1
+	}
+	return 1
+}
+
+// CheckIn 群签到（仅群聊）
+func (s *CheckInService) CheckIn(roomID, userID uint64) (*CheckInResult, error) {
+	var room models.Room
+	if err := s.DB.Select("id", "type").First(&room, roomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, err
+	}
+	if room.Type != 2 {
+		return nil, ErrCheckInGroupOnly
+	}
+	var member models.RoomUser
+	if err := s.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotRoomMember
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	today := now.Format(checkInDateLayout)
+	res := &CheckInResult{Date: today}
+
+	err := s.Tx(func(tx *gorm.DB) error {
+		*res = CheckInResult{Date: today}
+		stat := models.RoomCheckInStat{RoomID: roomID, UserID: userID}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("room_id = ? AND user_id = ?", roomID, userID).
+			FirstOrCreate(&stat).Error; err != nil {
+			return err
+		}
+		if stat.LastDate == today {
+			res.AlreadyChecked = true
+		} else {
+			stat.CurrentStreak = nextStreak(stat.LastDate, stat.CurrentStreak, now)
+			stat.TotalDays++
+			if stat.CurrentStreak > stat.MaxStreak {
+				stat.MaxStreak = stat.CurrentStreak
+			}
+			stat.LastDate = today
+			if err := tx.Create(&models.RoomCheckIn{RoomID: roomID, UserID: userID, Date: today, Streak: stat.CurrentStreak, CreatedAt: now}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.RoomCheckInStat{}).Where("id = ?", stat.ID).Updates(map[string]any{
+				"total_days":     stat.TotalDays,
+				"current_streak": stat.CurrentStreak,
+				"max_streak":     stat.MaxStreak,
+				"last_date":      stat.LastDate,
+				"updated_at":     now,
+			}).Error; err != nil {
+				return err
+			}
+			if _, ok := checkInMilestones[stat.CurrentStreak]; ok {
+				res.Milestone = stat.CurrentStreak
+			}
+		}
+		res.CurrentStreak = stat.CurrentStreak
+		res.MaxStreak = stat.MaxStreak
+		res.TotalDays = stat.TotalDays
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Milestone > 0 {
+		name := member.Nickname
+		if name == "" {
+			var u models.User
+			if err := s.DB.Select("id", "nickname").First(&u, userID).Error; err == nil {
+				name = u.Nickname
+			}
+		}
+		content := fmt.Sprintf("🎉 %s 已在本群连续签到 %d 天", name, res.Milestone)
+		msg, err := s.messageService.SaveSystemMessage(roomID, userID, content, message.Extra{UserID: userID})
+		if err != nil {
+			log.Printf("checkin milestone message failed: %v", err)
+		} else {
+			res.SystemMessage = msg
+		}
+	}
+	return res, nil
+}
+
+// Leaderboard 签到排行榜。orderBy: streak（当前连续，默认）/ total（累计）
+// 当前连续天数只有在最后签到日期为今天或昨天时才有效，否则视为已中断。
+func (s *CheckInService) Leaderboard(roomID, viewerID uint64, orderBy string, limit int) ([]CheckInRankItem, error) {
+	ok, err := s.isMember(roomID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotRoomMember
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	now := time.Now()
+	today := now.Format(checkInDateLayout)
+	yesterday := now.AddDate(0, 0, -1).Format(checkInDateLayout)
+
+	q := s.DB.Preload("User").Where("room_id = ?", roomID)
+	if orderBy == "total" {
+		q = q.Order("total_days DESC, max_streak DESC, id ASC")
+	} else {
+		q = q.Where("last_date IN ?", []string{today, yesterday}).
+			Order("current_streak DESC, total_days DESC, id ASC")
+	}
+	var stats []models.RoomCheckInStat
+	if err := q.Limit(limit).Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	out := make([]CheckInRankItem, 0, len(stats))
+	for i, st := range stats {
+		streak := st.CurrentStreak
+		if st.LastDate != today && st.LastDate != yesterday {
+			streak = 0
+		}
+		out = append(out, CheckInRankItem{
+			Rank:          i + 1,
+			UserID:        st.UserID,
+			Nickname:      st.User.Nickname,
+			Avatar:        st.User.Avatar,
+			CurrentStreak: streak,
+			MaxStreak:     st.MaxStreak,
+			TotalDays:     st.TotalDays,
+			LastDate:      st.LastDate,
+		})
+	}
+	return out, nil
+}
+
+func (s *CheckInService) isMember(roomID, userID uint64) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
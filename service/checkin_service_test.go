@@ -0,0 +1,21 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextStreak(t *testing.T) {
+	today := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+
+	if got := nextStreak("", 0, today); got != 1 {
+		t.Errorf("first check-in: got %d", got)
+	}
+	// 跨月的昨天
+	if got := nextStreak("2024-02-29", 6, today); got != 7 {
+		t.Errorf("consecutive: got %d", got)
+	}
+	if got := nextStreak("2024-02-27", 6, today); got != 1 {
+		t.Errorf("broken streak: got %d", got)
+	}
+}
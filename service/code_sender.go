@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+)
+
+var (
+	// ErrCodeSenderUnsupported 该通道不支持此类 identifier（如邮件通道收到手机号），FailoverCodeSender 会跳过
+	ErrCodeSenderUnsupported = errors.New("code sender: unsupported identifier")
+	// ErrCodeDelivery 验证码投递失败（通道异常，详细原因只记日志）
+	ErrCodeDelivery = newError(response.CodeInternalError, "err.code_delivery")
+	// ErrIdentifierUnsupported 没有可以投递到该手机号/邮箱的通道
+	ErrIdentifierUnsupported = newError(response.CodeParamError, "err.identifier_unsupported")
+)
+
+// CodeSender 验证码投递通道（短信/邮件），由 chat_sdk.WithCodeSender 注入。
+// identifier 为规范化后的手机号/邮箱，ttl 为验证码有效期（用于文案）。
+type CodeSender interface {
+	Send(ctx context.Context, purpose VerifyCodePurpose, identifier, code string, ttl time.Duration) error
+}
+
+// CodeTemplate 验证码文案，Subject 仅邮件使用；支持占位符 {code}、{minutes}
+type CodeTemplate struct {
+	Subject string
+	Body    string
+}
+
+// DefaultCodeTemplates 各用途的默认文案，通道未配置对应 purpose 时使用
+var DefaultCodeTemplates = map[VerifyCodePurpose]CodeTemplate{
+	VerifyCodePurposeRegister:       {Subject: "注册验证码", Body: "您的注册验证码是 {code}，{minutes} 分钟内有效。如非本人操作请忽略。"},
+	VerifyCodePurposeForgotPassword: {Subject: "找回密码验证码", Body: "您正在重置密码，验证码 {code}，{minutes} 分钟内有效。请勿泄露给他人。"},
+	VerifyCodePurposeLogin:          {Subject: "登录验证码", Body: "您的登录验证码是 {code}，{minutes} 分钟内有效。请勿泄露给他人。"},
+}
+
+// codeTemplate 取 purpose 的文案：自定义 > 默认 > 通用
+func codeTemplate(custom map[VerifyCodePurpose]CodeTemplate, purpose VerifyCodePurpose) CodeTemplate {
+	if t, ok := custom[purpose]; ok {
+		return t
+	}
+	if t, ok := DefaultCodeTemplates[purpose]; ok {
+		return t
+	}
+	return CodeTemplate{Subject: "验证码", Body: "您的验证码是 {code}，{minutes} 分钟内有效。"}
+}
+
+func (t CodeTemplate) render(code string, ttl time.Duration) (string, string) {
+	r := strings.NewReplacer("{code}", code, "{minutes}", strconv.Itoa(int((ttl+time.Minute-1)/time.Minute)))
+	return r.Replace(t.Subject), r.Replace(t.Body)
+}
+
+func isEmailIdentifier(identifier string) bool {
+	return strings.Contains(identifier, "@")
+}
+
+var codeSenderHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// FailoverCodeSender 依次尝试多个通道，第一个成功即返回；不支持该 identifier 的通道直接跳过。
+// 例：FailoverCodeSender{aliyun, twilio, smtp}，手机号走阿里云失败后切到 Twilio，邮箱走 SMTP。
+type FailoverCodeSender []CodeSender
+
+func (f FailoverCodeSender) Send(ctx context.Context, purpose VerifyCodePurpose, identifier, code string, ttl time.Duration) error {
+	var errs []error
+	for _, s := range f {
+		err := s.Send(ctx, purpose, identifier, code, ttl)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrCodeSenderUnsupported) {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return ErrCodeSenderUnsupported
+	}
+	return errors.Join(errs...)
+}
+
+// SMTPCodeSender 通过 SMTP 发送邮件验证码。Port 为 465 时使用隐式 TLS，其余端口在服务端支持时升级 STARTTLS。
+type SMTPCodeSender struct {
+	Host     string
+	Port     int // 默认 465
+	Username string
+	Password string
+	// From 发件人，如 "Chat <no-reply@example.com>"
+	From      string
+	Templates map[VerifyCodePurpose]CodeTemplate
+	// Timeout 连接+发送超时，默认 15s
+	Timeout time.Duration
+}
+
+func (s *SMTPCodeSender) Send(ctx context.Context, purpose VerifyCodePurpose, identifier, code string, ttl time.Duration) error {
+	if !isEmailIdentifier(identifier) {
+		return ErrCodeSenderUnsupported
+	}
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("smtp from %q: %w", s.From, err)
+	}
+	to, err := mail.ParseAddress(identifier)
+	if err != nil {
+		return ErrCodeSenderUnsupported
+	}
+	subject, body := codeTemplate(s.Templates, purpose).render(code, ttl)
+	msg := buildCodeMail(from, to, subject, body)
+
+	port := s.Port
+	if port == 0 {
+		port = 465
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: s.Host}
+	if port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+	if port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildCodeMail 纯文本 UTF-8 邮件，正文 base64 编码
+func buildCodeMail(from, to *mail.Address, subject, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from.String() + "\r\n")
+	b.WriteString("To: " + to.String() + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	enc := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(enc) > 76 {
+		b.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	b.WriteString(enc + "\r\n")
+	return []byte(b.String())
+}
+
+// AliyunSMSSender 阿里云短信（dysmsapi SendSms）。模板变量固定为 ${code}，文案在阿里云控制台按模板配置。
+type AliyunSMSSender struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SignName        string
+	// TemplateCode 默认模板，TemplateCodes 可按用途覆盖
+	TemplateCode  string
+	TemplateCodes map[VerifyCodePurpose]string
+	Endpoint      string // 默认 https://dysmsapi.aliyuncs.com/
+	HTTPClient    *http.Client
+}
+
+func (s *AliyunSMSSender) Send(ctx context.Context, purpose VerifyCodePurpose, identifier, code string, _ time.Duration) error {
+	if isEmailIdentifier(identifier) {
+		return ErrCodeSenderUnsupported
+	}
+	tpl := s.TemplateCode
+	if t, ok := s.TemplateCodes[purpose]; ok {
+		tpl = t
+	}
+	param, _ := json.Marshal(map[string]string{"code": code})
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	params := map[string]string{
+		"AccessKeyId":      s.AccessKeyID,
+		"Action":           "SendSms",
+		"Format":           "JSON",
+		"RegionId":         "cn-hangzhou",
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   fmt.Sprintf("%x", nonce),
+		"SignatureVersion": "1.0",
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Version":          "2017-05-25",
+		"PhoneNumbers":     identifier,
+		"SignName":         s.SignName,
+		"TemplateCode":     tpl,
+		"TemplateParam":    string(param),
+	}
+	query := aliyunCanonicalQuery(params)
+	mac := hmac.New(sha1.New, []byte(s.AccessKeySecret+"&"))
+	mac.Write([]byte("GET&" + aliyunPercentEncode("/") + "&" + aliyunPercentEncode(query)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://dysmsapi.aliyuncs.com/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?Signature="+aliyunPercentEncode(signature)+"&"+query, nil)
+	if err != nil {
+		return err
+	}
+	client := s.HTTPClient
+	if client == nil {
+		client = codeSenderHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Code    string `json:"Code"`
+		Message string `json:"Message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("aliyun sms: http status %d: %w", resp.StatusCode, err)
+	}
+	if out.Code != "OK" {
+		return fmt.Errorf("aliyun sms: %s %s", out.Code, out.Message)
+	}
+	return nil
+}
+
+// aliyunPercentEncode RPC 签名规则的 URL 编码（空格为 %20，* 为 %2A，~ 不编码）
+func aliyunPercentEncode(s string) string {
+	return strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").Replace(url.QueryEscape(s))
+}
+
+func aliyunCanonicalQuery(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = aliyunPercentEncode(k) + "=" + aliyunPercentEncode(params[k])
+	}
+	return strings.Join(parts, "&")
+}
+
+// TwilioSMSSender Twilio 短信（Programmable Messaging）
+type TwilioSMSSender struct {
+	AccountSID string
+	AuthToken  string
+	// From 发送号码（E.164）或 Messaging Service SID（MG 开头）
+	From string
+	// CountryCode 不以 + 开头的号码自动补的国家码，如 "+86"；为空时原样发送
+	CountryCode string
+	Templates   map[VerifyCodePurpose]CodeTemplate
+	BaseURL     string // 默认 https://api.twilio.com
+	HTTPClient  *http.Client
+}
+
+func (s *TwilioSMSSender) Send(ctx context.Context, purpose VerifyCodePurpose, identifier, code string, ttl time.Duration) error {
+	if isEmailIdentifier(identifier) {
+		return ErrCodeSenderUnsupported
+	}
+	to := identifier
+	if !strings.HasPrefix(to, "+") && s.CountryCode != "" {
+		to = s.CountryCode + strings.TrimLeft(to, "0")
+	}
+	_, body := codeTemplate(s.Templates, purpose).render(code, ttl)
+	form := url.Values{"To": {to}, "Body": {body}}
+	if strings.HasPrefix(s.From, "MG") {
+		form.Set("MessagingServiceSid", s.From)
+	} else {
+		form.Set("From", s.From)
+	}
+
+	base := s.BaseURL
+	if base == "" {
+		base = "https://api.twilio.com"
+	}
+	endpoint := strings.TrimRight(base, "/") + "/2010-04-01/Accounts/" + url.PathEscape(s.AccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	client := s.HTTPClient
+	if client == nil {
+		client = codeSenderHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	var out struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return fmt.Errorf("twilio sms: http status %d: %d %s", resp.StatusCode, out.Code, out.Message)
+}
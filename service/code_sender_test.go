@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeCodeSender struct {
+	err  error
+	sent []string
+}
+
+func (f *fakeCodeSender) Send(_ context.Context, _ VerifyCodePurpose, identifier, code string, _ time.Duration) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, identifier+":"+code)
+	return nil
+}
+
+func TestFailoverCodeSender(t *testing.T) {
+	ctx := context.Background()
+	down := &fakeCodeSender{err: errors.New("provider down")}
+	email := &fakeCodeSender{err: ErrCodeSenderUnsupported}
+	backup := &fakeCodeSender{}
+
+	if err := (FailoverCodeSender{email, down, backup}).Send(ctx, VerifyCodePurposeLogin, "13800138000", "123456", time.Minute); err != nil {
+		t.Fatalf("failover: %v", err)
+	}
+	if len(backup.sent) != 1 {
+		t.Fatalf("backup not used: %v", backup.sent)
+	}
+	if err := (FailoverCodeSender{email}).Send(ctx, VerifyCodePurposeLogin, "13800138000", "123456", time.Minute); !errors.Is(err, ErrCodeSenderUnsupported) {
+		t.Fatalf("expected unsupported, got %v", err)
+	}
+	if err := (FailoverCodeSender{down, email}).Send(ctx, VerifyCodePurposeLogin, "13800138000", "123456", time.Minute); err == nil || errors.Is(err, ErrCodeSenderUnsupported) {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
+
+func TestVerifyCodeService_Sender(t *testing.T) {
+	ctx := context.Background()
+	svc := NewVerifyCodeServiceWithKV(NewMemoryKVStore())
+	sender := &fakeCodeSender{}
+	svc.Sender = sender
+
+	ret, err := svc.SendCode(ctx, VerifyCodePurposeRegister, "a@b.com")
+	if err != nil || len(sender.sent) != 1 || sender.sent[0] != "a@b.com:"+ret.Code {
+		t.Fatalf("send: ret=%#v sent=%v err=%v", ret, sender.sent, err)
+	}
+
+	// 投递失败撤销验证码和冷却，可立即重试
+	sender.err = errors.New("smtp down")
+	if _, err := svc.SendCode(ctx, VerifyCodePurposeLogin, "c@d.com"); !errors.Is(err, ErrCodeDelivery) {
+		t.Fatalf("expected ErrCodeDelivery, got %v", err)
+	}
+	sender.err = ErrCodeSenderUnsupported
+	if _, err := svc.SendCode(ctx, VerifyCodePurposeLogin, "c@d.com"); !errors.Is(err, ErrIdentifierUnsupported) {
+		t.Fatalf("expected ErrIdentifierUnsupported, got %v", err)
+	}
+	sender.err = nil
+	ret, err = svc.SendCode(ctx, VerifyCodePurposeLogin, "c@d.com")
+	if err != nil || ret.Code == "" {
+		t.Fatalf("retry after failure: ret=%#v err=%v", ret, err)
+	}
+}
+
+func TestTwilioSMSSender(t *testing.T) {
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if r.URL.Path != "/2010-04-01/Accounts/AC1/Messages.json" || user != "AC1" || pass != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"code":20003,"message":"Authenticate"}`))
+			return
+		}
+		_ = r.ParseForm()
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	s := &TwilioSMSSender{AccountSID: "AC1", AuthToken: "tok", From: "+15550001111", CountryCode: "+86", BaseURL: srv.URL,
+		Templates: map[VerifyCodePurpose]CodeTemplate{VerifyCodePurposeLogin: {Body: "code {code}, {minutes}min"}}}
+	if err := s.Send(context.Background(), VerifyCodePurposeLogin, "13800138000", "123456", 5*time.Minute); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if form.Get("To") != "+8613800138000" || form.Get("From") != "+15550001111" || form.Get("Body") != "code 123456, 5min" {
+		t.Fatalf("unexpected form: %v", form)
+	}
+	if err := s.Send(context.Background(), VerifyCodePurposeLogin, "a@b.com", "123456", time.Minute); !errors.Is(err, ErrCodeSenderUnsupported) {
+		t.Fatalf("email: %v", err)
+	}
+	s.AuthToken = "bad"
+	if err := s.Send(context.Background(), VerifyCodePurposeLogin, "+13800138000", "123456", time.Minute); err == nil || !strings.Contains(err.Error(), "20003") {
+		t.Fatalf("expected auth error, got %v", err)
+	}
+}
+
+func TestAliyunSMSSender(t *testing.T) {
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		sig := q.Get("Signature")
+		q.Del("Signature")
+		params := map[string]string{}
+		for k := range q {
+			params[k] = q.Get(k)
+		}
+		mac := hmac.New(sha1.New, []byte("secret&"))
+		mac.Write([]byte("GET&%2F&" + aliyunPercentEncode(aliyunCanonicalQuery(params))))
+		if sig != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+			_, _ = w.Write([]byte(`{"Code":"SignatureDoesNotMatch","Message":"bad signature"}`))
+			return
+		}
+		got = q
+		_, _ = w.Write([]byte(`{"Code":"OK","Message":"OK"}`))
+	}))
+	defer srv.Close()
+
+	s := &AliyunSMSSender{AccessKeyID: "ak", AccessKeySecret: "secret", SignName: "测试", TemplateCode: "SMS_1",
+		TemplateCodes: map[VerifyCodePurpose]string{VerifyCodePurposeRegister: "SMS_2"}, Endpoint: srv.URL + "/"}
+	if err := s.Send(context.Background(), VerifyCodePurposeRegister, "13800138000", "654321", time.Minute); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got.Get("TemplateCode") != "SMS_2" || got.Get("PhoneNumbers") != "13800138000" || got.Get("TemplateParam") != `{"code":"654321"}` {
+		t.Fatalf("unexpected query: %v", got)
+	}
+	s.AccessKeySecret = "wrong"
+	if err := s.Send(context.Background(), VerifyCodePurposeLogin, "13800138000", "654321", time.Minute); err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestSMTPCodeSender(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	done := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- ""
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				done <- data.String()
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					reply("250 queued")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO":
+				reply("250 fake")
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				done <- data.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	s := &SMTPCodeSender{Host: "127.0.0.1", Port: p, From: "Chat <no-reply@example.com>"}
+	if err := s.Send(context.Background(), VerifyCodePurposeForgotPassword, "a@b.com", "112233", 5*time.Minute); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	msg := <-done
+	if !strings.Contains(msg, "To: <a@b.com>") || !strings.Contains(msg, "Subject: =?UTF-8?b?") {
+		t.Fatalf("unexpected headers:\n%s", msg)
+	}
+	body := msg[strings.Index(msg, "\r\n\r\n")+4:]
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+	if err != nil || !strings.Contains(string(decoded), "112233") || !strings.Contains(string(decoded), "5 分钟") {
+		t.Fatalf("unexpected body %q: %v", decoded, err)
+	}
+	if err := s.Send(context.Background(), VerifyCodePurposeLogin, "13800138000", "1", time.Minute); !errors.Is(err, ErrCodeSenderUnsupported) {
+		t.Fatalf("phone: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 合规归档事件
+const (
+	ArchiveEventCreated  = "created"           // 发送（含系统消息、转发）
+	ArchiveEventImported = "imported"          // 历史消息导入
+	ArchiveEventRecalled = "recalled"          // 撤回（保留占位）
+	ArchiveEventDeleted  = "deleted"           // 对所有人删除（双删 / 撤回不留占位）
+	ArchiveEventViewOnce = "view_once_cleared" // 阅后即焚被查看，内容清除
+	ArchiveEventExpired  = "expired"           // 限时消息到期删除
+	ArchiveEventPurged   = "purged"            // 超过保留期物理删除
+)
+
+// ArchiveExportMaxLimit 导出单页最大条数
+const ArchiveExportMaxLimit = 1000
+
+// ArchiveSink 外部合规归档（WORM 存储、审计平台等），在消息变更前同步调用。
+// 返回错误时该变更失败，保证不会出现未归档的修改；变更所在事务之后回滚时，sink 中会多出一条未生效的记录。
+type ArchiveSink interface {
+	WriteArchive(ctx context.Context, records []models.MessageArchive) error
+}
+
+// ComplianceArchive 合规归档（WithComplianceArchive 开启）：每条消息写入时、以及撤回/删除/销毁/清理前，
+// 把完整内容追加到 message_archive 表（与变更在同一事务中），并同步写入 Sink（可选）。
+// 归档表只追加，SDK 不提供修改与删除，消息保留期清理也不会触及；生产环境建议对该表只授予 INSERT/SELECT 权限。
+type ComplianceArchive struct {
+	Sink ArchiveSink
+}
+
+// record 把 msgs 当前（变更前）的内容作为 event 追加到归档，db 可为事务；未开启归档时什么也不做
+func (a *ComplianceArchive) record(db *gorm.DB, event string, operatorID uint64, msgs []models.Message) error {
+	if a == nil || len(msgs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	records := make([]models.MessageArchive, 0, len(msgs))
+	for i := range msgs {
+		m := &msgs[i]
+		records = append(records, models.MessageArchive{
+			MessageID:    m.ID,
+			RoomID:       m.RoomID,
+			SenderID:     m.SenderID,
+			Event:        event,
+			OperatorID:   operatorID,
+			ReplyToMsgID: m.ReplyToMsgID,
+			Type:         m.Type,
+			Content:      m.Content,
+			Extra:        m.Extra,
+			IsSystem:     m.IsSystem,
+			Status:       m.Status,
+			MessageAt:    m.CreatedAt,
+			CreatedAt:    now,
+		})
+	}
+	if err := db.Create(&records).Error; err != nil {
+		return fmt.Errorf("compliance archive: %w", err)
+	}
+	if a.Sink != nil {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := a.Sink.WriteArchive(ctx, records); err != nil {
+			return fmt.Errorf("compliance archive sink: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordIDs 先按 ID 读出消息再归档（定时清理等只拿到 ID 的场景），未开启归档时不查询
+func (a *ComplianceArchive) recordIDs(db *gorm.DB, event string, operatorID uint64, ids []uint64) error {
+	if a == nil || len(ids) == 0 {
+		return nil
+	}
+	var msgs []models.Message
+	if err := db.Unscoped().Where("id IN ?", ids).Order("id").Find(&msgs).Error; err != nil {
+		return err
+	}
+	return a.record(db, event, operatorID, msgs)
+}
+
+// ComplianceService 合规归档查询与导出（仅合规角色可调用，见 WithComplianceToken）
+type ComplianceService struct {
+	*Service
+}
+
+func NewComplianceService(s *Service) *ComplianceService {
+	log.Println("NewComplianceService")
+	return &ComplianceService{Service: s}
+}
+
+// ArchiveQuery 归档导出条件，零值表示不限
+type ArchiveQuery struct {
+	RoomID    uint64
+	SenderID  uint64
+	MessageID uint64
+	Event     string
+	Since     time.Time // 归档时间 >= Since
+	Until     time.Time // 归档时间 < Until
+	Cursor    uint64    // 上一页的 next_cursor
+	Limit     int       // 默认 100，最大 ArchiveExportMaxLimit
+}
+
+// ArchiveRecordDTO 归档记录
+type ArchiveRecordDTO struct {
+	ID           uint64         `json:"id"`
+	MessageID    uint64         `json:"message_id"`
+	RoomID       uint64         `json:"room_id"`
+	SenderID     uint64         `json:"sender_id"`
+	Event        string         `json:"event"`
+	OperatorID   uint64         `json:"operator_id"`
+	ReplyToMsgID *uint64        `json:"reply_to_msg_id,omitempty"`
+	Type         uint8          `json:"type"`
+	Content      string         `json:"content"`
+	Extra        datatypes.JSON `json:"extra,omitempty" swaggertype:"object"`
+	IsSystem     bool           `json:"is_system"`
+	Status       uint8          `json:"status"`
+	MessageAt    time.Time      `json:"message_at"`
+	ArchivedAt   time.Time      `json:"archived_at"`
+}
+
+// ArchivePage 一页归档记录，NextCursor 为 0 表示没有更多
+type ArchivePage struct {
+	Records    []ArchiveRecordDTO `json:"records"`
+	NextCursor uint64             `json:"next_cursor"`
+}
+
+// ExportArchive 按条件导出归档记录（按 id 升序，游标翻页）
+func (s *ComplianceService) ExportArchive(q ArchiveQuery) (*ArchivePage, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	q.Limit = min(q.Limit, ArchiveExportMaxLimit)
+
+	db := s.DB.Model(&models.MessageArchive{}).Where("id > ?", q.Cursor)
+	if q.RoomID != 0 {
+		db = db.Where("room_id = ?", q.RoomID)
+	}
+	if q.SenderID != 0 {
+		db = db.Where("sender_id = ?", q.SenderID)
+	}
+	if q.MessageID != 0 {
+		db = db.Where("message_id = ?", q.MessageID)
+	}
+	if q.Event != "" {
+		db = db.Where("event = ?", q.Event)
+	}
+	if !q.Since.IsZero() {
+		db = db.Where("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		db = db.Where("created_at < ?", q.Until)
+	}
+	var rows []models.MessageArchive
+	if err := db.Order("id").Limit(q.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	page := &ArchivePage{Records: make([]ArchiveRecordDTO, 0, len(rows))}
+	if len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+		page.NextCursor = rows[len(rows)-1].ID
+	}
+	for _, r := range rows {
+		page.Records = append(page.Records, ArchiveRecordDTO{
+			ID: r.ID, MessageID: r.MessageID, RoomID: r.RoomID, SenderID: r.SenderID, Event: r.Event, OperatorID: r.OperatorID,
+			ReplyToMsgID: r.ReplyToMsgID, Type: r.Type, Content: r.Content, Extra: r.Extra, IsSystem: r.IsSystem, Status: r.Status,
+			MessageAt: r.MessageAt, ArchivedAt: r.CreatedAt,
+		})
+	}
+	return page, nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+type fakeArchiveSink struct {
+	records []models.MessageArchive
+	err     error
+}
+
+func (f *fakeArchiveSink) WriteArchive(_ context.Context, records []models.MessageArchive) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.records = append(f.records, records...)
+	return nil
+}
+
+func TestPurgeMessagesBefore_ArchivesFirst(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	sink := &fakeArchiveSink{}
+	s := &MessageService{Service: &Service{DB: db, Archive: &ComplianceArchive{Sink: sink}}}
+	before := time.Now().Add(-30 * 24 * time.Hour)
+	sentAt := before.Add(-time.Hour)
+
+	mock.ExpectQuery("SELECT `id` FROM `im_message` WHERE created_at < \\? ORDER BY id ASC LIMIT \\?").
+		WithArgs(before, messagePurgeBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE id IN \\(\\?\\) ORDER BY id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "type", "content", "status", "created_at"}).
+			AddRow(1, 10, 100, 1, "hello", models.MessageStatusSent, sentAt))
+	mock.ExpectExec("INSERT INTO `im_message_archive`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM `im_message_status` WHERE message_id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM `im_message` WHERE id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := s.PurgeMessagesBefore(before)
+	if err != nil || n != 1 {
+		t.Fatalf("got (%d, %v), want (1, nil)", n, err)
+	}
+	if len(sink.records) != 1 {
+		t.Fatalf("sink records = %d, want 1", len(sink.records))
+	}
+	r := sink.records[0]
+	if r.MessageID != 1 || r.Event != ArchiveEventPurged || r.Content != "hello" || !r.MessageAt.Equal(sentAt) {
+		t.Fatalf("unexpected archive record %+v", r)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPurgeMessagesBefore_SinkFailureAborts(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	sinkErr := errors.New("worm unavailable")
+	s := &MessageService{Service: &Service{DB: db, Archive: &ComplianceArchive{Sink: &fakeArchiveSink{err: sinkErr}}}}
+
+	mock.ExpectQuery("SELECT `id` FROM `im_message`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "content"}).AddRow(1, 10, 100, "hello"))
+	mock.ExpectExec("INSERT INTO `im_message_archive`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	// 归档失败时不能继续删除
+	n, err := s.PurgeMessagesBefore(time.Now())
+	if !errors.Is(err, sinkErr) || n != 0 {
+		t.Fatalf("got (%d, %v), want (0, %v)", n, err, sinkErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportArchive_Paging(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &ComplianceService{Service: &Service{DB: db}}
+
+	mock.ExpectQuery("SELECT \\* FROM `im_message_archive` WHERE id > \\? AND room_id = \\? AND event = \\? ORDER BY id LIMIT \\?").
+		WithArgs(5, 10, ArchiveEventRecalled, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "room_id", "event", "content"}).
+			AddRow(6, 1, 10, ArchiveEventRecalled, "a").
+			AddRow(8, 2, 10, ArchiveEventRecalled, "b").
+			AddRow(9, 3, 10, ArchiveEventRecalled, "c"))
+
+	page, err := s.ExportArchive(ArchiveQuery{RoomID: 10, Event: ArchiveEventRecalled, Cursor: 5, Limit: 2})
+	if err != nil {
+		t.Fatalf("ExportArchive: %v", err)
+	}
+	if len(page.Records) != 2 || page.NextCursor != 8 || page.Records[1].Content != "b" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComplianceArchive_NilIsNoop(t *testing.T) {
+	var a *ComplianceArchive
+	if err := a.record(nil, ArchiveEventCreated, 1, []models.Message{{ID: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.recordIDs(nil, ArchiveEventPurged, 0, []uint64{1}); err != nil {
+		t.Fatal(err)
+	}
+}
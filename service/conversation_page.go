@@ -0,0 +1,152 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+)
+
+// 会话分页/增量拉取的条数
+const (
+	conversationPageDefault    = 20
+	conversationPageMax        = 100
+	conversationUpdatesDefault = 100
+	conversationUpdatesMax     = 500
+)
+
+// ErrConversationCursor 分页/增量游标格式错误（客户端应丢弃游标重新拉取）
+var ErrConversationCursor = newError(response.CodeParamError, "err.conversation_cursor_invalid")
+
+// ConversationPageDTO 会话列表的一页
+type ConversationPageDTO struct {
+	Items      []ConversationListItemDTO `json:"items"`
+	NextCursor string                    `json:"next_cursor,omitempty"` // 为空表示没有更多
+	// ServerTime 服务端时间（毫秒），拉完第一页后以它作为 since 调用增量接口
+	ServerTime int64 `json:"server_time"`
+}
+
+// ConversationUpdatesDTO 增量变化的会话（按更新时间升序）
+type ConversationUpdatesDTO struct {
+	// Items 含已归档与已隐藏的会话（hidden=true 时客户端应从列表移除）
+	Items []ConversationListItemDTO `json:"items"`
+	// Cursor 下次增量拉取传入；没有变化时原样返回
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// conversationCursor 会话在排序中的位置：置顶、更新时间（微秒）、会话 ID
+type conversationCursor struct {
+	pinned    bool
+	updatedAt time.Time
+	id        uint64
+}
+
+func (c conversationCursor) String() string {
+	pinned := 0
+	if c.pinned {
+		pinned = 1
+	}
+	return fmt.Sprintf("%d.%d.%d", pinned, c.updatedAt.UnixMicro(), c.id)
+}
+
+func cursorOf(r *conversationRow) conversationCursor {
+	return conversationCursor{pinned: r.IsPinned, updatedAt: r.UpdatedAt, id: r.ConversationID}
+}
+
+func parseConversationCursor(s string) (conversationCursor, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || (parts[0] != "0" && parts[0] != "1") {
+		return conversationCursor{}, ErrConversationCursor
+	}
+	micro, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return conversationCursor{}, ErrConversationCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return conversationCursor{}, ErrConversationCursor
+	}
+	return conversationCursor{pinned: parts[0] == "1", updatedAt: time.UnixMicro(micro), id: id}, nil
+}
+
+// ListConversationPage 分页获取会话列表：置顶在前，同组内按更新时间倒序、会话 ID 倒序（稳定排序）。
+// cursor 为上一页返回的 next_cursor，首页传空；翻页期间会话被更新会移到前面，由增量接口补齐。
+func (s *ConversationService) ListConversationPage(userID uint64, opts ConversationListOptions, cursor string, limit int) (*ConversationPageDTO, error) {
+	if limit <= 0 {
+		limit = conversationPageDefault
+	}
+	limit = min(limit, conversationPageMax)
+	var after *conversationCursor
+	if cursor != "" {
+		c, err := parseConversationCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &c
+	}
+
+	now := time.Now()
+	rows, items, err := s.queryConversations(userID, func(q *gorm.DB) *gorm.DB {
+		q = conversationFilter(q, opts)
+		if after != nil {
+			q = q.Where("c.is_pinned < ? OR (c.is_pinned = ? AND (c.updated_at < ? OR (c.updated_at = ? AND c.id < ?)))",
+				after.pinned, after.pinned, after.updatedAt, after.updatedAt, after.id)
+		}
+		return q.Order("c.is_pinned DESC").Order("c.updated_at DESC").Order("c.id DESC").Limit(limit + 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &ConversationPageDTO{Items: items, ServerTime: now.UnixMilli()}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = cursorOf(&rows[limit-1]).String()
+	}
+	return page, nil
+}
+
+// ConversationUpdates 增量获取 since（毫秒）之后有变化（新消息、置顶/免打扰/归档/隐藏）的会话，按更新时间升序；
+// 继续拉取时传上次返回的 cursor（优先于 since）。返回的会话不按标签过滤，包含已归档与已隐藏的。
+func (s *ConversationService) ConversationUpdates(userID uint64, since int64, cursor string, limit int) (*ConversationUpdatesDTO, error) {
+	if limit <= 0 {
+		limit = conversationUpdatesDefault
+	}
+	limit = min(limit, conversationUpdatesMax)
+	after := conversationCursor{updatedAt: time.UnixMilli(since)}
+	if cursor != "" {
+		c, err := parseConversationCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	rows, items, err := s.queryConversations(userID, func(q *gorm.DB) *gorm.DB {
+		return q.Where("c.updated_at > ? OR (c.updated_at = ? AND c.id > ?)", after.updatedAt, after.updatedAt, after.id).
+			Order("c.updated_at ASC").Order("c.id ASC").Limit(limit + 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := &ConversationUpdatesDTO{Items: items}
+	if len(items) > limit {
+		out.Items, rows, out.HasMore = items[:limit], rows[:limit], true
+	}
+	for i := range out.Items {
+		out.Items[i].Hidden = !rows[i].IsVisible
+	}
+	if n := len(rows); n > 0 {
+		// 增量游标只按更新时间 + ID 推进，置顶位不参与比较
+		last := cursorOf(&rows[n-1])
+		last.pinned = false
+		out.Cursor = last.String()
+	} else {
+		after.pinned = false
+		out.Cursor = after.String()
+	}
+	return out, nil
+}
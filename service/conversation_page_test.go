@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newConversationListDB 会话列表查询用到的表（sqlite 内存库），房间 1~9 均为群聊
+func newConversationListDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:conv_list_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Conversation{}, &models.ConversationTag{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// 会话列表 JOIN 的表：迁移会话表时已随关联建出的直接复用，其余只建用到的列
+	for _, ddl := range []string{
+		"CREATE TABLE IF NOT EXISTS im_room (id INTEGER PRIMARY KEY, type INTEGER, room_account TEXT, name TEXT, avatar TEXT, last_message_id INTEGER, deleted_at DATETIME)",
+		"CREATE TABLE IF NOT EXISTS im_room_user (room_id INTEGER, user_id INTEGER, nickname TEXT)",
+		"CREATE TABLE IF NOT EXISTS im_user (id INTEGER PRIMARY KEY, username TEXT, nickname TEXT, avatar TEXT, deleted_at DATETIME)",
+		"CREATE TABLE IF NOT EXISTS im_friend (user_id INTEGER, friend_id INTEGER, status INTEGER, remark TEXT)",
+	} {
+		if err := db.Exec(ddl).Error; err != nil {
+			t.Fatalf("create table: %v", err)
+		}
+	}
+	for roomID := 1; roomID <= 9; roomID++ {
+		if err := db.Exec("INSERT INTO im_room (id, type, room_account, name) VALUES (?, 2, ?, ?)", roomID, fmt.Sprint("g", roomID), fmt.Sprint("群", roomID)).Error; err != nil {
+			t.Fatalf("create room: %v", err)
+		}
+	}
+	return db
+}
+
+func TestConversationService_Page(t *testing.T) {
+	db := newConversationListDB(t)
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	// 房间 1~6：3、5 置顶；2 和 4 更新时间相同；6 已隐藏
+	for roomID := uint64(1); roomID <= 6; roomID++ {
+		conv := models.Conversation{UserID: 1, RoomID: roomID, IsVisible: roomID != 6, IsPinned: roomID == 3 || roomID == 5}
+		if err := db.Create(&conv).Error; err != nil {
+			t.Fatalf("create conversation: %v", err)
+		}
+		at := base.Add(time.Duration(roomID) * time.Minute)
+		if roomID == 4 {
+			at = base.Add(2 * time.Minute)
+		}
+		db.Model(&models.Conversation{}).Where("id = ?", conv.ID).UpdateColumn("updated_at", at)
+		if roomID == 6 {
+			db.Model(&models.Conversation{}).Where("id = ?", conv.ID).UpdateColumn("is_visible", false)
+		}
+	}
+	s := NewConversationService(&Service{DB: db})
+
+	var got []uint64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, err := s.ListConversationPage(1, ConversationListOptions{}, cursor, 2)
+		if err != nil || pages > 3 {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		for _, item := range page.Items {
+			got = append(got, item.RoomID)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if fmt.Sprint(got) != "[5 3 4 2 1]" {
+		t.Fatalf("order: %v", got)
+	}
+	if _, err := s.ListConversationPage(1, ConversationListOptions{}, "bad", 2); !errors.Is(err, ErrConversationCursor) {
+		t.Fatalf("bad cursor: %v", err)
+	}
+
+	// 增量：base+2min 之后的 3、4(同时间，按 ID)、5、6(隐藏)
+	up, err := s.ConversationUpdates(1, base.Add(2*time.Minute).UnixMilli()-1, "", 2)
+	if err != nil || !up.HasMore || len(up.Items) != 2 || up.Items[0].RoomID != 2 || up.Items[1].RoomID != 4 {
+		t.Fatalf("updates: %+v %v", up, err)
+	}
+	up, err = s.ConversationUpdates(1, 0, up.Cursor, 10)
+	if err != nil || up.HasMore || len(up.Items) != 3 || up.Items[2].RoomID != 6 || !up.Items[2].Hidden {
+		t.Fatalf("updates next: %+v %v", up, err)
+	}
+	same, err := s.ConversationUpdates(1, 0, up.Cursor, 10)
+	if err != nil || len(same.Items) != 0 || same.Cursor != up.Cursor {
+		t.Fatalf("no change: %+v %v", same, err)
+	}
+}
@@ -1,10 +1,14 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
 	"gorm.io/gorm"
 )
 
@@ -19,9 +23,23 @@ type ConversationListItemDTO struct {
 	Avatar         string      `json:"avatar"`    // 私聊：对方头像；群聊：群头像
 	LastMessage    *MessageDTO `json:"last_message,omitempty"`
 	UnreadCount    uint64      `json:"unread_count"`
-	UpdatedAt      int64       `json:"updated_at"` // unix seconds for easy sort/render
+	IsMuted        bool        `json:"is_muted"`         // 免打扰
+	IsPinned       bool        `json:"is_pinned"`        // 置顶
+	IsArchived     bool        `json:"is_archived"`      // 已归档
+	Tags           []string    `json:"tags,omitempty"`   // 我给会话打的标签（按名称排序）
+	Hidden         bool        `json:"hidden,omitempty"` // 已隐藏（只出现在增量接口中，客户端应从列表移除）
+	UpdatedAt      int64       `json:"updated_at"`       // unix seconds for easy sort/render
 }
 
+// ConversationListOptions 会话列表查询选项
+type ConversationListOptions struct {
+	Archived bool   // 只列已归档（否则只列未归档）
+	Tag      string // 只列打了该标签的会话
+}
+
+// ErrConversationNotFound 会话不存在（未加入房间或会话已被清理）
+var ErrConversationNotFound = newError(response.CodeParamError, "err.conversation_not_found")
+
 type ConversationService struct {
 	*Service
 }
@@ -31,87 +49,117 @@ func NewConversationService(s *Service) *ConversationService {
 	return &ConversationService{Service: s}
 }
 
-// GetConversationList 获取当前用户的会话列表（消息列表）
-func (s *ConversationService) GetConversationList(userID uint64) ([]ConversationListItemDTO, error) {
-	var convs []models.Conversation
-	err := s.DB.Model(&models.Conversation{}).
-		Where("user_id = ? AND is_visible = ?", userID, true).
-		Order("updated_at DESC").
-		Find(&convs).Error
-	if err != nil {
-		return nil, err
-	}
-	if len(convs) == 0 {
-		return []ConversationListItemDTO{}, nil
+// conversationRow 会话列表聚合查询的一行
+type conversationRow struct {
+	ConversationID uint64
+	RoomID         uint64
+	UnreadCount    uint64
+	IsMuted        bool
+	IsPinned       bool
+	IsArchived     bool
+	IsVisible      bool
+	UpdatedAt      time.Time
+	RoomType       uint8
+	RoomAccount    string
+	RoomName       string
+	RoomAvatar     string
+	LastMessageID  *uint64
+	GroupNickname  string // 我在群里的昵称
+	OtherID        *uint64
+	OtherUsername  string
+	OtherNickname  string
+	OtherAvatar    string
+	FriendRemark   string
+}
+
+// GetConversationList 获取当前用户的会话列表（消息列表，不含已归档），tag 非空时只返回打了该标签的会话
+func (s *ConversationService) GetConversationList(userID uint64, tag ...string) ([]ConversationListItemDTO, error) {
+	opts := ConversationListOptions{}
+	if len(tag) > 0 {
+		opts.Tag = tag[0]
 	}
+	return s.ListConversations(userID, opts)
+}
+
+// GetArchivedConversationList 获取当前用户已归档的会话列表
+func (s *ConversationService) GetArchivedConversationList(userID uint64) ([]ConversationListItemDTO, error) {
+	return s.ListConversations(userID, ConversationListOptions{Archived: true})
+}
+
+// ListConversations 按归档状态/标签列出可见会话（按最近更新排序，不分页）
+func (s *ConversationService) ListConversations(userID uint64, opts ConversationListOptions) ([]ConversationListItemDTO, error) {
+	_, items, err := s.queryConversations(userID, func(q *gorm.DB) *gorm.DB {
+		return conversationFilter(q, opts).Order("c.updated_at DESC")
+	})
+	return items, err
+}
 
-	// 全部 房间ID
-	roomIDs := make([]uint64, 0, len(convs))
-	// convMap: roomID -> conv
-	convMap := make(map[uint64]models.Conversation, len(convs))
-	for _, c := range convs {
-		roomIDs = append(roomIDs, c.RoomID)
-		convMap[c.RoomID] = c
+// conversationFilter 可见、归档状态与标签过滤
+func conversationFilter(q *gorm.DB, opts ConversationListOptions) *gorm.DB {
+	if tag := strings.TrimSpace(opts.Tag); tag != "" {
+		q = q.Joins("JOIN "+models.ConversationTag{}.TableName()+" AS t ON t.user_id = c.user_id AND t.room_id = c.room_id AND t.tag = ?", tag)
 	}
+	return q.Where("c.is_visible = ?", true).Where("c.is_archived = ?", opts.Archived)
+}
 
-	// rooms
-	var rooms []models.Room
-	if err := s.DB.Model(&models.Room{}).
-		Where("id IN ?", roomIDs).
-		Find(&rooms).Error; err != nil {
-		return nil, err
+// queryConversations 会话列表的公共查询，scope 追加过滤、排序与条数。
+// 查询次数固定：
+//  1. 会话 + 房间 + 我的群昵称 + 私聊对方资料 + 好友备注（一条 JOIN，走 conversation(user_id, room_id) 索引；按标签过滤时再 JOIN 标签表）
+//  2. 最后一条消息 + 发送者（一条 JOIN，按主键）
+//  3. 会话标签（按 user_id + room_id）
+//
+// 未读数来自会话投影 conversation.unread_count（写消息时维护），内存中已读游标更新时优先使用。
+func (s *ConversationService) queryConversations(userID uint64, scope func(q *gorm.DB) *gorm.DB) ([]conversationRow, []ConversationListItemDTO, error) {
+	db := s.ReadDB(ReadScopeConversation)
+	q := db.Table(models.Conversation{}.TableName()+" AS c").
+		Select(`c.id AS conversation_id, c.room_id, c.unread_count, c.is_muted, c.is_pinned, c.is_archived, c.is_visible, c.updated_at,
+			r.type AS room_type, r.room_account, r.name AS room_name, r.avatar AS room_avatar, r.last_message_id,
+			me.nickname AS group_nickname,
+			ou.id AS other_id, ou.username AS other_username, ou.nickname AS other_nickname, ou.avatar AS other_avatar,
+			f.remark AS friend_remark`).
+		Joins("JOIN "+models.Room{}.TableName()+" AS r ON r.id = c.room_id AND r.deleted_at IS NULL").
+		Joins("LEFT JOIN "+models.RoomUser{}.TableName()+" AS me ON me.room_id = c.room_id AND me.user_id = c.user_id").
+		Joins("LEFT JOIN "+models.RoomUser{}.TableName()+" AS o ON r.type = 1 AND o.room_id = c.room_id AND o.user_id <> c.user_id").
+		Joins("LEFT JOIN "+models.User{}.TableName()+" AS ou ON ou.id = o.user_id AND ou.deleted_at IS NULL").
+		Joins("LEFT JOIN "+(&models.Friend{}).TableName()+" AS f ON f.user_id = c.user_id AND f.friend_id = o.user_id AND f.status = 1").
+		Where("c.user_id = ?", userID)
+	var rows []conversationRow
+	if err := scope(q).Scan(&rows).Error; err != nil {
+		return nil, nil, err
 	}
-	roomMap := make(map[uint64]models.Room, len(rooms))
-	privateRoomIDs := make([]uint64, 0)
-	// 用于批量查询 last message
-	lastMsgIDs := make([]uint64, 0, len(rooms))
-	seenMsg := make(map[uint64]struct{}, len(rooms))
-	for _, r := range rooms {
-		roomMap[r.ID] = r
-		if r.Type == 1 {
-			privateRoomIDs = append(privateRoomIDs, r.ID)
-		}
-		if r.LastMessageID != nil && *r.LastMessageID > 0 {
-			mid := *r.LastMessageID
-			if _, ok := seenMsg[mid]; !ok {
-				seenMsg[mid] = struct{}{}
-				lastMsgIDs = append(lastMsgIDs, mid)
-			}
-		}
+	if len(rows) == 0 {
+		return rows, []ConversationListItemDTO{}, nil
 	}
 
 	// 批量查询最后一条消息（含 sender）
-	lastMsgMap := make(map[uint64]*MessageDTO, len(lastMsgIDs)) // key: room_id
+	lastMsgIDs := make([]uint64, 0, len(rows))
+	roomIDs := make([]uint64, 0, len(rows))
+	for _, r := range rows {
+		if r.LastMessageID != nil && *r.LastMessageID > 0 {
+			lastMsgIDs = append(lastMsgIDs, *r.LastMessageID)
+		}
+		roomIDs = append(roomIDs, r.RoomID)
+	}
+	msgByID := make(map[uint64]*MessageDTO, len(lastMsgIDs))
 	if len(lastMsgIDs) > 0 {
 		var msgs []models.Message
-		if err := s.DB.Model(&models.Message{}).
-			Preload("Sender").
-			Where("id IN ?", lastMsgIDs).
+		if err := db.Model(&models.Message{}).
+			Joins("Sender").
+			Where(models.Message{}.TableName()+".id IN ?", lastMsgIDs).
 			Find(&msgs).Error; err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		msgByID := make(map[uint64]models.Message, len(msgs))
 		for i := range msgs {
-			msgByID[msgs[i].ID] = msgs[i]
-		}
-		for _, r := range rooms {
-			if r.LastMessageID == nil || *r.LastMessageID == 0 {
-				continue
-			}
-			m, ok := msgByID[*r.LastMessageID]
-			if !ok {
-				continue
-			}
-			lastMsgMap[r.ID] = ToMessageDTO(&m)
+			msgByID[msgs[i].ID] = ToMessageDTO(&msgs[i])
 		}
 	}
 
-	// 预计算未读数：roomID -> unread
-	// 设计：ReadList 只保存“有未读的房间”以及对应 last_read_msg_id。
-	// - 命中 ReadList：用 (lastRead, lastMsgID] 统计未读数。
-	// - 未命中 ReadList：视为 0（说明该房间没有未读）。
-	unreadMap := make(map[uint64]uint64, len(roomIDs))
+	tagsByRoom, err := conversationTagsByRoom(db, userID, roomIDs)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	// 在线用户内存中的已读游标可能比库里新（尚未 flush）
 	sessionReads := map[uint64]uint64{}
 	if s.SessionReadGetter != nil {
 		if m := s.SessionReadGetter(userID); len(m) > 0 {
@@ -119,181 +167,58 @@ func (s *ConversationService) GetConversationList(userID uint64) ([]Conversation
 		}
 	}
 
-	type rng struct {
-		roomID    uint64
-		lastRead  uint64
-		lastMsgID uint64
-	}
-	ranges := make([]rng, 0, len(roomIDs))
-	for _, rid := range roomIDs {
-		r, ok := roomMap[rid]
-		if !ok {
-			unreadMap[rid] = 0
-			continue
-		}
-		if r.LastMessageID == nil || *r.LastMessageID == 0 {
-			unreadMap[rid] = 0
-			continue
-		}
-		lastMsgID := *r.LastMessageID
-
-		// 未命中 sessionReads：按你的规则，代表没有未读
-		lastRead, ok := sessionReads[rid]
-		if !ok {
-			unreadMap[rid] = 0
-			continue
-		}
-
-		if lastRead >= lastMsgID {
-			unreadMap[rid] = 0
-			continue
-		}
-		ranges = append(ranges, rng{roomID: rid, lastRead: lastRead, lastMsgID: lastMsgID})
-		unreadMap[rid] = 0
-	}
-
-	if len(ranges) > 0 {
-		q := s.DB.Model(&models.Message{}).
-			Select("room_id, COUNT(1) AS cnt")
-		for i, rg := range ranges {
-			cond := "room_id = ? AND id > ? AND id <= ?"
-			args := []any{rg.roomID, rg.lastRead, rg.lastMsgID}
-			if i == 0 {
-				q = q.Where(cond, args...)
-			} else {
-				q = q.Or(cond, args...)
-			}
-		}
-		q = q.Group("room_id")
-
-		type row struct {
-			RoomID uint64
-			Cnt    int64
-		}
-		var rows []row
-		if err := q.Scan(&rows).Error; err != nil {
-			return nil, err
-		}
-		for _, r := range rows {
-			if r.Cnt < 0 {
-				unreadMap[r.RoomID] = 0
-				continue
-			}
-			unreadMap[r.RoomID] = uint64(r.Cnt)
-		}
-	}
-
-	// 其他私人房间用户：Map[roomID]User
-	otherUserMap := make(map[uint64]models.User)
-	friendRemarkMap := make(map[uint64]string)
-	if len(privateRoomIDs) > 0 {
-		var roomUsers []models.RoomUser
-		// 查找这些私聊房间里，user_id != 当前 userID 的记录
-		if err := s.DB.Preload("User").
-			Where("room_id IN ? AND user_id <> ?", privateRoomIDs, userID).
-			Find(&roomUsers).Error; err == nil {
-			for _, ru := range roomUsers {
-				otherUserMap[ru.RoomID] = ru.User
-			}
-		}
-
-		// 取出对方 user_id 列表，用于查 remark
-		otherIDs := make([]uint64, 0, len(roomUsers))
-		roomToOtherID := make(map[uint64]uint64)
-		for _, ru := range roomUsers {
-			otherIDs = append(otherIDs, ru.UserID)
-			roomToOtherID[ru.RoomID] = ru.UserID
-		}
-		if len(otherIDs) > 0 {
-			var friends []models.Friend
-			_ = s.DB.Model(&models.Friend{}).
-				Select("friend_id, remark").
-				Where("user_id = ? AND friend_id IN ? AND status = ?", userID, otherIDs, 1).
-				Find(&friends).Error
-			remarkByFriendID := make(map[uint64]string, len(friends))
-			for _, f := range friends {
-				if f.Remark != "" {
-					remarkByFriendID[f.FriendID] = f.Remark
-				}
-			}
-			for roomID, otherID := range roomToOtherID {
-				if rmk, ok := remarkByFriendID[otherID]; ok {
-					friendRemarkMap[roomID] = rmk
-				}
-			}
-		}
-	}
-
-	// 用户的群昵称
-	groupNicknameMap := make(map[uint64]string)
-	{
-		var rows []models.RoomUser
-		_ = s.DB.Model(&models.RoomUser{}).
-			Select("room_id, nickname").
-			Where("user_id = ? AND room_id IN ?", userID, roomIDs).
-			Find(&rows).Error
-		for _, ru := range rows {
-			if ru.Nickname != "" {
-				groupNicknameMap[ru.RoomID] = ru.Nickname
-			}
-		}
-	}
-
-	out := make([]ConversationListItemDTO, 0, len(convs))
-	for _, c := range convs {
-		r, ok := roomMap[c.RoomID]
-		if !ok {
-			// room 被删了，跳过
-			continue
-		}
-
+	out := make([]ConversationListItemDTO, 0, len(rows))
+	for _, r := range rows {
 		item := ConversationListItemDTO{
-			ConversationID: c.ID,
-			RoomID:         r.ID,
+			ConversationID: r.ConversationID,
+			RoomID:         r.RoomID,
 			// 私聊：对方用户ID；群聊：0（下面 switch 会覆盖修正）
 			UserID:      0,
 			RoomAccount: r.RoomAccount,
-			RoomType:    r.Type,
-			UnreadCount: unreadMap[r.ID],
-			UpdatedAt:   c.UpdatedAt.Unix(),
-			LastMessage: lastMsgMap[r.ID],
+			RoomType:    r.RoomType,
+			UnreadCount: r.UnreadCount,
+			IsMuted:     r.IsMuted,
+			IsPinned:    r.IsPinned,
+			IsArchived:  r.IsArchived,
+			Tags:        tagsByRoom[r.RoomID],
+			UpdatedAt:   r.UpdatedAt.Unix(),
+		}
+		if r.LastMessageID != nil {
+			item.LastMessage = msgByID[*r.LastMessageID]
+			if lastRead, ok := sessionReads[r.RoomID]; ok && lastRead >= *r.LastMessageID {
+				item.UnreadCount = 0
+			}
 		}
 
-		switch r.Type {
+		switch r.RoomType {
 		case 1:
-			if other, ok := otherUserMap[r.ID]; ok {
-				item.UserID = other.ID
-				// 优先好友备注
-				if rmk, ok := friendRemarkMap[r.ID]; ok {
-					item.Name = rmk
-				} else if other.Nickname != "" {
-					item.Name = other.Nickname
-				} else {
-					item.Name = other.Username
-				}
-				item.Avatar = other.Avatar
+			if r.OtherID != nil {
+				item.UserID = *r.OtherID
+				// 私聊没有群昵称：好友备注 > 昵称 > 用户名
+				item.Name = DisplayName(r.FriendRemark, "", r.OtherNickname, r.OtherUsername)
+				item.Avatar = r.OtherAvatar
 			} else {
 				item.Name = "未知用户"
 				item.Avatar = ""
 			}
 		case 2:
 			item.UserID = 0
-			item.Name = r.Name
-			if nn, ok := groupNicknameMap[r.ID]; ok {
-				item.Name = nn
+			item.Name = r.RoomName
+			if r.GroupNickname != "" {
+				item.Name = r.GroupNickname
 			}
-			item.Avatar = r.Avatar
+			item.Avatar = r.RoomAvatar
 			if item.Name == "" {
 				item.Name = "群聊"
 			}
 		default:
-			item.Name = fmt.Sprintf("room_%d", r.ID)
+			item.Name = fmt.Sprintf("room_%d", r.RoomID)
 		}
 
 		out = append(out, item)
 	}
 
-	return out, nil
+	return rows, out, nil
 }
 
 // EnsureConversationForRoom 确保会话存在（用于首次进入房间或发送消息时创建）
@@ -330,3 +255,57 @@ func (s *ConversationService) UpdateConversationLastMessage(userID, roomID, mess
 		Update("updated_at", gorm.Expr("NOW()"))
 	return res.Error
 }
+
+// SetConversationArchived 归档/取消归档会话（仅影响自己）
+func (s *ConversationService) SetConversationArchived(userID, roomID uint64, archived bool) error {
+	return s.updateConversationFlag(userID, roomID, "is_archived", archived)
+}
+
+// SetConversationMuted 会话免打扰开关（仅影响自己，与归档互相独立）
+func (s *ConversationService) SetConversationMuted(userID, roomID uint64, muted bool) error {
+	return s.updateConversationFlag(userID, roomID, "is_muted", muted)
+}
+
+func (s *ConversationService) updateConversationFlag(userID, roomID uint64, column string, value bool) error {
+	var conv models.Conversation
+	err := s.DB.Select("id").Where("user_id = ? AND room_id = ?", userID, roomID).First(&conv).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrConversationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.DB.Model(&models.Conversation{}).Where("id = ?", conv.ID).
+		Updates(map[string]any{column: value, "updated_at": time.Now()}).Error
+}
+
+// TotalUnread 消息总未读数：可见且未归档的会话未读之和（与会话列表一致，内存中更新的已读游标优先）
+func (s *ConversationService) TotalUnread(userID uint64) (uint64, error) {
+	var rows []struct {
+		RoomID        uint64
+		UnreadCount   uint64
+		LastMessageID *uint64
+	}
+	err := s.DB.Table(models.Conversation{}.TableName()+" AS c").
+		Select("c.room_id, c.unread_count, r.last_message_id").
+		Joins("JOIN "+models.Room{}.TableName()+" AS r ON r.id = c.room_id AND r.deleted_at IS NULL").
+		Where("c.user_id = ? AND c.is_visible = ? AND c.is_archived = ? AND c.unread_count > 0", userID, true, false).
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	var sessionReads map[uint64]uint64
+	if s.SessionReadGetter != nil {
+		sessionReads = s.SessionReadGetter(userID)
+	}
+	var total uint64
+	for _, r := range rows {
+		if r.LastMessageID != nil {
+			if lastRead, ok := sessionReads[r.RoomID]; ok && lastRead >= *r.LastMessageID {
+				continue
+			}
+		}
+		total += r.UnreadCount
+	}
+	return total, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConversationService_GetConversationList(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	base := &Service{DB: gormDB}
+	base.SessionReadGetter = func(userID uint64) map[uint64]uint64 {
+		return map[uint64]uint64{2: 200} // 群 2 已在内存中读到最新
+	}
+	svc := NewConversationService(base)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT c.id AS conversation_id.* FROM im_conversation AS c JOIN im_room AS r .* WHERE c.user_id = \\? AND c.is_visible = \\? AND c.is_archived = \\? ORDER BY c.updated_at DESC").
+		WithArgs(1, true, false).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"conversation_id", "room_id", "unread_count", "updated_at", "room_type", "room_account", "room_name", "room_avatar",
+			"last_message_id", "group_nickname", "other_id", "other_username", "other_nickname", "other_avatar", "friend_remark",
+		}).
+			AddRow(11, 1, 3, now, 1, "", "", "", 100, "", 9, "bob", "Bobby", "b.png", "老王").
+			AddRow(12, 2, 5, now, 2, "g2", "群二", "g.png", 200, "我在群里", nil, nil, nil, nil, nil).
+			AddRow(13, 3, 0, now, 1, "", "", "", nil, "", nil, nil, nil, nil, nil))
+	mock.ExpectQuery("SELECT .* FROM `im_message` LEFT JOIN `im_user` `Sender`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "content", "Sender__id", "Sender__nickname"}).
+			AddRow(100, 1, 9, "hi", 9, "Bobby").
+			AddRow(200, 2, 1, "yo", 1, "me"))
+	mock.ExpectQuery("SELECT room_id, tag FROM `im_conversation_tag` WHERE user_id = \\? AND room_id IN \\(\\?,\\?,\\?\\) ORDER BY tag ASC").
+		WithArgs(1, 1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "tag"}).AddRow(2, "工作"))
+
+	list, err := svc.GetConversationList(1)
+	if err != nil {
+		t.Fatalf("GetConversationList: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("len=%d", len(list))
+	}
+	if p := list[0]; p.Name != "老王" || p.UserID != 9 || p.UnreadCount != 3 || p.LastMessage == nil || p.LastMessage.ID != 100 {
+		t.Fatalf("private item=%+v", p)
+	}
+	if g := list[1]; g.Name != "我在群里" || len(g.Tags) != 1 || g.UnreadCount != 0 || g.LastMessage == nil || g.LastMessage.ID != 200 {
+		t.Fatalf("group item=%+v", g)
+	}
+	if u := list[2]; u.Name != "未知用户" || u.LastMessage != nil {
+		t.Fatalf("unknown item=%+v", u)
+	}
+}
+
+func TestConversationService_ArchiveAndTotalUnread(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	base := &Service{DB: gormDB}
+	base.SessionReadGetter = func(userID uint64) map[uint64]uint64 {
+		return map[uint64]uint64{2: 200}
+	}
+	svc := NewConversationService(base)
+
+	mock.ExpectQuery("SELECT `id` FROM `im_conversation` WHERE user_id = \\? AND room_id = \\?").
+		WithArgs(1, 9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if err := svc.SetConversationArchived(1, 9, true); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("want ErrConversationNotFound, got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT `id` FROM `im_conversation` WHERE user_id = \\? AND room_id = \\?").
+		WithArgs(1, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectExec("UPDATE `im_conversation` SET `is_archived`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(true, sqlmock.AnyArg(), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := svc.SetConversationArchived(1, 1, true); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	// 群 2 已在内存中读到最新，不计入
+	mock.ExpectQuery("SELECT c.room_id, c.unread_count, r.last_message_id FROM im_conversation AS c JOIN im_room AS r .* WHERE c.user_id = \\? AND c.is_visible = \\? AND c.is_archived = \\? AND c.unread_count > 0").
+		WithArgs(1, true, false).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "unread_count", "last_message_id"}).
+			AddRow(3, 4, 300).
+			AddRow(2, 5, 200))
+	n, err := svc.TotalUnread(1)
+	if err != nil || n != 4 {
+		t.Fatalf("TotalUnread: %d %v", n, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 会话设置字段长度上限（按字符计）
+const (
+	convBackgroundMaxLen = 500
+	convAliasMaxLen      = 50
+)
+
+// ConversationSettingsDTO 会话的客户端设置，未设置过时各字段为空、version 为 0
+type ConversationSettingsDTO struct {
+	RoomID     uint64    `json:"room_id"`
+	Background string    `json:"background"`
+	Alias      string    `json:"alias"`
+	Version    uint64    `json:"version"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UpdateConversationSettingsReq 修改会话设置（只更新传入的字段，传空串表示清除）
+type UpdateConversationSettingsReq struct {
+	RoomID     uint64  `json:"room_id" binding:"required" example:"1"`
+	Background *string `json:"background" example:"https://cdn.example.com/bg/1.jpg"`
+	Alias      *string `json:"alias" example:"老王"`
+	// BaseVersion 客户端当前持有的版本，与服务端不一致时返回冲突；不传则直接覆盖传入的字段
+	BaseVersion *uint64 `json:"base_version" example:"2"`
+}
+
+func toConversationSettingsDTO(cs *models.ConversationSetting) ConversationSettingsDTO {
+	return ConversationSettingsDTO{RoomID: cs.RoomID, Background: cs.Background, Alias: cs.Alias, Version: cs.Version, UpdatedAt: cs.UpdatedAt}
+}
+
+// GetConversationSettings 获取会话设置，roomIDs 为空时返回全部设置过的会话
+func (s *ConversationService) GetConversationSettings(userID uint64, roomIDs ...uint64) ([]ConversationSettingsDTO, error) {
+	q := s.DB.Where("user_id = ?", userID)
+	if len(roomIDs) > 0 {
+		q = q.Where("room_id IN ?", roomIDs)
+	}
+	var rows []models.ConversationSetting
+	if err := q.Order("room_id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]ConversationSettingsDTO, 0, len(rows))
+	for i := range rows {
+		out = append(out, toConversationSettingsDTO(&rows[i]))
+	}
+	return out, nil
+}
+
+// UpdateConversationSettings 修改自己在某个会话的背景/别名（需存在该会话），版本号加 1 并推送 conversation_settings 给本人所有设备。
+// 与自定义设置（PatchPrefs）相同，按版本号条件更新。
+func (s *ConversationService) UpdateConversationSettings(userID uint64, req UpdateConversationSettingsReq) (*ConversationSettingsDTO, error) {
+	if req.Background != nil {
+		*req.Background = strings.TrimSpace(*req.Background)
+		if utf8.RuneCountInString(*req.Background) > convBackgroundMaxLen {
+			return nil, newError(response.CodeParamError, "err.conv_setting_too_long", "background", convBackgroundMaxLen)
+		}
+	}
+	if req.Alias != nil {
+		*req.Alias = strings.TrimSpace(*req.Alias)
+		if utf8.RuneCountInString(*req.Alias) > convAliasMaxLen {
+			return nil, newError(response.CodeParamError, "err.conv_setting_too_long", "alias", convAliasMaxLen)
+		}
+	}
+	var n int64
+	if err := s.DB.Model(&models.Conversation{}).Where("user_id = ? AND room_id = ?", userID, req.RoomID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrConversationNotFound
+	}
+
+	for attempt := 0; attempt < prefsWriteAttempts; attempt++ {
+		var row models.ConversationSetting
+		err := s.DB.Where("user_id = ? AND room_id = ?", userID, req.RoomID).First(&row).Error
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if req.BaseVersion != nil && *req.BaseVersion != row.Version {
+			return nil, ErrPrefsVersionConflict
+		}
+		if req.Background != nil {
+			row.Background = *req.Background
+		}
+		if req.Alias != nil {
+			row.Alias = *req.Alias
+		}
+
+		now := time.Now()
+		if exists {
+			res := s.DB.Model(&models.ConversationSetting{}).
+				Where("id = ? AND version = ?", row.ID, row.Version).
+				Updates(map[string]any{"background": row.Background, "alias": row.Alias, "version": row.Version + 1, "updated_at": now})
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue // 被其他设备抢先修改
+			}
+			row.Version, row.UpdatedAt = row.Version+1, now
+		} else {
+			row = models.ConversationSetting{UserID: userID, RoomID: req.RoomID, Background: row.Background, Alias: row.Alias, Version: 1, CreatedAt: now, UpdatedAt: now}
+			res := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue // 其他设备同时创建
+			}
+		}
+
+		dto := toConversationSettingsDTO(&row)
+		s.Emit([]uint64{userID}, &message.ConversationSettingsEvent{RoomID: dto.RoomID, Background: dto.Background, Alias: dto.Alias, Version: dto.Version})
+		return &dto, nil
+	}
+	return nil, ErrPrefsVersionConflict
+}
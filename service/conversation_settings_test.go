@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestConversationService_UpdateConversationSettings(t *testing.T) {
+	dsn := fmt.Sprintf("file:conv_settings_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Conversation{}, &models.ConversationSetting{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&models.Conversation{UserID: 1, RoomID: 10, IsVisible: true}).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	pushed := 0
+	s := &ConversationService{Service: &Service{DB: db, WsNotifier: func(uint64, []byte) { pushed++ }}}
+	str := func(v string) *string { return &v }
+
+	if _, err := s.UpdateConversationSettings(1, UpdateConversationSettingsReq{RoomID: 11, Alias: str("x")}); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("no conversation: %v", err)
+	}
+	if _, err := s.UpdateConversationSettings(1, UpdateConversationSettingsReq{RoomID: 10, Alias: str(strings.Repeat("名", convAliasMaxLen+1))}); err == nil {
+		t.Fatal("alias too long should fail")
+	}
+
+	cs, err := s.UpdateConversationSettings(1, UpdateConversationSettingsReq{RoomID: 10, Background: str(" bg/1 ")})
+	if err != nil || cs.Version != 1 || cs.Background != "bg/1" {
+		t.Fatalf("create: %+v %v", cs, err)
+	}
+	base := uint64(1)
+	cs, err = s.UpdateConversationSettings(1, UpdateConversationSettingsReq{RoomID: 10, Alias: str("老王"), BaseVersion: &base})
+	if err != nil || cs.Version != 2 || cs.Background != "bg/1" || cs.Alias != "老王" {
+		t.Fatalf("update keeps other fields: %+v %v", cs, err)
+	}
+	if _, err := s.UpdateConversationSettings(1, UpdateConversationSettingsReq{RoomID: 10, Alias: str(""), BaseVersion: &base}); !errors.Is(err, ErrPrefsVersionConflict) {
+		t.Fatalf("stale version: %v", err)
+	}
+
+	list, err := s.GetConversationSettings(1)
+	if err != nil || len(list) != 1 || list[0].Alias != "老王" {
+		t.Fatalf("get: %+v %v", list, err)
+	}
+	if list, _ := s.GetConversationSettings(1, 99); len(list) != 0 {
+		t.Fatalf("filter by room: %+v", list)
+	}
+	if pushed != 2 {
+		t.Fatalf("expected 2 pushes, got %d", pushed)
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// ConversationTagMaxLen 标签最长字符数
+	ConversationTagMaxLen = 20
+	// ConversationTagMaxPerRoom 单个会话最多的标签数
+	ConversationTagMaxPerRoom = 10
+	// ConversationTagMaxPerUser 每个用户最多使用的不同标签数
+	ConversationTagMaxPerUser = 50
+)
+
+var (
+	ErrConvTagInvalid  = newError(response.CodeParamError, "err.conv_tag_invalid", ConversationTagMaxLen)
+	ErrConvTagTooMany  = newError(response.CodeParamError, "err.conv_tag_too_many", ConversationTagMaxPerRoom, ConversationTagMaxPerUser)
+	ErrConvTagNotFound = newError(response.CodeParamError, "err.conv_tag_not_found")
+)
+
+// ConversationTagDTO 标签及打了该标签的会话数
+type ConversationTagDTO struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// SetConversationTagsReq 设置会话的标签（整体替换，传空数组表示清除）
+type SetConversationTagsReq struct {
+	RoomID uint64   `json:"room_id" binding:"required" example:"1"`
+	Tags   []string `json:"tags" example:"工作,重要"`
+}
+
+// RenameConversationTagReq 重命名标签（新名称已存在时合并）
+type RenameConversationTagReq struct {
+	Tag    string `json:"tag" binding:"required" example:"工作"`
+	NewTag string `json:"new_tag" binding:"required" example:"公司"`
+}
+
+// normalizeConvTag 去掉首尾空白并校验长度
+func normalizeConvTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || utf8.RuneCountInString(tag) > ConversationTagMaxLen {
+		return "", ErrConvTagInvalid
+	}
+	return tag, nil
+}
+
+// ListConversationTags 我用过的全部标签（按名称排序）及各自的会话数
+func (s *ConversationService) ListConversationTags(userID uint64) ([]ConversationTagDTO, error) {
+	out := make([]ConversationTagDTO, 0)
+	err := s.DB.Model(&models.ConversationTag{}).
+		Select("tag, COUNT(*) AS count").
+		Where("user_id = ?", userID).
+		Group("tag").Order("tag ASC").
+		Scan(&out).Error
+	return out, err
+}
+
+// SetConversationTags 整体替换某个会话的标签（需存在该会话），返回去重、排序后的标签
+func (s *ConversationService) SetConversationTags(userID uint64, req SetConversationTagsReq) ([]string, error) {
+	seen := make(map[string]struct{}, len(req.Tags))
+	tags := make([]string, 0, len(req.Tags))
+	for _, t := range req.Tags {
+		tag, err := normalizeConvTag(t)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	if len(tags) > ConversationTagMaxPerRoom {
+		return nil, ErrConvTagTooMany
+	}
+	sort.Strings(tags)
+
+	var n int64
+	if err := s.DB.Model(&models.Conversation{}).Where("user_id = ? AND room_id = ?", userID, req.RoomID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrConversationNotFound
+	}
+
+	// 新标签计入用户的不同标签数上限（本会话独有、将被移除的标签也计入，略偏保守）
+	if len(tags) > 0 {
+		var used []string
+		if err := s.DB.Model(&models.ConversationTag{}).Where("user_id = ?", userID).Distinct().Pluck("tag", &used).Error; err != nil {
+			return nil, err
+		}
+		total := len(used)
+		for _, tag := range tags {
+			if !slices.Contains(used, tag) {
+				total++
+			}
+		}
+		if total > ConversationTagMaxPerUser {
+			return nil, ErrConvTagTooMany
+		}
+	}
+
+	err := s.Tx(func(tx *gorm.DB) error {
+		del := tx.Where("user_id = ? AND room_id = ?", userID, req.RoomID)
+		if len(tags) > 0 {
+			del = del.Where("tag NOT IN ?", tags)
+		}
+		if err := del.Delete(&models.ConversationTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		now := time.Now()
+		rows := make([]models.ConversationTag, 0, len(tags))
+		for _, tag := range tags {
+			rows = append(rows, models.ConversationTag{UserID: userID, RoomID: req.RoomID, Tag: tag, CreatedAt: now})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// RenameConversationTag 重命名标签；已经有新标签的会话直接去掉旧标签（合并）
+func (s *ConversationService) RenameConversationTag(userID uint64, req RenameConversationTagReq) error {
+	from, err := normalizeConvTag(req.Tag)
+	if err != nil {
+		return err
+	}
+	to, err := normalizeConvTag(req.NewTag)
+	if err != nil {
+		return err
+	}
+	return s.Tx(func(tx *gorm.DB) error {
+		var roomIDs []uint64
+		if err := tx.Model(&models.ConversationTag{}).Where("user_id = ? AND tag = ?", userID, from).Pluck("room_id", &roomIDs).Error; err != nil {
+			return err
+		}
+		if len(roomIDs) == 0 {
+			return ErrConvTagNotFound
+		}
+		if from == to {
+			return nil
+		}
+		var merged []uint64
+		if err := tx.Model(&models.ConversationTag{}).Where("user_id = ? AND tag = ? AND room_id IN ?", userID, to, roomIDs).Pluck("room_id", &merged).Error; err != nil {
+			return err
+		}
+		if len(merged) > 0 {
+			if err := tx.Where("user_id = ? AND tag = ? AND room_id IN ?", userID, from, merged).Delete(&models.ConversationTag{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.ConversationTag{}).Where("user_id = ? AND tag = ?", userID, from).Update("tag", to).Error
+	})
+}
+
+// DeleteConversationTag 删除标签（从所有会话上移除），返回受影响的会话数
+func (s *ConversationService) DeleteConversationTag(userID uint64, tag string) (int64, error) {
+	tag, err := normalizeConvTag(tag)
+	if err != nil {
+		return 0, err
+	}
+	res := s.DB.Where("user_id = ? AND tag = ?", userID, tag).Delete(&models.ConversationTag{})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, ErrConvTagNotFound
+	}
+	return res.RowsAffected, nil
+}
+
+// conversationTagsByRoom 批量查询会话的标签：room_id -> 标签（按名称排序）
+func conversationTagsByRoom(db *gorm.DB, userID uint64, roomIDs []uint64) (map[uint64][]string, error) {
+	var rows []models.ConversationTag
+	if err := db.Select("room_id, tag").Where("user_id = ? AND room_id IN ?", userID, roomIDs).Order("tag ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint64][]string, len(rows))
+	for _, r := range rows {
+		out[r.RoomID] = append(out[r.RoomID], r.Tag)
+	}
+	return out, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestConversationService_Tags(t *testing.T) {
+	db := newConversationListDB(t)
+	for roomID := uint64(1); roomID <= 3; roomID++ {
+		if err := db.Create(&models.Conversation{UserID: 1, RoomID: roomID, IsVisible: true}).Error; err != nil {
+			t.Fatalf("create conversation: %v", err)
+		}
+	}
+	s := NewConversationService(&Service{DB: db})
+
+	if _, err := s.SetConversationTags(1, SetConversationTagsReq{RoomID: 9, Tags: []string{"工作"}}); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("missing conversation: %v", err)
+	}
+	if _, err := s.SetConversationTags(1, SetConversationTagsReq{RoomID: 1, Tags: []string{" "}}); !errors.Is(err, ErrConvTagInvalid) {
+		t.Fatalf("empty tag: %v", err)
+	}
+	tags, err := s.SetConversationTags(1, SetConversationTagsReq{RoomID: 1, Tags: []string{"重要", " 工作 ", "重要"}})
+	if err != nil || !slices.Equal(tags, []string{"工作", "重要"}) {
+		t.Fatalf("set: %v %v", tags, err)
+	}
+	if _, err := s.SetConversationTags(1, SetConversationTagsReq{RoomID: 2, Tags: []string{"工作"}}); err != nil {
+		t.Fatalf("set room 2: %v", err)
+	}
+	if _, err := s.SetConversationTags(1, SetConversationTagsReq{RoomID: 3, Tags: []string{"家人"}}); err != nil {
+		t.Fatalf("set room 3: %v", err)
+	}
+
+	list, err := s.GetConversationList(1, "工作")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("filter: %+v %v", list, err)
+	}
+	for _, item := range list {
+		if item.RoomID == 1 && !slices.Equal(item.Tags, []string{"工作", "重要"}) {
+			t.Fatalf("room 1 tags: %v", item.Tags)
+		}
+	}
+
+	// 家人 并入 工作：房间 3 改名，房间 1/2 不重复
+	if err := s.RenameConversationTag(1, RenameConversationTagReq{Tag: "家人", NewTag: "工作"}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := s.RenameConversationTag(1, RenameConversationTagReq{Tag: "家人", NewTag: "x"}); !errors.Is(err, ErrConvTagNotFound) {
+		t.Fatalf("rename missing: %v", err)
+	}
+	stats, err := s.ListConversationTags(1)
+	if err != nil || len(stats) != 2 || stats[0].Tag != "工作" || stats[0].Count != 3 {
+		t.Fatalf("tags: %+v %v", stats, err)
+	}
+
+	if n, err := s.DeleteConversationTag(1, "工作"); err != nil || n != 3 {
+		t.Fatalf("delete: %d %v", n, err)
+	}
+	if list, _ := s.GetConversationList(1, "工作"); len(list) != 0 {
+		t.Fatalf("deleted tag still matches: %+v", list)
+	}
+	if _, err := s.SetConversationTags(1, SetConversationTagsReq{RoomID: 1}); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if stats, _ := s.ListConversationTags(1); len(stats) != 0 {
+		t.Fatalf("after clear: %+v", stats)
+	}
+}
@@ -0,0 +1,21 @@
+package service
+
+import "gorm.io/gorm"
+
+// 读场景：配置了只读副本时，这些场景的查询走副本，可用 WithPrimaryReads 逐个改回主库
+const (
+	ReadScopeConversation = "conversation" // 会话列表
+	ReadScopeMessage      = "message"      // 历史消息、会话内搜索、定位
+	ReadScopeSearch       = "search"       // 用户 / 群成员 / 公开群搜索
+	ReadScopeMoment       = "moment"       // 朋友圈列表
+)
+
+// ReadDB scope 场景的读连接：配置了副本且该场景未强制读主库时轮询副本，否则返回主库。
+// 副本有复制延迟，写入后需要立即读回的路径（如发送消息、事务内的查询）应直接使用 DB。
+func (s *Service) ReadDB(scope string) *gorm.DB {
+	if len(s.Replicas) == 0 || s.PrimaryReads[scope] {
+		return s.DB
+	}
+	i := s.replicaSeq.Add(1) - 1
+	return s.Replicas[i%uint64(len(s.Replicas))]
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/gorm"
+)
+
+func TestService_ReadDB(t *testing.T) {
+	primary, primaryMock, primarySQL := newMockDB(t)
+	defer primarySQL.Close()
+	r1, _, r1SQL := newMockDB(t)
+	defer r1SQL.Close()
+	r2, r2Mock, r2SQL := newMockDB(t)
+	defer r2SQL.Close()
+
+	s := &Service{DB: primary}
+	if s.ReadDB(ReadScopeMessage) != primary {
+		t.Fatalf("no replicas should read primary")
+	}
+
+	s = &Service{DB: primary, Replicas: []*gorm.DB{r1, r2}, PrimaryReads: map[string]bool{ReadScopeConversation: true}}
+	if a, b := s.ReadDB(ReadScopeMessage), s.ReadDB(ReadScopeMessage); a == b || (a != r1 && a != r2) || (b != r1 && b != r2) {
+		t.Fatalf("replicas should be used round-robin")
+	}
+	if s.ReadDB(ReadScopeConversation) != primary {
+		t.Fatalf("primary read override ignored")
+	}
+
+	// 历史消息走副本，主库不应收到查询
+	svc := NewMessageService(s)
+	if s.ReadDB(ReadScopeMessage) != r1 {
+		t.Fatalf("expected next replica to be r1")
+	}
+	r2Mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if _, err := svc.GetRoomMessagesDTO(1, 2, 20, 0); err != nil {
+		t.Fatalf("GetRoomMessagesDTO: %v", err)
+	}
+	if err := r2Mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("replica: %v", err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("primary: %v", err)
+	}
+}
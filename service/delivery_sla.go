@@ -0,0 +1,180 @@
+package service
+
+import (
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSlowPersist 落库耗时超过该值记为慢消息
+	DefaultSlowPersist = 300 * time.Millisecond
+	// DefaultSlowFanout 扇出（查成员 + 写各连接发送队列）耗时超过该值记为慢消息
+	DefaultSlowFanout = 300 * time.Millisecond
+
+	// deliverySLAWindow 参与分位数计算的最近消息数
+	deliverySLAWindow = 2048
+	// deliverySlowLogSize 保留的最近慢消息条数
+	deliverySlowLogSize = 100
+)
+
+// DeliveryTimings 一条消息在本实例上的投递时间点
+type DeliveryTimings struct {
+	MessageID  uint64
+	RoomID     uint64
+	SenderID   uint64
+	Recipients int       // 扇出的房间成员数
+	Received   time.Time // 从 WS 读到
+	Persisted  time.Time // 落库完成
+	FannedOut  time.Time // 已写入各接收方连接的发送队列
+}
+
+// DeliverySLATracker 消息投递耗时统计（仅本实例、仅内存）：
+// 最近 deliverySLAWindow 条消息的落库/扇出/总耗时分位数，以及超过阈值的慢消息日志。
+type DeliverySLATracker struct {
+	SlowPersist time.Duration
+	SlowFanout  time.Duration
+
+	mu      sync.Mutex
+	samples [deliverySLAWindow]deliverySample
+	next    int
+	count   int
+	total   uint64 // 启动以来统计的消息数
+	slowN   uint64 // 启动以来的慢消息数
+	slow    []SlowMessageDTO
+}
+
+type deliverySample struct {
+	persist, fanout time.Duration
+}
+
+// NewDeliverySLATracker 阈值 <=0 时使用默认值
+func NewDeliverySLATracker(slowPersist, slowFanout time.Duration) *DeliverySLATracker {
+	log.Println("NewDeliverySLATracker")
+	if slowPersist <= 0 {
+		slowPersist = DefaultSlowPersist
+	}
+	if slowFanout <= 0 {
+		slowFanout = DefaultSlowFanout
+	}
+	return &DeliverySLATracker{SlowPersist: slowPersist, SlowFanout: slowFanout}
+}
+
+// SlowMessageDTO 慢消息记录
+type SlowMessageDTO struct {
+	MessageID  uint64    `json:"message_id"`
+	RoomID     uint64    `json:"room_id"`
+	SenderID   uint64    `json:"sender_id"`
+	Recipients int       `json:"recipients"`
+	PersistMs  float64   `json:"persist_ms"`
+	FanoutMs   float64   `json:"fanout_ms"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// LatencyPercentiles 耗时分位数（毫秒）
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// DeliverySLAStats 投递耗时统计快照
+type DeliverySLAStats struct {
+	Samples       int                `json:"samples"`  // 参与分位数计算的最近消息数
+	Observed      uint64             `json:"observed"` // 启动以来统计的消息数
+	SlowCount     uint64             `json:"slow_count"`
+	SlowPersistMs float64            `json:"slow_persist_ms"` // 当前阈值
+	SlowFanoutMs  float64            `json:"slow_fanout_ms"`
+	Persist       LatencyPercentiles `json:"persist"`     // 收到 -> 落库
+	Fanout        LatencyPercentiles `json:"fanout"`      // 落库 -> 写入发送队列
+	Total         LatencyPercentiles `json:"total"`       // 收到 -> 写入发送队列
+	RecentSlow    []SlowMessageDTO   `json:"recent_slow"` // 最近的慢消息，新的在前
+	GeneratedAt   int64              `json:"generated_at"`
+}
+
+// Observe 记录一条消息的投递耗时，落库或扇出超过阈值时打印慢消息日志。nil 时什么也不做
+func (t *DeliverySLATracker) Observe(d DeliveryTimings) {
+	if t == nil || d.Received.IsZero() || d.Persisted.IsZero() || d.FannedOut.IsZero() {
+		return
+	}
+	sample := deliverySample{persist: d.Persisted.Sub(d.Received), fanout: d.FannedOut.Sub(d.Persisted)}
+	isSlow := sample.persist > t.SlowPersist || sample.fanout > t.SlowFanout
+
+	t.mu.Lock()
+	t.samples[t.next] = sample
+	t.next = (t.next + 1) % deliverySLAWindow
+	t.count = min(t.count+1, deliverySLAWindow)
+	t.total++
+	if isSlow {
+		t.slowN++
+		t.slow = append(t.slow, SlowMessageDTO{
+			MessageID:  d.MessageID,
+			RoomID:     d.RoomID,
+			SenderID:   d.SenderID,
+			Recipients: d.Recipients,
+			PersistMs:  durationMs(sample.persist),
+			FanoutMs:   durationMs(sample.fanout),
+			ReceivedAt: d.Received,
+		})
+		if len(t.slow) > deliverySlowLogSize {
+			t.slow = t.slow[len(t.slow)-deliverySlowLogSize:]
+		}
+	}
+	t.mu.Unlock()
+
+	if isSlow {
+		log.Printf("slow message: id=%d room=%d sender=%d recipients=%d persist=%s fanout=%s",
+			d.MessageID, d.RoomID, d.SenderID, d.Recipients, sample.persist, sample.fanout)
+	}
+}
+
+// Snapshot 当前统计
+func (t *DeliverySLATracker) Snapshot() *DeliverySLAStats {
+	t.mu.Lock()
+	samples := make([]deliverySample, t.count)
+	copy(samples, t.samples[:t.count])
+	stats := &DeliverySLAStats{
+		Samples:       t.count,
+		Observed:      t.total,
+		SlowCount:     t.slowN,
+		SlowPersistMs: durationMs(t.SlowPersist),
+		SlowFanoutMs:  durationMs(t.SlowFanout),
+		RecentSlow:    make([]SlowMessageDTO, 0, len(t.slow)),
+		GeneratedAt:   time.Now().Unix(),
+	}
+	for i := len(t.slow) - 1; i >= 0; i-- {
+		stats.RecentSlow = append(stats.RecentSlow, t.slow[i])
+	}
+	t.mu.Unlock()
+
+	persist := make([]time.Duration, len(samples))
+	fanout := make([]time.Duration, len(samples))
+	total := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		persist[i], fanout[i], total[i] = s.persist, s.fanout, s.persist+s.fanout
+	}
+	stats.Persist = latencyPercentiles(persist)
+	stats.Fanout = latencyPercentiles(fanout)
+	stats.Total = latencyPercentiles(total)
+	return stats
+}
+
+// latencyPercentiles 最近邻法计算分位数（会原地排序 ds）
+func latencyPercentiles(ds []time.Duration) LatencyPercentiles {
+	if len(ds) == 0 {
+		return LatencyPercentiles{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	at := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(ds)))) - 1
+		return durationMs(ds[max(0, min(i, len(ds)-1))])
+	}
+	return LatencyPercentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: durationMs(ds[len(ds)-1])}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
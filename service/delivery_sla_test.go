@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeliverySLATracker_Percentiles(t *testing.T) {
+	tr := NewDeliverySLATracker(time.Second, time.Second)
+	base := time.Now()
+	for i := 1; i <= 100; i++ {
+		tr.Observe(DeliveryTimings{
+			MessageID: uint64(i),
+			Received:  base,
+			Persisted: base.Add(time.Duration(i) * time.Millisecond),
+			FannedOut: base.Add(time.Duration(i)*time.Millisecond + 2*time.Millisecond),
+		})
+	}
+	st := tr.Snapshot()
+	if st.Samples != 100 || st.Observed != 100 || st.SlowCount != 0 {
+		t.Fatalf("unexpected counters %+v", st)
+	}
+	if st.Persist.P50 != 50 || st.Persist.P90 != 90 || st.Persist.P99 != 99 || st.Persist.Max != 100 {
+		t.Fatalf("persist = %+v", st.Persist)
+	}
+	if st.Fanout.P99 != 2 || st.Total.Max != 102 {
+		t.Fatalf("fanout = %+v total = %+v", st.Fanout, st.Total)
+	}
+}
+
+func TestDeliverySLATracker_SlowLog(t *testing.T) {
+	tr := NewDeliverySLATracker(100*time.Millisecond, 50*time.Millisecond)
+	base := time.Now()
+	tr.Observe(DeliveryTimings{MessageID: 1, Received: base, Persisted: base.Add(10 * time.Millisecond), FannedOut: base.Add(20 * time.Millisecond)})
+	tr.Observe(DeliveryTimings{MessageID: 2, Received: base, Persisted: base.Add(200 * time.Millisecond), FannedOut: base.Add(210 * time.Millisecond)})
+	tr.Observe(DeliveryTimings{MessageID: 3, RoomID: 9, Recipients: 500, Received: base, Persisted: base.Add(10 * time.Millisecond), FannedOut: base.Add(90 * time.Millisecond)})
+	// 缺少时间点的不统计
+	tr.Observe(DeliveryTimings{MessageID: 4, Received: base})
+
+	st := tr.Snapshot()
+	if st.Observed != 3 || st.SlowCount != 2 || len(st.RecentSlow) != 2 {
+		t.Fatalf("unexpected counters %+v", st)
+	}
+	if s := st.RecentSlow[0]; s.MessageID != 3 || s.Recipients != 500 || s.FanoutMs != 80 {
+		t.Fatalf("newest slow = %+v", s)
+	}
+	if st.RecentSlow[1].MessageID != 2 || st.RecentSlow[1].PersistMs != 200 {
+		t.Fatalf("older slow = %+v", st.RecentSlow[1])
+	}
+}
+
+func TestDeliverySLATracker_Window(t *testing.T) {
+	tr := NewDeliverySLATracker(0, 0)
+	base := time.Now()
+	for i := 0; i < deliverySLAWindow+10; i++ {
+		tr.Observe(DeliveryTimings{Received: base, Persisted: base, FannedOut: base})
+	}
+	st := tr.Snapshot()
+	if st.Samples != deliverySLAWindow || st.Observed != uint64(deliverySLAWindow+10) {
+		t.Fatalf("samples = %d observed = %d", st.Samples, st.Observed)
+	}
+	var nilTracker *DeliverySLATracker
+	nilTracker.Observe(DeliveryTimings{Received: base, Persisted: base, FannedOut: base})
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+)
+
+// ErrDisappearingTTL 时长不在 DisappearingTTLOptions 内
+var ErrDisappearingTTL = newError(response.CodeParamError, "err.disappearing_ttl")
+
+// DisappearingTTLOptions 可选的消息定时删除时长（0 表示关闭，始终允许）
+var DisappearingTTLOptions = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	90 * 24 * time.Hour,
+}
+
+// disappearingPurgeBatch 每批软删除的消息数
+const disappearingPurgeBatch = 500
+
+// SetDisappearingTTL 设置房间消息定时删除时长（ttl=0 关闭）。
+// 私聊任一成员可设置，群聊需管理员；只对开启后发送的消息生效，修改时长不影响开启时间。
+func (s *RoomService) SetDisappearingTTL(operatorID, roomID uint64, ttl time.Duration) error {
+	if ttl < 0 || (ttl > 0 && !slices.Contains(DisappearingTTLOptions, ttl)) {
+		return ErrDisappearingTTL
+	}
+	var room models.Room
+	if err := s.DB.Select("id, type, disappearing_seconds").First(&room, roomID).Error; err != nil {
+		return err
+	}
+	role, err := s.getMemberRole(roomID, operatorID)
+	if err != nil {
+		return ErrPermissionDenied
+	}
+	if room.Type == 2 && role < 1 {
+		return ErrPermissionDenied
+	}
+
+	secs := int64(ttl / time.Second)
+	if secs == room.DisappearingSeconds {
+		return nil
+	}
+	updates := map[string]any{"disappearing_seconds": secs, "updated_at": time.Now()}
+	switch {
+	case secs == 0:
+		updates["disappearing_since"] = nil
+	case room.DisappearingSeconds == 0:
+		updates["disappearing_since"] = time.Now()
+	}
+	if err := s.DB.Model(&models.Room{}).Where("id = ?", roomID).Updates(updates).Error; err != nil {
+		return err
+	}
+	s.auditRoom(roomID, operatorID, EventRoomDisappearing, 0,
+		map[string]any{"disappearing_seconds": room.DisappearingSeconds},
+		map[string]any{"disappearing_seconds": secs})
+
+	if s.Notify != nil {
+		members, _ := s.GetRoomMembers(roomID)
+		_, _ = s.Notify.PublishRoomEvent(roomID, operatorID, EventRoomDisappearing, map[string]any{"ttl_seconds": secs}, members, true)
+	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:   EventRoomDisappearing,
+		ActorID: operatorID,
+		Params:  map[string]string{"ttl_seconds": strconv.FormatInt(secs, 10)},
+	})
+	return nil
+}
+
+// PurgeDisappearingMessages 软删除各房间已到期的消息（所有成员不可见），返回删除条数。
+// 按房间、分批执行，单批失败直接返回，已删除的部分不回滚。
+func (s *MessageService) PurgeDisappearingMessages(now time.Time) (int64, error) {
+	var rooms []models.Room
+	if err := s.DB.Select("id, disappearing_seconds, disappearing_since").
+		Where("disappearing_seconds > 0").
+		Find(&rooms).Error; err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, room := range rooms {
+		if room.DisappearingSince == nil {
+			continue
+		}
+		before := now.Add(-time.Duration(room.DisappearingSeconds) * time.Second)
+		for {
+			var ids []uint64
+			if err := s.DB.Model(&models.Message{}).
+				Where("room_id = ? AND created_at >= ? AND created_at < ?", room.ID, *room.DisappearingSince, before).
+				Order("id ASC").
+				Limit(disappearingPurgeBatch).
+				Pluck("id", &ids).Error; err != nil {
+				return total, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			if err := s.Archive.recordIDs(s.DB, ArchiveEventExpired, 0, ids); err != nil {
+				return total, err
+			}
+			res := s.DB.Where("id IN ?", ids).Delete(&models.Message{})
+			if res.Error != nil {
+				return total, res.Error
+			}
+			total += res.RowsAffected
+			if len(ids) < disappearingPurgeBatch {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// RunDisappearingLoop 定时删除到期的定时消息（阻塞，engine 中以 goroutine 启动）
+func (s *MessageService) RunDisappearingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.PurgeDisappearingMessages(time.Now())
+		if err != nil {
+			log.Printf("disappearing loop: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("disappearing: deleted %d messages", n)
+		}
+	}
+}
+
+// formatTTL 时长的中文描述（整天按天，其次小时、分钟）
+func formatTTL(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d天", d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d小时", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d分钟", d/time.Minute)
+	}
+}
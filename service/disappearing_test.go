@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPurgeDisappearingMessages(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &MessageService{Service: &Service{DB: db}}
+	now := time.Now()
+	since := now.Add(-48 * time.Hour)
+
+	mock.ExpectQuery("SELECT id, disappearing_seconds, disappearing_since FROM `im_room` WHERE disappearing_seconds > 0").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "disappearing_seconds", "disappearing_since"}).
+			AddRow(5, 86400, since).
+			AddRow(6, 86400, nil))
+	// 只删除开启之后、超过 TTL 的消息（软删除）
+	mock.ExpectQuery("SELECT `id` FROM `im_message` WHERE \\(room_id = \\? AND created_at >= \\? AND created_at < \\?\\) AND `im_message`.`deleted_at` IS NULL ORDER BY id ASC LIMIT \\?").
+		WithArgs(5, since, now.Add(-24*time.Hour), disappearingPurgeBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+	mock.ExpectExec("UPDATE `im_message` SET `deleted_at`=\\? WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(sqlmock.AnyArg(), 10, 11).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := s.PurgeDisappearingMessages(now)
+	if err != nil {
+		t.Fatalf("PurgeDisappearingMessages: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("deleted = %d, want 2", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoomService_SetDisappearingTTL(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := NewRoomService(&Service{DB: db, TablePrefix: "im_"})
+
+	if err := s.SetDisappearingTTL(1, 5, time.Hour); !errors.Is(err, ErrDisappearingTTL) {
+		t.Fatalf("want ErrDisappearingTTL, got %v", err)
+	}
+
+	// 群聊普通成员无权设置
+	mock.ExpectQuery("SELECT id, type, disappearing_seconds FROM `im_room`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "disappearing_seconds"}).AddRow(5, 2, 0))
+	mock.ExpectQuery("SELECT .* FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(0))
+	if err := s.SetDisappearingTTL(1, 5, 24*time.Hour); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("want ErrPermissionDenied, got %v", err)
+	}
+
+	// 时长未变：不更新
+	mock.ExpectQuery("SELECT id, type, disappearing_seconds FROM `im_room`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "disappearing_seconds"}).AddRow(5, 2, 86400))
+	mock.ExpectQuery("SELECT .* FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(1))
+	if err := s.SetDisappearingTTL(1, 5, 24*time.Hour); err != nil {
+		t.Fatalf("unchanged: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
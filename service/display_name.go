@@ -0,0 +1,166 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+const (
+	// displayNameCacheTTL 展示名缓存有效期（备注/群昵称/昵称修改时会主动失效）
+	displayNameCacheTTL = 30 * time.Second
+	// displayNameCacheMax 缓存条数上限，超过时先清过期项，仍超出则整体清空
+	displayNameCacheMax = 20000
+)
+
+// DisplayName 展示名优先级：好友备注 > 群昵称 > 用户昵称 > 用户名。
+// 已经 JOIN 出各字段的查询（会话列表、成员列表）直接用它组装，其余场景用 DisplayNameResolver 批量解析。
+func DisplayName(remark, groupNick, nickname, username string) string {
+	switch {
+	case remark != "":
+		return remark
+	case groupNick != "":
+		return groupNick
+	case nickname != "":
+		return nickname
+	default:
+		return username
+	}
+}
+
+// displayNameKey viewer 在 room 内看到的 user 的名字；roomID=0 表示不看群昵称，viewerID=0 表示不看好友备注
+type displayNameKey struct {
+	viewerID, roomID, userID uint64
+}
+
+type displayNameEntry struct {
+	name string
+	at   time.Time
+}
+
+// DisplayNameResolver 按 viewer / room 视角批量解析用户展示名（一条 JOIN 查询 + 进程内短 TTL 缓存）。
+// 多实例部署时各自缓存，靠 TTL 收敛；本实例内的备注、群昵称、昵称修改会立即失效。
+type DisplayNameResolver struct {
+	*Service
+
+	mu    sync.Mutex
+	items map[displayNameKey]displayNameEntry
+}
+
+func NewDisplayNameResolver(s *Service) *DisplayNameResolver {
+	log.Println("NewDisplayNameResolver")
+	return &DisplayNameResolver{Service: s}
+}
+
+// Resolve 返回 userIDs 在 viewer 视角、roomID 内的展示名（不存在的用户不在结果中）
+func (r *DisplayNameResolver) Resolve(viewerID, roomID uint64, userIDs []uint64) (map[uint64]string, error) {
+	now := time.Now()
+	out := make(map[uint64]string, len(userIDs))
+	miss := make([]uint64, 0, len(userIDs))
+	seen := make(map[uint64]struct{}, len(userIDs))
+	r.mu.Lock()
+	for _, id := range userIDs {
+		if id == 0 {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		if e, ok := r.items[displayNameKey{viewerID, roomID, id}]; ok && now.Sub(e.at) < displayNameCacheTTL {
+			out[id] = e.name
+			continue
+		}
+		miss = append(miss, id)
+	}
+	r.mu.Unlock()
+	if len(miss) == 0 {
+		return out, nil
+	}
+
+	// 已注销的用户也要在历史消息里显示名字，不过滤 deleted_at
+	var rows []struct {
+		ID        uint64
+		Username  string
+		Nickname  string
+		GroupNick string
+		Remark    string
+	}
+	if err := r.DB.Table(models.User{}.TableName()+" AS u").
+		Select("u.id, u.username, u.nickname, COALESCE(ru.nickname, '') AS group_nick, COALESCE(f.remark, '') AS remark").
+		Joins("LEFT JOIN "+models.RoomUser{}.TableName()+" AS ru ON ru.room_id = ? AND ru.user_id = u.id", roomID).
+		Joins("LEFT JOIN "+(&models.Friend{}).TableName()+" AS f ON f.user_id = ? AND f.friend_id = u.id AND f.status = ?", viewerID, 1).
+		Where("u.id IN ?", miss).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.items == nil || len(r.items)+len(rows) > displayNameCacheMax {
+		r.evict(now, len(rows))
+	}
+	for _, row := range rows {
+		name := DisplayName(row.Remark, row.GroupNick, row.Nickname, row.Username)
+		out[row.ID] = name
+		r.items[displayNameKey{viewerID, roomID, row.ID}] = displayNameEntry{name: name, at: now}
+	}
+	return out, nil
+}
+
+// Name 取单个用户的展示名，失败时返回空串（仅记日志），用于推送等尽力而为的场景
+func (r *DisplayNameResolver) Name(viewerID, roomID, userID uint64) string {
+	names, err := r.Resolve(viewerID, roomID, []uint64{userID})
+	if err != nil {
+		log.Printf("resolve display name %d: %v", userID, err)
+		return ""
+	}
+	return names[userID]
+}
+
+// evict 清理过期项（调用方持锁），仍放不下 incoming 条时整体清空
+func (r *DisplayNameResolver) evict(now time.Time, incoming int) {
+	if r.items == nil {
+		r.items = make(map[displayNameKey]displayNameEntry)
+		return
+	}
+	for k, e := range r.items {
+		if now.Sub(e.at) >= displayNameCacheTTL {
+			delete(r.items, k)
+		}
+	}
+	if len(r.items)+incoming > displayNameCacheMax {
+		r.items = make(map[displayNameKey]displayNameEntry)
+	}
+}
+
+// invalidate 删除满足条件的缓存项
+func (r *DisplayNameResolver) invalidate(match func(k displayNameKey) bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k := range r.items {
+		if match(k) {
+			delete(r.items, k)
+		}
+	}
+}
+
+// InvalidateUser 用户昵称变更
+func (r *DisplayNameResolver) InvalidateUser(userID uint64) {
+	r.invalidate(func(k displayNameKey) bool { return k.userID == userID })
+}
+
+// InvalidateRemark viewer 对 userID 的好友备注变更（含加/删好友）
+func (r *DisplayNameResolver) InvalidateRemark(viewerID, userID uint64) {
+	r.invalidate(func(k displayNameKey) bool { return k.viewerID == viewerID && k.userID == userID })
+}
+
+// InvalidateGroupNick userID 在 roomID 的群昵称变更
+func (r *DisplayNameResolver) InvalidateGroupNick(roomID, userID uint64) {
+	r.invalidate(func(k displayNameKey) bool { return k.roomID == roomID && k.userID == userID })
+}
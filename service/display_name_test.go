@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDisplayName(t *testing.T) {
+	cases := []struct {
+		remark, groupNick, nickname, username, want string
+	}{
+		{"老王", "群昵称", "昵称", "wang", "老王"},
+		{"", "群昵称", "昵称", "wang", "群昵称"},
+		{"", "", "昵称", "wang", "昵称"},
+		{"", "", "", "wang", "wang"},
+	}
+	for _, c := range cases {
+		if got := DisplayName(c.remark, c.groupNick, c.nickname, c.username); got != c.want {
+			t.Fatalf("DisplayName(%q,%q,%q,%q)=%q, want %q", c.remark, c.groupNick, c.nickname, c.username, got, c.want)
+		}
+	}
+}
+
+func TestDisplayNameResolver_Resolve(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	r := NewDisplayNameResolver(&Service{DB: db, TablePrefix: "im_"})
+
+	mock.ExpectQuery("SELECT u.id, u.username, u.nickname, COALESCE\\(ru.nickname, ''\\) AS group_nick, COALESCE\\(f.remark, ''\\) AS remark FROM im_user AS u "+
+		"LEFT JOIN im_room_user AS ru ON ru.room_id = \\? AND ru.user_id = u.id "+
+		"LEFT JOIN im_friend AS f ON f.user_id = \\? AND f.friend_id = u.id AND f.status = \\? WHERE u.id IN \\(\\?,\\?\\)").
+		WithArgs(5, 1, 1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "nickname", "group_nick", "remark"}).
+			AddRow(2, "bob", "Bob", "群里的 Bob", "老同学").
+			AddRow(3, "carol", "", "", ""))
+
+	names, err := r.Resolve(1, 5, []uint64{2, 3, 2, 0})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if names[2] != "老同学" || names[3] != "carol" || len(names) != 2 {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	// 命中缓存，不再查库
+	if name := r.Name(1, 5, 2); name != "老同学" {
+		t.Fatalf("cached name: %q", name)
+	}
+
+	// 备注变更后重新查询
+	r.InvalidateRemark(1, 2)
+	mock.ExpectQuery("SELECT u.id, .* WHERE u.id IN \\(\\?\\)").
+		WithArgs(5, 1, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "nickname", "group_nick", "remark"}).
+			AddRow(2, "bob", "Bob", "群里的 Bob", ""))
+	if name := r.Name(1, 5, 2); name != "群里的 Bob" {
+		t.Fatalf("after invalidate: %q", name)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/cydxin/chat-sdk/response"
+)
+
+// Error 带业务码的 service 错误：Code 对应 response.Code*，Key 为文案目录 key（见 response.Translate）。
+// handler 按请求语言渲染文案，可用 errors.Is / errors.As 判断（同 Key 视为同一错误）。
+type Error struct {
+	Code int
+	Key  string
+	Args []any
+}
+
+func newError(code int, key string, args ...any) *Error {
+	return &Error{Code: code, Key: key, Args: args}
+}
+
+// Error 返回 DefaultLang 下的文案
+func (e *Error) Error() string {
+	return e.Localize(response.DefaultLang)
+}
+
+// Localize 按语言渲染文案
+func (e *Error) Localize(lang string) string {
+	return response.Translate(lang, e.Key, e.Args...)
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Key == e.Key
+}
+
+// ErrorCode 返回 err 链上 *Error 的业务码，没有则返回 CodeInternalError
+func ErrorCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return response.CodeInternalError
+}
+
+// 参数/账号相关错误（注册、登录、找回密码、验证码）
+var (
+	ErrRedisNotConfigured  = newError(response.CodeRedisNotConfigured, "err.redis_not_configured")
+	ErrUsernameRequired    = newError(response.CodeParamError, "err.username_required")
+	ErrPasswordRequired    = newError(response.CodeParamError, "err.password_required")
+	ErrNicknameRequired    = newError(response.CodeParamError, "err.nickname_required")
+	ErrCodeRequired        = newError(response.CodeParamError, "err.code_required")
+	ErrPhoneOrEmail        = newError(response.CodeParamError, "err.phone_or_email")
+	ErrPhoneAndEmail       = newError(response.CodeParamError, "err.phone_and_email")
+	ErrAccountRequired     = newError(response.CodeParamError, "err.account_required")
+	ErrPasswordOrCode      = newError(response.CodeParamError, "err.password_or_code")
+	ErrPasswordAndCode     = newError(response.CodeParamError, "err.password_and_code")
+	ErrIdentifierRequired  = newError(response.CodeParamError, "err.identifier_required")
+	ErrPurposeRequired     = newError(response.CodeParamError, "err.purpose_required")
+	ErrNewPasswordRequired = newError(response.CodeParamError, "err.new_password_required")
+	ErrOldPasswordRequired = newError(response.CodeParamError, "err.old_password_required")
+	ErrOldPasswordWrong    = newError(response.CodePasswordError, "err.old_password_wrong")
+	ErrInvalidCredentials  = newError(response.CodePasswordError, "err.invalid_credentials")
+	ErrVerifyCodeInvalid   = newError(response.CodeVerifyCodeInvalid, "err.verify_code_invalid")
+	ErrUserNotFound        = newError(response.CodeUserNotFound, "err.user_not_found")
+	ErrUserExists          = newError(response.CodeUserAlreadyExists, "err.user_exists")
+)
+
+// 通用必填参数缺失（service 方法被直接调用时的兜底校验）
+var (
+	ErrUserIDRequired  = newError(response.CodeParamError, "err.user_id_required")
+	ErrRoomIDRequired  = newError(response.CodeParamError, "err.room_id_required")
+	ErrContentRequired = newError(response.CodeParamError, "err.content_required")
+)
+
+// ErrMessageNotFound 消息不存在（或已删除）
+var ErrMessageNotFound = newError(response.CodeParamError, "err.message_not_found")
+
+// ErrPermissionDenied 无权限（如普通成员改群资料），可 %w 包装补充原因
+var ErrPermissionDenied = newError(response.CodePermissionDeny, "err.permission_denied")
+
+// 发送消息的前置校验错误（WS 与 HTTP /message/send 共用）
+var (
+	ErrRoomNotFound   = newError(response.CodeParamError, "err.room_not_found")
+	ErrPrivateBlocked = newError(response.CodePermissionDeny, "err.private_blocked")
+	ErrHelpDeskClosed = newError(response.CodePermissionDeny, "err.helpdesk_closed")
+)
+
+// ErrWebhookURLInvalid Webhook 地址不合法（非 http/https、端口不允许或指向内网地址）
+var ErrWebhookURLInvalid = newError(response.CodeParamError, "err.webhook_url_invalid")
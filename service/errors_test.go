@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/response"
+)
+
+func TestError_CodeAndLocalize(t *testing.T) {
+	err := fmt.Errorf("%w：好友申请已达上限 %d", ErrRateLimited, 5)
+	if !errors.Is(err, ErrRateLimited) || errors.Is(err, ErrRoomFull) {
+		t.Fatalf("errors.Is mismatch: %v", err)
+	}
+	if got := ErrorCode(err); got != response.CodeRateLimited {
+		t.Fatalf("code=%d", got)
+	}
+	if got := ErrorCode(errors.New("db down")); got != response.CodeInternalError {
+		t.Fatalf("plain error code=%d", got)
+	}
+
+	exists := newError(response.CodeUserAlreadyExists, "err.phone_exists", "138")
+	if exists.Error() != "手机号已存在: 138" {
+		t.Fatalf("zh=%q", exists.Error())
+	}
+	if got := exists.Localize(response.LangEN); got != "Phone number already exists: 138" {
+		t.Fatalf("en=%q", got)
+	}
+	// 同 key 不同参数视为同一类错误
+	if !errors.Is(exists, newError(response.CodeUserAlreadyExists, "err.phone_exists", "139")) {
+		t.Fatalf("expected same key to match")
+	}
+	// 未知语言回退默认语言
+	if got := ErrPermissionDenied.Localize("fr"); got != "权限不足" {
+		t.Fatalf("fallback=%q", got)
+	}
+	if got := response.ParseAcceptLanguage("fr-FR,en-US;q=0.8,zh;q=0.5"); got != response.LangEN {
+		t.Fatalf("accept-language=%q", got)
+	}
+}
+
+// WS error 事件的错误码与 HTTP 业务码保持一致，发消息路径上的错误都有对应的 code
+func TestError_WsCodesMirrorResponse(t *testing.T) {
+	pairs := map[int]int{
+		message.WsErrInvalidPayload: response.CodeParamError,
+		message.WsErrForbidden:      response.CodePermissionDeny,
+		message.WsErrSuspended:      response.CodeAccountSuspended,
+		message.WsErrRateLimited:    response.CodeRateLimited,
+		message.WsErrServerBusy:     response.CodeServerBusy,
+		message.WsErrMuted:          response.CodeMuted,
+		message.WsErrInternal:       response.CodeInternalError,
+	}
+	for ws, code := range pairs {
+		if ws != code {
+			t.Fatalf("ws code %d != response code %d", ws, code)
+		}
+	}
+	for err, want := range map[error]int{
+		ErrRoomNotFound:   message.WsErrInvalidPayload,
+		ErrNotRoomMember:  message.WsErrForbidden,
+		ErrPrivateBlocked: message.WsErrForbidden,
+		ErrHelpDeskClosed: message.WsErrForbidden,
+		ErrRateLimited:    message.WsErrRateLimited,
+		newMuteError("user", time.Now().Add(time.Minute), time.Now()): message.WsErrMuted,
+	} {
+		if got := ErrorCode(err); got != want {
+			t.Fatalf("%v: code=%d want %d", err, got, want)
+		}
+	}
+
+	evt := message.NewErrorEvent(message.WsErrRateLimited, "", "slow down", "p1")
+	if evt.Reason != message.WsErrReasonRateLimited || !evt.Retryable {
+		t.Fatalf("rate limited event: %+v", evt)
+	}
+	if evt := message.NewErrorEvent(message.WsErrForbidden, message.WsErrReasonBlocked, "blocked", "p2"); evt.Retryable || evt.Reason != message.WsErrReasonBlocked {
+		t.Fatalf("blocked event: %+v", evt)
+	}
+	if got := message.WsErrorReason(12345); got != message.WsErrReasonInternal {
+		t.Fatalf("unknown code reason=%q", got)
+	}
+}
+
+// 业务错误的文案 key 需在中英文目录里都登记（缺英文时会回退成中文）
+func TestError_CatalogKeys(t *testing.T) {
+	errs := []*Error{
+		ErrUserIDRequired, ErrRoomIDRequired, ErrContentRequired, ErrWebhookURLInvalid,
+		ErrRedPacketType, ErrRedPacketCount, ErrRedPacketAmount, ErrRedPacketFixedAmount, ErrRedPacketNotFound,
+		ErrRedPacketOwn, ErrRedPacketClaimed, ErrRedPacketExpired, ErrRedPacketEmpty,
+		ErrPollQuestionRequired, ErrPollOptions, ErrPollChoiceRequired, ErrPollNotFound, ErrPollClosed,
+		ErrPollSingleChoice, ErrPollVoted, ErrPollOptionInvalid, ErrPollCloseDenied,
+		newError(response.CodeParamError, "err.poll_max_choices", 3),
+		ErrHelpDeskNotAgent, ErrHelpDeskSessionNotFound, ErrHelpDeskTransferDenied, ErrHelpDeskNoAgent,
+		ErrHelpDeskTransferSelf, ErrHelpDeskTargetNotAgent, ErrHelpDeskCloseDenied, ErrHelpDeskRating,
+		ErrHelpDeskRateDenied, ErrHelpDeskSessionNotClosed, ErrHelpDeskRated,
+		ErrBotNameRequired, ErrBotNotFound, ErrBotAPIKeyMissing, ErrBotAPIKeyInvalid, ErrBotDisabled, ErrBotNotRoomMember,
+		ErrAutoReplyPatternRequired, ErrAutoReplyReplyRequired, ErrAutoReplyMatchType, ErrAutoReplyTargetRequired,
+		ErrAutoReplyDenied, ErrAutoReplyRuleNotFound, newError(response.CodeParamError, "err.auto_reply_regex", "missing )"),
+		ErrCheckInGroupOnly, ErrGeoLatLng, ErrGeoLocationRequired, ErrQRCodeInvalid, ErrQRCodeExpired, ErrRoomStatsOwnerOnly,
+		ErrIPRuleCountry, ErrIPRuleKind, ErrIPRuleNotFound, newError(response.CodeParamError, "err.ip_rule_cidr_invalid", "bad"),
+		ErrDepartmentNameRequired, ErrDepartmentCycle, ErrChatSelf,
+		newError(response.CodeParamError, "err.provision_kind_invalid", "rooms"),
+		newError(response.CodeParamError, "err.provision_csv_column", "users", "username"),
+		newError(response.CodeParamError, "err.provision_csv_invalid", "users", 3, errors.New("bare quote")),
+		newError(response.CodeParamError, "err.provision_user_not_found", "zs"),
+		newError(response.CodeParamError, "err.provision_user_duplicate", "zs"),
+		newError(response.CodeParamError, "err.provision_friend_self", "zs"),
+		ErrProvisionRoomAccountRequired,
+		newError(response.CodeParamError, "err.provision_group_owner_required", "dept"),
+		newError(response.CodeParamError, "err.provision_not_group", "dept"),
+		newError(response.CodeParamError, "err.import_external_id", 2),
+		newError(response.CodeParamError, "err.import_external_id_duplicate", "m1"),
+		newError(response.CodeParamError, "err.import_sender_required", 2),
+		newError(response.CodeParamError, "err.import_sent_at_required", 2),
+		newError(response.CodeParamError, "err.import_sender_not_found", 9),
+		ErrSomeNotRoomMember,
+		ErrVoiceRequired, ErrVoiceDurationInvalid, ErrVoiceWaveformValue, ErrPrivateOrphaned,
+		newError(response.CodeParamError, "err.voice_too_long", 60),
+		newError(response.CodeParamError, "err.voice_waveform_too_long", 100),
+		newError(response.CodeParamError, "err.voice_codec", "wav"),
+		ErrForwardTargetRequired, ErrForwardItemsRequired, ErrForwardModeInvalid,
+		ErrSuspendReasonRequired, ErrAppealContentRequired, ErrAppealNotNeeded, ErrAppealPending,
+		ErrAppealNotFound, ErrAppealHandled, newError(response.CodeParamError, "err.appeal_too_long", appealMaxLen),
+	}
+	for _, e := range errs {
+		zh, en := e.Localize(response.LangZH), e.Localize(response.LangEN)
+		if zh == e.Key || en == zh || strings.Contains(zh+en, "%!") {
+			t.Errorf("%s: zh=%q en=%q", e.Key, zh, en)
+		}
+		if e.Code != response.CodeParamError && e.Code != response.CodePermissionDeny && e.Code != response.CodeTokenInvalid {
+			t.Errorf("%s: unexpected code %d", e.Key, e.Code)
+		}
+	}
+	// 未配置钱包属于服务端配置问题，仍按内部错误返回，但文案需可翻译
+	if zh, en := ErrWalletNotConfigured.Localize(response.LangZH), ErrWalletNotConfigured.Localize(response.LangEN); zh == ErrWalletNotConfigured.Key || en == zh {
+		t.Errorf("wallet: zh=%q en=%q", zh, en)
+	}
+}
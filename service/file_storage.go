@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStorage 服务端生成/上传文件（自动群头像、头像上传）的存储后端。
+// 默认 LocalFileStorage 落盘；接入 OSS/CDN 时实现该接口并通过 chat_sdk.WithFileStorage 注入。
+type FileStorage interface {
+	// Put 保存文件，name 为相对路径（如 "avatar/user/1/ab12_large.jpg"），返回写库/对外访问的 URL
+	Put(ctx context.Context, name string, data []byte, contentType string) (string, error)
+	// Delete 删除 Put 返回的 URL 对应的文件；不属于本存储的 URL 直接忽略
+	Delete(ctx context.Context, url string) error
+}
+
+// LocalFileStorage 本地目录存储，URL = URLPrefix + "/" + name
+type LocalFileStorage struct {
+	Dir string
+	// URLPrefix 为空时使用 Dir 作为前缀（去掉 file:// 与前导 /，生成相对路径）
+	URLPrefix string
+}
+
+func (l *LocalFileStorage) Put(_ context.Context, name string, data []byte, _ string) (string, error) {
+	path := filepath.Join(l.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	if prefix := l.prefix(); prefix != "" {
+		return prefix + "/" + name, nil
+	}
+	return name, nil
+}
+
+func (l *LocalFileStorage) Delete(_ context.Context, url string) error {
+	name := url
+	if prefix := l.prefix(); prefix != "" {
+		var ok bool
+		if name, ok = strings.CutPrefix(url, prefix+"/"); !ok {
+			return nil
+		}
+	}
+	// 只删 Dir 下的文件
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if rel == "." || filepath.IsAbs(rel) || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(l.Dir, rel)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *LocalFileStorage) prefix() string {
+	prefix := strings.TrimSpace(l.URLPrefix)
+	if prefix == "" {
+		prefix = strings.TrimSpace(l.Dir)
+		prefix = strings.TrimPrefix(prefix, "file://")
+		prefix = strings.ReplaceAll(prefix, "\\", "/")
+		prefix = strings.TrimPrefix(prefix, "/")
+	}
+	return strings.TrimSuffix(prefix, "/")
+}
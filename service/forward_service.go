@@ -5,11 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/cydxin/chat-sdk/message"
 	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 type ForwardMode string
@@ -35,35 +36,47 @@ type ForwardReq struct {
 	Comment string `json:"comment"`
 }
 
-type MergeForwardPayload struct {
-	MessageID uint64 `json:"message_id"`
-	Type      string `json:"type"` // merge_forward
-	Title     string `json:"title"`
-	From      uint64 `json:"from"`
-	Count     int    `json:"count"`
-	Items     []any  `json:"items"`
-	Comment   string `json:"comment,omitempty"`
+// 转发参数错误
+var (
+	ErrForwardTargetRequired = newError(response.CodeParamError, "err.forward_target_required")
+	ErrForwardItemsRequired  = newError(response.CodeParamError, "err.forward_items_required")
+	ErrForwardModeInvalid    = newError(response.CodeParamError, "err.forward_mode_invalid")
+)
+
+// MergeForwardPayload 合并转发消息的 extra，同时作为 merge_forward 事件推送
+type MergeForwardPayload = message.MergeForwardEvent
+
+// ForwardRoomResult 单个目标房间的转发结果（Error 非空表示该房间转发失败，已整体回滚）
+type ForwardRoomResult struct {
+	RoomID     uint64   `json:"room_id"`
+	MessageIDs []uint64 `json:"message_ids,omitempty"`
+	Error      string   `json:"error,omitempty"`
 }
 
 // ForwardMessages 支持逐条转发/合并转发。
 // 注意：
-// - 这里不会校验 FromUserID 是否有权限看到这些消息（你可以在上层按房间成员校验）。
-// - 逐条转发：每条消息会变成目标房间的一条新消息（保留 type/content/extra/is_system/is_encrypted）。
-// - 合并转发：目标房间只生成一条消息，type=1(content为摘要)，extra 内包含 merge payload。
-func (s *MessageService) ForwardMessages(ctx context.Context, req ForwardReq) ([]uint64, error) {
+//   - 只转发 FromUserID 当前所在房间的消息；已撤回、已双删、阅后即焚的消息跳过（与不存在同样处理）。
+//   - 逐条转发：每条消息会变成目标房间的一条新消息（保留 type/content/extra/is_system/is_encrypted）。
+//   - 合并转发：目标房间只生成一条消息，type=1(content为摘要)，extra 内包含 merge payload。
+//   - 每个目标房间一个事务，与普通发消息走同一落库流程（禁言、last_message_id、会话可见、@ 列表），
+//     不计入发消息频率；某个房间失败不影响其他房间，结果按房间返回。
+func (s *MessageService) ForwardMessages(ctx context.Context, req ForwardReq) ([]ForwardRoomResult, error) {
 	if req.FromUserID == 0 {
-		return nil, fmt.Errorf("from_user_id is required")
+		return nil, ErrUserIDRequired
 	}
 	if len(req.ToRoomIDs) == 0 {
-		return nil, fmt.Errorf("to_room_ids is required")
+		return nil, ErrForwardTargetRequired
 	}
 	if len(req.Items) == 0 {
-		return nil, fmt.Errorf("items is required")
+		return nil, ErrForwardItemsRequired
 	}
 	mode := req.Mode
 	if mode == "" {
 		mode = ForwardModeMerge
 	}
+	if mode != ForwardModeSingle && mode != ForwardModeMerge {
+		return nil, ErrForwardModeInvalid
+	}
 
 	// 1) 批量查原消息（后续按 req.Items 顺序还原）
 	ids := make([]uint64, 0, len(req.Items))
@@ -74,135 +87,156 @@ func (s *MessageService) ForwardMessages(ctx context.Context, req ForwardReq) ([
 		ids = append(ids, it.MessageID)
 	}
 	if len(ids) == 0 {
-		return nil, fmt.Errorf("no valid message_id")
+		return nil, ErrForwardItemsRequired
 	}
 
 	var msgs []models.Message
 	if err := s.DB.WithContext(ctx).Model(&models.Message{}).
-		Where("id IN ?", ids).
+		Where("id IN ? AND status NOT IN ?", ids, []int{models.MessageStatusRecalled, models.MessageStatusBothDeleted}).
 		Order("created_at ASC").
 		Find(&msgs).Error; err != nil {
 		return nil, err
 	}
+	// 只能转发自己所在房间的消息，不在的按不存在处理（不暴露消息是否存在）
+	srcRooms := make([]uint64, 0, len(msgs))
+	for _, m := range msgs {
+		srcRooms = append(srcRooms, m.RoomID)
+	}
+	var memberOf []uint64
+	if len(srcRooms) > 0 {
+		if err := s.DB.WithContext(ctx).Model(&models.RoomUser{}).
+			Where("user_id = ? AND room_id IN ?", req.FromUserID, srcRooms).
+			Pluck("room_id", &memberOf).Error; err != nil {
+			return nil, err
+		}
+	}
+	member := make(map[uint64]bool, len(memberOf))
+	for _, id := range memberOf {
+		member[id] = true
+	}
 	msgByID := make(map[uint64]models.Message, len(msgs))
 	for _, m := range msgs {
-		msgByID[m.ID] = m
+		if member[m.RoomID] {
+			msgByID[m.ID] = m
+		}
 	}
 
 	ordered := make([]models.Message, 0, len(ids))
 	for _, it := range req.Items {
 		m, ok := msgByID[it.MessageID]
-		if !ok {
+		// 阅后即焚消息不可转发
+		if !ok || m.IsViewOnce {
 			continue
 		}
 		ordered = append(ordered, m)
 	}
 	if len(ordered) == 0 {
-		return nil, fmt.Errorf("messages not found")
+		return nil, ErrMessageNotFound
 	}
 
-	createdIDs := make([]uint64, 0)
-
+	comment := strings.TrimSpace(req.Comment)
+	results := make([]ForwardRoomResult, 0, len(req.ToRoomIDs))
 	for _, toRoomID := range req.ToRoomIDs {
 		if toRoomID == 0 {
 			continue
 		}
-
-		switch mode {
-		case ForwardModeSingle:
-			// 可选：先发一条系统附言
-			if strings.TrimSpace(req.Comment) != "" {
-				_, _ = s.SaveMessage(toRoomID, req.FromUserID, strings.TrimSpace(req.Comment), 1, message.Extra{})
-			}
-			for _, m := range ordered {
-				newMsg := &models.Message{
-					RoomID:       toRoomID,
-					SenderID:     req.FromUserID,
-					ReplyToMsgID: nil,
-					Type:         m.Type,
-					Content:      m.Content,
-					Extra:        m.Extra,
-					IsSystem:     m.IsSystem,
-					IsEncrypted:  m.IsEncrypted,
-					Status:       models.MessageStatusSent,
-				}
-				if err := s.messageDAO.Create(newMsg); err != nil {
-					return createdIDs, err
+		res := ForwardRoomResult{RoomID: toRoomID}
+		var created []*models.Message
+		var mergePayload *MergeForwardPayload
+
+		err := s.TxContext(ctx, func(tx *gorm.DB) error {
+			created = created[:0]
+			var batch []*models.Message
+			switch mode {
+			case ForwardModeSingle:
+				// 可选：附言作为第一条普通文本消息
+				if comment != "" {
+					extra, _ := json.Marshal(message.Extra{})
+					batch = append(batch, &models.Message{Type: 1, Content: comment, Extra: datatypes.JSON(extra)})
 				}
-				createdIDs = append(createdIDs, newMsg.ID)
-				// 维持会话/通知：复用 SaveMessage 的后置逻辑需要更多重构；这里简化为 ws 推一次
-				if s.WsNotifier != nil {
-					notif := map[string]any{"type": EventForward, "room_id": toRoomID, "message_id": newMsg.ID}
-					b, _ := json.Marshal(notif)
-					var memberIDs []uint64
-					_ = s.DB.WithContext(ctx).Model(&models.RoomUser{}).Where("room_id = ?", toRoomID).Pluck("user_id", &memberIDs).Error
-					for _, uid := range memberIDs {
-						s.WsNotifier(uid, b)
-					}
+				for _, m := range ordered {
+					batch = append(batch, &models.Message{
+						Type:        m.Type,
+						Content:     m.Content,
+						Extra:       m.Extra,
+						IsSystem:    m.IsSystem,
+						IsEncrypted: m.IsEncrypted,
+					})
 				}
-			}
-
-		case ForwardModeMerge:
-			payload := MergeForwardPayload{
-				Type:    EventMergeForward,
-				Title:   "聊天记录",
-				From:    req.FromUserID,
-				Count:   len(ordered),
-				Items:   make([]any, 0, len(ordered)),
-				Comment: strings.TrimSpace(req.Comment),
-			}
-			for _, m := range ordered {
-				payload.Items = append(payload.Items, map[string]any{
-					"id":         m.ID,
-					"room_id":    m.RoomID,
-					"sender_id":  m.SenderID,
-					"type":       m.Type,
-					"content":    m.Content,
-					"extra":      json.RawMessage(m.Extra),
-					"created_at": m.CreatedAt,
+			case ForwardModeMerge:
+				mergePayload = buildMergeForwardPayload(req.FromUserID, ordered, comment)
+				b, _ := json.Marshal(mergePayload)
+				batch = append(batch, &models.Message{
+					Type:    1,
+					Content: fmt.Sprintf("[合并转发] %d 条聊天记录", len(ordered)),
+					Extra:   datatypes.JSON(b),
 				})
 			}
-			b, _ := json.Marshal(payload)
-
-			content := fmt.Sprintf("[合并转发] %d 条聊天记录", len(ordered))
-			newMsg := &models.Message{
-				RoomID:      toRoomID,
-				SenderID:    req.FromUserID,
-				Type:        1,
-				Content:     content,
-				Extra:       datatypes.JSON(b),
-				IsSystem:    false,
-				IsEncrypted: false,
-				Status:      models.MessageStatusSent,
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
-			}
-			if err := s.messageDAO.Create(newMsg); err != nil {
-				return createdIDs, err
+			for i, m := range batch {
+				m.RoomID = toRoomID
+				m.SenderID = req.FromUserID
+				m.Status = models.MessageStatusSent
+				// 禁言/账号状态只需在第一条校验
+				opts := persistOptions{skipRateLimit: true, clearMentions: true, skipChecks: i > 0}
+				if err := s.persistMessage(ctx, tx, m, opts); err != nil {
+					return err
+				}
+				created = append(created, m)
 			}
-			createdIDs = append(createdIDs, newMsg.ID)
+			return nil
+		})
+		if err != nil {
+			res.Error = err.Error()
+			results = append(results, res)
+			continue
+		}
+		for _, m := range created {
+			res.MessageIDs = append(res.MessageIDs, m.ID)
+		}
+		results = append(results, res)
+		s.notifyForwarded(ctx, toRoomID, created, mergePayload)
+	}
 
-			// 会话展示/last_message_id：复用 SaveMessage 的逻辑（但 SaveMessage 会检查禁言）
-			// 合并转发属于发送行为，仍需要禁言校验，直接调用 SaveMessage
-			if err := s.DB.WithContext(ctx).Model(&models.Message{}).Where("id = ?", newMsg.ID).Update("extra", newMsg.Extra).Error; err != nil {
-				_ = err
-			}
+	return results, nil
+}
 
-			if s.WsNotifier != nil {
-				//notif := map[string]any{"type": EventMergeForward, "room_id": toRoomID, "message_id": newMsg.ID, "content": payload}
-				payload.MessageID = newMsg.ID
-				nb, _ := json.Marshal(payload)
+func buildMergeForwardPayload(from uint64, ordered []models.Message, comment string) *MergeForwardPayload {
+	payload := &MergeForwardPayload{
+		EventHeader: message.EventHeader{Type: EventMergeForward},
+		Title:       "聊天记录",
+		From:        from,
+		Count:       len(ordered),
+		Items:       make([]message.MergeForwardItem, 0, len(ordered)),
+		Comment:     comment,
+	}
+	for _, m := range ordered {
+		payload.Items = append(payload.Items, message.MergeForwardItem{
+			ID:        m.ID,
+			RoomID:    m.RoomID,
+			SenderID:  m.SenderID,
+			Type:      m.Type,
+			Content:   m.Content,
+			Extra:     json.RawMessage(m.Extra),
+			CreatedAt: m.CreatedAt,
+		})
+	}
+	return payload
+}
 
-				var memberIDs []uint64
-				_ = s.DB.WithContext(ctx).Model(&models.RoomUser{}).Where("room_id = ?", toRoomID).Pluck("user_id", &memberIDs).Error
-				for _, uid := range memberIDs {
-					s.WsNotifier(uid, nb)
-				}
-			}
-		default:
-			return createdIDs, fmt.Errorf("invalid mode")
-		}
+// notifyForwarded 事务提交后再推送，避免推送了回滚掉的消息
+func (s *MessageService) notifyForwarded(ctx context.Context, roomID uint64, created []*models.Message, mergePayload *MergeForwardPayload) {
+	if (s.WsNotifier == nil && s.WsBatchNotifier == nil) || len(created) == 0 {
+		return
 	}
+	var memberIDs []uint64
+	_ = s.DB.WithContext(ctx).Model(&models.RoomUser{}).Where("room_id = ?", roomID).Pluck("user_id", &memberIDs).Error
 
-	return createdIDs, nil
+	if mergePayload != nil {
+		mergePayload.MessageID = created[0].ID
+		s.Emit(memberIDs, mergePayload)
+		return
+	}
+	for _, m := range created {
+		s.Emit(memberIDs, &message.ForwardEvent{RoomID: roomID, MessageID: m.ID})
+	}
 }
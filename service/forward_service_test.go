@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMessageService_ForwardMessages_PerRoomTx(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewMessageService(&Service{DB: gormDB})
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE \\(id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "type", "content", "extra", "created_at"}).
+			AddRow(1, 5, 7, 1, "hello", `{"mentioned_users":[9]}`, now))
+	mock.ExpectQuery("SELECT `room_id` FROM `im_room_user` WHERE user_id = \\? AND room_id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"room_id"}).AddRow(5))
+
+	expectChecks := func(roomMuted bool) {
+		mock.ExpectQuery("SELECT .* FROM `im_user` WHERE id = ?").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(3, 0))
+		room := sqlmock.NewRows([]string{"id", "is_mute", "mute_until"})
+		if roomMuted {
+			room.AddRow(20, true, now.Add(time.Hour))
+		} else {
+			room.AddRow(10, false, nil)
+		}
+		mock.ExpectQuery("SELECT \\* FROM `im_room` WHERE `im_room`.`id` = ?").WillReturnRows(room)
+		mock.ExpectQuery("SELECT \\* FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+			WillReturnRows(sqlmock.NewRows([]string{"room_id", "user_id", "role"}).AddRow(10, 3, 0))
+	}
+
+	// 房间 10：成功（附言 + 1 条消息，同一事务）
+	mock.ExpectBegin()
+	expectChecks(false)
+	for i := int64(1); i <= 2; i++ {
+		mock.ExpectExec("UPDATE `im_room` SET `message_seq`=message_seq \\+ \\? WHERE id = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT `message_seq` FROM `im_room` WHERE id = \\?").WillReturnRows(sqlmock.NewRows([]string{"message_seq"}).AddRow(i))
+		mock.ExpectExec("INSERT INTO `im_message`").WillReturnResult(sqlmock.NewResult(100+i, 1))
+		mock.ExpectExec("UPDATE `im_room` SET `last_message_id`").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE `im_conversation` SET `is_visible`").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+
+	// 房间 20：全员禁言，回滚
+	mock.ExpectBegin()
+	expectChecks(true)
+	mock.ExpectRollback()
+
+	results, err := svc.ForwardMessages(context.Background(), ForwardReq{
+		FromUserID: 3,
+		ToRoomIDs:  []uint64{10, 20},
+		Mode:       ForwardModeSingle,
+		Items:      []ForwardItem{{MessageID: 1}},
+		Comment:    "看看这个",
+	})
+	if err != nil {
+		t.Fatalf("ForwardMessages: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results=%+v", results)
+	}
+	if results[0].Error != "" || len(results[0].MessageIDs) != 2 || results[0].MessageIDs[1] != 102 {
+		t.Fatalf("room 10: %+v", results[0])
+	}
+	if results[1].Error == "" || len(results[1].MessageIDs) != 0 {
+		t.Fatalf("room 20 should fail: %+v", results[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestNormalizeMentions(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	mock.ExpectQuery("SELECT `user_id` FROM `im_room_user` WHERE room_id = \\? AND user_id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+	msg := &models.Message{RoomID: 1, Extra: datatypes.JSON(`{"mentioned_users":[2,3,2],"message_id":8}`)}
+	if err := normalizeMentions(gormDB, msg, false); err != nil {
+		t.Fatalf("normalizeMentions: %v", err)
+	}
+	var got map[string]any
+	_ = json.Unmarshal(msg.Extra, &got)
+	if m := got["mentioned_users"].([]any); len(m) != 1 || m[0].(float64) != 2 || got["message_id"].(float64) != 8 {
+		t.Fatalf("extra=%s", msg.Extra)
+	}
+
+	msg.Extra = datatypes.JSON(`{"mentioned_users":[2]}`)
+	if err := normalizeMentions(gormDB, msg, true); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if string(msg.Extra) != "{}" {
+		t.Fatalf("clear extra=%s", msg.Extra)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMessageService_ForwardMessages_SourceFilter(t *testing.T) {
+	dsn := fmt.Sprintf("file:forward_filter_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Room{}, &models.RoomUser{}, &models.Message{}, &models.Conversation{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.Create(&models.User{ID: 3, Username: "u3", Nickname: "u3"})
+	for id := uint64(1); id <= 3; id++ {
+		db.Create(&models.Room{ID: id, RoomAccount: fmt.Sprintf("r%d", id), Type: 2})
+	}
+	// 用户 3 在房间 1（来源）和 3（目标），不在房间 2
+	db.Create(&models.RoomUser{RoomID: 1, UserID: 3})
+	db.Create(&models.RoomUser{RoomID: 3, UserID: 3})
+	extra := datatypes.JSON(`{}`)
+	db.Create(&models.Message{ID: 1, RoomID: 1, SenderID: 7, Type: 1, Content: "ok", Extra: extra, Status: models.MessageStatusSent})
+	db.Create(&models.Message{ID: 2, RoomID: 1, SenderID: 7, Type: 1, Content: "recalled", Extra: extra, Status: models.MessageStatusRecalled})
+	db.Create(&models.Message{ID: 3, RoomID: 1, SenderID: 7, Type: 1, Content: "both deleted", Extra: extra, Status: models.MessageStatusBothDeleted})
+	db.Create(&models.Message{ID: 4, RoomID: 2, SenderID: 7, Type: 1, Content: "other room", Extra: extra, Status: models.MessageStatusSent})
+	svc := NewMessageService(&Service{DB: db})
+
+	items := []ForwardItem{{MessageID: 1}, {MessageID: 2}, {MessageID: 3}, {MessageID: 4}}
+	results, err := svc.ForwardMessages(context.Background(), ForwardReq{FromUserID: 3, ToRoomIDs: []uint64{3}, Mode: ForwardModeSingle, Items: items})
+	if err != nil || len(results) != 1 || results[0].Error != "" || len(results[0].MessageIDs) != 1 {
+		t.Fatalf("forward: %+v %v", results, err)
+	}
+	var copied models.Message
+	db.First(&copied, results[0].MessageIDs[0])
+	if copied.RoomID != 3 || copied.Content != "ok" {
+		t.Fatalf("copied=%+v", copied)
+	}
+
+	// 只剩撤回/双删/非成员房间的消息：按不存在处理
+	_, err = svc.ForwardMessages(context.Background(), ForwardReq{FromUserID: 3, ToRoomIDs: []uint64{3}, Items: items[1:]})
+	if !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("filtered forward: %v", err)
+	}
+}
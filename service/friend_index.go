@@ -0,0 +1,71 @@
+package service
+
+import (
+	"log"
+	"sort"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+// FriendSection 通讯录分组：按显示名拼音首字母 A–Z，其他归入 "#"
+type FriendSection struct {
+	Index   string    `json:"index"`
+	Friends []UserDTO `json:"friends"`
+}
+
+func (s *MemberService) pinyin() PinyinConverter {
+	if s.Pinyin != nil {
+		return s.Pinyin
+	}
+	return DefaultPinyinConverter
+}
+
+// fillNameIndex 为索引字母为空的好友（新加好友、改过备注/昵称、历史数据）计算索引字母并回写，
+// 按字母分批更新；回写失败只记日志，本次照常返回计算结果。
+func (s *MemberService) fillNameIndex(friends []models.Friend, dtos []UserDTO) {
+	pending := make(map[string][]uint64)
+	for i := range friends {
+		if dtos[i].NameIndex != "" {
+			continue
+		}
+		idx := s.pinyin().Initial(friendDisplayName(&dtos[i]))
+		if len(idx) != 1 {
+			idx = nameIndexOther
+		}
+		dtos[i].NameIndex = idx
+		pending[idx] = append(pending[idx], friends[i].ID)
+	}
+	for idx, ids := range pending {
+		if err := s.DB.Model(&models.Friend{}).Where("id IN ?", ids).UpdateColumn("name_index", idx).Error; err != nil {
+			log.Printf("fill friend name index: %v", err)
+		}
+	}
+}
+
+// groupFriendSections 把已按显示名排好序的好友按索引字母分组，A–Z 在前，"#" 最后
+func groupFriendSections(dtos []UserDTO) []FriendSection {
+	byIndex := make(map[string][]UserDTO)
+	for _, d := range dtos {
+		byIndex[d.NameIndex] = append(byIndex[d.NameIndex], d)
+	}
+	out := make([]FriendSection, 0, len(byIndex))
+	for idx, list := range byIndex {
+		out = append(out, FriendSection{Index: idx, Friends: list})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Index == nameIndexOther) != (out[j].Index == nameIndexOther) {
+			return out[j].Index == nameIndexOther
+		}
+		return out[i].Index < out[j].Index
+	})
+	return out
+}
+
+// GetFriendSections 按通讯录索引字母分组的好友列表，组内按显示名排序
+func (s *MemberService) GetFriendSections(userID uint64) ([]FriendSection, error) {
+	dtos, err := s.ListFriends(userID, FriendListOptions{Sort: FriendSortName})
+	if err != nil {
+		return nil, err
+	}
+	return groupFriendSections(dtos), nil
+}
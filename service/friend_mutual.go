@@ -0,0 +1,107 @@
+package service
+
+import (
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/gorm"
+)
+
+// MutualListLimit 共同好友 / 共同群聊列表的默认与最大条数
+const MutualListLimit = 50
+
+// MutualFriendsDTO 共同好友：Count 为总数，Friends 最多返回 limit 个
+type MutualFriendsDTO struct {
+	Count   int64          `json:"count"`
+	Friends []UserBasicDTO `json:"friends"`
+}
+
+// CommonGroupDTO 双方都在的群
+type CommonGroupDTO struct {
+	ID          uint64 `json:"id"`
+	RoomAccount string `json:"room_account"`
+	Name        string `json:"name"`
+	Avatar      string `json:"avatar"`
+	MemberCount int    `json:"member_count"`
+}
+
+func mutualLimit(limit int) int {
+	if limit <= 0 || limit > MutualListLimit {
+		return MutualListLimit
+	}
+	return limit
+}
+
+// mutualBlocked 任意一方拉黑了对方（friend.status=2）时不展示共同好友/群聊
+func (s *MemberService) mutualBlocked(userID, targetID uint64) (bool, error) {
+	var n int64
+	err := s.DB.Model(&models.Friend{}).
+		Where("((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)) AND status = ?", userID, targetID, targetID, userID, 2).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// GetMutualFriends userID 与 targetID 的共同好友（双方好友表按 friend_id 自连接，均为正常状态），按用户 ID 升序
+func (s *MemberService) GetMutualFriends(userID, targetID uint64, limit int) (*MutualFriendsDTO, error) {
+	out := &MutualFriendsDTO{Friends: []UserBasicDTO{}}
+	if userID == targetID {
+		return out, nil
+	}
+	if blocked, err := s.mutualBlocked(userID, targetID); err != nil || blocked {
+		return out, err
+	}
+
+	friendTable := (&models.Friend{}).TableName()
+	q := s.DB.Table(friendTable+" AS a").
+		Joins("JOIN "+friendTable+" AS b ON b.friend_id = a.friend_id AND b.user_id = ? AND b.status = ?", targetID, 1).
+		Where("a.user_id = ? AND a.status = ? AND a.friend_id <> ?", userID, 1, targetID)
+	if err := q.Session(&gorm.Session{}).Count(&out.Count).Error; err != nil {
+		return nil, err
+	}
+	if out.Count == 0 {
+		return out, nil
+	}
+	userTable := models.User{}.TableName()
+	if err := q.Joins("JOIN " + userTable + " AS u ON u.id = a.friend_id AND u.deleted_at IS NULL").
+		Select("u.id, u.username, u.nickname, u.avatar").
+		Order("u.id ASC").
+		Limit(mutualLimit(limit)).
+		Scan(&out.Friends).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetCommonGroups userID 与 targetID 都在的群聊（成员表按 room_id 自连接），按群最近活跃倒序
+func (s *MemberService) GetCommonGroups(userID, targetID uint64, limit int) ([]CommonGroupDTO, error) {
+	out := []CommonGroupDTO{}
+	if userID == targetID {
+		return out, nil
+	}
+	if blocked, err := s.mutualBlocked(userID, targetID); err != nil || blocked {
+		return out, err
+	}
+
+	memberTable := models.RoomUser{}.TableName()
+	roomTable := models.Room{}.TableName()
+	if err := s.DB.Table(memberTable+" AS a").
+		Joins("JOIN "+memberTable+" AS b ON b.room_id = a.room_id AND b.user_id = ?", targetID).
+		Joins("JOIN "+roomTable+" AS r ON r.id = a.room_id AND r.type = ? AND r.deleted_at IS NULL", 2).
+		Where("a.user_id = ?", userID).
+		Select("r.id, r.room_account, r.name, r.avatar").
+		Order("r.updated_at DESC").
+		Limit(mutualLimit(limit)).
+		Scan(&out).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(out))
+	for _, g := range out {
+		ids = append(ids, g.ID)
+	}
+	counts, err := countRoomMembers(s.DB, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].MemberCount = counts[out[i].ID]
+	}
+	return out, nil
+}
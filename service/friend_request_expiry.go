@@ -0,0 +1,81 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+// friendRequestExpireBatch 每批过期的好友申请数
+const friendRequestExpireBatch = 500
+
+// friendRequestCutoff 早于该时间创建的待处理申请视为过期；未配置有效期时返回零值
+func (s *MemberService) friendRequestCutoff() time.Time {
+	if s.FriendRequestTTL <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-s.FriendRequestTTL)
+}
+
+// friendRequestExpired 申请已过期：已标记过期，或仍待处理但超过有效期（定时任务尚未处理）
+func (s *MemberService) friendRequestExpired(r *models.FriendApply) bool {
+	if r.Status == models.StatusExpired {
+		return true
+	}
+	cutoff := s.friendRequestCutoff()
+	return r.Status == models.StatusPending && !cutoff.IsZero() && r.CreatedAt.Before(cutoff)
+}
+
+// ExpireFriendRequests 把 before 之前创建、仍待处理的好友申请标记为已过期，并通知申请人，返回过期条数。
+// 逐条条件更新（status 仍为待处理），与同意/拒绝并发时以先提交的为准。
+func (s *MemberService) ExpireFriendRequests(before time.Time) (int64, error) {
+	var total int64
+	for {
+		var rows []models.FriendApply
+		if err := s.DB.Select("id", "from_user_id", "to_user_id").
+			Where("status = ? AND created_at < ?", models.StatusPending, before).
+			Order("id ASC").
+			Limit(friendRequestExpireBatch).
+			Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		for _, r := range rows {
+			now := time.Now()
+			res := s.DB.Model(&models.FriendApply{}).
+				Where("id = ? AND status = ?", r.ID, models.StatusPending).
+				Updates(map[string]any{"status": models.StatusExpired, "updated_at": now, "processed_at": &now})
+			if res.Error != nil {
+				return total, res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			total++
+			s.Emit([]uint64{r.FromUserID}, &message.FriendExpiredEvent{RequestID: r.ID, UserID: r.ToUserID})
+		}
+		if len(rows) < friendRequestExpireBatch {
+			return total, nil
+		}
+	}
+}
+
+// RunFriendRequestExpireLoop 定时把超过 FriendRequestTTL 的待处理好友申请标记为过期（阻塞，engine 中以 goroutine 启动）
+func (s *MemberService) RunFriendRequestExpireLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.ExpireFriendRequests(s.friendRequestCutoff())
+		if err != nil {
+			log.Printf("friend request expire loop: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("friend request expire: %d requests expired", n)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+// 好友列表排序方式
+const (
+	FriendSortDefault = ""      // 默认（加好友顺序）
+	FriendSortName    = "name"  // 按显示名（备注 > 昵称 > 用户名）字母/拼音排序
+	FriendSortAdded   = "added" // 最近添加的在前
+)
+
+// FriendListOptions 好友列表查询选项
+type FriendListOptions struct {
+	Sort        string // FriendSortXxx
+	StarredOnly bool   // 只返回星标好友
+}
+
+// friendDisplayName 好友在我这里的显示名：备注 > 昵称 > 用户名
+func friendDisplayName(d *UserDTO) string {
+	switch {
+	case d.Remark != "":
+		return d.Remark
+	case d.Nickname != "":
+		return d.Nickname
+	default:
+		return d.Username
+	}
+}
+
+// sortFriendsByName 按显示名的字母/拼音顺序排序，相同时按用户 ID
+func sortFriendsByName(dtos []UserDTO) {
+	keys := make(map[uint64]string, len(dtos))
+	for i := range dtos {
+		keys[dtos[i].ID] = pinyinSortKey(friendDisplayName(&dtos[i]))
+	}
+	sort.SliceStable(dtos, func(i, j int) bool {
+		ki, kj := keys[dtos[i].ID], keys[dtos[j].ID]
+		if ki != kj {
+			return ki < kj
+		}
+		return dtos[i].ID < dtos[j].ID
+	})
+}
+
+// ListFriends 按选项获取好友列表
+func (s *MemberService) ListFriends(userID uint64, opts FriendListOptions) ([]UserDTO, error) {
+	q := s.DB.Model(&models.Friend{}).Where("user_id = ? AND status = ?", userID, 1)
+	if opts.StarredOnly {
+		q = q.Where("is_star = ?", true)
+	}
+	if opts.Sort == FriendSortAdded {
+		q = q.Order("created_at DESC").Order("id DESC")
+	}
+	var friends []models.Friend
+	if err := q.Preload("Friend").Find(&friends).Error; err != nil {
+		return nil, err
+	}
+
+	dtos := make([]UserDTO, len(friends))
+	roomAccounts := make([]string, 0, len(friends))
+	accountToIndex := make(map[string]int, len(friends))
+
+	for i, f := range friends {
+		dtos[i] = *toUserDTO(&f.Friend, userView{friend: true})
+		dtos[i].Remark = f.Remark
+		dtos[i].IsStar = f.IsStar
+		dtos[i].NameIndex = f.NameIndex
+
+		acc := generatePrivateRoomAccount(userID, f.Friend.ID)
+		roomAccounts = append(roomAccounts, acc)
+		accountToIndex[acc] = i
+	}
+
+	// 批量查询私聊房间
+	if len(roomAccounts) > 0 {
+		var rooms []models.Room
+		_ = s.DB.Model(&models.Room{}).
+			Select("id, room_account").
+			Where("room_account IN ?", roomAccounts).
+			Find(&rooms).Error
+
+		for _, r := range rooms {
+			if idx, ok := accountToIndex[r.RoomAccount]; ok {
+				dtos[idx].RoomID = r.ID
+				dtos[idx].RoomAccount = r.RoomAccount
+			}
+		}
+	}
+
+	s.fillNameIndex(friends, dtos)
+	if opts.Sort == FriendSortName {
+		sortFriendsByName(dtos)
+	}
+	return dtos, nil
+}
+
+// SetFriendStar 设置/取消星标好友（只影响自己视角）
+func (s *MemberService) SetFriendStar(userID, friendID uint64, star bool) error {
+	res := s.DB.Model(&models.Friend{}).
+		Where("user_id = ? AND friend_id = ? AND status = ?", userID, friendID, 1).
+		Updates(map[string]any{"is_star": star, "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("not friends")
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/redis/go-redis/v9"
+)
+
+// GeoService 附近的人（Redis GEO，可选功能，未配置 Redis 时不可用）。
+// 隐私约定：
+// - 只有主动上报位置（opt-in）的用户才会出现在结果中，且查询者自身也必须已上报；
+// - 位置有效期 locationTTL，过期后不再出现（查询时惰性清理）；
+// - 距离按 100 米取整返回，不返回坐标；
+// - 查询按用户限频。
+//
+// Redis Key:
+// - im:geo:users            GEO 集合（member=user_id）
+// - im:geo:alive:{uid}      位置有效标记，TTL=locationTTL
+// - im:geo:rl:{uid}         查询限频计数，TTL=1 分钟
+type GeoService struct {
+	*Service
+
+	locationTTL time.Duration
+	rateLimit   int64 // 每分钟最多查询次数
+	maxRadiusKm float64
+}
+
+func NewGeoService(s *Service) *GeoService {
+	log.Println("NewGeoService")
+	return &GeoService{
+		Service:     s,
+		locationTTL: 24 * time.Hour,
+		rateLimit:   10,
+		maxRadiusKm: 50,
+	}
+}
+
+// 附近的人错误
+var (
+	ErrGeoLatLng           = newError(response.CodeParamError, "err.geo_latlng_invalid")
+	ErrGeoLocationRequired = newError(response.CodeParamError, "err.geo_location_required")
+)
+
+const geoUsersKey = "im:geo:users"
+
+func geoAliveKey(userID uint64) string { return fmt.Sprintf("im:geo:alive:%d", userID) }
+func geoRateKey(userID uint64) string  { return fmt.Sprintf("im:geo:rl:%d", userID) }
+
+// NearbyUserDTO 附近的人
+type NearbyUserDTO struct {
+	UserID    uint64 `json:"user_id"`
+	UID       string `json:"uid"`
+	Nickname  string `json:"nickname"`
+	Avatar    string `json:"avatar"`
+	Gender    uint8  `json:"gender"`
+	Signature string `json:"signature"`
+	DistanceM int    `json:"distance_m"` // 按 100 米取整
+	IsFriend  bool   `json:"is_friend"`  // 已是好友（客户端据此决定是否展示“加好友”）
+}
+
+func (s *GeoService) ensure() error {
+	if s.RDB == nil {
+		return ErrRedisNotConfigured
+	}
+	return nil
+}
+
+func validLatLng(lat, lng float64) bool {
+	// Redis GEO 纬度有效范围 ±85.05112878
+	return lat >= -85.05112878 && lat <= 85.05112878 && lng >= -180 && lng <= 180
+}
+
+// roundDistance 距离按 100 米向上取整，避免通过多次查询三角定位
+func roundDistance(km float64) int {
+	m := int(math.Ceil(km*1000/100)) * 100
+	if m < 100 {
+		m = 100
+	}
+	return m
+}
+
+// UpdateLocation 上报/更新位置（即 opt-in）
+func (s *GeoService) UpdateLocation(ctx context.Context, userID uint64, lat, lng float64) error {
+	if err := s.ensure(); err != nil {
+		return err
+	}
+	if !validLatLng(lat, lng) {
+		return ErrGeoLatLng
+	}
+	pipe := s.RDB.TxPipeline()
+	pipe.GeoAdd(ctx, geoUsersKey, &redis.GeoLocation{Name: strconv.FormatUint(userID, 10), Latitude: lat, Longitude: lng})
+	pipe.Set(ctx, geoAliveKey(userID), 1, s.locationTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ClearLocation 清除位置（退出附近的人）
+func (s *GeoService) ClearLocation(ctx context.Context, userID uint64) error {
+	if err := s.ensure(); err != nil {
+		return err
+	}
+	pipe := s.RDB.TxPipeline()
+	pipe.ZRem(ctx, geoUsersKey, strconv.FormatUint(userID, 10))
+	pipe.Del(ctx, geoAliveKey(userID))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *GeoService) allowQuery(ctx context.Context, userID uint64) (bool, error) {
+	key := geoRateKey(userID)
+	n, err := s.RDB.Incr(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 {
+		_ = s.RDB.Expire(ctx, key, time.Minute).Err()
+	}
+	return n <= s.rateLimit, nil
+}
+
+// Nearby 查询附近的人（以查询者已上报的位置为中心）
+func (s *GeoService) Nearby(ctx context.Context, userID uint64, radiusKm float64, limit int) ([]NearbyUserDTO, error) {
+	if err := s.ensure(); err != nil {
+		return nil, err
+	}
+	if radiusKm <= 0 {
+		radiusKm = 5
+	}
+	if radiusKm > s.maxRadiusKm {
+		radiusKm = s.maxRadiusKm
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	self := strconv.FormatUint(userID, 10)
+	alive, err := s.RDB.Exists(ctx, geoAliveKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	pos, err := s.RDB.GeoPos(ctx, geoUsersKey, self).Result()
+	if err != nil {
+		return nil, err
+	}
+	if alive == 0 || len(pos) == 0 || pos[0] == nil {
+		return nil, ErrGeoLocationRequired
+	}
+	ok, err := s.allowQuery(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRateLimited
+	}
+
+	// 多取一些，给过期/自己留余量
+	locs, err := s.RDB.GeoRadius(ctx, geoUsersKey, pos[0].Longitude, pos[0].Latitude, &redis.GeoRadiusQuery{
+		Radius:   radiusKm,
+		Unit:     "km",
+		WithDist: true,
+		Count:    limit * 2,
+		Sort:     "ASC",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, 0, len(locs))
+	dist := make(map[uint64]float64, len(locs))
+	for _, l := range locs {
+		uid, err := strconv.ParseUint(l.Name, 10, 64)
+		if err != nil || uid == userID {
+			continue
+		}
+		n, err := s.RDB.Exists(ctx, geoAliveKey(uid)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			// 位置已过期：惰性清理
+			_ = s.RDB.ZRem(ctx, geoUsersKey, l.Name).Err()
+			continue
+		}
+		ids = append(ids, uid)
+		dist[uid] = l.Dist
+		if len(ids) >= limit {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return []NearbyUserDTO{}, nil
+	}
+
+	var users []models.User
+	if err := s.DB.Select("id", "uid", "nickname", "avatar", "gender", "signature").
+		Where("id IN ? AND is_bot = ? AND is_visitor = ?", ids, false, false).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]*models.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+
+	// 已是好友 / 拉黑关系（任意方向拉黑都不展示）
+	var rels []models.Friend
+	if err := s.DB.Select("user_id", "friend_id", "status").
+		Where("(user_id = ? AND friend_id IN ?) OR (friend_id = ? AND user_id IN ?)", userID, ids, userID, ids).
+		Find(&rels).Error; err != nil {
+		return nil, err
+	}
+	friends := make(map[uint64]bool)
+	blocked := make(map[uint64]bool)
+	for _, r := range rels {
+		peer := r.FriendID
+		if peer == userID {
+			peer = r.UserID
+		}
+		if r.Status == 2 {
+			blocked[peer] = true
+		} else if r.UserID == userID {
+			friends[peer] = true
+		}
+	}
+
+	out := make([]NearbyUserDTO, 0, len(ids))
+	for _, uid := range ids {
+		u := byID[uid]
+		if u == nil || blocked[uid] {
+			continue
+		}
+		out = append(out, NearbyUserDTO{
+			UserID:    u.ID,
+			UID:       u.UID,
+			Nickname:  u.Nickname,
+			Avatar:    u.Avatar,
+			Gender:    u.Gender,
+			Signature: u.Signature,
+			DistanceM: roundDistance(dist[uid]),
+			IsFriend:  friends[uid],
+		})
+	}
+	return out, nil
+}
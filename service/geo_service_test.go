@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestGeoService_Nearby(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	svc := NewGeoService(&Service{DB: gormDB, RDB: rdb})
+	ctx := context.Background()
+
+	// 未上报位置不能查询
+	if _, err := svc.Nearby(ctx, 1, 5, 10); err == nil {
+		t.Fatalf("expected error when caller has no location")
+	}
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("UpdateLocation: %v", err)
+		}
+	}
+	must(svc.UpdateLocation(ctx, 1, 31.2304, 121.4737))
+	must(svc.UpdateLocation(ctx, 2, 31.2314, 121.4737)) // ~110m
+	must(svc.UpdateLocation(ctx, 3, 31.2404, 121.4737)) // ~1.1km，拉黑了 1
+	must(svc.UpdateLocation(ctx, 4, 31.2504, 121.4737)) // 位置已过期
+	mr.Del(geoAliveKey(4))
+
+	mock.ExpectQuery("SELECT `id`,`uid`,`nickname`,`avatar`,`gender`,`signature` FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "uid", "nickname", "avatar", "gender", "signature"}).
+			AddRow(2, "u2", "bob", "", 1, "").
+			AddRow(3, "u3", "eve", "", 2, ""))
+	mock.ExpectQuery("SELECT `user_id`,`friend_id`,`status` FROM `im_friend`").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "friend_id", "status"}).
+			AddRow(1, 2, 1).
+			AddRow(3, 1, 2))
+
+	list, err := svc.Nearby(ctx, 1, 5, 10)
+	if err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if len(list) != 1 || list[0].UserID != 2 {
+		t.Fatalf("unexpected list: %#v", list)
+	}
+	if !list[0].IsFriend || list[0].DistanceM != 200 {
+		t.Fatalf("unexpected item: %#v", list[0])
+	}
+	if members, _ := mr.ZMembers(geoUsersKey); len(members) != 3 {
+		t.Fatalf("expired member should be removed, got %v", members)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGeoService_RateLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	svc := NewGeoService(&Service{RDB: rdb})
+	svc.rateLimit = 2
+	ctx := context.Background()
+
+	if err := svc.UpdateLocation(ctx, 1, 31.2304, 121.4737); err != nil {
+		t.Fatalf("UpdateLocation: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Nearby(ctx, 1, 5, 10); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+	if _, err := svc.Nearby(ctx, 1, 5, 10); err == nil {
+		t.Fatalf("expected rate limit error")
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+const (
+	// DefaultGroupAvatarDebounce 成员变动后重新合成群头像的默认延迟
+	DefaultGroupAvatarDebounce = 3 * time.Second
+	// groupAvatarMembers 参与合成的成员数（按入群先后）
+	groupAvatarMembers = 9
+)
+
+// GroupAvatarService 群头像自动合成：建群、成员加入/离开后，对未手动设置头像的群
+// 取前 9 个成员的头像重新拼图并更新 Room.Avatar。同一群的连续变动在 Debounce 内合并为一次，异步执行。
+type GroupAvatarService struct {
+	*Service
+
+	mu     sync.Mutex
+	timers map[uint64]*time.Timer
+}
+
+func NewGroupAvatarService(s *Service) *GroupAvatarService {
+	log.Println("NewGroupAvatarService")
+	return &GroupAvatarService{Service: s, timers: make(map[uint64]*time.Timer)}
+}
+
+// Schedule 安排一次防抖的重新合成（未开启合成时忽略）
+func (g *GroupAvatarService) Schedule(roomID uint64) {
+	if g == nil || !g.enabled() {
+		return
+	}
+	delay := g.GroupAvatarMergeConfig.Debounce
+	if delay <= 0 {
+		delay = DefaultGroupAvatarDebounce
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if t, ok := g.timers[roomID]; ok {
+		t.Reset(delay)
+		return
+	}
+	g.timers[roomID] = time.AfterFunc(delay, func() {
+		g.mu.Lock()
+		delete(g.timers, roomID)
+		g.mu.Unlock()
+		if _, err := g.Refresh(roomID); err != nil {
+			log.Printf("refresh group avatar room=%d: %v", roomID, err)
+		}
+	})
+}
+
+func (g *GroupAvatarService) enabled() bool {
+	return g.GroupAvatarMergeConfig != nil && g.GroupAvatarMergeConfig.Enabled
+}
+
+// Refresh 立即重新合成群头像并推送 room.group_info_updated；私聊、已手动设置头像的群不处理，返回新头像 URL（未更新时为空）
+func (g *GroupAvatarService) Refresh(roomID uint64) (string, error) {
+	var room models.Room
+	if err := g.DB.Select("id, type, creator_id, avatar, avatar_auto").
+		Where("id = ?", roomID).
+		Find(&room).Error; err != nil {
+		return "", err
+	}
+	if room.ID == 0 || room.Type != 2 || (room.Avatar != "" && !room.AvatarAuto) {
+		return "", nil
+	}
+
+	var rows []struct {
+		UserID uint64
+		Avatar string
+	}
+	if err := g.DB.Table(models.RoomUser{}.TableName()+" AS ru").
+		Select("ru.user_id, u.avatar").
+		Joins("JOIN "+models.User{}.TableName()+" AS u ON u.id = ru.user_id").
+		Where("ru.room_id = ?", roomID).
+		Order("ru.id ASC").
+		Limit(groupAvatarMembers).
+		Scan(&rows).Error; err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	avatars := make([]string, len(rows))
+	for i, r := range rows {
+		avatars[i] = r.Avatar
+	}
+
+	c := g.GroupAvatarMergeConfig
+	cfg := MergeAvatarsConfig{
+		CanvasSize: c.CanvasSize,
+		Padding:    c.Padding,
+		Gap:        c.Gap,
+		Timeout:    c.Timeout,
+		OutputDir:  c.OutputDir,
+		URLPrefix:  c.URLPrefix,
+	}.withDefaults()
+	name, data, err := renderMembersAvatar(avatars, cfg)
+	if err != nil {
+		return "", err
+	}
+	var storage FileStorage = &LocalFileStorage{Dir: cfg.OutputDir, URLPrefix: cfg.URLPrefix}
+	if g.Storage != nil {
+		storage = g.Storage
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	url, err := storage.Put(ctx, name, data, "image/png")
+	if err != nil {
+		return "", err
+	}
+	// 文件名由头像组合 hash 得到，组合不变时 URL 不变，不重复更新和推送
+	if url == room.Avatar {
+		return "", nil
+	}
+
+	// 合成期间群主/管理员手动改了头像则放弃
+	res := g.DB.Model(&models.Room{}).
+		Where("id = ? AND (avatar_auto = ? OR avatar = ?)", roomID, true, "").
+		Updates(map[string]any{"avatar": url, "avatar_auto": true})
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", nil
+	}
+
+	if g.Notify != nil {
+		members := make([]uint64, 0)
+		_ = g.DB.Model(&models.RoomUser{}).Where("room_id = ?", roomID).Pluck("user_id", &members).Error
+		_, _ = g.Notify.PublishRoomEvent(roomID, room.CreatorID, EventRoomGroupInfoUpdated,
+			map[string]any{"avatar": url, "auto": true}, members, true)
+	}
+	return url, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type memFileStorage struct{ files map[string][]byte }
+
+func (m *memFileStorage) Put(_ context.Context, name string, data []byte, _ string) (string, error) {
+	m.files[name] = data
+	return "https://cdn.example.com/" + name, nil
+}
+
+func (m *memFileStorage) Delete(_ context.Context, url string) error {
+	delete(m.files, strings.TrimPrefix(url, "https://cdn.example.com/"))
+	return nil
+}
+
+func TestGroupAvatarService_Refresh(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	store := &memFileStorage{files: map[string][]byte{}}
+	g := NewGroupAvatarService(&Service{DB: db, TablePrefix: "im_", Storage: store, GroupAvatarMergeConfig: &GroupAvatarMergeConfig{Enabled: true}})
+
+	// 已手动设置头像的群不处理
+	mock.ExpectQuery("SELECT id, type, creator_id, avatar, avatar_auto FROM `im_room` WHERE id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "creator_id", "avatar", "avatar_auto"}).AddRow(1, 2, 7, "custom.png", false))
+	if url, err := g.Refresh(1); err != nil || url != "" {
+		t.Fatalf("custom avatar: url=%q err=%v", url, err)
+	}
+
+	mock.ExpectQuery("SELECT id, type, creator_id, avatar, avatar_auto FROM `im_room`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "creator_id", "avatar", "avatar_auto"}).AddRow(2, 2, 7, "", false))
+	mock.ExpectQuery("SELECT ru.user_id, u.avatar FROM im_room_user AS ru JOIN im_user AS u ON u.id = ru.user_id WHERE ru.room_id = \\? ORDER BY ru.id ASC LIMIT \\?").
+		WithArgs(2, groupAvatarMembers).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "avatar"}).AddRow(7, "").AddRow(8, ""))
+	mock.ExpectExec("UPDATE `im_room` SET `avatar`=\\?,`avatar_auto`=\\?,`updated_at`=\\? WHERE \\(id = \\? AND \\(avatar_auto = \\? OR avatar = \\?\\)\\)").
+		WithArgs(sqlmock.AnyArg(), true, sqlmock.AnyArg(), 2, true, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	url, err := g.Refresh(2)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if !strings.HasPrefix(url, "https://cdn.example.com/") || len(store.files) != 1 {
+		t.Fatalf("unexpected url=%q files=%d", url, len(store.files))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,497 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// 客服分配策略
+const (
+	HelpDeskStrategyLeastActive = "least_active" // 当前接待数最少的在线客服
+	HelpDeskStrategyRoundRobin  = "round_robin"  // 在线客服轮询
+)
+
+// 客服错误
+var (
+	ErrHelpDeskNotAgent         = newError(response.CodePermissionDeny, "err.helpdesk_not_agent")
+	ErrHelpDeskSessionNotFound  = newError(response.CodeParamError, "err.helpdesk_session_not_found")
+	ErrHelpDeskTransferDenied   = newError(response.CodePermissionDeny, "err.helpdesk_transfer_denied")
+	ErrHelpDeskNoAgent          = newError(response.CodeParamError, "err.helpdesk_no_agent")
+	ErrHelpDeskTransferSelf     = newError(response.CodeParamError, "err.helpdesk_transfer_self")
+	ErrHelpDeskTargetNotAgent   = newError(response.CodeParamError, "err.helpdesk_target_not_agent")
+	ErrHelpDeskCloseDenied      = newError(response.CodePermissionDeny, "err.helpdesk_close_denied")
+	ErrHelpDeskRating           = newError(response.CodeParamError, "err.helpdesk_rating")
+	ErrHelpDeskRateDenied       = newError(response.CodePermissionDeny, "err.helpdesk_rate_denied")
+	ErrHelpDeskSessionNotClosed = newError(response.CodeParamError, "err.helpdesk_not_closed")
+	ErrHelpDeskRated            = newError(response.CodeParamError, "err.helpdesk_rated")
+)
+
+// HelpDeskService 客服模式：访客咨询 -> 分配在线客服 -> 转接 -> 结束/评价。
+// 每个咨询对应一个 type=3 的房间，消息收发完全复用现有 WS/房间能力。
+type HelpDeskService struct {
+	*Service
+	roomService  *RoomService
+	tokenService *TokenService
+
+	// Strategy 分配策略，默认 least_active
+	Strategy string
+	// VisitorTokenTTL 访客 token 有效期
+	VisitorTokenTTL time.Duration
+
+	rr uint64
+}
+
+func NewHelpDeskService(s *Service) *HelpDeskService {
+	log.Println("NewHelpDeskService")
+	return &HelpDeskService{
+		Service:         s,
+		roomService:     NewRoomService(s),
+		tokenService:    NewTokenServiceWithKV(s.KV),
+		Strategy:        HelpDeskStrategyLeastActive,
+		VisitorTokenTTL: 24 * time.Hour,
+	}
+}
+
+// HelpDeskSessionDTO 客服会话返回结构
+type HelpDeskSessionDTO struct {
+	ID            uint64     `json:"id"`
+	RoomID        uint64     `json:"room_id"`
+	VisitorID     uint64     `json:"visitor_id"`
+	AgentID       uint64     `json:"agent_id"`
+	Status        uint8      `json:"status"` // 1-排队 2-接待中 3-已结束
+	Rating        uint8      `json:"rating"`
+	RatingComment string     `json:"rating_comment"`
+	ClosedAt      *time.Time `json:"closed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// VisitorResp 创建访客返回
+type VisitorResp struct {
+	User  UserDTO `json:"user"`
+	Token string  `json:"token"`
+}
+
+func toHelpDeskSessionDTO(s *models.HelpDeskSession) *HelpDeskSessionDTO {
+	return &HelpDeskSessionDTO{
+		ID:            s.ID,
+		RoomID:        s.RoomID,
+		VisitorID:     s.VisitorID,
+		AgentID:       s.AgentID,
+		Status:        s.Status,
+		Rating:        s.Rating,
+		RatingComment: s.RatingComment,
+		ClosedAt:      s.ClosedAt,
+		CreatedAt:     s.CreatedAt,
+	}
+}
+
+// CreateVisitor 创建匿名访客并签发 token（需要 Redis）。
+func (s *HelpDeskService) CreateVisitor(ctx context.Context, nickname string) (*VisitorResp, error) {
+	if s.KV == nil {
+		return nil, ErrRedisNotConfigured
+	}
+	pwd, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	uid := uuid.New().String()
+	short := strings.ReplaceAll(uid, "-", "")[:16]
+	nickname = strings.TrimSpace(nickname)
+	if nickname == "" {
+		nickname = "访客" + short[:6]
+	}
+	now := time.Now()
+	u := &models.User{
+		UID:       uid,
+		Username:  "visitor_" + short,
+		Nickname:  nickname,
+		Password:  string(hash),
+		IsVisitor: true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.DB.Create(u).Error; err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokenService.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokenService.StoreToken(ctx, token, u.ID, s.VisitorTokenTTL); err != nil {
+		return nil, err
+	}
+	return &VisitorResp{User: *toUserDTO(u, selfView), Token: token}, nil
+}
+
+// SetAgent 设置/取消客服坐席（由业务方在后台调用，SDK 不提供 HTTP 接口）。
+func (s *HelpDeskService) SetAgent(userID uint64, enabled bool, maxSessions int) error {
+	if userID == 0 {
+		return ErrUserIDRequired
+	}
+	if maxSessions < 0 {
+		maxSessions = 0
+	}
+	agent := models.HelpDeskAgent{UserID: userID}
+	if err := s.DB.Where("user_id = ?", userID).FirstOrCreate(&agent).Error; err != nil {
+		return err
+	}
+	return s.DB.Model(&models.HelpDeskAgent{}).Where("id = ?", agent.ID).
+		Updates(map[string]any{"enabled": enabled, "max_sessions": maxSessions, "updated_at": time.Now()}).Error
+}
+
+func (s *HelpDeskService) getAgent(userID uint64) (*models.HelpDeskAgent, error) {
+	var agent models.HelpDeskAgent
+	if err := s.DB.Where("user_id = ? AND enabled = ?", userID, true).First(&agent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHelpDeskNotAgent
+		}
+		return nil, err
+	}
+	return &agent, nil
+}
+
+func (s *HelpDeskService) isOnline(userID uint64) bool {
+	if s.OnlineUserGetter == nil {
+		return true
+	}
+	_, _, ok := s.OnlineUserGetter(userID)
+	return ok
+}
+
+// pickAgent 按策略挑选一个在线、未满载的客服；没有可用客服返回 0。
+func (s *HelpDeskService) pickAgent(exclude uint64) (uint64, error) {
+	var agents []models.HelpDeskAgent
+	if err := s.DB.Where("enabled = ?", true).Find(&agents).Error; err != nil {
+		return 0, err
+	}
+	if len(agents) == 0 {
+		return 0, nil
+	}
+
+	type loadRow struct {
+		AgentID uint64
+		Cnt     int
+	}
+	var rows []loadRow
+	if err := s.DB.Model(&models.HelpDeskSession{}).
+		Select("agent_id, COUNT(*) AS cnt").
+		Where("status = ?", models.HelpDeskStatusActive).
+		Group("agent_id").
+		Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+	load := make(map[uint64]int, len(rows))
+	for _, r := range rows {
+		load[r.AgentID] = r.Cnt
+	}
+
+	candidates := make([]models.HelpDeskAgent, 0, len(agents))
+	for _, a := range agents {
+		if a.UserID == exclude || !s.isOnline(a.UserID) {
+			continue
+		}
+		if a.MaxSessions > 0 && load[a.UserID] >= a.MaxSessions {
+			continue
+		}
+		candidates = append(candidates, a)
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].UserID < candidates[j].UserID })
+
+	if s.Strategy == HelpDeskStrategyRoundRobin {
+		n := atomic.AddUint64(&s.rr, 1)
+		return candidates[(n-1)%uint64(len(candidates))].UserID, nil
+	}
+	best := candidates[0]
+	for _, a := range candidates[1:] {
+		if load[a.UserID] < load[best.UserID] {
+			best = a
+		}
+	}
+	return best.UserID, nil
+}
+
+// addAgentToRoom 把客服加入房间并创建会话
+func addAgentToRoom(tx *gorm.DB, roomID, agentID uint64) error {
+	now := time.Now()
+	member := models.RoomUser{RoomID: roomID, UserID: agentID, Role: 1, JoinSource: "helpdesk", JoinTime: now, CreatedAt: now, UpdatedAt: now}
+	if err := tx.Where("room_id = ? AND user_id = ?", roomID, agentID).FirstOrCreate(&member).Error; err != nil {
+		return err
+	}
+	conv := models.Conversation{UserID: agentID, RoomID: roomID}
+	if err := tx.Where("user_id = ? AND room_id = ?", agentID, roomID).FirstOrCreate(&conv).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Conversation{}).
+		Where("user_id = ? AND room_id = ?", agentID, roomID).
+		Updates(map[string]any{"is_visible": true, "updated_at": now}).Error
+}
+
+func (s *HelpDeskService) publish(sess *models.HelpDeskSession, actorID uint64, eventType string, payload map[string]interface{}) {
+	if s.Notify == nil {
+		return
+	}
+	members := []uint64{sess.VisitorID}
+	if sess.AgentID > 0 {
+		members = append(members, sess.AgentID)
+	}
+	payload["session_id"] = sess.ID
+	_, _ = s.Notify.PublishRoomEvent(sess.RoomID, actorID, eventType, payload, members, true)
+}
+
+// OpenSession 访客发起咨询：已有未结束会话直接返回，否则新建房间并分配客服。
+func (s *HelpDeskService) OpenSession(visitorID uint64) (*HelpDeskSessionDTO, error) {
+	if visitorID == 0 {
+		return nil, ErrUserIDRequired
+	}
+	var exist models.HelpDeskSession
+	err := s.DB.Where("visitor_id = ? AND status <> ?", visitorID, models.HelpDeskStatusClosed).
+		Order("id DESC").First(&exist).Error
+	if err == nil {
+		return toHelpDeskSessionDTO(&exist), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	agentID, err := s.pickAgent(0)
+	if err != nil {
+		return nil, err
+	}
+
+	room, err := s.roomService.createRoom(models.RoomTypeHelpDesk, "客服会话", visitorID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sess := &models.HelpDeskSession{
+		RoomID:    room.ID,
+		VisitorID: visitorID,
+		AgentID:   agentID,
+		Status:    models.HelpDeskStatusWaiting,
+	}
+	if agentID > 0 {
+		sess.Status = models.HelpDeskStatusActive
+	}
+	err = s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Create(sess).Error; err != nil {
+			return err
+		}
+		if agentID > 0 {
+			return addAgentToRoom(tx, room.ID, agentID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if agentID > 0 {
+		s.roomMembersChanged(room.ID, []uint64{agentID}, true)
+		s.publish(sess, visitorID, EventHelpDeskAssigned, map[string]interface{}{"agent_id": agentID})
+	}
+	return toHelpDeskSessionDTO(sess), nil
+}
+
+// ClaimSession 客服领取最早排队的会话；没有排队会话返回 (nil, nil)。
+func (s *HelpDeskService) ClaimSession(agentID uint64) (*HelpDeskSessionDTO, error) {
+	if _, err := s.getAgent(agentID); err != nil {
+		return nil, err
+	}
+	for i := 0; i < 3; i++ {
+		var sess models.HelpDeskSession
+		err := s.DB.Where("status = ?", models.HelpDeskStatusWaiting).Order("id ASC").First(&sess).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		claimed := false
+		err = s.Tx(func(tx *gorm.DB) error {
+			// 条件更新，防止多个客服同时领取
+			res := tx.Model(&models.HelpDeskSession{}).
+				Where("id = ? AND status = ?", sess.ID, models.HelpDeskStatusWaiting).
+				Updates(map[string]any{"agent_id": agentID, "status": models.HelpDeskStatusActive, "updated_at": time.Now()})
+			if res.Error != nil {
+				return res.Error
+			}
+			claimed = res.RowsAffected > 0
+			if !claimed {
+				return nil
+			}
+			return addAgentToRoom(tx, sess.RoomID, agentID)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			continue
+		}
+		sess.AgentID = agentID
+		sess.Status = models.HelpDeskStatusActive
+		s.roomMembersChanged(sess.RoomID, []uint64{agentID}, true)
+		s.publish(&sess, agentID, EventHelpDeskAssigned, map[string]interface{}{"agent_id": agentID})
+		return toHelpDeskSessionDTO(&sess), nil
+	}
+	return nil, nil
+}
+
+func (s *HelpDeskService) getSession(sessionID uint64) (*models.HelpDeskSession, error) {
+	var sess models.HelpDeskSession
+	if err := s.DB.First(&sess, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHelpDeskSessionNotFound
+		}
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// TransferSession 转接会话。toAgentID=0 表示按策略自动挑选其他客服。
+func (s *HelpDeskService) TransferSession(operatorID, sessionID, toAgentID uint64) (*HelpDeskSessionDTO, error) {
+	sess, err := s.getSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Status != models.HelpDeskStatusActive || sess.AgentID != operatorID {
+		return nil, ErrHelpDeskTransferDenied
+	}
+	if toAgentID == 0 {
+		if toAgentID, err = s.pickAgent(operatorID); err != nil {
+			return nil, err
+		}
+		if toAgentID == 0 {
+			return nil, ErrHelpDeskNoAgent
+		}
+	} else {
+		if toAgentID == operatorID {
+			return nil, ErrHelpDeskTransferSelf
+		}
+		if _, err := s.getAgent(toAgentID); err != nil {
+			return nil, ErrHelpDeskTargetNotAgent
+		}
+	}
+
+	err = s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.HelpDeskSession{}).Where("id = ?", sess.ID).
+			Updates(map[string]any{"agent_id": toAgentID, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id = ? AND user_id = ?", sess.RoomID, operatorID).Delete(&models.RoomUser{}).Error; err != nil {
+			return err
+		}
+		return addAgentToRoom(tx, sess.RoomID, toAgentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.roomMembersChanged(sess.RoomID, []uint64{operatorID}, false)
+	s.roomMembersChanged(sess.RoomID, []uint64{toAgentID}, true)
+
+	// 原客服也要收到转接通知
+	if s.Notify != nil {
+		_, _ = s.Notify.PublishRoomEvent(sess.RoomID, operatorID, EventHelpDeskTransferred, map[string]interface{}{
+			"session_id":    sess.ID,
+			"from_agent_id": operatorID,
+			"to_agent_id":   toAgentID,
+		}, []uint64{sess.VisitorID, toAgentID}, true)
+	}
+	sess.AgentID = toAgentID
+	return toHelpDeskSessionDTO(sess), nil
+}
+
+// CloseSession 结束会话（访客或当前客服）
+func (s *HelpDeskService) CloseSession(operatorID, sessionID uint64) error {
+	sess, err := s.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if operatorID != sess.VisitorID && operatorID != sess.AgentID {
+		return ErrHelpDeskCloseDenied
+	}
+	if sess.Status == models.HelpDeskStatusClosed {
+		return nil
+	}
+	now := time.Now()
+	if err := s.DB.Model(&models.HelpDeskSession{}).Where("id = ?", sess.ID).
+		Updates(map[string]any{"status": models.HelpDeskStatusClosed, "closed_by": operatorID, "closed_at": &now, "updated_at": now}).Error; err != nil {
+		return err
+	}
+	s.publish(sess, operatorID, EventHelpDeskClosed, map[string]interface{}{"closed_by": operatorID})
+	return nil
+}
+
+// RateSession 访客对已结束的会话评价（1-5 分，只能评价一次）
+func (s *HelpDeskService) RateSession(visitorID, sessionID uint64, rating uint8, comment string) error {
+	if rating < 1 || rating > 5 {
+		return ErrHelpDeskRating
+	}
+	sess, err := s.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if sess.VisitorID != visitorID {
+		return ErrHelpDeskRateDenied
+	}
+	if sess.Status != models.HelpDeskStatusClosed {
+		return ErrHelpDeskSessionNotClosed
+	}
+	res := s.DB.Model(&models.HelpDeskSession{}).
+		Where("id = ? AND rating = 0", sess.ID).
+		Updates(map[string]any{"rating": rating, "rating_comment": strings.TrimSpace(comment), "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrHelpDeskRated
+	}
+	s.publish(sess, visitorID, EventHelpDeskRated, map[string]interface{}{"rating": rating})
+	return nil
+}
+
+// ListAgentSessions 客服查看自己的会话（status=0 表示未结束的全部）
+func (s *HelpDeskService) ListAgentSessions(agentID uint64, status uint8) ([]HelpDeskSessionDTO, error) {
+	q := s.DB.Model(&models.HelpDeskSession{}).Where("agent_id = ?", agentID)
+	if status > 0 {
+		q = q.Where("status = ?", status)
+	} else {
+		q = q.Where("status <> ?", models.HelpDeskStatusClosed)
+	}
+	var list []models.HelpDeskSession
+	if err := q.Order("id DESC").Limit(200).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	out := make([]HelpDeskSessionDTO, 0, len(list))
+	for i := range list {
+		out = append(out, *toHelpDeskSessionDTO(&list[i]))
+	}
+	return out, nil
+}
+
+// IsSessionOpen 客服房间是否仍可发消息
+func (s *HelpDeskService) IsSessionOpen(roomID uint64) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.HelpDeskSession{}).
+		Where("room_id = ? AND status <> ?", roomID, models.HelpDeskStatusClosed).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectAgentLoad(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT \\* FROM `im_helpdesk_agent` WHERE enabled = ?").
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "enabled", "max_sessions"}).
+			AddRow(1, 10, true, 0).
+			AddRow(2, 20, true, 2).
+			AddRow(3, 30, true, 0).
+			AddRow(4, 40, true, 0))
+	mock.ExpectQuery("SELECT agent_id, COUNT\\(\\*\\) AS cnt FROM `im_helpdesk_session` WHERE status = ?").
+		WillReturnRows(sqlmock.NewRows([]string{"agent_id", "cnt"}).
+			AddRow(10, 3).
+			AddRow(20, 2).
+			AddRow(30, 1))
+}
+
+func TestHelpDeskService_PickAgentLeastActive(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	// 40 离线；20 已满载；30 接待数最少
+	hs := NewHelpDeskService(&Service{DB: gormDB, OnlineUserGetter: func(uid uint64) (string, string, bool) {
+		return "", "", uid != 40
+	}})
+
+	expectAgentLoad(mock)
+	got, err := hs.pickAgent(0)
+	if err != nil {
+		t.Fatalf("pickAgent: %v", err)
+	}
+	if got != 30 {
+		t.Fatalf("expected agent 30, got %d", got)
+	}
+
+	// 排除 30 后只剩 10
+	expectAgentLoad(mock)
+	got, err = hs.pickAgent(30)
+	if err != nil {
+		t.Fatalf("pickAgent: %v", err)
+	}
+	if got != 10 {
+		t.Fatalf("expected agent 10, got %d", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHelpDeskService_PickAgentRoundRobin(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	hs := NewHelpDeskService(&Service{DB: gormDB, OnlineUserGetter: func(uid uint64) (string, string, bool) {
+		return "", "", uid != 40
+	}})
+	hs.Strategy = HelpDeskStrategyRoundRobin
+
+	want := []uint64{10, 30, 10}
+	for i, w := range want {
+		expectAgentLoad(mock)
+		got, err := hs.pickAgent(0)
+		if err != nil {
+			t.Fatalf("pickAgent: %v", err)
+		}
+		if got != w {
+			t.Fatalf("round %d: expected agent %d, got %d", i, w, got)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrKVNotFound key 不存在（或已过期）
+var ErrKVNotFound = errors.New("kv: key not found")
+
+// KVStore token / 验证码等短期状态使用的最小 KV 能力。
+// 默认由 Redis 实现；单机部署或单元测试可用 NewMemoryKVStore，通过 chat_sdk.WithKVStore 注入。
+// ttl <= 0 表示不过期。
+type KVStore interface {
+	// Get 取字符串值，不存在返回 ErrKVNotFound
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX key 不存在时写入，返回是否写入成功
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+	// TTL 剩余有效期；key 不存在或未设置过期时返回 <=0
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// Incr 计数 +1 并返回新值；key 不存在时从 0 开始并设置 ttl（已存在时不改过期时间）
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	SAdd(ctx context.Context, key string, members ...string) error
+	SRem(ctx context.Context, key string, members ...string) error
+	// SMembers 集合成员，key 不存在返回空切片
+	SMembers(ctx context.Context, key string) ([]string, error)
+}
+
+// NormalizeRedisClient 把装着 nil 指针的 UniversalClient 归一为 nil。
+// 兼容旧写法：var rdb *redis.Client（未初始化）直接传入时，接口值不为 nil，各处 RDB == nil 判断会失效。
+func NormalizeRedisClient(rdb redis.UniversalClient) redis.UniversalClient {
+	switch c := rdb.(type) {
+	case nil:
+		return nil
+	case *redis.Client:
+		if c == nil {
+			return nil
+		}
+	case *redis.ClusterClient:
+		if c == nil {
+			return nil
+		}
+	case *redis.Ring:
+		if c == nil {
+			return nil
+		}
+	}
+	return rdb
+}
+
+// kvFromRedis rdb 为 nil 时返回 nil（避免接口里装着 nil 指针）
+func kvFromRedis(rdb redis.UniversalClient) KVStore {
+	if rdb = NormalizeRedisClient(rdb); rdb == nil {
+		return nil
+	}
+	return NewRedisKVStore(rdb)
+}
+
+// RedisKVStore 基于 go-redis 的 KVStore（单机 / Sentinel / Cluster 均可）
+type RedisKVStore struct {
+	rdb redis.UniversalClient
+}
+
+func NewRedisKVStore(rdb redis.UniversalClient) *RedisKVStore {
+	return &RedisKVStore{rdb: rdb}
+}
+
+func (r *RedisKVStore) Get(ctx context.Context, key string) (string, error) {
+	val, err := r.rdb.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrKVNotFound
+	}
+	return val, err
+}
+
+func (r *RedisKVStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return r.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *RedisKVStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return r.rdb.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (r *RedisKVStore) Del(ctx context.Context, keys ...string) error {
+	switch len(keys) {
+	case 0:
+		return nil
+	case 1:
+		return r.rdb.Del(ctx, keys[0]).Err()
+	}
+	// 多 key 分别 DEL：Cluster 模式下 key 可能分属不同 slot
+	pipe := r.rdb.Pipeline()
+	for _, k := range keys {
+		pipe.Del(ctx, k)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisKVStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return r.rdb.TTL(ctx, key).Result()
+}
+
+func (r *RedisKVStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return r.rdb.Persist(ctx, key).Err()
+	}
+	return r.rdb.Expire(ctx, key, ttl).Err()
+}
+
+// incrScript INCR 与首次设置过期放在同一脚本里，避免计数 key 没有 TTL
+var incrScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n`)
+
+func (r *RedisKVStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return incrScript.Run(ctx, r.rdb, []string{key}, ttl.Milliseconds()).Int64()
+}
+
+func (r *RedisKVStore) SAdd(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return r.rdb.SAdd(ctx, key, toAnySlice(members)...).Err()
+}
+
+func (r *RedisKVStore) SRem(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return r.rdb.SRem(ctx, key, toAnySlice(members)...).Err()
+}
+
+func (r *RedisKVStore) SMembers(ctx context.Context, key string) ([]string, error) {
+	return r.rdb.SMembers(ctx, key).Result()
+}
+
+func toAnySlice(ss []string) []any {
+	out := make([]any, len(ss))
+	for i, s := range ss {
+		out[i] = s
+	}
+	return out
+}
+
+// MemoryKVStore 进程内 KVStore（过期惰性清理 + 写入时定期扫描），仅适合单实例部署与测试
+type MemoryKVStore struct {
+	mu     sync.Mutex
+	items  map[string]*memKVItem
+	writes int
+	now    func() time.Time
+}
+
+type memKVItem struct {
+	str      string
+	set      map[string]struct{}
+	expireAt time.Time // 零值表示不过期
+}
+
+// memKVSweepEvery 每多少次写入做一次全量过期扫描
+const memKVSweepEvery = 1024
+
+func NewMemoryKVStore() *MemoryKVStore {
+	return &MemoryKVStore{items: make(map[string]*memKVItem), now: time.Now}
+}
+
+// get 取未过期的 item（调用方持锁），过期的顺手删除
+func (m *MemoryKVStore) get(key string) *memKVItem {
+	it, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.expireAt.IsZero() && !m.now().Before(it.expireAt) {
+		delete(m.items, key)
+		return nil
+	}
+	return it
+}
+
+func (m *MemoryKVStore) expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return m.now().Add(ttl)
+}
+
+// wrote 记录一次写入，定期清理过期 key，避免只写不读的 key 常驻内存（调用方持锁）
+func (m *MemoryKVStore) wrote() {
+	m.writes++
+	if m.writes < memKVSweepEvery {
+		return
+	}
+	m.writes = 0
+	now := m.now()
+	for k, it := range m.items {
+		if !it.expireAt.IsZero() && !now.Before(it.expireAt) {
+			delete(m.items, k)
+		}
+	}
+}
+
+func (m *MemoryKVStore) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it := m.get(key)
+	if it == nil || it.set != nil {
+		return "", ErrKVNotFound
+	}
+	return it.str, nil
+}
+
+func (m *MemoryKVStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = &memKVItem{str: value, expireAt: m.expireAt(ttl)}
+	m.wrote()
+	return nil
+}
+
+func (m *MemoryKVStore) SetNX(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.get(key) != nil {
+		return false, nil
+	}
+	m.items[key] = &memKVItem{str: value, expireAt: m.expireAt(ttl)}
+	m.wrote()
+	return true, nil
+}
+
+func (m *MemoryKVStore) Del(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.items, k)
+	}
+	return nil
+}
+
+func (m *MemoryKVStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it := m.get(key)
+	if it == nil || it.expireAt.IsZero() {
+		return 0, nil
+	}
+	return it.expireAt.Sub(m.now()), nil
+}
+
+func (m *MemoryKVStore) Expire(_ context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if it := m.get(key); it != nil {
+		it.expireAt = m.expireAt(ttl)
+	}
+	return nil
+}
+
+func (m *MemoryKVStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it := m.get(key)
+	if it == nil || it.set != nil {
+		it = &memKVItem{str: "0", expireAt: m.expireAt(ttl)}
+		m.items[key] = it
+	}
+	n, err := strconv.ParseInt(it.str, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	n++
+	it.str = strconv.FormatInt(n, 10)
+	m.wrote()
+	return n, nil
+}
+
+func (m *MemoryKVStore) SAdd(_ context.Context, key string, members ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it := m.get(key)
+	if it == nil || it.set == nil {
+		it = &memKVItem{set: make(map[string]struct{}, len(members))}
+		m.items[key] = it
+	}
+	for _, v := range members {
+		it.set[v] = struct{}{}
+	}
+	m.wrote()
+	return nil
+}
+
+func (m *MemoryKVStore) SRem(_ context.Context, key string, members ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it := m.get(key)
+	if it == nil || it.set == nil {
+		return nil
+	}
+	for _, v := range members {
+		delete(it.set, v)
+	}
+	if len(it.set) == 0 {
+		delete(m.items, key)
+	}
+	return nil
+}
+
+func (m *MemoryKVStore) SMembers(_ context.Context, key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it := m.get(key)
+	if it == nil || it.set == nil {
+		return []string{}, nil
+	}
+	out := make([]string, 0, len(it.set))
+	for v := range it.set {
+		out = append(out, v)
+	}
+	return out, nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testKVStoreContract Redis 与内存实现共用的行为约定；advance 推进时钟
+func testKVStoreContract(t *testing.T, kv KVStore, advance func(time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := kv.Get(ctx, "k"); !errors.Is(err, ErrKVNotFound) {
+		t.Fatalf("get missing: %v", err)
+	}
+	if err := kv.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if v, err := kv.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("get: %q %v", v, err)
+	}
+	if ttl, _ := kv.TTL(ctx, "k"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("ttl: %v", ttl)
+	}
+
+	ok, err := kv.SetNX(ctx, "k", "other", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("setnx existing: %v %v", ok, err)
+	}
+	if ok, _ := kv.SetNX(ctx, "nx", "1", time.Second); !ok {
+		t.Fatalf("setnx new should succeed")
+	}
+
+	if err := kv.SAdd(ctx, "s", "a", "b", "a"); err != nil {
+		t.Fatalf("sadd: %v", err)
+	}
+	if err := kv.SRem(ctx, "s", "b"); err != nil {
+		t.Fatalf("srem: %v", err)
+	}
+	members, err := kv.SMembers(ctx, "s")
+	sort.Strings(members)
+	if err != nil || len(members) != 1 || members[0] != "a" {
+		t.Fatalf("smembers: %v %v", members, err)
+	}
+	if members, _ := kv.SMembers(ctx, "missing"); len(members) != 0 {
+		t.Fatalf("smembers missing: %v", members)
+	}
+	if err := kv.Expire(ctx, "s", 2*time.Second); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		if n, err := kv.Incr(ctx, "cnt", 2*time.Second); err != nil || n != want {
+			t.Fatalf("incr: %d %v", n, err)
+		}
+	}
+
+	advance(3 * time.Second)
+	if _, err := kv.Get(ctx, "nx"); !errors.Is(err, ErrKVNotFound) {
+		t.Fatalf("nx should expire: %v", err)
+	}
+	if members, _ := kv.SMembers(ctx, "s"); len(members) != 0 {
+		t.Fatalf("set should expire: %v", members)
+	}
+	if n, _ := kv.Incr(ctx, "cnt", time.Second); n != 1 {
+		t.Fatalf("counter should expire: %d", n)
+	}
+	if ok, _ := kv.SetNX(ctx, "nx", "2", 0); !ok {
+		t.Fatalf("setnx after expiry should succeed")
+	}
+
+	if err := kv.Del(ctx, "k", "nx", "missing"); err != nil {
+		t.Fatalf("del: %v", err)
+	}
+	if _, err := kv.Get(ctx, "k"); !errors.Is(err, ErrKVNotFound) {
+		t.Fatalf("get after del: %v", err)
+	}
+}
+
+func TestMemoryKVStore_Contract(t *testing.T) {
+	kv := NewMemoryKVStore()
+	now := time.Unix(1700000000, 0)
+	kv.now = func() time.Time { return now }
+	testKVStoreContract(t, kv, func(d time.Duration) { now = now.Add(d) })
+}
+
+func TestRedisKVStore_Contract(t *testing.T) {
+	mr := miniredis.RunT(t)
+	kv := NewRedisKVStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	testKVStoreContract(t, kv, mr.FastForward)
+}
+
+func TestMemoryKVStore_SweepsExpiredKeys(t *testing.T) {
+	kv := NewMemoryKVStore()
+	now := time.Unix(1700000000, 0)
+	kv.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_ = kv.Set(ctx, "old"+string(rune('a'+i)), "1", time.Second)
+	}
+	now = now.Add(2 * time.Second)
+	for i := 0; i < memKVSweepEvery; i++ {
+		_ = kv.Set(ctx, "live", "1", 0)
+	}
+	if n := len(kv.items); n != 1 {
+		t.Fatalf("expected expired keys swept, got %d items", n)
+	}
+}
+
+func TestTokenService_MemoryKV(t *testing.T) {
+	kv := NewMemoryKVStore()
+	svc := NewTokenServiceWithKV(kv)
+	ctx := context.Background()
+
+	t1, _ := svc.GenerateToken()
+	t2, _ := svc.GenerateToken()
+	if err := svc.StoreToken(ctx, t1, 42, time.Hour); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if err := svc.StoreToken(ctx, t2, 42, time.Hour); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if uid, err := svc.GetUserIDByToken(ctx, t1); err != nil || uid != 42 {
+		t.Fatalf("lookup: %d %v", uid, err)
+	}
+
+	auth := NewAuthServiceWithKV(kv)
+	if err := auth.RevokeToken(ctx, t1); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, t1); !errors.Is(err, ErrKVNotFound) {
+		t.Fatalf("revoked token should fail: %v", err)
+	}
+	if tokens, _ := svc.ListUserTokens(ctx, 42); len(tokens) != 1 || tokens[0] != t2 {
+		t.Fatalf("tokens after revoke: %v", tokens)
+	}
+
+	if err := svc.RevokeAllTokensByUser(ctx, 42); err != nil {
+		t.Fatalf("revoke all: %v", err)
+	}
+	if _, err := svc.GetUserIDByToken(ctx, t2); err == nil {
+		t.Fatalf("t2 should be revoked")
+	}
+
+	if err := NewTokenServiceWithKV(nil).StoreToken(ctx, "x", 1, 0); err == nil {
+		t.Fatalf("nil kv should error")
+	}
+}
+
+func TestNormalizeRedisClient(t *testing.T) {
+	var nilClient *redis.Client
+	var nilCluster *redis.ClusterClient
+	if NormalizeRedisClient(nilClient) != nil || NormalizeRedisClient(nilCluster) != nil || NormalizeRedisClient(nil) != nil {
+		t.Fatalf("typed nil should normalize to nil")
+	}
+	// 旧写法传入未初始化的 *redis.Client 时不能 panic，按未配置处理
+	if _, err := NewVerifyCodeService(nilClient).SendCode(context.Background(), VerifyCodePurposeLogin, "a@b.com"); !errors.Is(err, ErrRedisNotConfigured) {
+		t.Fatalf("typed nil client: %v", err)
+	}
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	if NormalizeRedisClient(rdb) != rdb {
+		t.Fatalf("non-nil client should be kept")
+	}
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/datatypes"
+)
+
+const (
+	linkPreviewMaxBody     = 512 << 10 // 最多读取 512KB
+	linkPreviewCacheTTL    = time.Hour
+	linkPreviewFailTTL     = 10 * time.Minute // 抓取失败的链接/域名冷却时间
+	linkPreviewCacheMax    = 2048
+	linkPreviewMaxRedirect = 3
+)
+
+var (
+	errLinkPreviewBlocked = errors.New("link preview: address not allowed")
+
+	urlPattern     = regexp.MustCompile(`https?://[^\s<>"'，。！？、）)]+`)
+	metaTagPattern = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern    = regexp.MustCompile(`(?is)([a-z][a-z0-9:_-]*)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	titlePattern   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	// 100.64.0.0/10 运营商级 NAT，net.IP.IsPrivate 不包含
+	cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+)
+
+// LinkPreviewService 文本消息链接预览（Open Graph）。
+// 说明：
+// - 只抓取 http/https 且端口为 80/443/8080/8443 的地址；
+// - 拨号时校验最终连接的 IP（防 DNS rebinding），拒绝内网/回环/链路本地等地址，且不走环境代理；
+// - 结果按 URL 缓存：同一域名下不同页面的标题、图片各不相同，按域名缓存会串内容；
+// - 抓取失败的 URL 在一段时间内不再重试；只有连不上域名（DNS、拨号、TLS 握手失败）时才冷却整个域名，
+// 某个页面 404 或不是 HTML 不影响同域名的其他链接。
+type LinkPreviewService struct {
+	*Service
+	client *http.Client
+
+	mu        sync.Mutex
+	cache     map[string]linkPreviewEntry // url -> preview
+	failURLs  map[string]time.Time        // url -> 失败时间
+	failHosts map[string]time.Time        // host -> 连接失败时间
+}
+
+type linkPreviewEntry struct {
+	preview *message.LinkPreview
+	at      time.Time
+}
+
+func NewLinkPreviewService(s *Service) *LinkPreviewService {
+	log.Println("NewLinkPreviewService")
+	return &LinkPreviewService{
+		Service:   s,
+		client:    newPublicHTTPClient(5 * time.Second),
+		cache:     make(map[string]linkPreviewEntry),
+		failURLs:  make(map[string]time.Time),
+		failHosts: make(map[string]time.Time),
+	}
+}
+
+// newPublicHTTPClient 只访问公网地址的 HTTP 客户端（链接预览、机器人 Webhook 共用）：
+// 拨号时校验最终连接的 IP，不走环境代理，重定向目标同样按 checkPreviewURL 校验。
+func newPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 3 * time.Second, Control: linkPreviewDialControl}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 3 * time.Second,
+		MaxIdleConns:          16,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= linkPreviewMaxRedirect {
+				return errors.New("too many redirects")
+			}
+			return checkPreviewURL(req.URL)
+		},
+	}
+}
+
+// isPublicIP 是否为公网地址
+func isPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		if ip4[0] == 0 || cgnatNet.Contains(ip4) {
+			return false
+		}
+	}
+	return true
+}
+
+func linkPreviewDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicIP(net.ParseIP(host)) {
+		return errLinkPreviewBlocked
+	}
+	return nil
+}
+
+func checkPreviewURL(u *url.URL) error {
+	if u == nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errLinkPreviewBlocked
+	}
+	switch u.Port() {
+	case "", "80", "443", "8080", "8443":
+	default:
+		return errLinkPreviewBlocked
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublicIP(ip) {
+		return errLinkPreviewBlocked
+	}
+	return nil
+}
+
+// ExtractFirstURL 提取文本中的第一个 http(s) 链接
+func ExtractFirstURL(content string) string {
+	return urlPattern.FindString(content)
+}
+
+// parseOpenGraph 从 HTML 中解析 og:title/og:description/og:image/og:site_name，
+// 缺失时回退到 <title> 与 meta description。
+func parseOpenGraph(pageURL *url.URL, body string) *message.LinkPreview {
+	og := make(map[string]string)
+	for _, tag := range metaTagPattern.FindAllString(body, -1) {
+		attrs := make(map[string]string)
+		for _, m := range attrPattern.FindAllStringSubmatch(tag, -1) {
+			v := m[2]
+			if v == "" {
+				v = m[3]
+			}
+			attrs[strings.ToLower(m[1])] = v
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		if _, ok := og[key]; !ok {
+			og[key] = strings.TrimSpace(html.UnescapeString(attrs["content"]))
+		}
+	}
+
+	p := &message.LinkPreview{
+		URL:         pageURL.String(),
+		Title:       og["og:title"],
+		Description: og["og:description"],
+		Image:       og["og:image"],
+		SiteName:    og["og:site_name"],
+	}
+	if p.Title == "" {
+		if m := titlePattern.FindStringSubmatch(body); m != nil {
+			p.Title = strings.TrimSpace(html.UnescapeString(m[1]))
+		}
+	}
+	if p.Description == "" {
+		p.Description = og["description"]
+	}
+	if p.Image != "" {
+		if ref, err := url.Parse(p.Image); err == nil {
+			p.Image = pageURL.ResolveReference(ref).String()
+		}
+	}
+	p.Title = truncateRunes(p.Title, 200)
+	p.Description = truncateRunes(p.Description, 500)
+	if p.Title == "" && p.Description == "" && p.Image == "" {
+		return nil
+	}
+	return p
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// Fetch 抓取链接预览（带缓存）
+func (s *LinkPreviewService) Fetch(ctx context.Context, rawURL string) (*message.LinkPreview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPreviewURL(u); err != nil {
+		return nil, err
+	}
+	key := u.String()
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+
+	s.mu.Lock()
+	if e, ok := s.cache[key]; ok && now.Sub(e.at) < linkPreviewCacheTTL {
+		s.mu.Unlock()
+		return e.preview, nil
+	}
+	if t, ok := s.failHosts[host]; ok && now.Sub(t) < linkPreviewFailTTL {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("link preview: host %s temporarily skipped", host)
+	}
+	if t, ok := s.failURLs[key]; ok && now.Sub(t) < linkPreviewFailTTL {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("link preview: %s temporarily skipped", key)
+	}
+	s.mu.Unlock()
+
+	preview, err := s.fetch(ctx, u)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if isHostLevelError(err) {
+			pruneFailures(s.failHosts, now)
+			s.failHosts[host] = now
+		} else {
+			pruneFailures(s.failURLs, now)
+			s.failURLs[key] = now
+		}
+		return nil, err
+	}
+	delete(s.failURLs, key)
+	if len(s.cache) >= linkPreviewCacheMax {
+		for k, e := range s.cache {
+			if now.Sub(e.at) >= linkPreviewCacheTTL || len(s.cache) >= linkPreviewCacheMax {
+				delete(s.cache, k)
+			}
+		}
+	}
+	s.cache[key] = linkPreviewEntry{preview: preview, at: now}
+	return preview, nil
+}
+
+// pruneFailures 失败记录达到上限时清理（先清过期的，仍超限则继续删），调用方持锁
+func pruneFailures(m map[string]time.Time, now time.Time) {
+	if len(m) < linkPreviewCacheMax {
+		return
+	}
+	for k, t := range m {
+		if now.Sub(t) >= linkPreviewFailTTL || len(m) >= linkPreviewCacheMax {
+			delete(m, k)
+		}
+	}
+}
+
+// isHostLevelError 是否为连接层面的失败（DNS 解析、拨号被拒/超时、TLS 握手），同域名的其他链接大概率同样失败
+func isHostLevelError(err error) bool {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var certErr *tls.CertificateVerificationError
+	var alertErr tls.AlertError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.As(err, &dnsErr), errors.As(err, &certErr), errors.As(err, &alertErr), errors.As(err, &recordErr):
+		return true
+	case errors.As(err, &opErr):
+		return opErr.Op == "dial"
+	}
+	return false
+}
+
+func (s *LinkPreviewService) fetch(ctx context.Context, u *url.URL) (*message.LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "chat-sdk-link-preview/1.0")
+	req.Header.Set("Accept", "text/html")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("link preview: status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(strings.ToLower(ct), "text/html") {
+		return nil, fmt.Errorf("link preview: unsupported content type %q", ct)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, linkPreviewMaxBody))
+	if err != nil {
+		return nil, err
+	}
+	return parseOpenGraph(resp.Request.URL, string(body)), nil
+}
+
+// EnrichMessage 文本消息含链接时抓取预览并写回 Extra.link_preview。
+// 没有链接/抓取不到内容返回 (nil, nil)。
+func (s *LinkPreviewService) EnrichMessage(ctx context.Context, msg *models.Message) (*message.LinkPreview, error) {
+	if msg == nil || msg.Type != 1 {
+		return nil, nil
+	}
+	link := ExtractFirstURL(msg.Content)
+	if link == "" {
+		return nil, nil
+	}
+	preview, err := s.Fetch(ctx, link)
+	if err != nil || preview == nil {
+		return nil, err
+	}
+
+	var extra message.Extra
+	if len(msg.Extra) > 0 {
+		_ = json.Unmarshal(msg.Extra, &extra)
+	}
+	extra.LinkPreview = preview
+	b, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(&models.Message{}).Where("id = ?", msg.ID).UpdateColumn("extra", datatypes.JSON(b)).Error; err != nil {
+		return nil, err
+	}
+	msg.Extra = b
+	return preview, nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExtractFirstURL(t *testing.T) {
+	cases := map[string]string{
+		"看看这个 https://example.com/a?b=1，不错": "https://example.com/a?b=1",
+		"http://foo.bar/x) 结尾":              "http://foo.bar/x",
+		"没有链接":                              "",
+		"ftp://example.com":                 "",
+	}
+	for in, want := range cases {
+		if got := ExtractFirstURL(in); got != want {
+			t.Errorf("ExtractFirstURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	blocked := []string{"127.0.0.1", "10.1.2.3", "192.168.1.1", "172.16.0.1", "169.254.169.254", "100.64.1.1", "0.0.0.0", "::1", "fe80::1", "fc00::1"}
+	for _, s := range blocked {
+		if isPublicIP(net.ParseIP(s)) {
+			t.Errorf("%s should be blocked", s)
+		}
+	}
+	allowed := []string{"8.8.8.8", "1.1.1.1", "2606:4700:4700::1111"}
+	for _, s := range allowed {
+		if !isPublicIP(net.ParseIP(s)) {
+			t.Errorf("%s should be allowed", s)
+		}
+	}
+}
+
+func TestParseOpenGraph(t *testing.T) {
+	page, _ := url.Parse("https://example.com/post/1")
+	body := `<html><head>
+<title>Fallback Title</title>
+<meta property="og:title" content="Hello &amp; World">
+<meta name='description' content='plain desc'>
+<meta content="/img/cover.png" property="og:image" />
+<meta property="og:site_name" content="Example">
+</head></html>`
+
+	p := parseOpenGraph(page, body)
+	if p == nil {
+		t.Fatalf("expected preview")
+	}
+	if p.Title != "Hello & World" {
+		t.Errorf("title = %q", p.Title)
+	}
+	if p.Description != "plain desc" {
+		t.Errorf("description = %q", p.Description)
+	}
+	if p.Image != "https://example.com/img/cover.png" {
+		t.Errorf("image = %q", p.Image)
+	}
+	if p.SiteName != "Example" {
+		t.Errorf("site_name = %q", p.SiteName)
+	}
+
+	if parseOpenGraph(page, "<html></html>") != nil {
+		t.Errorf("empty page should return nil")
+	}
+}
+
+func TestLinkPreviewService_BlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<meta property="og:title" content="secret">`))
+	}))
+	defer srv.Close()
+
+	s := NewLinkPreviewService(&Service{})
+	// httptest 监听在 127.0.0.1 的随机端口：端口与 IP 均应被拒绝
+	if _, err := s.Fetch(context.Background(), srv.URL); !errors.Is(err, errLinkPreviewBlocked) {
+		t.Fatalf("expected blocked error, got %v", err)
+	}
+	// 即使端口合法，回环地址在拨号阶段也会被拒绝
+	if _, err := s.Fetch(context.Background(), "http://localhost:8080/"); err == nil {
+		t.Fatalf("expected error for localhost")
+	}
+}
+
+// dialTo 把所有请求拨到 addr（测试中绕过公网地址校验，URL 仍走 checkPreviewURL）
+func dialTo(addr string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+// 页面级失败（404、非 HTML）只冷却该 URL，同域名的其他链接照常抓取；成功结果按 URL 缓存
+func TestLinkPreviewService_FailurePerURL(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<meta property="og:title" content="page ` + r.URL.Path + `">`))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewLinkPreviewService(&Service{})
+	s.client = dialTo(srv.Listener.Addr().String())
+	ctx := context.Background()
+
+	for _, path := range []string{"/missing", "/pdf"} {
+		if _, err := s.Fetch(ctx, "http://news.example:8080"+path); err == nil {
+			t.Fatalf("%s: expected error", path)
+		}
+	}
+	a, err := s.Fetch(ctx, "http://news.example:8080/a")
+	if err != nil || a.Title != "page /a" {
+		t.Fatalf("same host after page failure: %+v %v", a, err)
+	}
+	b, err := s.Fetch(ctx, "http://news.example:8080/b")
+	if err != nil || b.Title != "page /b" {
+		t.Fatalf("second page: %+v %v", b, err)
+	}
+	if n := hits.Load(); n != 4 {
+		t.Fatalf("hits = %d, want 4", n)
+	}
+
+	// 失败的 URL 冷却期内不再请求；成功的从缓存返回
+	if _, err := s.Fetch(ctx, "http://news.example:8080/missing"); err == nil || !strings.Contains(err.Error(), "temporarily skipped") {
+		t.Fatalf("failed url retried: %v", err)
+	}
+	if again, err := s.Fetch(ctx, "http://news.example:8080/a"); err != nil || again != a {
+		t.Fatalf("cached: %+v %v", again, err)
+	}
+	if n := hits.Load(); n != 4 {
+		t.Fatalf("hits = %d after cached fetches, want 4", n)
+	}
+}
+
+// 连不上域名（拨号失败）时冷却整个域名
+func TestLinkPreviewService_FailureHostOnDial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	s := NewLinkPreviewService(&Service{})
+	s.client = dialTo(addr)
+	ctx := context.Background()
+
+	_, err = s.Fetch(ctx, "http://down.example:8080/a")
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || !isHostLevelError(err) {
+		t.Fatalf("expected dial error, got %v", err)
+	}
+	if _, err := s.Fetch(ctx, "http://down.example:8080/b"); err == nil || !strings.Contains(err.Error(), "host down.example temporarily skipped") {
+		t.Fatalf("host not skipped: %v", err)
+	}
+	// 其他域名不受影响（仍会尝试拨号）
+	if _, err := s.Fetch(ctx, "http://up.example:8080/"); err == nil || strings.Contains(err.Error(), "temporarily skipped") {
+		t.Fatalf("other host skipped: %v", err)
+	}
+}
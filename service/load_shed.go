@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// LoadShedConfig 过载保护阈值，零值字段使用默认值
+type LoadShedConfig struct {
+	// QueueFill WS 发送队列（各连接平均 / 全员广播）占用率阈值，0~1，默认 0.7
+	QueueFill float64
+	// DBLatency 数据库 ping 耗时阈值，默认 500ms
+	DBLatency time.Duration
+	// RetryAfter 检测到过载后持续拒绝非核心请求的时长（同时作为 Retry-After 返回），默认 30s
+	RetryAfter time.Duration
+	// Interval 检测间隔，默认 2s
+	Interval time.Duration
+}
+
+// LoadStatus 最近一次过载检测结果
+type LoadStatus struct {
+	Shedding      bool      `json:"shedding"`        // 当前是否在拒绝非核心请求
+	Overloaded    bool      `json:"overloaded"`      // 最近一次检测是否超过阈值
+	Reason        string    `json:"reason"`          // 超过的指标：send_queue / broadcast_queue / db_latency
+	SendQueueFill float64   `json:"send_queue_fill"` // 各连接发送队列平均占用率
+	BroadcastFill float64   `json:"broadcast_fill"`  // 全员广播队列占用率
+	DBLatencyMs   float64   `json:"db_latency_ms"`
+	ShedUntil     time.Time `json:"shed_until,omitempty"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// LoadShedder 过载检测（WithLoadShedding 开启）：定时采样 WS 发送队列深度与数据库延迟，
+// 任一指标超过阈值即在 RetryAfter 内拒绝动态、搜索、统计等非核心请求（见 middleware.GinLoadShedMiddleware），
+// 消息收发、登录、会话等核心接口不受影响。
+type LoadShedder struct {
+	*Service
+	Config LoadShedConfig
+	// QueueStats 返回 WS 各连接发送队列平均占用率与全员广播队列占用率（engine 注入），为 nil 时只看数据库
+	QueueStats func() (sendFill, broadcastFill float64)
+
+	mu     sync.RWMutex
+	status LoadStatus
+}
+
+func NewLoadShedder(s *Service, cfg LoadShedConfig) *LoadShedder {
+	log.Println("NewLoadShedder")
+	if cfg.QueueFill <= 0 || cfg.QueueFill > 1 {
+		cfg.QueueFill = 0.7
+	}
+	if cfg.DBLatency <= 0 {
+		cfg.DBLatency = 500 * time.Millisecond
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = 30 * time.Second
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 2 * time.Second
+	}
+	return &LoadShedder{Service: s, Config: cfg}
+}
+
+// Probe 采样一次并更新状态：超过阈值时把拒绝期延长到 now+RetryAfter，恢复后等拒绝期结束才放行
+func (l *LoadShedder) Probe(ctx context.Context) LoadStatus {
+	now := time.Now()
+	st := LoadStatus{CheckedAt: now}
+	if l.QueueStats != nil {
+		st.SendQueueFill, st.BroadcastFill = l.QueueStats()
+	}
+	st.DBLatencyMs = durationMs(l.pingDB(ctx))
+
+	switch {
+	case st.SendQueueFill >= l.Config.QueueFill:
+		st.Reason = "send_queue"
+	case st.BroadcastFill >= l.Config.QueueFill:
+		st.Reason = "broadcast_queue"
+	case st.DBLatencyMs >= durationMs(l.Config.DBLatency):
+		st.Reason = "db_latency"
+	}
+	st.Overloaded = st.Reason != ""
+
+	l.mu.Lock()
+	prev := l.status
+	st.ShedUntil = prev.ShedUntil
+	if st.Overloaded {
+		st.ShedUntil = now.Add(l.Config.RetryAfter)
+	}
+	st.Shedding = now.Before(st.ShedUntil)
+	l.status = st
+	l.mu.Unlock()
+
+	if st.Shedding != prev.Shedding {
+		if st.Shedding {
+			log.Printf("load shedding on: reason=%s send_queue=%.2f broadcast=%.2f db=%.1fms", st.Reason, st.SendQueueFill, st.BroadcastFill, st.DBLatencyMs)
+		} else {
+			log.Printf("load shedding off")
+		}
+	}
+	return st
+}
+
+// pingDB 数据库往返耗时（含连接池等待），超时或出错按阈值的两倍计
+func (l *LoadShedder) pingDB(ctx context.Context) time.Duration {
+	if l.DB == nil {
+		return 0
+	}
+	sqlDB, err := l.DB.DB()
+	if err != nil {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*l.Config.DBLatency)
+	defer cancel()
+	start := time.Now()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return 2 * l.Config.DBLatency
+	}
+	return time.Since(start)
+}
+
+// Shedding 当前是否拒绝非核心请求，以及建议客户端的重试等待时间。nil 时始终放行
+func (l *LoadShedder) Shedding() (bool, time.Duration) {
+	if l == nil {
+		return false, 0
+	}
+	l.mu.RLock()
+	until := l.status.ShedUntil
+	l.mu.RUnlock()
+	left := time.Until(until)
+	if left <= 0 {
+		return false, 0
+	}
+	return true, left
+}
+
+// Status 最近一次检测结果
+func (l *LoadShedder) Status() LoadStatus {
+	l.mu.RLock()
+	st := l.status
+	l.mu.RUnlock()
+	st.Shedding = time.Now().Before(st.ShedUntil)
+	return st
+}
+
+// RunLoop 按 Config.Interval 定时检测（阻塞，engine 中以 goroutine 启动）
+func (l *LoadShedder) RunLoop() {
+	ticker := time.NewTicker(l.Config.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.Probe(context.Background())
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadShedder_QueueOverload(t *testing.T) {
+	db, _, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	ls := NewLoadShedder(&Service{DB: db}, LoadShedConfig{QueueFill: 0.5, RetryAfter: 50 * time.Millisecond})
+	sendFill := 0.1
+	ls.QueueStats = func() (float64, float64) { return sendFill, 0 }
+
+	if st := ls.Probe(context.Background()); st.Shedding || st.Overloaded {
+		t.Fatalf("unexpected overload %+v", st)
+	}
+	if shedding, _ := ls.Shedding(); shedding {
+		t.Fatal("should not shed before overload")
+	}
+
+	sendFill = 0.8
+	st := ls.Probe(context.Background())
+	if !st.Shedding || st.Reason != "send_queue" {
+		t.Fatalf("want send_queue overload, got %+v", st)
+	}
+	shedding, retryAfter := ls.Shedding()
+	if !shedding || retryAfter <= 0 || retryAfter > 50*time.Millisecond {
+		t.Fatalf("Shedding() = %v, %v", shedding, retryAfter)
+	}
+
+	// 指标恢复后仍要等拒绝期结束
+	sendFill = 0.1
+	if st := ls.Probe(context.Background()); !st.Shedding || st.Overloaded {
+		t.Fatalf("want shedding until retry-after elapses, got %+v", st)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if st := ls.Probe(context.Background()); st.Shedding {
+		t.Fatalf("want recovered, got %+v", st)
+	}
+	if shedding, _ := ls.Shedding(); shedding {
+		t.Fatal("should stop shedding after retry-after")
+	}
+}
+
+func TestLoadShedder_BroadcastQueueAndNil(t *testing.T) {
+	ls := NewLoadShedder(&Service{}, LoadShedConfig{})
+	ls.QueueStats = func() (float64, float64) { return 0, 1 }
+	if st := ls.Probe(context.Background()); st.Reason != "broadcast_queue" || !ls.Status().Shedding {
+		t.Fatalf("want broadcast_queue overload, got %+v", st)
+	}
+	if ls.Config.RetryAfter != 30*time.Second || ls.Config.QueueFill != 0.7 {
+		t.Fatalf("defaults not applied: %+v", ls.Config)
+	}
+
+	var disabled *LoadShedder
+	if shedding, _ := disabled.Shedding(); shedding {
+		t.Fatal("nil shedder must never shed")
+	}
+}
@@ -1,31 +1,67 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/cydxin/chat-sdk/message"
 	"github.com/cydxin/chat-sdk/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MemberService struct {
 	*Service
+	// FriendDeletePolicy 删除好友后私聊房间/会话的处理方式（由 engine 按 WithFriendDeletePolicy 注入）
+	FriendDeletePolicy FriendDeletePolicy
+	// FriendRequestTTL 待处理好友申请的有效期（WithFriendRequestExpiry），超过后自动过期，<=0 永不过期
+	FriendRequestTTL time.Duration
+	// Pinyin 好友通讯录索引字母的计算方式（WithPinyinConverter），为空时用 DefaultPinyinConverter
+	Pinyin         PinyinConverter
+	messageService *MessageService
+}
+
+// FriendDeletePolicy 删除好友后的私聊处理策略
+type FriendDeletePolicy struct {
+	// HideConversation 双方会话列表隐藏该私聊（有新消息时会重新出现）
+	HideConversation bool
+	// BlockPrivateSend 禁止在该私聊房间继续发消息，直到重新成为好友
+	BlockPrivateSend bool
+}
+
+// DefaultFriendDeletePolicy 默认：隐藏双方会话，不限制发送
+var DefaultFriendDeletePolicy = FriendDeletePolicy{
+	HideConversation: true,
+	BlockPrivateSend: false,
 }
 
 func NewMemberService(s *Service) *MemberService {
 	log.Println("NewMemberService")
-	return &MemberService{Service: s}
+	return &MemberService{Service: s, FriendDeletePolicy: DefaultFriendDeletePolicy, messageService: NewMessageService(s)}
 }
 
-// SendFriendRequest 发送好友申请
+// SendFriendRequest 发送好友申请（来源按搜索处理）
 func (s *MemberService) SendFriendRequest(fromUser, toUser uint64, message string) error {
+	return s.SendFriendRequestWithSource(fromUser, toUser, message, models.FriendSourceSearch, 0)
+}
+
+// SendFriendRequestWithSource 发送好友申请并记录来源（见 models.FriendSource*），
+// 会按对方的隐私设置校验该来源是否允许；source=group 时 roomID 为双方所在的群。
+func (s *MemberService) SendFriendRequestWithSource(fromUser, toUser uint64, reason, source string, roomID uint64) error {
 	if fromUser == toUser {
 		return fmt.Errorf("不能添加自己为好友")
 	}
+	source, err := normalizeFriendSource(source)
+	if err != nil {
+		return err
+	}
+	if source != models.FriendSourceGroup {
+		roomID = 0
+	}
 	log.Println(1)
 	// 检查是否已经是好友
 	isFriend, _ := s.CheckFriendship(fromUser, toUser)
@@ -34,25 +70,40 @@ func (s *MemberService) SendFriendRequest(fromUser, toUser uint64, message strin
 	}
 	log.Println(2)
 
-	// 检查是否已经发送过申请
+	// 检查是否已经发送过申请（已超过有效期的不算）
 	var existingRequest models.FriendApply
-	err := s.DB.Model(&models.FriendApply{}).
-		Where("from_user_id = ? AND to_user_id = ? AND status = ?", fromUser, toUser, models.StatusPending).
-		First(&existingRequest).Error
+	q := s.DB.Model(&models.FriendApply{}).
+		Where("from_user_id = ? AND to_user_id = ? AND status = ?", fromUser, toUser, models.StatusPending)
+	if cutoff := s.friendRequestCutoff(); !cutoff.IsZero() {
+		q = q.Where("created_at >= ?", cutoff)
+	}
+	err = q.First(&existingRequest).Error
 	log.Println(3)
 
 	if err == nil {
 		return fmt.Errorf("已经发送过好友申请，请等待对方回应")
 	}
 
+	if err := checkFriendApplyAllowed(s.DB, fromUser, toUser, source, roomID); err != nil {
+		return err
+	}
+
+	// 反垃圾：每日好友申请数
+	if err := s.AntiSpam.Check(context.Background(), fromUser, SpamActionFriendRequest, 1); err != nil {
+		return err
+	}
+
 	// 创建好友申请
 	request := &models.FriendApply{
 		FromUserID: fromUser,
 		ToUserID:   toUser,
 		Status:     models.StatusPending,
-		Reason:     message,
+		Reason:     reason,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
+
+		Source:       source,
+		SourceRoomID: roomID,
 	}
 	log.Println(4)
 
@@ -64,16 +115,13 @@ func (s *MemberService) SendFriendRequest(fromUser, toUser uint64, message strin
 	}
 
 	// 通知对方
-	if s.WsNotifier != nil {
-		notification := map[string]interface{}{
-			"type":       EventFriendRequest,
-			"request_id": request.ID,
-			"from_user":  fromUser,
-			"message":    message,
-		}
-		notifBytes, _ := json.Marshal(notification)
-		s.WsNotifier(toUser, notifBytes)
-	}
+	s.Emit([]uint64{toUser}, &message.FriendRequestEvent{
+		RequestID: request.ID,
+		FromUser:  fromUser,
+		Message:   reason,
+		Source:    source,
+		RoomID:    roomID,
+	})
 	log.Println(6)
 
 	return nil
@@ -82,265 +130,192 @@ func (s *MemberService) SendFriendRequest(fromUser, toUser uint64, message strin
 // AcceptFriendRequest 同意好友申请
 func (s *MemberService) AcceptFriendRequest(requestID uint64, userID uint64) error {
 	log.Println(requestID, userID)
-	tx := s.DB.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-	defer tx.Rollback() // 确保事务在函数退出时回滚（如果未提交）
-
 	var request models.FriendApply
-	err := tx.First(&request, requestID).Error
-	if err != nil {
-		return err
-	}
-
-	// 验证是否是接收者
-	if request.ToUserID != userID {
-		return fmt.Errorf("无权操作此申请")
-	}
-
-	if request.Status != models.StatusPending {
-		return fmt.Errorf("该申请已处理")
-	}
-
-	// 更新申请状态 (使用乐观锁：Where status = Pending)
-	now := time.Now()
-	result := tx.Model(&models.FriendApply{}).
-		Where("id = ? AND status = ?", requestID, models.StatusPending).
-		Updates(map[string]interface{}{
-			"status":       models.StatusAgreed,
-			"updated_at":   now,
-			"processed_at": &now,
-		})
-
-	if result.Error != nil {
-		return result.Error
-	}
-
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("该申请已被处理")
-	}
-
-	// 创建好友关系 (双向)
-	friends := []models.Friend{
-		{
-			UserID:    request.FromUserID,
-			FriendID:  request.ToUserID,
-			Status:    1, // 正常
-			CreatedAt: now,
-			UpdatedAt: now,
-		},
-		{
-			UserID:    request.ToUserID,
-			FriendID:  request.FromUserID,
-			Status:    1, // 正常
-			CreatedAt: now,
-			UpdatedAt: now,
-		},
-	}
-
-	if err := tx.Create(&friends).Error; err != nil {
-		return err
-	}
+	var newRoomID uint64
+	// 同意申请与并发加群/建私聊可能互相死锁，由 Tx 整体重试
+	err := s.Tx(func(tx *gorm.DB) error {
+		request = models.FriendApply{}
+		if err := tx.First(&request, requestID).Error; err != nil {
+			return err
+		}
 
-	// 创建私聊房间（使用规则生成 RoomAccount）
-	roomAccount := generatePrivateRoomAccount(request.FromUserID, request.ToUserID)
+		// 验证是否是接收者
+		if request.ToUserID != userID {
+			return fmt.Errorf("无权操作此申请")
+		}
 
-	// 检查房间是否已存在
-	var existingRoom models.Room
-	err = tx.Where("room_account = ?", roomAccount).First(&existingRoom).Error
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return err
-	}
+		if s.friendRequestExpired(&request) {
+			return fmt.Errorf("该申请已过期")
+		}
+		if request.Status != models.StatusPending {
+			return fmt.Errorf("该申请已处理")
+		}
 
-	// 如果房间不存在，则创建
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		room := &models.Room{
-			RoomAccount: roomAccount,
-			Type:        1, // 1-私聊
-			CreatorID:   request.FromUserID,
-			CreatedAt:   now,
-			UpdatedAt:   now,
+		// 更新申请状态 (使用乐观锁：Where status = Pending)
+		now := time.Now()
+		result := tx.Model(&models.FriendApply{}).
+			Where("id = ? AND status = ?", requestID, models.StatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.StatusAgreed,
+				"updated_at":   now,
+				"processed_at": &now,
+			})
+
+		if result.Error != nil {
+			return result.Error
 		}
-		if err := tx.Create(room).Error; err != nil {
-			return err
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("该申请已被处理")
 		}
 
-		// 添加房间成员
-		members := []models.RoomUser{
+		// 创建好友关系 (双向)
+		friends := []models.Friend{
 			{
-				RoomID:    room.ID,
 				UserID:    request.FromUserID,
-				Role:      0,
-				JoinTime:  now,
+				FriendID:  request.ToUserID,
+				Status:    1, // 正常
 				CreatedAt: now,
 				UpdatedAt: now,
 			},
 			{
-				RoomID:    room.ID,
 				UserID:    request.ToUserID,
-				Role:      0,
-				JoinTime:  now,
+				FriendID:  request.FromUserID,
+				Status:    1, // 正常
 				CreatedAt: now,
 				UpdatedAt: now,
 			},
 		}
-		if err := tx.Create(&members).Error; err != nil {
-			return err
-		}
 
-		// 新建房间时：确保双方会话可见
-		for _, uid := range []uint64{request.FromUserID, request.ToUserID} {
-			conv := &models.Conversation{UserID: uid, RoomID: room.ID}
-			if err := tx.FirstOrCreate(conv, map[string]any{"user_id": uid, "room_id": room.ID}).Error; err != nil {
-				return err
-			}
-			if err := tx.Model(&models.Conversation{}).
-				Where("user_id = ? AND room_id = ?", uid, room.ID).
-				Updates(map[string]any{"is_visible": true, "updated_at": now}).Error; err != nil {
-				return err
-			}
-		}
-	} else {
-		// 房间已存在（通常是删好友后再加回来）：确保双方会话重新展示
-		for _, uid := range []uint64{request.FromUserID, request.ToUserID} {
-			conv := &models.Conversation{UserID: uid, RoomID: existingRoom.ID}
-			if err := tx.FirstOrCreate(conv, map[string]any{"user_id": uid, "room_id": existingRoom.ID}).Error; err != nil {
-				return err
-			}
-			if err := tx.Model(&models.Conversation{}).
-				Where("user_id = ? AND room_id = ?", uid, existingRoom.ID).
-				Updates(map[string]any{"is_visible": true, "updated_at": now}).Error; err != nil {
-				return err
-			}
+		if err := tx.Create(&friends).Error; err != nil {
+			return err
 		}
-	}
 
-	if err := tx.Commit().Error; err != nil {
+		// 创建私聊房间（已存在时解除孤立并恢复会话）
+		var err error
+		newRoomID, err = ensureFriendRoom(tx, request.FromUserID, request.ToUserID, now)
+		return err
+	})
+	if err != nil {
 		return err
 	}
+	s.roomMembersChanged(newRoomID, []uint64{request.FromUserID, request.ToUserID}, true)
 
 	// 通知申请者
-	if s.WsNotifier != nil {
-		notification := map[string]interface{}{
-			"type":       EventFriendAccepted,
-			"request_id": requestID,
-			"user_id":    userID,
-		}
-		notifBytes, _ := json.Marshal(notification)
-		s.WsNotifier(request.FromUserID, notifBytes)
-	}
+	s.Emit([]uint64{request.FromUserID}, &message.FriendAcceptedEvent{RequestID: requestID, UserID: userID})
 
 	return nil
 }
 
 // RejectFriendRequest 拒绝好友申请
 func (s *MemberService) RejectFriendRequest(requestID uint64, userID uint64) error {
-	tx := s.DB.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-	defer tx.Rollback()
-
 	var request models.FriendApply
+	err := s.Tx(func(tx *gorm.DB) error {
+		request = models.FriendApply{}
+		if err := tx.First(&request, requestID).Error; err != nil {
+			return err
+		}
 
-	if err := tx.First(&request, requestID).Error; err != nil {
-		return err
-	}
-
-	// 验证是否是接收者
-	if request.ToUserID != userID {
-		return fmt.Errorf("无权操作此申请")
-	}
-
-	if request.Status != models.StatusPending {
-		return fmt.Errorf("该申请已处理")
-	}
-
-	// 更新申请状态 (使用乐观锁)
-	now := time.Now()
-	result := tx.Model(&models.FriendApply{}).
-		Where("id = ? AND status = ?", requestID, models.StatusPending).
-		Updates(map[string]interface{}{
-			"status":       models.StatusRefused,
-			"updated_at":   now,
-			"processed_at": &now,
-		})
+		// 验证是否是接收者
+		if request.ToUserID != userID {
+			return fmt.Errorf("无权操作此申请")
+		}
 
-	if result.Error != nil {
-		return result.Error
-	}
+		if s.friendRequestExpired(&request) {
+			return fmt.Errorf("该申请已过期")
+		}
+		if request.Status != models.StatusPending {
+			return fmt.Errorf("该申请已处理")
+		}
 
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("该申请已被处理")
-	}
+		// 更新申请状态 (使用乐观锁)
+		now := time.Now()
+		result := tx.Model(&models.FriendApply{}).
+			Where("id = ? AND status = ?", requestID, models.StatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.StatusRefused,
+				"updated_at":   now,
+				"processed_at": &now,
+			})
+
+		if result.Error != nil {
+			return result.Error
+		}
 
-	if err := tx.Commit().Error; err != nil {
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("该申请已被处理")
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
 	// 通知申请者
-	if s.WsNotifier != nil {
-		notification := map[string]interface{}{
-			"type":       EventFriendRejected,
-			"request_id": requestID,
-			"user_id":    userID,
-		}
-		notifBytes, _ := json.Marshal(notification)
-		s.WsNotifier(request.FromUserID, notifBytes)
-	}
+	s.Emit([]uint64{request.FromUserID}, &message.FriendRejectedEvent{RequestID: requestID, UserID: userID})
 
 	return nil
 }
 
 // DeleteFriend 删除好友
+// 私聊房间与历史消息保留（重新加好友后复用），会话隐藏/禁止发送按 FriendDeletePolicy 处理。
 func (s *MemberService) DeleteFriend(user1, user2 uint64) error {
-	// 以事务保证：删好友 + 隐藏会话 一致
-	tx := s.DB.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-	defer tx.Rollback()
-
-	// 1) 删除双向好友关系
-	if err := tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", user1, user2, user2, user1).
-		Delete(&models.Friend{}).Error; err != nil {
-		return err
-	}
-
-	// 2) 找到两人的私聊房间，并把对应会话隐藏（仅隐藏这一个房间的会话）
-	roomAccount := generatePrivateRoomAccount(user1, user2)
+	policy := s.FriendDeletePolicy
+	// 以事务保证：删好友 + 隐藏会话 + 禁止发送 一致
 	var room models.Room
-	if err := tx.Model(&models.Room{}).
-		Select("id").
-		Where("room_account = ? AND type = ?", roomAccount, 1).
-		First(&room).Error; err == nil {
-		if err := tx.Model(&models.Conversation{}).
-			Where("room_id = ? AND user_id IN ?", room.ID, []uint64{user1, user2}).
-			Updates(map[string]any{"is_visible": false}).Error; err != nil {
+	err := s.Tx(func(tx *gorm.DB) error {
+		room = models.Room{}
+		// 1) 删除双向好友关系
+		if err := tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", user1, user2, user2, user1).
+			Delete(&models.Friend{}).Error; err != nil {
 			return err
 		}
-	}
 
-	if err := tx.Commit().Error; err != nil {
+		// 2) 找到两人的私聊房间：按策略隐藏双方会话（仅这一个房间）、标记禁止发送
+		roomAccount := generatePrivateRoomAccount(user1, user2)
+		err := tx.Model(&models.Room{}).
+			Select("id").
+			Where("room_account = ? AND type = ?", roomAccount, 1).
+			First(&room).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if policy.HideConversation {
+				if err := tx.Model(&models.Conversation{}).
+					Where("room_id = ? AND user_id IN ?", room.ID, []uint64{user1, user2}).
+					Updates(map[string]any{"is_visible": false}).Error; err != nil {
+					return err
+				}
+			}
+			if policy.BlockPrivateSend {
+				if err := tx.Model(&models.Room{}).
+					Where("id = ?", room.ID).
+					Updates(map[string]any{"is_orphaned": true, "updated_at": time.Now()}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
+	s.DisplayNames.InvalidateRemark(user1, user2)
+	s.DisplayNames.InvalidateRemark(user2, user1)
 
 	// 通知对方
-	if s.WsNotifier != nil {
-		notification := map[string]interface{}{
-			"type":    EventFriendDeleted,
-			"user_id": user1,
-			"room_id": room.ID,
+	deleted := func(other uint64) *message.FriendDeletedEvent {
+		return &message.FriendDeletedEvent{
+			UserID:             other,
+			RoomID:             room.ID,
+			ConversationHidden: policy.HideConversation,
+			SendBlocked:        policy.BlockPrivateSend,
 		}
-		notifBytes, _ := json.Marshal(notification)
-		s.WsNotifier(user2, notifBytes)
-
-		// 同时通知另一方
-		notification["user_id"] = user2
-		notifBytes, _ = json.Marshal(notification)
-		s.WsNotifier(user1, notifBytes)
 	}
+	s.Emit([]uint64{user2}, deleted(user1))
+	// 同时通知另一方
+	s.Emit([]uint64{user1}, deleted(user2))
 
 	return nil
 }
@@ -357,62 +332,7 @@ func (s *MemberService) CheckFriendship(user1, user2 uint64) (bool, error) {
 
 // GetFriendList 获取好友列表
 func (s *MemberService) GetFriendList(userID uint64) ([]UserDTO, error) {
-	var friends []models.Friend
-	err := s.DB.Model(&models.Friend{}).
-		Where("user_id = ? AND status = ?", userID, 1).
-		Preload("Friend").
-		Find(&friends).Error
-
-	if err != nil {
-		return nil, err
-	}
-
-	dtos := make([]UserDTO, len(friends))
-	roomAccounts := make([]string, 0, len(friends))
-	accountToIndex := make(map[string]int, len(friends))
-
-	for i, f := range friends {
-		dtos[i] = UserDTO{
-			ID:           f.Friend.ID,
-			UID:          f.Friend.UID,
-			Username:     f.Friend.Username,
-			Nickname:     f.Friend.Nickname,
-			Remark:       f.Remark,
-			Avatar:       f.Friend.Avatar,
-			Phone:        f.Friend.Phone,
-			Email:        f.Friend.Email,
-			Gender:       f.Friend.Gender,
-			Birthday:     f.Friend.Birthday,
-			Signature:    f.Friend.Signature,
-			OnlineStatus: f.Friend.OnlineStatus,
-			LastLoginAt:  f.Friend.LastLoginAt,
-			LastActiveAt: f.Friend.LastActiveAt,
-			CreatedAt:    f.Friend.CreatedAt,
-			UpdatedAt:    f.Friend.UpdatedAt,
-		}
-
-		acc := generatePrivateRoomAccount(userID, f.Friend.ID)
-		roomAccounts = append(roomAccounts, acc)
-		accountToIndex[acc] = i
-	}
-
-	// 批量查询私聊房间
-	if len(roomAccounts) > 0 {
-		var rooms []models.Room
-		_ = s.DB.Model(&models.Room{}).
-			Select("id, room_account").
-			Where("room_account IN ?", roomAccounts).
-			Find(&rooms).Error
-
-		for _, r := range rooms {
-			if idx, ok := accountToIndex[r.RoomAccount]; ok {
-				dtos[idx].RoomID = r.ID
-				dtos[idx].RoomAccount = r.RoomAccount
-			}
-		}
-	}
-
-	return dtos, nil
+	return s.ListFriends(userID, FriendListOptions{})
 }
 
 // UserBasicDTO 用户基本信息DTO
@@ -430,14 +350,21 @@ type FriendApplyDTO struct {
 	Reason    string       `json:"reason"`
 	Status    uint8        `json:"status"`
 	CreatedAt time.Time    `json:"created_at"`
+	// Source 申请来源：search/group/qrcode/nearby；RoomID 为来源群（source=group）
+	Source string `json:"source"`
+	RoomID uint64 `json:"room_id,omitempty"`
 }
 
 // GetPendingRequests 获取全部的好友申请
 func (s *MemberService) GetPendingRequests(userID uint64) ([]FriendApplyDTO, error) {
 	var requests []models.FriendApply
-	err := s.DB.Model(&models.FriendApply{}).
-		Where("to_user_id = ?", userID).
-		Preload("FromUser").
+	// 已过期（含超过有效期尚未被定时任务处理）的申请不返回
+	q := s.DB.Model(&models.FriendApply{}).
+		Where("to_user_id = ? AND status <> ?", userID, models.StatusExpired)
+	if cutoff := s.friendRequestCutoff(); !cutoff.IsZero() {
+		q = q.Where("status <> ? OR created_at >= ?", models.StatusPending, cutoff)
+	}
+	err := q.Preload("FromUser").
 		Order("created_at DESC").
 		Find(&requests).Error
 
@@ -459,6 +386,11 @@ func (s *MemberService) GetPendingRequests(userID uint64) ([]FriendApplyDTO, err
 			Reason:    r.Reason,
 			Status:    r.Status,
 			CreatedAt: r.CreatedAt,
+			Source:    r.Source,
+			RoomID:    r.SourceRoomID,
+		}
+		if dtos[i].Source == "" {
+			dtos[i].Source = models.FriendSourceSearch
 		}
 	}
 	return dtos, nil
@@ -474,14 +406,11 @@ func (s *MemberService) SearchUsers(keyword string, currentUserID int64, limit i
 		limit = 100
 	}
 
-	q := s.DB.Model(&models.User{})
+	q := s.ReadDB(ReadScopeSearch).Model(&models.User{})
 	if currentUserID > 0 {
 		q = q.Where("id <> ?", currentUserID)
 	}
-	if keyword != "" {
-		like := "%" + keyword + "%"
-		q = q.Where("username LIKE ? OR nickname LIKE ? OR uid LIKE ?", like, like, like)
-	}
+	q = q.Scopes(models.UserSearchScope(keyword))
 
 	var users []models.User
 	err := q.Select("id, username, nickname, avatar").
@@ -508,7 +437,7 @@ func (s *MemberService) SetFriendRemark(userID, friendID uint64, remark string)
 
 	res := s.DB.Model(&models.Friend{}).
 		Where("user_id = ? AND friend_id = ? AND status = ?", userID, friendID, 1).
-		Updates(map[string]any{"remark": remark, "updated_at": time.Now()})
+		Updates(map[string]any{"remark": remark, "name_index": "", "updated_at": time.Now()})
 
 	if res.Error != nil {
 		return res.Error
@@ -516,20 +445,46 @@ func (s *MemberService) SetFriendRemark(userID, friendID uint64, remark string)
 	if res.RowsAffected == 0 {
 		return fmt.Errorf("not friends")
 	}
+	s.DisplayNames.InvalidateRemark(userID, friendID)
 	return nil
 }
 
-// AddRoomMember 添加成员到房间（群聊）
-func (s *MemberService) AddRoomMember(roomID uint64, userIDs []uint64, operatorID uint64) error {
+// 批量拉人时单个用户的处理结果
+const (
+	AddMemberOK            = "ok"             // 已加入
+	AddMemberAlreadyMember = "already_member" // 已经是成员
+	AddMemberBlocked       = "blocked"        // 与操作者存在拉黑关系
+	AddMemberLimitReached  = "limit_reached"  // 群成员已满
+	AddMemberUserNotFound  = "user_not_found" // 用户不存在
+)
+
+// AddMemberResult 单个被邀请用户的结果
+type AddMemberResult struct {
+	UserID uint64 `json:"user_id"`
+	Status string `json:"status"` // 见 AddMember* 常量
+}
+
+// AddMembersResult 批量拉人结果
+type AddMembersResult struct {
+	Results []AddMemberResult `json:"results"`
+	Added   []uint64          `json:"added"`
+	// SystemMessage "A 邀请 B、C 加入了群聊" 系统消息（有人加入时才有），由调用方推送
+	SystemMessage *models.Message `json:"-"`
+}
+
+// AddRoomMember 批量添加成员到房间（群聊）
+// 逐个校验（用户不存在/已是成员/拉黑/超出上限），合法的用户在同一事务内入群并写一条邀请系统消息；
+// 没有任何人入群且原因是群已满时返回 ErrRoomFull。
+func (s *MemberService) AddRoomMember(roomID uint64, userIDs []uint64, operatorID uint64) (*AddMembersResult, error) {
 	// 基本校验
 	if roomID == 0 {
-		return fmt.Errorf("room_id is required")
+		return nil, fmt.Errorf("room_id is required")
 	}
 	if operatorID == 0 {
-		return fmt.Errorf("operator_id is required")
+		return nil, fmt.Errorf("operator_id is required")
 	}
 	if len(userIDs) == 0 {
-		return fmt.Errorf("user_ids is required")
+		return nil, fmt.Errorf("user_ids is required")
 	}
 
 	// 检查操作者是否是管理员
@@ -539,12 +494,12 @@ func (s *MemberService) AddRoomMember(roomID uint64, userIDs []uint64, operatorI
 		First(&member).Error
 
 	if err != nil {
-		return fmt.Errorf("操作者不是房间成员")
+		return nil, fmt.Errorf("操作者不是房间成员")
 	}
 
 	// 假设 Role 1=管理员, 2=群主
 	if member.Role < 1 {
-		return fmt.Errorf("只有管理员可以添加成员")
+		return nil, fmt.Errorf("只有管理员可以添加成员")
 	}
 
 	// 去重 + 过滤掉 operator 自己
@@ -561,7 +516,22 @@ func (s *MemberService) AddRoomMember(roomID uint64, userIDs []uint64, operatorI
 		clean = append(clean, uid)
 	}
 	if len(clean) == 0 {
-		return fmt.Errorf("no valid user_ids")
+		return nil, fmt.Errorf("no valid user_ids")
+	}
+
+	// 用户存在性 + 昵称（邀请消息用，含操作者）
+	var users []models.User
+	if err := s.DB.Model(&models.User{}).
+		Select("id, nickname, avatar").
+		Where("id IN ?", append(clean, operatorID)).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	nickname := make(map[uint64]string, len(users))
+	avatar := make(map[uint64]string, len(users))
+	for _, u := range users {
+		nickname[u.ID] = u.Nickname
+		avatar[u.ID] = u.Avatar
 	}
 
 	// 查询已存在的成员，避免唯一索引冲突
@@ -569,67 +539,153 @@ func (s *MemberService) AddRoomMember(roomID uint64, userIDs []uint64, operatorI
 	if err := s.DB.Model(&models.RoomUser{}).
 		Where("room_id = ? AND user_id IN ?", roomID, clean).
 		Pluck("user_id", &existingIDs).Error; err != nil {
-		return err
+		return nil, err
 	}
 	existingSet := make(map[uint64]struct{}, len(existingIDs))
 	for _, id := range existingIDs {
 		existingSet[id] = struct{}{}
 	}
 
-	toAdd := make([]uint64, 0, len(clean))
-	toAddUserInfo := make([]map[string]interface{}, 0, len(clean))
-	for _, uid := range clean {
-		if _, ok := existingSet[uid]; ok {
-			continue
-		}
-		toAdd = append(toAdd, uid)
+	// 与操作者任意方向拉黑（friend.status=2）的用户不能被拉入
+	var blockedRows []models.Friend
+	if err := s.DB.Model(&models.Friend{}).
+		Select("user_id, friend_id").
+		Where("((user_id = ? AND friend_id IN ?) OR (friend_id = ? AND user_id IN ?)) AND status = ?", operatorID, clean, operatorID, clean, 2).
+		Find(&blockedRows).Error; err != nil {
+		return nil, err
 	}
-	if len(toAdd) == 0 {
-		return fmt.Errorf("用户已经是房间成员")
+	blockedSet := make(map[uint64]struct{}, len(blockedRows))
+	for _, f := range blockedRows {
+		if f.UserID == operatorID {
+			blockedSet[f.FriendID] = struct{}{}
+		} else {
+			blockedSet[f.UserID] = struct{}{}
+		}
 	}
 
-	now := time.Now()
-	rows := make([]models.RoomUser, 0, len(toAdd))
-
-	// 批量获取用户头像/昵称（优先在线缓存，未命中再查库）
-	briefMap, err := models.NewUserDAO(s.DB).BatchGetUserBriefsPreferOnline(toAdd, func(userID uint64) (models.UserBrief, bool, error) {
-		if s.OnlineUserGetter == nil {
-			return models.UserBrief{}, false, nil
-		}
-		nn, av, ok := s.OnlineUserGetter(userID)
-		if !ok {
-			return models.UserBrief{}, false, nil
+	status := make(map[uint64]string, len(clean))
+	candidates := make([]uint64, 0, len(clean))
+	for _, uid := range clean {
+		if _, ok := nickname[uid]; !ok {
+			status[uid] = AddMemberUserNotFound
+		} else if _, ok := existingSet[uid]; ok {
+			status[uid] = AddMemberAlreadyMember
+		} else if _, ok := blockedSet[uid]; ok {
+			status[uid] = AddMemberBlocked
+		} else {
+			candidates = append(candidates, uid)
 		}
-		return models.UserBrief{UserID: userID, Nickname: nn, Avatar: av}, true, nil
-	})
-	if err != nil {
-		return err
 	}
 
-	for _, uid := range toAdd {
-		b := briefMap[uid]
-		toAddUserInfo = append(toAddUserInfo, map[string]interface{}{
-			"user_id":  uid,
-			"nickname": b.Nickname,
-			"avatar":   b.Avatar,
-		})
-		rows = append(rows, models.RoomUser{
-			RoomID:    roomID,
-			UserID:    uid,
-			Role:      0, // 普通成员
-			JoinTime:  now,
-			CreatedAt: now,
-			UpdatedAt: now,
+	res := &AddMembersResult{}
+	limit := 0
+	if len(candidates) > 0 {
+		// 反垃圾：每小时拉人数
+		if err := s.AntiSpam.Check(context.Background(), operatorID, SpamActionInvite, len(candidates)); err != nil {
+			return nil, err
+		}
+
+		// 锁住房间行后统计成员数，保证并发拉人也不会超过上限；超出部分按顺序标记 limit_reached
+		err = s.Tx(func(tx *gorm.DB) error {
+			var room models.Room
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("id, type, member_limit").
+				First(&room, roomID).Error; err != nil {
+				return err
+			}
+			toAdd := candidates
+			if room.Type == 2 {
+				var count int64
+				if err := tx.Model(&models.RoomUser{}).Where("room_id = ?", roomID).Count(&count).Error; err != nil {
+					return err
+				}
+				limit = roomMemberLimit(&room)
+				free := limit - int(count)
+				if free < 0 {
+					free = 0
+				}
+				if free < len(toAdd) {
+					for _, uid := range toAdd[free:] {
+						status[uid] = AddMemberLimitReached
+					}
+					toAdd = toAdd[:free]
+				}
+			}
+			if len(toAdd) == 0 {
+				return nil
+			}
+
+			now := time.Now()
+			rows := make([]models.RoomUser, 0, len(toAdd))
+			convs := make([]models.Conversation, 0, len(toAdd))
+			for _, uid := range toAdd {
+				rows = append(rows, models.RoomUser{
+					RoomID:    roomID,
+					UserID:    uid,
+					Role:      0, // 普通成员
+					JoinTime:  now,
+					CreatedAt: now,
+					UpdatedAt: now,
+				})
+				convs = append(convs, models.Conversation{UserID: uid, RoomID: roomID, IsVisible: true, CreatedAt: now, UpdatedAt: now})
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+			// 新成员的会话（退群后重新加入则恢复展示、清空旧未读）
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "room_id"}},
+				DoUpdates: clause.Assignments(map[string]any{"is_visible": true, "unread_count": 0, "updated_at": now}),
+			}).Create(&convs).Error; err != nil {
+				return err
+			}
+
+			// 一条邀请系统消息，与入群同事务
+			msg, err := recordSystemMessage(tx, s.messageService, roomID, message.SystemInfo{
+				Event:     EventRoomMemberAdded,
+				ActorID:   operatorID,
+				TargetIDs: toAdd,
+			}, nickname)
+			if err != nil {
+				return err
+			}
+			for _, uid := range toAdd {
+				status[uid] = AddMemberOK
+			}
+			res.Added = toAdd
+			res.SystemMessage = msg
+			return nil
 		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// 批量写入
-	if err := s.DB.Create(&rows).Error; err != nil {
-		return err
+	limitReached := false
+	for _, uid := range clean {
+		res.Results = append(res.Results, AddMemberResult{UserID: uid, Status: status[uid]})
+		if status[uid] == AddMemberLimitReached {
+			limitReached = true
+		}
 	}
+	if len(res.Added) == 0 {
+		if limitReached {
+			return res, fmt.Errorf("%w（上限 %d 人）", ErrRoomFull, limit)
+		}
+		return res, nil
+	}
+	s.roomMembersChanged(roomID, res.Added, true)
 
 	// 通知（尽力而为：落库 + WS）
 	if s.Notify != nil {
+		toAddUserInfo := make([]map[string]interface{}, 0, len(res.Added))
+		for _, uid := range res.Added {
+			toAddUserInfo = append(toAddUserInfo, map[string]interface{}{
+				"user_id":  uid,
+				"nickname": nickname[uid],
+				"avatar":   avatar[uid],
+			})
+		}
 		var members []uint64
 		_ = s.DB.Model(&models.RoomUser{}).Where("room_id = ?", roomID).Pluck("user_id", &members).Error
 		_, _ = s.Notify.PublishRoomEvent(
@@ -642,51 +698,55 @@ func (s *MemberService) AddRoomMember(roomID uint64, userIDs []uint64, operatorI
 		)
 	}
 
-	return nil
+	return res, nil
 }
 
 // RemoveRoomMember 从房间移除成员
 func (s *MemberService) RemoveRoomMember(roomID uint64, userID uint64, operatorID uint64) error {
 	// 事务：移除成员 + 隐藏该成员会话
-	tx := s.DB.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-	defer tx.Rollback()
+	var target models.RoomUser
+	var removed bool
+	err := s.Tx(func(tx *gorm.DB) error {
+		// 检查操作者是否是管理员
+		var operator models.RoomUser
+		err := tx.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id = ?", roomID, operatorID).
+			First(&operator).Error
+
+		if err != nil {
+			return fmt.Errorf("操作者不是房间成员")
+		}
 
-	// 检查操作者是否是管理员
-	var operator models.RoomUser
-	err := tx.Model(&models.RoomUser{}).
-		Where("room_id = ? AND user_id = ?", roomID, operatorID).
-		First(&operator).Error
+		if operator.Role < 1 {
+			return fmt.Errorf("只有管理员可以移除成员")
+		}
 
-	if err != nil {
-		return fmt.Errorf("操作者不是房间成员")
-	}
+		target = models.RoomUser{}
+		_ = tx.Select("role").Where("room_id = ? AND user_id = ?", roomID, userID).Take(&target).Error
 
-	if operator.Role < 1 {
-		return fmt.Errorf("只有管理员可以移除成员")
-	}
+		// 删除成员（幂等：如果目标已不在群里，RowsAffected=0 直接返回 nil，不再重复通知）
+		res := tx.Where("room_id = ? AND user_id = ?", roomID, userID).
+			Delete(&models.RoomUser{})
+		if res.Error != nil {
+			return res.Error
+		}
+		removed = res.RowsAffected > 0
+		if !removed {
+			// 目标用户已不在群里（可能已被踢/已退出）
+			return nil
+		}
 
-	// 删除成员（幂等：如果目标已不在群里，RowsAffected=0 直接返回 nil，不再重复通知）
-	res := tx.Where("room_id = ? AND user_id = ?", roomID, userID).
-		Delete(&models.RoomUser{})
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		// 目标用户已不在群里（可能已被踢/已退出）
+		// 隐藏该成员的会话（从消息列表不展示）
+		_ = tx.Model(&models.Conversation{}).
+			Where("user_id = ? AND room_id = ?", userID, roomID).
+			Updates(map[string]any{"is_visible": false, "updated_at": time.Now()}).Error
 		return nil
-	}
-
-	// 隐藏该成员的会话（从消息列表不展示）
-	_ = tx.Model(&models.Conversation{}).
-		Where("user_id = ? AND room_id = ?", userID, roomID).
-		Updates(map[string]any{"is_visible": false, "updated_at": time.Now()}).Error
-
-	if err := tx.Commit().Error; err != nil {
+	})
+	if err != nil || !removed {
 		return err
 	}
+	s.roomMembersChanged(roomID, []uint64{userID}, false)
+	s.auditRoom(roomID, operatorID, EventRoomMemberRemoved, userID, map[string]any{"role": target.Role}, nil)
 
 	// 通知（尽力而为：落库 + WS）
 	if s.Notify != nil {
@@ -701,6 +761,93 @@ func (s *MemberService) RemoveRoomMember(roomID uint64, userID uint64, operatorI
 			true,
 		)
 	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{Event: EventRoomMemberRemoved, ActorID: operatorID, TargetIDs: []uint64{userID}})
 
 	return nil
 }
+
+// ensureFriendRoom 确保好友双方的私聊房间存在（使用规则生成 RoomAccount），并让双方会话可见。
+// 新建房间时返回其 ID，房间已存在时返回 0（提交后用于成员变动通知）。
+func ensureFriendRoom(tx *gorm.DB, from, to uint64, now time.Time) (uint64, error) {
+	roomAccount := generatePrivateRoomAccount(from, to)
+
+	// 检查房间是否已存在
+	var existingRoom models.Room
+	err := tx.Where("room_account = ?", roomAccount).First(&existingRoom).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	// 如果房间不存在，则创建
+	var newRoomID uint64
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		room := &models.Room{
+			RoomAccount: roomAccount,
+			Type:        1, // 1-私聊
+			CreatorID:   from,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := tx.Create(room).Error; err != nil {
+			return 0, err
+		}
+
+		// 添加房间成员
+		members := []models.RoomUser{
+			{
+				RoomID:    room.ID,
+				UserID:    from,
+				Role:      0,
+				JoinTime:  now,
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			{
+				RoomID:    room.ID,
+				UserID:    to,
+				Role:      0,
+				JoinTime:  now,
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+		}
+		if err := tx.Create(&members).Error; err != nil {
+			return 0, err
+		}
+		newRoomID = room.ID
+
+		// 新建房间时：确保双方会话可见
+		for _, uid := range []uint64{from, to} {
+			conv := &models.Conversation{UserID: uid, RoomID: room.ID}
+			if err := tx.FirstOrCreate(conv, map[string]any{"user_id": uid, "room_id": room.ID}).Error; err != nil {
+				return 0, err
+			}
+			if err := tx.Model(&models.Conversation{}).
+				Where("user_id = ? AND room_id = ?", uid, room.ID).
+				Updates(map[string]any{"is_visible": true, "updated_at": now}).Error; err != nil {
+				return 0, err
+			}
+		}
+	} else {
+		// 房间已存在（通常是删好友后再加回来）：解除发送限制，并确保双方会话重新展示
+		if existingRoom.IsOrphaned {
+			if err := tx.Model(&models.Room{}).
+				Where("id = ?", existingRoom.ID).
+				Updates(map[string]any{"is_orphaned": false, "updated_at": now}).Error; err != nil {
+				return 0, err
+			}
+		}
+		for _, uid := range []uint64{from, to} {
+			conv := &models.Conversation{UserID: uid, RoomID: existingRoom.ID}
+			if err := tx.FirstOrCreate(conv, map[string]any{"user_id": uid, "room_id": existingRoom.ID}).Error; err != nil {
+				return 0, err
+			}
+			if err := tx.Model(&models.Conversation{}).
+				Where("user_id = ? AND room_id = ?", uid, existingRoom.ID).
+				Updates(map[string]any{"is_visible": true, "updated_at": now}).Error; err != nil {
+				return 0, err
+			}
+		}
+	}
+	return newRoomID, nil
+}
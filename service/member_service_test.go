@@ -1,10 +1,14 @@
 package service
 
 import (
+	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
 )
 
 func TestMemberService_SearchUsers(t *testing.T) {
@@ -18,8 +22,8 @@ func TestMemberService_SearchUsers(t *testing.T) {
 	limit := 10
 
 	// SearchUsers(keyword="bo", currentUserID=1, limit=10)
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, nickname, avatar FROM `im_user` WHERE id <> ? AND (username LIKE ? OR nickname LIKE ? OR uid LIKE ?) AND `im_user`.`deleted_at` IS NULL ORDER BY id DESC LIMIT ?")).
-		WithArgs(int64(1), "%bo%", "%bo%", "%bo%", limit).
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, nickname, avatar FROM `im_user` WHERE id <> ? AND (((username LIKE ? OR nickname LIKE ? OR uid LIKE ?) AND id NOT IN (SELECT user_id FROM im_user_privacy WHERE search_mode <> ?)) OR ((uid = ? OR username = ?) AND id NOT IN (SELECT user_id FROM im_user_privacy WHERE search_mode = ?)) OR (phone = ? AND id IN (SELECT user_id FROM im_user_privacy WHERE allow_phone_search = ? AND search_mode <> ?))) AND `im_user`.`deleted_at` IS NULL ORDER BY id DESC LIMIT ?")).
+		WithArgs(int64(1), "%bo%", "%bo%", "%bo%", 0, "bo", "bo", 2, "bo", true, 2, limit).
 		WillReturnRows(rows)
 
 	users, err := ms.SearchUsers("bo", 1, limit)
@@ -34,3 +38,345 @@ func TestMemberService_SearchUsers(t *testing.T) {
 		t.Fatalf("sql expectations: %v", err)
 	}
 }
+
+func TestMemberService_DeleteFriend_Policy(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	var notified []uint64
+	ms := NewMemberService(&Service{DB: gormDB, TablePrefix: "im_", WsNotifier: func(userID uint64, _ []byte) {
+		notified = append(notified, userID)
+	}})
+	ms.FriendDeletePolicy = FriendDeletePolicy{HideConversation: true, BlockPrivateSend: true}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `im_friend`").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT `id` FROM `im_room` WHERE \\(room_account = \\? AND type = \\?\\)").
+		WithArgs(generatePrivateRoomAccount(1, 2), 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("UPDATE `im_conversation` SET `is_visible`=\\?,`updated_at`=\\? WHERE room_id = \\? AND user_id IN \\(\\?,\\?\\)").
+		WithArgs(false, sqlmock.AnyArg(), 9, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE `im_room` SET `is_orphaned`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(true, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := ms.DeleteFriend(1, 2); err != nil {
+		t.Fatalf("DeleteFriend: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+	if len(notified) != 2 {
+		t.Fatalf("notified=%v", notified)
+	}
+
+	// 默认策略只隐藏会话，不标记房间
+	ms.FriendDeletePolicy = DefaultFriendDeletePolicy
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `im_friend`").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT `id` FROM `im_room`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("UPDATE `im_conversation` SET `is_visible`=\\?").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := ms.DeleteFriend(1, 2); err != nil {
+		t.Fatalf("DeleteFriend default: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestMemberService_AddRoomMember_RoomFull(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	ms := NewMemberService(&Service{DB: gormDB, TablePrefix: "im_"})
+
+	mock.ExpectQuery("SELECT \\* FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "user_id", "role"}).AddRow(1, 5, 1, 2))
+	mock.ExpectQuery("SELECT id, nickname, avatar FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "avatar"}).AddRow(1, "A", "").AddRow(2, "B", "").AddRow(3, "C", ""))
+	mock.ExpectQuery("SELECT `user_id` FROM `im_room_user` WHERE room_id = \\? AND user_id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery("SELECT user_id, friend_id FROM `im_friend`").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "friend_id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, type, member_limit FROM `im_room` WHERE .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "member_limit"}).AddRow(5, 2, 3))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_room_user` WHERE room_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectCommit()
+
+	res, err := ms.AddRoomMember(5, []uint64{2, 3}, 1)
+	if !errors.Is(err, ErrRoomFull) {
+		t.Fatalf("expected ErrRoomFull, got %v", err)
+	}
+	if res == nil || len(res.Results) != 2 || res.Results[0].Status != AddMemberLimitReached {
+		t.Fatalf("res=%+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestMemberService_AddRoomMember_PartialResults(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	ms := NewMemberService(&Service{DB: gormDB, TablePrefix: "im_"})
+
+	mock.ExpectQuery("SELECT \\* FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "user_id", "role"}).AddRow(1, 5, 1, 2))
+	// 5 不存在
+	mock.ExpectQuery("SELECT id, nickname, avatar FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "avatar"}).
+			AddRow(1, "A", "").AddRow(2, "B", "").AddRow(3, "C", "").AddRow(4, "D", "").AddRow(6, "F", ""))
+	// 2 已是成员
+	mock.ExpectQuery("SELECT `user_id` FROM `im_room_user` WHERE room_id = \\? AND user_id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+	// 3 拉黑了操作者
+	mock.ExpectQuery("SELECT user_id, friend_id FROM `im_friend`").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "friend_id"}).AddRow(3, 1))
+	mock.ExpectBegin()
+	// 只剩 1 个名额：4 入群，6 超出上限
+	mock.ExpectQuery("SELECT id, type, member_limit FROM `im_room` WHERE .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "member_limit"}).AddRow(5, 2, 4))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_room_user` WHERE room_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectExec("INSERT INTO `im_room_user`").WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec("INSERT INTO `im_conversation` .* ON DUPLICATE KEY UPDATE").WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec("UPDATE `im_room` SET `message_seq`=message_seq \\+ \\? WHERE id = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT `message_seq` FROM `im_room` WHERE id = \\?").WillReturnRows(sqlmock.NewRows([]string{"message_seq"}).AddRow(8))
+	mock.ExpectExec("INSERT INTO `im_message`").
+		WithArgs(5, 1, 8, nil, 1, "A 邀请 D 加入了群聊", sqlmock.AnyArg(), true, false, false, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(100, 1))
+	mock.ExpectExec("UPDATE `im_room` SET `last_message_id`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `im_conversation` SET").WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectCommit()
+
+	res, err := ms.AddRoomMember(5, []uint64{2, 3, 4, 5, 6, 1}, 1)
+	if err != nil {
+		t.Fatalf("AddRoomMember: %v", err)
+	}
+	want := map[uint64]string{2: AddMemberAlreadyMember, 3: AddMemberBlocked, 4: AddMemberOK, 5: AddMemberUserNotFound, 6: AddMemberLimitReached}
+	if len(res.Results) != len(want) {
+		t.Fatalf("results=%+v", res.Results)
+	}
+	for _, r := range res.Results {
+		if want[r.UserID] != r.Status {
+			t.Fatalf("user %d: status=%s want %s", r.UserID, r.Status, want[r.UserID])
+		}
+	}
+	if len(res.Added) != 1 || res.Added[0] != 4 || res.SystemMessage == nil || res.SystemMessage.ID != 100 {
+		t.Fatalf("res=%+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestMemberService_RemoveRoomMember_RoomMembersChanged(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	type change struct {
+		roomID uint64
+		users  []uint64
+		joined bool
+	}
+	var changes []change
+	ms := NewMemberService(&Service{DB: gormDB, TablePrefix: "im_", RoomMembersChanged: func(roomID uint64, userIDs []uint64, joined bool) {
+		changes = append(changes, change{roomID, userIDs, joined})
+	}})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(5, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "user_id", "role"}).AddRow(5, 1, 2))
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user` WHERE room_id = \\? AND user_id = \\? LIMIT \\?").
+		WithArgs(5, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(1))
+	mock.ExpectExec("DELETE FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(5, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `im_conversation`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `im_room_audit_log`").
+		WithArgs(5, 1, EventRoomMemberRemoved, 2, `{"role":1}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := ms.RemoveRoomMember(5, 2, 1); err != nil {
+		t.Fatalf("RemoveRoomMember: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+	if len(changes) != 1 || changes[0].roomID != 5 || len(changes[0].users) != 1 || changes[0].users[0] != 2 || changes[0].joined {
+		t.Fatalf("changes=%#v", changes)
+	}
+}
+
+func TestService_NotifyUsers(t *testing.T) {
+	var single []uint64
+	s := &Service{WsNotifier: func(userID uint64, _ []byte) { single = append(single, userID) }}
+	s.notifyUsers([]uint64{1, 2}, []byte("x"))
+	if len(single) != 2 {
+		t.Fatalf("fallback to WsNotifier: %v", single)
+	}
+
+	var batches [][]uint64
+	s.WsBatchNotifier = func(userIDs []uint64, _ []byte) { batches = append(batches, userIDs) }
+	s.notifyUsers([]uint64{3, 4}, []byte("x"))
+	s.notifyUsers(nil, []byte("x"))
+	if len(batches) != 1 || len(batches[0]) != 2 || len(single) != 2 {
+		t.Fatalf("batch=%v single=%v", batches, single)
+	}
+}
+
+func TestService_Emit(t *testing.T) {
+	got := map[uint64]string{}
+	s := &Service{WsNotifier: func(userID uint64, b []byte) { got[userID] = string(b) }}
+	s.Emit([]uint64{7}, &message.FriendAcceptedEvent{RequestID: 3, UserID: 9})
+	if want := `{"type":"friend_accepted","request_id":3,"user_id":9}`; got[7] != want {
+		t.Fatalf("emit = %s, want %s", got[7], want)
+	}
+
+	s.AdminUserIDs = []uint64{1}
+	s.NotifyAdmins(EventAdminAppeal, map[string]int{"id": 5})
+	if want := `{"type":"admin.appeal","data":{"id":5}}`; got[1] != want {
+		t.Fatalf("admin event = %s, want %s", got[1], want)
+	}
+
+	// 每种固定类型的事件都有 schema，且 type 为常量
+	schemas := message.EventSchemas()
+	if len(schemas) != len(message.EventCatalog) {
+		t.Fatalf("schemas = %d, catalog = %d", len(schemas), len(message.EventCatalog))
+	}
+	props := schemas[EventFriendRequest]["properties"].(map[string]any)
+	if props["type"].(map[string]any)["const"] != EventFriendRequest || props["request_id"] == nil {
+		t.Fatalf("friend_request schema = %v", props)
+	}
+}
+
+func TestMemberService_ExpireFriendRequests(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	got := map[uint64]string{}
+	ms := NewMemberService(&Service{DB: gormDB, WsNotifier: func(userID uint64, b []byte) { got[userID] = string(b) }})
+	ms.FriendRequestTTL = 7 * 24 * time.Hour
+	before := time.Now().Add(-ms.FriendRequestTTL)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`,`from_user_id`,`to_user_id` FROM `im_friend_apply` WHERE status = ? AND created_at < ? ORDER BY id ASC LIMIT ?")).
+		WithArgs(models.StatusPending, before, friendRequestExpireBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "from_user_id", "to_user_id"}).AddRow(3, 10, 20).AddRow(4, 11, 20))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `im_friend_apply` SET `processed_at`=?,`status`=?,`updated_at`=? WHERE id = ? AND status = ?")).
+		WithArgs(sqlmock.AnyArg(), models.StatusExpired, sqlmock.AnyArg(), 3, models.StatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 4 已被同意：条件更新不命中，不通知
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `im_friend_apply`")).
+		WithArgs(sqlmock.AnyArg(), models.StatusExpired, sqlmock.AnyArg(), 4, models.StatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := ms.ExpireFriendRequests(before)
+	if err != nil || n != 1 {
+		t.Fatalf("ExpireFriendRequests: n=%d err=%v", n, err)
+	}
+	if want := `{"type":"friend_expired","request_id":3,"user_id":20}`; got[10] != want || len(got) != 1 {
+		t.Fatalf("notified = %v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+
+	if !ms.friendRequestExpired(&models.FriendApply{Status: models.StatusPending, CreatedAt: before.Add(-time.Minute)}) ||
+		ms.friendRequestExpired(&models.FriendApply{Status: models.StatusPending, CreatedAt: time.Now()}) ||
+		ms.friendRequestExpired(&models.FriendApply{Status: models.StatusAgreed, CreatedAt: before.Add(-time.Minute)}) {
+		t.Fatal("friendRequestExpired")
+	}
+}
+
+func TestMemberService_MutualFriendsAndCommonGroups(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	ms := NewMemberService(&Service{DB: gormDB})
+
+	blockQuery := regexp.QuoteMeta("SELECT count(*) FROM `im_friend` WHERE ((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)) AND status = ?")
+	mock.ExpectQuery(blockQuery).WithArgs(1, 2, 2, 1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM im_friend AS a JOIN im_friend AS b ON b.friend_id = a.friend_id AND b.user_id = ? AND b.status = ? WHERE a.user_id = ? AND a.status = ? AND a.friend_id <> ?")).
+		WithArgs(2, 1, 1, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT u.id, u.username, u.nickname, u.avatar FROM im_friend AS a JOIN im_friend AS b ON b.friend_id = a.friend_id AND b.user_id = ? AND b.status = ? JOIN im_user AS u ON u.id = a.friend_id AND u.deleted_at IS NULL WHERE a.user_id = ? AND a.status = ? AND a.friend_id <> ? ORDER BY u.id ASC LIMIT ?")).
+		WithArgs(2, 1, 1, 1, 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "nickname", "avatar"}).AddRow(5, "e", "E", "").AddRow(6, "f", "F", ""))
+
+	mutual, err := ms.GetMutualFriends(1, 2, 2)
+	if err != nil || mutual.Count != 3 || len(mutual.Friends) != 2 || mutual.Friends[1].ID != 6 {
+		t.Fatalf("GetMutualFriends: %+v %v", mutual, err)
+	}
+
+	mock.ExpectQuery(blockQuery).WithArgs(1, 2, 2, 1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT r.id, r.room_account, r.name, r.avatar FROM im_room_user AS a JOIN im_room_user AS b ON b.room_id = a.room_id AND b.user_id = ? JOIN im_room AS r ON r.id = a.room_id AND r.type = ? AND r.deleted_at IS NULL WHERE a.user_id = ? ORDER BY r.updated_at DESC LIMIT ?")).
+		WithArgs(2, 2, 1, MutualListLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_account", "name", "avatar"}).AddRow(9, "100009", "g", ""))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT room_id, COUNT(1) AS cnt FROM `im_room_user` WHERE room_id IN (?) GROUP BY `room_id`")).
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cnt"}).AddRow(9, 12))
+
+	groups, err := ms.GetCommonGroups(1, 2, 0)
+	if err != nil || len(groups) != 1 || groups[0].MemberCount != 12 {
+		t.Fatalf("GetCommonGroups: %+v %v", groups, err)
+	}
+
+	// 被拉黑时不查询
+	mock.ExpectQuery(blockQuery).WithArgs(1, 2, 2, 1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	if groups, err := ms.GetCommonGroups(1, 2, 0); err != nil || len(groups) != 0 {
+		t.Fatalf("blocked: %+v %v", groups, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSortFriendsByName(t *testing.T) {
+	dtos := []UserDTO{
+		{ID: 1, Nickname: "张三"},
+		{ID: 2, Nickname: "bob"},
+		{ID: 3, Nickname: "李四", Remark: "阿强"},
+		{ID: 4, Username: "123"},
+		{ID: 5, Nickname: "Alice"},
+		{ID: 6, Nickname: "白云"},
+		{ID: 7, Nickname: "zoe"},
+	}
+	sortFriendsByName(dtos)
+	want := []uint64{5, 3, 2, 6, 7, 1, 4} // Alice, 阿强, bob, 白云, zoe, 张三, 123
+	for i, d := range dtos {
+		if d.ID != want[i] {
+			t.Fatalf("order[%d]=%d want %d (%+v)", i, d.ID, want[i], dtos)
+		}
+	}
+}
+
+func TestMemberService_SetFriendStar(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	ms := NewMemberService(&Service{DB: gormDB})
+
+	update := regexp.QuoteMeta("UPDATE `im_friend` SET `is_star`=?,`updated_at`=? WHERE user_id = ? AND friend_id = ? AND status = ?")
+	mock.ExpectExec(update).WithArgs(true, sqlmock.AnyArg(), 1, 2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := ms.SetFriendStar(1, 2, true); err != nil {
+		t.Fatalf("SetFriendStar: %v", err)
+	}
+
+	mock.ExpectExec(update).WithArgs(false, sqlmock.AnyArg(), 1, 3, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := ms.SetFriendStar(1, 3, false); err == nil {
+		t.Fatal("expected error for non-friend")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// messageColdBatch 每批搬到冷表的消息数，避免长事务锁表
+const messageColdBatch = 500
+
+func (s *MessageService) coldEnabled() bool {
+	return s.ColdAfter > 0
+}
+
+// findVisible viewer 可见的消息，先查热表，不足 limit 时再查冷表补齐（冷表中的消息都早于热表）；
+// coldFirst 用于按时间正序向后取的场景（先冷后热）。未开启冷表时只查热表。
+func (s *MessageService) findVisible(roomID, viewerID uint64, scope func(*gorm.DB) *gorm.DB, order string, limit int, coldFirst bool) ([]models.Message, error) {
+	tiers := []bool{false, true}
+	if coldFirst {
+		tiers = []bool{true, false}
+	}
+	var out []models.Message
+	for _, cold := range tiers {
+		if len(out) >= limit {
+			break
+		}
+		if cold && !s.coldEnabled() {
+			continue
+		}
+		q := scope(s.visibleMessages(roomID, viewerID, cold)).Order(order).Limit(limit - len(out))
+		if !cold {
+			var rows []models.Message
+			if err := q.Find(&rows).Error; err != nil {
+				return nil, err
+			}
+			out = append(out, rows...)
+			continue
+		}
+		var rows []models.MessageCold
+		if err := q.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			out = append(out, rows[i].ToMessage())
+		}
+	}
+	return out, nil
+}
+
+// findColdByID 在冷表中按 ID 查消息
+func (s *MessageService) findColdByID(messageID uint64) (*models.Message, error) {
+	var row models.MessageCold
+	if err := s.DB.Where("id = ?", messageID).First(&row).Error; err != nil {
+		return nil, err
+	}
+	msg := row.ToMessage()
+	return &msg, nil
+}
+
+// ArchiveColdMessages 把 before 之前的消息（含已软删的）整行搬到冷表，返回搬运条数。
+// 房间的最后一条消息留在热表，保证会话列表不受影响；每批在一个事务中先写冷表再删热表，
+// 单批失败直接返回，已搬运的批次不回滚。回执（message_status）不搬运，仍按 message_id 关联。
+func (s *MessageService) ArchiveColdMessages(before time.Time) (int64, error) {
+	roomTable := models.Room{}.TableName()
+	var total int64
+	for {
+		var msgs []models.Message
+		if err := s.DB.Unscoped().
+			Where("created_at < ?", before).
+			Where("id NOT IN (SELECT last_message_id FROM " + roomTable + " WHERE last_message_id IS NOT NULL)").
+			Order("id ASC").
+			Limit(messageColdBatch).
+			Find(&msgs).Error; err != nil {
+			return total, err
+		}
+		if len(msgs) == 0 {
+			return total, nil
+		}
+		ids := make([]uint64, 0, len(msgs))
+		rows := make([]models.MessageCold, 0, len(msgs))
+		for i := range msgs {
+			ids = append(ids, msgs[i].ID)
+			rows = append(rows, models.NewMessageCold(&msgs[i]))
+		}
+		err := s.Tx(func(tx *gorm.DB) error {
+			// 冷表中已存在（上次搬运后删除热表失败）时保留已有行
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Message{}).Error
+		})
+		if err != nil {
+			return total, err
+		}
+		total += int64(len(msgs))
+		if len(msgs) < messageColdBatch {
+			return total, nil
+		}
+	}
+}
+
+// purgeColdBefore 物理删除冷表中 before 之前的消息及其回执（消息保留期清理的一部分）
+func (s *MessageService) purgeColdBefore(before time.Time) (int64, error) {
+	var total int64
+	for {
+		var rows []models.MessageCold
+		if err := s.DB.Unscoped().
+			Where("created_at < ?", before).
+			Order("id ASC").
+			Limit(messagePurgeBatch).
+			Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		ids := make([]uint64, 0, len(rows))
+		msgs := make([]models.Message, 0, len(rows))
+		for i := range rows {
+			ids = append(ids, rows[i].ID)
+			msgs = append(msgs, rows[i].ToMessage())
+		}
+		if err := s.Archive.record(s.DB, ArchiveEventPurged, 0, msgs); err != nil {
+			return total, err
+		}
+		if err := s.DB.Where("message_id IN ?", ids).Delete(&models.MessageStatus{}).Error; err != nil {
+			return total, err
+		}
+		res := s.DB.Unscoped().Where("id IN ?", ids).Delete(&models.MessageCold{})
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if len(rows) < messagePurgeBatch {
+			return total, nil
+		}
+	}
+}
+
+// RunColdArchiveLoop 定时把超过 ColdAfter 的消息搬到冷表（阻塞，engine 中以 goroutine 启动）
+func (s *MessageService) RunColdArchiveLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.ArchiveColdMessages(time.Now().Add(-s.ColdAfter))
+		if err != nil {
+			log.Printf("message cold archive loop: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("message cold archive: moved %d messages", n)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestMessageService_ArchiveColdMessages(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewMessageService(&Service{DB: gormDB})
+	before := time.Now().Add(-90 * 24 * time.Hour)
+
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE created_at < \\? AND id NOT IN \\(SELECT last_message_id FROM im_room WHERE last_message_id IS NOT NULL\\) ORDER BY id ASC LIMIT \\?").
+		WithArgs(before, messageColdBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "type", "content", "status", "created_at"}).
+			AddRow(3, 1, 2, 1, "a", 1, before.Add(-time.Hour)).
+			AddRow(5, 1, 2, 1, "b", 4, before.Add(-time.Minute)))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `im_message_cold` .* ON DUPLICATE KEY UPDATE `id`=`id`").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM `im_message` WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(3, 5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	n, err := svc.ArchiveColdMessages(before)
+	if err != nil || n != 2 {
+		t.Fatalf("ArchiveColdMessages: n=%d err=%v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMessageService_GetRoomMessagesDTO_ColdFallback(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewMessageService(&Service{DB: gormDB})
+	svc.ColdAfter = 90 * 24 * time.Hour
+
+	cols := []string{"id", "room_id", "sender_id", "content", "status"}
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE .* AND id < \\? AND `im_message`.`deleted_at` IS NULL ORDER BY created_at DESC LIMIT \\?").
+		WithArgs(1, models.MessageStatusBothDeleted, 3, true, 100, 3).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(99, 1, 2, "hot", 1))
+	mock.ExpectQuery("SELECT \\* FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT \\* FROM `im_message_cold` WHERE \\(room_id = \\? AND status <> \\?\\) AND \\(NOT EXISTS \\(SELECT 1 FROM im_message_status AS ms "+
+		"WHERE ms.message_id = im_message_cold.id .*\\)\\) AND id < \\? AND `im_message_cold`.`deleted_at` IS NULL ORDER BY created_at DESC LIMIT \\?").
+		WithArgs(1, models.MessageStatusBothDeleted, 3, true, 100, 2).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(12, 1, 2, "cold", 1))
+	mock.ExpectQuery("SELECT \\* FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	list, err := svc.GetRoomMessagesDTO(1, 3, 3, 100)
+	if err != nil {
+		t.Fatalf("GetRoomMessagesDTO: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != 99 || list[1].ID != 12 || list[1].Content != "cold" {
+		t.Fatalf("list: %+v", list)
+	}
+
+	// 热表找不到时按 ID 查冷表
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE id = \\?").
+		WithArgs(12, 1).
+		WillReturnRows(sqlmock.NewRows(cols))
+	mock.ExpectQuery("SELECT \\* FROM `im_message_cold` WHERE id = \\?").
+		WithArgs(12, 1).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(12, 1, 2, "cold", 1))
+	msg, err := svc.GetMessageByID(12)
+	if err != nil || msg.Content != "cold" {
+		t.Fatalf("GetMessageByID: %+v %v", msg, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
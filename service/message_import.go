@@ -0,0 +1,224 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ImportMessagesMax 单次导入的最大条数，更多消息请分批（按时间顺序）导入
+const ImportMessagesMax = 1000
+
+var (
+	// ErrImportTooMany 单次导入超过 ImportMessagesMax 条
+	ErrImportTooMany = newError(response.CodeParamError, "err.import_too_many", ImportMessagesMax)
+	// ErrImportExternalID external_id 为空或过长，参数为消息序号（从 1 开始）
+	ErrImportExternalID = newError(response.CodeParamError, "err.import_external_id")
+	// ErrImportExternalIDDuplicate 同一批次 external_id 重复
+	ErrImportExternalIDDuplicate = newError(response.CodeParamError, "err.import_external_id_duplicate")
+	// ErrImportSenderRequired 缺少 sender_id
+	ErrImportSenderRequired = newError(response.CodeParamError, "err.import_sender_required")
+	// ErrImportSentAtRequired 缺少 sent_at
+	ErrImportSentAtRequired = newError(response.CodeParamError, "err.import_sent_at_required")
+	// ErrImportSenderNotFound 发送者不存在
+	ErrImportSenderNotFound = newError(response.CodeParamError, "err.import_sender_not_found")
+)
+
+// ImportedMessage 从其他 IM 迁移过来的一条历史消息
+type ImportedMessage struct {
+	ExternalID string        `json:"external_id"` // 原系统消息 ID，同一房间内去重
+	SenderID   uint64        `json:"sender_id"`   // 本系统的用户 ID（调用方先完成用户映射）
+	Type       uint8         `json:"type"`        // 消息类型，默认 1-文本
+	Content    string        `json:"content"`
+	Extra      message.Extra `json:"extra"`
+	IsSystem   bool          `json:"is_system"`
+	SentAt     time.Time     `json:"sent_at"` // 原始发送时间，写入 created_at
+	// ReplyToExternalID 回复的原系统消息 ID（同一房间内已导入或本批次中的消息），找不到时忽略
+	ReplyToExternalID string `json:"reply_to_external_id,omitempty"`
+}
+
+// ImportMessagesResult 导入结果
+type ImportMessagesResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"` // external_id 已导入过
+	// MessageIDs external_id -> 本系统消息 ID（含跳过的）
+	MessageIDs map[string]uint64 `json:"message_ids"`
+}
+
+// ImportMessages 导入房间历史消息（迁移用）：
+//   - 不校验账号状态、禁言与发消息频率，不推送、不增加未读数，发送者无需是当前成员；
+//   - 按 sent_at 排序后写入（相同时间保持传入顺序），created_at 使用原始时间；
+//   - 按 (room_id, external_id) 去重，重复导入只返回已有的消息 ID，可安全重试。
+//
+// 消息 ID 按导入顺序递增，房间已有新消息后再导入更早的历史，按 ID 翻页的顺序会与时间不一致，建议上线前导入。
+func (s *MessageService) ImportMessages(roomID uint64, msgs []ImportedMessage) (*ImportMessagesResult, error) {
+	if len(msgs) > ImportMessagesMax {
+		return nil, ErrImportTooMany
+	}
+	res := &ImportMessagesResult{MessageIDs: make(map[string]uint64, len(msgs))}
+	if len(msgs) == 0 {
+		return res, nil
+	}
+
+	externalIDs := make([]string, 0, len(msgs))
+	senders := make(map[uint64]struct{})
+	seen := make(map[string]bool, len(msgs))
+	for i := range msgs {
+		m := &msgs[i]
+		m.ExternalID = strings.TrimSpace(m.ExternalID)
+		switch {
+		case m.ExternalID == "" || len(m.ExternalID) > 128:
+			return nil, newError(response.CodeParamError, "err.import_external_id", i+1)
+		case seen[m.ExternalID]:
+			return nil, newError(response.CodeParamError, "err.import_external_id_duplicate", m.ExternalID)
+		case m.SenderID == 0:
+			return nil, newError(response.CodeParamError, "err.import_sender_required", i+1)
+		case m.SentAt.IsZero():
+			return nil, newError(response.CodeParamError, "err.import_sent_at_required", i+1)
+		}
+		if m.Type == 0 {
+			m.Type = 1
+		}
+		seen[m.ExternalID] = true
+		externalIDs = append(externalIDs, m.ExternalID)
+		if m.ReplyToExternalID != "" && !seen[m.ReplyToExternalID] {
+			externalIDs = append(externalIDs, m.ReplyToExternalID)
+		}
+		senders[m.SenderID] = struct{}{}
+	}
+
+	err := s.Tx(func(tx *gorm.DB) error {
+		// 重试时从头统计
+		*res = ImportMessagesResult{MessageIDs: make(map[string]uint64, len(msgs))}
+		var room models.Room
+		if err := tx.Select("id", "last_message_id").First(&room, roomID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoomNotFound
+			}
+			return err
+		}
+
+		senderIDs := make([]uint64, 0, len(senders))
+		for id := range senders {
+			senderIDs = append(senderIDs, id)
+		}
+		var found []uint64
+		if err := tx.Model(&models.User{}).Where("id IN ?", senderIDs).Pluck("id", &found).Error; err != nil {
+			return err
+		}
+		if len(found) != len(senderIDs) {
+			exists := make(map[uint64]bool, len(found))
+			for _, id := range found {
+				exists[id] = true
+			}
+			for _, id := range senderIDs {
+				if !exists[id] {
+					return newError(response.CodeParamError, "err.import_sender_not_found", id)
+				}
+			}
+		}
+
+		// 已导入过的消息（含被回复的），localIDs 随导入过程补充本批次的映射
+		var refs []models.MessageImportRef
+		if err := tx.Where("room_id = ? AND external_id IN ?", roomID, externalIDs).Find(&refs).Error; err != nil {
+			return err
+		}
+		localIDs := make(map[string]uint64, len(refs)+len(msgs))
+		for _, r := range refs {
+			localIDs[r.ExternalID] = r.MessageID
+		}
+
+		ordered := make([]*ImportedMessage, 0, len(msgs))
+		for i := range msgs {
+			if id, ok := localIDs[msgs[i].ExternalID]; ok {
+				res.MessageIDs[msgs[i].ExternalID] = id
+				res.Skipped++
+				continue
+			}
+			ordered = append(ordered, &msgs[i])
+		}
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].SentAt.Before(ordered[j].SentAt) })
+
+		// 按发送时间顺序占用房间序号（导入到已有消息的房间时排在现有消息之后）
+		var seq uint64
+		if len(ordered) > 0 {
+			first, err := reserveRoomSeq(tx, roomID, len(ordered))
+			if err != nil {
+				return err
+			}
+			seq = first
+		}
+		var last *models.Message
+		for _, m := range ordered {
+			extra, err := json.Marshal(m.Extra)
+			if err != nil {
+				return err
+			}
+			msg := &models.Message{
+				RoomID:     roomID,
+				SenderID:   m.SenderID,
+				Seq:        seq,
+				Type:       m.Type,
+				Content:    m.Content,
+				Extra:      datatypes.JSON(extra),
+				IsSystem:   m.IsSystem,
+				IsViewOnce: m.Extra.ViewOnce,
+				Status:     models.MessageStatusSent,
+				CreatedAt:  m.SentAt,
+				UpdatedAt:  m.SentAt,
+			}
+			if id, ok := localIDs[m.ReplyToExternalID]; ok && m.ReplyToExternalID != "" {
+				msg.ReplyToMsgID = &id
+			}
+			if err := tx.Create(msg).Error; err != nil {
+				return err
+			}
+			if err := s.Archive.record(tx, ArchiveEventImported, 0, []models.Message{*msg}); err != nil {
+				return err
+			}
+			if err := tx.Create(&models.MessageImportRef{RoomID: roomID, ExternalID: m.ExternalID, MessageID: msg.ID}).Error; err != nil {
+				return err
+			}
+			if seq > 0 {
+				seq++
+			}
+			localIDs[m.ExternalID] = msg.ID
+			res.MessageIDs[m.ExternalID] = msg.ID
+			res.Imported++
+			last = msg
+		}
+		if last == nil {
+			return nil
+		}
+
+		// 导入的最后一条比房间当前最后一条消息更新时才作为会话预览
+		if room.LastMessageID != nil {
+			var cur models.Message
+			err := tx.Unscoped().Select("id", "created_at").First(&cur, *room.LastMessageID).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil && cur.CreatedAt.After(last.CreatedAt) {
+				last = nil
+			}
+		}
+		if last != nil {
+			if err := tx.Model(&models.Room{}).Where("id = ?", roomID).UpdateColumn("last_message_id", last.ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Conversation{}).Where("room_id = ?", roomID).Update("is_visible", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestImportMessages(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &MessageService{Service: &Service{DB: db}}
+	t0 := time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	if _, err := s.ImportMessages(5, make([]ImportedMessage, ImportMessagesMax+1)); !errors.Is(err, ErrImportTooMany) {
+		t.Fatalf("want ErrImportTooMany, got %v", err)
+	}
+	dup := []ImportedMessage{{ExternalID: "a", SenderID: 1, SentAt: t0}, {ExternalID: "a", SenderID: 1, SentAt: t0}}
+	if _, err := s.ImportMessages(5, dup); !errors.Is(err, ErrImportExternalIDDuplicate) {
+		t.Fatalf("duplicate external_id should fail, got %v", err)
+	}
+	noSender := []ImportedMessage{{ExternalID: "a", SenderID: 1, SentAt: t0}, {ExternalID: "b", SentAt: t0}}
+	if _, err := s.ImportMessages(5, noSender); !errors.Is(err, ErrImportSenderRequired) || !strings.Contains(err.Error(), "第 2 条") {
+		t.Fatalf("missing sender_id should name the message, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT `id`,`last_message_id` FROM `im_room` WHERE `im_room`.`id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "last_message_id"}).AddRow(5, 50))
+	mock.ExpectQuery("SELECT `id` FROM `im_user` WHERE id IN \\(\\?,\\?\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery("SELECT \\* FROM `im_message_import_ref` WHERE room_id = \\? AND external_id IN \\(\\?,\\?,\\?,\\?\\)").
+		WithArgs(5, "e1", "e3", "e2", "e2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "external_id", "message_id"}).AddRow(1, 5, "e1", 40))
+	// 按 sent_at 排序：e2 先于 e3 写入，e3 回复 e2；一次预留 2 个房间序号（房间已有 7 条）
+	mock.ExpectExec("UPDATE `im_room` SET `message_seq`=message_seq \\+ \\? WHERE id = \\?").
+		WithArgs(2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT `message_seq` FROM `im_room` WHERE id = \\?").WillReturnRows(sqlmock.NewRows([]string{"message_seq"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO `im_message` ").WillReturnResult(sqlmock.NewResult(101, 1))
+	mock.ExpectExec("INSERT INTO `im_message_import_ref` ").
+		WithArgs(5, "e2", 101, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	anyArg := sqlmock.AnyArg()
+	mock.ExpectExec("INSERT INTO `im_message` \\(`room_id`,`sender_id`,`seq`,`reply_to_msg_id`,`type`,`content`").
+		WithArgs(5, 2, 9, 101, 1, "回复", anyArg, false, false, false, 1, t0.Add(2*time.Minute), t0.Add(2*time.Minute), nil).
+		WillReturnResult(sqlmock.NewResult(102, 1))
+	mock.ExpectExec("INSERT INTO `im_message_import_ref` ").
+		WithArgs(5, "e3", 102, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	// 房间已有的最后一条消息更早，会话预览换成导入的最后一条
+	mock.ExpectQuery("SELECT `id`,`created_at` FROM `im_message` WHERE `im_message`.`id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(50, t0.Add(-time.Hour)))
+	mock.ExpectExec("UPDATE `im_room` SET `last_message_id`=\\? WHERE id = \\?").
+		WithArgs(102, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `im_conversation` SET `is_visible`=\\?,`updated_at`=\\? WHERE room_id = \\?").
+		WithArgs(true, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	res, err := s.ImportMessages(5, []ImportedMessage{
+		{ExternalID: "e1", SenderID: 1, Content: "已导入", SentAt: t0},
+		{ExternalID: "e3", SenderID: 2, Content: "回复", SentAt: t0.Add(2 * time.Minute), ReplyToExternalID: "e2"},
+		{ExternalID: "e2", SenderID: 1, Content: "原消息", SentAt: t0.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("ImportMessages: %v", err)
+	}
+	if res.Imported != 2 || res.Skipped != 1 || res.MessageIDs["e1"] != 40 || res.MessageIDs["e2"] != 101 || res.MessageIDs["e3"] != 102 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageReminderLimit 每个用户同时待提醒的消息数上限
+const MessageReminderLimit = 100
+
+// reminderFireBatch 每批触发的提醒数
+const reminderFireBatch = 500
+
+var (
+	// ErrReminderTime 提醒时间必须晚于当前时间
+	ErrReminderTime = newError(response.CodeParamError, "err.reminder_time")
+	// ErrReminderLimit 待提醒的消息过多
+	ErrReminderLimit = newError(response.CodeParamError, "err.reminder_limit")
+)
+
+// MessageReminderDTO 消息提醒
+type MessageReminderDTO struct {
+	ID        uint64              `json:"id"`
+	MessageID uint64              `json:"message_id"`
+	RoomID    uint64              `json:"room_id"`
+	Note      string              `json:"note"`
+	RemindAt  time.Time           `json:"remind_at"`
+	CreatedAt time.Time           `json:"created_at"`
+	Message   *MessageListItemDTO `json:"message,omitempty"` // 消息已被删除时为空
+}
+
+// SetMessageReminder 标记消息并在 remindAt 提醒自己；已标记过的消息更新时间和备注，并重新等待触发
+func (s *MessageService) SetMessageReminder(userID, messageID uint64, remindAt time.Time, note string) (*MessageReminderDTO, error) {
+	now := time.Now()
+	if !remindAt.After(now) {
+		return nil, ErrReminderTime
+	}
+
+	var msg models.Message
+	if err := s.DB.Select("id, room_id").Where("id = ?", messageID).First(&msg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	var n int64
+	if err := s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", msg.RoomID, userID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrPermissionDenied
+	}
+
+	var pending int64
+	if err := s.DB.Model(&models.MessageReminder{}).
+		Where("user_id = ? AND fired_at IS NULL AND message_id <> ?", userID, messageID).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending >= MessageReminderLimit {
+		return nil, ErrReminderLimit
+	}
+
+	r := models.MessageReminder{
+		UserID: userID, MessageID: messageID, RoomID: msg.RoomID,
+		Note: note, RemindAt: remindAt, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := s.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "message_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"note":       note,
+			"remind_at":  remindAt,
+			"fired_at":   nil,
+			"updated_at": now,
+		}),
+	}).Create(&r).Error; err != nil {
+		return nil, err
+	}
+	// 冲突更新时拿不到原记录 ID，重新读取
+	var saved models.MessageReminder
+	if err := s.DB.Where("user_id = ? AND message_id = ?", userID, messageID).First(&saved).Error; err != nil {
+		return nil, err
+	}
+	return &MessageReminderDTO{
+		ID: saved.ID, MessageID: saved.MessageID, RoomID: saved.RoomID,
+		Note: saved.Note, RemindAt: saved.RemindAt, CreatedAt: saved.CreatedAt,
+	}, nil
+}
+
+// CancelMessageReminder 取消消息提醒
+func (s *MessageService) CancelMessageReminder(userID, messageID uint64) error {
+	return s.DB.Where("user_id = ? AND message_id = ?", userID, messageID).Delete(&models.MessageReminder{}).Error
+}
+
+// ListUpcomingReminders 待提醒的消息，按提醒时间先后排列
+func (s *MessageService) ListUpcomingReminders(userID uint64, limit, offset int) ([]MessageReminderDTO, error) {
+	var rows []models.MessageReminder
+	if err := s.DB.Where("user_id = ? AND fired_at IS NULL", userID).
+		Order("remind_at ASC, id ASC").
+		Limit(limit).Offset(offset).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []MessageReminderDTO{}, nil
+	}
+
+	ids := make([]uint64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.MessageID
+	}
+	var msgs []models.Message
+	if err := s.DB.Where("id IN ?", ids).
+		Preload("Sender", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, nickname, avatar") }).
+		Find(&msgs).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]*models.Message, len(msgs))
+	for i := range msgs {
+		byID[msgs[i].ID] = &msgs[i]
+	}
+
+	out := make([]MessageReminderDTO, len(rows))
+	for i, r := range rows {
+		out[i] = MessageReminderDTO{
+			ID: r.ID, MessageID: r.MessageID, RoomID: r.RoomID,
+			Note: r.Note, RemindAt: r.RemindAt, CreatedAt: r.CreatedAt,
+		}
+		if m, ok := byID[r.MessageID]; ok {
+			out[i].Message = toMessageListItemDTO(m)
+		}
+	}
+	return out, nil
+}
+
+// FireDueReminders 触发到期的提醒：标记 fired_at 后通过通知服务发给本人（message.reminder），返回触发条数。
+// 先抢占再通知，多实例同时执行时同一条只会触发一次。
+func (s *MessageService) FireDueReminders(now time.Time) (int, error) {
+	fired := 0
+	for {
+		var due []models.MessageReminder
+		if err := s.DB.Where("fired_at IS NULL AND remind_at <= ?", now).
+			Order("remind_at ASC").
+			Limit(reminderFireBatch).
+			Find(&due).Error; err != nil {
+			return fired, err
+		}
+		for _, r := range due {
+			res := s.DB.Model(&models.MessageReminder{}).
+				Where("id = ? AND fired_at IS NULL", r.ID).
+				Update("fired_at", now)
+			if res.Error != nil {
+				return fired, res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			fired++
+			if s.Notify != nil {
+				payload := map[string]any{"message_id": r.MessageID, "note": r.Note, "remind_at": r.RemindAt}
+				if _, err := s.Notify.PublishRoomEvent(r.RoomID, r.UserID, EventMessageReminder, payload, []uint64{r.UserID}, true); err != nil {
+					log.Printf("message reminder %d notify: %v", r.ID, err)
+				}
+			}
+		}
+		if len(due) < reminderFireBatch {
+			return fired, nil
+		}
+	}
+}
+
+// RunReminderLoop 定时触发到期的消息提醒（阻塞，engine 中以 goroutine 启动）
+func (s *MessageService) RunReminderLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.FireDueReminders(time.Now()); err != nil {
+			log.Printf("message reminder loop: %v", err)
+		}
+	}
+}
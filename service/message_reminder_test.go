@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFireDueReminders(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &MessageService{Service: &Service{DB: db}}
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT \\* FROM `im_message_reminder` WHERE fired_at IS NULL AND remind_at <= \\? ORDER BY remind_at ASC LIMIT \\?").
+		WithArgs(now, reminderFireBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "message_id", "room_id", "remind_at"}).
+			AddRow(1, 7, 100, 5, now.Add(-time.Minute)).
+			AddRow(2, 8, 101, 5, now.Add(-time.Second)))
+	mock.ExpectExec("UPDATE `im_message_reminder` SET `fired_at`=\\?,`updated_at`=\\? WHERE id = \\? AND fired_at IS NULL").
+		WithArgs(now, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 已被其他实例触发
+	mock.ExpectExec("UPDATE `im_message_reminder` SET `fired_at`=\\?,`updated_at`=\\? WHERE id = \\? AND fired_at IS NULL").
+		WithArgs(now, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := s.FireDueReminders(now)
+	if err != nil {
+		t.Fatalf("FireDueReminders: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("fired = %d, want 1", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetMessageReminder(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &MessageService{Service: &Service{DB: db}}
+
+	if _, err := s.SetMessageReminder(1, 100, time.Now().Add(-time.Minute), ""); !errors.Is(err, ErrReminderTime) {
+		t.Fatalf("want ErrReminderTime, got %v", err)
+	}
+
+	// 不是房间成员
+	mock.ExpectQuery("SELECT id, room_id FROM `im_message` WHERE id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id"}).AddRow(100, 5))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	if _, err := s.SetMessageReminder(1, 100, time.Now().Add(time.Hour), ""); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("want ErrPermissionDenied, got %v", err)
+	}
+
+	// 待提醒数量已满
+	mock.ExpectQuery("SELECT id, room_id FROM `im_message`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id"}).AddRow(100, 5))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_message_reminder` WHERE user_id = \\? AND fired_at IS NULL AND message_id <> \\?").
+		WithArgs(1, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(MessageReminderLimit))
+	if _, err := s.SetMessageReminder(1, 100, time.Now().Add(time.Hour), ""); !errors.Is(err, ErrReminderLimit) {
+		t.Fatalf("want ErrReminderLimit, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+// messagePurgeBatch 每批物理删除的消息数，避免长事务锁表
+const messagePurgeBatch = 500
+
+// PurgeMessagesBefore 物理删除 before 之前的消息及其回执（含已软删的，开启冷表时也清理冷表），返回删除条数。
+// 分批执行，单批失败直接返回，已删除的部分不回滚。
+func (s *MessageService) PurgeMessagesBefore(before time.Time) (int64, error) {
+	total, err := s.purgeHotBefore(before)
+	if err != nil || !s.coldEnabled() {
+		return total, err
+	}
+	n, err := s.purgeColdBefore(before)
+	return total + n, err
+}
+
+// purgeHotBefore 物理删除热表中 before 之前的消息及其回执
+func (s *MessageService) purgeHotBefore(before time.Time) (int64, error) {
+	var total int64
+	for {
+		var ids []uint64
+		if err := s.DB.Unscoped().Model(&models.Message{}).
+			Where("created_at < ?", before).
+			Order("id ASC").
+			Limit(messagePurgeBatch).
+			Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		if err := s.Archive.recordIDs(s.DB, ArchiveEventPurged, 0, ids); err != nil {
+			return total, err
+		}
+		if err := s.DB.Where("message_id IN ?", ids).Delete(&models.MessageStatus{}).Error; err != nil {
+			return total, err
+		}
+		res := s.DB.Unscoped().Where("id IN ?", ids).Delete(&models.Message{})
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if len(ids) < messagePurgeBatch {
+			return total, nil
+		}
+	}
+}
+
+// RunRetentionLoop 定时清理超过 retention 的历史消息（阻塞，engine 中以 goroutine 启动）
+func (s *MessageService) RunRetentionLoop(retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.PurgeMessagesBefore(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("message retention loop: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("message retention: purged %d messages", n)
+		}
+	}
+}
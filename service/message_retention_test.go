@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPurgeMessagesBefore(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &MessageService{Service: &Service{DB: db}}
+	before := time.Now().Add(-30 * 24 * time.Hour)
+
+	mock.ExpectQuery("SELECT `id` FROM `im_message` WHERE created_at < \\? ORDER BY id ASC LIMIT \\?").
+		WithArgs(before, messagePurgeBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectExec("DELETE FROM `im_message_status` WHERE message_id IN \\(\\?,\\?\\)").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM `im_message` WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := s.PurgeMessagesBefore(before)
+	if err != nil {
+		t.Fatalf("PurgeMessagesBefore: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("purged = %d, want 2", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPurgeMessagesBefore_Empty(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &MessageService{Service: &Service{DB: db}}
+
+	mock.ExpectQuery("SELECT `id` FROM `im_message`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	n, err := s.PurgeMessagesBefore(time.Now())
+	if err != nil || n != 0 {
+		t.Fatalf("got (%d, %v), want (0, nil)", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+)
+
+const (
+	// RoomSearchMaxLimit 会话内搜索单页最大条数
+	RoomSearchMaxLimit = 50
+	// RoomSearchMaxKeyword 搜索关键词最大字符数
+	RoomSearchMaxKeyword = 64
+	// MessageContextMax 定位消息时前/后最多各取的条数
+	MessageContextMax = 50
+)
+
+// roomSearchTypes 参与会话内搜索的消息类型（内容为文本）；不能用 []uint8，会被当作 []byte 绑定
+var roomSearchTypes = []int{int(message.TypeText), int(message.TypeQuote), int(message.TypeMention)}
+
+// HighlightRange 命中关键词在 content 中的位置 [start, end)，按 Unicode 字符（rune）计
+type HighlightRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// RoomSearchHit 一条命中的消息
+type RoomSearchHit struct {
+	Message    MessageListItemDTO `json:"message"`
+	Highlights []HighlightRange   `json:"highlights"`
+}
+
+// RoomSearchPage 一页搜索结果（按消息 ID 从新到旧），NextCursor 为 0 表示没有更多
+type RoomSearchPage struct {
+	Hits       []RoomSearchHit `json:"hits"`
+	NextCursor uint64          `json:"next_cursor"`
+}
+
+// SearchInRoom 在单个房间内按关键词搜索文本消息（不区分大小写）。
+// 只搜索 viewer 可见的文本/引用/@ 消息：撤回、删除、阅后即焚、加密与系统消息不参与。cursor 为上一页的 NextCursor。
+func (s *MessageService) SearchInRoom(roomID, viewerID uint64, keyword string, cursor uint64, limit int) (*RoomSearchPage, error) {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return nil, newError(response.CodeParamError, "valid.required", "keyword")
+	}
+	if utf8.RuneCountInString(keyword) > RoomSearchMaxKeyword {
+		return nil, newError(response.CodeParamError, "valid.max_len", "keyword", strconv.Itoa(RoomSearchMaxKeyword))
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	limit = min(limit, RoomSearchMaxLimit)
+
+	msgs, err := s.findVisible(roomID, viewerID, func(q *gorm.DB) *gorm.DB {
+		q = q.Where("status <> ? AND is_view_once = ? AND is_encrypted = ? AND is_system = ?", models.MessageStatusRecalled, false, false, false).
+			Where("type IN ?", roomSearchTypes).
+			Where("content LIKE ?", "%"+escapeLike(keyword)+"%")
+		if cursor > 0 {
+			q = q.Where("id < ?", cursor)
+		}
+		return q
+	}, "id DESC", limit+1, false)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &RoomSearchPage{Hits: make([]RoomSearchHit, 0, len(msgs))}
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+		page.NextCursor = msgs[len(msgs)-1].ID
+	}
+	items := toMessageListItemDTOs(msgs)
+	if err := s.fillSenderNames(viewerID, roomID, items); err != nil {
+		return nil, err
+	}
+	for _, m := range items {
+		page.Hits = append(page.Hits, RoomSearchHit{Message: m, Highlights: highlightRanges(m.Content, keyword)})
+	}
+	return page, nil
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// highlightRanges content 中所有（不重叠的）关键词位置，不区分大小写
+func highlightRanges(content, keyword string) []HighlightRange {
+	text, kw := foldRunes(content), foldRunes(keyword)
+	ranges := []HighlightRange{}
+	if len(kw) == 0 {
+		return ranges
+	}
+	for i := 0; i+len(kw) <= len(text); {
+		match := true
+		for j := range kw {
+			if text[i+j] != kw[j] {
+				match = false
+				break
+			}
+		}
+		if !match {
+			i++
+			continue
+		}
+		ranges = append(ranges, HighlightRange{Start: i, End: i + len(kw)})
+		i += len(kw)
+	}
+	return ranges
+}
+
+// foldRunes 逐字符转小写（保持字符数不变，偏移量与原文一致）
+func foldRunes(s string) []rune {
+	rs := []rune(s)
+	for i, r := range rs {
+		rs[i] = unicode.ToLower(r)
+	}
+	return rs
+}
+
+// MessageContextDTO 定位到某条消息时的上下文（按消息 ID 从旧到新），客户端据此滚动到 AnchorID
+type MessageContextDTO struct {
+	AnchorID      uint64               `json:"anchor_id"`
+	Messages      []MessageListItemDTO `json:"messages"`
+	HasMoreBefore bool                 `json:"has_more_before"` // 更早的消息继续用 /message/list?mess_id=Messages[0].id 加载
+	HasMoreAfter  bool                 `json:"has_more_after"`
+}
+
+// GetMessageContext 取 messageID 及其前 before 条、后 after 条 viewer 可见的消息（搜索结果跳转、引用定位）
+func (s *MessageService) GetMessageContext(roomID, viewerID, messageID uint64, before, after int) (*MessageContextDTO, error) {
+	before = max(0, min(before, MessageContextMax))
+	after = max(0, min(after, MessageContextMax))
+
+	older, err := s.findVisible(roomID, viewerID, func(q *gorm.DB) *gorm.DB {
+		return q.Where("id <= ?", messageID)
+	}, "id DESC", before+2, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(older) == 0 || older[0].ID != messageID {
+		return nil, ErrMessageNotFound
+	}
+	// 更新的消息从旧到新取，冷表在前
+	newer, err := s.findVisible(roomID, viewerID, func(q *gorm.DB) *gorm.DB {
+		return q.Where("id > ?", messageID)
+	}, "id", after+1, true)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &MessageContextDTO{AnchorID: messageID}
+	if len(older) > before+1 {
+		older = older[:before+1]
+		ctx.HasMoreBefore = true
+	}
+	if len(newer) > after {
+		newer = newer[:after]
+		ctx.HasMoreAfter = true
+	}
+	msgs := make([]models.Message, 0, len(older)+len(newer))
+	for i := len(older) - 1; i >= 0; i-- {
+		msgs = append(msgs, older[i])
+	}
+	msgs = append(msgs, newer...)
+	ctx.Messages = toMessageListItemDTOs(msgs)
+	if err := s.fillSenderNames(viewerID, roomID, ctx.Messages); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestHighlightRanges(t *testing.T) {
+	got := highlightRanges("Go语言 go GO 语言", "go")
+	want := []HighlightRange{{0, 2}, {5, 7}, {8, 10}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if r := highlightRanges("aaaa", "aa"); len(r) != 2 || r[1].Start != 2 {
+		t.Fatalf("non-overlapping: %v", r)
+	}
+	if r := highlightRanges("Go语言", "语言"); len(r) != 1 || r[0] != (HighlightRange{2, 4}) {
+		t.Fatalf("rune offsets: %v", r)
+	}
+	if escapeLike(`50%_a\`) != `50\%\_a\\` {
+		t.Fatalf("escapeLike: %s", escapeLike(`50%_a\`))
+	}
+}
+
+func TestMessageService_SearchInRoom(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewMessageService(&Service{DB: gormDB})
+
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE \\(room_id = \\? AND status <> \\?\\) AND \\(NOT EXISTS .*\\) "+
+		"AND \\(status <> \\? AND is_view_once = \\? AND is_encrypted = \\? AND is_system = \\?\\) AND type IN \\(\\?,\\?,\\?\\) "+
+		"AND content LIKE \\? AND id < \\? AND `im_message`.`deleted_at` IS NULL ORDER BY id DESC LIMIT \\?").
+		WithArgs(1, models.MessageStatusBothDeleted, 3, true, models.MessageStatusRecalled, false, false, false, 1, 7, 8, "%100\\%%", 50, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "type", "content", "status"}).
+			AddRow(40, 1, 2, 1, "打折 100% 真的", models.MessageStatusRead).
+			AddRow(30, 1, 2, 7, "100%", models.MessageStatusRead).
+			AddRow(20, 1, 2, 1, "100%!", models.MessageStatusRead))
+	mock.ExpectQuery("SELECT \\* FROM `im_user` WHERE `im_user`.`id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(2, "bob"))
+
+	page, err := svc.SearchInRoom(1, 3, " 100% ", 50, 2)
+	if err != nil {
+		t.Fatalf("SearchInRoom: %v", err)
+	}
+	if len(page.Hits) != 2 || page.NextCursor != 30 {
+		t.Fatalf("page: %+v", page)
+	}
+	if h := page.Hits[0]; h.Message.ID != 40 || len(h.Highlights) != 1 || h.Highlights[0] != (HighlightRange{3, 7}) {
+		t.Fatalf("hit: %+v", h)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	if _, err := svc.SearchInRoom(1, 3, "  ", 0, 0); err == nil {
+		t.Fatalf("empty keyword should fail")
+	}
+}
+
+func TestMessageService_GetMessageContext(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewMessageService(&Service{DB: gormDB})
+
+	cols := []string{"id", "room_id", "sender_id", "content", "status"}
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE .* AND id <= \\? AND `im_message`.`deleted_at` IS NULL ORDER BY id DESC LIMIT \\?").
+		WithArgs(1, models.MessageStatusBothDeleted, 3, true, 10, 4).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(10, 1, 2, "hit", 1).AddRow(9, 1, 2, "b1", 1).AddRow(7, 1, 2, "b2", 1).AddRow(5, 1, 2, "b3", 1))
+	mock.ExpectQuery("SELECT \\* FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE .* AND id > \\? AND `im_message`.`deleted_at` IS NULL ORDER BY id LIMIT \\?").
+		WithArgs(1, models.MessageStatusBothDeleted, 3, true, 10, 3).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(11, 1, 2, "a1", 1))
+	mock.ExpectQuery("SELECT \\* FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	out, err := svc.GetMessageContext(1, 3, 10, 2, 2)
+	if err != nil {
+		t.Fatalf("GetMessageContext: %v", err)
+	}
+	var ids []uint64
+	for _, m := range out.Messages {
+		ids = append(ids, m.ID)
+	}
+	if len(ids) != 4 || ids[0] != 7 || ids[2] != 10 || ids[3] != 11 || !out.HasMoreBefore || out.HasMoreAfter || out.AnchorID != 10 {
+		t.Fatalf("context ids=%v %+v", ids, out)
+	}
+
+	// 目标消息不可见（被删除或不在房间中）
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE .* AND id <= \\?").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(9, 1, 2, "b1", 1))
+	mock.ExpectQuery("SELECT \\* FROM `im_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	if _, err := svc.GetMessageContext(1, 3, 10, 2, 2); err != ErrMessageNotFound {
+		t.Fatalf("want ErrMessageNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
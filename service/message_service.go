@@ -1,32 +1,40 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/cydxin/chat-sdk/message"
 	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
 	"gorm.io/datatypes"
-	"gorm.io/gorm/clause"
+	"gorm.io/gorm"
 )
 
 // MessageDTO 消息数据传输对象（避免 Swagger 递归）
 type MessageDTO struct {
-	ID           uint64         `json:"id"`
-	MessageID    string         `json:"message_id"`
-	RoomID       uint64         `json:"room_id"`
-	SenderID     uint64         `json:"sender_id"`
-	ReplyToMsgID *uint64        `json:"reply_to_msg_id,omitempty"`
-	Type         uint8          `json:"type"`
-	Content      string         `json:"content"`
-	Extra        datatypes.JSON `json:"extra,omitempty"`
-	IsSystem     bool           `json:"is_system"`
-	IsEncrypted  bool           `json:"is_encrypted"`
-	Status       uint8          `json:"status"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID           uint64             `json:"id"`
+	MessageID    string             `json:"message_id"`
+	RoomID       uint64             `json:"room_id"`
+	SenderID     uint64             `json:"sender_id"`
+	ReplyToMsgID *uint64            `json:"reply_to_msg_id,omitempty"`
+	Type         uint8              `json:"type"`
+	Content      string             `json:"content"`
+	Extra        datatypes.JSON     `json:"extra,omitempty"`
+	Voice        *message.VoiceInfo `json:"voice,omitempty"` // 语音消息元数据（type=3）
+	IsSystem     bool               `json:"is_system"`
+	IsEncrypted  bool               `json:"is_encrypted"`
+	ViewOnce     bool               `json:"view_once,omitempty"` // 阅后即焚：content/extra 不返回，需调用查看接口
+	Status       uint8              `json:"status"`
+	RoomSeq      uint64             `json:"room_seq"`  // 房间内序号，同房间消息按它排序（0 为引入序号前的历史消息，按 id 排序）
+	SendTime     int64              `json:"send_time"` // 服务端落库时间（毫秒），展示时间以它为准，不依赖客户端时钟
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
 }
 
 // SenderDTO 发送人信息（用于消息列表返回）
@@ -39,19 +47,24 @@ type SenderDTO struct {
 
 // MessageListItemDTO 消息列表项（带发送人信息；不返回 Room，避免冗余/递归）
 type MessageListItemDTO struct {
-	ID           uint64         `json:"id"`
-	RoomID       uint64         `json:"room_id"`
-	SenderID     uint64         `json:"sender_id"`
-	Sender       *SenderDTO     `json:"sender,omitempty"`
-	ReplyToMsgID *uint64        `json:"reply_to_msg_id,omitempty"`
-	Type         uint8          `json:"type"`
-	Content      string         `json:"content"`
-	Extra        datatypes.JSON `json:"extra,omitempty"`
-	IsSystem     bool           `json:"is_system"`
-	IsEncrypted  bool           `json:"is_encrypted"`
-	Status       uint8          `json:"status"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID           uint64             `json:"id"`
+	RoomID       uint64             `json:"room_id"`
+	SenderID     uint64             `json:"sender_id"`
+	Sender       *SenderDTO         `json:"sender,omitempty"`
+	SenderName   string             `json:"sender_name,omitempty"` // 当前用户视角的展示名（备注 > 群昵称 > 昵称 > 用户名）
+	ReplyToMsgID *uint64            `json:"reply_to_msg_id,omitempty"`
+	Type         uint8              `json:"type"`
+	Content      string             `json:"content"`
+	Extra        datatypes.JSON     `json:"extra,omitempty"`
+	Voice        *message.VoiceInfo `json:"voice,omitempty"` // 语音消息元数据（type=3）
+	IsSystem     bool               `json:"is_system"`
+	IsEncrypted  bool               `json:"is_encrypted"`
+	ViewOnce     bool               `json:"view_once,omitempty"` // 阅后即焚：content/extra 不返回，需调用查看接口
+	Status       uint8              `json:"status"`
+	RoomSeq      uint64             `json:"room_seq"`  // 房间内序号，同房间消息按它排序（0 为引入序号前的历史消息，按 id 排序）
+	SendTime     int64              `json:"send_time"` // 服务端落库时间（毫秒），展示时间以它为准，不依赖客户端时钟
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
 }
 
 // ToMessageDTO 将 Message 转换为 MessageDTO
@@ -59,7 +72,7 @@ func ToMessageDTO(msg *models.Message) *MessageDTO {
 	if msg == nil {
 		return nil
 	}
-	return &MessageDTO{
+	dto := &MessageDTO{
 		ID: msg.ID,
 		//MessageID:    msg.MessageID,
 		RoomID:       msg.RoomID,
@@ -68,12 +81,78 @@ func ToMessageDTO(msg *models.Message) *MessageDTO {
 		Type:         msg.Type,
 		Content:      msg.Content,
 		Extra:        msg.Extra,
+		Voice:        voiceFromExtra(msg.Type, msg.Extra),
 		IsSystem:     msg.IsSystem,
 		IsEncrypted:  msg.IsEncrypted,
 		Status:       msg.Status,
+		RoomSeq:      msg.Seq,
+		SendTime:     msg.CreatedAt.UnixMilli(),
 		CreatedAt:    msg.CreatedAt,
 		UpdatedAt:    msg.UpdatedAt,
 	}
+	if msg.IsViewOnce {
+		dto.ViewOnce = true
+		dto.Content, dto.Extra, dto.Voice = "", viewOnceExtra, nil
+	}
+	if msg.Status == models.MessageStatusRecalled {
+		dto.Content, dto.Extra, dto.Voice = "", nil, nil
+	}
+	return dto
+}
+
+// voiceFromExtra 从 Extra 中取出语音元数据（仅 type=3）
+func voiceFromExtra(msgType uint8, extra datatypes.JSON) *message.VoiceInfo {
+	return message.VoiceOf(msgType, extra)
+}
+
+// validateExtra 按消息类型的 schema 校验 Extra（见 message.RegisterExtraSchema），格式错误转为参数错误
+func validateExtra(msgType uint8, content string, extra *message.Extra) error {
+	err := message.ValidateExtra(msgType, content, extra)
+	var xe *message.ExtraError
+	if errors.As(err, &xe) {
+		return newError(response.CodeParamError, "err.extra_"+xe.Reason, xe.Field)
+	}
+	return err
+}
+
+// 语音消息校验错误
+var (
+	ErrVoiceRequired        = newError(response.CodeParamError, "err.voice_required")
+	ErrVoiceDurationInvalid = newError(response.CodeParamError, "err.voice_duration_invalid")
+	ErrVoiceWaveformValue   = newError(response.CodeParamError, "err.voice_waveform_value")
+)
+
+// ErrPrivateOrphaned 删好友后私聊被禁止发送（FriendDeletePolicy.BlockPrivateSend）
+var ErrPrivateOrphaned = newError(response.CodePermissionDeny, "err.private_orphaned")
+
+// validateVoiceExtra 校验语音消息的 Extra.voice
+func validateVoiceExtra(extra *message.Extra, maxDuration time.Duration) error {
+	v := extra.Voice
+	if v == nil {
+		return ErrVoiceRequired
+	}
+	if maxDuration <= 0 {
+		maxDuration = DefaultVoiceMaxDuration
+	}
+	if v.DurationMs <= 0 {
+		return ErrVoiceDurationInvalid
+	}
+	if time.Duration(v.DurationMs)*time.Millisecond > maxDuration {
+		return newError(response.CodeParamError, "err.voice_too_long", int(maxDuration/time.Second))
+	}
+	if len(v.Waveform) > message.VoiceMaxWaveformSamples {
+		return newError(response.CodeParamError, "err.voice_waveform_too_long", message.VoiceMaxWaveformSamples)
+	}
+	for _, w := range v.Waveform {
+		if w < 0 || w > 255 {
+			return ErrVoiceWaveformValue
+		}
+	}
+	v.Codec = strings.ToLower(strings.TrimSpace(v.Codec))
+	if _, ok := message.VoiceCodecs[v.Codec]; !ok {
+		return newError(response.CodeParamError, "err.voice_codec", v.Codec)
+	}
+	return nil
 }
 
 func toSenderDTO(u *models.User) *SenderDTO {
@@ -87,7 +166,7 @@ func toMessageListItemDTO(m *models.Message) *MessageListItemDTO {
 	if m == nil {
 		return nil
 	}
-	return &MessageListItemDTO{
+	dto := &MessageListItemDTO{
 		ID:           m.ID,
 		RoomID:       m.RoomID,
 		SenderID:     m.SenderID,
@@ -96,12 +175,24 @@ func toMessageListItemDTO(m *models.Message) *MessageListItemDTO {
 		Type:         m.Type,
 		Content:      m.Content,
 		Extra:        m.Extra,
+		Voice:        voiceFromExtra(m.Type, m.Extra),
 		IsSystem:     m.IsSystem,
 		IsEncrypted:  m.IsEncrypted,
 		Status:       m.Status,
+		RoomSeq:      m.Seq,
+		SendTime:     m.CreatedAt.UnixMilli(),
 		CreatedAt:    m.CreatedAt,
 		UpdatedAt:    m.UpdatedAt,
 	}
+	if m.IsViewOnce {
+		dto.ViewOnce = true
+		dto.Content, dto.Extra, dto.Voice = "", viewOnceExtra, nil
+	}
+	// 撤回的消息只保留占位（status=4 + 发送人），不返回原内容
+	if m.Status == models.MessageStatusRecalled {
+		dto.Content, dto.Extra, dto.Voice = "", nil, nil
+	}
+	return dto
 }
 
 // ToMessageDTOs 已弃用：当前仓库内无引用。
@@ -128,21 +219,36 @@ func toMessageListItemDTOs(msgs []models.Message) []MessageListItemDTO {
 
 type MessageService struct {
 	*Service
-	messageDAO *models.MessageDAO
+	// Store 基础读写（发送、撤回/删除、按 ID / 按房间读取），默认 GORM 实现，可由 WithMessageStore 替换
+	Store MessageStore
+	// RecallPolicy 撤回策略（由 engine 按 WithRecallPolicy 注入）
+	RecallPolicy RecallPolicy
 	// SessionBootstrap 用于 WS 建连时加载会话已读游标（由 engine 注入）
 	SessionBootstrap *SessionBootstrapService
+	// ColdAfter 冷消息冷却期（由 engine 按 WithMessageColdStorage 注入），>0 时读接口会透明地查询冷表
+	ColdAfter time.Duration
 }
 
 func NewMessageService(s *Service) *MessageService {
 	log.Println("NewMessageService")
-	return &MessageService{Service: s, messageDAO: models.NewMessageDAO(s.DB), SessionBootstrap: s.SessionBootstrap}
+	return &MessageService{Service: s, Store: models.NewMessageDAO(s.DB), RecallPolicy: DefaultRecallPolicy, SessionBootstrap: s.SessionBootstrap}
 }
 
 // SaveMessage 保存消息到数据库
 func (s *MessageService) SaveMessage(roomID uint64, senderID uint64, content string, msgType uint8, extra message.Extra) (*models.Message, error) {
-	if err := s.checkMuteStatus(roomID, senderID); err != nil {
+	if err := validateExtra(msgType, content, &extra); err != nil {
 		return nil, err
 	}
+	if msgType == message.TypeVoice {
+		if err := validateVoiceExtra(&extra, s.VoiceMaxDuration); err != nil {
+			return nil, err
+		}
+	}
+	if extra.ViewOnce {
+		if err := s.checkViewOnceRoom(roomID); err != nil {
+			return nil, err
+		}
+	}
 
 	extraBytes, err := json.Marshal(extra)
 	if err != nil {
@@ -151,72 +257,296 @@ func (s *MessageService) SaveMessage(roomID uint64, senderID uint64, content str
 
 	msg := &models.Message{
 		//MessageID: uuid.New().String(), // 生成唯一的消息 ID
+		RoomID:     roomID,
+		SenderID:   senderID,
+		Type:       msgType,
+		Content:    content,
+		Status:     models.MessageStatusSent, // 默认状态为已发送
+		Extra:      datatypes.JSON(extraBytes),
+		IsViewOnce: extra.ViewOnce,
+	}
+	if err := s.persistMessage(context.Background(), s.DB, msg, persistOptions{}); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// SaveSystemMessage 保存系统消息（不校验禁言，is_system=true），actorID 记为发送者。
+func (s *MessageService) SaveSystemMessage(roomID, actorID uint64, content string, extra message.Extra) (*models.Message, error) {
+	extraBytes, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	msg := &models.Message{
 		RoomID:   roomID,
-		SenderID: senderID,
-		Type:     msgType,
+		SenderID: actorID,
+		Type:     1,
 		Content:  content,
-		Status:   models.MessageStatusSent, // 默认状态为已发送
+		IsSystem: true,
+		Status:   models.MessageStatusSent,
 		Extra:    datatypes.JSON(extraBytes),
 	}
-	err = s.messageDAO.Create(msg)
-	if err != nil {
+	if err := s.persistMessage(context.Background(), s.DB, msg, persistOptions{skipChecks: true}); err != nil {
 		return nil, err
 	}
-	log.Println(msg.ID, " 最后的消息 ID")
-	s.DB.Model(&models.Room{}).Where("id = ?", roomID).UpdateColumn("last_message_id", msg.ID)
-
 	return msg, nil
 }
 
-func (s *MessageService) checkMuteStatus(roomID, userID uint64) error {
-	var room models.Room
-	if err := s.DB.First(&room, roomID).Error; err != nil {
+// persistOptions persistMessage 的可选行为
+type persistOptions struct {
+	skipChecks    bool // 不校验账号状态/禁言/频率（系统消息，或同一批次中已校验过）
+	skipRateLimit bool // 一次操作写入多条（转发），不计入发消息频率
+	clearMentions bool // 清空 @ 列表（转发的消息不应再次 @ 人）
+}
+
+// persistMessage 消息落库的统一流程（SaveMessage / SaveSystemMessage / 转发共用）：
+// 账号状态 -> 禁言 -> 发消息频率 -> 校正 @ 列表 -> 分配房间序号 -> 写消息 -> 房间 last_message_id -> 会话投影（未读数/可见/排序）。
+// db 可以是事务，由调用方提交/回滚（含死锁重试）；否则经由 TxRetry 开启事务，死锁等可重试错误整体重试。
+func (s *MessageService) persistMessage(ctx context.Context, db *gorm.DB, msg *models.Message, opts persistOptions) error {
+	if !opts.skipChecks {
+		if err := s.checkSenderStatus(db, msg.SenderID); err != nil {
+			return err
+		}
+		if err := s.checkMuteStatus(db, msg.RoomID, msg.SenderID); err != nil {
+			return err
+		}
+		if !opts.skipRateLimit {
+			if err := s.AntiSpam.Check(ctx, msg.SenderID, SpamActionMessage, 1); err != nil {
+				return err
+			}
+		}
+	}
+	if err := normalizeMentions(db, msg, opts.clearMentions); err != nil {
 		return err
 	}
+	// 分配序号到更新会话投影在同一事务内：房间行锁保证序号不重复、不乱序
+	id := msg.ID
+	return s.inTx(ctx, db, func(tx *gorm.DB) error {
+		// 重试时还原调用方给定的 ID（上一次的插入已随事务回滚）
+		msg.ID = id
+		seq, err := nextRoomSeq(tx, msg.RoomID)
+		if err != nil {
+			return err
+		}
+		msg.Seq = seq
+		if err := s.storeTx(tx).Create(msg); err != nil {
+			return err
+		}
+		if err := s.Archive.record(tx, ArchiveEventCreated, msg.SenderID, []models.Message{*msg}); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Room{}).Where("id = ?", msg.RoomID).UpdateColumn("last_message_id", msg.ID).Error; err != nil {
+			return err
+		}
+		// 会话投影：成员未读 +1、隐藏的会话重新出现、按最新活动排序；发送者自己的未读清零
+		updates := map[string]any{
+			"is_visible":   true,
+			"unread_count": gorm.Expr("CASE WHEN user_id = ? THEN 0 ELSE unread_count + 1 END", msg.SenderID),
+			"updated_at":   time.Now(),
+		}
+		if s.AutoUnarchive {
+			updates["is_archived"] = gorm.Expr("is_archived AND is_muted")
+		}
+		return tx.Model(&models.Conversation{}).
+			Where("room_id = ?", msg.RoomID).
+			Updates(updates).Error
+	})
+}
+
+// storeTx 绑定到事务 tx 的 Store：默认 GORM 实现与其他表同事务提交，替换的后端原样返回（不参与关系库事务）
+func (s *MessageService) storeTx(tx *gorm.DB) MessageStore {
+	if _, ok := s.Store.(*models.MessageDAO); ok {
+		return models.NewMessageDAO(tx)
+	}
+	return s.Store
+}
 
-	var member models.RoomUser
-	if err := s.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error; err != nil {
-		return err // Not a member?
+// findMessages 按 ID 批量取消息（不存在的忽略）：默认 GORM 实现一次 IN 查询，替换的 Store 逐条 FindByID
+func (s *MessageService) findMessages(ids []uint64) ([]models.Message, error) {
+	var msgs []models.Message
+	if _, ok := s.Store.(*models.MessageDAO); ok {
+		err := s.DB.Model(&models.Message{}).Where("id IN ?", ids).Find(&msgs).Error
+		return msgs, err
+	}
+	for _, id := range ids {
+		m, err := s.Store.FindByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, *m)
+	}
+	return msgs, nil
+}
+
+// inTx 在事务中执行 fn：db 已是事务时直接复用（由外层事务负责重试），否则同 Tx 开启事务并按 TxRetry 重试
+func (s *Service) inTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return fn(db)
+	}
+	return s.runTx(ctx, db.WithContext(ctx), fn)
+}
+
+// nextRoomSeq 分配房间的下一个消息序号
+func nextRoomSeq(tx *gorm.DB, roomID uint64) (uint64, error) {
+	return reserveRoomSeq(tx, roomID, 1)
+}
+
+// reserveRoomSeq 为房间连续预留 n 个消息序号，返回第一个：先自增 room.message_seq 再读回，
+// tx 须为事务（自增持有房间行锁直到提交，并发发送拿到的序号不重复）
+func reserveRoomSeq(tx *gorm.DB, roomID uint64, n int) (uint64, error) {
+	if err := tx.Model(&models.Room{}).Where("id = ?", roomID).
+		UpdateColumn("message_seq", gorm.Expr("message_seq + ?", n)).Error; err != nil {
+		return 0, err
 	}
+	var last uint64
+	if err := tx.Model(&models.Room{}).Where("id = ?", roomID).Pluck("message_seq", &last).Error; err != nil {
+		return 0, err
+	}
+	if last < uint64(n) {
+		// 房间不存在（或已删除）时不分配序号
+		return 0, nil
+	}
+	return last - uint64(n) + 1, nil
+}
 
-	// Admin/Owner bypass mute
-	if member.Role > 0 {
+// normalizeMentions 校正 extra.mentioned_users：只保留当前房间成员（去重），clear=true 时直接移除。
+// extra 按原始 JSON 处理，保留其他字段（合并转发等 extra 不是 message.Extra 结构）。
+func normalizeMentions(db *gorm.DB, msg *models.Message, clear bool) error {
+	if len(msg.Extra) == 0 {
 		return nil
 	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Extra, &fields); err != nil {
+		return nil
+	}
+	raw, ok := fields["mentioned_users"]
+	if !ok {
+		return nil
+	}
+	var mentioned []uint64
+	_ = json.Unmarshal(raw, &mentioned)
 
-	now := time.Now()
+	var kept []uint64
+	if !clear && len(mentioned) > 0 {
+		var members []uint64
+		if err := db.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id IN ?", msg.RoomID, mentioned).
+			Pluck("user_id", &members).Error; err != nil {
+			return err
+		}
+		isMember := make(map[uint64]bool, len(members))
+		for _, id := range members {
+			isMember[id] = true
+		}
+		for _, id := range mentioned {
+			if isMember[id] {
+				kept = append(kept, id)
+				delete(isMember, id)
+			}
+		}
+	}
+	if len(kept) == 0 {
+		delete(fields, "mentioned_users")
+	} else {
+		fields["mentioned_users"], _ = json.Marshal(kept)
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	msg.Extra = datatypes.JSON(b)
+	return nil
+}
+
+// checkSenderStatus 被暂停/封禁的账号不能发消息
+func (s *MessageService) checkSenderStatus(db *gorm.DB, userID uint64) error {
+	var u models.User
+	if err := db.Select("id", "status", "suspend_until", "suspend_note").Where("id = ?", userID).First(&u).Error; err != nil {
+		return err
+	}
+	return checkAccountStatus(db, &u, time.Now())
+}
 
-	// 1. Check User Mute
-	if member.IsMuted && member.MutedUntil != nil && member.MutedUntil.After(now) {
-		return fmt.Errorf("你已经被禁至 %s", member.MutedUntil.Format("2006-01-02 15:04:05"))
+func (s *MessageService) checkMuteStatus(db *gorm.DB, roomID, userID uint64) error {
+	var room models.Room
+	if err := db.First(&room, roomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoomNotFound
+		}
+		return err
+	}
+	// 删好友后被禁止发送的私聊（FriendDeletePolicy.BlockPrivateSend）
+	if room.Type == 1 && room.IsOrphaned {
+		return ErrPrivateOrphaned
 	}
 
-	// 2. Check Global Mute (Countdown)
-	if room.IsMute && room.MuteUntil != nil && room.MuteUntil.After(now) {
-		return fmt.Errorf("群开启禁言至 %s", room.MuteUntil.Format("2006-01-02 15:04:05"))
+	var member models.RoomUser
+	if err := db.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotRoomMember
+		}
+		return err
 	}
 
-	// 3. Check Global Mute (Scheduled)
-	if room.MuteDailyDuration > 0 && room.MuteDailyStartTime != "" {
-		// Parse start time
-		t, err := time.Parse("15:04", room.MuteDailyStartTime)
-		if err == nil {
-			// Check two windows: starting today and starting yesterday
-			startToday := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
-			endToday := startToday.Add(time.Duration(room.MuteDailyDuration) * time.Minute)
+	if me := checkMemberMute(&room, &member, time.Now()); me != nil {
+		return me
+	}
+	return nil
+}
 
-			if now.After(startToday) && now.Before(endToday) {
-				return fmt.Errorf("群每日禁言 %s 禁言 %d分钟", room.MuteDailyStartTime, room.MuteDailyDuration)
-			}
+// RecallPolicy 撤回策略
+type RecallPolicy struct {
+	// Window 撤回时限（撤回自己的消息），<=0 表示不限制
+	Window time.Duration
+	// AdminRecallOthers 群主/管理员可撤回（双删）群内角色比自己低的成员的消息，不受时限限制
+	AdminRecallOthers bool
+	// Placeholder 撤回后保留占位（status=撤回，客户端展示"xx 撤回了一条消息"）；
+	// false 时按双删处理，消息对所有人直接消失
+	Placeholder bool
+}
 
-			startYesterday := startToday.Add(-24 * time.Hour)
-			endYesterday := startYesterday.Add(time.Duration(room.MuteDailyDuration) * time.Minute)
-			if now.After(startYesterday) && now.Before(endYesterday) {
-				return fmt.Errorf("群每日禁言 %s 禁言 %d分钟", room.MuteDailyStartTime, room.MuteDailyDuration)
-			}
+// DefaultRecallPolicy 默认策略：2 分钟内撤回自己的消息，保留占位
+var DefaultRecallPolicy = RecallPolicy{
+	Window:      2 * time.Minute,
+	Placeholder: true,
+}
+
+// recallRoleKey 房间内成员角色查询 key
+type recallRoleKey struct{ roomID, userID uint64 }
+
+// canRemoveForAll 判断 operator 能否让消息 m 对所有人消失（撤回/群聊双删），返回失败原因，空串表示允许。
+// checkWindow 仅撤回时为 true。
+func (p RecallPolicy) canRemoveForAll(m *models.Message, operatorID uint64, roomType uint8, roles map[recallRoleKey]uint8, checkWindow bool, now time.Time) string {
+	if m.SenderID == operatorID {
+		if checkWindow && p.Window > 0 && now.Sub(m.CreatedAt) > p.Window {
+			return "消息撤回时间已过"
+		}
+		return ""
+	}
+	if roomType == 2 && p.AdminRecallOthers {
+		opRole := roles[recallRoleKey{m.RoomID, operatorID}]
+		if opRole > 0 && opRole > roles[recallRoleKey{m.RoomID, m.SenderID}] {
+			return ""
 		}
 	}
+	if checkWindow {
+		return "撤回只能操作自己的消息"
+	}
+	return "群聊双删只能操作自己的消息"
+}
 
+// RecallMessage 撤回单条消息（按 RecallPolicy 校验）
+func (s *MessageService) RecallMessage(messageID, userID uint64) error {
+	_, failed, err := s.RecallMessages([]uint64{messageID}, userID, models.MessageStatusRecalled)
+	if err != nil {
+		return err
+	}
+	if reason, ok := failed[messageID]; ok {
+		return fmt.Errorf("%s", reason)
+	}
 	return nil
 }
 
@@ -225,7 +555,7 @@ func (s *MessageService) checkMuteStatus(roomID, userID uint64) error {
 func (s *MessageService) RecallMessages(messageIDs []uint64, userID uint64, recallType uint8) (okIDs []uint64, failed map[uint64]string, err error) {
 	failed = make(map[uint64]string)
 	if userID == 0 {
-		return nil, map[uint64]string{0: "user_id is required"}, fmt.Errorf("user_id is required")
+		return nil, map[uint64]string{0: "user_id is required"}, ErrUserIDRequired
 	}
 	if len(messageIDs) == 0 {
 		return []uint64{}, failed, nil
@@ -249,10 +579,8 @@ func (s *MessageService) RecallMessages(messageIDs []uint64, userID uint64, reca
 	}
 
 	// 批量查消息
-	var msgs []models.Message
-	if err := s.DB.Model(&models.Message{}).
-		Where("id IN ?", ids).
-		Find(&msgs).Error; err != nil {
+	msgs, err := s.findMessages(ids)
+	if err != nil {
 		return nil, nil, err
 	}
 	msgByID := make(map[uint64]models.Message, len(msgs))
@@ -288,22 +616,38 @@ func (s *MessageService) RecallMessages(messageIDs []uint64, userID uint64, reca
 		roomTypeByID[r.ID] = r.Type
 	}
 
-	now := time.Now()
-
-	// 单事务执行批量变更
-	tx := s.DB.Begin()
-	if tx.Error != nil {
-		return nil, nil, tx.Error
+	// 管理员撤回他人消息：批量查相关成员角色
+	policy := s.RecallPolicy
+	roles := make(map[recallRoleKey]uint8)
+	if policy.AdminRecallOthers && recallType != models.MessageStatusDeleted {
+		userIDs := []uint64{userID}
+		for _, m := range msgByID {
+			if m.SenderID != userID && roomTypeByID[m.RoomID] == 2 {
+				userIDs = append(userIDs, m.SenderID)
+			}
+		}
+		if len(userIDs) > 1 {
+			var members []models.RoomUser
+			if err := s.DB.Model(&models.RoomUser{}).
+				Select("room_id, user_id, role").
+				Where("room_id IN ? AND user_id IN ?", roomIDs, userIDs).
+				Find(&members).Error; err != nil {
+				return nil, nil, err
+			}
+			for _, ru := range members {
+				roles[recallRoleKey{ru.RoomID, ru.UserID}] = ru.Role
+			}
+		}
 	}
-	defer tx.Rollback()
+
+	now := time.Now()
 
 	// 需要更新 message.status 的 IDs（撤回/双删）
 	setStatusIDs := make([]uint64, 0, len(ids))
 	setStatusTo := 0
 
-	// 需要插入/更新 message_status（单删）
-	statusRows := make([]models.MessageStatus, 0)
-	statusUpdateIDs := make([]uint64, 0)
+	// 单删的 IDs（写入 message_status，用户维度）
+	deleteIDs := make([]uint64, 0)
 
 	for _, id := range ids {
 		m, ok := msgByID[id]
@@ -313,29 +657,26 @@ func (s *MessageService) RecallMessages(messageIDs []uint64, userID uint64, reca
 
 		switch recallType {
 		case models.MessageStatusRecalled:
-			if m.SenderID != userID {
-				failed[id] = "撤回只能操作自己的消息"
-				continue
-			}
-			if now.Sub(m.CreatedAt) > 2*time.Minute {
-				failed[id] = "消息撤回时间已过"
+			if reason := policy.canRemoveForAll(&m, userID, roomTypeByID[m.RoomID], roles, true, now); reason != "" {
+				failed[id] = reason
 				continue
 			}
 			setStatusIDs = append(setStatusIDs, id)
 			setStatusTo = models.MessageStatusRecalled
+			if !policy.Placeholder {
+				setStatusTo = models.MessageStatusBothDeleted
+			}
 			okIDs = append(okIDs, id)
 
 		case models.MessageStatusDeleted:
-			// 单删：写入 message_status（用户维度）
-			statusRows = append(statusRows, models.MessageStatus{UserID: userID, MessageID: id, RoomID: m.RoomID, IsDeleted: true, CreatedAt: now, UpdatedAt: now})
-			statusUpdateIDs = append(statusUpdateIDs, id)
+			deleteIDs = append(deleteIDs, id)
 			okIDs = append(okIDs, id)
 
 		case models.MessageStatusBothDeleted:
-			// 群聊双删：只能删除自己的
+			// 群聊双删：只能删除自己的（策略允许时管理员可删成员的）
 			if roomTypeByID[m.RoomID] == 2 {
-				if m.SenderID != userID {
-					failed[id] = "群聊双删只能操作自己的消息"
+				if reason := policy.canRemoveForAll(&m, userID, 2, roles, false, now); reason != "" {
+					failed[id] = reason
 					continue
 				}
 			}
@@ -348,29 +689,38 @@ func (s *MessageService) RecallMessages(messageIDs []uint64, userID uint64, reca
 		}
 	}
 
-	// 批量更新 message.status
-	if len(setStatusIDs) > 0 {
-		if err := tx.Model(&models.Message{}).
-			Where("id IN ?", setStatusIDs).
-			Update("status", setStatusTo).Error; err != nil {
-			return nil, nil, err
+	// 单事务执行批量变更（经由 Store，默认 GORM 实现绑定到本事务）
+	err = s.Tx(func(tx *gorm.DB) error {
+		store := s.storeTx(tx)
+		// 更新 message.status（合规模式下先归档原内容）
+		if len(setStatusIDs) > 0 {
+			event := ArchiveEventDeleted
+			if setStatusTo == models.MessageStatusRecalled {
+				event = ArchiveEventRecalled
+			}
+			archived := make([]models.Message, 0, len(setStatusIDs))
+			for _, id := range setStatusIDs {
+				archived = append(archived, msgByID[id])
+			}
+			if err := s.Archive.record(tx, event, userID, archived); err != nil {
+				return err
+			}
+			for _, id := range setStatusIDs {
+				if err := store.UpdateStatus(id, setStatusTo); err != nil {
+					return err
+				}
+			}
 		}
-	}
 
-	// 单删：批量 upsert message_status.is_deleted=true
-	if len(statusRows) > 0 {
-		// 先插入（唯一键冲突则忽略），再统一 update
-		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&statusRows).Error; err != nil {
-			return nil, nil, err
-		}
-		if err := tx.Model(&models.MessageStatus{}).
-			Where("user_id = ? AND message_id IN ?", userID, statusUpdateIDs).
-			Updates(map[string]any{"is_deleted": true, "updated_at": now}).Error; err != nil {
-			return nil, nil, err
+		// 单删：message_status.is_deleted=true（重复单删幂等）
+		for _, id := range deleteIDs {
+			if err := store.DeleteForUser(userID, id); err != nil {
+				return err
+			}
 		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
+		return nil
+	})
+	if err != nil {
 		return nil, nil, err
 	}
 
@@ -400,23 +750,20 @@ func (s *MessageService) RecallMessages(messageIDs []uint64, userID uint64, reca
 				"room_id":      roomID,
 				"operator_id":  userID,
 				"operator_uid": userID,
+				"placeholder":  recallType == models.MessageStatusRecalled && policy.Placeholder,
 			}
 
 			// 有 Notify 就用统一通知落库+WS；没有则保留旧 WS notifier
 			if s.Notify != nil {
 				_, _ = s.Notify.PublishRoomEvent(roomID, userID, EventRecall, payload, members, true)
-			} else if s.WsNotifier != nil {
-				notification := map[string]any{
-					"type":        EventRecall,
-					"recall_type": recallType,
-					"message_ids": mids,
-					"room_id":     roomID,
-					"user_id":     userID,
-				}
-				b, _ := json.Marshal(notification)
-				for _, memberID := range members {
-					s.WsNotifier(memberID, b)
-				}
+			} else {
+				s.Emit(members, &message.RecallEvent{
+					RecallType:  recallType,
+					MessageIDs:  mids,
+					RoomID:      roomID,
+					UserID:      userID,
+					Placeholder: recallType == models.MessageStatusRecalled && policy.Placeholder,
+				})
 			}
 		}
 	}
@@ -426,32 +773,104 @@ func (s *MessageService) RecallMessages(messageIDs []uint64, userID uint64, reca
 
 // GetRoomMessages 获取房间消息列表（分页）
 func (s *MessageService) GetRoomMessages(roomID uint64, limit, offset int) ([]models.Message, error) {
-	dao := s.messageDAO
-	return dao.FindByRoomID(roomID, limit, offset)
+	return s.Store.FindByRoomID(roomID, limit, offset)
 }
 
-// GetRoomMessagesDTO 获取房间消息列表（分页，带发送人信息，返回 DTO）
-func (s *MessageService) GetRoomMessagesDTO(roomID uint64, limit, messID int) ([]MessageListItemDTO, error) {
-	var msgs []models.Message
-	// 这里不走 DAO：需要 preload sender
-	//err
-	query := s.DB.Model(&models.Message{}).
+// GetRoomMessagesDTO 获取房间消息列表（分页，带发送人信息，返回 DTO），按 viewerID 视角过滤：
+// 双删的消息对所有人隐藏，viewer 单删（message_status.is_deleted）的消息只对其隐藏，撤回的消息返回不含内容的占位。
+func (s *MessageService) GetRoomMessagesDTO(roomID, viewerID uint64, limit, messID int) ([]MessageListItemDTO, error) {
+	// 这里不走 DAO：需要 preload sender；热表不足一页时从冷表补齐
+	msgs, err := s.findVisible(roomID, viewerID, func(q *gorm.DB) *gorm.DB {
+		if messID > 0 {
+			q = q.Where("id < ?", messID)
+		}
+		return q
+	}, "created_at DESC", limit, false)
+	if err != nil {
+		return nil, err
+	}
+	out := toMessageListItemDTOs(msgs)
+	if err := s.fillSenderNames(viewerID, roomID, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// visibleMessages viewerID 在房间中能看到的消息（带 sender）：排除双删与 viewer 单删的；cold 为 true 时查冷表
+func (s *MessageService) visibleMessages(roomID, viewerID uint64, cold bool) *gorm.DB {
+	var model any = &models.Message{}
+	msgTable := models.Message{}.TableName()
+	if cold {
+		model, msgTable = &models.MessageCold{}, models.MessageCold{}.TableName()
+	}
+	return s.ReadDB(ReadScopeMessage).Model(model).
 		Preload("Sender").
-		Where("room_id = ?", roomID)
-	if messID > 0 {
-		query = query.Where("id < ?", messID)
+		Where("room_id = ? AND status <> ?", roomID, models.MessageStatusBothDeleted).
+		Where("NOT EXISTS (SELECT 1 FROM "+models.MessageStatus{}.TableName()+" AS ms WHERE ms.message_id = "+msgTable+".id AND ms.user_id = ? AND ms.is_deleted = ?)", viewerID, true)
+}
+
+// fillSenderNames 按 viewer 视角填写发送人展示名
+func (s *MessageService) fillSenderNames(viewerID, roomID uint64, out []MessageListItemDTO) error {
+	if s.DisplayNames == nil || len(out) == 0 {
+		return nil
+	}
+	senderIDs := make([]uint64, 0, len(out))
+	for _, m := range out {
+		senderIDs = append(senderIDs, m.SenderID)
 	}
-	err := query.Order("created_at DESC").
-		Limit(limit).
-		Find(&msgs).Error
+	names, err := s.DisplayNames.Resolve(viewerID, roomID, senderIDs)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	for i := range out {
+		out[i].SenderName = names[out[i].SenderID]
 	}
-	return toMessageListItemDTOs(msgs), nil
+	return nil
 }
 
 // GetMessageByID 根据ID获取消息
 func (s *MessageService) GetMessageByID(messageID uint64) (*models.Message, error) {
-	dao := s.messageDAO
-	return dao.FindByID(messageID)
+	msg, err := s.Store.FindByID(messageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) && s.coldEnabled() {
+		msg, err = s.findColdByID(messageID)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 阅后即焚内容只能通过 OpenViewOnceMessage 获取
+	if msg.IsViewOnce {
+		msg.Content, msg.Extra = "", viewOnceExtra
+	}
+	return msg, nil
+}
+
+// GetMessageForViewer 以 viewerID 的视角获取单条消息（消息详情接口）：
+// 仅房间成员可查看；双删与 viewer 单删的消息按不存在处理；撤回只保留占位、阅后即焚不返回内容（同消息列表）。
+func (s *MessageService) GetMessageForViewer(messageID, viewerID uint64) (*MessageDTO, error) {
+	msg, err := s.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	var n int64
+	if err := s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", msg.RoomID, viewerID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNotRoomMember
+	}
+	if msg.Status == models.MessageStatusBothDeleted {
+		return nil, ErrMessageNotFound
+	}
+	if err := s.DB.Model(&models.MessageStatus{}).
+		Where("message_id = ? AND user_id = ? AND is_deleted = ?", messageID, viewerID, true).
+		Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, ErrMessageNotFound
+	}
+	return ToMessageDTO(msg), nil
 }
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/glebarez/sqlite"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestValidateVoiceExtra(t *testing.T) {
+	cases := []struct {
+		name  string
+		voice *message.VoiceInfo
+		key   string // 期望的错误 key，空表示通过
+	}{
+		{"missing", nil, "err.voice_required"},
+		{"ok", &message.VoiceInfo{DurationMs: 3200, Waveform: []int{1, 20, 255}, Codec: "OPUS"}, ""},
+		{"zero duration", &message.VoiceInfo{DurationMs: 0, Codec: "opus"}, "err.voice_duration_invalid"},
+		{"too long", &message.VoiceInfo{DurationMs: 61000, Codec: "aac"}, "err.voice_too_long"},
+		{"bad codec", &message.VoiceInfo{DurationMs: 1000, Codec: "wav"}, "err.voice_codec"},
+		{"bad sample", &message.VoiceInfo{DurationMs: 1000, Codec: "opus", Waveform: []int{256}}, "err.voice_waveform_value"},
+		{"too many samples", &message.VoiceInfo{DurationMs: 1000, Codec: "amr", Waveform: make([]int, message.VoiceMaxWaveformSamples+1)}, "err.voice_waveform_too_long"},
+	}
+	for _, c := range cases {
+		extra := message.Extra{Voice: c.voice}
+		err := validateVoiceExtra(&extra, 0)
+		if c.key == "" {
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+			}
+			continue
+		}
+		var se *Error
+		if !errors.As(err, &se) || se.Key != c.key || se.Code != response.CodeParamError {
+			t.Errorf("%s: err=%v, want %s", c.name, err, c.key)
+		}
+	}
+
+	// 自定义最大时长
+	extra := message.Extra{Voice: &message.VoiceInfo{DurationMs: 90000, Codec: "opus"}}
+	if err := validateVoiceExtra(&extra, 2*time.Minute); err != nil {
+		t.Errorf("custom max duration: %v", err)
+	}
+	if extra.Voice.Codec != "opus" {
+		t.Errorf("codec should be normalized, got %q", extra.Voice.Codec)
+	}
+}
+
+func TestValidateExtra(t *testing.T) {
+	cases := []struct {
+		name    string
+		msgType uint8
+		content string
+		extra   message.Extra
+		key     string // 期望的错误 key，空表示通过
+	}{
+		{"text", 1, "hi", message.Extra{MentionedUsers: []uint64{2}}, ""},
+		{"text with location", 1, "hi", message.Extra{Location: &message.LocationInfo{}}, "err.extra_not_allowed"},
+		{"text mention zero", 1, "hi", message.Extra{MentionedUsers: []uint64{0}}, "err.extra_invalid"},
+		{"image", 2, "https://cdn/a.png", message.Extra{}, ""},
+		{"image no url", 2, " ", message.Extra{}, "err.extra_required"},
+		{"file", 5, "https://cdn/a.pdf", message.Extra{FileInfo: &message.FileInfo{Name: "a.pdf", Size: 10, Ext: ".PDF"}}, ""},
+		{"file negative size", 5, "https://cdn/a.pdf", message.Extra{FileInfo: &message.FileInfo{Name: "a.pdf", Size: -1}}, "err.extra_out_of_range"},
+		{"file bad name", 5, "https://cdn/a.pdf", message.Extra{FileInfo: &message.FileInfo{Name: "../a.pdf"}}, "err.extra_invalid"},
+		{"voice missing", 3, "https://cdn/a.opus", message.Extra{}, "err.extra_required"},
+		{"location", 6, "", message.Extra{Location: &message.LocationInfo{Latitude: 31.2, Longitude: 121.5}}, ""},
+		{"location missing", 6, "", message.Extra{}, "err.extra_required"},
+		{"location lat", 6, "", message.Extra{Location: &message.LocationInfo{Latitude: 91}}, "err.extra_out_of_range"},
+		{"quote", 7, "ok", message.Extra{MessageID: 9}, ""},
+		{"quote missing", 7, "ok", message.Extra{}, "err.extra_required"},
+		{"mention", 8, "@a", message.Extra{MentionedUsers: []uint64{2, 3}}, ""},
+		{"mention empty", 8, "@a", message.Extra{}, "err.extra_required"},
+		{"mention too many", 8, "@a", message.Extra{MentionedUsers: make([]uint64, message.ExtraMaxMentions+1)}, "err.extra_too_long"},
+		{"client red packet", 1, "hi", message.Extra{RedPacket: &message.RedPacketInfo{}}, "err.extra_not_allowed"},
+		{"poll", models.MessageTypePoll, "q", message.Extra{Poll: &message.PollInfo{PollID: 1}}, ""},
+		{"custom type", 99, "", message.Extra{Location: &message.LocationInfo{Latitude: 200}}, ""},
+	}
+	for _, c := range cases {
+		err := validateExtra(c.msgType, c.content, &c.extra)
+		if c.key == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected err %v", c.name, err)
+			}
+			continue
+		}
+		var e *Error
+		if !errors.As(err, &e) || e.Key != c.key || e.Code != response.CodeParamError {
+			t.Errorf("%s: err=%v, want %s", c.name, err, c.key)
+		}
+	}
+
+	// 可修正字段：扩展名统一为小写、去掉点
+	extra := message.Extra{FileInfo: &message.FileInfo{Name: "a.pdf", Ext: ".PDF"}}
+	if err := validateExtra(5, "https://cdn/a.pdf", &extra); err != nil || extra.FileInfo.Ext != "pdf" {
+		t.Fatalf("ext=%q err=%v", extra.FileInfo.Ext, err)
+	}
+
+	// 自定义消息类型
+	message.RegisterExtraSchema(200, message.ExtraSchema{Fields: []string{message.ExtraFieldFileInfo}})
+	defer message.RegisterExtraSchema(200, message.ExtraSchema{})
+	if err := validateExtra(200, "", &message.Extra{FileInfo: &message.FileInfo{}}); err != nil {
+		t.Fatalf("custom schema: %v", err)
+	}
+	if err := validateExtra(200, "", &message.Extra{Voice: &message.VoiceInfo{}}); err == nil {
+		t.Fatalf("custom schema should reject voice")
+	}
+}
+
+func TestExtraAccessors(t *testing.T) {
+	raw := []byte(`{"location":{"lat":1.5,"lng":2.5,"address":"x"},"mentioned_users":[3],"message_id":7}`)
+	if l := message.LocationOf(6, raw); l == nil || l.Latitude != 1.5 || l.Address != "x" {
+		t.Fatalf("location=%#v", l)
+	}
+	if message.LocationOf(1, raw) != nil || message.FileOf(6, raw) != nil {
+		t.Fatalf("accessor should check message type")
+	}
+	if m := message.MentionsOf(raw); len(m) != 1 || m[0] != 3 || message.QuoteOf(raw) != 7 {
+		t.Fatalf("mentions=%v quote=%d", m, message.QuoteOf(raw))
+	}
+	payload := buildMergeForwardPayload(1, []models.Message{{ID: 5, Type: 1, Content: "hi"}}, "")
+	b, _ := json.Marshal(payload)
+	if f := message.MergeForwardOf(b); f == nil || f.Count != 1 || f.Items[0].ID != 5 {
+		t.Fatalf("merge forward=%#v", f)
+	}
+	if message.MergeForwardOf(raw) != nil {
+		t.Fatalf("plain extra is not a merge forward")
+	}
+}
+
+func TestVoiceFromExtra(t *testing.T) {
+	raw := datatypes.JSON(`{"voice":{"duration_ms":1500,"waveform":[1,2,3],"codec":"opus"}}`)
+	v := voiceFromExtra(3, raw)
+	if v == nil || v.DurationMs != 1500 || len(v.Waveform) != 3 || v.Codec != "opus" {
+		t.Fatalf("unexpected voice: %#v", v)
+	}
+	if voiceFromExtra(1, raw) != nil {
+		t.Fatalf("non-voice message should not expose voice")
+	}
+}
+
+func TestRecallPolicy_CanRemoveForAll(t *testing.T) {
+	now := time.Now()
+	own := &models.Message{RoomID: 1, SenderID: 1, CreatedAt: now.Add(-5 * time.Minute)}
+	other := &models.Message{RoomID: 1, SenderID: 2, CreatedAt: now}
+	roles := map[recallRoleKey]uint8{{1, 1}: 1, {1, 2}: 0, {1, 3}: 2}
+
+	p := DefaultRecallPolicy
+	if r := p.canRemoveForAll(own, 1, 2, roles, true, now); r == "" {
+		t.Fatalf("default window should reject 5min old message")
+	}
+	if r := p.canRemoveForAll(own, 1, 2, roles, false, now); r != "" {
+		t.Fatalf("both-delete has no window: %s", r)
+	}
+	if r := p.canRemoveForAll(other, 1, 2, roles, true, now); r == "" {
+		t.Fatalf("admin recall disabled by default")
+	}
+
+	p = RecallPolicy{Window: 10 * time.Minute, AdminRecallOthers: true}
+	if r := p.canRemoveForAll(own, 1, 2, roles, true, now); r != "" {
+		t.Fatalf("within window: %s", r)
+	}
+	if r := p.canRemoveForAll(other, 1, 2, roles, true, now); r != "" {
+		t.Fatalf("admin should recall member: %s", r)
+	}
+	if r := p.canRemoveForAll(other, 1, 1, roles, true, now); r == "" {
+		t.Fatalf("admin recall only applies to groups")
+	}
+	// 管理员不能撤回群主的消息
+	ownerMsg := &models.Message{RoomID: 1, SenderID: 3, CreatedAt: now}
+	if r := p.canRemoveForAll(ownerMsg, 1, 2, roles, true, now); r == "" {
+		t.Fatalf("admin must not recall owner's message")
+	}
+}
+
+func TestMessageService_RecallMessages_AdminNoPlaceholder(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewMessageService(&Service{DB: gormDB})
+	svc.RecallPolicy = RecallPolicy{Window: time.Minute, AdminRecallOthers: true}
+	old := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "created_at"}).AddRow(8, 1, 2, old))
+	mock.ExpectQuery("SELECT id, type FROM `im_room`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(1, 2))
+	mock.ExpectQuery("SELECT room_id, user_id, role FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "user_id", "role"}).AddRow(1, 1, 2).AddRow(1, 2, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_message` SET `status`=\\?").
+		WithArgs(models.MessageStatusBothDeleted, sqlmock.AnyArg(), 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT `user_id` FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	if err := svc.RecallMessage(8, 1); err != nil {
+		t.Fatalf("RecallMessage: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMessageService_GetRoomMessagesDTO_ViewerFilter(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewMessageService(&Service{DB: gormDB})
+
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE \\(room_id = \\? AND status <> \\?\\) AND \\(NOT EXISTS \\(SELECT 1 FROM im_message_status AS ms "+
+		"WHERE ms.message_id = im_message.id AND ms.user_id = \\? AND ms.is_deleted = \\?\\)\\) AND id < \\? AND `im_message`.`deleted_at` IS NULL ORDER BY created_at DESC LIMIT \\?").
+		WithArgs(1, models.MessageStatusBothDeleted, 3, true, 100, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "content", "extra", "status"}).
+			AddRow(9, 1, 2, "secret", []byte(`{"at":[3]}`), models.MessageStatusRecalled).
+			AddRow(8, 1, 2, "hello", nil, models.MessageStatusRead))
+	mock.ExpectQuery("SELECT \\* FROM `im_user` WHERE `im_user`.`id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(2, "bob"))
+
+	list, err := svc.GetRoomMessagesDTO(1, 3, 20, 100)
+	if err != nil {
+		t.Fatalf("GetRoomMessagesDTO: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("want 2 messages, got %d", len(list))
+	}
+	if r := list[0]; r.Status != models.MessageStatusRecalled || r.Content != "" || r.Extra != nil || r.Sender == nil {
+		t.Fatalf("recalled placeholder: %+v", r)
+	}
+	if list[1].Content != "hello" {
+		t.Fatalf("normal message: %+v", list[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 消息按房间分配连续序号，DTO 带 room_seq 与服务端 send_time
+func TestMessageService_RoomSeq(t *testing.T) {
+	dsn := fmt.Sprintf("file:room_seq_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Room{}, &models.Message{}, &models.Conversation{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for _, id := range []uint64{1, 2} {
+		if err := db.Create(&models.Room{ID: id, RoomAccount: fmt.Sprint("r", id), Type: 2}).Error; err != nil {
+			t.Fatalf("create room: %v", err)
+		}
+	}
+	s := NewMessageService(&Service{DB: db})
+
+	var got []uint64
+	for _, roomID := range []uint64{1, 1, 2, 1} {
+		msg, err := s.SaveSystemMessage(roomID, 1, "hi", message.Extra{})
+		if err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		got = append(got, msg.Seq)
+	}
+	if fmt.Sprint(got) != "[1 2 1 3]" {
+		t.Fatalf("seqs: %v", got)
+	}
+	var stored models.Message
+	if err := db.Where("room_id = ? AND seq = ?", 1, 3).First(&stored).Error; err != nil {
+		t.Fatalf("stored: %v", err)
+	}
+	dto := ToMessageDTO(&stored)
+	if dto.RoomSeq != 3 || dto.SendTime != stored.CreatedAt.UnixMilli() || dto.SendTime == 0 {
+		t.Fatalf("dto: %+v", dto)
+	}
+	if item := toMessageListItemDTO(&stored); item.RoomSeq != 3 || item.SendTime != dto.SendTime {
+		t.Fatalf("list item: %+v", item)
+	}
+}
+
+func TestMessageService_StoreWrites(t *testing.T) {
+	dsn := fmt.Sprintf("file:store_writes_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Room{}, &models.Message{}, &models.Conversation{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&models.Room{ID: 1, RoomAccount: "r1", Type: 2}).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	store := NewMemoryMessageStore()
+	s := NewMessageService(&Service{DB: db})
+	s.Store = store
+
+	// 发送经由 Store：消息写入替换的后端，房间序号与 last_message_id 仍在关系库
+	a, err := s.SaveSystemMessage(1, 1, "a", message.Extra{})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	b, err := s.SaveSystemMessage(1, 1, "b", message.Extra{})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	var n int64
+	db.Model(&models.Message{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("messages should not be written to DB, got %d", n)
+	}
+	if got, err := store.FindByID(b.ID); err != nil || got.Content != "b" || got.Seq != 2 {
+		t.Fatalf("store: %+v %v", got, err)
+	}
+	var room models.Room
+	db.First(&room, 1)
+	if room.LastMessageID == nil || *room.LastMessageID != b.ID {
+		t.Fatalf("last_message_id: %v", room.LastMessageID)
+	}
+
+	// 撤回与单删同样经由 Store
+	if err := s.RecallMessage(a.ID, 1); err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if got, _ := store.FindByID(a.ID); got.Status != models.MessageStatusRecalled {
+		t.Fatalf("recalled status: %d", got.Status)
+	}
+	if ok, failed, err := s.RecallMessages([]uint64{b.ID}, 1, models.MessageStatusDeleted); err != nil || len(ok) != 1 || len(failed) != 0 {
+		t.Fatalf("delete for user: ok=%v failed=%v err=%v", ok, failed, err)
+	}
+	if mine, _ := store.FindByRoomIDForUser(1, 1, 10, 0); len(mine) != 1 || mine[0].ID != a.ID {
+		t.Fatalf("for user 1: %+v", mine)
+	}
+}
+
+func TestMessageService_CheckMuteStatusErrors(t *testing.T) {
+	dsn := fmt.Sprintf("file:mute_status_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Room{}, &models.RoomUser{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.Create(&models.Room{ID: 1, RoomAccount: "r1", Type: 2})
+	db.Create(&models.Room{ID: 2, RoomAccount: "r2", Type: 1, IsOrphaned: true})
+	db.Create(&models.RoomUser{RoomID: 1, UserID: 1})
+	s := NewMessageService(&Service{DB: db})
+
+	if err := s.checkMuteStatus(db, 1, 1); err != nil {
+		t.Fatalf("member: %v", err)
+	}
+	cases := []struct {
+		roomID, userID uint64
+		want           *Error
+	}{
+		{1, 2, ErrNotRoomMember},
+		{2, 1, ErrPrivateOrphaned},
+		{9, 1, ErrRoomNotFound},
+	}
+	for _, c := range cases {
+		if err := s.checkMuteStatus(db, c.roomID, c.userID); !errors.Is(err, c.want) {
+			t.Errorf("room %d user %d: got %v, want %s", c.roomID, c.userID, err, c.want.Key)
+		}
+	}
+}
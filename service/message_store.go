@@ -0,0 +1,141 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/gorm"
+)
+
+// MessageStore 消息存储的基础读写。默认由 models.MessageDAO（GORM，MySQL 等关系库）实现，
+// 可通过 chat_sdk.WithMessageStore 替换为其他后端（如 MongoDB 文档存储、ClickHouse 分析型读取），
+// 实现需通过与 GORM 实现相同的行为约定（见 message_store_test.go）。
+//
+// 发送（Create）、撤回/双删（UpdateStatus）、单删（DeleteForUser）以及按 ID / 按房间读取都经由 MessageStore：
+// 默认 GORM 实现会绑定到发送/撤回的事务上，与序号分配、会话投影一起提交；替换的后端不参与关系库事务。
+// 转发、回执、搜索、冷热分层、批量导入等直接按消息表查询的功能仍依赖关系库中的消息数据。
+type MessageStore interface {
+	Create(msg *models.Message) error
+	// FindByID 找不到时返回 gorm.ErrRecordNotFound
+	FindByID(id uint64) (*models.Message, error)
+	// FindByRoomID 房间消息，按发送时间倒序
+	FindByRoomID(roomID uint64, limit, offset int) ([]models.Message, error)
+	// FindByRoomIDForUser 同 FindByRoomID，但排除双删与 userID 单删的消息
+	FindByRoomIDForUser(roomID, userID uint64, limit, offset int) ([]models.Message, error)
+	UpdateStatus(id uint64, status int) error
+	UpdateContent(id uint64, content string) error
+	// DeleteForUser 单删：仅对 userID 不可见
+	DeleteForUser(userID, messageID uint64) error
+	// DeleteForEveryone 双删：对所有人不可见
+	DeleteForEveryone(messageID uint64) error
+}
+
+var _ MessageStore = (*models.MessageDAO)(nil)
+
+// MemoryMessageStore 进程内的 MessageStore，用于单元测试与单机演示，重启后数据丢失
+type MemoryMessageStore struct {
+	mu      sync.RWMutex
+	nextID  uint64
+	msgs    map[uint64]models.Message
+	deleted map[[2]uint64]bool // {userID, messageID} 单删
+}
+
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{msgs: make(map[uint64]models.Message), deleted: make(map[[2]uint64]bool)}
+}
+
+func (m *MemoryMessageStore) Create(msg *models.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if msg.ID == 0 {
+		m.nextID++
+		msg.ID = m.nextID
+	} else if msg.ID > m.nextID {
+		m.nextID = msg.ID
+	}
+	now := time.Now()
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = now
+	}
+	msg.UpdatedAt = now
+	m.msgs[msg.ID] = *msg
+	return nil
+}
+
+func (m *MemoryMessageStore) FindByID(id uint64) (*models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	msg, ok := m.msgs[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &msg, nil
+}
+
+func (m *MemoryMessageStore) FindByRoomID(roomID uint64, limit, offset int) ([]models.Message, error) {
+	return m.find(roomID, limit, offset, func(*models.Message) bool { return true }), nil
+}
+
+func (m *MemoryMessageStore) FindByRoomIDForUser(roomID, userID uint64, limit, offset int) ([]models.Message, error) {
+	return m.find(roomID, limit, offset, func(msg *models.Message) bool {
+		return msg.Status != models.MessageStatusBothDeleted && !m.deleted[[2]uint64{userID, msg.ID}]
+	}), nil
+}
+
+// find 按发送时间倒序（同一时间按 ID 倒序）分页
+func (m *MemoryMessageStore) find(roomID uint64, limit, offset int, keep func(*models.Message) bool) []models.Message {
+	m.mu.RLock()
+	out := make([]models.Message, 0)
+	for _, msg := range m.msgs {
+		if msg.RoomID == roomID && keep(&msg) {
+			out = append(out, msg)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID > out[j].ID
+	})
+	if offset >= len(out) {
+		return []models.Message{}
+	}
+	out = out[max(offset, 0):]
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func (m *MemoryMessageStore) update(id uint64, fn func(*models.Message)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if msg, ok := m.msgs[id]; ok {
+		fn(&msg)
+		msg.UpdatedAt = time.Now()
+		m.msgs[id] = msg
+	}
+	return nil
+}
+
+func (m *MemoryMessageStore) UpdateStatus(id uint64, status int) error {
+	return m.update(id, func(msg *models.Message) { msg.Status = uint8(status) })
+}
+
+func (m *MemoryMessageStore) UpdateContent(id uint64, content string) error {
+	return m.update(id, func(msg *models.Message) { msg.Content = content })
+}
+
+func (m *MemoryMessageStore) DeleteForUser(userID, messageID uint64) error {
+	m.mu.Lock()
+	m.deleted[[2]uint64{userID, messageID}] = true
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryMessageStore) DeleteForEveryone(messageID uint64) error {
+	return m.UpdateStatus(messageID, models.MessageStatusBothDeleted)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testMessageStoreContract 各 MessageStore 实现共用的行为约定
+func testMessageStoreContract(t *testing.T, store MessageStore) {
+	t.Helper()
+
+	if _, err := store.FindByID(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("find missing: %v", err)
+	}
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var ids []uint64
+	for i := 0; i < 4; i++ {
+		msg := &models.Message{RoomID: 1, SenderID: 2, Type: 1, Content: fmt.Sprintf("m%d", i), Status: models.MessageStatusSent, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := store.Create(msg); err != nil || msg.ID == 0 {
+			t.Fatalf("create: id=%d err=%v", msg.ID, err)
+		}
+		ids = append(ids, msg.ID)
+	}
+	if err := store.Create(&models.Message{RoomID: 2, SenderID: 2, Type: 1, Content: "other", CreatedAt: base}); err != nil {
+		t.Fatalf("create other room: %v", err)
+	}
+
+	got, err := store.FindByID(ids[1])
+	if err != nil || got.Content != "m1" || got.RoomID != 1 {
+		t.Fatalf("find: %+v %v", got, err)
+	}
+
+	list, err := store.FindByRoomID(1, 2, 1)
+	if err != nil || len(list) != 2 || list[0].Content != "m2" || list[1].Content != "m1" {
+		t.Fatalf("page: %+v %v", list, err)
+	}
+
+	if err := store.UpdateContent(ids[0], "edited"); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	if err := store.UpdateStatus(ids[0], models.MessageStatusRecalled); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	if got, _ := store.FindByID(ids[0]); got.Content != "edited" || got.Status != models.MessageStatusRecalled {
+		t.Fatalf("updated: %+v", got)
+	}
+
+	if err := store.DeleteForEveryone(ids[3]); err != nil {
+		t.Fatalf("delete for everyone: %v", err)
+	}
+	if err := store.DeleteForUser(7, ids[2]); err != nil {
+		t.Fatalf("delete for user: %v", err)
+	}
+	// 重复单删幂等
+	if err := store.DeleteForUser(7, ids[2]); err != nil {
+		t.Fatalf("delete for user again: %v", err)
+	}
+	mine, err := store.FindByRoomIDForUser(1, 7, 10, 0)
+	if err != nil || len(mine) != 2 || mine[0].ID != ids[1] || mine[1].ID != ids[0] {
+		t.Fatalf("for user 7: %+v %v", mine, err)
+	}
+	others, err := store.FindByRoomIDForUser(1, 8, 10, 0)
+	if err != nil || len(others) != 3 {
+		t.Fatalf("for user 8: %+v %v", others, err)
+	}
+	if all, _ := store.FindByRoomID(1, 10, 0); len(all) != 4 {
+		t.Fatalf("FindByRoomID should not filter deletions: %d", len(all))
+	}
+}
+
+func TestMessageStore_GormDAO(t *testing.T) {
+	dsn := fmt.Sprintf("file:message_store_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Message{}, &models.MessageStatus{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	testMessageStoreContract(t, models.NewMessageDAO(db))
+}
+
+func TestMessageStore_Memory(t *testing.T) {
+	testMessageStoreContract(t, NewMemoryMessageStore())
+}
+
+func TestMessageService_CustomStore(t *testing.T) {
+	store := NewMemoryMessageStore()
+	_ = store.Create(&models.Message{RoomID: 1, SenderID: 2, Content: "hi", IsViewOnce: true})
+	svc := NewMessageService(&Service{})
+	svc.Store = store
+
+	msg, err := svc.GetMessageByID(1)
+	if err != nil || msg.Content != "" {
+		t.Fatalf("view-once content should be hidden: %+v %v", msg, err)
+	}
+	if list, err := svc.GetRoomMessages(1, 10, 0); err != nil || len(list) != 1 {
+		t.Fatalf("GetRoomMessages: %+v %v", list, err)
+	}
+}
@@ -80,7 +80,7 @@ func (s *MomentService) CreateMoment(userID uint64, req CreateMomentReq) (Moment
 	}
 
 	var result MomentDTO
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
+	err := s.Tx(func(tx *gorm.DB) error {
 		m := models.Moment{
 			UserID:      userID,
 			Title:       req.Title,
@@ -124,11 +124,12 @@ func (s *MomentService) ListFriendMoments(userID uint64, limit, offset int) ([]M
 	if limit <= 0 {
 		limit = 20
 	}
+	db := s.ReadDB(ReadScopeMoment)
 
 	// 获取好友ID（双向容错）
 	var a, b []uint64
-	s.DB.Model(&models.Friend{}).Where("user_id = ? AND status = 1", userID).Pluck("friend_id", &a)
-	s.DB.Model(&models.Friend{}).Where("friend_id = ? AND status = 1", userID).Pluck("user_id", &b)
+	db.Model(&models.Friend{}).Where("user_id = ? AND status = 1", userID).Pluck("friend_id", &a)
+	db.Model(&models.Friend{}).Where("friend_id = ? AND status = 1", userID).Pluck("user_id", &b)
 	idset := map[uint64]struct{}{userID: {}}
 	for _, id := range a {
 		idset[id] = struct{}{}
@@ -146,7 +147,7 @@ func (s *MomentService) ListFriendMoments(userID uint64, limit, offset int) ([]M
 
 	// 查询动态
 	var moments []models.Moment
-	if err := s.DB.Where("user_id IN ?", ids).
+	if err := db.Where("user_id IN ?", ids).
 		Order("created_at DESC").Limit(limit).Offset(offset).Find(&moments).Error; err != nil {
 		return nil, err
 	}
@@ -160,7 +161,7 @@ func (s *MomentService) ListFriendMoments(userID uint64, limit, offset int) ([]M
 		momentIDs[i] = m.ID
 	}
 	var medias []models.MomentMedia
-	if err := s.DB.Where("moment_id IN ?", momentIDs).Order("sort_order ASC").Find(&medias).Error; err != nil {
+	if err := db.Where("moment_id IN ?", momentIDs).Order("sort_order ASC").Find(&medias).Error; err != nil {
 		return nil, err
 	}
 	mediaMap := make(map[uint64][]models.MomentMedia)
@@ -171,7 +172,7 @@ func (s *MomentService) ListFriendMoments(userID uint64, limit, offset int) ([]M
 	// 额外：批量拉取评论（每条动态最多带最近 N 条）
 	const maxCommentsPerMoment = 20
 	var comments []models.MomentComment
-	if err := s.DB.Where("moment_id IN ?", momentIDs).
+	if err := db.Where("moment_id IN ?", momentIDs).
 		Order("created_at DESC").
 		Find(&comments).Error; err != nil {
 		return nil, err
@@ -225,7 +226,7 @@ func (s *MomentService) AddComment(userID, momentID uint64, content string, pare
 		}
 	}
 
-	return s.DB.Transaction(func(tx *gorm.DB) error {
+	return s.Tx(func(tx *gorm.DB) error {
 		c := models.MomentComment{MomentID: momentID, UserID: userID, ParentID: parentID, Content: content}
 		if err := tx.Create(&c).Error; err != nil {
 			return err
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+)
+
+// NamecardService 用户二维码名片：
+// 二维码内容为 chatsdk://namecard?uid=<UID>&token=<加好友令牌>，
+// 令牌 = base64url("<uid>.<过期时间戳>") + "." + HMAC-SHA256 签名，
+// 扫码方调用 /friend/add-by-qr 携带令牌即可发起好友申请（令牌过期后需重新生成二维码）。
+type NamecardService struct {
+	*Service
+	memberService *MemberService
+	httpClient    *http.Client
+
+	secret   []byte
+	TokenTTL time.Duration
+}
+
+// NamecardLinkPrefix 二维码内容前缀，客户端扫码后据此识别名片
+// 名片二维码错误
+var (
+	ErrQRCodeInvalid = newError(response.CodeParamError, "err.qrcode_invalid")
+	ErrQRCodeExpired = newError(response.CodeParamError, "err.qrcode_expired")
+)
+
+const NamecardLinkPrefix = "chatsdk://namecard"
+
+// NewNamecardService secret 为空时随机生成（服务重启后旧二维码失效，多实例部署请显式配置）。
+func NewNamecardService(s *Service, secret string) *NamecardService {
+	log.Println("NewNamecardService")
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Printf("NewNamecardService: generate secret failed: %v", err)
+		}
+	}
+	return &NamecardService{
+		Service:       s,
+		memberService: NewMemberService(s),
+		httpClient:    &http.Client{Timeout: 3 * time.Second},
+		secret:        key,
+		TokenTTL:      7 * 24 * time.Hour,
+	}
+}
+
+// NamecardDTO 名片信息（二维码内容 + 令牌过期时间）
+type NamecardDTO struct {
+	UID       string `json:"uid"`
+	Token     string `json:"token"`
+	Content   string `json:"content"` // 二维码编码的内容
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+func (s *NamecardService) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// IssueFriendToken 签发加好友令牌
+func (s *NamecardService) IssueFriendToken(uid string, now time.Time) (string, int64) {
+	exp := now.Add(s.TokenTTL).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(uid + "." + strconv.FormatInt(exp, 10)))
+	return payload + "." + s.sign(payload), exp
+}
+
+// ParseFriendToken 校验令牌，返回名片主人的 UID
+func (s *NamecardService) ParseFriendToken(token string, now time.Time) (string, error) {
+	payload, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || payload == "" || !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return "", ErrQRCodeInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrQRCodeInvalid
+	}
+	i := strings.LastIndexByte(string(raw), '.')
+	if i <= 0 {
+		return "", ErrQRCodeInvalid
+	}
+	exp, err := strconv.ParseInt(string(raw[i+1:]), 10, 64)
+	if err != nil {
+		return "", ErrQRCodeInvalid
+	}
+	if now.Unix() > exp {
+		return "", ErrQRCodeExpired
+	}
+	return string(raw[:i]), nil
+}
+
+func (s *NamecardService) loadUser(userID uint64) (*models.User, error) {
+	var u models.User
+	if err := s.DB.Select("id", "uid", "avatar").Where("id = ?", userID).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetNamecard 生成名片内容（不含图片）
+func (s *NamecardService) GetNamecard(userID uint64) (*NamecardDTO, error) {
+	u, err := s.loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildNamecard(u), nil
+}
+
+func (s *NamecardService) buildNamecard(u *models.User) *NamecardDTO {
+	token, exp := s.IssueFriendToken(u.UID, time.Now())
+	q := url.Values{}
+	q.Set("uid", u.UID)
+	q.Set("token", token)
+	return &NamecardDTO{
+		UID:       u.UID,
+		Token:     token,
+		Content:   NamecardLinkPrefix + "?" + q.Encode(),
+		ExpiresAt: exp,
+	}
+}
+
+// GenerateQRCode 生成名片二维码 PNG（中间贴用户头像，头像拉取失败则不贴）
+func (s *NamecardService) GenerateQRCode(userID uint64, size int) ([]byte, *NamecardDTO, error) {
+	if size <= 0 || size > 1024 {
+		size = 256
+	}
+	u, err := s.loadUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	card := s.buildNamecard(u)
+	logo, _ := fetchAvatarImage(s.httpClient, u.Avatar)
+	png, err := RenderQRCode(card.Content, size, logo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return png, card, nil
+}
+
+// AddFriendByQR 扫码加好友：校验令牌后向名片主人发起好友申请
+func (s *NamecardService) AddFriendByQR(fromUserID uint64, token, message string) (*UserBasicDTO, error) {
+	uid, err := s.ParseFriendToken(token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var target models.User
+	if err := s.DB.Select("id", "username", "nickname", "avatar").Where("uid = ?", uid).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if err := s.memberService.SendFriendRequestWithSource(fromUserID, target.ID, message, models.FriendSourceQRCode, 0); err != nil {
+		return nil, err
+	}
+	return &UserBasicDTO{ID: target.ID, Username: target.Username, Nickname: target.Nickname, Avatar: target.Avatar}, nil
+}
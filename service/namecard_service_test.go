@@ -0,0 +1,49 @@
+package service
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamecardService_FriendToken(t *testing.T) {
+	svc := NewNamecardService(&Service{}, "secret")
+	now := time.Now()
+
+	token, exp := svc.IssueFriendToken("uid-1.x", now)
+	if exp != now.Add(svc.TokenTTL).Unix() {
+		t.Fatalf("unexpected exp %d", exp)
+	}
+	uid, err := svc.ParseFriendToken(token, now)
+	if err != nil || uid != "uid-1.x" {
+		t.Fatalf("ParseFriendToken: uid=%q err=%v", uid, err)
+	}
+
+	if _, err := svc.ParseFriendToken(token, now.Add(svc.TokenTTL+time.Second)); err == nil {
+		t.Fatalf("expected expired error")
+	}
+	other := NewNamecardService(&Service{}, "other")
+	if _, err := other.ParseFriendToken(token, now); err == nil {
+		t.Fatalf("expected signature error")
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+	if _, err := svc.ParseFriendToken(payload+"x."+sig, now); err == nil {
+		t.Fatalf("expected tampered payload error")
+	}
+}
+
+func TestRenderQRCode(t *testing.T) {
+	data, err := RenderQRCode(NamecardLinkPrefix+"?uid=abc", 200, placeholderImage(64, 64))
+	if err != nil {
+		t.Fatalf("RenderQRCode: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	if w := img.Bounds().Dx(); w <= 0 || w > 200 || w != img.Bounds().Dy() {
+		t.Fatalf("unexpected size %v", img.Bounds())
+	}
+}
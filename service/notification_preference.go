@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotificationEventType 事件类型不在 MutableRoomEventTypes 内
+var ErrNotificationEventType = newError(response.CodeParamError, "err.notification_event_type")
+
+// NotificationPreferenceDTO 用户屏蔽的事件类型，RoomID = 0 表示所有房间
+type NotificationPreferenceDTO struct {
+	RoomID     uint64   `json:"room_id"`
+	EventTypes []string `json:"event_types"`
+}
+
+// SetNotificationMuted 屏蔽/恢复某房间（roomID=0 为所有房间）的若干事件类型，重复设置幂等
+func (s *NotificationService) SetNotificationMuted(userID, roomID uint64, eventTypes []string, muted bool) error {
+	if userID == 0 {
+		return errors.New("user_id is required")
+	}
+	for _, t := range eventTypes {
+		if !slices.Contains(MutableRoomEventTypes, t) {
+			return ErrNotificationEventType
+		}
+	}
+	if len(eventTypes) == 0 {
+		return nil
+	}
+
+	if !muted {
+		return s.DB.Where("user_id = ? AND room_id = ? AND event_type IN ?", userID, roomID, eventTypes).
+			Delete(&models.NotificationPreference{}).Error
+	}
+	rows := make([]models.NotificationPreference, 0, len(eventTypes))
+	for _, t := range eventTypes {
+		rows = append(rows, models.NotificationPreference{UserID: userID, RoomID: roomID, EventType: t})
+	}
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// ListNotificationPreferences 列出用户的屏蔽设置，roomID 不为 nil 时只看该房间（含 room_id=0 的全局设置）
+func (s *NotificationService) ListNotificationPreferences(userID uint64, roomID *uint64) ([]NotificationPreferenceDTO, error) {
+	if userID == 0 {
+		return nil, errors.New("user_id is required")
+	}
+	q := s.DB.Model(&models.NotificationPreference{}).Where("user_id = ?", userID)
+	if roomID != nil {
+		q = q.Where("room_id IN ?", []uint64{0, *roomID})
+	}
+	var rows []models.NotificationPreference
+	if err := q.Order("room_id asc, event_type asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]NotificationPreferenceDTO, 0)
+	for _, r := range rows {
+		if n := len(out); n > 0 && out[n-1].RoomID == r.RoomID {
+			out[n-1].EventTypes = append(out[n-1].EventTypes, r.EventType)
+			continue
+		}
+		out = append(out, NotificationPreferenceDTO{RoomID: r.RoomID, EventTypes: []string{r.EventType}})
+	}
+	return out, nil
+}
+
+// filterMutedRecipients 去掉屏蔽了该房间（或全局屏蔽）此类事件的用户
+func filterMutedRecipients(db *gorm.DB, roomID uint64, eventType string, userIDs []uint64) ([]uint64, error) {
+	if len(userIDs) == 0 || !slices.Contains(MutableRoomEventTypes, eventType) {
+		return userIDs, nil
+	}
+	var muted []uint64
+	if err := db.Model(&models.NotificationPreference{}).
+		Where("event_type = ? AND room_id IN ? AND user_id IN ?", eventType, []uint64{0, roomID}, userIDs).
+		Distinct().Pluck("user_id", &muted).Error; err != nil {
+		return nil, err
+	}
+	if len(muted) == 0 {
+		return userIDs, nil
+	}
+	out := make([]uint64, 0, len(userIDs))
+	for _, uid := range userIDs {
+		if !slices.Contains(muted, uid) {
+			out = append(out, uid)
+		}
+	}
+	return out, nil
+}
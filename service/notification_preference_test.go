@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNotificationService_PublishRoomEvent_SkipsMuted(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	var pushed []uint64
+	ns := NewNotificationService(&Service{DB: gormDB, TablePrefix: "im_", WsNotifier: func(userID uint64, _ []byte) {
+		pushed = append(pushed, userID)
+	}})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `im_room_notification`").WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectQuery("SELECT DISTINCT `user_id` FROM `im_notification_preference` WHERE event_type = \\? AND room_id IN \\(\\?,\\?\\) AND user_id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(EventRoomMemberAdded, 0, 5, 2, 3, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(3))
+	mock.ExpectExec("INSERT INTO `im_room_notification_delivery`").
+		WithArgs(2, 7, 5, false, nil, sqlmock.AnyArg(), nil, 4, 7, 5, false, nil, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("INSERT INTO `im_notification_counter` \\(`user_id`,`unread`,`updated_at`\\) VALUES \\(\\?,\\?,\\?\\),\\(\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE `unread`=unread \\+ 1").
+		WithArgs(2, 1, sqlmock.AnyArg(), 4, 1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if _, err := ns.PublishRoomEvent(5, 1, EventRoomMemberAdded, map[string]any{"user_ids": []uint64{4}}, []uint64{1, 2, 3, 4}, false); err != nil {
+		t.Fatalf("PublishRoomEvent: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+	if len(pushed) != 2 || pushed[0] != 2 || pushed[1] != 4 {
+		t.Fatalf("pushed=%v", pushed)
+	}
+}
+
+func TestNotificationService_PublishRoomEvent_RecallNotMutable(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	ns := NewNotificationService(&Service{DB: gormDB, TablePrefix: "im_"})
+
+	// 撤回不可屏蔽：不查偏好表
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `im_room_notification`").WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectExec("INSERT INTO `im_room_notification_delivery`").WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("INSERT INTO `im_notification_counter`").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if _, err := ns.PublishRoomEvent(5, 1, EventRecall, nil, []uint64{1, 2}, true); err != nil {
+		t.Fatalf("PublishRoomEvent: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestNotificationService_SetNotificationMuted(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	ns := NewNotificationService(&Service{DB: gormDB, TablePrefix: "im_"})
+
+	if err := ns.SetNotificationMuted(1, 5, []string{EventRecall}, true); !errors.Is(err, ErrNotificationEventType) {
+		t.Fatalf("want ErrNotificationEventType, got %v", err)
+	}
+
+	mock.ExpectExec("INSERT INTO `im_notification_preference` \\(`user_id`,`room_id`,`event_type`,`created_at`\\) VALUES \\(\\?,\\?,\\?,\\?\\),\\(\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE `id`=`id`").
+		WithArgs(1, 5, EventRoomMemberAdded, sqlmock.AnyArg(), 1, 5, EventRoomMemberQuit, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	if err := ns.SetNotificationMuted(1, 5, []string{EventRoomMemberAdded, EventRoomMemberQuit}, true); err != nil {
+		t.Fatalf("mute: %v", err)
+	}
+
+	mock.ExpectExec("DELETE FROM `im_notification_preference` WHERE user_id = \\? AND room_id = \\? AND event_type IN \\(\\?\\)").
+		WithArgs(1, 0, EventRoomMemberAdded).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := ns.SetNotificationMuted(1, 0, []string{EventRoomMemberAdded}, false); err != nil {
+		t.Fatalf("unmute: %v", err)
+	}
+
+	mock.ExpectQuery("SELECT \\* FROM `im_notification_preference` WHERE user_id = \\? AND room_id IN \\(\\?,\\?\\) ORDER BY room_id asc, event_type asc").
+		WithArgs(1, 0, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "room_id", "event_type"}).
+			AddRow(1, 0, EventRoomMemberNickname).
+			AddRow(1, 5, EventRoomMemberAdded).
+			AddRow(1, 5, EventRoomMemberQuit))
+	room := uint64(5)
+	items, err := ns.ListNotificationPreferences(1, &room)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(items) != 2 || items[0].RoomID != 0 || len(items[1].EventTypes) != 2 {
+		t.Fatalf("items=%#v", items)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestNotificationService_PublishRoomEvent_PushOnly(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	var pushed [][]uint64
+	ns := NewNotificationService(&Service{DB: gormDB, TablePrefix: "im_", WsBatchNotifier: func(userIDs []uint64, _ []byte) {
+		pushed = append(pushed, userIDs)
+	}})
+	ns.PushOnly = true
+
+	// 只查偏好，不写事件/投递/未读数
+	mock.ExpectQuery("SELECT DISTINCT `user_id` FROM `im_notification_preference`").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	evt, err := ns.PublishRoomEvent(5, 1, EventRoomMemberAdded, nil, []uint64{1, 2, 3}, false)
+	if err != nil {
+		t.Fatalf("PublishRoomEvent: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+	if evt.ID != 0 || len(pushed) != 1 || len(pushed[0]) != 2 {
+		t.Fatalf("evt=%#v pushed=%v", evt, pushed)
+	}
+}
@@ -3,10 +3,13 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"sync"
 	"time"
 
+	"github.com/cydxin/chat-sdk/message"
 	"github.com/cydxin/chat-sdk/models"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -14,6 +17,13 @@ import (
 // 约定：先落库(事件+投递)，再尽力通过 WS 推送；离线/新设备通过 HTTP 拉取。
 type NotificationService struct {
 	*Service
+
+	// PushOnly 只做 WS 推送、不落库（见 chat_sdk.WithNotificationService），HTTP 拉取与未读数均为空
+	PushOnly bool
+
+	// AnnouncementRate 系统公告分批投递的速率（见 PublishAnnouncement），零值使用 DefaultAnnouncementRate
+	AnnouncementRate AnnouncementRate
+	announceMu       sync.Mutex
 }
 
 func NewNotificationService(s *Service) *NotificationService {
@@ -45,21 +55,71 @@ func (s *NotificationService) PublishRoomEvent(roomID, actorID uint64, eventType
 
 	now := time.Now()
 
+	// 只推送不落库：没有事件 ID，客户端无法标记已读
+	if s.PushOnly {
+		clean, err := notificationRecipients(s.DB, roomID, actorID, eventType, payload, members, includeActor)
+		if err != nil {
+			return nil, err
+		}
+		evt := &models.RoomNotification{RoomID: roomID, ActorID: actorID, EventType: eventType, Payload: pl, CreatedAt: now}
+		s.pushRoomEventToUsers(evt, clean)
+		return evt, nil
+	}
+
 	// 事件 + 投递建议同事务，确保离线拉取一定能看到。
-	tx := s.DB.Begin()
-	defer tx.Rollback()
+	var evt *models.RoomNotification
+	var clean []uint64
+	err := s.Tx(func(tx *gorm.DB) error {
+		evt = &models.RoomNotification{
+			RoomID:    roomID,
+			ActorID:   actorID,
+			EventType: eventType,
+			Payload:   pl,
+			CreatedAt: now,
+		}
+		if err := tx.Create(evt).Error; err != nil {
+			return err
+		}
 
-	evt := &models.RoomNotification{
-		RoomID:    roomID,
-		ActorID:   actorID,
-		EventType: eventType,
-		Payload:   pl,
-		CreatedAt: now,
-	}
-	if err := tx.Create(evt).Error; err != nil {
+		var err error
+		clean, err = notificationRecipients(tx, roomID, actorID, eventType, payload, members, includeActor)
+		if err != nil {
+			return err
+		}
+
+		rows := make([]models.RoomNotificationDelivery, 0, len(clean))
+		for _, uid := range clean {
+			rows = append(rows, models.RoomNotificationDelivery{
+				UserID:    uid,
+				EventID:   evt.ID,
+				RoomID:    roomID,
+				IsRead:    false,
+				CreatedAt: now,
+			})
+		}
+		if len(rows) > 0 {
+			// OnConflict DoNothing: 避免并发/重试重复投递
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+				return err
+			}
+			if err := incrUnread(tx, clean); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	// WS 推送（尽力而为：失败不影响主流程）
+	s.pushRoomEventToUsers(evt, clean)
+
+	return evt, nil
+}
+
+// notificationRecipients 计算事件接收者：去重、按 includeActor 处理操作者、按用户偏好过滤
+func notificationRecipients(db *gorm.DB, roomID, actorID uint64, eventType string, payload any, members []uint64, includeActor bool) ([]uint64, error) {
 	// 处理 members
 	// - 去重
 	// - 可选排除 actor
@@ -83,6 +143,11 @@ func (s *NotificationService) PublishRoomEvent(roomID, actorID uint64, eventType
 			clean = append(clean, actorID)
 		}
 	}
+	// 按用户偏好过滤（被移出的人在下面单独补上，不受影响）
+	clean, err := filterMutedRecipients(db, roomID, eventType, clean)
+	if err != nil {
+		return nil, err
+	}
 	switch eventType {
 	case EventRoomMemberRemoved:
 		// 把移除的人也放进通知里
@@ -96,64 +161,21 @@ func (s *NotificationService) PublishRoomEvent(roomID, actorID uint64, eventType
 
 	default:
 	}
-
-	rows := make([]models.RoomNotificationDelivery, 0, len(clean))
-	for _, uid := range clean {
-		rows = append(rows, models.RoomNotificationDelivery{
-			UserID:    uid,
-			EventID:   evt.ID,
-			RoomID:    roomID,
-			IsRead:    false,
-			CreatedAt: now,
-		})
-	}
-	if len(rows) > 0 {
-		// OnConflict DoNothing: 避免并发/重试重复投递
-		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
-			return nil, err
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
-	}
-
-	// WS 推送（尽力而为：失败不影响主流程）
-	s.pushRoomEventToUsers(evt, clean)
-
-	return evt, nil
+	return clean, nil
 }
 
 func (s *NotificationService) pushRoomEventToUsers(evt *models.RoomNotification, userIDs []uint64) {
-	if s.WsNotifier == nil || evt == nil {
+	if evt == nil {
 		return
 	}
-
-	msg := struct {
-		Type      string         `json:"type"`
-		EventID   uint64         `json:"event_id"`
-		RoomID    uint64         `json:"room_id"`
-		ActorID   uint64         `json:"actor_id"`
-		EventType string         `json:"event_type"`
-		Payload   datatypes.JSON `json:"payload,omitempty"`
-		CreatedAt time.Time      `json:"created_at"`
-	}{
-		Type:      EventNotification,
+	s.Emit(userIDs, &message.NotificationEvent{
 		EventID:   evt.ID,
 		RoomID:    evt.RoomID,
 		ActorID:   evt.ActorID,
 		EventType: evt.EventType,
-		Payload:   evt.Payload,
+		Payload:   json.RawMessage(evt.Payload),
 		CreatedAt: evt.CreatedAt,
-	}
-
-	b, err := json.Marshal(msg)
-	if err != nil {
-		return
-	}
-	for _, uid := range userIDs {
-		s.WsNotifier(uid, b)
-	}
+	})
 }
 
 // NotificationDTO HTTP 返回结构
@@ -226,7 +248,7 @@ func (s *NotificationService) ListUserNotifications(userID uint64, sinceDays int
 	return out, nextCursor, nil
 }
 
-// MarkReadByIDs 批量标记已读
+// MarkReadByIDs 批量标记已读（只处理未读的投递，并同步扣减未读数）
 func (s *NotificationService) MarkReadByIDs(userID uint64, ids []uint64) error {
 	if userID == 0 {
 		return errors.New("user_id is required")
@@ -235,7 +257,13 @@ func (s *NotificationService) MarkReadByIDs(userID uint64, ids []uint64) error {
 		return nil
 	}
 	now := time.Now()
-	return s.DB.Model(&models.RoomNotificationDelivery{}).
-		Where("user_id = ? AND id IN ?", userID, ids).
-		Updates(map[string]any{"is_read": true, "read_at": &now}).Error
+	return s.Tx(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RoomNotificationDelivery{}).
+			Where("user_id = ? AND id IN ? AND is_read = ?", userID, ids, false).
+			Updates(map[string]any{"is_read": true, "read_at": &now})
+		if res.Error != nil {
+			return res.Error
+		}
+		return decrUnread(tx, userID, res.RowsAffected)
+	})
 }
@@ -1,25 +1,89 @@
 package service
 
+import "github.com/cydxin/chat-sdk/message"
+
 // 统一的房间/群通知事件类型（event_type）
 const (
 	EventRoomGroupInfoUpdated   = "room.group.info_updated"   // 群信息更新
 	EventRoomAdminSet           = "room.admin.set"            // 群管理员设置
 	EventRoomGroupMuteCountdown = "room.group.mute.countdown" // 群检测到禁言倒计时结束
 	EventRoomGroupMuteScheduled = "room.group.mute.scheduled" // 群定时禁言
+	EventRoomGroupMuteWindow    = "room.group.mute.window"    // 每日定时禁言开始/结束
 	EventRoomUserMute           = "room.user.mute"            // 群用户禁言
+	EventRoomUserUnmute         = "room.user.unmute"          // 群用户禁言到期自动解除
+	EventRoomMuteAdmins         = "room.mute.admins"          // 全员禁言是否对管理员生效
+	EventRoomMuteExempt         = "room.mute.exempt"          // 全员禁言豁免成员变更
 	EventRoomMemberAdded        = "room.member.added"         // 群用户添加
 	EventRoomMemberRemoved      = "room.member.removed"       // 群用户移除(踢出去)
 	EventRoomMemberQuit         = "room.member.quit"          // 群用户退群
+	EventRoomMemberNickname     = "room.member.nickname"      // 群用户修改群昵称
+	EventRoomDisappearing       = "room.disappearing"         // 消息定时删除设置变更
+	EventRoomJoinRequest        = "room.join.request"         // 有人申请按群号加群（通知管理员）
+	EventRoomJoinFailed         = "room.join.failed"          // 加群验证问题回答错误（通知管理员）
+	EventRoomJoinHandled        = "room.join.handled"         // 加群申请被处理（通知申请人）
+	EventMessageReminder        = "message.reminder"          // 消息提醒到期（只发给设置提醒的本人）
+	EventSystemAnnouncement     = "system.announcement"       // 管理员发布的系统公告（room_id=0，payload 见 AnnouncementPayload）
+)
+
+// MutableRoomEventTypes 用户可以按房间屏蔽的事件类型（撤回、客服等影响客户端状态的事件不可屏蔽）
+var MutableRoomEventTypes = []string{
+	EventRoomGroupInfoUpdated,
+	EventRoomAdminSet,
+	EventRoomGroupMuteCountdown,
+	EventRoomGroupMuteScheduled,
+	EventRoomGroupMuteWindow,
+	EventRoomUserMute,
+	EventRoomUserUnmute,
+	EventRoomMuteAdmins,
+	EventRoomMuteExempt,
+	EventRoomMemberAdded,
+	EventRoomMemberRemoved,
+	EventRoomMemberQuit,
+	EventRoomMemberNickname,
+	EventRoomJoinFailed,
+	EventRedPacketClaimed,
+}
+
+// 统一的 用户通知（WS 顶层 type，结构见 message 包中对应的 *Event）
+const (
+	EventForward          = message.WsEventForward          // 逐条转发的新消息
+	EventMergeForward     = message.WsEventMergeForward     // 合并转发的新消息
+	EventNotification     = message.WsEventNotification     // 房间事件
+	EventFriendDeleted    = message.WsEventFriendDeleted    // 好友关系解除
+	EventRecall           = message.WsEventRecall           // 消息撤回
+	EventFriendRejected   = message.WsEventFriendRejected   // 好友申请被拒绝
+	EventFriendExpired    = message.WsEventFriendExpired    // 好友申请已过期
+	EventFriendRequest    = message.WsEventFriendRequest    // 收到好友申请
+	EventFriendAccepted   = message.WsEventFriendAccepted   // 好友申请被同意
+	EventMessageUpdated   = message.WsEventMessageUpdated   // 消息内容/扩展更新（如链接预览）
+	EventPollUpdated      = message.WsEventPollUpdated      // 投票结果更新
+	EventAccountStatus    = message.WsEventAccountStatus    // 账号被暂停/封禁/恢复
+	EventMessageStatus    = message.WsEventMessageStatus    // 消息状态推进（已送达/已读），只推给发送者
+	EventConversationRead = message.WsEventConversationRead // 会话已读游标前进，推给同一用户的其他设备
+	EventTyping           = message.WsEventTyping           // 房间成员正在输入，推给其他成员
+	EventViewOnceViewed   = message.WsEventViewOnceViewed   // 阅后即焚消息已被查看、内容已清除
+	EventPrefsUpdated     = message.WsEventPrefsUpdated     // 用户自定义设置变更，推给同一用户的所有设备
+	EventConvSettings     = message.WsEventConvSettings     // 会话背景/别名变更，推给同一用户的所有设备
+	EventRoomOnline       = message.WsEventRoomOnline       // 房间在线人数变化
+)
+
+// 运维事件（推送给 AdminUserIDs）
+const (
+	EventAdminAppeal        = "admin.appeal"         // 用户提交封禁申诉
+	EventAdminSpamViolation = "admin.spam_violation" // 用户触发反垃圾限制
+	EventAdminAnnouncement  = "admin.announcement"   // 系统公告投递完成（AnnouncementResult）
+)
+
+// 客服会话事件（event_type）
+const (
+	EventHelpDeskAssigned    = "helpdesk.assigned"    // 会话分配给客服
+	EventHelpDeskTransferred = "helpdesk.transferred" // 会话转接
+	EventHelpDeskClosed      = "helpdesk.closed"      // 会话结束
+	EventHelpDeskRated       = "helpdesk.rated"       // 访客评价
 )
 
-// 统一的 用户通知
+// 红包事件（event_type）
 const (
-	EventForward        = "forward"         // 群信息更新
-	EventMergeForward   = "merge_forward"   // 群管理员设置
-	EventNotification   = "notification"    // 群检测到禁言倒计时结束
-	EventFriendDeleted  = "friend_deleted"  // 群定时禁言
-	EventRecall         = "recall"          // 群用户禁言
-	EventFriendRejected = "friend_rejected" // 群用户添加
-	EventFriendRequest  = "friend_request"  // 群用户移除(踢出去)
-	EventFriendAccepted = "friend_accepted" // 群用户移除(踢出去)
+	EventRedPacketClaimed  = "redpacket.claimed"  // 有人领取红包
+	EventRedPacketRefunded = "redpacket.refunded" // 红包过期退回（仅通知发送者）
 )
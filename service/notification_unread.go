@@ -0,0 +1,169 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notificationPurgeBatch 每批物理删除的投递/事件数
+const notificationPurgeBatch = 500
+
+// incrUnread 给 userIDs 的未读数各加 1（在投递事务内调用，重复的 user 只计一次）
+func incrUnread(db *gorm.DB, userIDs []uint64) error {
+	seen := make(map[uint64]struct{}, len(userIDs))
+	rows := make([]models.NotificationCounter, 0, len(userIDs))
+	for _, uid := range userIDs {
+		if _, ok := seen[uid]; ok {
+			continue
+		}
+		seen[uid] = struct{}{}
+		rows = append(rows, models.NotificationCounter{UserID: uid, Unread: 1})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"unread":     gorm.Expr("unread + 1"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&rows).Error
+}
+
+// decrUnread 未读数减 n（不低于 0）
+func decrUnread(db *gorm.DB, userID uint64, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	return db.Model(&models.NotificationCounter{}).Where("user_id = ?", userID).
+		Update("unread", gorm.Expr("CASE WHEN unread > ? THEN unread - ? ELSE 0 END", n, n)).Error
+}
+
+// UnreadCount 用户未读通知数
+func (s *NotificationService) UnreadCount(userID uint64) (int64, error) {
+	if userID == 0 {
+		return 0, errors.New("user_id is required")
+	}
+	var c models.NotificationCounter
+	if err := s.DB.Where("user_id = ?", userID).Limit(1).Find(&c).Error; err != nil {
+		return 0, err
+	}
+	return c.Unread, nil
+}
+
+// MarkAllRead 全部标记已读，roomID 不为 nil 时只处理该房间，返回标记条数。
+// 不限房间时直接把未读数归零（顺便纠正可能的计数偏差）。
+func (s *NotificationService) MarkAllRead(userID uint64, roomID *uint64) (int64, error) {
+	if userID == 0 {
+		return 0, errors.New("user_id is required")
+	}
+	now := time.Now()
+	var updated int64
+	err := s.Tx(func(tx *gorm.DB) error {
+		q := tx.Model(&models.RoomNotificationDelivery{}).Where("user_id = ? AND is_read = ?", userID, false)
+		if roomID != nil && *roomID > 0 {
+			q = q.Where("room_id = ?", *roomID)
+		}
+		res := q.Updates(map[string]any{"is_read": true, "read_at": &now})
+		if res.Error != nil {
+			return res.Error
+		}
+		updated = res.RowsAffected
+		if roomID != nil && *roomID > 0 {
+			return decrUnread(tx, userID, updated)
+		}
+		return tx.Model(&models.NotificationCounter{}).Where("user_id = ?", userID).Update("unread", 0).Error
+	})
+	return updated, err
+}
+
+// PurgeNotificationsBefore 物理删除 before 之前的投递与事件（含已软删的），未读的投递同步扣减未读数，返回删除的投递条数。
+// 分批执行，单批失败直接返回，已删除的部分不回滚。
+func (s *NotificationService) PurgeNotificationsBefore(before time.Time) (int64, error) {
+	var total int64
+	for {
+		var rows []models.RoomNotificationDelivery
+		if err := s.DB.Unscoped().Select("id", "user_id", "is_read").
+			Where("created_at < ?", before).
+			Order("id ASC").
+			Limit(notificationPurgeBatch).
+			Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		ids := make([]uint64, 0, len(rows))
+		unread := make(map[uint64]int64)
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+			if !r.IsRead {
+				unread[r.UserID]++
+			}
+		}
+		var deleted int64
+		err := s.Tx(func(tx *gorm.DB) error {
+			res := tx.Unscoped().Where("id IN ?", ids).Delete(&models.RoomNotificationDelivery{})
+			if res.Error != nil {
+				return res.Error
+			}
+			deleted = res.RowsAffected
+			for uid, n := range unread {
+				if err := decrUnread(tx, uid, n); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+		if len(rows) < notificationPurgeBatch {
+			break
+		}
+	}
+
+	// 事件与投递同时创建，投递清完后同期事件已无人引用
+	for {
+		var ids []uint64
+		if err := s.DB.Unscoped().Model(&models.RoomNotification{}).
+			Where("created_at < ?", before).
+			Order("id ASC").
+			Limit(notificationPurgeBatch).
+			Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		if err := s.DB.Unscoped().Where("id IN ?", ids).Delete(&models.RoomNotification{}).Error; err != nil {
+			return total, err
+		}
+		if len(ids) < notificationPurgeBatch {
+			return total, nil
+		}
+	}
+}
+
+// RunRetentionLoop 定时清理超过 retention 的通知（阻塞，engine 中以 goroutine 启动）
+func (s *NotificationService) RunRetentionLoop(retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := s.PurgeNotificationsBefore(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("notification retention loop: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("notification retention: purged %d deliveries", n)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNotificationService_MarkReadDecrementsUnread(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	ns := NewNotificationService(&Service{DB: gormDB, TablePrefix: "im_"})
+
+	// 只有未读的投递会被更新，按实际更新条数扣减
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_room_notification_delivery` SET `is_read`=\\?,`read_at`=\\? WHERE \\(user_id = \\? AND id IN \\(\\?,\\?,\\?\\) AND is_read = \\?\\)").
+		WithArgs(true, sqlmock.AnyArg(), 1, 10, 11, 12, false).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE `im_notification_counter` SET `unread`=CASE WHEN unread > \\? THEN unread - \\? ELSE 0 END,`updated_at`=\\? WHERE user_id = \\?").
+		WithArgs(2, 2, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := ns.MarkReadByIDs(1, []uint64{10, 11, 12}); err != nil {
+		t.Fatalf("MarkReadByIDs: %v", err)
+	}
+
+	// 全部已读：计数直接归零
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_room_notification_delivery` SET `is_read`=\\?,`read_at`=\\? WHERE \\(user_id = \\? AND is_read = \\?\\)").
+		WithArgs(true, sqlmock.AnyArg(), 1, false).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec("UPDATE `im_notification_counter` SET `unread`=\\?,`updated_at`=\\? WHERE user_id = \\?").
+		WithArgs(0, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if n, err := ns.MarkAllRead(1, nil); err != nil || n != 5 {
+		t.Fatalf("MarkAllRead: %d %v", n, err)
+	}
+
+	// 按房间全部已读：扣减
+	room := uint64(9)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_room_notification_delivery` SET `is_read`=\\?,`read_at`=\\? WHERE \\(user_id = \\? AND is_read = \\?\\) AND room_id = \\?").
+		WithArgs(true, sqlmock.AnyArg(), 1, false, 9).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE `im_notification_counter` SET `unread`=CASE").
+		WithArgs(3, 3, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if n, err := ns.MarkAllRead(1, &room); err != nil || n != 3 {
+		t.Fatalf("MarkAllRead room: %d %v", n, err)
+	}
+
+	mock.ExpectQuery("SELECT \\* FROM `im_notification_counter` WHERE user_id = \\? LIMIT \\?").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "unread"}).AddRow(1, 4))
+	if n, err := ns.UnreadCount(1); err != nil || n != 4 {
+		t.Fatalf("UnreadCount: %d %v", n, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestNotificationService_PurgeNotificationsBefore(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	ns := NewNotificationService(&Service{DB: gormDB, TablePrefix: "im_"})
+	before := time.Now().Add(-30 * 24 * time.Hour)
+
+	mock.ExpectQuery("SELECT `id`,`user_id`,`is_read` FROM `im_room_notification_delivery` WHERE created_at < \\? ORDER BY id ASC LIMIT \\?").
+		WithArgs(before, notificationPurgeBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "is_read"}).
+			AddRow(1, 7, false).
+			AddRow(2, 7, false).
+			AddRow(3, 8, true))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `im_room_notification_delivery` WHERE id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(1, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	// 只有 user 7 有未读被清理
+	mock.ExpectExec("UPDATE `im_notification_counter` SET `unread`=CASE").
+		WithArgs(2, 2, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT `id` FROM `im_room_notification` WHERE created_at < \\? ORDER BY id ASC LIMIT \\?").
+		WithArgs(before, notificationPurgeBatch).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(100).AddRow(101))
+	mock.ExpectExec("DELETE FROM `im_room_notification` WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(100, 101).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := ns.PurgeNotificationsBefore(before)
+	if err != nil {
+		t.Fatalf("PurgeNotificationsBefore: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("purged=%d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,546 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDepartmentNotFound 部门不存在
+	ErrDepartmentNotFound = newError(response.CodeParamError, "err.department_not_found")
+	// ErrDepartmentNotEmpty 部门下还有子部门或成员，不能删除
+	ErrDepartmentNotEmpty = newError(response.CodeParamError, "err.department_not_empty")
+	// ErrNotColleague 对方不在组织架构中（或自己不在）
+	ErrNotColleague = newError(response.CodePermissionDeny, "err.not_colleague")
+	// ErrDepartmentNameRequired 部门名称为空
+	ErrDepartmentNameRequired = newError(response.CodeParamError, "err.department_name_required")
+	// ErrDepartmentCycle 不能移动到自己的下级部门
+	ErrDepartmentCycle = newError(response.CodeParamError, "err.department_cycle")
+	// ErrChatSelf 不能和自己发起私聊
+	ErrChatSelf = newError(response.CodeParamError, "err.chat_self")
+)
+
+// OrgService 组织架构（可选，WithOrgDirectory 开启）：
+// - 部门树与部门成员由管理端维护（或从 HR 系统同步），一个用户可属于多个部门；
+// - 组织内成员可浏览架构、查看同事，并直接发起私聊（无需互为好友）；
+// - 部门可开启“部门群”：群成员与部门直属成员自动保持一致（加入部门即入群，离开部门即出群），部门负责人为群管理员。
+type OrgService struct {
+	*Service
+}
+
+func NewOrgService(s *Service) *OrgService {
+	log.Println("NewOrgService")
+	return &OrgService{Service: s}
+}
+
+// DepartmentDTO 部门
+type DepartmentDTO struct {
+	ID          uint64 `json:"id"`
+	ParentID    uint64 `json:"parent_id"`
+	Name        string `json:"name"`
+	Code        string `json:"code,omitempty"`
+	Sort        int    `json:"sort"`
+	RoomID      uint64 `json:"room_id"`      // 部门群，0 表示未开启
+	MemberCount int64  `json:"member_count"` // 直属成员数
+}
+
+// DepartmentNode 部门树节点
+type DepartmentNode struct {
+	DepartmentDTO
+	Children []*DepartmentNode `json:"children"`
+}
+
+// OrgMemberDTO 部门成员
+type OrgMemberDTO struct {
+	UserID         uint64 `json:"user_id"`
+	Username       string `json:"username"`
+	Nickname       string `json:"nickname"`
+	Avatar         string `json:"avatar"`
+	DepartmentID   uint64 `json:"department_id"`
+	DepartmentName string `json:"department_name,omitempty"`
+	Title          string `json:"title"`
+	IsLeader       bool   `json:"is_leader"`
+}
+
+// OrgChatDTO 与同事的私聊房间
+type OrgChatDTO struct {
+	RoomID      uint64 `json:"room_id"`
+	RoomAccount string `json:"room_account"`
+}
+
+func toDepartmentDTO(d *models.Department, count int64) DepartmentDTO {
+	return DepartmentDTO{ID: d.ID, ParentID: d.ParentID, Name: d.Name, Code: d.Code, Sort: d.Sort, RoomID: d.RoomID, MemberCount: count}
+}
+
+type CreateDepartmentReq struct {
+	ParentID  uint64 `json:"parent_id"` // 0 为顶级部门
+	Name      string `json:"name" binding:"required"`
+	Code      string `json:"code"` // 外部系统部门编码（可选，唯一）
+	Sort      int    `json:"sort"`
+	AutoGroup bool   `json:"auto_group"` // 同时开启部门群
+}
+
+// CreateDepartment 创建部门
+func (s *OrgService) CreateDepartment(req CreateDepartmentReq) (*DepartmentDTO, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrDepartmentNameRequired
+	}
+	if req.ParentID != 0 {
+		if _, err := s.department(req.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	dept := &models.Department{ParentID: req.ParentID, Name: name, Code: strings.TrimSpace(req.Code), Sort: req.Sort, CreatedAt: now, UpdatedAt: now}
+	if err := s.DB.Create(dept).Error; err != nil {
+		return nil, err
+	}
+	if req.AutoGroup {
+		return s.SetDepartmentGroup(dept.ID, true)
+	}
+	dto := toDepartmentDTO(dept, 0)
+	return &dto, nil
+}
+
+type UpdateDepartmentReq struct {
+	ID       uint64  `json:"id" binding:"required"`
+	ParentID *uint64 `json:"parent_id"`
+	Name     *string `json:"name"`
+	Sort     *int    `json:"sort"`
+}
+
+// UpdateDepartment 修改部门名称、排序或移动到其他部门下（不能移到自己的下级），改名会同步到部门群
+func (s *OrgService) UpdateDepartment(req UpdateDepartmentReq) error {
+	dept, err := s.department(req.ID)
+	if err != nil {
+		return err
+	}
+	updates := map[string]any{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return ErrDepartmentNameRequired
+		}
+		updates["name"] = name
+	}
+	if req.Sort != nil {
+		updates["sort"] = *req.Sort
+	}
+	if req.ParentID != nil && *req.ParentID != dept.ParentID {
+		// 沿新上级向上查找，遇到自己说明形成环
+		for pid := *req.ParentID; pid != 0; {
+			if pid == dept.ID {
+				return ErrDepartmentCycle
+			}
+			parent, err := s.department(pid)
+			if err != nil {
+				return err
+			}
+			pid = parent.ParentID
+		}
+		updates["parent_id"] = *req.ParentID
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	updates["updated_at"] = time.Now()
+	return s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Department{}).Where("id = ?", dept.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if name, ok := updates["name"]; ok && dept.RoomID != 0 {
+			return tx.Model(&models.Room{}).Where("id = ?", dept.RoomID).
+				Updates(map[string]any{"name": name, "updated_at": updates["updated_at"]}).Error
+		}
+		return nil
+	})
+}
+
+// DeleteDepartment 删除空部门（没有子部门和成员）；部门群保留为普通群
+func (s *OrgService) DeleteDepartment(id uint64) error {
+	if _, err := s.department(id); err != nil {
+		return err
+	}
+	var children, members int64
+	if err := s.DB.Model(&models.Department{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Model(&models.DepartmentMember{}).Where("department_id = ?", id).Count(&members).Error; err != nil {
+		return err
+	}
+	if children > 0 || members > 0 {
+		return ErrDepartmentNotEmpty
+	}
+	return s.DB.Delete(&models.Department{}, id).Error
+}
+
+// SetDepartmentGroup 开启/关闭部门群。开启时创建（或复用之前的）群号为 dept_{id} 的群并同步成员；
+// 关闭只解除关联，群保留为普通群，成员不再自动同步。
+func (s *OrgService) SetDepartmentGroup(id uint64, enabled bool) (*DepartmentDTO, error) {
+	dept, err := s.department(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case enabled && dept.RoomID == 0:
+		account := fmt.Sprintf("dept_%d", dept.ID)
+		var room models.Room
+		err := s.DB.Where("room_account = ?", account).First(&room).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 部门群由系统维护：没有群主，部门负责人为管理员
+			room = models.Room{RoomAccount: account, Type: 2, Name: dept.Name, MemberLimit: DefaultRoomMemberLimit, CreatedAt: now, UpdatedAt: now}
+			err = s.DB.Create(&room).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := s.DB.Model(&models.Department{}).Where("id = ?", dept.ID).
+			Updates(map[string]any{"room_id": room.ID, "updated_at": now}).Error; err != nil {
+			return nil, err
+		}
+		dept.RoomID = room.ID
+		if err := s.SyncDepartmentGroup(dept.ID); err != nil {
+			return nil, err
+		}
+	case !enabled && dept.RoomID != 0:
+		if err := s.DB.Model(&models.Department{}).Where("id = ?", dept.ID).
+			Updates(map[string]any{"room_id": 0, "updated_at": now}).Error; err != nil {
+			return nil, err
+		}
+		dept.RoomID = 0
+	}
+	var count int64
+	if err := s.DB.Model(&models.DepartmentMember{}).Where("department_id = ?", dept.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	dto := toDepartmentDTO(dept, count)
+	return &dto, nil
+}
+
+type OrgMembersReq struct {
+	DepartmentID uint64   `json:"department_id" binding:"required"`
+	UserIDs      []uint64 `json:"user_ids" binding:"required"`
+	Title        string   `json:"title"`     // 职位（添加时使用）
+	IsLeader     bool     `json:"is_leader"` // 是否部门负责人（添加时使用）
+}
+
+// AddMembers 把用户加入部门（已在部门中的更新职位与负责人标记），并同步部门群
+func (s *OrgService) AddMembers(req OrgMembersReq) error {
+	if _, err := s.department(req.DepartmentID); err != nil {
+		return err
+	}
+	userIDs := uniqueUint64s(req.UserIDs)
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var found int64
+	if err := s.DB.Model(&models.User{}).Where("id IN ?", userIDs).Count(&found).Error; err != nil {
+		return err
+	}
+	if int(found) != len(userIDs) {
+		return ErrUserNotFound
+	}
+	now := time.Now()
+	rows := make([]models.DepartmentMember, 0, len(userIDs))
+	for _, uid := range userIDs {
+		rows = append(rows, models.DepartmentMember{
+			DepartmentID: req.DepartmentID, UserID: uid, Title: strings.TrimSpace(req.Title), IsLeader: req.IsLeader,
+			CreatedAt: now, UpdatedAt: now,
+		})
+	}
+	if err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "department_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "is_leader", "updated_at"}),
+	}).Create(&rows).Error; err != nil {
+		return err
+	}
+	return s.SyncDepartmentGroup(req.DepartmentID)
+}
+
+// RemoveMembers 把用户移出部门，并同步部门群
+func (s *OrgService) RemoveMembers(req OrgMembersReq) error {
+	if _, err := s.department(req.DepartmentID); err != nil {
+		return err
+	}
+	if len(req.UserIDs) == 0 {
+		return nil
+	}
+	if err := s.DB.Where("department_id = ? AND user_id IN ?", req.DepartmentID, req.UserIDs).
+		Delete(&models.DepartmentMember{}).Error; err != nil {
+		return err
+	}
+	return s.SyncDepartmentGroup(req.DepartmentID)
+}
+
+// SyncDepartmentGroup 让部门群成员与部门直属成员一致：补充缺少的成员、移出已离开部门的成员，并按负责人标记设置管理员。
+// 成员变动后会自动调用，也可在直接修改表数据后手动调用修复。
+func (s *OrgService) SyncDepartmentGroup(id uint64) error {
+	dept, err := s.department(id)
+	if err != nil || dept.RoomID == 0 {
+		return err
+	}
+	roomID := dept.RoomID
+	var want []models.DepartmentMember
+	if err := s.DB.Select("user_id", "is_leader").Where("department_id = ?", id).Find(&want).Error; err != nil {
+		return err
+	}
+	var have []models.RoomUser
+	if err := s.DB.Select("user_id", "role").Where("room_id = ?", roomID).Find(&have).Error; err != nil {
+		return err
+	}
+	roles := make(map[uint64]uint8, len(have))
+	for _, ru := range have {
+		roles[ru.UserID] = ru.Role
+	}
+	leader := make(map[uint64]bool, len(want))
+	for _, m := range want {
+		leader[m.UserID] = m.IsLeader
+	}
+
+	var added, removed []uint64
+	now := time.Now()
+	err = s.Tx(func(tx *gorm.DB) error {
+		added, removed = nil, nil
+		if err := tx.Model(&models.Room{}).Where("id = ? AND member_limit < ?", roomID, len(want)).
+			Update("member_limit", len(want)).Error; err != nil {
+			return err
+		}
+		for _, m := range want {
+			role := uint8(models.RoomRoleMember)
+			if m.IsLeader {
+				role = models.RoomRoleAdmin
+			}
+			cur, ok := roles[m.UserID]
+			if ok {
+				if cur != role && cur != models.RoomRoleOwner {
+					if err := tx.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", roomID, m.UserID).
+						Updates(map[string]any{"role": role, "updated_at": now}).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := tx.Create(&models.RoomUser{
+				RoomID: roomID, UserID: m.UserID, Role: role, JoinSource: "org",
+				JoinTime: now, CreatedAt: now, UpdatedAt: now,
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "room_id"}},
+				DoUpdates: clause.Assignments(map[string]any{"is_visible": true, "updated_at": now}),
+			}).Create(&models.Conversation{UserID: m.UserID, RoomID: roomID, IsVisible: true, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+				return err
+			}
+			added = append(added, m.UserID)
+		}
+		for uid := range roles {
+			if _, ok := leader[uid]; !ok {
+				removed = append(removed, uid)
+			}
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+		if err := tx.Where("room_id = ? AND user_id IN ?", roomID, removed).Delete(&models.RoomUser{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Conversation{}).Where("room_id = ? AND user_id IN ?", roomID, removed).
+			Updates(map[string]any{"is_visible": false, "updated_at": now}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(added) > 0 {
+		s.roomMembersChanged(roomID, added, true)
+		s.SystemMsg.Post(roomID, message.SystemInfo{Event: EventRoomMemberAdded, TargetIDs: added})
+	}
+	if len(removed) > 0 {
+		s.roomMembersChanged(roomID, removed, false)
+		s.SystemMsg.Post(roomID, message.SystemInfo{Event: EventRoomMemberRemoved, TargetIDs: removed})
+	}
+	return nil
+}
+
+// Tree 完整的部门树（按 sort、id 排序），含各部门直属成员数
+func (s *OrgService) Tree() ([]*DepartmentNode, error) {
+	var depts []models.Department
+	if err := s.DB.Order("sort, id").Find(&depts).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		DepartmentID uint64
+		N            int64
+	}
+	if err := s.DB.Model(&models.DepartmentMember{}).Select("department_id, COUNT(*) AS n").
+		Group("department_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countOf := make(map[uint64]int64, len(counts))
+	for _, c := range counts {
+		countOf[c.DepartmentID] = c.N
+	}
+
+	nodes := make(map[uint64]*DepartmentNode, len(depts))
+	for i := range depts {
+		nodes[depts[i].ID] = &DepartmentNode{DepartmentDTO: toDepartmentDTO(&depts[i], countOf[depts[i].ID]), Children: []*DepartmentNode{}}
+	}
+	roots := []*DepartmentNode{}
+	for i := range depts {
+		n := nodes[depts[i].ID]
+		// 上级已被删除的部门挂到顶级
+		if parent, ok := nodes[depts[i].ParentID]; ok {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	return roots, nil
+}
+
+// Members 部门成员（负责人在前），recursive 为 true 时包含所有下级部门的成员（同一用户只出现一次）
+func (s *OrgService) Members(departmentID uint64, recursive bool) ([]OrgMemberDTO, error) {
+	if _, err := s.department(departmentID); err != nil {
+		return nil, err
+	}
+	deptIDs := []uint64{departmentID}
+	if recursive {
+		var all []models.Department
+		if err := s.DB.Select("id", "parent_id").Find(&all).Error; err != nil {
+			return nil, err
+		}
+		children := make(map[uint64][]uint64, len(all))
+		for _, d := range all {
+			children[d.ParentID] = append(children[d.ParentID], d.ID)
+		}
+		for i := 0; i < len(deptIDs); i++ {
+			deptIDs = append(deptIDs, children[deptIDs[i]]...)
+		}
+	}
+	var rows []models.DepartmentMember
+	if err := s.DB.Where("department_id IN ?", deptIDs).Order("is_leader DESC, sort, id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return s.toOrgMembers(rows, !recursive)
+}
+
+// UserDepartments 用户所在的部门及职位
+func (s *OrgService) UserDepartments(userID uint64) ([]OrgMemberDTO, error) {
+	var rows []models.DepartmentMember
+	if err := s.DB.Where("user_id = ?", userID).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return s.toOrgMembers(rows, true)
+}
+
+// toOrgMembers 补充用户资料与部门名称，keepDup 为 false 时同一用户只保留第一条
+func (s *OrgService) toOrgMembers(rows []models.DepartmentMember, keepDup bool) ([]OrgMemberDTO, error) {
+	out := make([]OrgMemberDTO, 0, len(rows))
+	if len(rows) == 0 {
+		return out, nil
+	}
+	userIDs := make([]uint64, 0, len(rows))
+	deptIDs := make([]uint64, 0, len(rows))
+	for _, r := range rows {
+		userIDs = append(userIDs, r.UserID)
+		deptIDs = append(deptIDs, r.DepartmentID)
+	}
+	var users []models.User
+	if err := s.DB.Select("id", "username", "nickname", "avatar").Where("id IN ?", uniqueUint64s(userIDs)).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	var depts []models.Department
+	if err := s.DB.Select("id", "name").Where("id IN ?", uniqueUint64s(deptIDs)).Find(&depts).Error; err != nil {
+		return nil, err
+	}
+	userOf := make(map[uint64]*models.User, len(users))
+	for i := range users {
+		userOf[users[i].ID] = &users[i]
+	}
+	deptName := make(map[uint64]string, len(depts))
+	for _, d := range depts {
+		deptName[d.ID] = d.Name
+	}
+	seen := make(map[uint64]bool, len(rows))
+	for _, r := range rows {
+		u := userOf[r.UserID]
+		if u == nil || (!keepDup && seen[r.UserID]) {
+			continue
+		}
+		seen[r.UserID] = true
+		out = append(out, OrgMemberDTO{
+			UserID: u.ID, Username: u.Username, Nickname: u.Nickname, Avatar: u.Avatar,
+			DepartmentID: r.DepartmentID, DepartmentName: deptName[r.DepartmentID], Title: r.Title, IsLeader: r.IsLeader,
+		})
+	}
+	return out, nil
+}
+
+// StartChat 与同事发起私聊：双方都在组织架构中即可，无需互为好友；房间已存在时直接返回
+func (s *OrgService) StartChat(userID, peerID uint64) (*OrgChatDTO, error) {
+	if peerID == 0 || peerID == userID {
+		return nil, ErrChatSelf
+	}
+	var n int64
+	if err := s.DB.Model(&models.DepartmentMember{}).Where("user_id IN ?", []uint64{userID, peerID}).
+		Distinct("user_id").Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n != 2 {
+		return nil, ErrNotColleague
+	}
+
+	var newRoomID uint64
+	err := s.Tx(func(tx *gorm.DB) error {
+		var err error
+		newRoomID, err = ensureFriendRoom(tx, userID, peerID, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.roomMembersChanged(newRoomID, []uint64{userID, peerID}, true)
+
+	account := generatePrivateRoomAccount(userID, peerID)
+	var room models.Room
+	if err := s.DB.Select("id", "room_account").Where("room_account = ?", account).First(&room).Error; err != nil {
+		return nil, err
+	}
+	return &OrgChatDTO{RoomID: room.ID, RoomAccount: room.RoomAccount}, nil
+}
+
+func (s *OrgService) department(id uint64) (*models.Department, error) {
+	var d models.Department
+	if err := s.DB.First(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDepartmentNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// uniqueUint64s 去重并保持顺序，忽略 0
+func uniqueUint64s(ids []uint64) []uint64 {
+	out := make([]uint64, 0, len(ids))
+	seen := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOrgService_SyncDepartmentGroup(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	var changed []string
+	s := &OrgService{Service: &Service{DB: db, RoomMembersChanged: func(roomID uint64, uids []uint64, joined bool) {
+		changed = append(changed, fmt.Sprintf("%d:%v:%v", roomID, uids, joined))
+	}}}
+
+	mock.ExpectQuery("SELECT \\* FROM `im_department` WHERE `im_department`.`id` = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "room_id"}).AddRow(1, "研发部", 50))
+	// 部门成员：1（负责人）、2；群成员：1（普通成员）、3（已离开部门）
+	mock.ExpectQuery("SELECT `user_id`,`is_leader` FROM `im_department_member` WHERE department_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "is_leader"}).AddRow(1, true).AddRow(2, false))
+	mock.ExpectQuery("SELECT `user_id`,`role` FROM `im_room_user` WHERE room_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "role"}).AddRow(1, 0).AddRow(3, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_room` SET `member_limit`=\\?,`updated_at`=\\? WHERE \\(id = \\? AND member_limit < \\?\\)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE `im_room_user` SET `role`=\\?,`updated_at`=\\? WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(1, sqlmock.AnyArg(), 50, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `im_room_user` ").WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectExec("INSERT INTO `im_conversation` .* ON DUPLICATE KEY UPDATE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM `im_room_user` WHERE room_id = \\? AND user_id IN \\(\\?\\)").
+		WithArgs(50, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `im_conversation` SET `is_visible`=\\?,`updated_at`=\\? WHERE room_id = \\? AND user_id IN \\(\\?\\)").
+		WithArgs(false, sqlmock.AnyArg(), 50, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.SyncDepartmentGroup(1); err != nil {
+		t.Fatalf("SyncDepartmentGroup: %v", err)
+	}
+	if len(changed) != 2 || changed[0] != "50:[2]:true" || changed[1] != "50:[3]:false" {
+		t.Fatalf("unexpected member changes: %v", changed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrgService_UpdateDepartmentCycle(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &OrgService{Service: &Service{DB: db}}
+
+	// 1 -> 2 -> 3，把 1 移到 3 下面会形成环
+	mock.ExpectQuery("SELECT \\* FROM `im_department`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(1, 0))
+	mock.ExpectQuery("SELECT \\* FROM `im_department`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(3, 2))
+	mock.ExpectQuery("SELECT \\* FROM `im_department`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, 1))
+	parent := uint64(3)
+	if err := s.UpdateDepartment(UpdateDepartmentReq{ID: 1, ParentID: &parent}); err == nil {
+		t.Fatal("moving a department under its descendant should fail")
+	}
+
+	mock.ExpectQuery("SELECT \\* FROM `im_department`").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if _, err := s.Members(9, false); !errors.Is(err, ErrDepartmentNotFound) {
+		t.Fatalf("want ErrDepartmentNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrgService_Tree(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &OrgService{Service: &Service{DB: db}}
+
+	mock.ExpectQuery("SELECT \\* FROM `im_department` ORDER BY sort, id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "name"}).
+			AddRow(1, 0, "总部").AddRow(2, 1, "研发部").AddRow(3, 1, "市场部").AddRow(4, 99, "孤儿部门"))
+	mock.ExpectQuery("SELECT department_id, COUNT\\(\\*\\) AS n FROM `im_department_member` GROUP BY `department_id`").
+		WillReturnRows(sqlmock.NewRows([]string{"department_id", "n"}).AddRow(2, 5))
+
+	tree, err := s.Tree()
+	if err != nil {
+		t.Fatalf("Tree: %v", err)
+	}
+	if len(tree) != 2 || len(tree[0].Children) != 2 || tree[0].Children[0].MemberCount != 5 || tree[1].Name != "孤儿部门" {
+		t.Fatalf("unexpected tree: %+v", tree)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// PinyinConverter 把名字转换为通讯录索引字母："A"–"Z"，无法识别时返回 "#"。
+// 默认实现只覆盖常用汉字，需要完整拼音库时用 WithPinyinConverter 替换。
+type PinyinConverter interface {
+	Initial(name string) string
+}
+
+// PinyinConverterFunc 函数形式的 PinyinConverter
+type PinyinConverterFunc func(name string) string
+
+func (f PinyinConverterFunc) Initial(name string) string { return f(name) }
+
+// DefaultPinyinConverter 默认转换：GB2312 一级汉字码位表 + 内置姓名用字词典
+var DefaultPinyinConverter PinyinConverter = PinyinConverterFunc(pinyinNameIndex)
+
+// nameIndexOther 无法归入 A–Z 的名字所在分组
+const nameIndexOther = "#"
+
+// nameInitials 名字首字专用的小词典，优先于码位表：多音字姓氏（曾 zeng、单 shan 等）
+// 以及名字里常见、但不在一级汉字内的字
+var nameInitials = map[rune]byte{
+	'曾': 'z', '单': 's', '仇': 'q', '区': 'o', '解': 'x', '查': 'z', '朴': 'p', '翟': 'z',
+	'尉': 'y', '乐': 'y', '缪': 'm', '秘': 'b', '覃': 'q', '隗': 'w', '种': 'c', '重': 'c',
+	'钰': 'y', '琪': 'q', '璐': 'l', '婧': 'j', '琦': 'q', '昊': 'h', '祎': 'y', '喆': 'z',
+	'玥': 'y', '晗': 'h', '芮': 'r', '璇': 'x', '彧': 'y', '翊': 'y', '骞': 'q', '楠': 'n',
+	'妍': 'y', '熠': 'y', '烨': 'y', '瑾': 'j', '珂': 'k',
+}
+
+// firstInitial 名字首字的拼音首字母（先查 nameInitials）
+func firstInitial(r rune) byte {
+	if c, ok := nameInitials[r]; ok {
+		return c
+	}
+	return pinyinInitial(r)
+}
+
+// pinyinNameIndex 名字的通讯录索引字母（大写），无法识别时返回 "#"
+func pinyinNameIndex(name string) string {
+	for _, r := range strings.TrimSpace(name) {
+		if c := firstInitial(r); c != 0 {
+			return string(c - 'a' + 'A')
+		}
+		break
+	}
+	return nameIndexOther
+}
+
+// gb2312Initials GB2312 一级汉字（按拼音排列）各声母的起始编码，用于取拼音首字母；
+// 二级汉字按部首排列，无法据此取首字母，归入 "#"。
+var gb2312Initials = []struct {
+	code    uint16
+	initial byte
+}{
+	{0xB0A1, 'a'}, {0xB0C5, 'b'}, {0xB2C1, 'c'}, {0xB4EE, 'd'}, {0xB6EA, 'e'}, {0xB7A2, 'f'},
+	{0xB8C1, 'g'}, {0xB9FE, 'h'}, {0xBBF7, 'j'}, {0xBFA6, 'k'}, {0xC0AC, 'l'}, {0xC2E8, 'm'},
+	{0xC4C3, 'n'}, {0xC5B6, 'o'}, {0xC5BE, 'p'}, {0xC6DA, 'q'}, {0xC8BB, 'r'}, {0xC8F6, 's'},
+	{0xCBFA, 't'}, {0xCDDA, 'w'}, {0xCEF4, 'x'}, {0xD1B9, 'y'}, {0xD4D1, 'z'},
+}
+
+// gb2312Level1End 一级汉字结束编码
+const gb2312Level1End = 0xD7F9
+
+// gbkCode 汉字的 GBK 编码，非汉字或无法编码时返回 0
+func gbkCode(r rune) uint16 {
+	if !unicode.Is(unicode.Han, r) {
+		return 0
+	}
+	b, err := simplifiedchinese.GBK.NewEncoder().String(string(r))
+	if err != nil || len(b) != 2 {
+		return 0
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// pinyinInitial 字符的拼音首字母（小写）：英文字母返回自身小写，常用汉字返回拼音首字母，其他返回 0
+func pinyinInitial(r rune) byte {
+	if r < unicode.MaxASCII {
+		if unicode.IsLetter(r) {
+			return byte(unicode.ToLower(r))
+		}
+		return 0
+	}
+	code := gbkCode(r)
+	if code < gb2312Initials[0].code || code > gb2312Level1End {
+		return 0
+	}
+	initial := gb2312Initials[0].initial
+	for _, g := range gb2312Initials {
+		if code < g.code {
+			break
+		}
+		initial = g.initial
+	}
+	return initial
+}
+
+// pinyinSortKey 按拼音/字母排序的键：英文忽略大小写，常用汉字按拼音（首字母 + GB2312 编码，一级汉字的编码本身按拼音排列），
+// 同首字母时英文排在汉字前；不以字母或常用汉字开头的名字排在最后。
+func pinyinSortKey(name string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(name) {
+		initial := pinyinInitial(r)
+		if i == 0 {
+			initial = firstInitial(r)
+		}
+		if i == 0 && initial == 0 {
+			b.WriteByte('{') // 排在 'z' 之后
+		}
+		switch {
+		case initial == 0:
+			b.WriteString(strings.ToLower(string(r)))
+		case r < unicode.MaxASCII:
+			b.WriteByte(initial)
+		default:
+			code := gbkCode(r)
+			b.WriteByte(initial)
+			b.WriteByte(byte(code >> 8))
+			b.WriteByte(byte(code))
+		}
+	}
+	return b.String()
+}
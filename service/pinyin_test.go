@@ -0,0 +1,66 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestPinyinNameIndex(t *testing.T) {
+	cases := map[string]string{
+		"张三":     "Z",
+		"阿强":     "A",
+		"bob":    "B",
+		" Alice": "A",
+		"曾小贤":    "Z", // 多音字姓氏走词典
+		"单田芳":    "S",
+		"王钰":     "W",
+		"钰涵":     "Y", // 二级汉字走词典
+		"123":    "#",
+		"😀":      "#",
+		"":       "#",
+	}
+	for name, want := range cases {
+		if got := pinyinNameIndex(name); got != want {
+			t.Errorf("pinyinNameIndex(%q)=%q want %q", name, got, want)
+		}
+	}
+}
+
+func TestGroupFriendSections(t *testing.T) {
+	dtos := []UserDTO{
+		{ID: 1, NameIndex: "#"}, {ID: 2, NameIndex: "Z"}, {ID: 3, NameIndex: "A"}, {ID: 4, NameIndex: "A"},
+	}
+	got := groupFriendSections(dtos)
+	if len(got) != 3 || got[0].Index != "A" || got[1].Index != "Z" || got[2].Index != "#" {
+		t.Fatalf("sections order: %+v", got)
+	}
+	if got[0].Friends[0].ID != 3 || got[0].Friends[1].ID != 4 {
+		t.Fatalf("section A should keep order: %+v", got[0].Friends)
+	}
+}
+
+func TestMemberService_FillNameIndex(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	ms := NewMemberService(&Service{DB: gormDB})
+
+	friends := []models.Friend{{ID: 10, NameIndex: "L"}, {ID: 11}, {ID: 12}}
+	dtos := []UserDTO{{ID: 1, NameIndex: "L", Nickname: "李四"}, {ID: 2, Nickname: "张三", Remark: "老板"}, {ID: 3, Username: "42"}}
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `im_friend` SET `name_index`=? WHERE id IN (?)")).
+		WithArgs("L", 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `im_friend` SET `name_index`=? WHERE id IN (?)")).
+		WithArgs("#", 12).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ms.fillNameIndex(friends, dtos)
+	if dtos[1].NameIndex != "L" || dtos[2].NameIndex != "#" {
+		t.Fatalf("name index: %+v", dtos)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pollMaxOptions = 20
+	pollMinOptions = 2
+)
+
+// 投票错误
+var (
+	ErrPollQuestionRequired = newError(response.CodeParamError, "err.poll_question_required")
+	ErrPollOptions          = newError(response.CodeParamError, "err.poll_options", pollMinOptions, pollMaxOptions)
+	ErrPollChoiceRequired   = newError(response.CodeParamError, "err.poll_choice_required")
+	ErrPollNotFound         = newError(response.CodeParamError, "err.poll_not_found")
+	ErrPollClosed           = newError(response.CodeParamError, "err.poll_closed")
+	ErrPollSingleChoice     = newError(response.CodeParamError, "err.poll_single_choice")
+	ErrPollVoted            = newError(response.CodeParamError, "err.poll_voted")
+	ErrPollOptionInvalid    = newError(response.CodeParamError, "err.poll_option_invalid")
+	ErrPollCloseDenied      = newError(response.CodePermissionDeny, "err.poll_close_denied")
+)
+
+// PollService 群投票：投票以特殊消息（type=11）发出，投票/结束后向房间成员推送 poll_update。
+type PollService struct {
+	*Service
+	messageService *MessageService
+}
+
+func NewPollService(s *Service) *PollService {
+	log.Println("NewPollService")
+	return &PollService{Service: s, messageService: NewMessageService(s)}
+}
+
+// CreatePollReq 创建投票参数
+type CreatePollReq struct {
+	RoomID          uint64   `json:"room_id"`
+	Question        string   `json:"question"`
+	Options         []string `json:"options"`
+	MultiChoice     bool     `json:"multi_choice"`
+	MaxChoices      int      `json:"max_choices"` // 多选上限，0 表示不限
+	Anonymous       bool     `json:"anonymous"`
+	DeadlineMinutes int      `json:"deadline_minutes"` // 0 表示手动结束
+}
+
+// PollOptionDTO 选项结果
+type PollOptionDTO struct {
+	ID        uint64   `json:"id"`
+	Text      string   `json:"text"`
+	VoteCount int      `json:"vote_count"`
+	Voters    []uint64 `json:"voters,omitempty"` // 匿名投票不返回
+}
+
+// PollDTO 投票详情/结果
+type PollDTO struct {
+	ID          uint64          `json:"id"`
+	RoomID      uint64          `json:"room_id"`
+	CreatorID   uint64          `json:"creator_id"`
+	MessageID   uint64          `json:"message_id"`
+	Question    string          `json:"question"`
+	MultiChoice bool            `json:"multi_choice"`
+	MaxChoices  int             `json:"max_choices"`
+	Anonymous   bool            `json:"anonymous"`
+	Status      uint8           `json:"status"` // 1-进行中 2-已结束
+	Deadline    *time.Time      `json:"deadline"`
+	TotalVoters int             `json:"total_voters"`
+	Options     []PollOptionDTO `json:"options"`
+	MyOptionIDs []uint64        `json:"my_option_ids,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func (s *PollService) isMember(roomID, userID uint64) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreatePoll 创建投票并发出投票消息
+func (s *PollService) CreatePoll(creatorID uint64, req CreatePollReq) (*models.Poll, *models.Message, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, nil, ErrPollQuestionRequired
+	}
+	options := make([]string, 0, len(req.Options))
+	seen := make(map[string]struct{}, len(req.Options))
+	for _, o := range req.Options {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		if _, ok := seen[o]; ok {
+			continue
+		}
+		seen[o] = struct{}{}
+		options = append(options, o)
+	}
+	if len(options) < pollMinOptions || len(options) > pollMaxOptions {
+		return nil, nil, ErrPollOptions
+	}
+	maxChoices := 1
+	if req.MultiChoice {
+		maxChoices = req.MaxChoices
+		if maxChoices <= 0 || maxChoices > len(options) {
+			maxChoices = len(options)
+		}
+	}
+	ok, err := s.isMember(req.RoomID, creatorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrNotRoomMember
+	}
+
+	poll := &models.Poll{
+		RoomID:      req.RoomID,
+		CreatorID:   creatorID,
+		Question:    question,
+		MultiChoice: req.MultiChoice,
+		MaxChoices:  maxChoices,
+		Anonymous:   req.Anonymous,
+		Status:      models.PollStatusOpen,
+	}
+	if req.DeadlineMinutes > 0 {
+		d := time.Now().Add(time.Duration(req.DeadlineMinutes) * time.Minute)
+		poll.Deadline = &d
+	}
+	for i, o := range options {
+		poll.Options = append(poll.Options, models.PollOption{Text: o, Sort: i})
+	}
+	if err := s.DB.Create(poll).Error; err != nil {
+		return nil, nil, err
+	}
+
+	msg, err := s.messageService.SaveMessage(req.RoomID, creatorID, question, models.MessageTypePoll, message.Extra{
+		Poll: &message.PollInfo{PollID: poll.ID, Question: question, MultiChoice: poll.MultiChoice, Anonymous: poll.Anonymous},
+	})
+	if err != nil {
+		_ = s.DB.Select("Options").Delete(poll).Error
+		return nil, nil, err
+	}
+	_ = s.DB.Model(&models.Poll{}).Where("id = ?", poll.ID).Update("message_id", msg.ID).Error
+	poll.MessageID = msg.ID
+	return poll, msg, nil
+}
+
+// Vote 投票（每人只能提交一次）
+func (s *PollService) Vote(userID, pollID uint64, optionIDs []uint64) (*PollDTO, error) {
+	uniq := make([]uint64, 0, len(optionIDs))
+	seen := make(map[uint64]struct{}, len(optionIDs))
+	for _, id := range optionIDs {
+		if _, ok := seen[id]; ok || id == 0 {
+			continue
+		}
+		seen[id] = struct{}{}
+		uniq = append(uniq, id)
+	}
+	if len(uniq) == 0 {
+		return nil, ErrPollChoiceRequired
+	}
+
+	var poll models.Poll
+	err := s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&poll, pollID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPollNotFound
+			}
+			return err
+		}
+		if poll.Status != models.PollStatusOpen || (poll.Deadline != nil && time.Now().After(*poll.Deadline)) {
+			return ErrPollClosed
+		}
+		if !poll.MultiChoice && len(uniq) > 1 {
+			return ErrPollSingleChoice
+		}
+		if poll.MultiChoice && poll.MaxChoices > 0 && len(uniq) > poll.MaxChoices {
+			return newError(response.CodeParamError, "err.poll_max_choices", poll.MaxChoices)
+		}
+		var cnt int64
+		if err := tx.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", poll.RoomID, userID).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt == 0 {
+			return ErrNotRoomMember
+		}
+		if err := tx.Model(&models.PollVote{}).Where("poll_id = ? AND user_id = ?", poll.ID, userID).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return ErrPollVoted
+		}
+		if err := tx.Model(&models.PollOption{}).Where("poll_id = ? AND id IN ?", poll.ID, uniq).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if int(cnt) != len(uniq) {
+			return ErrPollOptionInvalid
+		}
+		now := time.Now()
+		votes := make([]models.PollVote, 0, len(uniq))
+		for _, oid := range uniq {
+			votes = append(votes, models.PollVote{PollID: poll.ID, UserID: userID, OptionID: oid, CreatedAt: now})
+		}
+		if err := tx.Create(&votes).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PollOption{}).Where("id IN ?", uniq).
+			UpdateColumn("vote_count", gorm.Expr("vote_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.pushUpdate(poll.ID)
+	return s.GetPoll(userID, poll.ID)
+}
+
+// ClosePoll 发起人结束投票
+func (s *PollService) ClosePoll(operatorID, pollID uint64) error {
+	var poll models.Poll
+	if err := s.DB.First(&poll, pollID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPollNotFound
+		}
+		return err
+	}
+	if poll.CreatorID != operatorID {
+		return ErrPollCloseDenied
+	}
+	return s.close(poll.ID)
+}
+
+func (s *PollService) close(pollID uint64) error {
+	now := time.Now()
+	res := s.DB.Model(&models.Poll{}).
+		Where("id = ? AND status = ?", pollID, models.PollStatusOpen).
+		Updates(map[string]any{"status": models.PollStatusClosed, "closed_at": &now, "updated_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		s.pushUpdate(pollID)
+	}
+	return nil
+}
+
+// CloseExpired 结束已到截止时间的投票
+func (s *PollService) CloseExpired() error {
+	var ids []uint64
+	if err := s.DB.Model(&models.Poll{}).
+		Where("status = ? AND deadline IS NOT NULL AND deadline < ?", models.PollStatusOpen, time.Now()).
+		Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.close(id); err != nil {
+			log.Printf("close poll %d failed: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// RunCloseLoop 定时结束到期投票（阻塞，engine 中以 goroutine 启动）
+func (s *PollService) RunCloseLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.CloseExpired(); err != nil {
+			log.Printf("poll close loop: %v", err)
+		}
+	}
+}
+
+// buildPollDTO viewerID=0 时不填 MyOptionIDs
+func (s *PollService) buildPollDTO(viewerID uint64, poll *models.Poll) (*PollDTO, error) {
+	var options []models.PollOption
+	if err := s.DB.Where("poll_id = ?", poll.ID).Order("sort ASC, id ASC").Find(&options).Error; err != nil {
+		return nil, err
+	}
+	var votes []models.PollVote
+	if err := s.DB.Where("poll_id = ?", poll.ID).Order("id ASC").Find(&votes).Error; err != nil {
+		return nil, err
+	}
+
+	voters := make(map[uint64][]uint64, len(options))
+	uniqVoters := make(map[uint64]struct{})
+	var mine []uint64
+	for _, v := range votes {
+		uniqVoters[v.UserID] = struct{}{}
+		voters[v.OptionID] = append(voters[v.OptionID], v.UserID)
+		if viewerID != 0 && v.UserID == viewerID {
+			mine = append(mine, v.OptionID)
+		}
+	}
+
+	status := poll.Status
+	if status == models.PollStatusOpen && poll.Deadline != nil && time.Now().After(*poll.Deadline) {
+		status = models.PollStatusClosed
+	}
+	dto := &PollDTO{
+		ID:          poll.ID,
+		RoomID:      poll.RoomID,
+		CreatorID:   poll.CreatorID,
+		MessageID:   poll.MessageID,
+		Question:    poll.Question,
+		MultiChoice: poll.MultiChoice,
+		MaxChoices:  poll.MaxChoices,
+		Anonymous:   poll.Anonymous,
+		Status:      status,
+		Deadline:    poll.Deadline,
+		TotalVoters: len(uniqVoters),
+		Options:     make([]PollOptionDTO, 0, len(options)),
+		MyOptionIDs: mine,
+		CreatedAt:   poll.CreatedAt,
+	}
+	for _, o := range options {
+		item := PollOptionDTO{ID: o.ID, Text: o.Text, VoteCount: len(voters[o.ID])}
+		if !poll.Anonymous {
+			item.Voters = voters[o.ID]
+		}
+		dto.Options = append(dto.Options, item)
+	}
+	return dto, nil
+}
+
+// GetPoll 投票详情（房间成员可见）
+func (s *PollService) GetPoll(viewerID, pollID uint64) (*PollDTO, error) {
+	var poll models.Poll
+	if err := s.DB.First(&poll, pollID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPollNotFound
+		}
+		return nil, err
+	}
+	ok, err := s.isMember(poll.RoomID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotRoomMember
+	}
+	return s.buildPollDTO(viewerID, &poll)
+}
+
+// pushUpdate 向房间成员推送最新投票结果
+func (s *PollService) pushUpdate(pollID uint64) {
+	if s.WsNotifier == nil && s.WsBatchNotifier == nil {
+		return
+	}
+	var poll models.Poll
+	if err := s.DB.First(&poll, pollID).Error; err != nil {
+		return
+	}
+	dto, err := s.buildPollDTO(0, &poll)
+	if err != nil {
+		return
+	}
+	members, err := NewRoomService(s.Service).GetRoomMembers(poll.RoomID)
+	if err != nil {
+		return
+	}
+	s.Emit(members, &message.PollUpdatedEvent{RoomID: poll.RoomID, Poll: dto})
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func expectPollRows(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT \\* FROM `im_poll_option` WHERE poll_id = \\? ORDER BY sort ASC, id ASC").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "poll_id", "text", "sort"}).
+			AddRow(1, 7, "A", 0).
+			AddRow(2, 7, "B", 1))
+	mock.ExpectQuery("SELECT \\* FROM `im_poll_vote` WHERE poll_id = \\? ORDER BY id ASC").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "poll_id", "user_id", "option_id"}).
+			AddRow(1, 7, 100, 1).
+			AddRow(2, 7, 100, 2).
+			AddRow(3, 7, 200, 2))
+}
+
+func TestPollService_BuildPollDTO(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	ps := NewPollService(&Service{DB: gormDB})
+
+	expectPollRows(mock)
+	dto, err := ps.buildPollDTO(100, &models.Poll{ID: 7, MultiChoice: true, Status: models.PollStatusOpen})
+	if err != nil {
+		t.Fatalf("buildPollDTO: %v", err)
+	}
+	if dto.TotalVoters != 2 {
+		t.Fatalf("expected 2 voters, got %d", dto.TotalVoters)
+	}
+	if dto.Options[0].VoteCount != 1 || dto.Options[1].VoteCount != 2 {
+		t.Fatalf("unexpected counts: %+v", dto.Options)
+	}
+	if len(dto.Options[1].Voters) != 2 {
+		t.Fatalf("named poll should expose voters: %+v", dto.Options[1])
+	}
+	if len(dto.MyOptionIDs) != 2 {
+		t.Fatalf("expected my options [1 2], got %v", dto.MyOptionIDs)
+	}
+
+	// 匿名投票不返回投票人
+	expectPollRows(mock)
+	dto, err = ps.buildPollDTO(0, &models.Poll{ID: 7, Anonymous: true, Status: models.PollStatusOpen})
+	if err != nil {
+		t.Fatalf("buildPollDTO: %v", err)
+	}
+	for _, o := range dto.Options {
+		if len(o.Voters) != 0 {
+			t.Fatalf("anonymous poll leaked voters: %+v", o)
+		}
+	}
+	if dto.MyOptionIDs != nil {
+		t.Fatalf("viewer 0 should not have my_option_ids")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/cydxin/chat-sdk/response"
+	"io"
+	"strings"
+)
+
+// provisionCSVColumns 各类数据的 CSV 列（表头），列名与 JSON 字段一致；多值列（admins/members）用 | 分隔
+var provisionCSVColumns = map[string][]string{
+	ProvisionKindUsers:       {"username", "nickname", "password", "phone", "email", "avatar"},
+	ProvisionKindFriendships: {"user", "friend"},
+	ProvisionKindGroups:      {"room_account", "name", "owner", "admins", "members"},
+}
+
+// provisionCSVRequired 各类数据必须出现在表头中的列
+var provisionCSVRequired = map[string][]string{
+	ProvisionKindUsers:       {"username"},
+	ProvisionKindFriendships: {"user", "friend"},
+	ProvisionKindGroups:      {"room_account"},
+}
+
+// CSV 导入/导出错误，参数带上类别、列名或出错的行号
+var (
+	ErrProvisionKind       = newError(response.CodeParamError, "err.provision_kind_invalid")
+	ErrProvisionCSVColumn  = newError(response.CodeParamError, "err.provision_csv_column")
+	ErrProvisionCSVInvalid = newError(response.CodeParamError, "err.provision_csv_invalid")
+)
+
+// provisionCSVError CSV 格式错误时返回带文件名与行号的参数错误，其他读取错误原样包装
+func provisionCSVError(kind string, err error) error {
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return newError(response.CodeParamError, "err.provision_csv_invalid", kind, pe.Line, pe.Err)
+	}
+	return fmt.Errorf("%s.csv: %w", kind, err)
+}
+
+// ParseProvisionCSV 解析一类数据的 CSV 并追加到 into：首行为表头（不区分大小写、顺序任意，未知列忽略），空行跳过
+func ParseProvisionCSV(kind string, r io.Reader, into *ProvisionRequest) error {
+	if _, ok := provisionCSVColumns[kind]; !ok {
+		return newError(response.CodeParamError, "err.provision_kind_invalid", kind)
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return provisionCSVError(kind, err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		// 兼容 Excel 导出的 UTF-8 BOM
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, col := range provisionCSVRequired[kind] {
+		if _, ok := cols[col]; !ok {
+			return newError(response.CodeParamError, "err.provision_csv_column", kind, col)
+		}
+	}
+
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return provisionCSVError(kind, err)
+		}
+		get := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(rec, "")) == "" {
+			continue
+		}
+		switch kind {
+		case ProvisionKindUsers:
+			into.Users = append(into.Users, ProvisionUser{
+				Username: get("username"),
+				Nickname: get("nickname"),
+				Password: get("password"),
+				Phone:    get("phone"),
+				Email:    get("email"),
+				Avatar:   get("avatar"),
+			})
+		case ProvisionKindFriendships:
+			into.Friendships = append(into.Friendships, ProvisionFriendship{User: get("user"), Friend: get("friend")})
+		case ProvisionKindGroups:
+			into.Groups = append(into.Groups, ProvisionGroup{
+				RoomAccount: get("room_account"),
+				Name:        get("name"),
+				Owner:       get("owner"),
+				Admins:      splitProvisionList(get("admins")),
+				Members:     splitProvisionList(get("members")),
+			})
+		}
+	}
+}
+
+func splitProvisionList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// WriteProvisionCSV 把一类数据写成 CSV（表头同 ParseProvisionCSV），导出结果可直接用于导入
+func WriteProvisionCSV(kind string, w io.Writer, data *ProvisionRequest) error {
+	header, ok := provisionCSVColumns[kind]
+	if !ok {
+		return newError(response.CodeParamError, "err.provision_kind_invalid", kind)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	switch kind {
+	case ProvisionKindUsers:
+		for _, u := range data.Users {
+			if err := cw.Write([]string{u.Username, u.Nickname, "", u.Phone, u.Email, u.Avatar}); err != nil {
+				return err
+			}
+		}
+	case ProvisionKindFriendships:
+		for _, f := range data.Friendships {
+			if err := cw.Write([]string{f.User, f.Friend}); err != nil {
+				return err
+			}
+		}
+	case ProvisionKindGroups:
+		for _, g := range data.Groups {
+			if err := cw.Write([]string{g.RoomAccount, g.Name, g.Owner, strings.Join(g.Admins, "|"), strings.Join(g.Members, "|")}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,601 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ProvisionMaxRows 单次批量导入的最大行数（用户、好友关系、群合计），更多数据请分批导入
+const ProvisionMaxRows = 5000
+
+// ErrProvisionTooMany 单次导入超过 ProvisionMaxRows 行
+var ErrProvisionTooMany = newError(response.CodeParamError, "err.provision_too_many", ProvisionMaxRows)
+
+// 单行导入错误（写入报告的 error 字段），参数带上出错的 username / room_account
+var (
+	ErrProvisionUserNotFound        = newError(response.CodeParamError, "err.provision_user_not_found")
+	ErrProvisionUserDuplicate       = newError(response.CodeParamError, "err.provision_user_duplicate")
+	ErrProvisionFriendSelf          = newError(response.CodeParamError, "err.provision_friend_self")
+	ErrProvisionRoomAccountRequired = newError(response.CodeParamError, "err.provision_room_account_required")
+	ErrProvisionGroupOwnerRequired  = newError(response.CodeParamError, "err.provision_group_owner_required")
+	ErrProvisionNotGroup            = newError(response.CodeParamError, "err.provision_not_group")
+)
+
+// errProvisionDryRun 试运行时回滚事务
+var errProvisionDryRun = errors.New("provision dry run")
+
+// 导入数据的类别（也是 CSV 导入/导出的 kind 与 multipart 文件字段名）
+const (
+	ProvisionKindUsers       = "users"
+	ProvisionKindFriendships = "friendships"
+	ProvisionKindGroups      = "groups"
+)
+
+// 单行的处理结果
+const (
+	ProvisionCreated   = "created"
+	ProvisionUpdated   = "updated"
+	ProvisionUnchanged = "unchanged"
+	ProvisionFailed    = "failed"
+)
+
+// ProvisionService 从企业 HR/组织架构批量开通用户、好友关系与群成员（管理端使用）：
+// - 按业务键幂等 upsert：用户按 username、好友按双方 username、群按 room_account，重复导入不会产生重复数据；
+// - 每行单独处理（事务内的保存点），失败的行写入报告，不影响其他行；
+// - dry-run 在同一事务里完整执行后回滚，报告与真实导入一致，但不落库、不触发通知。
+type ProvisionService struct {
+	*Service
+	// Users 设置后更新用户资料时清理其资料缓存（引擎自动注入）
+	Users *UserService
+}
+
+func NewProvisionService(s *Service) *ProvisionService {
+	log.Println("NewProvisionService")
+	return &ProvisionService{Service: s}
+}
+
+// ProvisionUser 用户行，按 username 幂等：不存在时创建，存在时按非空字段更新资料
+type ProvisionUser struct {
+	Username string `json:"username"`
+	Nickname string `json:"nickname,omitempty"` // 新建时为空取 username
+	// Password 新建时为空则不设置密码，用户只能验证码登录（或找回密码后设置）；已存在用户非空时重置密码
+	Password string `json:"password,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+}
+
+// ProvisionFriendship 好友关系行（双方 username），建立双向好友并创建私聊房间；已拉黑的一方保持拉黑
+type ProvisionFriendship struct {
+	User   string `json:"user"`
+	Friend string `json:"friend"`
+}
+
+// ProvisionGroup 群行，按 room_account（群号）幂等：
+//   - 不存在时以 owner 为群主创建，成员数超过默认上限时上限随之提高；
+//   - 已存在时更新群名、补充成员、把 admins 中的普通成员设为管理员，不移除成员、不转让群主。
+type ProvisionGroup struct {
+	RoomAccount string   `json:"room_account"`
+	Name        string   `json:"name,omitempty"`  // 新建时为空取 room_account
+	Owner       string   `json:"owner,omitempty"` // 新建时必填
+	Admins      []string `json:"admins,omitempty"`
+	Members     []string `json:"members,omitempty"`
+}
+
+// ProvisionRequest 批量导入的数据，按用户、好友关系、群的顺序处理（后两者可引用本批次新建的用户）
+type ProvisionRequest struct {
+	Users       []ProvisionUser       `json:"users,omitempty"`
+	Friendships []ProvisionFriendship `json:"friendships,omitempty"`
+	Groups      []ProvisionGroup      `json:"groups,omitempty"`
+}
+
+// ProvisionRowResult 单行的处理结果
+type ProvisionRowResult struct {
+	Kind   string `json:"kind"`         // users / friendships / groups
+	Row    int    `json:"row"`          // 在对应列表中的序号，从 1 开始（CSV 为去掉表头后的行号）
+	Key    string `json:"key"`          // username / "a,b" / room_account
+	Result string `json:"result"`       // created / updated / unchanged / failed
+	ID     uint64 `json:"id,omitempty"` // 用户 ID / 群房间 ID
+	Error  string `json:"error,omitempty"`
+}
+
+// ProvisionReport 导入报告
+type ProvisionReport struct {
+	DryRun    bool                 `json:"dry_run"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+	Failed    int                  `json:"failed"`
+	Rows      []ProvisionRowResult `json:"rows"`
+}
+
+// provisionRun 一次导入的上下文
+type provisionRun struct {
+	tx     *gorm.DB
+	now    time.Time
+	report *ProvisionReport
+	// ids username -> 用户 ID（含本批次新建的）
+	ids map[string]uint64
+	// joined 提交后需要通知的成员变动：room_id -> 新加入的用户
+	joined  map[uint64][]uint64
+	touched []uint64 // 资料有更新的用户
+	// abort 行内遇到死锁等可重试错误：数据库已回滚整个事务，需要从头重放
+	abort error
+}
+
+// Provision 批量导入用户、好友关系与群成员，dryRun 为 true 时只返回报告不落库
+func (s *ProvisionService) Provision(req ProvisionRequest, dryRun bool) (*ProvisionReport, error) {
+	if len(req.Users)+len(req.Friendships)+len(req.Groups) > ProvisionMaxRows {
+		return nil, ErrProvisionTooMany
+	}
+	var run *provisionRun
+	err := s.Tx(func(tx *gorm.DB) error {
+		run = &provisionRun{
+			tx:     tx,
+			now:    time.Now(),
+			report: &ProvisionReport{DryRun: dryRun, Rows: []ProvisionRowResult{}},
+			ids:    make(map[string]uint64),
+			joined: make(map[uint64][]uint64),
+		}
+		for i, u := range req.Users {
+			run.row(ProvisionKindUsers, i+1, strings.TrimSpace(u.Username), func(tx *gorm.DB) (string, uint64, error) {
+				return run.upsertUser(tx, u)
+			})
+		}
+		for i, f := range req.Friendships {
+			key := strings.TrimSpace(f.User) + "," + strings.TrimSpace(f.Friend)
+			run.row(ProvisionKindFriendships, i+1, key, func(tx *gorm.DB) (string, uint64, error) {
+				return run.upsertFriendship(tx, f)
+			})
+		}
+		for i, g := range req.Groups {
+			run.row(ProvisionKindGroups, i+1, strings.TrimSpace(g.RoomAccount), func(tx *gorm.DB) (string, uint64, error) {
+				return run.upsertGroup(tx, g)
+			})
+		}
+		if run.abort != nil {
+			return run.abort
+		}
+		if dryRun {
+			return errProvisionDryRun
+		}
+		return nil
+	})
+	if dryRun && errors.Is(err, errProvisionDryRun) {
+		return run.report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.Users != nil {
+		for _, uid := range run.touched {
+			s.Users.invalidateUserBrief(uid)
+		}
+	}
+	for roomID, uids := range run.joined {
+		s.roomMembersChanged(roomID, uids, true)
+	}
+	return run.report, nil
+}
+
+// row 在保存点中处理一行，失败时只回滚该行
+func (r *provisionRun) row(kind string, n int, key string, fn func(tx *gorm.DB) (string, uint64, error)) {
+	res := ProvisionRowResult{Kind: kind, Row: n, Key: key}
+	err := r.tx.Transaction(func(tx *gorm.DB) error {
+		var err error
+		res.Result, res.ID, err = fn(tx)
+		return err
+	})
+	if IsRetryableTxError(err) && r.abort == nil {
+		r.abort = err
+	}
+	switch {
+	case err != nil:
+		res.Result, res.ID, res.Error = ProvisionFailed, 0, err.Error()
+		r.report.Failed++
+	case res.Result == ProvisionCreated:
+		r.report.Created++
+	case res.Result == ProvisionUpdated:
+		r.report.Updated++
+	default:
+		r.report.Unchanged++
+	}
+	r.report.Rows = append(r.report.Rows, res)
+}
+
+// userID 按 username 查用户 ID
+func (r *provisionRun) userID(tx *gorm.DB, username string) (uint64, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return 0, ErrUsernameRequired
+	}
+	if id, ok := r.ids[username]; ok {
+		return id, nil
+	}
+	var ids []uint64
+	if err := tx.Model(&models.User{}).Where("username = ?", username).Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, newError(response.CodeParamError, "err.provision_user_not_found", username)
+	}
+	r.ids[username] = ids[0]
+	return ids[0], nil
+}
+
+// userIDs 批量查用户 ID（去重并保持顺序），有不存在的用户时返回错误
+func (r *provisionRun) userIDs(tx *gorm.DB, usernames []string) ([]uint64, error) {
+	out := make([]uint64, 0, len(usernames))
+	seen := make(map[uint64]bool, len(usernames))
+	for _, name := range usernames {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		id, err := r.userID(tx, name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// contactTaken 手机号/邮箱是否已被其他用户占用
+func contactTaken(tx *gorm.DB, userID uint64, phone, email string) error {
+	check := []struct{ col, val, key string }{
+		{"phone", phone, "err.phone_exists"},
+		{"email", email, "err.email_exists"},
+	}
+	for _, c := range check {
+		if c.val == "" {
+			continue
+		}
+		var n int64
+		if err := tx.Model(&models.User{}).Where(c.col+" = ? AND id <> ?", c.val, userID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return newError(response.CodeUserAlreadyExists, c.key, c.val)
+		}
+	}
+	return nil
+}
+
+func (r *provisionRun) upsertUser(tx *gorm.DB, u ProvisionUser) (string, uint64, error) {
+	username := strings.TrimSpace(u.Username)
+	if username == "" {
+		return "", 0, ErrUsernameRequired
+	}
+	if _, ok := r.ids[username]; ok {
+		return "", 0, newError(response.CodeParamError, "err.provision_user_duplicate", username)
+	}
+	nickname := strings.TrimSpace(u.Nickname)
+	phone := strings.TrimSpace(u.Phone)
+	email := normalizeEmail(u.Email)
+	avatar := strings.TrimSpace(u.Avatar)
+	password := strings.TrimSpace(u.Password)
+
+	var user models.User
+	err := tx.Where("username = ?", username).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, err
+	}
+	if err := contactTaken(tx, user.ID, phone, email); err != nil {
+		return "", 0, err
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = models.User{
+			UID:       uuid.New().String(),
+			Username:  username,
+			Nickname:  nickname,
+			Phone:     phone,
+			Email:     email,
+			Avatar:    avatar,
+			CreatedAt: r.now,
+			UpdatedAt: r.now,
+		}
+		if user.Nickname == "" {
+			user.Nickname = username
+		}
+		if password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return "", 0, err
+			}
+			user.Password = string(hash)
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return "", 0, err
+		}
+		r.ids[username] = user.ID
+		return ProvisionCreated, user.ID, nil
+	}
+
+	r.ids[username] = user.ID
+	updates := map[string]any{}
+	for col, v := range map[string][2]string{
+		"nickname": {nickname, user.Nickname},
+		"phone":    {phone, user.Phone},
+		"email":    {email, user.Email},
+		"avatar":   {avatar, user.Avatar},
+	} {
+		if v[0] != "" && v[0] != v[1] {
+			updates[col] = v[0]
+		}
+	}
+	if password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", 0, err
+		}
+		updates["password"] = string(hash)
+	}
+	if len(updates) == 0 {
+		return ProvisionUnchanged, user.ID, nil
+	}
+	updates["updated_at"] = r.now
+	if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return "", 0, err
+	}
+	r.touched = append(r.touched, user.ID)
+	return ProvisionUpdated, user.ID, nil
+}
+
+func (r *provisionRun) upsertFriendship(tx *gorm.DB, f ProvisionFriendship) (string, uint64, error) {
+	a, err := r.userID(tx, f.User)
+	if err != nil {
+		return "", 0, err
+	}
+	b, err := r.userID(tx, f.Friend)
+	if err != nil {
+		return "", 0, err
+	}
+	if a == b {
+		return "", 0, newError(response.CodeParamError, "err.provision_friend_self", strings.TrimSpace(f.User))
+	}
+
+	var rows []models.Friend
+	if err := tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", a, b, b, a).
+		Find(&rows).Error; err != nil {
+		return "", 0, err
+	}
+	exists := make(map[uint64]bool, 2)
+	for _, row := range rows {
+		exists[row.UserID] = true
+	}
+	if exists[a] && exists[b] {
+		return ProvisionUnchanged, 0, nil
+	}
+	for _, p := range [][2]uint64{{a, b}, {b, a}} {
+		if exists[p[0]] {
+			continue
+		}
+		if err := tx.Create(&models.Friend{UserID: p[0], FriendID: p[1], Status: 1, CreatedAt: r.now, UpdatedAt: r.now}).Error; err != nil {
+			return "", 0, err
+		}
+	}
+	roomID, err := ensureFriendRoom(tx, a, b, r.now)
+	if err != nil {
+		return "", 0, err
+	}
+	if roomID != 0 {
+		r.joined[roomID] = []uint64{a, b}
+	}
+	return ProvisionCreated, 0, nil
+}
+
+func (r *provisionRun) upsertGroup(tx *gorm.DB, g ProvisionGroup) (string, uint64, error) {
+	account := strings.TrimSpace(g.RoomAccount)
+	if account == "" {
+		return "", 0, ErrProvisionRoomAccountRequired
+	}
+	name := strings.TrimSpace(g.Name)
+	admins, err := r.userIDs(tx, g.Admins)
+	if err != nil {
+		return "", 0, err
+	}
+	members, err := r.userIDs(tx, append(append([]string{g.Owner}, g.Admins...), g.Members...))
+	if err != nil {
+		return "", 0, err
+	}
+	isAdmin := make(map[uint64]bool, len(admins))
+	for _, id := range admins {
+		isAdmin[id] = true
+	}
+
+	var room models.Room
+	err = tx.Where("room_account = ?", account).First(&room).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, err
+	}
+	result := ProvisionUnchanged
+	existing := map[uint64]uint8{}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if strings.TrimSpace(g.Owner) == "" {
+			return "", 0, newError(response.CodeParamError, "err.provision_group_owner_required", account)
+		}
+		if name == "" {
+			name = account
+		}
+		room = models.Room{
+			RoomAccount: account,
+			Type:        2,
+			Name:        name,
+			CreatorID:   members[0],
+			MemberLimit: max(DefaultRoomMemberLimit, len(members)),
+			CreatedAt:   r.now,
+			UpdatedAt:   r.now,
+		}
+		if err := tx.Create(&room).Error; err != nil {
+			return "", 0, err
+		}
+		result = ProvisionCreated
+	} else {
+		if room.Type != 2 {
+			return "", 0, newError(response.CodeParamError, "err.provision_not_group", account)
+		}
+		var rus []models.RoomUser
+		if err := tx.Select("user_id", "role").Where("room_id = ?", room.ID).Find(&rus).Error; err != nil {
+			return "", 0, err
+		}
+		for _, ru := range rus {
+			existing[ru.UserID] = ru.Role
+		}
+		updates := map[string]any{}
+		if name != "" && name != room.Name {
+			updates["name"] = name
+		}
+		if n := len(existing) + countMissing(existing, members); n > room.MemberLimit {
+			updates["member_limit"] = n
+		}
+		if len(updates) > 0 {
+			updates["updated_at"] = r.now
+			if err := tx.Model(&models.Room{}).Where("id = ?", room.ID).Updates(updates).Error; err != nil {
+				return "", 0, err
+			}
+			result = ProvisionUpdated
+		}
+	}
+
+	var added []uint64
+	for _, uid := range members {
+		role, ok := existing[uid]
+		if ok {
+			if isAdmin[uid] && role == models.RoomRoleMember {
+				if err := tx.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", room.ID, uid).
+					Updates(map[string]any{"role": models.RoomRoleAdmin, "updated_at": r.now}).Error; err != nil {
+					return "", 0, err
+				}
+				result = ProvisionUpdated
+			}
+			continue
+		}
+		ru := &models.RoomUser{RoomID: room.ID, UserID: uid, Role: models.RoomRoleMember, JoinTime: r.now, CreatedAt: r.now, UpdatedAt: r.now}
+		switch {
+		case result == ProvisionCreated && uid == room.CreatorID:
+			ru.Role = models.RoomRoleOwner
+		case isAdmin[uid]:
+			ru.Role = models.RoomRoleAdmin
+		}
+		if err := tx.Create(ru).Error; err != nil {
+			return "", 0, err
+		}
+		conv := &models.Conversation{UserID: uid, RoomID: room.ID}
+		if err := tx.FirstOrCreate(conv, map[string]any{"user_id": uid, "room_id": room.ID}).Error; err != nil {
+			return "", 0, err
+		}
+		if err := tx.Model(&models.Conversation{}).
+			Where("user_id = ? AND room_id = ?", uid, room.ID).
+			Updates(map[string]any{"is_visible": true, "updated_at": r.now}).Error; err != nil {
+			return "", 0, err
+		}
+		added = append(added, uid)
+	}
+	if len(added) > 0 {
+		r.joined[room.ID] = append(r.joined[room.ID], added...)
+		if result == ProvisionUnchanged {
+			result = ProvisionUpdated
+		}
+	}
+	return result, room.ID, nil
+}
+
+func countMissing(existing map[uint64]uint8, ids []uint64) int {
+	n := 0
+	for _, id := range ids {
+		if _, ok := existing[id]; !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Export 导出当前的用户、好友关系与群成员，格式与 Provision 的输入一致（不含密码，不含机器人与访客）
+func (s *ProvisionService) Export() (*ProvisionRequest, error) {
+	var users []models.User
+	if err := s.DB.Select("id", "username", "nickname", "phone", "email", "avatar").
+		Where("is_bot = ? AND is_visitor = ?", false, false).Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	out := &ProvisionRequest{
+		Users:       make([]ProvisionUser, 0, len(users)),
+		Friendships: []ProvisionFriendship{},
+		Groups:      []ProvisionGroup{},
+	}
+	names := make(map[uint64]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+		out.Users = append(out.Users, ProvisionUser{Username: u.Username, Nickname: u.Nickname, Phone: u.Phone, Email: u.Email, Avatar: u.Avatar})
+	}
+
+	var friends []models.Friend
+	if err := s.DB.Select("user_id", "friend_id").Where("status = ?", 1).Order("id").Find(&friends).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[[2]uint64]bool, len(friends))
+	for _, f := range friends {
+		a, b := f.UserID, f.FriendID
+		if a > b {
+			a, b = b, a
+		}
+		if seen[[2]uint64{a, b}] || names[a] == "" || names[b] == "" {
+			continue
+		}
+		seen[[2]uint64{a, b}] = true
+		out.Friendships = append(out.Friendships, ProvisionFriendship{User: names[f.UserID], Friend: names[f.FriendID]})
+	}
+
+	var rooms []models.Room
+	if err := s.DB.Select("id", "room_account", "name").Where("type = ?", 2).Order("id").Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	if len(rooms) == 0 {
+		return out, nil
+	}
+	roomIDs := make([]uint64, 0, len(rooms))
+	for _, r := range rooms {
+		roomIDs = append(roomIDs, r.ID)
+	}
+	var rus []models.RoomUser
+	if err := s.DB.Select("room_id", "user_id", "role").Where("room_id IN ?", roomIDs).Order("id").Find(&rus).Error; err != nil {
+		return nil, err
+	}
+	byRoom := make(map[uint64][]models.RoomUser, len(rooms))
+	for _, ru := range rus {
+		byRoom[ru.RoomID] = append(byRoom[ru.RoomID], ru)
+	}
+	for _, r := range rooms {
+		g := ProvisionGroup{RoomAccount: r.RoomAccount, Name: r.Name}
+		for _, ru := range byRoom[r.ID] {
+			name := names[ru.UserID]
+			if name == "" {
+				continue
+			}
+			switch ru.Role {
+			case models.RoomRoleOwner:
+				g.Owner = name
+			case models.RoomRoleAdmin:
+				g.Admins = append(g.Admins, name)
+			default:
+				g.Members = append(g.Members, name)
+			}
+		}
+		sort.Strings(g.Admins)
+		sort.Strings(g.Members)
+		out.Groups = append(out.Groups, g)
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/response"
+)
+
+func TestProvisionCSVRoundTrip(t *testing.T) {
+	var req ProvisionRequest
+	users := "\ufeffUsername,Nickname,Email\nzhangsan,张三,ZS@corp.com\n,,\nlisi,,\n"
+	if err := ParseProvisionCSV(ProvisionKindUsers, strings.NewReader(users), &req); err != nil {
+		t.Fatalf("parse users: %v", err)
+	}
+	groups := "room_account,name,owner,members\ndept_rd,研发部,lisi, zhangsan | wangwu |\n"
+	if err := ParseProvisionCSV(ProvisionKindGroups, strings.NewReader(groups), &req); err != nil {
+		t.Fatalf("parse groups: %v", err)
+	}
+	if len(req.Users) != 2 || req.Users[0].Nickname != "张三" || req.Users[1].Username != "lisi" {
+		t.Fatalf("unexpected users: %+v", req.Users)
+	}
+	if g := req.Groups[0]; g.Owner != "lisi" || strings.Join(g.Members, ",") != "zhangsan,wangwu" {
+		t.Fatalf("unexpected group: %+v", g)
+	}
+	if err := ParseProvisionCSV(ProvisionKindFriendships, strings.NewReader("user\nzhangsan\n"), &req); !errors.Is(err, ErrProvisionCSVColumn) {
+		t.Fatalf("missing friend column should fail, got %v", err)
+	}
+	err := ParseProvisionCSV(ProvisionKindFriendships, strings.NewReader("user,friend\na,b\nc,\"d\n"), &req)
+	if !errors.Is(err, ErrProvisionCSVInvalid) || ErrorCode(err) != response.CodeParamError || !strings.Contains(err.Error(), "第 3 行") {
+		t.Fatalf("malformed row should report its line, got %v", err)
+	}
+	if err := ParseProvisionCSV("rooms", strings.NewReader(""), &req); !errors.Is(err, ErrProvisionKind) {
+		t.Fatalf("unknown kind: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteProvisionCSV(ProvisionKindGroups, &buf, &req); err != nil {
+		t.Fatal(err)
+	}
+	var back ProvisionRequest
+	if err := ParseProvisionCSV(ProvisionKindGroups, &buf, &back); err != nil || len(back.Groups) != 1 || back.Groups[0].Name != "研发部" || len(back.Groups[0].Members) != 2 {
+		t.Fatalf("round trip: %+v %v", back.Groups, err)
+	}
+}
+
+func TestProvision_DryRunRowErrors(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &ProvisionService{Service: &Service{DB: db}}
+
+	if _, err := s.Provision(ProvisionRequest{Users: make([]ProvisionUser, ProvisionMaxRows+1)}, true); !errors.Is(err, ErrProvisionTooMany) {
+		t.Fatalf("want ErrProvisionTooMany, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	// 第 1 行：新建用户
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT \\* FROM `im_user` WHERE username = \\?").
+		WithArgs("zhangsan", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("INSERT INTO `im_user` ").WillReturnResult(sqlmock.NewResult(7, 1))
+	// 第 2 行：同一批次重复的 username
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	// 好友：zhangsan 命中本批次缓存，ghost 不存在，该行回滚
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT `id` FROM `im_user` WHERE username = \\?").
+		WithArgs("ghost", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	// dry-run 整体回滚
+	mock.ExpectRollback()
+
+	report, err := s.Provision(ProvisionRequest{
+		Users:       []ProvisionUser{{Username: "zhangsan", Nickname: "张三"}, {Username: " zhangsan "}},
+		Friendships: []ProvisionFriendship{{User: "zhangsan", Friend: "ghost"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if !report.DryRun || report.Created != 1 || report.Failed != 2 || len(report.Rows) != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if r := report.Rows[0]; r.Result != ProvisionCreated || r.ID != 7 {
+		t.Fatalf("row 1: %+v", r)
+	}
+	if r := report.Rows[1]; r.Error != newError(response.CodeParamError, "err.provision_user_duplicate", "zhangsan").Error() {
+		t.Fatalf("row 2: %+v", r)
+	}
+	if r := report.Rows[2]; r.Kind != ProvisionKindFriendships || r.Key != "zhangsan,ghost" || !strings.Contains(r.Error, "ghost") {
+		t.Fatalf("row 3: %+v", r)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
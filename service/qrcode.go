@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// RenderQRCode 生成二维码 PNG。
+// - size 为输出边长（像素），会按模块数取整，实际尺寸可能略小于 size；
+// - logo 不为空时贴在中间（约 1/5 边长，带白底），使用最高纠错等级保证仍可识别。
+func RenderQRCode(content string, size int, logo image.Image) ([]byte, error) {
+	if content == "" {
+		return nil, fmt.Errorf("qrcode content is empty")
+	}
+	if size <= 0 {
+		size = 256
+	}
+	level := qrcode.Medium
+	if logo != nil {
+		level = qrcode.Highest
+	}
+	q, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := q.Bitmap() // 已包含 4 模块静区
+	n := len(bitmap)
+	scale := size / n
+	if scale < 1 {
+		scale = 1
+	}
+	side := n * scale
+
+	canvas := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	black := &image.Uniform{C: color.Black}
+	for y, row := range bitmap {
+		for x, on := range row {
+			if on {
+				r := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale)
+				draw.Draw(canvas, r, black, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if logo != nil {
+		ls := side / 5
+		border := ls / 10
+		x0 := (side - ls) / 2
+		bg := image.Rect(x0-border, x0-border, x0+ls+border, x0+ls+border)
+		draw.Draw(canvas, bg, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+		thumb := resizeNearest(logo, ls, ls)
+		draw.Draw(canvas, image.Rect(x0, x0, x0+ls, x0+ls), thumb, image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
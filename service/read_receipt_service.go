@@ -1,53 +1,245 @@
 package service
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/cydxin/chat-sdk/message"
 	"github.com/cydxin/chat-sdk/models"
 )
 
 // ReadReceiptService 用于处理“已读回执”落库：更新 conversation.last_read_msg_id/unread_count。
-// 说明：你希望先写在 client.readList 中延迟落库，本服务用于最终 flush。
+// 说明：已读游标先写在 session.readList 中，由 WS 周期 flush / 断线 flush 最终落库。
 type ReadReceiptService struct {
 	*Service
+	conversationDAO *models.ConversationDAO
 }
 
 func NewReadReceiptService(s *Service) *ReadReceiptService {
-	return &ReadReceiptService{Service: s}
+	return &ReadReceiptService{Service: s, conversationDAO: models.NewConversationDAO(s.DB)}
 }
 
 // FlushUserRead 批量 flush 用户在多个 room 的最后已读 message_id。
-// rooms: room_id -> last_read_msg_id。
-// 行为：
-// - last_read_msg_id 取更大值（避免乱序回执覆盖）。
-// - unread_count 直接置 0（简单策略：表示用户已读到最后）。
+// rooms: room_id -> last_read_msg_id，规则见 ConversationDAO.UpdateLastReadMsgID。
 func (s *ReadReceiptService) FlushUserRead(userID uint64, rooms map[uint64]uint64) error {
 	if userID == 0 || len(rooms) == 0 {
 		return nil
 	}
-
-	now := time.Now()
 	for roomID, lastRead := range rooms {
 		if roomID == 0 || lastRead == 0 {
 			continue
 		}
+		if err := s.conversationDAO.UpdateLastReadMsgID(userID, roomID, lastRead); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiptBatchLimit 单次回执最多处理的消息数（防止一次 read_ack 扫描整个房间历史）
+const receiptBatchLimit = 200
+
+// receiptRow 回执处理时需要的消息字段
+type receiptRow struct {
+	ID       uint64
+	SenderID uint64
+	Status   uint8
+}
+
+// MarkDelivered 记录 userID 已收到房间内的 messageIDs（WS delivery_ack）。
+// 逐条写 message_status.is_delivered，并把仍为“已发送”的消息推进到“已送达”，推送给发送者。
+func (s *ReadReceiptService) MarkDelivered(userID, roomID uint64, messageIDs []uint64) error {
+	if userID == 0 || roomID == 0 || len(messageIDs) == 0 {
+		return nil
+	}
+	if len(messageIDs) > receiptBatchLimit {
+		messageIDs = messageIDs[:receiptBatchLimit]
+	}
+	var rows []receiptRow
+	if err := s.DB.Model(&models.Message{}).
+		Select("id, sender_id, status").
+		Where("room_id = ? AND id IN ? AND sender_id <> ?", roomID, messageIDs, userID).
+		Find(&rows).Error; err != nil {
+		return err
+	}
+	return s.applyReceipts(userID, roomID, rows, false)
+}
+
+// MarkRead 记录 userID 在 roomID 已读到 lastRead（WS read_ack）：
+// 把该用户尚未标记已读的他人消息（最近 receiptBatchLimit 条）逐条标记已读，并推进消息级状态。
+func (s *ReadReceiptService) MarkRead(userID, roomID, lastRead uint64) error {
+	if userID == 0 || roomID == 0 || lastRead == 0 {
+		return nil
+	}
+	readIDs := s.DB.Model(&models.MessageStatus{}).
+		Select("message_id").
+		Where("user_id = ? AND room_id = ? AND is_read = ?", userID, roomID, true)
+	var rows []receiptRow
+	if err := s.DB.Model(&models.Message{}).
+		Select("id, sender_id, status").
+		Where("room_id = ? AND id <= ? AND sender_id <> ? AND is_system = ?", roomID, lastRead, userID, false).
+		Where("status IN ?", []int{models.MessageStatusSent, models.MessageStatusDelivered, models.MessageStatusRead}).
+		Where("id NOT IN (?)", readIDs).
+		Order("id DESC").
+		Limit(receiptBatchLimit).
+		Find(&rows).Error; err != nil {
+		return err
+	}
+	return s.applyReceipts(userID, roomID, rows, true)
+}
 
-		// last_read_msg_id = GREATEST(last_read_msg_id, lastRead)
-		// unread_count = 0
-		// updated_at = now
-
-		err := s.DB.Model(&models.Conversation{}).
-			Where("user_id = ? AND room_id = ?", userID, roomID).
-			Updates(map[string]any{
-				"last_read_msg_id": gorm.Expr("CASE WHEN last_read_msg_id IS NULL OR last_read_msg_id < ? THEN ? ELSE last_read_msg_id END", lastRead, lastRead),
-				"updated_at":       now,
-			}).Error
-		if err != nil {
+func (s *ReadReceiptService) applyReceipts(userID, roomID uint64, rows []receiptRow, read bool) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	now := time.Now()
+	statusRows := make([]models.MessageStatus, 0, len(rows))
+	ids := make([]uint64, 0, len(rows))
+	for _, r := range rows {
+		statusRows = append(statusRows, models.MessageStatus{
+			MessageID:   r.ID,
+			UserID:      userID,
+			RoomID:      roomID,
+			IsRead:      read,
+			ReadAt:      timePtrIf(read, now),
+			IsDelivered: true,
+			DeliveredAt: &now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		ids = append(ids, r.ID)
+	}
+	// 已读隐含送达；已有行（如单删）只补充回执字段，不覆盖更早的时间
+	updates := map[string]any{
+		"is_delivered": true,
+		"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", now),
+		"updated_at":   now,
+	}
+	target := uint8(models.MessageStatusDelivered)
+	if read {
+		updates["is_read"] = true
+		updates["read_at"] = gorm.Expr("COALESCE(read_at, ?)", now)
+		target = models.MessageStatusRead
+	}
+	err := s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}, {Name: "room_id"}},
+			DoUpdates: clause.Assignments(updates),
+		}).Create(&statusRows).Error; err != nil {
 			return err
 		}
+		// 消息级状态只前进，不覆盖撤回/删除
+		return tx.Model(&models.Message{}).
+			Where("id IN ? AND status IN ? AND status < ?", ids, []int{models.MessageStatusSent, models.MessageStatusDelivered}, target).
+			UpdateColumn("status", target).Error
+	})
+	if err != nil {
+		return err
 	}
 
+	// 推送给发送者：只推本次状态真正前进的消息
+	if s.WsNotifier == nil {
+		return nil
+	}
+	bySender := make(map[uint64][]uint64)
+	for _, r := range rows {
+		if r.Status < target && r.Status >= models.MessageStatusSent {
+			bySender[r.SenderID] = append(bySender[r.SenderID], r.ID)
+		}
+	}
+	for senderID, mids := range bySender {
+		s.Emit([]uint64{senderID}, &message.MessageStatusEvent{RoomID: roomID, MessageIDs: mids, Status: target, UserID: userID})
+	}
 	return nil
 }
+
+func timePtrIf(ok bool, t time.Time) *time.Time {
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+// ReceiptUserDTO 回执成员
+type ReceiptUserDTO struct {
+	UserID   uint64     `json:"user_id"`
+	Nickname string     `json:"nickname"`
+	Avatar   string     `json:"avatar"`
+	At       *time.Time `json:"at,omitempty"` // 已读/送达时间
+}
+
+// MessageReceiptDTO 群消息回执详情
+type MessageReceiptDTO struct {
+	MessageID uint64           `json:"message_id"`
+	Status    uint8            `json:"status"` // 消息级状态：1-已发送 2-已送达 3-已读（至少一人）
+	Read      []ReceiptUserDTO `json:"read"`
+	Delivered []ReceiptUserDTO `json:"delivered"` // 已送达未读
+	Unread    []ReceiptUserDTO `json:"unread"`    // 未送达
+}
+
+// GetMessageReceipts 查询某条消息的已读/送达/未读成员，仅发送者可查。
+// 成员为当前房间成员中消息发送前已入群的人（不含发送者）。
+func (s *ReadReceiptService) GetMessageReceipts(messageID, operatorID uint64) (*MessageReceiptDTO, error) {
+	var msg models.Message
+	if err := s.DB.Select("id, room_id, sender_id, status, created_at").Where("id = ?", messageID).First(&msg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if msg.SenderID != operatorID {
+		return nil, ErrPermissionDenied
+	}
+
+	type memberRow struct {
+		UserID   uint64
+		Nickname string
+		Avatar   string
+	}
+	var members []memberRow
+	if err := s.DB.Table(models.RoomUser{}.TableName()+" AS ru").
+		Select("ru.user_id, u.nickname, u.avatar").
+		Joins("JOIN "+models.User{}.TableName()+" AS u ON u.id = ru.user_id").
+		Where("ru.room_id = ? AND ru.user_id <> ? AND ru.join_time <= ?", msg.RoomID, operatorID, msg.CreatedAt).
+		Order("ru.id ASC").
+		Scan(&members).Error; err != nil {
+		return nil, err
+	}
+
+	var statuses []models.MessageStatus
+	if err := s.DB.Select("user_id, is_read, is_delivered, read_at, delivered_at").
+		Where("message_id = ? AND room_id = ?", messageID, msg.RoomID).
+		Find(&statuses).Error; err != nil {
+		return nil, err
+	}
+	byUser := make(map[uint64]models.MessageStatus, len(statuses))
+	for _, st := range statuses {
+		byUser[st.UserID] = st
+	}
+
+	out := &MessageReceiptDTO{
+		MessageID: msg.ID,
+		Status:    msg.Status,
+		Read:      make([]ReceiptUserDTO, 0),
+		Delivered: make([]ReceiptUserDTO, 0),
+		Unread:    make([]ReceiptUserDTO, 0),
+	}
+	for _, m := range members {
+		u := ReceiptUserDTO{UserID: m.UserID, Nickname: m.Nickname, Avatar: m.Avatar}
+		st, ok := byUser[m.UserID]
+		switch {
+		case ok && st.IsRead:
+			u.At = st.ReadAt
+			out.Read = append(out.Read, u)
+		case ok && st.IsDelivered:
+			u.At = st.DeliveredAt
+			out.Delivered = append(out.Delivered, u)
+		default:
+			out.Unread = append(out.Unread, u)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestReadReceiptService_MarkDelivered(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	var pushed []map[string]any
+	base := &Service{DB: gormDB}
+	base.WsNotifier = func(userID uint64, msg []byte) {
+		var m map[string]any
+		_ = json.Unmarshal(msg, &m)
+		m["to"] = float64(userID)
+		pushed = append(pushed, m)
+	}
+	svc := NewReadReceiptService(base)
+
+	mock.ExpectQuery("SELECT id, sender_id, status FROM `im_message` WHERE \\(room_id = \\? AND id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sender_id", "status"}).
+			AddRow(10, 1, models.MessageStatusSent).
+			AddRow(11, 1, models.MessageStatusDelivered))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `im_message_status` .* ON DUPLICATE KEY UPDATE").
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("UPDATE `im_message` SET `status`=\\? WHERE \\(id IN .* AND status IN \\(\\?,\\?\\)").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := svc.MarkDelivered(2, 5, []uint64{10, 11}); err != nil {
+		t.Fatalf("MarkDelivered: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+	// 只有状态真正前进的消息（10）推给发送者
+	if len(pushed) != 1 || pushed[0]["to"] != float64(1) || pushed[0]["type"] != EventMessageStatus {
+		t.Fatalf("pushed=%v", pushed)
+	}
+	if ids := pushed[0]["message_ids"].([]any); len(ids) != 1 || ids[0] != float64(10) {
+		t.Fatalf("message_ids=%v", ids)
+	}
+}
+
+func TestReadReceiptService_GetMessageReceipts(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewReadReceiptService(&Service{DB: gormDB})
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT id, room_id, sender_id, status, created_at FROM `im_message`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "status", "created_at"}).
+			AddRow(10, 5, 1, models.MessageStatusRead, now))
+	mock.ExpectQuery("SELECT ru.user_id, u.nickname, u.avatar FROM im_room_user AS ru JOIN im_user AS u").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "nickname", "avatar"}).
+			AddRow(2, "b", "").AddRow(3, "c", "").AddRow(4, "d", ""))
+	mock.ExpectQuery("SELECT user_id, is_read, is_delivered, read_at, delivered_at FROM `im_message_status`").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "is_read", "is_delivered", "read_at", "delivered_at"}).
+			AddRow(2, true, true, now, now).
+			AddRow(3, false, true, nil, now))
+
+	out, err := svc.GetMessageReceipts(10, 1)
+	if err != nil {
+		t.Fatalf("GetMessageReceipts: %v", err)
+	}
+	if len(out.Read) != 1 || out.Read[0].UserID != 2 || len(out.Delivered) != 1 || out.Delivered[0].UserID != 3 ||
+		len(out.Unread) != 1 || out.Unread[0].UserID != 4 {
+		t.Fatalf("receipts=%+v", out)
+	}
+
+	// 非发送者不能查
+	mock.ExpectQuery("SELECT id, room_id, sender_id, status, created_at FROM `im_message`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "status", "created_at"}).
+			AddRow(10, 5, 1, models.MessageStatusRead, now))
+	if _, err := svc.GetMessageReceipts(10, 2); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("non-sender should be rejected, got %v", err)
+	}
+
+	// 消息不存在
+	mock.ExpectQuery("SELECT id, room_id, sender_id, status, created_at FROM `im_message`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "sender_id", "status", "created_at"}))
+	if _, err := svc.GetMessageReceipts(11, 1); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("missing message: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReadReceiptService_FlushUserRead(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewReadReceiptService(&Service{DB: gormDB})
+
+	// 游标只前进，未读数按游标之后的消息重算
+	mock.ExpectExec("UPDATE `im_conversation` SET `last_read_msg_id`=CASE WHEN last_read_msg_id IS NULL OR last_read_msg_id < \\? THEN \\? ELSE last_read_msg_id END,`unread_count`=\\(SELECT COUNT\\(1\\) FROM im_message m WHERE m.room_id = \\? AND m.id > GREATEST").
+		WithArgs(uint64(20), uint64(20), uint64(5), uint64(20), sqlmock.AnyArg(), uint64(2), uint64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// room_id/last_read 为 0 的条目被忽略
+	if err := svc.FlushUserRead(2, map[uint64]uint64{5: 20, 0: 3, 6: 0}); err != nil {
+		t.Fatalf("FlushUserRead: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
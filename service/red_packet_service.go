@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	redPacketDefaultExpire = 24 * time.Hour
+	redPacketMaxCount      = 200
+	redPacketMaxAmount     = 200 * 100 * 100 // 单个红包总额上限（分）
+)
+
+// 红包错误
+var (
+	ErrRedPacketType        = newError(response.CodeParamError, "err.red_packet_type_invalid")
+	ErrRedPacketCount       = newError(response.CodeParamError, "err.red_packet_count", redPacketMaxCount)
+	ErrRedPacketAmount      = newError(response.CodeParamError, "err.red_packet_amount")
+	ErrRedPacketFixedAmount = newError(response.CodeParamError, "err.red_packet_fixed_amount")
+	ErrRedPacketNotFound    = newError(response.CodeParamError, "err.red_packet_not_found")
+	ErrRedPacketOwn         = newError(response.CodePermissionDeny, "err.red_packet_own")
+	ErrRedPacketClaimed     = newError(response.CodeParamError, "err.red_packet_claimed")
+	ErrRedPacketExpired     = newError(response.CodeParamError, "err.red_packet_expired")
+	ErrRedPacketEmpty       = newError(response.CodeParamError, "err.red_packet_empty")
+)
+
+// RedPacketService 红包：发红包（扣款 + 红包消息）、抢红包（行锁记账 + 入账）、过期退回。
+// 资金操作通过 Wallet 接口交给业务方，SDK 只负责红包本身的记账，bizID 保证重试幂等。
+type RedPacketService struct {
+	*Service
+	messageService *MessageService
+
+	// Wallet 钱包实现（由 engine 注入），为 nil 时红包功能不可用
+	Wallet Wallet
+}
+
+func NewRedPacketService(s *Service) *RedPacketService {
+	log.Println("NewRedPacketService")
+	return &RedPacketService{Service: s, messageService: NewMessageService(s)}
+}
+
+// SendRedPacketReq 发红包参数（金额单位：分）
+type SendRedPacketReq struct {
+	RoomID        uint64 `json:"room_id"`
+	Type          uint8  `json:"type"`         // 1-拼手气 2-普通
+	TotalAmount   int64  `json:"total_amount"` // 总金额（分）
+	TotalCount    int    `json:"total_count"`
+	Greeting      string `json:"greeting"`
+	ExpireMinutes int    `json:"expire_minutes"` // 默认 24 小时
+}
+
+// RedPacketClaimDTO 领取记录
+type RedPacketClaimDTO struct {
+	UserID    uint64    `json:"user_id"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RedPacketDTO 红包详情
+type RedPacketDTO struct {
+	ID           uint64              `json:"id"`
+	RoomID       uint64              `json:"room_id"`
+	SenderID     uint64              `json:"sender_id"`
+	MessageID    uint64              `json:"message_id"`
+	Type         uint8               `json:"type"`
+	TotalAmount  int64               `json:"total_amount"`
+	TotalCount   int                 `json:"total_count"`
+	RemainAmount int64               `json:"remain_amount"`
+	RemainCount  int                 `json:"remain_count"`
+	Greeting     string              `json:"greeting"`
+	Status       uint8               `json:"status"` // 1-可领取 2-已领完 3-已过期退回 4-退回入账中
+	ExpireAt     time.Time           `json:"expire_at"`
+	MyAmount     int64               `json:"my_amount"` // 当前用户领取金额，0 表示未领取
+	Claims       []RedPacketClaimDTO `json:"claims"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// SendRedPacket 发红包：先扣款，再落红包与消息；落库失败会把钱退回。
+func (s *RedPacketService) SendRedPacket(ctx context.Context, senderID uint64, req SendRedPacketReq) (*models.RedPacket, *models.Message, error) {
+	if s.Wallet == nil {
+		return nil, nil, ErrWalletNotConfigured
+	}
+	if req.Type == 0 {
+		req.Type = models.RedPacketTypeRandom
+	}
+	if req.Type != models.RedPacketTypeRandom && req.Type != models.RedPacketTypeFixed {
+		return nil, nil, ErrRedPacketType
+	}
+	if req.TotalCount <= 0 || req.TotalCount > redPacketMaxCount {
+		return nil, nil, ErrRedPacketCount
+	}
+	if req.TotalAmount < int64(req.TotalCount) || req.TotalAmount > redPacketMaxAmount {
+		return nil, nil, ErrRedPacketAmount
+	}
+	if req.Type == models.RedPacketTypeFixed && req.TotalAmount%int64(req.TotalCount) != 0 {
+		return nil, nil, ErrRedPacketFixedAmount
+	}
+	ok, err := s.isMember(req.RoomID, senderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrNotRoomMember
+	}
+	greeting := strings.TrimSpace(req.Greeting)
+	if greeting == "" {
+		greeting = "恭喜发财，大吉大利"
+	}
+	expire := redPacketDefaultExpire
+	if req.ExpireMinutes > 0 {
+		expire = time.Duration(req.ExpireMinutes) * time.Minute
+	}
+
+	bizID := "redpacket:send:" + uuid.New().String()
+	if err := s.Wallet.Debit(ctx, senderID, req.TotalAmount, bizID); err != nil {
+		return nil, nil, err
+	}
+	refund := func() {
+		if err := s.Wallet.Credit(ctx, senderID, req.TotalAmount, bizID+":rollback"); err != nil {
+			log.Printf("red packet rollback credit failed: biz=%s err=%v", bizID, err)
+		}
+	}
+
+	now := time.Now()
+	packet := &models.RedPacket{
+		RoomID:       req.RoomID,
+		SenderID:     senderID,
+		Type:         req.Type,
+		TotalAmount:  req.TotalAmount,
+		TotalCount:   req.TotalCount,
+		RemainAmount: req.TotalAmount,
+		RemainCount:  req.TotalCount,
+		Greeting:     greeting,
+		Status:       models.RedPacketStatusActive,
+		ExpireAt:     now.Add(expire),
+	}
+	if err := s.DB.Create(packet).Error; err != nil {
+		refund()
+		return nil, nil, err
+	}
+	msg, err := s.messageService.SaveMessage(req.RoomID, senderID, greeting, models.MessageTypeRedPacket, message.Extra{
+		RedPacket: &message.RedPacketInfo{RedPacketID: packet.ID, Type: packet.Type, Greeting: greeting},
+	})
+	if err != nil {
+		// 消息发不出去（如被禁言）：红包作废并退款
+		_ = s.DB.Model(&models.RedPacket{}).Where("id = ?", packet.ID).
+			Updates(map[string]any{"status": models.RedPacketStatusRefunded, "remain_amount": 0, "remain_count": 0}).Error
+		refund()
+		return nil, nil, err
+	}
+	_ = s.DB.Model(&models.RedPacket{}).Where("id = ?", packet.ID).Update("message_id", msg.ID).Error
+	packet.MessageID = msg.ID
+	return packet, msg, nil
+}
+
+func (s *RedPacketService) isMember(roomID, userID uint64) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// splitRedPacket 计算本次领取金额。
+// 拼手气使用二倍均值法：每次在 [1, 2*剩余均值) 中随机，并保证后面每个人至少 1 分。
+func splitRedPacket(packetType uint8, remainAmount int64, remainCount int, rnd *rand.Rand) int64 {
+	if remainCount <= 1 {
+		return remainAmount
+	}
+	if packetType == models.RedPacketTypeFixed {
+		return remainAmount / int64(remainCount)
+	}
+	max := remainAmount / int64(remainCount) * 2
+	if limit := remainAmount - int64(remainCount-1); max > limit {
+		max = limit
+	}
+	if max <= 1 {
+		return 1
+	}
+	return rnd.Int63n(max-1) + 1
+}
+
+// ClaimRedPacket 抢红包：行锁内完成记账（领取记录标记为待入账），提交后入账到领取人钱包；
+// 入账失败时领取记录保持待入账，由 RunRefundLoop 中的 RetryPendingCredits 重试。
+func (s *RedPacketService) ClaimRedPacket(ctx context.Context, userID, packetID uint64) (int64, *models.RedPacket, error) {
+	if s.Wallet == nil {
+		return 0, nil, ErrWalletNotConfigured
+	}
+	var packet models.RedPacket
+	var claim models.RedPacketClaim
+	var amount int64
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	err := s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&packet, packetID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRedPacketNotFound
+			}
+			return err
+		}
+		var room models.Room
+		if err := tx.Select("id", "type").First(&room, packet.RoomID).Error; err != nil {
+			return err
+		}
+		// 私聊红包只能对方领取
+		if room.Type == 1 && packet.SenderID == userID {
+			return ErrRedPacketOwn
+		}
+		var cnt int64
+		if err := tx.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", packet.RoomID, userID).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt == 0 {
+			return ErrNotRoomMember
+		}
+		if err := tx.Model(&models.RedPacketClaim{}).Where("red_packet_id = ? AND user_id = ?", packet.ID, userID).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return ErrRedPacketClaimed
+		}
+		switch {
+		case packet.Status == models.RedPacketStatusRefunded || packet.Status == models.RedPacketStatusRefunding || time.Now().After(packet.ExpireAt):
+			return ErrRedPacketExpired
+		case packet.Status == models.RedPacketStatusFinished || packet.RemainCount <= 0:
+			return ErrRedPacketEmpty
+		}
+
+		amount = splitRedPacket(packet.Type, packet.RemainAmount, packet.RemainCount, rnd)
+		claim = models.RedPacketClaim{RedPacketID: packet.ID, UserID: userID, Amount: amount, CreditPending: true}
+		if err := tx.Create(&claim).Error; err != nil {
+			return err
+		}
+		packet.RemainAmount -= amount
+		packet.RemainCount--
+		if packet.RemainCount == 0 {
+			packet.Status = models.RedPacketStatusFinished
+		}
+		return tx.Model(&models.RedPacket{}).Where("id = ?", packet.ID).Updates(map[string]any{
+			"remain_amount": packet.RemainAmount,
+			"remain_count":  packet.RemainCount,
+			"status":        packet.Status,
+			"updated_at":    time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	_ = s.creditClaim(ctx, &claim)
+
+	if s.Notify != nil {
+		if members, err := NewRoomService(s.Service).GetRoomMembers(packet.RoomID); err == nil {
+			_, _ = s.Notify.PublishRoomEvent(packet.RoomID, userID, EventRedPacketClaimed, map[string]interface{}{
+				"red_packet_id": packet.ID,
+				"message_id":    packet.MessageID,
+				"sender_id":     packet.SenderID,
+				"user_id":       userID,
+				"amount":        amount,
+				"remain_count":  packet.RemainCount,
+				"finished":      packet.Status == models.RedPacketStatusFinished,
+			}, members, true)
+		}
+	}
+	return amount, &packet, nil
+}
+
+// GetRedPacket 红包详情（含领取记录）
+func (s *RedPacketService) GetRedPacket(viewerID, packetID uint64) (*RedPacketDTO, error) {
+	var packet models.RedPacket
+	if err := s.DB.First(&packet, packetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRedPacketNotFound
+		}
+		return nil, err
+	}
+	ok, err := s.isMember(packet.RoomID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && packet.SenderID != viewerID {
+		return nil, ErrNotRoomMember
+	}
+	var claims []models.RedPacketClaim
+	if err := s.DB.Where("red_packet_id = ?", packet.ID).Order("id ASC").Find(&claims).Error; err != nil {
+		return nil, err
+	}
+	dto := &RedPacketDTO{
+		ID:           packet.ID,
+		RoomID:       packet.RoomID,
+		SenderID:     packet.SenderID,
+		MessageID:    packet.MessageID,
+		Type:         packet.Type,
+		TotalAmount:  packet.TotalAmount,
+		TotalCount:   packet.TotalCount,
+		RemainAmount: packet.RemainAmount,
+		RemainCount:  packet.RemainCount,
+		Greeting:     packet.Greeting,
+		Status:       packet.Status,
+		ExpireAt:     packet.ExpireAt,
+		Claims:       make([]RedPacketClaimDTO, 0, len(claims)),
+		CreatedAt:    packet.CreatedAt,
+	}
+	for _, c := range claims {
+		if c.UserID == viewerID {
+			dto.MyAmount = c.Amount
+		}
+		dto.Claims = append(dto.Claims, RedPacketClaimDTO{UserID: c.UserID, Amount: c.Amount, CreatedAt: c.CreatedAt})
+	}
+	return dto, nil
+}
+
+// creditClaim 把领取金额入账到领取人钱包，成功后清除待入账标记。
+// bizID 按 红包+用户 固定，钱包侧重试幂等。
+func (s *RedPacketService) creditClaim(ctx context.Context, claim *models.RedPacketClaim) error {
+	bizID := fmt.Sprintf("redpacket:claim:%d:%d", claim.RedPacketID, claim.UserID)
+	if err := s.Wallet.Credit(ctx, claim.UserID, claim.Amount, bizID); err != nil {
+		log.Printf("red packet claim credit failed: biz=%s err=%v", bizID, err)
+		return err
+	}
+	if err := s.DB.Model(&models.RedPacketClaim{}).Where("id = ?", claim.ID).Update("credit_pending", false).Error; err != nil {
+		log.Printf("red packet claim mark credited failed: biz=%s err=%v", bizID, err)
+		return err
+	}
+	claim.CreditPending = false
+	return nil
+}
+
+// RetryPendingCredits 重试入账失败的领取记录，返回本次入账成功的数量。
+func (s *RedPacketService) RetryPendingCredits(ctx context.Context) (int, error) {
+	if s.Wallet == nil {
+		return 0, ErrWalletNotConfigured
+	}
+	var claims []models.RedPacketClaim
+	if err := s.DB.Where("credit_pending = ?", true).Order("id ASC").Limit(100).Find(&claims).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := range claims {
+		if s.creditClaim(ctx, &claims[i]) == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// RefundExpired 退回已过期红包的剩余金额，返回处理数量。
+// 行锁内先把红包置为退回入账中，入账成功后才置为已退回；入账失败的红包下一轮继续重试。
+func (s *RedPacketService) RefundExpired(ctx context.Context) (int, error) {
+	if s.Wallet == nil {
+		return 0, ErrWalletNotConfigured
+	}
+	var ids []uint64
+	if err := s.DB.Model(&models.RedPacket{}).
+		Where("(status = ? AND expire_at < ?) OR status = ?", models.RedPacketStatusActive, time.Now(), models.RedPacketStatusRefunding).
+		Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		var packet models.RedPacket
+		err := s.Tx(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&packet, id).Error; err != nil {
+				return err
+			}
+			if packet.Status != models.RedPacketStatusActive {
+				return nil
+			}
+			packet.Status = models.RedPacketStatusRefunding
+			return tx.Model(&models.RedPacket{}).Where("id = ?", id).Updates(map[string]any{
+				"status":     models.RedPacketStatusRefunding,
+				"updated_at": time.Now(),
+			}).Error
+		})
+		if err != nil {
+			log.Printf("red packet refund failed: id=%d err=%v", id, err)
+			continue
+		}
+		if packet.Status != models.RedPacketStatusRefunding {
+			continue
+		}
+		refundAmount := packet.RemainAmount
+		if refundAmount > 0 {
+			bizID := fmt.Sprintf("redpacket:refund:%d", id)
+			if err := s.Wallet.Credit(ctx, packet.SenderID, refundAmount, bizID); err != nil {
+				log.Printf("red packet refund credit failed: biz=%s err=%v", bizID, err)
+				continue
+			}
+		}
+		if err := s.DB.Model(&models.RedPacket{}).
+			Where("id = ? AND status = ?", id, models.RedPacketStatusRefunding).
+			Updates(map[string]any{
+				"status":        models.RedPacketStatusRefunded,
+				"remain_amount": 0,
+				"updated_at":    time.Now(),
+			}).Error; err != nil {
+			log.Printf("red packet mark refunded failed: id=%d err=%v", id, err)
+			continue
+		}
+		if refundAmount <= 0 {
+			continue
+		}
+		n++
+		if s.Notify != nil {
+			_, _ = s.Notify.PublishRoomEvent(packet.RoomID, packet.SenderID, EventRedPacketRefunded, map[string]interface{}{
+				"red_packet_id": packet.ID,
+				"amount":        refundAmount,
+			}, nil, true)
+		}
+	}
+	return n, nil
+}
+
+// RunRefundLoop 定时退回过期红包、重试入账失败的领取（阻塞，engine 中以 goroutine 启动）
+func (s *RedPacketService) RunRefundLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.RefundExpired(context.Background()); err != nil {
+			log.Printf("red packet refund loop: %v", err)
+		}
+		if _, err := s.RetryPendingCredits(context.Background()); err != nil {
+			log.Printf("red packet credit retry: %v", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSplitRedPacket_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for round := 0; round < 200; round++ {
+		total := int64(rnd.Intn(10000) + 50)
+		count := rnd.Intn(50) + 1
+		if int64(count) > total {
+			count = int(total)
+		}
+		remain, left := total, count
+		var sum int64
+		for left > 0 {
+			amt := splitRedPacket(models.RedPacketTypeRandom, remain, left, rnd)
+			if amt < 1 {
+				t.Fatalf("amount must be >= 1, got %d", amt)
+			}
+			if remain-amt < int64(left-1) {
+				t.Fatalf("not enough left for remaining %d: remain=%d amt=%d", left-1, remain, amt)
+			}
+			remain -= amt
+			left--
+			sum += amt
+		}
+		if sum != total || remain != 0 {
+			t.Fatalf("sum=%d total=%d remain=%d", sum, total, remain)
+		}
+	}
+}
+
+func TestSplitRedPacket_Fixed(t *testing.T) {
+	remain, left := int64(1000), 4
+	for left > 0 {
+		amt := splitRedPacket(models.RedPacketTypeFixed, remain, left, nil)
+		if amt != 250 {
+			t.Fatalf("expected 250, got %d", amt)
+		}
+		remain -= amt
+		left--
+	}
+}
+
+// flakyWallet 可控制入账失败的钱包，按 bizID 幂等记账
+type flakyWallet struct {
+	failCredit bool
+	credits    map[string]int64
+}
+
+func (w *flakyWallet) Debit(ctx context.Context, userID uint64, amount int64, bizID string) error {
+	return nil
+}
+
+func (w *flakyWallet) Credit(ctx context.Context, userID uint64, amount int64, bizID string) error {
+	if w.failCredit {
+		return errors.New("wallet unavailable")
+	}
+	w.credits[bizID] = amount
+	return nil
+}
+
+func TestRedPacketService_CreditRetry(t *testing.T) {
+	dsn := fmt.Sprintf("file:red_packet_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.RedPacket{}, &models.RedPacketClaim{}, &models.Room{}, &models.RoomUser{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	room := models.Room{Type: 2, RoomAccount: "g1", Name: "群"}
+	if err := db.Create(&room).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	if err := db.Create(&models.RoomUser{RoomID: room.ID, UserID: 2}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+	live := models.RedPacket{RoomID: room.ID, SenderID: 1, Type: models.RedPacketTypeFixed, TotalAmount: 300, TotalCount: 3,
+		RemainAmount: 300, RemainCount: 3, Status: models.RedPacketStatusActive, ExpireAt: time.Now().Add(time.Hour)}
+	expired := models.RedPacket{RoomID: room.ID, SenderID: 1, Type: models.RedPacketTypeFixed, TotalAmount: 200, TotalCount: 2,
+		RemainAmount: 200, RemainCount: 2, Status: models.RedPacketStatusActive, ExpireAt: time.Now().Add(-time.Minute)}
+	for _, p := range []*models.RedPacket{&live, &expired} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatalf("create packet: %v", err)
+		}
+	}
+
+	wallet := &flakyWallet{failCredit: true, credits: map[string]int64{}}
+	s := NewRedPacketService(&Service{DB: db})
+	s.Wallet = wallet
+	ctx := context.Background()
+
+	// 入账失败：领取已记账但保持待入账，过期红包停在退回入账中
+	amount, _, err := s.ClaimRedPacket(ctx, 2, live.ID)
+	if err != nil || amount != 100 {
+		t.Fatalf("claim: amount=%d err=%v", amount, err)
+	}
+	var claim models.RedPacketClaim
+	if err := db.Where("red_packet_id = ? AND user_id = ?", live.ID, 2).First(&claim).Error; err != nil || !claim.CreditPending {
+		t.Fatalf("claim should be credit pending: %+v err=%v", claim, err)
+	}
+	if n, err := s.RefundExpired(ctx); err != nil || n != 0 {
+		t.Fatalf("refund with failing wallet: n=%d err=%v", n, err)
+	}
+	var got models.RedPacket
+	db.First(&got, expired.ID)
+	if got.Status != models.RedPacketStatusRefunding || got.RemainAmount != 200 {
+		t.Fatalf("expired packet should be refunding with amount kept: %+v", got)
+	}
+
+	// 钱包恢复：重试后入账并完成状态
+	wallet.failCredit = false
+	if n, err := s.RetryPendingCredits(ctx); err != nil || n != 1 {
+		t.Fatalf("retry credits: n=%d err=%v", n, err)
+	}
+	db.First(&claim, claim.ID)
+	if claim.CreditPending || wallet.credits[fmt.Sprintf("redpacket:claim:%d:2", live.ID)] != 100 {
+		t.Fatalf("claim not credited: %+v credits=%v", claim, wallet.credits)
+	}
+	if n, err := s.RefundExpired(ctx); err != nil || n != 1 {
+		t.Fatalf("refund retry: n=%d err=%v", n, err)
+	}
+	db.First(&got, expired.ID)
+	if got.Status != models.RedPacketStatusRefunded || got.RemainAmount != 0 {
+		t.Fatalf("expired packet should be refunded: %+v", got)
+	}
+	if wallet.credits[fmt.Sprintf("redpacket:refund:%d", expired.ID)] != 200 {
+		t.Fatalf("refund not credited: %v", wallet.credits)
+	}
+	if n, _ := s.RetryPendingCredits(ctx); n != 0 {
+		t.Fatalf("nothing left to retry, got %d", n)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/datatypes"
+)
+
+// 没有对应房间事件的审计动作
+const (
+	AuditRoomJoinVerification = "room.join.verification" // 入群验证方式变更
+	AuditRoomDiscovery        = "room.discovery"         // 公开/分类/标签变更
+)
+
+// RoomAuditMaxLimit 审计日志单页最大条数
+const RoomAuditMaxLimit = 200
+
+// auditRoom 记录一次群设置变更（尽力而为：写入失败只打日志，不影响变更本身）。
+// before/after 为变更涉及字段的前后值，通常 before 取自 roomAuditSnapshot / memberAuditSnapshot，after 为实际写入的字段。
+func (s *Service) auditRoom(roomID, actorID uint64, action string, targetID uint64, before, after any) {
+	row := models.RoomAuditLog{
+		RoomID:    roomID,
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		Before:    auditJSON(before),
+		After:     auditJSON(after),
+		CreatedAt: time.Now(),
+	}
+	if err := s.DB.Create(&row).Error; err != nil {
+		log.Printf("room audit room=%d action=%s: %v", roomID, action, err)
+	}
+}
+
+func auditJSON(v any) datatypes.JSON {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// roomAuditSnapshot 读取群的指定字段作为变更前的值，读取失败返回 nil
+func (s *Service) roomAuditSnapshot(roomID uint64, columns ...string) map[string]any {
+	m := map[string]any{}
+	if err := s.DB.Model(&models.Room{}).Select(columns).Where("id = ?", roomID).Take(&m).Error; err != nil {
+		return nil
+	}
+	return m
+}
+
+// memberAuditSnapshot 读取成员的指定字段作为变更前的值，读取失败返回 nil
+func (s *Service) memberAuditSnapshot(roomID, userID uint64, columns ...string) map[string]any {
+	m := map[string]any{}
+	if err := s.DB.Model(&models.RoomUser{}).Select(columns).Where("room_id = ? AND user_id = ?", roomID, userID).Take(&m).Error; err != nil {
+		return nil
+	}
+	return m
+}
+
+// RoomAuditQuery 审计日志查询条件，按 id 倒序（新的在前）游标翻页
+type RoomAuditQuery struct {
+	RoomID uint64
+	Action string // 为空表示全部
+	Cursor uint64 // 上一页的 next_cursor，0 表示第一页
+	Limit  int    // 默认 50，最大 RoomAuditMaxLimit
+}
+
+// RoomAuditDTO 一条群设置变更记录
+type RoomAuditDTO struct {
+	ID        uint64         `json:"id"`
+	ActorID   uint64         `json:"actor_id"` // 0 为系统
+	Action    string         `json:"action"`
+	TargetID  uint64         `json:"target_id,omitempty"`
+	Before    datatypes.JSON `json:"before,omitempty" swaggertype:"object"`
+	After     datatypes.JSON `json:"after,omitempty" swaggertype:"object"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// RoomAuditPage 一页审计日志，NextCursor 为 0 表示没有更多
+type RoomAuditPage struct {
+	Records    []RoomAuditDTO `json:"records"`
+	NextCursor uint64         `json:"next_cursor"`
+}
+
+// ListRoomAudit 群设置变更记录（群主/管理员）
+func (s *RoomService) ListRoomAudit(operatorID uint64, q RoomAuditQuery) (*RoomAuditPage, error) {
+	role, err := s.getMemberRole(q.RoomID, operatorID)
+	if err != nil || role < models.RoomRoleAdmin {
+		return nil, ErrPermissionDenied
+	}
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	q.Limit = min(q.Limit, RoomAuditMaxLimit)
+
+	db := s.DB.Model(&models.RoomAuditLog{}).Where("room_id = ?", q.RoomID)
+	if q.Cursor != 0 {
+		db = db.Where("id < ?", q.Cursor)
+	}
+	if q.Action != "" {
+		db = db.Where("action = ?", q.Action)
+	}
+	var rows []models.RoomAuditLog
+	if err := db.Order("id DESC").Limit(q.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	page := &RoomAuditPage{Records: make([]RoomAuditDTO, 0, len(rows))}
+	if len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+		page.NextCursor = rows[len(rows)-1].ID
+	}
+	for _, r := range rows {
+		page.Records = append(page.Records, RoomAuditDTO{
+			ID: r.ID, ActorID: r.ActorID, Action: r.Action, TargetID: r.TargetID,
+			Before: r.Before, After: r.After, CreatedAt: r.CreatedAt,
+		})
+	}
+	return page, nil
+}
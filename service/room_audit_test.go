@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRoomService_SetGroupMuteAdmins_Audit(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &RoomService{Service: &Service{DB: db, TablePrefix: "im_"}}
+
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(5, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(2))
+	mock.ExpectQuery("SELECT `mute_admins` FROM `im_room` WHERE id = \\? AND `im_room`.`deleted_at` IS NULL LIMIT \\?").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"mute_admins"}).AddRow(false))
+	mock.ExpectExec("UPDATE `im_room` SET `mute_admins`=\\?").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `im_room_audit_log`").
+		WithArgs(5, 1, EventRoomMuteAdmins, 0, `{"mute_admins":false}`, `{"mute_admins":true}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := s.SetGroupMuteAdmins(1, 5, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoomService_ListRoomAudit(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &RoomService{Service: &Service{DB: db, TablePrefix: "im_"}}
+
+	// 普通成员无权查看
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(0))
+	if _, err := s.ListRoomAudit(3, RoomAuditQuery{RoomID: 5}); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("want ErrPermissionDenied, got %v", err)
+	}
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(1))
+	mock.ExpectQuery("SELECT \\* FROM `im_room_audit_log` WHERE room_id = \\? AND id < \\? AND action = \\? ORDER BY id DESC LIMIT \\?").
+		WithArgs(5, 100, EventRoomUserMute, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "actor_id", "action", "target_id", "before", "after", "created_at"}).
+			AddRow(90, 5, 1, EventRoomUserMute, 3, `{"is_muted":false}`, `{"is_muted":true}`, now).
+			AddRow(80, 5, 1, EventRoomUserMute, 4, nil, nil, now).
+			AddRow(70, 5, 1, EventRoomUserMute, 3, nil, nil, now))
+
+	page, err := s.ListRoomAudit(1, RoomAuditQuery{RoomID: 5, Action: EventRoomUserMute, Cursor: 100, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Records) != 2 || page.NextCursor != 80 || page.Records[0].TargetID != 3 || string(page.Records[0].After) != `{"is_muted":true}` {
+		t.Fatalf("page = %+v", page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
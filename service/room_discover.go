@@ -0,0 +1,189 @@
+package service
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+)
+
+// 群标签限制
+const (
+	RoomTagLimit  = 10 // 每个群最多标签数
+	RoomTagMaxLen = 20 // 单个标签最大字符数
+)
+
+// ErrRoomTags 标签数量或长度超限
+var ErrRoomTags = newError(response.CodeParamError, "err.room_tags_invalid")
+
+// DiscoverGroupDTO 公开群目录项
+type DiscoverGroupDTO struct {
+	ID          uint64   `json:"id"`
+	RoomAccount string   `json:"room_account"`
+	Name        string   `json:"name"`
+	Avatar      string   `json:"avatar"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	MemberCount int      `json:"member_count"`
+	MemberLimit int      `json:"member_limit"`
+	JoinMode    uint8    `json:"join_mode"` // 见 models.RoomJoin*
+}
+
+// DiscoverQuery 公开群搜索条件（均可选，Keyword 匹配群名或精确群号）
+type DiscoverQuery struct {
+	Keyword  string
+	Category string
+	Tag      string
+	Limit    int
+	Offset   int
+}
+
+// normalizeRoomTags 去空白、转小写、去重，超出数量/长度返回 ErrRoomTags
+func normalizeRoomTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if utf8.RuneCountInString(t) > RoomTagMaxLen {
+			return nil, ErrRoomTags
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	if len(out) > RoomTagLimit {
+		return nil, ErrRoomTags
+	}
+	return out, nil
+}
+
+// SetRoomDiscovery 设置群是否公开可搜索、分类与标签（群主/管理员），标签整体覆盖
+func (s *RoomService) SetRoomDiscovery(operatorID, roomID uint64, isPublic bool, category string, tags []string) error {
+	tags, err := normalizeRoomTags(tags)
+	if err != nil {
+		return err
+	}
+	category = strings.TrimSpace(category)
+	if utf8.RuneCountInString(category) > 50 {
+		return newError(response.CodeParamError, "valid.max_len", "category", "50")
+	}
+	role, err := s.getMemberRole(roomID, operatorID)
+	if err != nil || role < 1 {
+		return ErrPermissionDenied
+	}
+	before := s.roomAuditSnapshot(roomID, "is_public", "category")
+	if before != nil {
+		oldTags, _ := s.roomTags([]uint64{roomID})
+		before["tags"] = oldTags[roomID]
+	}
+	err = s.Tx(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Room{}).Where("id = ? AND type = ?", roomID, 2).
+			Updates(map[string]any{"is_public": isPublic, "category": category, "updated_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrGroupNotFound
+		}
+		if err := tx.Where("room_id = ?", roomID).Delete(&models.RoomTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		rows := make([]models.RoomTag, 0, len(tags))
+		for _, t := range tags {
+			rows = append(rows, models.RoomTag{RoomID: roomID, Tag: t})
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.auditRoom(roomID, operatorID, AuditRoomDiscovery, 0, before, map[string]any{"is_public": isPublic, "category": category, "tags": tags})
+	return nil
+}
+
+// roomTags 批量查询群标签
+func (s *RoomService) roomTags(roomIDs []uint64) (map[uint64][]string, error) {
+	out := make(map[uint64][]string, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return out, nil
+	}
+	var rows []models.RoomTag
+	if err := s.DB.Where("room_id IN ?", roomIDs).Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.RoomID] = append(out[r.RoomID], r.Tag)
+	}
+	return out, nil
+}
+
+// DiscoverGroups 搜索公开群（按群名/群号、分类、标签），按创建时间倒序分页
+func (s *RoomService) DiscoverGroups(q DiscoverQuery) ([]DiscoverGroupDTO, error) {
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > 100 {
+		q.Limit = 100
+	}
+	rdb := s.ReadDB(ReadScopeSearch)
+	db := rdb.Model(&models.Room{}).Where("type = ? AND is_public = ?", 2, true)
+	if kw := strings.TrimSpace(q.Keyword); kw != "" {
+		db = db.Where("name LIKE ? OR room_account = ?", "%"+kw+"%", kw)
+	}
+	if c := strings.TrimSpace(q.Category); c != "" {
+		db = db.Where("category = ?", c)
+	}
+	if t := strings.ToLower(strings.TrimSpace(q.Tag)); t != "" {
+		db = db.Where("id IN (?)", rdb.Model(&models.RoomTag{}).Select("room_id").Where("tag = ?", t))
+	}
+	var rooms []models.Room
+	if err := db.Select("id, room_account, name, avatar, description, category, member_limit, join_mode").
+		Order("id DESC").
+		Limit(q.Limit).
+		Offset(q.Offset).
+		Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, 0, len(rooms))
+	for _, r := range rooms {
+		ids = append(ids, r.ID)
+	}
+	counts, err := countRoomMembers(rdb, ids)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.roomTags(ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DiscoverGroupDTO, 0, len(rooms))
+	for i := range rooms {
+		r := &rooms[i]
+		out = append(out, DiscoverGroupDTO{
+			ID:          r.ID,
+			RoomAccount: r.RoomAccount,
+			Name:        r.Name,
+			Avatar:      r.Avatar,
+			Description: r.Description,
+			Category:    r.Category,
+			Tags:        append([]string{}, tags[r.ID]...),
+			MemberCount: counts[r.ID],
+			MemberLimit: roomMemberLimit(r),
+			JoinMode:    r.JoinMode,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNormalizeRoomTags(t *testing.T) {
+	got, err := normalizeRoomTags([]string{" Go ", "go", "", "后端"})
+	if err != nil || len(got) != 2 || got[0] != "go" || got[1] != "后端" {
+		t.Fatalf("got %v %v", got, err)
+	}
+	if _, err := normalizeRoomTags([]string{strings.Repeat("长", RoomTagMaxLen+1)}); !errors.Is(err, ErrRoomTags) {
+		t.Fatalf("long tag: %v", err)
+	}
+	many := make([]string, 0, RoomTagLimit+1)
+	for i := 0; i <= RoomTagLimit; i++ {
+		many = append(many, string(rune('a'+i)))
+	}
+	if _, err := normalizeRoomTags(many); !errors.Is(err, ErrRoomTags) {
+		t.Fatalf("too many tags: %v", err)
+	}
+}
+
+func TestRoomService_DiscoverGroups(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := NewRoomService(&Service{DB: db, TablePrefix: "im_"})
+
+	mock.ExpectQuery("SELECT id, room_account, name, avatar, description, category, member_limit, join_mode FROM `im_room` "+
+		"WHERE \\(type = \\? AND is_public = \\?\\) AND \\(name LIKE \\? OR room_account = \\?\\) AND id IN \\(SELECT `room_id` FROM `im_room_tag` WHERE tag = \\?\\) "+
+		"AND `im_room`.`deleted_at` IS NULL ORDER BY id DESC LIMIT \\?").
+		WithArgs(2, true, "%go%", "go", "backend", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_account", "name", "category", "join_mode"}).
+			AddRow(7, "g7", "Go 爱好者", "tech", 1))
+	mock.ExpectQuery("SELECT room_id, COUNT\\(1\\) AS cnt FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"room_id", "cnt"}).AddRow(7, 12))
+	mock.ExpectQuery("SELECT \\* FROM `im_room_tag` WHERE room_id IN \\(\\?\\) ORDER BY id ASC").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "tag"}).AddRow(1, 7, "backend").AddRow(2, 7, "go"))
+
+	list, err := s.DiscoverGroups(DiscoverQuery{Keyword: " go ", Tag: "Backend"})
+	if err != nil {
+		t.Fatalf("DiscoverGroups: %v", err)
+	}
+	if len(list) != 1 || list[0].MemberCount != 12 || len(list[0].Tags) != 2 || list[0].JoinMode != 1 {
+		t.Fatalf("list=%+v", list)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
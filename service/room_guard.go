@@ -0,0 +1,27 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+)
+
+// ErrNotRoomMember 调用方不是该房间成员
+var ErrNotRoomMember = newError(response.CodePermissionDeny, "err.not_room_member")
+
+// RequireRole 校验 userID 是 roomID 的成员且角色不低于 minRole（models.RoomRole*），返回其角色。
+// 非成员返回 ErrNotRoomMember，角色不足返回 ErrPermissionDenied；供 RoomMemberGuard 及各服务复用。
+func (s *RoomService) RequireRole(roomID, userID uint64, minRole uint8) (uint8, error) {
+	role, err := s.getMemberRole(roomID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrNotRoomMember
+		}
+		return 0, err
+	}
+	if uint8(role) < minRole {
+		return uint8(role), ErrPermissionDenied
+	}
+	return uint8(role), nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestRoomService_RequireRole(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := NewRoomService(&Service{DB: db, TablePrefix: "im_"})
+
+	// 非成员
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(5, 9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}))
+	if _, err := s.RequireRole(5, 9, models.RoomRoleMember); !errors.Is(err, ErrNotRoomMember) {
+		t.Fatalf("want ErrNotRoomMember, got %v", err)
+	}
+
+	// 普通成员访问管理员接口
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(0))
+	if role, err := s.RequireRole(5, 1, models.RoomRoleAdmin); !errors.Is(err, ErrPermissionDenied) || role != models.RoomRoleMember {
+		t.Fatalf("want ErrPermissionDenied, got role=%d err=%v", role, err)
+	}
+
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(2))
+	if role, err := s.RequireRole(5, 2, models.RoomRoleAdmin); err != nil || role != models.RoomRoleOwner {
+		t.Fatalf("owner: role=%d err=%v", role, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrGroupNotFound        = newError(response.CodeParamError, "err.group_not_found")
+	ErrJoinMode             = newError(response.CodeParamError, "err.join_mode_invalid")
+	ErrJoinDisabled         = newError(response.CodePermissionDeny, "err.join_disabled")
+	ErrJoinAnswerWrong      = newError(response.CodeParamError, "err.join_answer_wrong")
+	ErrJoinQuestionRequired = newError(response.CodeParamError, "err.join_question_required")
+	ErrJoinRequestHandled   = newError(response.CodeParamError, "err.join_request_handled")
+)
+
+// 按群号加群的结果
+const (
+	JoinStatusJoined        = "joined"         // 已加入
+	JoinStatusAlreadyMember = "already_member" // 已经是成员
+	JoinStatusPending       = "pending"        // 已提交申请，等待管理员审批
+)
+
+// GroupJoinInfoDTO 按群号查到的群（加群前展示，不含答案）
+type GroupJoinInfoDTO struct {
+	ID           uint64 `json:"id"`
+	RoomAccount  string `json:"room_account"`
+	Name         string `json:"name"`
+	Avatar       string `json:"avatar"`
+	MemberCount  int    `json:"member_count"`
+	MemberLimit  int    `json:"member_limit"`
+	JoinMode     uint8  `json:"join_mode"` // 0-需审批 1-直接加入 2-回答问题 3-不允许
+	JoinQuestion string `json:"join_question,omitempty"`
+	IsMember     bool   `json:"is_member"`
+}
+
+// JoinGroupResult 加群结果
+type JoinGroupResult struct {
+	RoomID    uint64 `json:"room_id"`
+	Status    string `json:"status"` // 见 JoinStatus* 常量
+	RequestID uint64 `json:"request_id,omitempty"`
+	// SystemMessage "X 加入了群聊" 系统消息（加入成功时才有），由调用方推送
+	SystemMessage *models.Message `json:"-"`
+}
+
+// JoinRequestDTO 待处理的加群申请
+type JoinRequestDTO struct {
+	ID        uint64     `json:"id"`
+	RoomID    uint64     `json:"room_id"`
+	UserID    uint64     `json:"user_id"`
+	Nickname  string     `json:"nickname"`
+	Avatar    string     `json:"avatar"`
+	Reason    string     `json:"reason"`
+	Status    uint8      `json:"status"`
+	HandlerID uint64     `json:"handler_id,omitempty"`
+	HandledAt *time.Time `json:"handled_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// findGroupByAccount 按群号查群（只查群聊）
+func (s *MemberService) findGroupByAccount(account string) (*models.Room, error) {
+	account = strings.TrimSpace(account)
+	if account == "" {
+		return nil, ErrGroupNotFound
+	}
+	var room models.Room
+	err := s.DB.Where("room_account = ? AND type = ?", account, 2).First(&room).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// roomAdminIDs 群主和管理员
+func (s *MemberService) roomAdminIDs(roomID uint64) []uint64 {
+	var ids []uint64
+	_ = s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND role >= ?", roomID, 1).Pluck("user_id", &ids).Error
+	return ids
+}
+
+// isGroupAdmin 操作者是否为群主/管理员
+func (s *MemberService) isGroupAdmin(roomID, userID uint64) bool {
+	var n int64
+	s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ? AND role >= ?", roomID, userID, 1).Count(&n)
+	return n > 0
+}
+
+// LookupGroupByAccount 按群号查询群资料与加群方式
+func (s *MemberService) LookupGroupByAccount(userID uint64, account string) (*GroupJoinInfoDTO, error) {
+	room, err := s.findGroupByAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := countRoomMembers(s.DB, []uint64{room.ID})
+	if err != nil {
+		return nil, err
+	}
+	var n int64
+	if err := s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", room.ID, userID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	dto := &GroupJoinInfoDTO{
+		ID:          room.ID,
+		RoomAccount: room.RoomAccount,
+		Name:        room.Name,
+		Avatar:      room.Avatar,
+		MemberCount: counts[room.ID],
+		MemberLimit: roomMemberLimit(room),
+		JoinMode:    room.JoinMode,
+		IsMember:    n > 0,
+	}
+	if room.JoinMode == models.RoomJoinQuestion {
+		dto.JoinQuestion = room.JoinQuestion
+	}
+	return dto, nil
+}
+
+// SetJoinVerification 设置按群号加群的验证方式（群主/管理员）。
+// mode=回答问题 时 question/answer 必填，答案比较忽略大小写与首尾空格。
+func (s *MemberService) SetJoinVerification(operatorID, roomID uint64, mode uint8, question, answer string) error {
+	if mode > models.RoomJoinDisabled {
+		return ErrJoinMode
+	}
+	question, answer = strings.TrimSpace(question), strings.TrimSpace(answer)
+	if mode == models.RoomJoinQuestion && (question == "" || answer == "") {
+		return ErrJoinQuestionRequired
+	}
+	if !s.isGroupAdmin(roomID, operatorID) {
+		return ErrPermissionDenied
+	}
+	updates := map[string]any{"join_mode": mode, "updated_at": time.Now()}
+	if mode == models.RoomJoinQuestion {
+		updates["join_question"] = question
+		updates["join_answer"] = answer
+	}
+	before := s.roomAuditSnapshot(roomID, "join_mode", "join_question")
+	res := s.DB.Model(&models.Room{}).Where("id = ? AND type = ?", roomID, 2).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrGroupNotFound
+	}
+	// 答案不进审计日志
+	after := map[string]any{"join_mode": mode}
+	if mode == models.RoomJoinQuestion {
+		after["join_question"] = question
+	}
+	s.auditRoom(roomID, operatorID, AuditRoomJoinVerification, 0, before, after)
+	return nil
+}
+
+// JoinGroupByAccount 按群号加群：
+//   - 直接加入：立即入群
+//   - 回答问题：答对入群，答错通知管理员并返回 ErrJoinAnswerWrong
+//   - 需审批：创建/更新待处理申请并通知管理员
+//   - 不允许：返回 ErrJoinDisabled
+func (s *MemberService) JoinGroupByAccount(userID uint64, account, answer, reason string) (*JoinGroupResult, error) {
+	if userID == 0 {
+		return nil, errors.New("user_id is required")
+	}
+	room, err := s.findGroupByAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	res := &JoinGroupResult{RoomID: room.ID}
+	var n int64
+	if err := s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", room.ID, userID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		res.Status = JoinStatusAlreadyMember
+		return res, nil
+	}
+
+	switch room.JoinMode {
+	case models.RoomJoinFree:
+	case models.RoomJoinQuestion:
+		if !strings.EqualFold(strings.TrimSpace(answer), room.JoinAnswer) {
+			if s.Notify != nil {
+				_, _ = s.Notify.PublishRoomEvent(room.ID, userID, EventRoomJoinFailed,
+					map[string]any{"user_id": userID, "answer": answer}, s.roomAdminIDs(room.ID), false)
+			}
+			return nil, ErrJoinAnswerWrong
+		}
+	case models.RoomJoinDisabled:
+		return nil, ErrJoinDisabled
+	default:
+		req, err := s.upsertJoinRequest(room.ID, userID, strings.TrimSpace(reason))
+		if err != nil {
+			return nil, err
+		}
+		if s.Notify != nil {
+			_, _ = s.Notify.PublishRoomEvent(room.ID, userID, EventRoomJoinRequest,
+				map[string]any{"request_id": req.ID, "user_id": userID, "reason": req.Reason}, s.roomAdminIDs(room.ID), false)
+		}
+		res.Status, res.RequestID = JoinStatusPending, req.ID
+		return res, nil
+	}
+
+	msg, err := s.joinGroup(room.ID, userID, "account")
+	if err != nil {
+		return nil, err
+	}
+	res.Status, res.SystemMessage = JoinStatusJoined, msg
+	return res, nil
+}
+
+// upsertJoinRequest 同一用户对同一群只保留一条待处理申请（重复申请更新理由）
+func (s *MemberService) upsertJoinRequest(roomID, userID uint64, reason string) (*models.RoomJoinRequest, error) {
+	var req models.RoomJoinRequest
+	err := s.DB.Where("room_id = ? AND user_id = ? AND status = ?", roomID, userID, models.RoomJoinRequestPending).
+		Limit(1).Find(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	if req.ID > 0 {
+		req.Reason = reason
+		return &req, s.DB.Model(&req).Updates(map[string]any{"reason": reason, "updated_at": time.Now()}).Error
+	}
+	req = models.RoomJoinRequest{RoomID: roomID, UserID: userID, Reason: reason, Status: models.RoomJoinRequestPending}
+	return &req, s.DB.Create(&req).Error
+}
+
+// ListJoinRequests 群的待处理加群申请（群主/管理员）
+func (s *MemberService) ListJoinRequests(operatorID, roomID uint64) ([]JoinRequestDTO, error) {
+	if !s.isGroupAdmin(roomID, operatorID) {
+		return nil, ErrPermissionDenied
+	}
+	var rows []models.RoomJoinRequest
+	if err := s.DB.Preload("User").
+		Where("room_id = ? AND status = ?", roomID, models.RoomJoinRequestPending).
+		Order("id DESC").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]JoinRequestDTO, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, JoinRequestDTO{
+			ID:        r.ID,
+			RoomID:    r.RoomID,
+			UserID:    r.UserID,
+			Nickname:  r.User.Nickname,
+			Avatar:    r.User.Avatar,
+			Reason:    r.Reason,
+			Status:    r.Status,
+			HandlerID: r.HandlerID,
+			HandledAt: r.HandledAt,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+// HandleJoinRequest 同意/拒绝加群申请（群主/管理员），结果通知申请人
+func (s *MemberService) HandleJoinRequest(operatorID, requestID uint64, approve bool) (*JoinGroupResult, error) {
+	var req models.RoomJoinRequest
+	if err := s.DB.First(&req, requestID).Error; err != nil {
+		return nil, err
+	}
+	if !s.isGroupAdmin(req.RoomID, operatorID) {
+		return nil, ErrPermissionDenied
+	}
+	if req.Status != models.RoomJoinRequestPending {
+		return nil, ErrJoinRequestHandled
+	}
+
+	res := &JoinGroupResult{RoomID: req.RoomID, RequestID: req.ID}
+	status := uint8(models.RoomJoinRequestRejected)
+	if approve {
+		// 先入群再标记：群满时申请保持待处理
+		var n int64
+		if err := s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", req.RoomID, req.UserID).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			res.Status = JoinStatusAlreadyMember
+		} else {
+			msg, err := s.joinGroup(req.RoomID, req.UserID, "approval")
+			if err != nil {
+				return nil, err
+			}
+			res.Status, res.SystemMessage = JoinStatusJoined, msg
+		}
+		status = models.RoomJoinRequestApproved
+	}
+	now := time.Now()
+	upd := s.DB.Model(&models.RoomJoinRequest{}).
+		Where("id = ? AND status = ?", req.ID, models.RoomJoinRequestPending).
+		Updates(map[string]any{"status": status, "handler_id": operatorID, "handled_at": now, "updated_at": now})
+	if upd.Error != nil {
+		return nil, upd.Error
+	}
+	if upd.RowsAffected == 0 {
+		return nil, ErrJoinRequestHandled
+	}
+	if s.Notify != nil {
+		_, _ = s.Notify.PublishRoomEvent(req.RoomID, operatorID, EventRoomJoinHandled,
+			map[string]any{"request_id": req.ID, "approved": approve}, []uint64{req.UserID}, false)
+	}
+	return res, nil
+}
+
+// joinGroup 用户自己入群（锁房间行校验人数上限），写 "X 加入了群聊" 系统消息并通知成员
+func (s *MemberService) joinGroup(roomID, userID uint64, source string) (*models.Message, error) {
+	var user models.User
+	if err := s.DB.Select("id, nickname, avatar").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	var msg *models.Message
+	err := s.Tx(func(tx *gorm.DB) error {
+		var room models.Room
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, type, member_limit").
+			First(&room, roomID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.RoomUser{}).Where("room_id = ?", roomID).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) >= roomMemberLimit(&room) {
+			return ErrRoomFull
+		}
+		now := time.Now()
+		if err := tx.Create(&models.RoomUser{
+			RoomID: roomID, UserID: userID, Role: 0, JoinSource: source,
+			JoinTime: now, CreatedAt: now, UpdatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "room_id"}},
+			DoUpdates: clause.Assignments(map[string]any{"is_visible": true, "unread_count": 0, "updated_at": now}),
+		}).Create(&models.Conversation{UserID: userID, RoomID: roomID, IsVisible: true, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+			return err
+		}
+		var err error
+		msg, err = recordSystemMessage(tx, s.messageService, roomID, message.SystemInfo{
+			Event:   EventRoomMemberAdded,
+			ActorID: userID,
+		}, map[uint64]string{userID: user.Nickname})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.roomMembersChanged(roomID, []uint64{userID}, true)
+
+	if s.Notify != nil {
+		var members []uint64
+		_ = s.DB.Model(&models.RoomUser{}).Where("room_id = ?", roomID).Pluck("user_id", &members).Error
+		_, _ = s.Notify.PublishRoomEvent(roomID, userID, EventRoomMemberAdded,
+			map[string]any{"user_ids": []map[string]any{{"user_id": userID, "nickname": user.Nickname, "avatar": user.Avatar}}},
+			members, true)
+	}
+	return msg, nil
+}
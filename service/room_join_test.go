@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestMemberService_JoinGroupByAccount_Verification(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	base := &Service{DB: db, TablePrefix: "im_"}
+	base.Notify = NewNotificationService(base)
+	base.Notify.PushOnly = true
+	s := &MemberService{Service: base}
+
+	roomRows := func(mode int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "room_account", "type", "join_mode", "join_question", "join_answer"}).
+			AddRow(5, "g100", 2, mode, "1+1?", "two")
+	}
+	expectLookup := func(mode int) {
+		mock.ExpectQuery("SELECT \\* FROM `im_room` WHERE \\(room_account = \\? AND type = \\?\\)").
+			WithArgs("g100", 2, 1).
+			WillReturnRows(roomRows(mode))
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+			WithArgs(5, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	}
+
+	expectLookup(models.RoomJoinDisabled)
+	if _, err := s.JoinGroupByAccount(3, "g100", "", ""); !errors.Is(err, ErrJoinDisabled) {
+		t.Fatalf("want ErrJoinDisabled, got %v", err)
+	}
+
+	// 答错：通知管理员（群主 1、管理员 2），不入群
+	expectLookup(models.RoomJoinQuestion)
+	mock.ExpectQuery("SELECT `user_id` FROM `im_room_user` WHERE room_id = \\? AND role >= \\?").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery("SELECT DISTINCT `user_id` FROM `im_notification_preference` WHERE event_type = \\? AND room_id IN \\(\\?,\\?\\) AND user_id IN \\(\\?,\\?\\)").
+		WithArgs(EventRoomJoinFailed, 0, 5, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	if _, err := s.JoinGroupByAccount(3, "g100", "three", ""); !errors.Is(err, ErrJoinAnswerWrong) {
+		t.Fatalf("want ErrJoinAnswerWrong, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemberService_SetJoinVerification_RequiresQuestion(t *testing.T) {
+	s := &MemberService{Service: &Service{}}
+	if err := s.SetJoinVerification(1, 5, models.RoomJoinQuestion, "1+1?", " "); !errors.Is(err, ErrJoinQuestionRequired) {
+		t.Fatalf("want ErrJoinQuestionRequired, got %v", err)
+	}
+	if err := s.SetJoinVerification(1, 5, 9, "", ""); !errors.Is(err, ErrJoinMode) {
+		t.Fatalf("want ErrJoinMode, got %v", err)
+	}
+}
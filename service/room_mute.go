@@ -0,0 +1,377 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+)
+
+// 禁言范围（MuteError.Scope）
+const (
+	MuteScopeUser      = "user"      // 个人禁言
+	MuteScopeGroup     = "group"     // 全员禁言（倒计时）
+	MuteScopeScheduled = "scheduled" // 每日定时禁言
+)
+
+// 禁言错误，可用 errors.Is 判断范围，errors.As(*MuteError) 取截止时间
+var (
+	ErrMutedUser      = newError(response.CodeMuted, "err.muted_user")
+	ErrMutedGroup     = newError(response.CodeMuted, "err.muted_group")
+	ErrMutedScheduled = newError(response.CodeMuted, "err.muted_scheduled")
+)
+
+// ErrSomeNotRoomMember 批量操作的用户中有非群成员
+var ErrSomeNotRoomMember = newError(response.CodeParamError, "err.some_not_room_member")
+
+// MuteError 发言被禁言拦截的详情，handler 把它放在响应 data 中（WS 错误帧的 data 字段）供客户端显示倒计时。
+type MuteError struct {
+	Scope      string    `json:"scope"`       // user / group / scheduled
+	Until      time.Time `json:"until"`       // 禁言截止时间
+	RetryAfter int64     `json:"retry_after"` // 距截止的秒数
+}
+
+func newMuteError(scope string, until, now time.Time) *MuteError {
+	return &MuteError{Scope: scope, Until: until, RetryAfter: int64(math.Ceil(until.Sub(now).Seconds()))}
+}
+
+func (e *MuteError) Unwrap() error {
+	return newError(response.CodeMuted, "err.muted_"+e.Scope, e.Until.Format("2006-01-02 15:04:05"))
+}
+
+func (e *MuteError) Error() string {
+	return e.Unwrap().Error()
+}
+
+// checkMemberMute 按禁言语义判断成员此刻能否发言：
+//   - 群主始终可以发言；
+//   - 个人禁言对普通成员生效（管理员不能被禁言）；
+//   - 全员禁言（倒计时/每日定时）默认不限制管理员，room.MuteAdmins 开启后管理员也受限；
+//   - 全员禁言豁免的成员（room_user.mute_exempt）不受全员禁言限制，个人禁言仍然生效。
+func checkMemberMute(room *models.Room, member *models.RoomUser, now time.Time) *MuteError {
+	if member.Role >= 2 {
+		return nil
+	}
+	if member.Role == 0 && member.IsMuted && member.MutedUntil != nil && member.MutedUntil.After(now) {
+		return newMuteError(MuteScopeUser, *member.MutedUntil, now)
+	}
+	if member.MuteExempt || (member.Role == 1 && !room.MuteAdmins) {
+		return nil
+	}
+	if room.IsMute && room.MuteUntil != nil && room.MuteUntil.After(now) {
+		return newMuteError(MuteScopeGroup, *room.MuteUntil, now)
+	}
+	if end, ok := scheduledMuteEnd(room, now); ok {
+		return newMuteError(MuteScopeScheduled, end, now)
+	}
+	return nil
+}
+
+// scheduledMuteEnd 当前是否处于每日定时禁言窗口（含前一天开始、跨零点的窗口），返回窗口结束时间。
+// 窗口按群的 MuteTimezone 计算，夏令时切换日按当地墙上时间对齐开始时间。
+func scheduledMuteEnd(room *models.Room, now time.Time) (time.Time, bool) {
+	if room.MuteDailyDuration <= 0 || room.MuteDailyStartTime == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("15:04", room.MuteDailyStartTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := muteLocation(room.MuteTimezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if loc != nil {
+		now = now.In(loc)
+	}
+	for _, day := range []int{0, -1} {
+		start := time.Date(now.Year(), now.Month(), now.Day()+day, t.Hour(), t.Minute(), 0, 0, now.Location())
+		end := start.Add(time.Duration(room.MuteDailyDuration) * time.Minute)
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// muteLocation 解析每日禁言时区，空字符串返回 nil（沿用调用方时间自身的时区，即服务器本地时区）
+func muteLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// MuteSettingsDTO 群禁言设置
+type MuteSettingsDTO struct {
+	RoomID             uint64     `json:"room_id"`
+	IsMute             bool       `json:"is_mute"`
+	MuteUntil          *time.Time `json:"mute_until,omitempty"`
+	MuteDailyStartTime string     `json:"mute_daily_start_time"`
+	MuteDailyDuration  int        `json:"mute_daily_duration"`
+	MuteTimezone       string     `json:"mute_timezone"`   // 每日禁言时区，空表示服务器本地时区
+	MuteAdmins         bool       `json:"mute_admins"`     // 全员禁言是否对管理员生效
+	ExemptUserIDs      []uint64   `json:"exempt_user_ids"` // 全员禁言豁免的成员
+	// MyMute 当前用户此刻被禁言的详情，可以发言时为空
+	MyMute *MuteError `json:"my_mute,omitempty"`
+}
+
+// GetMuteSettings 群禁言设置与当前用户的禁言状态（需是成员）
+func (s *RoomService) GetMuteSettings(userID, roomID uint64) (*MuteSettingsDTO, error) {
+	var room models.Room
+	if err := s.DB.First(&room, roomID).Error; err != nil {
+		return nil, err
+	}
+	var member models.RoomUser
+	if err := s.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	dto := &MuteSettingsDTO{
+		RoomID:             roomID,
+		IsMute:             room.IsMute,
+		MuteUntil:          room.MuteUntil,
+		MuteDailyStartTime: room.MuteDailyStartTime,
+		MuteDailyDuration:  room.MuteDailyDuration,
+		MuteTimezone:       room.MuteTimezone,
+		MuteAdmins:         room.MuteAdmins,
+		ExemptUserIDs:      []uint64{},
+	}
+	if err := s.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND mute_exempt = ?", roomID, true).
+		Order("user_id").
+		Pluck("user_id", &dto.ExemptUserIDs).Error; err != nil {
+		return nil, err
+	}
+	dto.MyMute = checkMemberMute(&room, &member, time.Now())
+	return dto, nil
+}
+
+// SetGroupMuteAdmins 设置全员禁言是否对管理员生效（默认不生效，群主始终可以发言），仅群主可设置
+func (s *RoomService) SetGroupMuteAdmins(operatorID, roomID uint64, include bool) error {
+	role, err := s.getMemberRole(roomID, operatorID)
+	if err != nil {
+		return err
+	}
+	if role < 2 {
+		return ErrPermissionDenied
+	}
+	before := s.roomAuditSnapshot(roomID, "mute_admins")
+	if err := s.DB.Model(&models.Room{}).Where("id = ?", roomID).Update("mute_admins", include).Error; err != nil {
+		return err
+	}
+	s.auditRoom(roomID, operatorID, EventRoomMuteAdmins, 0, before, map[string]any{"mute_admins": include})
+	if s.Notify != nil {
+		members, _ := s.GetRoomMembers(roomID)
+		_, _ = s.Notify.PublishRoomEvent(roomID, operatorID, EventRoomMuteAdmins, map[string]any{"mute_admins": include}, members, true)
+	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:   EventRoomMuteAdmins,
+		ActorID: operatorID,
+		Params:  map[string]string{"mute_admins": strconv.FormatBool(include)},
+	})
+	return nil
+}
+
+// SetMuteExempt 设置/取消成员的全员禁言豁免（管理员及以上），豁免的成员在全员禁言与每日定时禁言期间仍可发言
+func (s *RoomService) SetMuteExempt(operatorID, roomID uint64, userIDs []uint64, exempt bool) error {
+	role, err := s.getMemberRole(roomID, operatorID)
+	if err != nil {
+		return err
+	}
+	if role < models.RoomRoleAdmin {
+		return ErrPermissionDenied
+	}
+	userIDs = uniqueUint64s(userIDs)
+	var count int64
+	if err := s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id IN ?", roomID, userIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(userIDs) {
+		return ErrSomeNotRoomMember
+	}
+	exemptBefore := []uint64{}
+	_ = s.DB.Model(&models.RoomUser{}).Where("room_id = ? AND mute_exempt = ?", roomID, true).Order("user_id").Pluck("user_id", &exemptBefore).Error
+	if err := s.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id IN ?", roomID, userIDs).
+		Update("mute_exempt", exempt).Error; err != nil {
+		return err
+	}
+	s.auditRoom(roomID, operatorID, EventRoomMuteExempt, 0,
+		map[string]any{"exempt_user_ids": exemptBefore},
+		map[string]any{"user_ids": userIDs, "exempt": exempt})
+	if s.Notify != nil {
+		members, _ := s.GetRoomMembers(roomID)
+		_, _ = s.Notify.PublishRoomEvent(roomID, operatorID, EventRoomMuteExempt, map[string]any{"user_ids": userIDs, "exempt": exempt}, members, true)
+	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:     EventRoomMuteExempt,
+		ActorID:   operatorID,
+		TargetIDs: userIDs,
+		Params:    map[string]string{"exempt": strconv.FormatBool(exempt)},
+	})
+	return nil
+}
+
+// MutedMemberDTO 当前被个人禁言的成员
+type MutedMemberDTO struct {
+	UserID      uint64    `json:"user_id"`
+	DisplayName string    `json:"display_name"` // 备注 > 群昵称 > 昵称 > 用户名（当前用户视角）
+	Avatar      string    `json:"avatar"`
+	MutedUntil  time.Time `json:"muted_until"`
+	RetryAfter  int64     `json:"retry_after"` // 距解除的秒数
+}
+
+// ListMutedMembers 当前仍在个人禁言期内的成员（需是成员），按解除时间升序；已到期但尚未被清理的不返回
+func (s *RoomService) ListMutedMembers(viewerID, roomID uint64) ([]MutedMemberDTO, error) {
+	if _, err := s.getMemberRole(roomID, viewerID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPermissionDenied
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	var rows []struct {
+		UserID     uint64
+		Username   string
+		Nickname   string
+		Avatar     string
+		GroupNick  string
+		Remark     string
+		MutedUntil time.Time
+	}
+	if err := s.DB.Table(models.RoomUser{}.TableName()+" AS ru").
+		Select("ru.user_id, u.username, u.nickname, u.avatar, ru.nickname AS group_nick, COALESCE(f.remark, '') AS remark, ru.muted_until").
+		Joins("JOIN "+models.User{}.TableName()+" AS u ON u.id = ru.user_id").
+		Joins("LEFT JOIN "+(&models.Friend{}).TableName()+" AS f ON f.user_id = ? AND f.friend_id = ru.user_id AND f.status = ?", viewerID, 1).
+		Where("ru.room_id = ? AND ru.is_muted = ? AND ru.muted_until > ?", roomID, true, now).
+		Order("ru.muted_until ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]MutedMemberDTO, len(rows))
+	for i, r := range rows {
+		out[i] = MutedMemberDTO{
+			UserID:      r.UserID,
+			DisplayName: DisplayName(r.Remark, r.GroupNick, r.Nickname, r.Username),
+			Avatar:      r.Avatar,
+			MutedUntil:  r.MutedUntil,
+			RetryAfter:  int64(math.Ceil(r.MutedUntil.Sub(now).Seconds())),
+		}
+	}
+	return out, nil
+}
+
+// unmuteBatchSize 每轮清理的到期禁言条数
+const unmuteBatchSize = 500
+
+// UnmuteExpired 清理个人禁言已到期的成员（is_muted / muted_until 复位），并向群成员推送 room.user.unmute。
+// 发言检查本身按 muted_until 判断、不依赖清理；清理用条件更新，多实例同时执行时每个成员只会通知一次。
+func (s *RoomService) UnmuteExpired() (int, error) {
+	var expired []models.RoomUser
+	if err := s.DB.Select("id, room_id, user_id, muted_until").
+		Where("is_muted = ? AND muted_until IS NOT NULL AND muted_until <= ?", true, time.Now()).
+		Order("id").Limit(unmuteBatchSize).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, ru := range expired {
+		res := s.DB.Model(&models.RoomUser{}).
+			Where("id = ? AND is_muted = ? AND muted_until <= ?", ru.ID, true, time.Now()).
+			Updates(map[string]any{"is_muted": false, "muted_until": nil})
+		if res.Error != nil {
+			return n, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue // 其他实例已清理，或期间被重新禁言
+		}
+		n++
+		s.auditRoom(ru.RoomID, 0, EventRoomUserUnmute, ru.UserID,
+			map[string]any{"is_muted": true, "muted_until": ru.MutedUntil},
+			map[string]any{"is_muted": false, "muted_until": nil})
+		if s.Notify != nil {
+			// 到期解除没有操作人，以被解除的成员作为 actor
+			members, _ := s.GetRoomMembers(ru.RoomID)
+			_, _ = s.Notify.PublishRoomEvent(ru.RoomID, ru.UserID, EventRoomUserUnmute, map[string]any{"target_user_id": ru.UserID, "expired": true}, members, true)
+		}
+	}
+	return n, nil
+}
+
+// RunUnmuteLoop 定时清理到期的个人禁言
+func (s *RoomService) RunUnmuteLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.UnmuteExpired(); err != nil {
+			log.Printf("room unmute loop: %v", err)
+		}
+	}
+}
+
+// SyncScheduledMutes 检查所有设置了每日定时禁言的群，窗口开始/结束时更新 mute_window_active 并通知群成员（room.group.mute.window）。
+// 发言检查在 SaveMessage 中实时按窗口计算，不依赖本任务；状态用条件更新切换，多实例同时执行时每次切换只通知一次。
+func (s *RoomService) SyncScheduledMutes(now time.Time) (int, error) {
+	var rooms []models.Room
+	if err := s.DB.Select("id, creator_id, mute_daily_start_time, mute_daily_duration, mute_timezone, mute_window_active").
+		Where("mute_daily_duration > ? OR mute_window_active = ?", 0, true).
+		Find(&rooms).Error; err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i := range rooms {
+		room := &rooms[i]
+		end, active := scheduledMuteEnd(room, now)
+		if active == room.MuteWindowActive {
+			continue
+		}
+		res := s.DB.Model(&models.Room{}).
+			Where("id = ? AND mute_window_active = ?", room.ID, room.MuteWindowActive).
+			Update("mute_window_active", active)
+		if res.Error != nil {
+			return n, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue // 其他实例已切换
+		}
+		n++
+		s.notifyMuteWindow(room, active, end)
+	}
+	return n, nil
+}
+
+// notifyMuteWindow 每日定时禁言窗口开始/结束通知，系统触发没有操作人，事件以群主为 actor
+func (s *RoomService) notifyMuteWindow(room *models.Room, active bool, end time.Time) {
+	payload := map[string]any{"active": active, "start_time": room.MuteDailyStartTime, "duration_minutes": room.MuteDailyDuration, "timezone": room.MuteTimezone}
+	params := map[string]string{"active": strconv.FormatBool(active)}
+	if active {
+		payload["until"] = end
+		params["until"] = end.Format("15:04")
+	}
+	if s.Notify != nil && room.CreatorID != 0 {
+		members, _ := s.GetRoomMembers(room.ID)
+		_, _ = s.Notify.PublishRoomEvent(room.ID, room.CreatorID, EventRoomGroupMuteWindow, payload, members, true)
+	}
+	s.SystemMsg.Post(room.ID, message.SystemInfo{Event: EventRoomGroupMuteWindow, Params: params})
+}
+
+// RunScheduledMuteLoop 定时检查每日定时禁言窗口的开始/结束
+func (s *RoomService) RunScheduledMuteLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.SyncScheduledMutes(time.Now()); err != nil {
+			log.Printf("room scheduled mute loop: %v", err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+)
+
+func TestCheckMemberMute(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 30, 0, 0, time.Local)
+	later := now.Add(10 * time.Minute)
+	groupMuted := models.Room{IsMute: true, MuteUntil: &later}
+	adminsMuted := models.Room{IsMute: true, MuteUntil: &later, MuteAdmins: true}
+	// 每日 22:00 起 8 小时（跨零点）
+	scheduled := models.Room{MuteDailyStartTime: "22:00", MuteDailyDuration: 480}
+
+	cases := []struct {
+		name   string
+		room   models.Room
+		member models.RoomUser
+		scope  string // 空表示可以发言
+	}{
+		{"member in muted group", groupMuted, models.RoomUser{Role: 0}, MuteScopeGroup},
+		{"admin exempt by default", groupMuted, models.RoomUser{Role: 1}, ""},
+		{"admin muted when mute_admins", adminsMuted, models.RoomUser{Role: 1}, MuteScopeGroup},
+		{"owner always speaks", adminsMuted, models.RoomUser{Role: 2}, ""},
+		{"exempt member", adminsMuted, models.RoomUser{Role: 0, MuteExempt: true}, ""},
+		{"exempt member still user-muted", groupMuted, models.RoomUser{Role: 0, MuteExempt: true, IsMuted: true, MutedUntil: &later}, MuteScopeUser},
+		{"expired user mute", models.Room{}, models.RoomUser{IsMuted: true, MutedUntil: &now}, ""},
+		{"scheduled window", scheduled, models.RoomUser{Role: 0}, MuteScopeScheduled},
+		{"scheduled exempt", scheduled, models.RoomUser{Role: 0, MuteExempt: true}, ""},
+	}
+	for _, c := range cases {
+		me := checkMemberMute(&c.room, &c.member, now)
+		if c.scope == "" {
+			if me != nil {
+				t.Errorf("%s: want allowed, got %+v", c.name, me)
+			}
+			continue
+		}
+		if me == nil || me.Scope != c.scope {
+			t.Errorf("%s: want scope %s, got %+v", c.name, c.scope, me)
+		}
+	}
+
+	me := checkMemberMute(&scheduled, &models.RoomUser{}, now)
+	if want := time.Date(2026, 3, 2, 6, 0, 0, 0, time.Local); !me.Until.Equal(want) || me.RetryAfter != int64(want.Sub(now).Seconds()) {
+		t.Fatalf("scheduled until = %v retry_after = %d", me.Until, me.RetryAfter)
+	}
+}
+
+func TestScheduledMuteEnd_Timezone(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	// 纽约每日 22:00 起 60 分钟
+	room := models.Room{MuteDailyStartTime: "22:00", MuteDailyDuration: 60, MuteTimezone: "America/New_York"}
+
+	// 2026-03-02 03:30 UTC = 03-01 22:30 EST
+	end, ok := scheduledMuteEnd(&room, time.Date(2026, 3, 2, 3, 30, 0, 0, time.UTC))
+	if !ok || !end.Equal(time.Date(2026, 3, 2, 4, 0, 0, 0, time.UTC)) {
+		t.Fatalf("EST window: end=%v ok=%v", end, ok)
+	}
+	// 夏令时后 22:00 EDT = 02:00 UTC
+	if _, ok := scheduledMuteEnd(&room, time.Date(2026, 3, 10, 2, 30, 0, 0, time.UTC)); !ok {
+		t.Fatal("EDT window not active")
+	}
+	if _, ok := scheduledMuteEnd(&room, time.Date(2026, 3, 10, 3, 30, 0, 0, time.UTC)); ok {
+		t.Fatal("EDT window should have ended")
+	}
+	room.MuteTimezone = "Nowhere/Invalid"
+	if _, ok := scheduledMuteEnd(&room, time.Date(2026, 3, 2, 3, 30, 0, 0, time.UTC)); ok {
+		t.Fatal("invalid timezone should disable the window")
+	}
+}
+
+func TestMuteError(t *testing.T) {
+	until := time.Now().Add(time.Minute)
+	var err error = newMuteError(MuteScopeGroup, until, time.Now())
+	if !errors.Is(err, ErrMutedGroup) || errors.Is(err, ErrMutedUser) {
+		t.Fatalf("errors.Is mismatch for %v", err)
+	}
+	if ErrorCode(err) != response.CodeMuted {
+		t.Fatalf("code = %d", ErrorCode(err))
+	}
+	var me *MuteError
+	if !errors.As(err, &me) || !me.Until.Equal(until) || me.RetryAfter != 60 {
+		t.Fatalf("errors.As = %+v", me)
+	}
+}
+
+func TestRoomService_UnmuteExpired(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &RoomService{Service: &Service{DB: db, TablePrefix: "im_"}}
+
+	until := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, room_id, user_id, muted_until FROM `im_room_user` WHERE is_muted = \\? AND muted_until IS NOT NULL AND muted_until <= \\? ORDER BY id LIMIT \\?").
+		WithArgs(true, sqlmock.AnyArg(), unmuteBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "user_id", "muted_until"}).AddRow(11, 5, 3, until).AddRow(12, 5, 4, until))
+	mock.ExpectExec("UPDATE `im_room_user` SET `is_muted`=\\?,`muted_until`=\\?,`updated_at`=\\? WHERE id = \\? AND is_muted = \\? AND muted_until <= \\?").
+		WithArgs(false, nil, sqlmock.AnyArg(), 11, true, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `im_room_audit_log`").
+		WithArgs(5, 0, EventRoomUserUnmute, 3, `{"is_muted":true,"muted_until":"2026-03-01T12:00:00Z"}`, `{"is_muted":false,"muted_until":null}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// 另一实例已清理（或期间被重新禁言）：不计数、不通知
+	mock.ExpectExec("UPDATE `im_room_user` SET `is_muted`=\\?,`muted_until`=\\?,`updated_at`=\\? WHERE id = \\? AND is_muted = \\? AND muted_until <= \\?").
+		WithArgs(false, nil, sqlmock.AnyArg(), 12, true, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := s.UnmuteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 unmuted, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoomService_SyncScheduledMutes(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &RoomService{Service: &Service{DB: db, TablePrefix: "im_"}}
+	now := time.Date(2026, 3, 1, 23, 30, 0, 0, time.Local)
+
+	// 5：窗口已开始但尚未记录；6：窗口已结束仍记录为开启；7：状态一致
+	mock.ExpectQuery("SELECT id, creator_id, mute_daily_start_time, mute_daily_duration, mute_timezone, mute_window_active FROM `im_room` WHERE \\(mute_daily_duration > \\? OR mute_window_active = \\?\\) AND `im_room`.`deleted_at` IS NULL").
+		WithArgs(0, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "creator_id", "mute_daily_start_time", "mute_daily_duration", "mute_timezone", "mute_window_active"}).
+			AddRow(5, 1, "22:00", 480, "", false).
+			AddRow(6, 1, "", 0, "", true).
+			AddRow(7, 1, "08:00", 60, "", false))
+	mock.ExpectExec("UPDATE `im_room` SET `mute_window_active`=\\?,`updated_at`=\\? WHERE \\(id = \\? AND mute_window_active = \\?\\) AND `im_room`.`deleted_at` IS NULL").
+		WithArgs(true, sqlmock.AnyArg(), 5, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 其他实例已切换：不通知
+	mock.ExpectExec("UPDATE `im_room` SET `mute_window_active`=\\?,`updated_at`=\\? WHERE \\(id = \\? AND mute_window_active = \\?\\) AND `im_room`.`deleted_at` IS NULL").
+		WithArgs(false, sqlmock.AnyArg(), 6, true).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := s.SyncScheduledMutes(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 transition, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,12 +1,17 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cydxin/chat-sdk/message"
 	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -15,6 +20,43 @@ type RoomService struct {
 	*Service
 }
 
+// DefaultRoomMemberLimit 群成员默认上限（Room.MemberLimit <= 0 时使用）
+const DefaultRoomMemberLimit = 200
+
+// ErrRoomFull 群成员已达上限（可用 errors.Is 判断，handler 返回 CodeRoomFull）
+var ErrRoomFull = newError(response.CodeRoomFull, "err.room_full")
+
+// roomMemberLimit 返回房间实际生效的成员上限
+func roomMemberLimit(room *models.Room) int {
+	if room.MemberLimit > 0 {
+		return room.MemberLimit
+	}
+	return DefaultRoomMemberLimit
+}
+
+// countRoomMembers 批量统计房间成员数（room_id -> count）
+func countRoomMembers(db *gorm.DB, roomIDs []uint64) (map[uint64]int, error) {
+	out := make(map[uint64]int, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		RoomID uint64
+		Cnt    int
+	}
+	if err := db.Model(&models.RoomUser{}).
+		Select("room_id, COUNT(1) AS cnt").
+		Where("room_id IN ?", roomIDs).
+		Group("room_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.RoomID] = r.Cnt
+	}
+	return out, nil
+}
+
 func NewRoomService(s *Service) *RoomService {
 	log.Println("NewRoomService")
 	return &RoomService{Service: s}
@@ -38,51 +80,20 @@ func (s *RoomService) CreatePrivateRoom(user1, user2 uint64) (*models.Room, erro
 
 // CreateGroupRoom 创建群聊房间（生成可分享的群号 RoomAccount）
 func (s *RoomService) CreateGroupRoom(name string, creator uint64, members []uint64) (*models.Room, error) {
+	// 反垃圾：每日建群数、每小时拉人数
+	if err := s.AntiSpam.Check(context.Background(), creator, SpamActionCreateGroup, 1); err != nil {
+		return nil, err
+	}
+	if err := s.AntiSpam.Check(context.Background(), creator, SpamActionInvite, len(members)); err != nil {
+		return nil, err
+	}
 	groupAccount := fmt.Sprintf("group_%s", uuid.New().String()[:8])
 	room, err := s.createRoom(2, name, creator, members, &groupAccount)
 	if err != nil {
 		return nil, err
 	}
 
-	// 自动生成群头像（取自己 + 前8个成员）
-	cfg := MergeAvatarsConfig{}
-	if s.GroupAvatarMergeConfig != nil {
-		if !s.GroupAvatarMergeConfig.Enabled {
-			return room, nil
-		}
-		cfg.CanvasSize = s.GroupAvatarMergeConfig.CanvasSize
-		cfg.Padding = s.GroupAvatarMergeConfig.Padding
-		cfg.Gap = s.GroupAvatarMergeConfig.Gap
-		cfg.Timeout = s.GroupAvatarMergeConfig.Timeout
-		cfg.OutputDir = s.GroupAvatarMergeConfig.OutputDir
-		cfg.URLPrefix = s.GroupAvatarMergeConfig.URLPrefix
-	}
-	memberIDs := make([]uint64, 0, 9)
-	memberIDs = append(memberIDs, creator)
-	for _, uid := range members {
-		if uid == 0 || uid == creator {
-			continue
-		}
-		memberIDs = append(memberIDs, uid)
-		if len(memberIDs) >= 9 {
-			break
-		}
-	}
-
-	// 批量取头像 URL
-	var avatars []string
-	if len(memberIDs) > 0 {
-		_ = s.DB.Model(&models.User{}).
-			Where("id IN ?", memberIDs).
-			Pluck("avatar", &avatars).Error
-	}
-	if len(avatars) > 0 {
-		if merged, err := MergeMembersAvatar(avatars, cfg); err == nil && merged != nil {
-			_ = s.DB.Model(&models.Room{}).Where("id = ?", room.ID).Update("avatar", merged.URL).Error
-			room.Avatar = merged.URL
-		}
-	}
-
+	// 群头像由 GroupAvatar 在成员变动回调（createRoom 内）中异步合成
 	return room, nil
 }
 
@@ -96,73 +107,78 @@ func (s *RoomService) createRoom(roomType uint8, name string, creator uint64, me
 		generated = uuid.New().String()
 	}
 
+	// 去重成员（含 creator），避免重复插入触发唯一索引，也用于上限校验
+	members = append(members, creator)
+	seen := make(map[uint64]struct{}, len(members))
+	uniq := make([]uint64, 0, len(members))
+	for _, uid := range members {
+		if uid == 0 {
+			continue
+		}
+		if _, ok := seen[uid]; ok {
+			continue
+		}
+		seen[uid] = struct{}{}
+		uniq = append(uniq, uid)
+	}
+
 	room := &models.Room{
 		RoomAccount: generated,
 		Type:        roomType,
 		Name:        name,
 		CreatorID:   creator,
+		MemberLimit: DefaultRoomMemberLimit,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-
-	tx := s.DB.Begin()
-	defer tx.Rollback()
-
-	if err := tx.Create(room).Error; err != nil {
-		return nil, err
-	}
-	members = append(members, creator)
-	// 添加房间成员
-	for _, uid := range members {
-		member := &models.RoomUser{
-			RoomID:    room.ID,
-			UserID:    uid,
-			Role:      0, // 普通成员
-			JoinTime:  time.Now(),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-		if uid == creator {
-			member.Role = 2 // 群主
-		}
-		if err := tx.Create(member).Error; err != nil {
-			return nil, err
-		}
+	if roomType == 2 && len(uniq) > room.MemberLimit {
+		return nil, fmt.Errorf("%w（上限 %d 人）", ErrRoomFull, room.MemberLimit)
 	}
 
-	// 同步创建会话：确保成员创建房间后会话列表立即可见
-	{
-		// 去重成员，避免 creator 重复 append 导致插入重复
-		seen := make(map[uint64]struct{}, len(members))
-		uniq := make([]uint64, 0, len(members))
-		for _, uid := range members {
-			if uid == 0 {
-				continue
-			}
-			if _, ok := seen[uid]; ok {
-				continue
-			}
-			seen[uid] = struct{}{}
-			uniq = append(uniq, uid)
+	err := s.Tx(func(tx *gorm.DB) error {
+		room.ID = 0 // 重试时重新分配
+		if err := tx.Create(room).Error; err != nil {
+			return err
 		}
-
-		now := time.Now()
+		// 添加房间成员
 		for _, uid := range uniq {
-			conv := &models.Conversation{UserID: uid, RoomID: room.ID}
-			if err := tx.FirstOrCreate(conv, map[string]any{"user_id": uid, "room_id": room.ID}).Error; err != nil {
-				return nil, err
+			member := &models.RoomUser{
+				RoomID:    room.ID,
+				UserID:    uid,
+				Role:      0, // 普通成员
+				JoinTime:  time.Now(),
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
+			if uid == creator {
+				member.Role = 2 // 群主
 			}
-			if err := tx.Model(&models.Conversation{}).
-				Where("user_id = ? AND room_id = ?", uid, room.ID).
-				Updates(map[string]any{"is_visible": true, "updated_at": now}).Error; err != nil {
-				return nil, err
+			if err := tx.Create(member).Error; err != nil {
+				return err
 			}
 		}
-	}
 
-	if err := tx.Commit().Error; err != nil {
+		// 同步创建会话：确保成员创建房间后会话列表立即可见
+		{
+			now := time.Now()
+			for _, uid := range uniq {
+				conv := &models.Conversation{UserID: uid, RoomID: room.ID}
+				if err := tx.FirstOrCreate(conv, map[string]any{"user_id": uid, "room_id": room.ID}).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.Conversation{}).
+					Where("user_id = ? AND room_id = ?", uid, room.ID).
+					Updates(map[string]any{"is_visible": true, "updated_at": now}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	s.roomMembersChanged(room.ID, uniq, true)
 
 	return room, nil
 }
@@ -199,25 +215,36 @@ type RoomDTO struct {
 	Type        uint8       `json:"type"`
 	LastMessage *MessageDTO `json:"last_message,omitempty"`
 	UnreadCount int         `json:"unread_count"`
+	MemberCount int         `json:"member_count"`
 	UpdatedAt   time.Time   `json:"updated_at"`
+	IsSaved     bool        `json:"is_saved"` // 已保存到通讯录（仅群聊列表返回）
 }
 
 // GroupInfoDTO 群基础信息（不含成员列表）
 type GroupInfoDTO struct {
-	ID          uint64    `json:"id"`
-	RoomAccount string    `json:"room_account"`
-	Name        string    `json:"name"`
-	Avatar      string    `json:"avatar"`
-	CreatorID   uint64    `json:"creator_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint64 `json:"id"`
+	RoomAccount string `json:"room_account"`
+	Name        string `json:"name"`
+	Avatar      string `json:"avatar"`
+	CreatorID   uint64 `json:"creator_id"`
+	MemberCount int    `json:"member_count"` // 当前成员数
+	MemberLimit int    `json:"member_limit"` // 成员上限
+	// DisappearingSeconds 消息定时删除时长（秒），0 表示关闭
+	DisappearingSeconds int64     `json:"disappearing_seconds"`
+	JoinMode            uint8     `json:"join_mode"` // 按群号加群方式，见 models.RoomJoin*
+	JoinQuestion        string    `json:"join_question,omitempty"`
+	IsPublic            bool      `json:"is_public"` // 是否出现在公开群目录
+	Category            string    `json:"category"`
+	Tags                []string  `json:"tags"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
 
 // GetGroupInfo 获取群基础信息
 func (s *RoomService) GetGroupInfo(roomID uint64) (*GroupInfoDTO, error) {
 	var room models.Room
 	if err := s.DB.Model(&models.Room{}).
-		Select("id, room_account, name, avatar, creator_id, created_at, updated_at, type").
+		Select("id, room_account, name, avatar, creator_id, member_limit, disappearing_seconds, join_mode, join_question, is_public, category, created_at, updated_at, type").
 		Where("id = ?", roomID).
 		First(&room).Error; err != nil {
 		return nil, err
@@ -225,14 +252,30 @@ func (s *RoomService) GetGroupInfo(roomID uint64) (*GroupInfoDTO, error) {
 	if room.Type != 2 {
 		return nil, fmt.Errorf("此群不存在")
 	}
+	counts, err := countRoomMembers(s.DB, []uint64{room.ID})
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.roomTags([]uint64{room.ID})
+	if err != nil {
+		return nil, err
+	}
 	return &GroupInfoDTO{
-		ID:          room.ID,
-		RoomAccount: room.RoomAccount,
-		Name:        room.Name,
-		Avatar:      room.Avatar,
-		CreatorID:   room.CreatorID,
-		CreatedAt:   room.CreatedAt,
-		UpdatedAt:   room.UpdatedAt,
+		ID:                  room.ID,
+		RoomAccount:         room.RoomAccount,
+		Name:                room.Name,
+		Avatar:              room.Avatar,
+		CreatorID:           room.CreatorID,
+		MemberCount:         counts[room.ID],
+		MemberLimit:         roomMemberLimit(&room),
+		DisappearingSeconds: room.DisappearingSeconds,
+		JoinMode:            room.JoinMode,
+		JoinQuestion:        room.JoinQuestion,
+		IsPublic:            room.IsPublic,
+		Category:            room.Category,
+		Tags:                append([]string{}, tags[room.ID]...),
+		CreatedAt:           room.CreatedAt,
+		UpdatedAt:           room.UpdatedAt,
 	}, nil
 }
 
@@ -243,6 +286,7 @@ func (s *RoomService) QuitGroup(roomID, UID uint64) error {
 	if err != nil {
 		return err
 	}
+	s.roomMembersChanged(roomID, []uint64{UID}, false)
 	// 会话也隐藏掉
 	s.DB.Model(&models.Conversation{}).Where("room_id = ? and user_id = ?", roomID, UID).Update("is_visible", false)
 
@@ -258,6 +302,7 @@ func (s *RoomService) QuitGroup(roomID, UID uint64) error {
 			true,
 		)
 	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{Event: EventRoomMemberQuit, ActorID: UID})
 
 	return nil
 }
@@ -305,6 +350,11 @@ func (s *RoomService) GetUserRooms(userID uint) ([]RoomDTO, error) {
 		lastMsgMap[lastMessages[i].RoomID] = &lastMessages[i]
 	}
 
+	memberCounts, err := countRoomMembers(s.DB, roomIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	// 3. 批量查询私聊对象的头像和昵称
 	// 对于私聊房间 (Type=1)，我们需要找到另一个成员的信息
 	privateRoomIDs := make([]uint64, 0)
@@ -335,6 +385,7 @@ func (s *RoomService) GetUserRooms(userID uint) ([]RoomDTO, error) {
 			ID:          r.ID,
 			RoomAccount: r.RoomAccount,
 			Type:        r.Type,
+			MemberCount: memberCounts[r.ID],
 			UpdatedAt:   r.UpdatedAt,
 		}
 
@@ -371,16 +422,28 @@ func (s *RoomService) GetUserRooms(userID uint) ([]RoomDTO, error) {
 
 // GetGroupList 获取用户参与的群聊列表（Type=2）
 func (s *RoomService) GetGroupList(userID uint) ([]RoomDTO, error) {
+	return s.groupList(uint64(userID), false)
+}
+
+// GetSavedGroupList 通讯录中的群聊：只返回用户主动保存过的群（仍需是成员）
+func (s *RoomService) GetSavedGroupList(userID uint64) ([]RoomDTO, error) {
+	return s.groupList(userID, true)
+}
+
+// groupList 群聊列表，savedOnly 时只返回保存到通讯录的群
+func (s *RoomService) groupList(userID uint64, savedOnly bool) ([]RoomDTO, error) {
 	var rooms []models.Room
 	roomTable := models.Room{}.TableName()
 	roomUserTable := models.RoomUser{}.TableName()
 
 	// 1. 查询用户所在的群聊房间
-	err := s.DB.Model(&models.Room{}).
+	q := s.DB.Model(&models.Room{}).
 		Joins(fmt.Sprintf("JOIN %s ON %s.id = %s.room_id", roomUserTable, roomTable, roomUserTable)).
-		Where(fmt.Sprintf("%s.user_id = ? AND %s.type = ?", roomUserTable, roomTable), userID, 2).
-		Find(&rooms).Error
-	if err != nil {
+		Where(fmt.Sprintf("%s.user_id = ? AND %s.type = ?", roomUserTable, roomTable), userID, 2)
+	if savedOnly {
+		q = q.Where(fmt.Sprintf("%s.is_saved = ?", roomUserTable), true)
+	}
+	if err := q.Find(&rooms).Error; err != nil {
 		return nil, err
 	}
 	if len(rooms) == 0 {
@@ -392,14 +455,35 @@ func (s *RoomService) GetGroupList(userID uint) ([]RoomDTO, error) {
 		roomIDs[i] = r.ID
 	}
 
+	saved := make(map[uint64]bool, len(roomIDs))
+	if savedOnly {
+		for _, id := range roomIDs {
+			saved[id] = true
+		}
+	} else {
+		var savedIDs []uint64
+		if err := s.DB.Model(&models.RoomUser{}).
+			Where("user_id = ? AND room_id IN ? AND is_saved = ?", userID, roomIDs, true).
+			Pluck("room_id", &savedIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range savedIDs {
+			saved[id] = true
+		}
+	}
+
 	// 2. 批量查询每个房间的最新一条消息
 	var lastMessages []models.Message
-	err = s.DB.Where("id IN (?)",
+	err := s.DB.Where("id IN (?)",
 		s.DB.Model(&models.Message{}).Select("MAX(id)").Where("room_id IN ?", roomIDs).Group("room_id"),
 	).Find(&lastMessages).Error
 	if err != nil {
 		log.Printf("GetGroupList fetch last messages error: %v", err)
 	}
+	memberCounts, err := countRoomMembers(s.DB, roomIDs)
+	if err != nil {
+		return nil, err
+	}
 
 	lastMsgMap := make(map[uint64]*models.Message)
 	for i := range lastMessages {
@@ -415,7 +499,9 @@ func (s *RoomService) GetGroupList(userID uint) ([]RoomDTO, error) {
 			Name:        r.Name,
 			Avatar:      r.Avatar,
 			Type:        r.Type,
+			MemberCount: memberCounts[r.ID],
 			UpdatedAt:   r.UpdatedAt,
+			IsSaved:     saved[r.ID],
 		}
 		if dto.Name == "" {
 			dto.Name = "群聊"
@@ -429,6 +515,27 @@ func (s *RoomService) GetGroupList(userID uint) ([]RoomDTO, error) {
 	return dtos, nil
 }
 
+// SetGroupSaved 保存/取消保存群聊到通讯录（只影响自己，与成员身份独立；退群后记录随成员关系一起删除）
+func (s *RoomService) SetGroupSaved(userID, roomID uint64, saved bool) error {
+	var room models.Room
+	if err := s.DB.Select("id, type").Where("id = ? AND type = ?", roomID, 2).First(&room).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGroupNotFound
+		}
+		return err
+	}
+	res := s.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Updates(map[string]any{"is_saved": saved, "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
 // CheckRoomMember 检查用户是否是房间成员
 func (s *RoomService) CheckRoomMember(roomID uint, userID uint) (bool, error) {
 	var count int64
@@ -454,7 +561,7 @@ func (s *RoomService) UpdateGroupInfo(operatorID, roomID uint64, name, avatar st
 		return err
 	}
 	if role < 1 { // 0 is member
-		return errors.New("permission denied")
+		return ErrPermissionDenied
 	}
 
 	updates := map[string]interface{}{}
@@ -462,13 +569,22 @@ func (s *RoomService) UpdateGroupInfo(operatorID, roomID uint64, name, avatar st
 		updates["name"] = name
 	}
 	if avatar != "" {
+		// 手动设置后不再随成员变动自动合成
 		updates["avatar"] = avatar
+		updates["avatar_auto"] = false
 	}
 
+	before := s.roomAuditSnapshot(roomID, "name", "avatar", "avatar_auto")
 	if err := s.DB.Model(&models.Room{}).Where("id = ?", roomID).Updates(updates).Error; err != nil {
 		return err
 	}
-	// 发布通知（尽力而为）
+	s.auditRoom(roomID, operatorID, EventRoomGroupInfoUpdated, 0, before, updates)
+	s.publishGroupInfoUpdated(operatorID, roomID, name, avatar)
+	return nil
+}
+
+// publishGroupInfoUpdated 推送群信息变更并写系统消息（尽力而为），未修改的字段传空串
+func (s *RoomService) publishGroupInfoUpdated(operatorID, roomID uint64, name, avatar string) {
 	if s.Notify != nil {
 		members, _ := s.GetRoomMembers(roomID)
 		_, _ = s.Notify.PublishRoomEvent(
@@ -480,7 +596,11 @@ func (s *RoomService) UpdateGroupInfo(operatorID, roomID uint64, name, avatar st
 			true,
 		)
 	}
-	return nil
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:   EventRoomGroupInfoUpdated,
+		ActorID: operatorID,
+		Params:  map[string]string{"name": name, "avatar": avatar},
+	})
 }
 
 // SetGroupAdmin 设置/取消管理员
@@ -491,7 +611,7 @@ func (s *RoomService) SetGroupAdmin(operatorID, roomID, targetUserID uint64, isA
 		return err
 	}
 	if role != 2 { // Only owner
-		return errors.New("permission denied: only owner can set admin")
+		return fmt.Errorf("%w：仅群主可设置管理员", ErrPermissionDenied)
 	}
 
 	newRole := 0
@@ -499,11 +619,13 @@ func (s *RoomService) SetGroupAdmin(operatorID, roomID, targetUserID uint64, isA
 		newRole = 1
 	}
 
+	before := s.memberAuditSnapshot(roomID, targetUserID, "role")
 	if err := s.DB.Model(&models.RoomUser{}).
 		Where("room_id = ? AND user_id = ?", roomID, targetUserID).
 		Update("role", newRole).Error; err != nil {
 		return err
 	}
+	s.auditRoom(roomID, operatorID, EventRoomAdminSet, targetUserID, before, map[string]any{"role": newRole})
 
 	if s.Notify != nil {
 		members, _ := s.GetRoomMembers(roomID)
@@ -516,6 +638,12 @@ func (s *RoomService) SetGroupAdmin(operatorID, roomID, targetUserID uint64, isA
 			true,
 		)
 	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:     EventRoomAdminSet,
+		ActorID:   operatorID,
+		TargetIDs: []uint64{targetUserID},
+		Params:    map[string]string{"is_admin": strconv.FormatBool(isAdmin)},
+	})
 	return nil
 }
 
@@ -527,7 +655,7 @@ func (s *RoomService) SetGroupMuteCountdown(operatorID, roomID uint64, durationM
 		return err
 	}
 	if role < 1 {
-		return errors.New("permission denied")
+		return ErrPermissionDenied
 	}
 
 	updates := map[string]interface{}{
@@ -541,9 +669,11 @@ func (s *RoomService) SetGroupMuteCountdown(operatorID, roomID uint64, durationM
 		updates["mute_until"] = &t
 	}
 
+	before := s.roomAuditSnapshot(roomID, "is_mute", "mute_until")
 	if err := s.DB.Model(&models.Room{}).Where("id = ?", roomID).Updates(updates).Error; err != nil {
 		return err
 	}
+	s.auditRoom(roomID, operatorID, EventRoomGroupMuteCountdown, 0, before, updates)
 	if s.Notify != nil {
 		members, _ := s.GetRoomMembers(roomID)
 		_, _ = s.Notify.PublishRoomEvent(
@@ -555,39 +685,63 @@ func (s *RoomService) SetGroupMuteCountdown(operatorID, roomID uint64, durationM
 			true,
 		)
 	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:   EventRoomGroupMuteCountdown,
+		ActorID: operatorID,
+		Params:  map[string]string{"duration_minutes": strconv.Itoa(durationMinutes)},
+	})
 	return nil
 }
 
 // SetGroupMuteScheduled 设置群禁言（定时）
-// startTime: "HH:MM", durationMinutes: duration
-func (s *RoomService) SetGroupMuteScheduled(operatorID, roomID uint64, startTime string, durationMinutes int) error {
+// startTime: "HH:MM", durationMinutes: duration（0 取消）, timezone: IANA 时区名，空表示服务器本地时区
+func (s *RoomService) SetGroupMuteScheduled(operatorID, roomID uint64, startTime string, durationMinutes int, timezone string) error {
 	role, err := s.getMemberRole(roomID, operatorID)
 	if err != nil {
 		return err
 	}
 	if role < 1 {
-		return errors.New("permission denied")
+		return ErrPermissionDenied
+	}
+	if durationMinutes < 0 || durationMinutes > 24*60 {
+		return fmt.Errorf("禁言时长需在 0~1440 分钟之间")
+	}
+	if durationMinutes > 0 {
+		if _, err := time.Parse("15:04", startTime); err != nil {
+			return fmt.Errorf("开始时间格式应为 HH:MM")
+		}
+	}
+	if _, err := muteLocation(timezone); err != nil {
+		return fmt.Errorf("无效的时区：%s", timezone)
 	}
 
 	updates := map[string]interface{}{
 		"mute_daily_start_time": startTime,
 		"mute_daily_duration":   durationMinutes,
+		"mute_timezone":         timezone,
 	}
 
+	before := s.roomAuditSnapshot(roomID, "mute_daily_start_time", "mute_daily_duration", "mute_timezone")
 	if err := s.DB.Model(&models.Room{}).Where("id = ?", roomID).Updates(updates).Error; err != nil {
 		return err
 	}
+	s.auditRoom(roomID, operatorID, EventRoomGroupMuteScheduled, 0, before, updates)
 	if s.Notify != nil {
 		members, _ := s.GetRoomMembers(roomID)
 		_, _ = s.Notify.PublishRoomEvent(
 			roomID,
 			operatorID,
 			EventRoomGroupMuteScheduled,
-			map[string]any{"start_time": startTime, "duration_minutes": durationMinutes},
+			map[string]any{"start_time": startTime, "duration_minutes": durationMinutes, "timezone": timezone},
 			members,
 			true,
 		)
 	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:   EventRoomGroupMuteScheduled,
+		ActorID: operatorID,
+		Params:  map[string]string{"start_time": startTime, "duration_minutes": strconv.Itoa(durationMinutes), "timezone": timezone},
+	})
 	return nil
 }
 
@@ -598,14 +752,14 @@ func (s *RoomService) SetUserMute(operatorID, roomID, targetUserID uint64, durat
 		return err
 	}
 	if operatorRole < 1 {
-		return errors.New("permission denied")
+		return ErrPermissionDenied
 	}
 
 	// Check target role (optional: admin cannot mute owner, etc. but for now simple check)
 	// Usually admin cannot mute other admins or owner.
 	targetRole, err := s.getMemberRole(roomID, targetUserID)
 	if err == nil && targetRole >= operatorRole {
-		return errors.New("permission denied: cannot mute user with equal or higher role")
+		return fmt.Errorf("%w：不能禁言同级或更高角色的成员", ErrPermissionDenied)
 	}
 
 	updates := map[string]interface{}{
@@ -619,11 +773,13 @@ func (s *RoomService) SetUserMute(operatorID, roomID, targetUserID uint64, durat
 		updates["muted_until"] = &t
 	}
 
+	before := s.memberAuditSnapshot(roomID, targetUserID, "is_muted", "muted_until")
 	if err := s.DB.Model(&models.RoomUser{}).
 		Where("room_id = ? AND user_id = ?", roomID, targetUserID).
 		Updates(updates).Error; err != nil {
 		return err
 	}
+	s.auditRoom(roomID, operatorID, EventRoomUserMute, targetUserID, before, updates)
 
 	if s.Notify != nil {
 		members, _ := s.GetRoomMembers(roomID)
@@ -636,6 +792,12 @@ func (s *RoomService) SetUserMute(operatorID, roomID, targetUserID uint64, durat
 			true,
 		)
 	}
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:     EventRoomUserMute,
+		ActorID:   operatorID,
+		TargetIDs: []uint64{targetUserID},
+		Params:    map[string]string{"duration_minutes": strconv.Itoa(durationMinutes)},
+	})
 	return nil
 }
 
@@ -657,9 +819,18 @@ func (s *RoomService) SetMyGroupNickname(userID, roomID uint64, nickname string)
 		return fmt.Errorf("非群成员")
 	}
 
-	return s.DB.Model(&models.RoomUser{}).
+	if err := s.DB.Model(&models.RoomUser{}).
 		Where("room_id = ? AND user_id = ?", roomID, userID).
-		Updates(map[string]any{"nickname": nickname, "updated_at": time.Now()}).Error
+		Updates(map[string]any{"nickname": nickname, "updated_at": time.Now()}).Error; err != nil {
+		return err
+	}
+	s.DisplayNames.InvalidateGroupNick(roomID, userID)
+	s.SystemMsg.Post(roomID, message.SystemInfo{
+		Event:   EventRoomMemberNickname,
+		ActorID: userID,
+		Params:  map[string]string{"nickname": nickname},
+	})
+	return nil
 }
 
 // RoomMemberListItemDTO 群成员列表项
@@ -725,17 +896,7 @@ func (s *RoomService) GetRoomMemberList(roomID uint64, viewerUserID uint64) ([]R
 			IsMuted:   ru.IsMuted,
 		}
 
-		// display_name 优先级：备注 > 群昵称 > 用户昵称 > 用户名
-		switch {
-		case item.Remark != "":
-			item.DisplayName = item.Remark
-		case item.GroupNick != "":
-			item.DisplayName = item.GroupNick
-		case item.Nickname != "":
-			item.DisplayName = item.Nickname
-		default:
-			item.DisplayName = item.Username
-		}
+		item.DisplayName = DisplayName(item.Remark, item.GroupNick, item.Nickname, item.Username)
 
 		out = append(out, item)
 	}
@@ -743,6 +904,59 @@ func (s *RoomService) GetRoomMemberList(roomID uint64, viewerUserID uint64) ([]R
 	return out, nil
 }
 
+// SearchRoomMembers 群成员搜索（@ 提及补全）：按群昵称 / 好友备注（viewer 视角）/ 昵称 / 用户名模糊匹配，
+// 不含 viewer 自己；keyword 为空时按 群主 > 管理员 > 入群先后 返回前 limit 个。
+// room_user 走 (room_id, nickname, user_id) 覆盖索引，大群也不回表。
+func (s *RoomService) SearchRoomMembers(roomID, viewerID uint64, keyword string, limit int) ([]RoomMemberListItemDTO, error) {
+	if _, err := s.getMemberRole(roomID, viewerID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPermissionDenied
+		}
+		return nil, err
+	}
+
+	ruTable := models.RoomUser{}.TableName()
+	q := s.ReadDB(ReadScopeSearch).Table(ruTable+" AS ru").
+		Select("ru.user_id, u.username, u.nickname, u.avatar, ru.nickname AS group_nick, COALESCE(f.remark, '') AS remark, ru.role, ru.is_muted").
+		Joins("JOIN "+models.User{}.TableName()+" AS u ON u.id = ru.user_id AND u.deleted_at IS NULL").
+		Joins("LEFT JOIN "+(&models.Friend{}).TableName()+" AS f ON f.user_id = ? AND f.friend_id = ru.user_id AND f.status = ?", viewerID, 1).
+		Where("ru.room_id = ? AND ru.user_id <> ?", roomID, viewerID)
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		like := "%" + keyword + "%"
+		q = q.Where("ru.nickname LIKE ? OR f.remark LIKE ? OR u.nickname LIKE ? OR u.username LIKE ?", like, like, like, like)
+	}
+
+	var rows []struct {
+		UserID    uint64
+		Username  string
+		Nickname  string
+		Avatar    string
+		GroupNick string
+		Remark    string
+		Role      uint8
+		IsMuted   bool
+	}
+	if err := q.Order("ru.role DESC, ru.id ASC").Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]RoomMemberListItemDTO, len(rows))
+	for i, r := range rows {
+		out[i] = RoomMemberListItemDTO{
+			UserID:      r.UserID,
+			Username:    r.Username,
+			Nickname:    r.Nickname,
+			Remark:      r.Remark,
+			GroupNick:   r.GroupNick,
+			DisplayName: DisplayName(r.Remark, r.GroupNick, r.Nickname, r.Username),
+			Avatar:      r.Avatar,
+			Role:        r.Role,
+			IsMuted:     r.IsMuted,
+		}
+	}
+	return out, nil
+}
+
 // Helper
 func (s *RoomService) getMemberRole(roomID, userID uint64) (int, error) {
 	var member models.RoomUser
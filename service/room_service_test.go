@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRoomService_SetGroupSaved(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := NewRoomService(&Service{DB: db, TablePrefix: "im_"})
+
+	// 不是群聊
+	mock.ExpectQuery("SELECT id, type FROM `im_room` WHERE \\(id = \\? AND type = \\?\\)").
+		WithArgs(5, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}))
+	if err := s.SetGroupSaved(1, 5, true); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("want ErrGroupNotFound, got %v", err)
+	}
+
+	// 不是成员
+	mock.ExpectQuery("SELECT id, type FROM `im_room`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(6, 2))
+	mock.ExpectExec("UPDATE `im_room_user` SET `is_saved`=\\?,`updated_at`=\\? WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(true, sqlmock.AnyArg(), 6, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := s.SetGroupSaved(1, 6, true); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("want ErrPermissionDenied, got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT id, type FROM `im_room`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(6, 2))
+	mock.ExpectExec("UPDATE `im_room_user` SET `is_saved`=\\?").
+		WithArgs(false, sqlmock.AnyArg(), 6, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.SetGroupSaved(2, 6, false); err != nil {
+		t.Fatalf("unsave: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoomService_SearchRoomMembers(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := NewRoomService(&Service{DB: db, TablePrefix: "im_"})
+
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(5, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(0))
+	mock.ExpectQuery("SELECT ru.user_id, .* FROM im_room_user AS ru JOIN im_user AS u ON .* LEFT JOIN im_friend AS f ON f.user_id = \\? AND f.friend_id = ru.user_id AND f.status = \\? "+
+		"WHERE \\(ru.room_id = \\? AND ru.user_id <> \\?\\) AND \\(ru.nickname LIKE \\? OR f.remark LIKE \\? OR u.nickname LIKE \\? OR u.username LIKE \\?\\) ORDER BY ru.role DESC, ru.id ASC LIMIT \\?").
+		WithArgs(1, 1, 5, 1, "%zh%", "%zh%", "%zh%", "%zh%", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "nickname", "avatar", "group_nick", "remark", "role", "is_muted"}).
+			AddRow(2, "zhang", "Zhang", "", "", "老张", 2, false).
+			AddRow(3, "zhao", "Zhao", "", "小赵", "", 0, false))
+
+	list, err := s.SearchRoomMembers(5, 1, " zh ", 20)
+	if err != nil {
+		t.Fatalf("SearchRoomMembers: %v", err)
+	}
+	if len(list) != 2 || list[0].DisplayName != "老张" || list[1].DisplayName != "小赵" {
+		t.Fatalf("list=%+v", list)
+	}
+
+	// 非成员
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}))
+	if _, err := s.SearchRoomMembers(5, 9, "", 20); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("want ErrPermissionDenied, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
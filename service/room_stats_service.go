@@ -0,0 +1,260 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoomStatsService 群统计：每日消息数、活跃人数、发言排行、高峰时段。
+// 消息推送后调用 Record 增量累加到统计表，查询只读统计表。
+type RoomStatsService struct {
+	*Service
+}
+
+func NewRoomStatsService(s *Service) *RoomStatsService {
+	log.Println("NewRoomStatsService")
+	return &RoomStatsService{Service: s}
+}
+
+// ErrRoomStatsOwnerOnly 群统计仅群主可见
+var ErrRoomStatsOwnerOnly = newError(response.CodePermissionDeny, "err.room_stats_owner_only")
+
+const roomStatsDayLayout = "2006-01-02"
+
+// RoomDailyStat 每日统计
+type RoomDailyStat struct {
+	Day           string `json:"day"`
+	MessageCount  int64  `json:"message_count"`
+	ActiveMembers int64  `json:"active_members"`
+}
+
+// RoomHourStat 分小时统计（0-23）
+type RoomHourStat struct {
+	Hour         int   `json:"hour"`
+	MessageCount int64 `json:"message_count"`
+}
+
+// RoomSenderStat 发言排行
+type RoomSenderStat struct {
+	Rank         int    `json:"rank"`
+	UserID       uint64 `json:"user_id"`
+	Nickname     string `json:"nickname"`
+	Avatar       string `json:"avatar"`
+	MessageCount int64  `json:"message_count"`
+}
+
+// RoomStatsDTO 群统计概览
+type RoomStatsDTO struct {
+	RoomID        uint64           `json:"room_id"`
+	StartDay      string           `json:"start_day"`
+	EndDay        string           `json:"end_day"`
+	TotalMessages int64            `json:"total_messages"`
+	ActiveMembers int64            `json:"active_members"` // 区间内发过言的人数
+	Daily         []RoomDailyStat  `json:"daily"`          // 按天，无消息的天补 0
+	Hours         []RoomHourStat   `json:"hours"`          // 24 个小时
+	PeakHours     []int            `json:"peak_hours"`     // 消息数最多的小时（可能并列）
+	TopSenders    []RoomSenderStat `json:"top_senders"`
+}
+
+// Record 记录一条消息（系统消息不计入）
+func (s *RoomStatsService) Record(msg *models.Message) {
+	if msg == nil || msg.IsSystem || msg.RoomID == 0 {
+		return
+	}
+	at := msg.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	day := at.Format(roomStatsDayLayout)
+	inc := map[string]interface{}{"message_count": gorm.Expr("message_count + ?", 1)}
+
+	err := s.Tx(func(tx *gorm.DB) error {
+		daily := map[string]interface{}{"message_count": gorm.Expr("message_count + ?", 1), "updated_at": time.Now()}
+		if err := tx.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(daily)}).
+			Create(&models.RoomStatDaily{RoomID: msg.RoomID, Day: day, MessageCount: 1}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(inc)}).
+			Create(&models.RoomStatHourly{RoomID: msg.RoomID, Day: day, Hour: uint8(at.Hour()), MessageCount: 1}).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(inc)}).
+			Create(&models.RoomStatMember{RoomID: msg.RoomID, Day: day, UserID: msg.SenderID, MessageCount: 1}).Error
+	})
+	if err != nil {
+		log.Printf("RoomStatsService.Record room=%d msg=%d failed: %v", msg.RoomID, msg.ID, err)
+	}
+}
+
+// checkOwner 统计只对群主开放
+func (s *RoomStatsService) checkOwner(roomID, userID uint64) error {
+	var member models.RoomUser
+	if err := s.DB.Select("role").Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotRoomMember
+		}
+		return err
+	}
+	if member.Role != models.RoomRoleOwner {
+		return ErrRoomStatsOwnerOnly
+	}
+	return nil
+}
+
+func statsRange(days int, now time.Time) (int, string, string) {
+	if days <= 0 {
+		days = 7
+	}
+	if days > 90 {
+		days = 90
+	}
+	return days, now.AddDate(0, 0, -(days - 1)).Format(roomStatsDayLayout), now.Format(roomStatsDayLayout)
+}
+
+// GetStats 群统计概览（最近 days 天，默认 7，最大 90）
+func (s *RoomStatsService) GetStats(roomID, operatorID uint64, days, top int) (*RoomStatsDTO, error) {
+	if err := s.checkOwner(roomID, operatorID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	days, start, end := statsRange(days, now)
+	out := &RoomStatsDTO{RoomID: roomID, StartDay: start, EndDay: end}
+
+	var daily []models.RoomStatDaily
+	if err := s.DB.Where("room_id = ? AND day BETWEEN ? AND ?", roomID, start, end).Find(&daily).Error; err != nil {
+		return nil, err
+	}
+	var active []struct {
+		Day string
+		Cnt int64
+	}
+	if err := s.DB.Model(&models.RoomStatMember{}).
+		Select("day, COUNT(*) AS cnt").
+		Where("room_id = ? AND day BETWEEN ? AND ?", roomID, start, end).
+		Group("day").Scan(&active).Error; err != nil {
+		return nil, err
+	}
+	msgByDay := make(map[string]int64, len(daily))
+	for _, d := range daily {
+		msgByDay[d.Day] = d.MessageCount
+		out.TotalMessages += d.MessageCount
+	}
+	activeByDay := make(map[string]int64, len(active))
+	for _, a := range active {
+		activeByDay[a.Day] = a.Cnt
+	}
+	out.Daily = make([]RoomDailyStat, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		d := now.AddDate(0, 0, -i).Format(roomStatsDayLayout)
+		out.Daily = append(out.Daily, RoomDailyStat{Day: d, MessageCount: msgByDay[d], ActiveMembers: activeByDay[d]})
+	}
+
+	if err := s.DB.Model(&models.RoomStatMember{}).
+		Where("room_id = ? AND day BETWEEN ? AND ?", roomID, start, end).
+		Distinct("user_id").Count(&out.ActiveMembers).Error; err != nil {
+		return nil, err
+	}
+
+	var hours []roomHourRow
+	if err := s.DB.Model(&models.RoomStatHourly{}).
+		Select("hour, SUM(message_count) AS cnt").
+		Where("room_id = ? AND day BETWEEN ? AND ?", roomID, start, end).
+		Group("hour").Scan(&hours).Error; err != nil {
+		return nil, err
+	}
+	out.Hours, out.PeakHours = buildHourStats(hours)
+
+	senders, err := s.topSenders(roomID, start, end, top)
+	if err != nil {
+		return nil, err
+	}
+	out.TopSenders = senders
+	return out, nil
+}
+
+// TopSenders 发言排行（最近 days 天）
+func (s *RoomStatsService) TopSenders(roomID, operatorID uint64, days, limit int) ([]RoomSenderStat, error) {
+	if err := s.checkOwner(roomID, operatorID); err != nil {
+		return nil, err
+	}
+	_, start, end := statsRange(days, time.Now())
+	return s.topSenders(roomID, start, end, limit)
+}
+
+func (s *RoomStatsService) topSenders(roomID uint64, start, end string, limit int) ([]RoomSenderStat, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	var rows []struct {
+		UserID uint64
+		Cnt    int64
+	}
+	if err := s.DB.Model(&models.RoomStatMember{}).
+		Select("user_id, SUM(message_count) AS cnt").
+		Where("room_id = ? AND day BETWEEN ? AND ?", roomID, start, end).
+		Group("user_id").
+		Order("cnt DESC, user_id ASC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]RoomSenderStat, 0, len(rows))
+	if len(rows) == 0 {
+		return out, nil
+	}
+	ids := make([]uint64, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.UserID)
+	}
+	var users []models.User
+	if err := s.DB.Select("id", "nickname", "avatar").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	for i, r := range rows {
+		u := byID[r.UserID]
+		out = append(out, RoomSenderStat{Rank: i + 1, UserID: r.UserID, Nickname: u.Nickname, Avatar: u.Avatar, MessageCount: r.Cnt})
+	}
+	return out, nil
+}
+
+type roomHourRow struct {
+	Hour int
+	Cnt  int64
+}
+
+// buildHourStats 补齐 24 小时并找出高峰时段
+func buildHourStats(rows []roomHourRow) ([]RoomHourStat, []int) {
+	hours := make([]RoomHourStat, 24)
+	for h := range hours {
+		hours[h].Hour = h
+	}
+	var peak int64
+	for _, r := range rows {
+		if r.Hour < 0 || r.Hour > 23 {
+			continue
+		}
+		hours[r.Hour].MessageCount = r.Cnt
+		if r.Cnt > peak {
+			peak = r.Cnt
+		}
+	}
+	peaks := make([]int, 0, 1)
+	if peak > 0 {
+		for _, h := range hours {
+			if h.MessageCount == peak {
+				peaks = append(peaks, h.Hour)
+			}
+		}
+	}
+	return hours, peaks
+}
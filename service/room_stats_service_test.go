@@ -0,0 +1,65 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestBuildHourStats(t *testing.T) {
+	hours, peaks := buildHourStats([]roomHourRow{{Hour: 9, Cnt: 5}, {Hour: 21, Cnt: 5}, {Hour: 3, Cnt: 1}})
+	if len(hours) != 24 || hours[9].MessageCount != 5 || hours[0].MessageCount != 0 {
+		t.Fatalf("unexpected hours: %#v", hours)
+	}
+	if len(peaks) != 2 || peaks[0] != 9 || peaks[1] != 21 {
+		t.Fatalf("unexpected peaks: %v", peaks)
+	}
+	if _, peaks := buildHourStats(nil); len(peaks) != 0 {
+		t.Fatalf("expected no peaks, got %v", peaks)
+	}
+}
+
+func TestRoomStatsService_Record(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewRoomStatsService(&Service{DB: gormDB})
+
+	at := time.Date(2024, 5, 1, 20, 30, 0, 0, time.Local)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `im_room_stat_daily`")).
+		WithArgs(uint64(7), "2024-05-01", int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `im_room_stat_hourly`")).
+		WithArgs(uint64(7), "2024-05-01", uint8(20), int64(1), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `im_room_stat_member`")).
+		WithArgs(uint64(7), "2024-05-01", uint64(3), int64(1), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	svc.Record(&models.Message{ID: 1, RoomID: 7, SenderID: 3, CreatedAt: at})
+	// 系统消息不计入
+	svc.Record(&models.Message{ID: 2, RoomID: 7, SenderID: 3, IsSystem: true, CreatedAt: at})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRoomStatsService_OwnerOnly(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	svc := NewRoomStatsService(&Service{DB: gormDB})
+
+	mock.ExpectQuery("SELECT `role` FROM `im_room_user`").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(1))
+	if _, err := svc.GetStats(7, 3, 7, 10); err == nil {
+		t.Fatalf("expected permission error for admin")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
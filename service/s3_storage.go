@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3FileStorage S3 兼容对象存储（AWS S3、MinIO、阿里云 OSS / 腾讯云 COS 的 S3 兼容接口、Cloudflare R2 等），
+// 使用 AWS Signature V4 直接调用 PutObject / DeleteObject，不依赖 SDK。
+// 对象需可公开读取（Bucket 策略或 CDN 回源），返回的 URL 直接写库下发给客户端。
+type S3FileStorage struct {
+	// Endpoint 服务地址，如 https://s3.ap-east-1.amazonaws.com、http://minio:9000、https://oss-cn-hangzhou.aliyuncs.com
+	Endpoint string
+	// Region 签名用区域，默认 us-east-1（MinIO 默认值）
+	Region string
+	Bucket string
+
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// PathStyle 使用 Endpoint/Bucket/Key 形式（MinIO 常用）；否则为 Bucket.Endpoint/Key
+	PathStyle bool
+	// PublicURL 对外访问前缀（如 CDN 域名 https://cdn.example.com），为空时使用对象的存储地址
+	PublicURL string
+	// Prefix 对象 key 前缀（如 "chat/"）
+	Prefix string
+
+	// HTTPClient 为空时使用 30s 超时的默认客户端
+	HTTPClient *http.Client
+}
+
+func (s *S3FileStorage) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	key := s.Prefix + strings.TrimPrefix(name, "/")
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if err := s.do(ctx, http.MethodPut, key, header, data); err != nil {
+		return "", err
+	}
+	return s.publicBase() + "/" + key, nil
+}
+
+func (s *S3FileStorage) Delete(ctx context.Context, fileURL string) error {
+	key, ok := strings.CutPrefix(fileURL, s.publicBase()+"/")
+	if !ok || key == "" || !strings.HasPrefix(key, s.Prefix) {
+		return nil
+	}
+	return s.do(ctx, http.MethodDelete, key, http.Header{}, nil)
+}
+
+// objectURL 对象的存储地址（已按 SigV4 规则转义），及用于签名的 Host
+func (s *S3FileStorage) objectURL(key string) (string, string, error) {
+	u, err := url.Parse(strings.TrimRight(s.Endpoint, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid s3 endpoint %q", s.Endpoint)
+	}
+	host, path := u.Host, "/"+s3Escape(key, false)
+	if s.PathStyle {
+		path = "/" + s3Escape(s.Bucket, true) + path
+	} else {
+		host = s.Bucket + "." + host
+	}
+	return u.Scheme + "://" + host + path, host, nil
+}
+
+func (s *S3FileStorage) publicBase() string {
+	if s.PublicURL != "" {
+		return strings.TrimRight(s.PublicURL, "/")
+	}
+	base, _, err := s.objectURL("")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(base, "/")
+}
+
+func (s *S3FileStorage) do(ctx context.Context, method, key string, header http.Header, body []byte) error {
+	rawURL, host, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Host = host
+	if len(body) == 0 {
+		req.Body, req.ContentLength = nil, 0
+	}
+	s.sign(req, host, body, time.Now())
+
+	hc := s.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 || (method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s: status %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+// sign AWS Signature V4：签名 Host 及 req.Header 中已有的全部头
+func (s *S3FileStorage) sign(req *http.Request, host string, body []byte, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	values := map[string]string{"host": host}
+	for k, v := range req.Header {
+		values[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + values[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// s3Escape 按 SigV4 规则转义：只保留 A-Za-z0-9-._~，encodeSlash=false 时保留 /
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+)
+
+// IP 规则错误
+var (
+	ErrIPRuleCountry  = newError(response.CodeParamError, "err.ip_rule_country_invalid")
+	ErrIPRuleKind     = newError(response.CodeParamError, "err.ip_rule_kind_invalid")
+	ErrIPRuleNotFound = newError(response.CodeParamError, "err.ip_rule_not_found")
+)
+
+// GeoIPResolver 根据 IP 解析国家/地区代码（ISO 3166-1 alpha-2，如 "CN"/"US"），由业务方注入（如 MaxMind GeoLite2）。
+type GeoIPResolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+// SecurityService IP 访问控制：CIDR 黑白名单 + 按国家屏蔽。
+// 规则存库，内存缓存；管理接口修改后立即重载，多实例通过 RunReloadLoop 定时同步。
+// 判定顺序：
+//  1. 命中黑名单 -> 拒绝
+//  2. 存在白名单且未命中 -> 拒绝
+//  3. 命中屏蔽国家且未命中白名单 -> 拒绝
+type SecurityService struct {
+	*Service
+	GeoIP GeoIPResolver
+	// TrustProxyHeaders 为 true 时从 X-Forwarded-For / X-Real-IP 取客户端 IP（仅在可信反向代理之后开启）
+	TrustProxyHeaders bool
+
+	mu        sync.RWMutex
+	allow     []compiledIPRule
+	deny      []compiledIPRule
+	countries map[string]*compiledIPRule
+	hits      sync.Map // rule id -> *int64
+}
+
+type compiledIPRule struct {
+	rule models.IPRule
+	net  *net.IPNet
+}
+
+func NewSecurityService(s *Service) *SecurityService {
+	log.Println("NewSecurityService")
+	return &SecurityService{Service: s, countries: map[string]*compiledIPRule{}}
+}
+
+// IPRuleDTO 规则
+type IPRuleDTO struct {
+	ID        uint64    `json:"id"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Note      string    `json:"note"`
+	Enabled   bool      `json:"enabled"`
+	Hits      int64     `json:"hits"` // 本实例启动以来的拦截/放行次数
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SecurityDecision 判定结果
+type SecurityDecision struct {
+	Allowed bool
+	Rule    *models.IPRule // 起决定作用的规则（白名单未命中时为 nil）
+	Reason  string
+}
+
+// parseCIDR 支持 CIDR 和单个 IP
+func parseCIDR(v string) (*net.IPNet, error) {
+	v = strings.TrimSpace(v)
+	if !strings.Contains(v, "/") {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, newError(response.CodeParamError, "err.ip_rule_cidr_invalid", v)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(v)
+	if err != nil {
+		return nil, newError(response.CodeParamError, "err.ip_rule_cidr_invalid", v)
+	}
+	return n, nil
+}
+
+// Reload 从库里重新加载启用的规则
+func (s *SecurityService) Reload() error {
+	var rules []models.IPRule
+	if err := s.DB.Where("enabled = ?", true).Order("id ASC").Find(&rules).Error; err != nil {
+		return err
+	}
+	s.load(rules)
+	return nil
+}
+
+func (s *SecurityService) load(rules []models.IPRule) {
+	var allow, deny []compiledIPRule
+	countries := make(map[string]*compiledIPRule)
+	for _, r := range rules {
+		switch r.Kind {
+		case models.IPRuleAllow, models.IPRuleDeny:
+			n, err := parseCIDR(r.Value)
+			if err != nil {
+				log.Printf("security: skip rule %d: %v", r.ID, err)
+				continue
+			}
+			if r.Kind == models.IPRuleAllow {
+				allow = append(allow, compiledIPRule{rule: r, net: n})
+			} else {
+				deny = append(deny, compiledIPRule{rule: r, net: n})
+			}
+		case models.IPRuleCountry:
+			cr := compiledIPRule{rule: r}
+			countries[strings.ToUpper(strings.TrimSpace(r.Value))] = &cr
+		}
+	}
+	s.mu.Lock()
+	s.allow, s.deny, s.countries = allow, deny, countries
+	s.mu.Unlock()
+}
+
+// RunReloadLoop 定时重载规则（多实例部署时同步其他实例的修改）
+func (s *SecurityService) RunReloadLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.Reload(); err != nil {
+			log.Printf("security reload loop: %v", err)
+		}
+	}
+}
+
+func (s *SecurityService) hit(id uint64) {
+	v, _ := s.hits.LoadOrStore(id, new(int64))
+	atomic.AddInt64(v.(*int64), 1)
+}
+
+func (s *SecurityService) hitCount(id uint64) int64 {
+	if v, ok := s.hits.Load(id); ok {
+		return atomic.LoadInt64(v.(*int64))
+	}
+	return 0
+}
+
+// Evaluate 判定 IP 是否允许访问
+func (s *SecurityService) Evaluate(ip net.IP) SecurityDecision {
+	if ip == nil {
+		return SecurityDecision{Allowed: true}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.deny {
+		if s.deny[i].net.Contains(ip) {
+			return SecurityDecision{Allowed: false, Rule: &s.deny[i].rule, Reason: "deny"}
+		}
+	}
+	var allowed *models.IPRule
+	for i := range s.allow {
+		if s.allow[i].net.Contains(ip) {
+			allowed = &s.allow[i].rule
+			break
+		}
+	}
+	if len(s.allow) > 0 && allowed == nil {
+		return SecurityDecision{Allowed: false, Reason: "not_in_allowlist"}
+	}
+	if allowed == nil && len(s.countries) > 0 && s.GeoIP != nil {
+		country, err := s.GeoIP.Country(ip)
+		if err == nil {
+			if cr := s.countries[strings.ToUpper(country)]; cr != nil {
+				return SecurityDecision{Allowed: false, Rule: &cr.rule, Reason: "country"}
+			}
+		}
+	}
+	return SecurityDecision{Allowed: true, Rule: allowed}
+}
+
+// ClientIP 从 http.Request 取客户端 IP（Gin 场景请优先用 ctx.ClientIP()）
+func (s *SecurityService) ClientIP(r *http.Request) net.IP {
+	if s.TrustProxyHeaders {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip
+			}
+		}
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// Check 判定并记录日志/命中次数，返回 false 表示应拒绝。
+func (s *SecurityService) Check(ip net.IP, path string) bool {
+	d := s.Evaluate(ip)
+	if d.Rule != nil {
+		s.hit(d.Rule.ID)
+	}
+	if !d.Allowed {
+		if d.Rule != nil {
+			log.Printf("security: blocked ip=%s path=%s rule=%d(%s %s)", ip, path, d.Rule.ID, d.Rule.Kind, d.Rule.Value)
+		} else {
+			log.Printf("security: blocked ip=%s path=%s reason=%s", ip, path, d.Reason)
+		}
+	}
+	return d.Allowed
+}
+
+// AllowRequest net/http 场景（如 WS 升级）的判定
+func (s *SecurityService) AllowRequest(r *http.Request) bool {
+	return s.Check(s.ClientIP(r), r.URL.Path)
+}
+
+// AddRule 新增规则
+func (s *SecurityService) AddRule(kind, value, note string) (*IPRuleDTO, error) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	value = strings.TrimSpace(value)
+	switch kind {
+	case models.IPRuleAllow, models.IPRuleDeny:
+		n, err := parseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		value = n.String()
+	case models.IPRuleCountry:
+		value = strings.ToUpper(value)
+		if len(value) != 2 {
+			return nil, ErrIPRuleCountry
+		}
+	default:
+		return nil, ErrIPRuleKind
+	}
+	rule := &models.IPRule{Kind: kind, Value: value, Note: strings.TrimSpace(note), Enabled: true}
+	if err := s.DB.Create(rule).Error; err != nil {
+		return nil, err
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	dto := s.toDTO(rule)
+	return &dto, nil
+}
+
+// DeleteRule 删除规则
+func (s *SecurityService) DeleteRule(id uint64) error {
+	res := s.DB.Where("id = ?", id).Delete(&models.IPRule{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrIPRuleNotFound
+	}
+	return s.Reload()
+}
+
+// ListRules 规则列表
+func (s *SecurityService) ListRules() ([]IPRuleDTO, error) {
+	var rules []models.IPRule
+	if err := s.DB.Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	out := make([]IPRuleDTO, 0, len(rules))
+	for i := range rules {
+		out = append(out, s.toDTO(&rules[i]))
+	}
+	return out, nil
+}
+
+func (s *SecurityService) toDTO(r *models.IPRule) IPRuleDTO {
+	return IPRuleDTO{
+		ID:        r.ID,
+		Kind:      r.Kind,
+		Value:     r.Value,
+		Note:      r.Note,
+		Enabled:   r.Enabled,
+		Hits:      s.hitCount(r.ID),
+		CreatedAt: r.CreatedAt,
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cydxin/chat-sdk/models"
+)
+
+type fakeGeoIP map[string]string
+
+func (f fakeGeoIP) Country(ip net.IP) (string, error) {
+	if c, ok := f[ip.String()]; ok {
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown")
+}
+
+func TestSecurityService_Evaluate(t *testing.T) {
+	svc := NewSecurityService(&Service{})
+	svc.GeoIP = fakeGeoIP{"8.8.8.8": "us", "1.1.1.1": "CN"}
+
+	// 无规则全部放行
+	if d := svc.Evaluate(net.ParseIP("8.8.8.8")); !d.Allowed {
+		t.Fatalf("empty rules should allow")
+	}
+
+	svc.load([]models.IPRule{
+		{ID: 1, Kind: models.IPRuleDeny, Value: "10.0.0.0/8"},
+		{ID: 2, Kind: models.IPRuleCountry, Value: "US"},
+	})
+	cases := []struct {
+		ip      string
+		allowed bool
+		ruleID  uint64
+	}{
+		{"10.1.2.3", false, 1},
+		{"8.8.8.8", false, 2},
+		{"1.1.1.1", true, 0},
+		{"192.168.1.1", true, 0},
+	}
+	for _, c := range cases {
+		d := svc.Evaluate(net.ParseIP(c.ip))
+		if d.Allowed != c.allowed {
+			t.Fatalf("%s: allowed=%v want %v", c.ip, d.Allowed, c.allowed)
+		}
+		if c.ruleID != 0 && (d.Rule == nil || d.Rule.ID != c.ruleID) {
+			t.Fatalf("%s: rule=%v want %d", c.ip, d.Rule, c.ruleID)
+		}
+	}
+
+	// 配置白名单后，只有白名单 IP 可访问；白名单优先于国家屏蔽，但不覆盖黑名单
+	svc.load([]models.IPRule{
+		{ID: 1, Kind: models.IPRuleDeny, Value: "10.0.0.1"},
+		{ID: 2, Kind: models.IPRuleCountry, Value: "US"},
+		{ID: 3, Kind: models.IPRuleAllow, Value: "10.0.0.0/8"},
+		{ID: 4, Kind: models.IPRuleAllow, Value: "8.8.8.8"},
+	})
+	if d := svc.Evaluate(net.ParseIP("10.0.0.1")); d.Allowed {
+		t.Fatalf("deny should win over allow")
+	}
+	if d := svc.Evaluate(net.ParseIP("10.0.0.2")); !d.Allowed || d.Rule.ID != 3 {
+		t.Fatalf("allowlisted ip should pass: %+v", d)
+	}
+	if d := svc.Evaluate(net.ParseIP("8.8.8.8")); !d.Allowed {
+		t.Fatalf("allowlisted ip should bypass country block")
+	}
+	if d := svc.Evaluate(net.ParseIP("1.1.1.1")); d.Allowed || d.Reason != "not_in_allowlist" {
+		t.Fatalf("ip outside allowlist should be denied: %+v", d)
+	}
+
+	if svc.Check(net.ParseIP("10.0.0.1"), "/x") {
+		t.Fatalf("check should deny")
+	}
+	if got := svc.hitCount(1); got != 1 {
+		t.Fatalf("hits=%d want 1", got)
+	}
+}
+
+func TestSecurityService_ClientIP(t *testing.T) {
+	svc := NewSecurityService(&Service{})
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.RemoteAddr = "203.0.113.5:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.5")
+
+	if ip := svc.ClientIP(r); ip.String() != "203.0.113.5" {
+		t.Fatalf("untrusted proxy headers should be ignored: %s", ip)
+	}
+	svc.TrustProxyHeaders = true
+	if ip := svc.ClientIP(r); ip.String() != "198.51.100.7" {
+		t.Fatalf("want forwarded ip, got %s", ip)
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	n, err := parseCIDR("192.168.1.9")
+	if err != nil || n.String() != "192.168.1.9/32" {
+		t.Fatalf("single ip: %v %v", n, err)
+	}
+	if _, err := parseCIDR("bad/99"); err == nil {
+		t.Fatalf("want error")
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ServerStatsService 全局运营统计（需要 Redis）：
+// - 消息量：按分钟/小时分桶计数
+// - DAU/MAU：HyperLogLog 去重计数
+//
+// Redis Key:
+// - im:stats:msg:m:{200601021504}  分钟桶，TTL 2 小时
+// - im:stats:msg:h:{2006010215}    小时桶，TTL 26 小时
+// - im:stats:dau:{20060102}        HLL，TTL 35 天
+// - im:stats:mau:{200601}          HLL，TTL 62 天
+type ServerStatsService struct {
+	*Service
+}
+
+func NewServerStatsService(s *Service) *ServerStatsService {
+	log.Println("NewServerStatsService")
+	return &ServerStatsService{Service: s}
+}
+
+// ServerStatsDTO 全局统计
+type ServerStatsDTO struct {
+	Connections        int   `json:"connections"`  // 当前 WS 连接数
+	OnlineUsers        int   `json:"online_users"` // 当前在线用户数（去重）
+	MessagesLastMinute int64 `json:"messages_last_minute"`
+	MessagesLastHour   int64 `json:"messages_last_hour"`
+	MessagesLastDay    int64 `json:"messages_last_day"`
+	DAU                int64 `json:"dau"`
+	MAU                int64 `json:"mau"`
+	GeneratedAt        int64 `json:"generated_at"`
+}
+
+func statsMinuteKey(t time.Time) string { return "im:stats:msg:m:" + t.Format("200601021504") }
+func statsHourKey(t time.Time) string   { return "im:stats:msg:h:" + t.Format("2006010215") }
+func statsDAUKey(t time.Time) string    { return "im:stats:dau:" + t.Format("20060102") }
+func statsMAUKey(t time.Time) string    { return "im:stats:mau:" + t.Format("200601") }
+
+// RecordMessage 消息计数（尽力而为，失败只打日志）
+func (s *ServerStatsService) RecordMessage(ctx context.Context, at time.Time) {
+	if s.RDB == nil {
+		return
+	}
+	mk, hk := statsMinuteKey(at), statsHourKey(at)
+	pipe := s.RDB.Pipeline()
+	pipe.Incr(ctx, mk)
+	pipe.Expire(ctx, mk, 2*time.Hour)
+	pipe.Incr(ctx, hk)
+	pipe.Expire(ctx, hk, 26*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("ServerStatsService.RecordMessage failed: %v", err)
+	}
+}
+
+// RecordActive 记录活跃用户（DAU/MAU）
+func (s *ServerStatsService) RecordActive(ctx context.Context, userID uint64, at time.Time) {
+	if s.RDB == nil || userID == 0 {
+		return
+	}
+	member := strconv.FormatUint(userID, 10)
+	dk, mk := statsDAUKey(at), statsMAUKey(at)
+	pipe := s.RDB.Pipeline()
+	pipe.PFAdd(ctx, dk, member)
+	pipe.Expire(ctx, dk, 35*24*time.Hour)
+	pipe.PFAdd(ctx, mk, member)
+	pipe.Expire(ctx, mk, 62*24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("ServerStatsService.RecordActive failed: %v", err)
+	}
+}
+
+// Snapshot 读取 Redis 中的消息量与 DAU/MAU（连接数由调用方填充）。
+// 最近一分钟按滑动窗口估算：当前分钟桶 + 上一分钟桶 * 未过去的比例。
+func (s *ServerStatsService) Snapshot(ctx context.Context, now time.Time) (*ServerStatsDTO, error) {
+	if s.RDB == nil {
+		return nil, fmt.Errorf("r 服务暂未开启")
+	}
+	minuteKeys := make([]string, 0, 60)
+	for i := 0; i < 60; i++ {
+		minuteKeys = append(minuteKeys, statsMinuteKey(now.Add(-time.Duration(i)*time.Minute)))
+	}
+	hourKeys := make([]string, 0, 24)
+	for i := 0; i < 24; i++ {
+		hourKeys = append(hourKeys, statsHourKey(now.Add(-time.Duration(i)*time.Hour)))
+	}
+
+	// 逐 key GET 而非 MGET：Cluster 模式下各桶分属不同 slot，MGET 会报 CROSSSLOT
+	pipe := s.RDB.Pipeline()
+	mins := pipelineGets(ctx, pipe, minuteKeys)
+	hours := pipelineGets(ctx, pipe, hourKeys)
+	dau := pipe.PFCount(ctx, statsDAUKey(now))
+	mau := pipe.PFCount(ctx, statsMAUKey(now))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	minVals := sumCounters(mins)
+	out := &ServerStatsDTO{
+		MessagesLastHour: sumInt64(minVals),
+		MessagesLastDay:  sumInt64(sumCounters(hours)),
+		DAU:              dau.Val(),
+		MAU:              mau.Val(),
+		GeneratedAt:      now.Unix(),
+	}
+	elapsed := float64(now.Second()) / 60
+	out.MessagesLastMinute = minVals[0] + int64(float64(minVals[1])*(1-elapsed))
+	return out, nil
+}
+
+func pipelineGets(ctx context.Context, pipe redis.Pipeliner, keys []string) []*redis.StringCmd {
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, k := range keys {
+		cmds[i] = pipe.Get(ctx, k)
+	}
+	return cmds
+}
+
+// sumCounters 把 GET 结果转换成整数（不存在的 key 记 0）
+func sumCounters(cmds []*redis.StringCmd) []int64 {
+	out := make([]int64, len(cmds))
+	for i, c := range cmds {
+		out[i], _ = strconv.ParseInt(c.Val(), 10, 64)
+	}
+	return out
+}
+
+func sumInt64(vals []int64) int64 {
+	var n int64
+	for _, v := range vals {
+		n += v
+	}
+	return n
+}
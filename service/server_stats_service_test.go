@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestServerStatsService_Snapshot(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	svc := NewServerStatsService(&Service{RDB: rdb})
+	ctx := context.Background()
+
+	now := time.Date(2024, 6, 15, 12, 30, 0, 0, time.Local) // 分钟刚开始：上一分钟桶全部计入
+	svc.RecordMessage(ctx, now)
+	svc.RecordMessage(ctx, now)
+	svc.RecordMessage(ctx, now.Add(-time.Minute))
+	svc.RecordMessage(ctx, now.Add(-30*time.Minute))
+	svc.RecordMessage(ctx, now.Add(-5*time.Hour))
+	svc.RecordMessage(ctx, now.Add(-25*time.Hour)) // 超出 1 天
+
+	svc.RecordActive(ctx, 1, now)
+	svc.RecordActive(ctx, 1, now)
+	svc.RecordActive(ctx, 2, now)
+	svc.RecordActive(ctx, 3, now.AddDate(0, 0, -1)) // 同月不同天
+
+	st, err := svc.Snapshot(ctx, now)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if st.MessagesLastMinute != 3 || st.MessagesLastHour != 4 || st.MessagesLastDay != 5 {
+		t.Fatalf("unexpected message counts: %#v", st)
+	}
+	if st.DAU != 2 || st.MAU != 3 {
+		t.Fatalf("unexpected dau/mau: %#v", st)
+	}
+}
@@ -21,28 +21,5 @@ func (s *SessionBootstrapService) GetVisibleConversationLastReads(userID uint64)
 		return map[uint64]uint64{}, nil
 	}
 
-	type row struct {
-		RoomID        uint64
-		LastReadMsgID *uint64
-	}
-	var rows []row
-	if err := s.DB.Model(&models.Conversation{}).
-		Select("room_id, last_read_msg_id").
-		Where("user_id = ? AND is_visible = ?", userID, true).
-		Find(&rows).Error; err != nil {
-		return nil, err
-	}
-
-	out := make(map[uint64]uint64, len(rows))
-	for _, r := range rows {
-		if r.RoomID == 0 {
-			continue
-		}
-		if r.LastReadMsgID == nil {
-			out[r.RoomID] = 0
-			continue
-		}
-		out[r.RoomID] = *r.LastReadMsgID
-	}
-	return out, nil
+	return models.NewConversationDAO(s.DB).FindLastReads(userID)
 }
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SystemMessageService 把入群/踢人/退群/改群昵称/禁言/群资料变更写成 is_system 消息，
+// 聊天记录里统一展示“X 加入了群聊”；extra.system 携带结构化字段，客户端可按 event 本地化。
+type SystemMessageService struct {
+	*Service
+	messageService *MessageService
+}
+
+func NewSystemMessageService(s *Service) *SystemMessageService {
+	log.Println("NewSystemMessageService")
+	return &SystemMessageService{Service: s, messageService: NewMessageService(s)}
+}
+
+// Post 写入一条系统消息并推送给房间成员（尽力而为：失败只记日志，不影响已完成的变更）
+func (s *SystemMessageService) Post(roomID uint64, info message.SystemInfo) *models.Message {
+	if s == nil {
+		return nil
+	}
+	msg, err := recordSystemMessage(s.DB, s.messageService, roomID, info, nil)
+	if err != nil {
+		log.Printf("system message failed: room=%d event=%s err=%v", roomID, info.Event, err)
+		return nil
+	}
+	if s.RoomMessagePusher != nil {
+		s.RoomMessagePusher(msg)
+	}
+	return msg
+}
+
+// recordSystemMessage 在 db（可为事务）中写入系统消息，不推送。
+// names 为已查好的 user_id -> 昵称，缺失的会补查。
+func recordSystemMessage(db *gorm.DB, ms *MessageService, roomID uint64, info message.SystemInfo, names map[uint64]string) (*models.Message, error) {
+	ids := append([]uint64{info.ActorID}, info.TargetIDs...)
+	var miss []uint64
+	for _, id := range ids {
+		if _, ok := names[id]; !ok && id != 0 {
+			miss = append(miss, id)
+		}
+	}
+	if len(miss) > 0 {
+		var users []models.User
+		if err := db.Model(&models.User{}).Select("id, nickname").Where("id IN ?", miss).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		merged := make(map[uint64]string, len(names)+len(users))
+		for k, v := range names {
+			merged[k] = v
+		}
+		for _, u := range users {
+			merged[u.ID] = u.Nickname
+		}
+		names = merged
+	}
+
+	extra, err := json.Marshal(message.Extra{System: &info})
+	if err != nil {
+		return nil, err
+	}
+	msg := &models.Message{
+		RoomID:   roomID,
+		SenderID: info.ActorID,
+		Type:     1,
+		Content:  renderSystemContent(info, names),
+		IsSystem: true,
+		Status:   models.MessageStatusSent,
+		Extra:    datatypes.JSON(extra),
+	}
+	if err := ms.persistMessage(context.Background(), db, msg, persistOptions{skipChecks: true}); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// renderSystemContent 系统消息默认中文文案
+func renderSystemContent(info message.SystemInfo, names map[uint64]string) string {
+	actor := names[info.ActorID]
+	targets := make([]string, 0, len(info.TargetIDs))
+	for _, id := range info.TargetIDs {
+		targets = append(targets, names[id])
+	}
+	target := strings.Join(targets, "、")
+	p := info.Params
+
+	switch info.Event {
+	case EventRoomMemberAdded:
+		if info.ActorID == 0 && len(info.TargetIDs) > 0 {
+			return fmt.Sprintf("%s 加入了群聊", target)
+		}
+		if len(info.TargetIDs) == 0 || (len(info.TargetIDs) == 1 && info.TargetIDs[0] == info.ActorID) {
+			return fmt.Sprintf("%s 加入了群聊", actor)
+		}
+		return fmt.Sprintf("%s 邀请 %s 加入了群聊", actor, target)
+	case EventRoomMemberRemoved:
+		if info.ActorID == 0 {
+			return fmt.Sprintf("%s 已被移出群聊", target)
+		}
+		return fmt.Sprintf("%s 将 %s 移出了群聊", actor, target)
+	case EventRoomMemberQuit:
+		return fmt.Sprintf("%s 退出了群聊", actor)
+	case EventRoomMemberNickname:
+		if p["nickname"] == "" {
+			return fmt.Sprintf("%s 清除了群昵称", actor)
+		}
+		return fmt.Sprintf("%s 将群昵称修改为“%s”", actor, p["nickname"])
+	case EventRoomUserMute:
+		if p["duration_minutes"] == "" || p["duration_minutes"] == "0" {
+			return fmt.Sprintf("%s 解除了 %s 的禁言", actor, target)
+		}
+		return fmt.Sprintf("%s 将 %s 禁言 %s 分钟", actor, target, p["duration_minutes"])
+	case EventRoomGroupMuteCountdown:
+		if p["duration_minutes"] == "" || p["duration_minutes"] == "0" {
+			return fmt.Sprintf("%s 关闭了全员禁言", actor)
+		}
+		return fmt.Sprintf("%s 开启了全员禁言 %s 分钟", actor, p["duration_minutes"])
+	case EventRoomGroupMuteScheduled:
+		if p["duration_minutes"] == "" || p["duration_minutes"] == "0" {
+			return fmt.Sprintf("%s 取消了每日定时禁言", actor)
+		}
+		return fmt.Sprintf("%s 设置了每日 %s 起禁言 %s 分钟", actor, p["start_time"], p["duration_minutes"])
+	case EventRoomGroupMuteWindow:
+		if p["active"] == "true" {
+			return fmt.Sprintf("全员定时禁言开始，%s 结束", p["until"])
+		}
+		return "全员定时禁言已结束"
+	case EventRoomMuteAdmins:
+		if p["mute_admins"] == "true" {
+			return fmt.Sprintf("%s 设置全员禁言对管理员生效", actor)
+		}
+		return fmt.Sprintf("%s 设置全员禁言时管理员可以发言", actor)
+	case EventRoomMuteExempt:
+		if p["exempt"] == "true" {
+			return fmt.Sprintf("%s 允许 %s 在全员禁言时发言", actor, target)
+		}
+		return fmt.Sprintf("%s 取消了 %s 的禁言豁免", actor, target)
+	case EventRoomAdminSet:
+		if p["is_admin"] == "true" {
+			return fmt.Sprintf("%s 将 %s 设为管理员", actor, target)
+		}
+		return fmt.Sprintf("%s 取消了 %s 的管理员", actor, target)
+	case EventRoomDisappearing:
+		secs, _ := strconv.ParseInt(p["ttl_seconds"], 10, 64)
+		if secs <= 0 {
+			return fmt.Sprintf("%s 关闭了消息定时删除", actor)
+		}
+		return fmt.Sprintf("%s 开启了消息定时删除，新消息将在%s后删除", actor, formatTTL(time.Duration(secs)*time.Second))
+	case EventRoomGroupInfoUpdated:
+		var parts []string
+		if p["name"] != "" {
+			parts = append(parts, fmt.Sprintf("修改群名为“%s”", p["name"]))
+		}
+		if p["avatar"] != "" {
+			parts = append(parts, "更换了群头像")
+		}
+		if len(parts) == 0 {
+			return fmt.Sprintf("%s 修改了群资料", actor)
+		}
+		return fmt.Sprintf("%s %s", actor, strings.Join(parts, "，"))
+	}
+	return fmt.Sprintf("%s 更新了群设置", actor)
+}
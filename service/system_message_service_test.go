@@ -0,0 +1,79 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestRenderSystemContent(t *testing.T) {
+	names := map[uint64]string{1: "A", 2: "B", 3: "C"}
+	cases := []struct {
+		info message.SystemInfo
+		want string
+	}{
+		{message.SystemInfo{Event: EventRoomMemberAdded, ActorID: 1, TargetIDs: []uint64{2, 3}}, "A 邀请 B、C 加入了群聊"},
+		{message.SystemInfo{Event: EventRoomMemberAdded, ActorID: 2, TargetIDs: []uint64{2}}, "B 加入了群聊"},
+		{message.SystemInfo{Event: EventRoomMemberRemoved, ActorID: 1, TargetIDs: []uint64{2}}, "A 将 B 移出了群聊"},
+		{message.SystemInfo{Event: EventRoomMemberAdded, TargetIDs: []uint64{2, 3}}, "B、C 加入了群聊"},
+		{message.SystemInfo{Event: EventRoomMemberRemoved, TargetIDs: []uint64{2}}, "B 已被移出群聊"},
+		{message.SystemInfo{Event: EventRoomMemberQuit, ActorID: 3}, "C 退出了群聊"},
+		{message.SystemInfo{Event: EventRoomMemberNickname, ActorID: 2, Params: map[string]string{"nickname": "小B"}}, "B 将群昵称修改为“小B”"},
+		{message.SystemInfo{Event: EventRoomUserMute, ActorID: 1, TargetIDs: []uint64{2}, Params: map[string]string{"duration_minutes": "10"}}, "A 将 B 禁言 10 分钟"},
+		{message.SystemInfo{Event: EventRoomUserMute, ActorID: 1, TargetIDs: []uint64{2}, Params: map[string]string{"duration_minutes": "0"}}, "A 解除了 B 的禁言"},
+		{message.SystemInfo{Event: EventRoomGroupMuteCountdown, ActorID: 1, Params: map[string]string{"duration_minutes": "0"}}, "A 关闭了全员禁言"},
+		{message.SystemInfo{Event: EventRoomGroupMuteWindow, Params: map[string]string{"active": "true", "until": "06:00"}}, "全员定时禁言开始，06:00 结束"},
+		{message.SystemInfo{Event: EventRoomGroupMuteWindow, Params: map[string]string{"active": "false"}}, "全员定时禁言已结束"},
+		{message.SystemInfo{Event: EventRoomMuteAdmins, ActorID: 1, Params: map[string]string{"mute_admins": "true"}}, "A 设置全员禁言对管理员生效"},
+		{message.SystemInfo{Event: EventRoomMuteExempt, ActorID: 1, TargetIDs: []uint64{2, 3}, Params: map[string]string{"exempt": "true"}}, "A 允许 B、C 在全员禁言时发言"},
+		{message.SystemInfo{Event: EventRoomGroupInfoUpdated, ActorID: 1, Params: map[string]string{"name": "新群", "avatar": "x.png"}}, "A 修改群名为“新群”，更换了群头像"},
+	}
+	for _, c := range cases {
+		if got := renderSystemContent(c.info, names); got != c.want {
+			t.Errorf("%s: got %q want %q", c.info.Event, got, c.want)
+		}
+	}
+}
+
+func TestSystemMessageService_Post(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	var pushed *models.Message
+	base := &Service{DB: gormDB, RoomMessagePusher: func(msg *models.Message) { pushed = msg }}
+	svc := NewSystemMessageService(base)
+
+	mock.ExpectQuery("SELECT id, nickname FROM `im_user` WHERE id IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname"}).AddRow(3, "C"))
+	// 分配序号到会话投影在同一事务内
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_room` SET `message_seq`=message_seq \\+ \\? WHERE id = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT `message_seq` FROM `im_room` WHERE id = \\?").WillReturnRows(sqlmock.NewRows([]string{"message_seq"}).AddRow(3))
+	mock.ExpectExec("INSERT INTO `im_message`").
+		WithArgs(5, 3, 3, nil, 1, "C 退出了群聊", sqlmock.AnyArg(), true, false, false, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(50, 1))
+	mock.ExpectExec("UPDATE `im_room` SET `last_message_id`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `im_conversation` SET").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	msg := svc.Post(5, message.SystemInfo{Event: EventRoomMemberQuit, ActorID: 3})
+	if msg == nil || pushed != msg || !msg.IsSystem || msg.Seq != 3 {
+		t.Fatalf("msg=%+v pushed=%+v", msg, pushed)
+	}
+	var extra message.Extra
+	if err := json.Unmarshal(msg.Extra, &extra); err != nil || extra.System == nil || extra.System.Event != EventRoomMemberQuit || extra.System.ActorID != 3 {
+		t.Fatalf("extra=%s", msg.Extra)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+
+	// 未注入时为 no-op
+	var nilSvc *SystemMessageService
+	if nilSvc.Post(5, message.SystemInfo{Event: EventRoomMemberQuit, ActorID: 3}) != nil {
+		t.Fatal("nil service should not write")
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
@@ -17,9 +19,11 @@ const (
 )
 
 // TokenService 专门负责 token 的生成、存储、校验与注销。
-// Redis Key 设计：
+// 存储走 KVStore（默认 Redis，也可注入内存实现），Key 设计：
 // - im:token:{token} -> userID (String, TTL)
 // - im:user_tokens:{userID} -> Set(token1, token2, ...) (Set, 可选 TTL)
+// - im:token_meta:{token} -> JSON(TokenMeta)（登录设备等，可选，TTL 与 tokenKey 一致）
+// - im:device_tokens:{deviceID} -> Set(token1, ...)（同一设备上登录的多个账号，失效 token 在读取时清理）
 //
 // 这样可以：
 // - 单 token 注销：DEL tokenKey + SREM userSet
@@ -27,16 +31,22 @@ const (
 // - 支持多端登录/多 token
 // - 可选做单点登录：登录时先 RevokeAllTokensByUser
 type TokenService struct {
-	rdb *redis.Client
+	kv KVStore
 }
 
-func NewTokenService(rdb *redis.Client) *TokenService {
-	return &TokenService{rdb: rdb}
+// NewTokenService 使用 Redis 存储（rdb 为 nil 时各方法返回错误）
+func NewTokenService(rdb redis.UniversalClient) *TokenService {
+	return NewTokenServiceWithKV(kvFromRedis(rdb))
+}
+
+// NewTokenServiceWithKV 使用任意 KVStore 存储
+func NewTokenServiceWithKV(kv KVStore) *TokenService {
+	return &TokenService{kv: kv}
 }
 
 func (s *TokenService) ensure() error {
-	if s == nil || s.rdb == nil {
-		return fmt.Errorf("redis client is nil")
+	if s == nil || s.kv == nil {
+		return fmt.Errorf("token kv store is nil")
 	}
 	return nil
 }
@@ -45,6 +55,14 @@ func (s *TokenService) tokenKey(token string) string {
 	return "im:token:" + token
 }
 
+func (s *TokenService) tokenMetaKey(token string) string {
+	return "im:token_meta:" + token
+}
+
+func (s *TokenService) deviceTokensKey(deviceID string) string {
+	return "im:device_tokens:" + deviceID
+}
+
 func (s *TokenService) userTokensKey(userID uint64) string {
 	return fmt.Sprintf("im:user_tokens:%d", userID)
 }
@@ -67,13 +85,14 @@ func (s *TokenService) StoreToken(ctx context.Context, token string, userID uint
 		ttl = defaultTokenTTL
 	}
 
-	pipe := s.rdb.TxPipeline()
-	pipe.Set(ctx, s.tokenKey(token), fmt.Sprintf("%d", userID), ttl)
-	pipe.SAdd(ctx, s.userTokensKey(userID), token)
+	if err := s.kv.Set(ctx, s.tokenKey(token), fmt.Sprintf("%d", userID), ttl); err != nil {
+		return err
+	}
+	if err := s.kv.SAdd(ctx, s.userTokensKey(userID), token); err != nil {
+		return err
+	}
 	// user token set 的 TTL 不是必须；这里设置为略大于 token TTL，方便自动清理
-	pipe.Expire(ctx, s.userTokensKey(userID), ttl+24*time.Hour)
-	_, err := pipe.Exec(ctx)
-	return err
+	return s.kv.Expire(ctx, s.userTokensKey(userID), ttl+24*time.Hour)
 }
 
 // RefreshTokenTTL 对 token 续期（同时延长 user token set TTL）。
@@ -90,11 +109,93 @@ func (s *TokenService) RefreshTokenTTL(ctx context.Context, token string, ttl ti
 		return err
 	}
 
-	pipe := s.rdb.TxPipeline()
-	pipe.Expire(ctx, s.tokenKey(token), ttl)
-	pipe.Expire(ctx, s.userTokensKey(uid), ttl+24*time.Hour)
-	_, err = pipe.Exec(ctx)
-	return err
+	if err := s.kv.Expire(ctx, s.tokenKey(token), ttl); err != nil {
+		return err
+	}
+	_ = s.kv.Expire(ctx, s.tokenMetaKey(token), ttl)
+	return s.kv.Expire(ctx, s.userTokensKey(uid), ttl+24*time.Hour)
+}
+
+// TokenMeta token 签发时的附加信息（登录设备、IP 等）
+type TokenMeta struct {
+	// DeviceID 客户端生成的设备标识，同一设备上多个账号的 token 据此关联（账号切换）
+	DeviceID  string    `json:"device_id,omitempty"`
+	Device    string    `json:"device,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StoreTokenMeta 保存 token 的附加信息，ttl 应与 token 一致
+func (s *TokenService) StoreTokenMeta(ctx context.Context, token string, meta TokenMeta, ttl time.Duration) error {
+	if err := s.ensure(); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := s.kv.Set(ctx, s.tokenMetaKey(token), string(b), ttl); err != nil {
+		return err
+	}
+	if meta.DeviceID == "" {
+		return nil
+	}
+	if err := s.kv.SAdd(ctx, s.deviceTokensKey(meta.DeviceID), token); err != nil {
+		return err
+	}
+	return s.kv.Expire(ctx, s.deviceTokensKey(meta.DeviceID), ttl+24*time.Hour)
+}
+
+// ListDeviceTokens 列出设备上仍有效的 token，顺带从集合中移除已失效的
+func (s *TokenService) ListDeviceTokens(ctx context.Context, deviceID string) ([]string, error) {
+	if err := s.ensure(); err != nil {
+		return nil, err
+	}
+	tokens, err := s.kv.SMembers(ctx, s.deviceTokensKey(deviceID))
+	if err != nil {
+		if errors.Is(err, ErrKVNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out := tokens[:0]
+	for _, t := range tokens {
+		if _, err := s.kv.Get(ctx, s.tokenKey(t)); errors.Is(err, ErrKVNotFound) {
+			_ = s.kv.SRem(ctx, s.deviceTokensKey(deviceID), t)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// GetTokenMeta 读取 token 的附加信息，未保存过时返回 ErrKVNotFound
+func (s *TokenService) GetTokenMeta(ctx context.Context, token string) (*TokenMeta, error) {
+	if err := s.ensure(); err != nil {
+		return nil, err
+	}
+	val, err := s.kv.Get(ctx, s.tokenMetaKey(token))
+	if err != nil {
+		return nil, err
+	}
+	var meta TokenMeta
+	if err := json.Unmarshal([]byte(val), &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// TokenTTL token 剩余有效期（<=0 表示不存在或未设置过期）
+func (s *TokenService) TokenTTL(ctx context.Context, token string) (time.Duration, error) {
+	if err := s.ensure(); err != nil {
+		return 0, err
+	}
+	return s.kv.TTL(ctx, s.tokenKey(token))
 }
 
 // GetUserIDByToken 根据 token 取 userID。
@@ -102,7 +203,7 @@ func (s *TokenService) GetUserIDByToken(ctx context.Context, token string) (uint
 	if err := s.ensure(); err != nil {
 		return 0, err
 	}
-	val, err := s.rdb.Get(ctx, s.tokenKey(token)).Result()
+	val, err := s.kv.Get(ctx, s.tokenKey(token))
 	if err != nil {
 		return 0, err
 	}
@@ -118,7 +219,7 @@ func (s *TokenService) RevokeToken(ctx context.Context, token string) error {
 	if err := s.ensure(); err != nil {
 		return err
 	}
-	return s.rdb.Del(ctx, s.tokenKey(token)).Err()
+	return s.kv.Del(ctx, s.tokenKey(token), s.tokenMetaKey(token))
 }
 
 // AddUserToken 将 token 加入 user 的 token 集合。
@@ -126,7 +227,7 @@ func (s *TokenService) AddUserToken(ctx context.Context, userID uint64, token st
 	if err := s.ensure(); err != nil {
 		return err
 	}
-	return s.rdb.SAdd(ctx, s.userTokensKey(userID), token).Err()
+	return s.kv.SAdd(ctx, s.userTokensKey(userID), token)
 }
 
 // RemoveUserToken 从 user 的 token 集合中移除 token。
@@ -134,7 +235,7 @@ func (s *TokenService) RemoveUserToken(ctx context.Context, userID uint64, token
 	if err := s.ensure(); err != nil {
 		return err
 	}
-	return s.rdb.SRem(ctx, s.userTokensKey(userID), token).Err()
+	return s.kv.SRem(ctx, s.userTokensKey(userID), token)
 }
 
 // ListUserTokens 列出用户所有 token（用于全端注销）。
@@ -142,7 +243,7 @@ func (s *TokenService) ListUserTokens(ctx context.Context, userID uint64) ([]str
 	if err := s.ensure(); err != nil {
 		return nil, err
 	}
-	return s.rdb.SMembers(ctx, s.userTokensKey(userID)).Result()
+	return s.kv.SMembers(ctx, s.userTokensKey(userID))
 }
 
 // RevokeAllTokensByUser 注销用户全部 token。
@@ -153,21 +254,15 @@ func (s *TokenService) RevokeAllTokensByUser(ctx context.Context, userID uint64)
 	tokens, err := s.ListUserTokens(ctx, userID)
 	if err != nil {
 		// 如果 set 不存在，视为没有 token
-		if err == redis.Nil {
+		if errors.Is(err, ErrKVNotFound) {
 			return nil
 		}
 		return err
 	}
-	if len(tokens) == 0 {
-		_ = s.rdb.Del(ctx, s.userTokensKey(userID)).Err()
-		return nil
-	}
-
-	pipe := s.rdb.TxPipeline()
+	keys := make([]string, 0, 2*len(tokens)+1)
 	for _, t := range tokens {
-		pipe.Del(ctx, s.tokenKey(t))
+		keys = append(keys, s.tokenKey(t), s.tokenMetaKey(t))
 	}
-	pipe.Del(ctx, s.userTokensKey(userID))
-	_, err = pipe.Exec(ctx)
-	return err
+	keys = append(keys, s.userTokensKey(userID))
+	return s.kv.Del(ctx, keys...)
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// TxRetryPolicy 事务遇到死锁、锁等待超时、序列化失败时的自动重试策略
+type TxRetryPolicy struct {
+	// MaxAttempts 最多执行次数（含首次），<=1 不重试
+	MaxAttempts int
+	// BaseDelay 首次重试前的等待，之后每次翻倍，实际等待在 [delay/2, delay] 内随机抖动
+	BaseDelay time.Duration
+	// MaxDelay 单次等待上限
+	MaxDelay time.Duration
+}
+
+// DefaultTxRetryPolicy 默认：最多执行 3 次，等待 20ms 起翻倍，不超过 500ms
+var DefaultTxRetryPolicy = TxRetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   20 * time.Millisecond,
+	MaxDelay:    500 * time.Millisecond,
+}
+
+// backoff 第 attempt 次失败后的等待时长（带抖动）
+func (p TxRetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// MySQL 错误码：1213 死锁，1205 锁等待超时
+const (
+	mysqlErrDeadlock        = 1213
+	mysqlErrLockWaitTimeout = 1205
+)
+
+// IsRetryableTxError 事务失败是否可整体重试（死锁、锁等待超时、序列化失败）
+func IsRetryableTxError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == mysqlErrDeadlock || me.Number == mysqlErrLockWaitTimeout
+	}
+	// 其他驱动按 SQLSTATE 40001 / 40P01 或错误文案判断
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"deadlock", "40001", "40p01", "could not serialize access", "database is locked"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tx 在事务中执行 fn，死锁等可重试错误按 TxRetry 策略整体重试。
+// fn 可能被执行多次：写入外部变量时每次重新赋值（不要累加），WS 推送等副作用放在 Tx 返回之后。
+func (s *Service) Tx(fn func(tx *gorm.DB) error) error {
+	return s.runTx(context.Background(), s.DB, fn)
+}
+
+// TxContext 同 Tx，ctx 取消时停止重试
+func (s *Service) TxContext(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return s.runTx(ctx, s.DB.WithContext(ctx), fn)
+}
+
+func (s *Service) runTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	p := s.TxRetry
+	for attempt := 1; ; attempt++ {
+		err := db.Transaction(fn)
+		if err == nil || attempt >= p.MaxAttempts || !IsRetryableTxError(err) {
+			return err
+		}
+		wait := p.backoff(attempt)
+		log.Printf("tx retry: attempt=%d wait=%s err=%v", attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/glebarez/sqlite"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{fmt.Errorf("wrap: %w", &mysql.MySQLError{Number: 1205}), true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{errors.New("ERROR: could not serialize access due to concurrent update (SQLSTATE 40001)"), true},
+		{errors.New("database is locked"), true},
+		{gorm.ErrRecordNotFound, false},
+	}
+	for _, c := range cases {
+		if got := IsRetryableTxError(c.err); got != c.want {
+			t.Errorf("IsRetryableTxError(%v)=%v want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestTxRetryPolicy_Backoff(t *testing.T) {
+	p := TxRetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 30 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 4: 30 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt); d < max/2 || d > max {
+				t.Fatalf("attempt %d: %s not in [%s, %s]", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestService_TxRetriesDeadlock(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	s := &Service{DB: gormDB, TxRetry: TxRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_room`").WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `im_room`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	attempts := 0
+	err := s.Tx(func(tx *gorm.DB) error {
+		attempts++
+		return tx.Exec("UPDATE `im_room` SET name = ? WHERE id = ?", "a", 1).Error
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("Tx: attempts=%d err=%v", attempts, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestService_TxGivesUp(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+	s := &Service{DB: gormDB, TxRetry: TxRetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+	}
+	attempts := 0
+	err := s.Tx(func(tx *gorm.DB) error {
+		attempts++
+		return deadlock
+	})
+	if !errors.Is(err, deadlock) || attempts != 2 {
+		t.Fatalf("should stop after MaxAttempts: attempts=%d err=%v", attempts, err)
+	}
+
+	// 业务错误不重试
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	attempts = 0
+	err = s.Tx(func(tx *gorm.DB) error {
+		attempts++
+		return ErrRoomFull
+	})
+	if !errors.Is(err, ErrRoomFull) || attempts != 1 {
+		t.Fatalf("non-retryable: attempts=%d err=%v", attempts, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 发消息的事务（分配房间序号时持有房间行锁）同样按 TxRetry 重试
+func TestMessageService_PersistRetriesDeadlock(t *testing.T) {
+	dsn := fmt.Sprintf("file:persist_retry_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Room{}, &models.Message{}, &models.Conversation{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&models.Room{ID: 1, RoomAccount: "r1", Type: 2}).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	// 第一次自增 message_seq 时模拟死锁
+	deadlocks := 0
+	if err := db.Callback().Update().Before("gorm:update").Register("test:deadlock", func(d *gorm.DB) {
+		if d.Statement.Table == (models.Room{}).TableName() && deadlocks == 0 {
+			deadlocks++
+			_ = d.AddError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	s := NewMessageService(&Service{DB: db, TxRetry: TxRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}})
+
+	msg, err := s.SaveSystemMessage(1, 1, "hi", message.Extra{})
+	if err != nil || deadlocks != 1 {
+		t.Fatalf("save: deadlocks=%d err=%v", deadlocks, err)
+	}
+	var room models.Room
+	db.First(&room, 1)
+	var n int64
+	db.Model(&models.Message{}).Count(&n)
+	if msg.Seq != 1 || room.MessageSeq != 1 || n != 1 {
+		t.Fatalf("seq=%d room seq=%d messages=%d", msg.Seq, room.MessageSeq, n)
+	}
+}
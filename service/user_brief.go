@@ -0,0 +1,155 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+)
+
+const (
+	// UserBriefBatchMax 接口单次最多查询的用户数
+	UserBriefBatchMax = 100
+	// userBriefCacheTTL 昵称/头像缓存有效期（本人修改资料时会主动失效）
+	userBriefCacheTTL = 30 * time.Second
+	// userBriefCacheMax 缓存条数上限，超过时先清过期项，仍超出则整体清空
+	userBriefCacheMax = 10000
+)
+
+// ErrUserBriefBatch 单次查询的用户数超过 UserBriefBatchMax
+var ErrUserBriefBatch = newError(response.CodeParamError, "err.user_brief_batch")
+
+type userBriefEntry struct {
+	brief models.UserBrief
+	at    time.Time
+}
+
+// userBriefCache 进程内的用户展示信息缓存（多实例部署时各自缓存，靠短 TTL 收敛）
+type userBriefCache struct {
+	mu    sync.Mutex
+	items map[uint64]userBriefEntry
+}
+
+func (c *userBriefCache) get(id uint64, now time.Time) (models.UserBrief, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[id]
+	if !ok || now.Sub(e.at) >= userBriefCacheTTL {
+		return models.UserBrief{}, false
+	}
+	return e.brief, true
+}
+
+func (c *userBriefCache) put(briefs map[uint64]models.UserBrief, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.items == nil {
+		c.items = make(map[uint64]userBriefEntry, len(briefs))
+	}
+	if len(c.items)+len(briefs) > userBriefCacheMax {
+		for id, e := range c.items {
+			if now.Sub(e.at) >= userBriefCacheTTL {
+				delete(c.items, id)
+			}
+		}
+		if len(c.items)+len(briefs) > userBriefCacheMax {
+			c.items = make(map[uint64]userBriefEntry, len(briefs))
+		}
+	}
+	for id, b := range briefs {
+		c.items[id] = userBriefEntry{brief: b, at: now}
+	}
+}
+
+func (c *userBriefCache) del(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, id)
+}
+
+// BatchGetUserBriefs 批量获取用户昵称/头像：先查缓存，再走在线会话，最后批量查库。
+// 不限数量，供服务内部给携带 user_id 的 DTO / 推送补全展示信息；不存在的用户返回空昵称头像。
+func (s *UserService) BatchGetUserBriefs(ids []uint64) (map[uint64]models.UserBrief, error) {
+	now := time.Now()
+	out := make(map[uint64]models.UserBrief, len(ids))
+	miss := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+		if _, ok := out[id]; ok {
+			continue
+		}
+		if b, ok := s.briefCache.get(id, now); ok {
+			out[id] = b
+			continue
+		}
+		out[id] = models.UserBrief{UserID: id}
+		miss = append(miss, id)
+	}
+	if len(miss) == 0 {
+		return out, nil
+	}
+
+	var online models.OnlineUserBriefGetter
+	if s.OnlineUserGetter != nil {
+		online = func(id uint64) (models.UserBrief, bool, error) {
+			nickname, avatar, ok := s.OnlineUserGetter(id)
+			return models.UserBrief{UserID: id, Nickname: nickname, Avatar: avatar}, ok, nil
+		}
+	}
+	fetched, err := s.userDao.BatchGetUserBriefsPreferOnline(miss, online)
+	if err != nil {
+		return nil, err
+	}
+	s.briefCache.put(fetched, now)
+	for id, b := range fetched {
+		out[id] = b
+	}
+	return out, nil
+}
+
+// GetUserBriefs 按传入顺序返回用户展示信息（去重，最多 UserBriefBatchMax 个），供 /user/briefs 使用
+func (s *UserService) GetUserBriefs(ids []uint64) ([]models.UserBrief, error) {
+	uniq := make([]uint64, 0, len(ids))
+	seen := make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		uniq = append(uniq, id)
+	}
+	if len(uniq) > UserBriefBatchMax {
+		return nil, ErrUserBriefBatch
+	}
+	briefs, err := s.BatchGetUserBriefs(uniq)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]models.UserBrief, 0, len(uniq))
+	for _, id := range uniq {
+		list = append(list, briefs[id])
+	}
+	return list, nil
+}
+
+// UserBrief 取单个用户的昵称/头像，失败时返回空值（仅记日志），用于推送等尽力而为的场景
+func (s *UserService) UserBrief(userID uint64) models.UserBrief {
+	briefs, err := s.BatchGetUserBriefs([]uint64{userID})
+	if err != nil {
+		log.Printf("get user brief %d: %v", userID, err)
+		return models.UserBrief{UserID: userID}
+	}
+	return briefs[userID]
+}
+
+// invalidateUserBrief 资料变更后让缓存失效
+func (s *UserService) invalidateUserBrief(userID uint64) {
+	s.briefCache.del(userID)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUserService_GetUserBriefs(t *testing.T) {
+	gormDB, mock, sqlDB := newMockDB(t)
+	defer sqlDB.Close()
+
+	us := NewUserService(&Service{DB: gormDB, TablePrefix: "im_"})
+	// 2 在线，直接取会话里的昵称头像
+	us.OnlineUserGetter = func(id uint64) (string, string, bool) {
+		if id == 2 {
+			return "online", "a2.png", true
+		}
+		return "", "", false
+	}
+
+	mock.ExpectQuery("SELECT id, nickname, avatar FROM `im_user` WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "avatar"}).AddRow(3, "c", "a3.png"))
+
+	list, err := us.GetUserBriefs([]uint64{3, 2, 3, 0, 1})
+	if err != nil {
+		t.Fatalf("GetUserBriefs: %v", err)
+	}
+	if len(list) != 3 || list[0].Nickname != "c" || list[1].Nickname != "online" || list[2].UserID != 1 || list[2].Nickname != "" {
+		t.Fatalf("list=%#v", list)
+	}
+
+	// 第二次命中缓存，不再查库；修改资料后失效
+	if b := us.UserBrief(3); b.Avatar != "a3.png" {
+		t.Fatalf("cached brief=%#v", b)
+	}
+	us.invalidateUserBrief(3)
+	mock.ExpectQuery("SELECT id, nickname, avatar FROM `im_user` WHERE id IN \\(\\?\\)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "avatar"}).AddRow(3, "c2", "a3.png"))
+	if b := us.UserBrief(3); b.Nickname != "c2" {
+		t.Fatalf("refreshed brief=%#v", b)
+	}
+
+	ids := make([]uint64, UserBriefBatchMax+1)
+	for i := range ids {
+		ids[i] = uint64(i + 1)
+	}
+	if _, err := us.GetUserBriefs(ids); !errors.Is(err, ErrUserBriefBatch) {
+		t.Fatalf("want ErrUserBriefBatch, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
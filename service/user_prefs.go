@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// UserPrefsMaxBytes 单个命名空间 JSON 的最大字节数
+	UserPrefsMaxBytes = 16 << 10
+	// UserPrefsMaxNamespaces 每个用户最多的命名空间数
+	UserPrefsMaxNamespaces = 50
+	// prefsWriteAttempts 未指定 base_version 时，并发写冲突的重试次数
+	prefsWriteAttempts = 3
+)
+
+var prefsNamespaceRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+var (
+	ErrPrefsNamespace       = newError(response.CodeParamError, "err.prefs_namespace_invalid")
+	ErrPrefsData            = newError(response.CodeParamError, "err.prefs_data_invalid")
+	ErrPrefsTooLarge        = newError(response.CodeParamError, "err.prefs_too_large", UserPrefsMaxBytes)
+	ErrPrefsTooMany         = newError(response.CodeParamError, "err.prefs_too_many", UserPrefsMaxNamespaces)
+	ErrPrefsVersionConflict = newError(response.CodeParamError, "err.prefs_version_conflict")
+)
+
+// UserPrefsDTO 一个命名空间的自定义设置
+type UserPrefsDTO struct {
+	Namespace string          `json:"namespace"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+	Version   uint64          `json:"version"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// PatchPrefsReq 修改一个命名空间的自定义设置
+type PatchPrefsReq struct {
+	Namespace string `json:"namespace" binding:"required" example:"chat.appearance"`
+	// Data JSON 对象。默认按 JSON Merge Patch（RFC 7386）合并：值为 null 的键被删除，对象递归合并，其他值直接覆盖
+	Data json.RawMessage `json:"data" binding:"required" swaggertype:"object"`
+	// Replace 为 true 时用 data 整体替换
+	Replace bool `json:"replace" example:"false"`
+	// BaseVersion 客户端当前持有的版本（新命名空间为 0），与服务端不一致时返回冲突；不传则以服务端最新版本为基础合并
+	BaseVersion *uint64 `json:"base_version" example:"3"`
+}
+
+func toPrefsDTO(p *models.UserPreference) UserPrefsDTO {
+	data := json.RawMessage(p.Data)
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	return UserPrefsDTO{Namespace: p.Namespace, Data: data, Version: p.Version, UpdatedAt: p.UpdatedAt}
+}
+
+// mergePatch 按 RFC 7386 把 patch 合并进 target
+func mergePatch(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any, len(patch))
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+		if pv, ok := v.(map[string]any); ok {
+			tv, _ := target[k].(map[string]any)
+			target[k] = mergePatch(tv, pv)
+			continue
+		}
+		target[k] = v
+	}
+	return target
+}
+
+// decodePrefsObject 解析 JSON 对象，空内容视为空对象
+func decodePrefsObject(b []byte) (map[string]any, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return map[string]any{}, nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil || m == nil {
+		return nil, ErrPrefsData
+	}
+	return m, nil
+}
+
+// applyPrefsPatch 计算写入后的内容
+func applyPrefsPatch(current, patch []byte, replace bool) ([]byte, error) {
+	p, err := decodePrefsObject(patch)
+	if err != nil {
+		return nil, err
+	}
+	if !replace {
+		cur, err := decodePrefsObject(current)
+		if err != nil {
+			cur = nil // 库里的旧数据损坏时按空对象处理
+		}
+		p = mergePatch(cur, p)
+	}
+	out, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > UserPrefsMaxBytes {
+		return nil, ErrPrefsTooLarge
+	}
+	return out, nil
+}
+
+// GetPrefs 获取自定义设置，namespaces 为空时返回全部命名空间（按名称排序）
+func (s *UserService) GetPrefs(userID uint64, namespaces ...string) ([]UserPrefsDTO, error) {
+	q := s.DB.Where("user_id = ?", userID)
+	if len(namespaces) > 0 {
+		q = q.Where("namespace IN ?", namespaces)
+	}
+	var rows []models.UserPreference
+	if err := q.Order("namespace ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]UserPrefsDTO, 0, len(rows))
+	for i := range rows {
+		out = append(out, toPrefsDTO(&rows[i]))
+	}
+	return out, nil
+}
+
+// PatchPrefs 修改一个命名空间的自定义设置，成功后版本号加 1，并推送 prefs_updated 给该用户的所有设备。
+// 以版本号做条件更新：指定 base_version 时不一致直接返回冲突；未指定时基于最新内容重新合并，最多重试 prefsWriteAttempts 次。
+func (s *UserService) PatchPrefs(userID uint64, req PatchPrefsReq) (*UserPrefsDTO, error) {
+	if !prefsNamespaceRe.MatchString(req.Namespace) {
+		return nil, ErrPrefsNamespace
+	}
+	for attempt := 0; attempt < prefsWriteAttempts; attempt++ {
+		var row models.UserPreference
+		err := s.DB.Where("user_id = ? AND namespace = ?", userID, req.Namespace).First(&row).Error
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if req.BaseVersion != nil && *req.BaseVersion != row.Version {
+			return nil, ErrPrefsVersionConflict
+		}
+		data, err := applyPrefsPatch(row.Data, req.Data, req.Replace)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		if exists {
+			res := s.DB.Model(&models.UserPreference{}).
+				Where("id = ? AND version = ?", row.ID, row.Version).
+				Updates(map[string]any{"data": datatypes.JSON(data), "version": row.Version + 1, "updated_at": now})
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue // 被其他设备抢先修改
+			}
+			row.Data, row.Version, row.UpdatedAt = data, row.Version+1, now
+		} else {
+			var n int64
+			if err := s.DB.Model(&models.UserPreference{}).Where("user_id = ?", userID).Count(&n).Error; err != nil {
+				return nil, err
+			}
+			if n >= UserPrefsMaxNamespaces {
+				return nil, ErrPrefsTooMany
+			}
+			row = models.UserPreference{UserID: userID, Namespace: req.Namespace, Data: data, Version: 1, CreatedAt: now, UpdatedAt: now}
+			res := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue // 其他设备同时创建了该命名空间
+			}
+		}
+
+		dto := toPrefsDTO(&row)
+		s.Emit([]uint64{userID}, &message.PrefsUpdatedEvent{Namespace: dto.Namespace, Version: dto.Version, Data: dto.Data})
+		return &dto, nil
+	}
+	return nil, ErrPrefsVersionConflict
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestApplyPrefsPatch(t *testing.T) {
+	cur := []byte(`{"font":{"size":14,"family":"sans"},"bubble":"blue","dark":true}`)
+	out, err := applyPrefsPatch(cur, []byte(`{"font":{"size":16},"bubble":null,"lang":"zh"}`), false)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if string(out) != `{"dark":true,"font":{"family":"sans","size":16},"lang":"zh"}` {
+		t.Fatalf("merge result: %s", out)
+	}
+	if out, _ := applyPrefsPatch(cur, []byte(`{"a":1}`), true); string(out) != `{"a":1}` {
+		t.Fatalf("replace result: %s", out)
+	}
+	if _, err := applyPrefsPatch(nil, []byte(`[1,2]`), false); !errors.Is(err, ErrPrefsData) {
+		t.Fatalf("array should be rejected: %v", err)
+	}
+	big := fmt.Sprintf(`{"x":%q}`, strings.Repeat("a", UserPrefsMaxBytes))
+	if _, err := applyPrefsPatch(nil, []byte(big), false); !errors.Is(err, ErrPrefsTooLarge) {
+		t.Fatalf("too large: %v", err)
+	}
+}
+
+func TestUserService_PatchPrefs(t *testing.T) {
+	dsn := fmt.Sprintf("file:user_prefs_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UserPreference{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	var pushed []json.RawMessage
+	s := &UserService{Service: &Service{DB: db, WsNotifier: func(userID uint64, b []byte) { pushed = append(pushed, b) }}}
+
+	if _, err := s.PatchPrefs(1, PatchPrefsReq{Namespace: "Bad NS", Data: json.RawMessage(`{}`)}); !errors.Is(err, ErrPrefsNamespace) {
+		t.Fatalf("namespace: %v", err)
+	}
+	p, err := s.PatchPrefs(1, PatchPrefsReq{Namespace: "chat.font", Data: json.RawMessage(`{"size":14}`)})
+	if err != nil || p.Version != 1 {
+		t.Fatalf("create: %+v %v", p, err)
+	}
+	base := uint64(1)
+	p, err = s.PatchPrefs(1, PatchPrefsReq{Namespace: "chat.font", Data: json.RawMessage(`{"family":"serif"}`), BaseVersion: &base})
+	if err != nil || p.Version != 2 || string(p.Data) != `{"family":"serif","size":14}` {
+		t.Fatalf("merge: %+v %v", p, err)
+	}
+	// 旧版本写入被拒绝
+	if _, err := s.PatchPrefs(1, PatchPrefsReq{Namespace: "chat.font", Data: json.RawMessage(`{"size":20}`), BaseVersion: &base}); !errors.Is(err, ErrPrefsVersionConflict) {
+		t.Fatalf("conflict: %v", err)
+	}
+
+	list, err := s.GetPrefs(1)
+	if err != nil || len(list) != 1 || list[0].Version != 2 {
+		t.Fatalf("get: %+v %v", list, err)
+	}
+	if list, _ := s.GetPrefs(2); len(list) != 0 {
+		t.Fatalf("other user should have no prefs: %+v", list)
+	}
+	if len(pushed) != 2 {
+		t.Fatalf("expected 2 prefs_updated pushes, got %d", len(pushed))
+	}
+	var evt struct {
+		Type    string `json:"type"`
+		Version uint64 `json:"version"`
+	}
+	_ = json.Unmarshal(pushed[1], &evt)
+	if evt.Type != EventPrefsUpdated || evt.Version != 2 {
+		t.Fatalf("event: %s", pushed[1])
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 隐私/好友来源相关错误
+var (
+	ErrPrivacySearchMode   = newError(response.CodeParamError, "err.privacy_search_mode")
+	ErrFriendSource        = newError(response.CodeParamError, "err.friend_source_invalid")
+	ErrFriendSourceRoom    = newError(response.CodeParamError, "err.friend_source_room")
+	ErrFriendApplyDisabled = newError(response.CodePermissionDeny, "err.friend_apply_disabled")
+	ErrFriendSourceDenied  = newError(response.CodePermissionDeny, "err.friend_source_denied")
+)
+
+// UserPrivacyDTO “如何找到/添加我”设置
+type UserPrivacyDTO struct {
+	SearchMode       uint8 `json:"search_mode"`        // 0-可模糊搜索 1-仅 UID/用户名精确搜索 2-不可搜索
+	AllowPhoneSearch bool  `json:"allow_phone_search"` // 允许通过手机号精确搜到
+	DenyFromGroup    bool  `json:"deny_from_group"`    // 不允许通过群聊添加
+	DenyFromQRCode   bool  `json:"deny_from_qrcode"`   // 不允许扫码添加
+	DenyFromNearby   bool  `json:"deny_from_nearby"`   // 不允许通过附近的人添加
+	DenyFriendApply  bool  `json:"deny_friend_apply"`  // 关闭好友申请
+	HideSignature    bool  `json:"hide_signature"`     // 对非好友隐藏个性签名
+	HideLastActive   bool  `json:"hide_last_active"`   // 对非好友隐藏最近登录/活跃时间
+	HideMomentCount  bool  `json:"hide_moment_count"`  // 对非好友隐藏动态数
+}
+
+// UpdatePrivacyReq 更新隐私设置（只更新传入的字段）
+type UpdatePrivacyReq struct {
+	SearchMode       *uint8 `json:"search_mode"`
+	AllowPhoneSearch *bool  `json:"allow_phone_search"`
+	DenyFromGroup    *bool  `json:"deny_from_group"`
+	DenyFromQRCode   *bool  `json:"deny_from_qrcode"`
+	DenyFromNearby   *bool  `json:"deny_from_nearby"`
+	DenyFriendApply  *bool  `json:"deny_friend_apply"`
+	HideSignature    *bool  `json:"hide_signature"`
+	HideLastActive   *bool  `json:"hide_last_active"`
+	HideMomentCount  *bool  `json:"hide_moment_count"`
+}
+
+// loadUserPrivacy 读取隐私设置，没有记录时返回默认值
+func loadUserPrivacy(db *gorm.DB, userID uint64) (models.UserPrivacy, error) {
+	var p models.UserPrivacy
+	err := db.Where("user_id = ?", userID).Limit(1).Find(&p).Error
+	p.UserID = userID
+	return p, err
+}
+
+func toUserPrivacyDTO(p models.UserPrivacy) UserPrivacyDTO {
+	return UserPrivacyDTO{
+		SearchMode:       p.SearchMode,
+		AllowPhoneSearch: p.AllowPhoneSearch,
+		DenyFromGroup:    p.DenyFromGroup,
+		DenyFromQRCode:   p.DenyFromQRCode,
+		DenyFromNearby:   p.DenyFromNearby,
+		DenyFriendApply:  p.DenyFriendApply,
+		HideSignature:    p.HideSignature,
+		HideLastActive:   p.HideLastActive,
+		HideMomentCount:  p.HideMomentCount,
+	}
+}
+
+// GetPrivacy 获取当前用户隐私设置
+func (s *UserService) GetPrivacy(userID uint64) (*UserPrivacyDTO, error) {
+	p, err := loadUserPrivacy(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	dto := toUserPrivacyDTO(p)
+	return &dto, nil
+}
+
+// UpdatePrivacy 更新隐私设置（没有记录时创建）
+func (s *UserService) UpdatePrivacy(userID uint64, req UpdatePrivacyReq) (*UserPrivacyDTO, error) {
+	updates := make(map[string]any)
+	if req.SearchMode != nil {
+		if *req.SearchMode > models.SearchModeHidden {
+			return nil, ErrPrivacySearchMode
+		}
+		updates["search_mode"] = *req.SearchMode
+	}
+	if req.AllowPhoneSearch != nil {
+		updates["allow_phone_search"] = *req.AllowPhoneSearch
+	}
+	if req.DenyFromGroup != nil {
+		updates["deny_from_group"] = *req.DenyFromGroup
+	}
+	if req.DenyFromQRCode != nil {
+		updates["deny_from_qrcode"] = *req.DenyFromQRCode
+	}
+	if req.DenyFromNearby != nil {
+		updates["deny_from_nearby"] = *req.DenyFromNearby
+	}
+	if req.DenyFriendApply != nil {
+		updates["deny_friend_apply"] = *req.DenyFriendApply
+	}
+	if req.HideSignature != nil {
+		updates["hide_signature"] = *req.HideSignature
+	}
+	if req.HideLastActive != nil {
+		updates["hide_last_active"] = *req.HideLastActive
+	}
+	if req.HideMomentCount != nil {
+		updates["hide_moment_count"] = *req.HideMomentCount
+	}
+	if len(updates) == 0 {
+		return s.GetPrivacy(userID)
+	}
+
+	updates["updated_at"] = time.Now()
+	columns := make([]string, 0, len(updates))
+	for k := range updates {
+		columns = append(columns, k)
+	}
+	row := map[string]any{"user_id": userID}
+	for k, v := range updates {
+		row[k] = v
+	}
+	if err := s.DB.Model(&models.UserPrivacy{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(row).Error; err != nil {
+		return nil, err
+	}
+	return s.GetPrivacy(userID)
+}
+
+// normalizeFriendSource 校验申请来源，空视为搜索
+func normalizeFriendSource(source string) (string, error) {
+	switch source {
+	case "":
+		return models.FriendSourceSearch, nil
+	case models.FriendSourceSearch, models.FriendSourceGroup, models.FriendSourceQRCode, models.FriendSourceNearby:
+		return source, nil
+	}
+	return "", ErrFriendSource
+}
+
+// checkFriendApplyAllowed 按被申请人的隐私设置校验来源是否允许；
+// source=group 时要求双方都在 roomID 群中。
+func checkFriendApplyAllowed(db *gorm.DB, fromUser, toUser uint64, source string, roomID uint64) error {
+	p, err := loadUserPrivacy(db, toUser)
+	if err != nil {
+		return err
+	}
+	if p.DenyFriendApply {
+		return ErrFriendApplyDisabled
+	}
+	switch source {
+	case models.FriendSourceSearch:
+		// 对方设置了不可搜索时，说明申请人不是搜到的
+		if p.SearchMode == models.SearchModeHidden {
+			return ErrFriendSourceDenied
+		}
+	case models.FriendSourceGroup:
+		if p.DenyFromGroup {
+			return ErrFriendSourceDenied
+		}
+		if roomID == 0 {
+			return ErrFriendSourceRoom
+		}
+		var cnt int64
+		if err := db.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id IN ?", roomID, []uint64{fromUser, toUser}).
+			Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt < 2 {
+			return ErrFriendSourceRoom
+		}
+	case models.FriendSourceQRCode:
+		if p.DenyFromQRCode {
+			return ErrFriendSourceDenied
+		}
+	case models.FriendSourceNearby:
+		if p.DenyFromNearby {
+			return ErrFriendSourceDenied
+		}
+	}
+	return nil
+}
+
+// userView 查看者与资料主人的关系，决定 toUserDTO 对外展示哪些字段
+type userView struct {
+	self    bool               // 本人或系统内部调用：不脱敏
+	friend  bool               // 查看者是资料主人的好友
+	privacy models.UserPrivacy // 资料主人的隐私设置（仅非好友时生效）
+}
+
+// selfView 本人/内部调用视角
+var selfView = userView{self: true}
+
+// userViewsFor 批量计算 viewerID 看 userIDs 时的视角；viewerID 为 0 表示系统内部调用
+func userViewsFor(db *gorm.DB, viewerID uint64, userIDs []uint64) (map[uint64]userView, error) {
+	views := make(map[uint64]userView, len(userIDs))
+	others := make([]uint64, 0, len(userIDs))
+	for _, id := range userIDs {
+		if viewerID == 0 || id == viewerID {
+			views[id] = selfView
+			continue
+		}
+		views[id] = userView{}
+		others = append(others, id)
+	}
+	if len(others) == 0 {
+		return views, nil
+	}
+
+	// 以资料主人的好友列表为准（对方删了我，我就是陌生人）
+	var friendOf []uint64
+	if err := db.Model(&models.Friend{}).
+		Where("user_id IN ? AND friend_id = ? AND status = ?", others, viewerID, 1).
+		Pluck("user_id", &friendOf).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range friendOf {
+		views[id] = userView{friend: true}
+	}
+
+	var rows []models.UserPrivacy
+	if err := db.Where("user_id IN ?", others).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range rows {
+		v := views[p.UserID]
+		v.privacy = p
+		views[p.UserID] = v
+	}
+	return views, nil
+}
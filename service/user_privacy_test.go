@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+func TestCheckFriendApplyAllowed(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+
+	privacyCols := []string{"user_id", "search_mode", "allow_phone_search", "deny_from_group", "deny_from_qrcode", "deny_from_nearby", "deny_friend_apply"}
+
+	// 没有设置记录：搜索来源允许
+	mock.ExpectQuery("SELECT \\* FROM `im_user_privacy` WHERE user_id = \\? LIMIT \\?").
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows(privacyCols))
+	if err := checkFriendApplyAllowed(db, 1, 2, models.FriendSourceSearch, 0); err != nil {
+		t.Fatalf("default: %v", err)
+	}
+
+	// 关闭好友申请
+	mock.ExpectQuery("SELECT \\* FROM `im_user_privacy`").
+		WillReturnRows(sqlmock.NewRows(privacyCols).AddRow(2, 0, false, false, false, false, true))
+	if err := checkFriendApplyAllowed(db, 1, 2, models.FriendSourceQRCode, 0); !errors.Is(err, ErrFriendApplyDisabled) {
+		t.Fatalf("want ErrFriendApplyDisabled, got %v", err)
+	}
+
+	// 不允许附近的人添加
+	mock.ExpectQuery("SELECT \\* FROM `im_user_privacy`").
+		WillReturnRows(sqlmock.NewRows(privacyCols).AddRow(2, 0, false, false, false, true, false))
+	if err := checkFriendApplyAllowed(db, 1, 2, models.FriendSourceNearby, 0); !errors.Is(err, ErrFriendSourceDenied) {
+		t.Fatalf("want ErrFriendSourceDenied, got %v", err)
+	}
+
+	// 群来源：申请人不在群里
+	mock.ExpectQuery("SELECT \\* FROM `im_user_privacy`").
+		WillReturnRows(sqlmock.NewRows(privacyCols))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_room_user` WHERE room_id = \\? AND user_id IN \\(\\?,\\?\\)").
+		WithArgs(9, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	if err := checkFriendApplyAllowed(db, 1, 2, models.FriendSourceGroup, 9); !errors.Is(err, ErrFriendSourceRoom) {
+		t.Fatalf("want ErrFriendSourceRoom, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+
+	if _, err := normalizeFriendSource("card"); !errors.Is(err, ErrFriendSource) {
+		t.Fatalf("want ErrFriendSource, got %v", err)
+	}
+	if src, _ := normalizeFriendSource(""); src != models.FriendSourceSearch {
+		t.Fatalf("empty source=%q", src)
+	}
+}
+
+func TestUserService_UpdatePrivacy_InvalidMode(t *testing.T) {
+	db, _, sqldb := newMockDB(t)
+	defer sqldb.Close()
+
+	us := &UserService{Service: &Service{DB: db, TablePrefix: "im_"}}
+	mode := uint8(3)
+	if _, err := us.UpdatePrivacy(1, UpdatePrivacyReq{SearchMode: &mode}); !errors.Is(err, ErrPrivacySearchMode) {
+		t.Fatalf("want ErrPrivacySearchMode, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cydxin/chat-sdk/models"
 )
 
 func TestUserService_UpdatePassword(t *testing.T) {
@@ -36,14 +37,21 @@ func TestUserService_SearchUsers(t *testing.T) {
 
 	now := time.Now()
 	rows := sqlmock.NewRows([]string{"id", "uid", "username", "nickname", "password", "avatar", "phone", "email", "gender", "birthday", "signature", "online_status", "last_login_at", "last_active_at", "created_at", "updated_at", "deleted_at"}).
-		AddRow(uint64(2), "u2", "bob", "Bobby", "hash", "", "", "", 0, nil, "", 0, nil, nil, now, now, nil)
+		AddRow(uint64(2), "u2", "bob", "Bobby", "hash", "", "13800000000", "bob@example.com", 0, nil, "hello", 0, nil, nil, now, now, nil)
 
 	limit := 10
 
 	// GORM 会生成 LIMIT ?（而不是 LIMIT 10）
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `im_user` WHERE id <> ? AND (username LIKE ? OR nickname LIKE ? OR uid LIKE ?) AND `im_user`.`deleted_at` IS NULL ORDER BY id DESC LIMIT ?")).
-		WithArgs(uint64(1), "%bo%", "%bo%", "%bo%", limit).
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `im_user` WHERE id <> ? AND (((username LIKE ? OR nickname LIKE ? OR uid LIKE ?) AND id NOT IN (SELECT user_id FROM im_user_privacy WHERE search_mode <> ?)) OR ((uid = ? OR username = ?) AND id NOT IN (SELECT user_id FROM im_user_privacy WHERE search_mode = ?)) OR (phone = ? AND id IN (SELECT user_id FROM im_user_privacy WHERE allow_phone_search = ? AND search_mode <> ?))) AND `im_user`.`deleted_at` IS NULL ORDER BY id DESC LIMIT ?")).
+		WithArgs(uint64(1), "%bo%", "%bo%", "%bo%", 0, "bo", "bo", 2, "bo", true, 2, limit).
 		WillReturnRows(rows)
+	// 非好友 + 对方隐藏签名
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `user_id` FROM `im_friend` WHERE user_id IN (?) AND friend_id = ? AND status = ?")).
+		WithArgs(2, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `im_user_privacy` WHERE user_id IN (?)")).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "hide_signature"}).AddRow(2, true))
 
 	res, err := us.SearchUsers("bo", 1, limit, 0)
 	if err != nil {
@@ -55,8 +63,26 @@ func TestUserService_SearchUsers(t *testing.T) {
 	if res[0].Username != "bob" {
 		t.Fatalf("expected bob, got %s", res[0].Username)
 	}
+	if res[0].Phone != "" || res[0].Email != "" || res[0].Signature != "" {
+		t.Fatalf("stranger fields should be hidden: %+v", res[0])
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
 	}
 }
+
+func TestToUserDTO_Invisible(t *testing.T) {
+	now := time.Now()
+	u := &models.User{ID: 2, OnlineStatus: 1, Invisible: true, LastActiveAt: &now, Phone: "138"}
+
+	if d := toUserDTO(u, selfView); d.OnlineStatus != 1 || d.LastActiveAt == nil {
+		t.Fatalf("self should see real status: %+v", d)
+	}
+	if d := toUserDTO(u, userView{friend: true}); d.OnlineStatus != 0 || d.LastActiveAt != nil || d.Phone != "138" {
+		t.Fatalf("friend should see offline: %+v", d)
+	}
+	if d := toUserDTO(u, userView{}); d.OnlineStatus != 0 || d.Phone != "" {
+		t.Fatalf("stranger should see offline without phone: %+v", d)
+	}
+}
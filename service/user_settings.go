@@ -0,0 +1,36 @@
+package service
+
+import (
+	"github.com/cydxin/chat-sdk/models"
+)
+
+// UserSettingsDTO 用户个人设置
+type UserSettingsDTO struct {
+	AppearOffline bool `json:"appear_offline"` // 隐身：他人看到的在线状态始终为离线
+}
+
+// UpdateUserSettingsReq 更新个人设置（只更新传入的字段）
+type UpdateUserSettingsReq struct {
+	AppearOffline *bool `json:"appear_offline"`
+}
+
+// GetSettings 获取当前用户设置
+func (s *UserService) GetSettings(userID uint64) (*UserSettingsDTO, error) {
+	var u models.User
+	if err := s.DB.Select("id", "invisible").Where("id = ?", userID).First(&u).Error; err != nil {
+		return nil, err
+	}
+	return &UserSettingsDTO{AppearOffline: u.Invisible}, nil
+}
+
+// UpdateSettings 更新当前用户设置。隐身只影响他人看到的在线状态（资料、好友列表），WS 连接与消息收发照常。
+func (s *UserService) UpdateSettings(userID uint64, req UpdateUserSettingsReq) (*UserSettingsDTO, error) {
+	updates := make(map[string]any)
+	if req.AppearOffline != nil {
+		updates["invisible"] = *req.AppearOffline
+	}
+	if err := s.userDao.UpdateFields(userID, updates); err != nil {
+		return nil, err
+	}
+	return s.GetSettings(userID)
+}
@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -19,7 +21,15 @@ type UserService struct {
 	userDao           *models.UserDAO
 	tokenService      *TokenService
 	verifyCodeService *VerifyCodeService
-	loginTokenTTL     time.Duration
+
+	// LoginTokenTTL 登录 token 有效期，默认 7 天
+	LoginTokenTTL time.Duration
+
+	// Captcha 人机验证（nil 为关闭）：注册、发送验证码必须校验；登录连续失败 CaptchaLoginFailures 次后需要校验
+	Captcha              Captcha
+	CaptchaLoginFailures int
+
+	briefCache userBriefCache
 }
 
 func NewUserService(s *Service) *UserService {
@@ -27,9 +37,11 @@ func NewUserService(s *Service) *UserService {
 	return &UserService{
 		Service:           s,
 		userDao:           models.NewUserDAO(s.DB),
-		tokenService:      NewTokenService(s.RDB),
-		verifyCodeService: NewVerifyCodeService(s.RDB),
-		loginTokenTTL:     7 * 24 * time.Hour,
+		tokenService:      NewTokenServiceWithKV(s.KV),
+		verifyCodeService: NewVerifyCodeServiceWithKV(s.KV),
+		LoginTokenTTL:     7 * 24 * time.Hour,
+
+		CaptchaLoginFailures: 3,
 	}
 }
 
@@ -40,8 +52,10 @@ type UserDTO struct {
 	UID           string     `json:"uid"`
 	Username      string     `json:"username"`
 	Nickname      string     `json:"nickname"`
-	Remark        string     `json:"remark"`         // 好友备注（仅在好友/私聊场景有意义）
-	GroupNickname string     `json:"group_nickname"` // 我在该群里的昵称（群成员/会话列表可用）
+	Remark        string     `json:"remark"`               // 好友备注（仅在好友/私聊场景有意义）
+	IsStar        bool       `json:"is_star"`              // 星标好友（仅好友列表返回）
+	NameIndex     string     `json:"name_index,omitempty"` // 通讯录索引字母 A–Z/#（仅好友列表返回）
+	GroupNickname string     `json:"group_nickname"`       // 我在该群里的昵称（群成员/会话列表可用）
 	Avatar        string     `json:"avatar"`
 	Phone         string     `json:"phone"`
 	Email         string     `json:"email"`
@@ -53,8 +67,9 @@ type UserDTO struct {
 	LastActiveAt  *time.Time `json:"last_active_at"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
-	RoomID        uint64     `json:"room_id"`      // 私聊房间ID（与该好友的会话）
-	RoomAccount   string     `json:"room_account"` // 私聊房间对外号（与该好友的会话）
+	RoomID        uint64     `json:"room_id"`                // 私聊房间ID（与该好友的会话）
+	RoomAccount   string     `json:"room_account"`           // 私聊房间对外号（与该好友的会话）
+	MomentCount   *int64     `json:"moment_count,omitempty"` // 动态数（仅资料页返回，对方隐藏时为空）
 }
 
 type RegisterReq struct {
@@ -64,12 +79,22 @@ type RegisterReq struct {
 	NickName string `json:"nickname"`
 	Password string `json:"password"`
 	Code     string `json:"code"`
+
+	CaptchaToken string `json:"captcha_token,omitempty"` // 开启人机验证时必填
+	ClientIP     string `json:"-"`
 }
 
 type LoginReq struct {
 	Account  string `json:"account"`            // username/phone/email
 	Password string `json:"password,omitempty"` // plaintext（可选：与 code 二选一）
 	Code     string `json:"code,omitempty"`     // 验证码（可选：与 password 二选一）
+
+	CaptchaToken string `json:"captcha_token,omitempty"` // 连续登录失败后必填
+	// Device 登录设备描述（如 "iPhone 15 / iOS 18"），随 token 保存，可通过 token 详情查询；为空时 HTTP 接口取 User-Agent
+	Device string `json:"device,omitempty"`
+	// DeviceID 客户端生成并持久化的设备标识；同一设备登录多个账号时需一致，才能在账号之间切换
+	DeviceID string `json:"device_id,omitempty"`
+	ClientIP string `json:"-"`
 }
 
 type UpdateUserReq struct {
@@ -104,11 +129,11 @@ type ForgotPasswordReq struct {
 
 // --- 现 ---
 
-func toUserDTO(u *models.User) *UserDTO {
+func toUserDTO(u *models.User, v userView) *UserDTO {
 	if u == nil {
 		return nil
 	}
-	return &UserDTO{
+	dto := &UserDTO{
 		ID:           u.ID,
 		UID:          u.UID,
 		Username:     u.Username,
@@ -125,6 +150,26 @@ func toUserDTO(u *models.User) *UserDTO {
 		CreatedAt:    u.CreatedAt,
 		UpdatedAt:    u.UpdatedAt,
 	}
+	if v.self {
+		return dto
+	}
+	// 隐身：对他人一律显示离线，也不暴露最近活跃时间
+	if u.Invisible {
+		dto.OnlineStatus = 0
+		dto.LastActiveAt = nil
+	}
+	if v.friend {
+		return dto
+	}
+	// 非好友：手机号/邮箱始终隐藏，其余按资料主人的隐私设置
+	dto.Phone, dto.Email = "", ""
+	if v.privacy.HideSignature {
+		dto.Signature = ""
+	}
+	if v.privacy.HideLastActive {
+		dto.LastLoginAt, dto.LastActiveAt = nil, nil
+	}
+	return dto
 }
 
 func normalizeAccount(s string) string {
@@ -143,10 +188,10 @@ func pickIdentifier(phone, email string) (string, error) {
 	phone = strings.TrimSpace(phone)
 	email = normalizeEmail(email)
 	if phone == "" && email == "" {
-		return "", fmt.Errorf("请通过电话或电子邮件")
+		return "", ErrPhoneOrEmail
 	}
 	if phone != "" && email != "" {
-		return "", fmt.Errorf("电话和电子邮件不可同时提供")
+		return "", ErrPhoneAndEmail
 	}
 	if phone != "" {
 		return phone, nil
@@ -158,15 +203,15 @@ func pickIdentifier(phone, email string) (string, error) {
 func (s *UserService) Register(ctx context.Context, req RegisterReq) error {
 	username := strings.TrimSpace(req.Username)
 	if username == "" {
-		return fmt.Errorf("输入账号")
+		return ErrUsernameRequired
 	}
 	password := strings.TrimSpace(req.Password)
 	if password == "" {
-		return fmt.Errorf("输入密码")
+		return ErrPasswordRequired
 	}
 	nickName := strings.TrimSpace(req.NickName)
 	if nickName == "" {
-		return fmt.Errorf("输入昵称")
+		return ErrNicknameRequired
 	}
 	identifier, err := pickIdentifier(req.Phone, req.Email)
 	if err != nil {
@@ -174,10 +219,13 @@ func (s *UserService) Register(ctx context.Context, req RegisterReq) error {
 	}
 	code := strings.TrimSpace(req.Code)
 	if code == "" {
-		return fmt.Errorf("输入验证码")
+		return ErrCodeRequired
 	}
-	if s.RDB == nil {
-		return fmt.Errorf("r 服务暂未开启")
+	if s.KV == nil {
+		return ErrRedisNotConfigured
+	}
+	if err := verifyCaptcha(ctx, s.Captcha, req.CaptchaToken, req.ClientIP); err != nil {
+		return err
 	}
 
 	ok, err := s.verifyCodeService.VerifyCode(ctx, VerifyCodePurposeRegister, identifier, code)
@@ -185,7 +233,7 @@ func (s *UserService) Register(ctx context.Context, req RegisterReq) error {
 		return err
 	}
 	if !ok {
-		return fmt.Errorf("输入验证码")
+		return ErrVerifyCodeInvalid
 	}
 
 	existsKind, existsVal, err := s.userDao.ExistsByAccount(username, req.Phone, req.Email)
@@ -195,13 +243,13 @@ func (s *UserService) Register(ctx context.Context, req RegisterReq) error {
 	if existsKind != 0 {
 		switch existsKind {
 		case 1:
-			return fmt.Errorf("用户名已存在: %s", existsVal)
+			return newError(response.CodeUserAlreadyExists, "err.username_exists", existsVal)
 		case 2:
-			return fmt.Errorf("手机号已存在: %s", existsVal)
+			return newError(response.CodeUserAlreadyExists, "err.phone_exists", existsVal)
 		case 3:
-			return fmt.Errorf("邮箱已存在: %s", existsVal)
+			return newError(response.CodeUserAlreadyExists, "err.email_exists", existsVal)
 		default:
-			return fmt.Errorf("用户已存在")
+			return ErrUserExists
 		}
 	}
 
@@ -249,21 +297,26 @@ func (s *UserService) Login(req LoginReq) (*UserDTO, error) {
 func (s *UserService) LoginWithToken(ctx context.Context, req LoginReq) (*LoginResp, error) {
 	acc := normalizeAccount(req.Account)
 	if acc == "" {
-		return nil, fmt.Errorf("需要账户")
+		return nil, ErrAccountRequired
 	}
 	password := strings.TrimSpace(req.Password)
 	code := strings.TrimSpace(req.Code)
 	if password == "" && code == "" {
-		return nil, fmt.Errorf("需要密码或验证码")
+		return nil, ErrPasswordOrCode
 	}
 	if password != "" && code != "" {
-		return nil, fmt.Errorf("密码和代码不能同时提供")
+		return nil, ErrPasswordAndCode
+	}
+
+	if err := s.checkLoginCaptcha(ctx, acc, req.CaptchaToken, req.ClientIP); err != nil {
+		return nil, err
 	}
 
 	u, err := s.userDao.FindByAccount(acc)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("账户或密码无效")
+			s.recordLoginFailure(ctx, acc)
+			return nil, ErrInvalidCredentials
 		}
 		return nil, err
 	}
@@ -271,23 +324,29 @@ func (s *UserService) LoginWithToken(ctx context.Context, req LoginReq) (*LoginR
 	// 1) 密码登录
 	if password != "" {
 		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
-			return nil, fmt.Errorf("账户或密码无效")
+			s.recordLoginFailure(ctx, acc)
+			return nil, ErrInvalidCredentials
 		}
 	} else {
 		// 2) 验证码登录
-		if s.RDB == nil {
-			return nil, fmt.Errorf("r 服务暂未开启")
+		if s.KV == nil {
+			return nil, ErrRedisNotConfigured
 		}
 		ok, err := s.verifyCodeService.VerifyCode(ctx, VerifyCodePurposeLogin, acc, code)
 		if err != nil {
 			return nil, err
 		}
 		if !ok {
-			return nil, fmt.Errorf("无效验证码")
+			s.recordLoginFailure(ctx, acc)
+			return nil, ErrVerifyCodeInvalid
 		}
 	}
+	s.clearLoginFailures(ctx, acc)
 
 	now := time.Now()
+	if err := checkAccountStatus(s.DB, u, now); err != nil {
+		return nil, err
+	}
 	_ = s.userDao.UpdateFields(u.ID, map[string]any{
 		"last_login_at":  &now,
 		"last_active_at": &now,
@@ -299,9 +358,9 @@ func (s *UserService) LoginWithToken(ctx context.Context, req LoginReq) (*LoginR
 		fresh = u
 	}
 
-	resp := &LoginResp{User: *toUserDTO(fresh)}
+	resp := &LoginResp{User: *toUserDTO(fresh, selfView)}
 
-	if s.RDB == nil {
+	if s.KV == nil {
 		resp.Token = ""
 		return resp, nil
 	}
@@ -310,33 +369,89 @@ func (s *UserService) LoginWithToken(ctx context.Context, req LoginReq) (*LoginR
 	if err != nil {
 		return nil, err
 	}
-	if err := s.tokenService.StoreToken(ctx, token, fresh.ID, s.loginTokenTTL); err != nil {
+	if err := s.tokenService.StoreToken(ctx, token, fresh.ID, s.LoginTokenTTL); err != nil {
 		return nil, err
 	}
+	meta := TokenMeta{
+		DeviceID:  truncateRunes(strings.TrimSpace(req.DeviceID), 64),
+		Device:    truncateRunes(strings.TrimSpace(req.Device), 128),
+		IP:        req.ClientIP,
+		CreatedAt: now,
+	}
+	if err := s.tokenService.StoreTokenMeta(ctx, token, meta, s.LoginTokenTTL); err != nil {
+		log.Printf("store token meta user=%d: %v", fresh.ID, err)
+	}
 	resp.Token = token
 	return resp, nil
 }
 
+// VerifyCaptcha 校验人机验证 token（未开启时直接通过），供发送验证码等接口使用
+func (s *UserService) VerifyCaptcha(ctx context.Context, token, remoteIP string) error {
+	return verifyCaptcha(ctx, s.Captcha, token, remoteIP)
+}
+
+const loginFailTTL = 15 * time.Minute
+
+func loginFailKey(acc string) string { return "im:login:fail:" + acc }
+
+// checkLoginCaptcha 连续失败达到阈值后要求人机验证；没有 Redis 无法计数时每次都要求
+func (s *UserService) checkLoginCaptcha(ctx context.Context, acc, token, remoteIP string) error {
+	if s.Captcha == nil {
+		return nil
+	}
+	if s.RDB != nil && s.CaptchaLoginFailures > 0 {
+		n, err := s.RDB.Get(ctx, loginFailKey(acc)).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if n < s.CaptchaLoginFailures {
+			return nil
+		}
+	}
+	return verifyCaptcha(ctx, s.Captcha, token, remoteIP)
+}
+
+func (s *UserService) recordLoginFailure(ctx context.Context, acc string) {
+	if s.Captcha == nil || s.RDB == nil {
+		return
+	}
+	key := loginFailKey(acc)
+	pipe := s.RDB.TxPipeline()
+	pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, loginFailTTL)
+	_, _ = pipe.Exec(ctx)
+}
+
+func (s *UserService) clearLoginFailures(ctx context.Context, acc string) {
+	if s.Captcha == nil || s.RDB == nil {
+		return
+	}
+	s.RDB.Del(ctx, loginFailKey(acc))
+}
+
 // ForgotPassword 忘记密码（验证码校验后更新密码）
 func (s *UserService) ForgotPassword(ctx context.Context, req ForgotPasswordReq) error {
 	identifier := normalizeAccount(req.Identifier)
 	if identifier == "" {
-		return fmt.Errorf("需要标识符")
+		return ErrIdentifierRequired
 	}
 	newPwd := strings.TrimSpace(req.NewPassword)
 	if newPwd == "" {
-		return fmt.Errorf("输入新密码")
+		return ErrNewPasswordRequired
 	}
 	code := strings.TrimSpace(req.Code)
 	if code == "" {
-		return fmt.Errorf("需要验证码")
+		return ErrCodeRequired
 	}
-	if s.RDB == nil {
-		return fmt.Errorf("r 服务暂未开启")
+	if s.KV == nil {
+		return ErrRedisNotConfigured
 	}
 
 	u, err := s.userDao.FindByAccount(identifier)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
 		return err
 	}
 
@@ -345,19 +460,41 @@ func (s *UserService) ForgotPassword(ctx context.Context, req ForgotPasswordReq)
 		return err
 	}
 	if !ok {
-		return fmt.Errorf("输入验证码")
+		return ErrVerifyCodeInvalid
 	}
 
 	return s.UpdatePassword(u.ID, newPwd)
 }
 
-// GetUser 获取用户信息（脱敏）
-func (s *UserService) GetUser(userID uint64) (*UserDTO, error) {
+// GetUser 以 viewerID 的身份查看 userID 的资料（脱敏）：
+// 本人看到全部字段；非好友看不到手机号/邮箱，签名/最近活跃/动态数按对方隐私设置隐藏。
+// viewerID 为 0 表示系统内部调用（如取昵称头像），不做可见性过滤。
+func (s *UserService) GetUser(viewerID, userID uint64) (*UserDTO, error) {
 	u, err := s.userDao.FindByID(userID)
 	if err != nil {
 		return nil, err
 	}
-	return toUserDTO(u), nil
+	views, err := userViewsFor(s.DB, viewerID, []uint64{userID})
+	if err != nil {
+		return nil, err
+	}
+	v := views[userID]
+	dto := toUserDTO(u, v)
+	// 内部调用只取基础资料，不统计动态数
+	if viewerID != 0 && (v.self || v.friend || !v.privacy.HideMomentCount) {
+		var cnt int64
+		if err := s.DB.Model(&models.Moment{}).Where("user_id = ?", userID).Count(&cnt).Error; err != nil {
+			return nil, err
+		}
+		dto.MomentCount = &cnt
+	}
+	return dto, nil
+}
+
+// SetOnlineStatus 写入在线状态（models.OnlineStatus*）与最后活跃时间，由 WS 连接状态变化驱动
+func (s *UserService) SetOnlineStatus(userID uint64, status uint8, at time.Time) error {
+	return s.DB.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]any{"online_status": status, "last_active_at": &at}).Error
 }
 
 // UpdateAvatar 更新用户头像
@@ -365,7 +502,8 @@ func (s *UserService) UpdateAvatar(userID uint64, avatarURL string) (*UserDTO, e
 	if err := s.userDao.UpdateAvatar(userID, strings.TrimSpace(avatarURL)); err != nil {
 		return nil, err
 	}
-	return s.GetUser(userID)
+	s.invalidateUserBrief(userID)
+	return s.GetUser(userID, userID)
 }
 
 // UpdateUser 更新用户信息
@@ -394,19 +532,28 @@ func (s *UserService) UpdateUser(userID uint64, req UpdateUserReq) (*UserDTO, er
 	if err := s.userDao.UpdateFields(userID, updates); err != nil {
 		return nil, err
 	}
-	return s.GetUser(userID)
+	s.invalidateUserBrief(userID)
+	if req.Nickname != nil {
+		s.DisplayNames.InvalidateUser(userID)
+		// 没有备注的好友按昵称分组，清空索引字母，下次拉好友列表时重新计算
+		if err := s.DB.Model(&models.Friend{}).Where("friend_id = ? AND remark = ?", userID, "").
+			UpdateColumn("name_index", "").Error; err != nil {
+			log.Printf("reset friend name index %d: %v", userID, err)
+		}
+	}
+	return s.GetUser(userID, userID)
 }
 
 // UpdatePassword 更新用户密码（上层自行做验证码/鉴权；这仅负责写库）
 func (s *UserService) UpdatePassword(userID uint64, newPassword string, old ...string) error {
 	newPassword = strings.TrimSpace(newPassword)
 	if newPassword == "" {
-		return fmt.Errorf("输入新密码")
+		return ErrNewPasswordRequired
 	}
 	if old != nil && len(old) > 0 {
 		oldPassword := strings.TrimSpace(old[0])
 		if oldPassword == "" {
-			return fmt.Errorf("输入旧密码")
+			return ErrOldPasswordRequired
 		}
 
 		// 获取用户当前密码
@@ -417,7 +564,7 @@ func (s *UserService) UpdatePassword(userID uint64, newPassword string, old ...s
 
 		// 验证旧密码
 		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(oldPassword)); err != nil {
-			return fmt.Errorf("旧密码不正确")
+			return ErrOldPasswordWrong
 		}
 	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
@@ -427,16 +574,25 @@ func (s *UserService) UpdatePassword(userID uint64, newPassword string, old ...s
 	return s.userDao.UpdatePassword(userID, string(hash))
 }
 
-// SearchUsers 按关键字搜索用户（username/nickname/uid），返回脱敏数据
-func (s *UserService) SearchUsers(keyword string, excludeUserID uint64, limit, offset int) ([]UserDTO, error) {
-	users, err := s.userDao.SearchUsers(keyword, excludeUserID, limit, offset)
+// SearchUsers 按关键字搜索用户（username/nickname/uid），返回脱敏数据；
+// viewerID 为搜索人（结果中排除自己），字段可见性同 GetUser。
+func (s *UserService) SearchUsers(keyword string, viewerID uint64, limit, offset int) ([]UserDTO, error) {
+	users, err := s.userDao.SearchUsers(keyword, viewerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, len(users))
+	for i := range users {
+		ids[i] = users[i].ID
+	}
+	views, err := userViewsFor(s.DB, viewerID, ids)
 	if err != nil {
 		return nil, err
 	}
 
 	out := make([]UserDTO, 0, len(users))
 	for i := range users {
-		dto := toUserDTO(&users[i])
+		dto := toUserDTO(&users[i], views[users[i].ID])
 		if dto != nil {
 			out = append(out, *dto)
 		}
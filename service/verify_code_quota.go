@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cydxin/chat-sdk/response"
+)
+
+// SendCodeLimits 发送验证码的配额（在 60 秒冷却之外），<=0 表示不限制。
+// 计数存 KVStore（默认 Redis），多实例共享。
+type SendCodeLimits struct {
+	PerIdentifierPerDay int // 同一手机号/邮箱每天最多发送次数（不分用途）
+	PerIPPerDay         int // 同一 IP 每天最多发送次数
+	// IdentifiersPerIPPerHour 同一 IP 一小时内请求的不同手机号/邮箱数上限，超过即锁定该 IP（枚举号码/轰炸他人）
+	IdentifiersPerIPPerHour int
+	// IPsPerIdentifierPerHour 同一手机号/邮箱一小时内被多少个不同 IP 请求后锁定（分布式轰炸）
+	IPsPerIdentifierPerHour int
+	// LockDuration 可疑行为的锁定时长，<=0 时使用 24h
+	LockDuration time.Duration
+}
+
+// DefaultSendCodeLimits 默认配额
+var DefaultSendCodeLimits = SendCodeLimits{
+	PerIdentifierPerDay:     10,
+	PerIPPerDay:             50,
+	IdentifiersPerIPPerHour: 10,
+	IPsPerIdentifierPerHour: 5,
+	LockDuration:            24 * time.Hour,
+}
+
+// 配额超限的维度与原因
+const (
+	SendCodeScopeIdentifier = "identifier"
+	SendCodeScopeIP         = "ip"
+
+	SendCodeReasonDailyLimit = "daily_limit" // 当天次数用尽
+	SendCodeReasonLocked     = "locked"      // 命中可疑行为被锁定
+)
+
+var (
+	// ErrSendCodeQuota 验证码当天发送次数已用尽
+	ErrSendCodeQuota = newError(response.CodeRateLimited, "err.send_code_quota")
+	// ErrSendCodeLocked 请求异常，暂时禁止发送验证码
+	ErrSendCodeLocked = newError(response.CodeRateLimited, "err.send_code_locked")
+)
+
+// SendCodeQuotaError 验证码配额超限的详情，handler 把它放在响应 data 中并设置 Retry-After。
+// errors.Is 可匹配 ErrSendCodeQuota / ErrSendCodeLocked。
+type SendCodeQuotaError struct {
+	Scope      string `json:"scope"`           // identifier / ip
+	Reason     string `json:"reason"`          // daily_limit / locked
+	Limit      int    `json:"limit,omitempty"` // 触发的阈值
+	RetryAfter int64  `json:"retry_after"`     // 多少秒后可重试
+}
+
+func (e *SendCodeQuotaError) Unwrap() error {
+	if e.Reason == SendCodeReasonLocked {
+		return ErrSendCodeLocked
+	}
+	return ErrSendCodeQuota
+}
+
+func (e *SendCodeQuotaError) Error() string {
+	return e.Unwrap().Error()
+}
+
+func (l SendCodeLimits) lockDuration() time.Duration {
+	if l.LockDuration > 0 {
+		return l.LockDuration
+	}
+	return 24 * time.Hour
+}
+
+func sendCodeLockKey(scope, value string) string {
+	return fmt.Sprintf("im:verify_code_lock:%s:%s", scope, value)
+}
+
+// checkSendCodeLocks 检查锁定并记录“IP ↔ 号码”的对应关系，超出阈值时锁定；冷却期内的重复请求也会计入
+func (s *VerifyCodeService) checkSendCodeLocks(ctx context.Context, identifier, clientIP string) error {
+	targets := [][2]string{{SendCodeScopeIdentifier, identifier}}
+	if clientIP != "" {
+		targets = append(targets, [2]string{SendCodeScopeIP, clientIP})
+	}
+	for _, t := range targets {
+		if ttl, err := s.kv.TTL(ctx, sendCodeLockKey(t[0], t[1])); err != nil {
+			return err
+		} else if ttl > 0 {
+			return &SendCodeQuotaError{Scope: t[0], Reason: SendCodeReasonLocked, RetryAfter: int64(ttl.Seconds())}
+		}
+	}
+	if clientIP == "" {
+		return nil
+	}
+
+	hour := time.Now().Truncate(time.Hour).Unix()
+	checks := []struct {
+		scope, value, member string
+		limit                int
+	}{
+		{SendCodeScopeIP, clientIP, identifier, s.Limits.IdentifiersPerIPPerHour},
+		{SendCodeScopeIdentifier, identifier, clientIP, s.Limits.IPsPerIdentifierPerHour},
+	}
+	for _, c := range checks {
+		if c.limit <= 0 {
+			continue
+		}
+		key := fmt.Sprintf("im:verify_code_seen:%s:%s:%d", c.scope, c.value, hour)
+		if err := s.kv.SAdd(ctx, key, c.member); err != nil {
+			return err
+		}
+		_ = s.kv.Expire(ctx, key, time.Hour+time.Minute)
+		members, err := s.kv.SMembers(ctx, key)
+		if err != nil {
+			return err
+		}
+		if len(members) <= c.limit {
+			continue
+		}
+		lock := s.Limits.lockDuration()
+		if err := s.kv.Set(ctx, sendCodeLockKey(c.scope, c.value), "1", lock); err != nil {
+			return err
+		}
+		log.Printf("verify code: lock %s=%s for %s (%d distinct in an hour, limit %d)", c.scope, c.value, lock, len(members), c.limit)
+		return &SendCodeQuotaError{Scope: c.scope, Reason: SendCodeReasonLocked, Limit: c.limit, RetryAfter: int64(lock.Seconds())}
+	}
+	return nil
+}
+
+// consumeSendCodeQuota 累加当天的发送次数（按 UTC 自然日），超出时返回配额错误
+func (s *VerifyCodeService) consumeSendCodeQuota(ctx context.Context, identifier, clientIP string) error {
+	now := time.Now()
+	day := now.Truncate(24 * time.Hour)
+	retryAfter := int64(day.Add(24 * time.Hour).Sub(now).Seconds())
+	checks := []struct {
+		scope, value string
+		limit        int
+	}{
+		{SendCodeScopeIdentifier, identifier, s.Limits.PerIdentifierPerDay},
+		{SendCodeScopeIP, clientIP, s.Limits.PerIPPerDay},
+	}
+	for _, c := range checks {
+		if c.limit <= 0 || c.value == "" {
+			continue
+		}
+		key := fmt.Sprintf("im:verify_code_day:%s:%s:%d", c.scope, c.value, day.Unix())
+		n, err := s.kv.Incr(ctx, key, 24*time.Hour+time.Minute)
+		if err != nil {
+			return err
+		}
+		if n > int64(c.limit) {
+			return &SendCodeQuotaError{Scope: c.scope, Reason: SendCodeReasonDailyLimit, Limit: c.limit, RetryAfter: retryAfter}
+		}
+	}
+	return nil
+}
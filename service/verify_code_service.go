@@ -3,12 +3,14 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"log"
 	"math/big"
 	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/redis/go-redis/v9"
 )
 
 type VerifyCodePurpose string
@@ -19,35 +21,46 @@ const (
 	VerifyCodePurposeLogin          VerifyCodePurpose = "login"
 )
 
-// VerifyCodeService 负责验证码的生成、存储与校验（Redis）。
-// 注意：这里不负责“短信/邮件发送”，调用方可自行集成第三方通道。
-// 最小实现：生成 6 位数字验证码，写入 Redis，返回 code 便于调用层发送。
+// VerifyCodeService 负责验证码的生成、存储与校验（KVStore，默认 Redis）。
+// 配置 Sender 时由它投递短信/邮件；未配置时只生成 6 位数字验证码写入 Redis，返回 code 由调用层发送。
 //
 // Redis Key: im:verify_code:{purpose}:{identifier}
 // TTL: 默认 5 分钟
 // Cooldown: 默认 60 秒（防刷，可选；这里实现了）
 // Cooldown Key: im:verify_code_cd:{purpose}:{identifier}
+// 每日配额与锁定（见 SendCodeLimits）：im:verify_code_day:* / im:verify_code_seen:* / im:verify_code_lock:*
 //
 // identifier 统一使用 string（手机号/邮箱），并做 TrimSpace；邮箱会 ToLower。
 // purpose 用于区分注册/找回密码等场景，避免串码。
 type VerifyCodeService struct {
-	rdb *redis.Client
+	kv KVStore
+	// Sender 验证码投递通道（见 CodeSender），为 nil 时不投递
+	Sender CodeSender
+	// Limits 冷却之外的每日配额与可疑行为锁定，默认 DefaultSendCodeLimits
+	Limits SendCodeLimits
 
 	ttl      time.Duration
 	cooldown time.Duration
 }
 
-func NewVerifyCodeService(rdb *redis.Client) *VerifyCodeService {
+// NewVerifyCodeService 使用 Redis 存储（rdb 为 nil 时返回 ErrRedisNotConfigured）
+func NewVerifyCodeService(rdb redis.UniversalClient) *VerifyCodeService {
+	return NewVerifyCodeServiceWithKV(kvFromRedis(rdb))
+}
+
+// NewVerifyCodeServiceWithKV 使用任意 KVStore 存储
+func NewVerifyCodeServiceWithKV(kv KVStore) *VerifyCodeService {
 	return &VerifyCodeService{
-		rdb:      rdb,
+		kv:       kv,
+		Limits:   DefaultSendCodeLimits,
 		ttl:      5 * time.Minute,
 		cooldown: 60 * time.Second,
 	}
 }
 
 func (s *VerifyCodeService) ensure() error {
-	if s == nil || s.rdb == nil {
-		return fmt.Errorf("redis client is nil")
+	if s == nil || s.kv == nil {
+		return ErrRedisNotConfigured
 	}
 	return nil
 }
@@ -84,31 +97,43 @@ type SendCodeResult struct {
 	Code       string `json:"code,omitempty"` // 是否返回由上层/调用方决定；这里总是返回，便于集成发送通道与测试
 }
 
-// SendCode 生成验证码并写入 Redis。
-// 返回 code 供调用方发送短信/邮件。
+// SendCode 生成验证码并写入 KVStore，配置了 Sender 时同步投递（失败则撤销本次验证码）。
+// 返回 code 供调用方自行发送或调试。不区分来源 IP，按 IP 的配额不生效，HTTP 接口请用 SendCodeFrom。
 func (s *VerifyCodeService) SendCode(ctx context.Context, purpose VerifyCodePurpose, identifier string) (*SendCodeResult, error) {
+	return s.SendCodeFrom(ctx, purpose, identifier, "")
+}
+
+// SendCodeFrom 同 SendCode，并按 clientIP 统计配额；超出 Limits 时返回 *SendCodeQuotaError
+func (s *VerifyCodeService) SendCodeFrom(ctx context.Context, purpose VerifyCodePurpose, identifier, clientIP string) (*SendCodeResult, error) {
 	if err := s.ensure(); err != nil {
 		return nil, err
 	}
 	identifier = s.normalizeIdentifier(identifier)
 	if identifier == "" {
-		return nil, fmt.Errorf("identifier is required")
+		return nil, ErrIdentifierRequired
 	}
 	if purpose == "" {
-		return nil, fmt.Errorf("purpose is required")
+		return nil, ErrPurposeRequired
+	}
+
+	if err := s.checkSendCodeLocks(ctx, identifier, clientIP); err != nil {
+		return nil, err
 	}
 
 	// cooldown
 	cdKey := s.cooldownKey(purpose, identifier)
-	ok, err := s.rdb.SetNX(ctx, cdKey, "1", s.cooldown).Result()
+	ok, err := s.kv.SetNX(ctx, cdKey, "1", s.cooldown)
 	if err != nil {
 		return nil, err
 	}
 	if !ok {
 		// 仍然视为成功，但提示稍后再试
-		ttl, _ := s.rdb.TTL(ctx, cdKey).Result()
+		ttl, _ := s.kv.TTL(ctx, cdKey)
 		return &SendCodeResult{TTLSeconds: int64(ttl.Seconds()), Code: ""}, nil
 	}
+	if err := s.consumeSendCodeQuota(ctx, identifier, clientIP); err != nil {
+		return nil, err
+	}
 
 	code, err := s.generate6Digits()
 	if err != nil {
@@ -116,10 +141,22 @@ func (s *VerifyCodeService) SendCode(ctx context.Context, purpose VerifyCodePurp
 	}
 
 	key := s.codeKey(purpose, identifier)
-	if err := s.rdb.Set(ctx, key, code, s.ttl).Err(); err != nil {
+	if err := s.kv.Set(ctx, key, code, s.ttl); err != nil {
 		return nil, err
 	}
 
+	if s.Sender != nil {
+		if err := s.Sender.Send(ctx, purpose, identifier, code, s.ttl); err != nil {
+			// 投递失败时撤销验证码与冷却，允许立即重试
+			_ = s.kv.Del(ctx, key, cdKey)
+			if errors.Is(err, ErrCodeSenderUnsupported) {
+				return nil, ErrIdentifierUnsupported
+			}
+			log.Printf("deliver verify code purpose=%s: %v", purpose, err)
+			return nil, ErrCodeDelivery
+		}
+	}
+
 	return &SendCodeResult{TTLSeconds: int64(s.ttl.Seconds()), Code: code}, nil
 }
 
@@ -134,19 +171,19 @@ func (s *VerifyCodeService) VerifyCode(ctx context.Context, purpose VerifyCodePu
 	identifier = s.normalizeIdentifier(identifier)
 	code = strings.TrimSpace(code)
 	if identifier == "" {
-		return false, fmt.Errorf("identifier is required")
+		return false, ErrIdentifierRequired
 	}
 	if purpose == "" {
-		return false, fmt.Errorf("purpose is required")
+		return false, ErrPurposeRequired
 	}
 	if code == "" {
-		return false, fmt.Errorf("输入验证码")
+		return false, ErrCodeRequired
 	}
 
 	key := s.codeKey(purpose, identifier)
-	val, err := s.rdb.Get(ctx, key).Result()
+	val, err := s.kv.Get(ctx, key)
 	if err != nil {
-		if err == redis.Nil {
+		if errors.Is(err, ErrKVNotFound) {
 			return false, nil
 		}
 		return false, err
@@ -154,6 +191,6 @@ func (s *VerifyCodeService) VerifyCode(ctx context.Context, purpose VerifyCodePu
 	if strings.TrimSpace(val) != code {
 		return false, nil
 	}
-	_ = s.rdb.Del(ctx, key).Err()
+	_ = s.kv.Del(ctx, key)
 	return true, nil
 }
@@ -2,11 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
-	"github.com/go-redis/redis/v8"
+	"github.com/redis/go-redis/v9"
 )
 
 func TestVerifyCodeService_SendAndVerify(t *testing.T) {
@@ -67,3 +68,77 @@ func TestVerifyCodeService_Cooldown(t *testing.T) {
 		t.Fatalf("expected empty code due to cooldown")
 	}
 }
+
+func TestVerifyCodeService_MemoryKV(t *testing.T) {
+	kv := NewMemoryKVStore()
+	now := time.Unix(1700000000, 0)
+	kv.now = func() time.Time { return now }
+	svc := NewVerifyCodeServiceWithKV(kv)
+	ctx := context.Background()
+
+	ret, err := svc.SendCode(ctx, VerifyCodePurposeLogin, "a@b.com")
+	if err != nil || ret.Code == "" {
+		t.Fatalf("SendCode: %#v %v", ret, err)
+	}
+	// cooldown 内不再生成，返回剩余冷却秒数
+	again, err := svc.SendCode(ctx, VerifyCodePurposeLogin, "a@b.com")
+	if err != nil || again.Code != "" || again.TTLSeconds != 60 {
+		t.Fatalf("cooldown: %#v %v", again, err)
+	}
+
+	// 过期后校验失败
+	now = now.Add(6 * time.Minute)
+	if ok, err := svc.VerifyCode(ctx, VerifyCodePurposeLogin, "a@b.com", ret.Code); err != nil || ok {
+		t.Fatalf("expired code: %v %v", ok, err)
+	}
+
+	if _, err := NewVerifyCodeServiceWithKV(nil).SendCode(ctx, VerifyCodePurposeLogin, "a@b.com"); !errors.Is(err, ErrRedisNotConfigured) {
+		t.Fatalf("nil kv: %v", err)
+	}
+}
+
+func TestVerifyCodeService_Quota(t *testing.T) {
+	kv := NewMemoryKVStore()
+	now := time.Unix(1700000000, 0)
+	kv.now = func() time.Time { return now }
+	svc := NewVerifyCodeServiceWithKV(kv)
+	svc.Limits = SendCodeLimits{PerIdentifierPerDay: 2, PerIPPerDay: 3, IdentifiersPerIPPerHour: 3, IPsPerIdentifierPerHour: 2}
+	ctx := context.Background()
+
+	// 同一号码每天 2 次（冷却结束后再发）
+	for i := 0; i < 2; i++ {
+		if _, err := svc.SendCodeFrom(ctx, VerifyCodePurposeLogin, "13800138000", "1.1.1.1"); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		now = now.Add(61 * time.Second)
+	}
+	_, err := svc.SendCodeFrom(ctx, VerifyCodePurposeLogin, "13800138000", "1.1.1.1")
+	var qe *SendCodeQuotaError
+	if !errors.As(err, &qe) || !errors.Is(err, ErrSendCodeQuota) || qe.Scope != SendCodeScopeIdentifier || qe.Limit != 2 || qe.RetryAfter <= 0 {
+		t.Fatalf("identifier daily limit: %#v %v", qe, err)
+	}
+
+	// 同一 IP 请求过多不同号码：锁定 IP
+	for _, id := range []string{"13800138001", "13800138002"} {
+		if _, err := svc.SendCodeFrom(ctx, VerifyCodePurposeLogin, id, "2.2.2.2"); err != nil {
+			t.Fatalf("send %s: %v", id, err)
+		}
+	}
+	_, _ = svc.SendCodeFrom(ctx, VerifyCodePurposeLogin, "13800138003", "2.2.2.2")
+	_, err = svc.SendCodeFrom(ctx, VerifyCodePurposeLogin, "13800138004", "2.2.2.2")
+	if !errors.As(err, &qe) || !errors.Is(err, ErrSendCodeLocked) || qe.Scope != SendCodeScopeIP {
+		t.Fatalf("ip lock: %#v %v", qe, err)
+	}
+	if _, err = svc.SendCodeFrom(ctx, VerifyCodePurposeLogin, "13800138009", "2.2.2.2"); !errors.Is(err, ErrSendCodeLocked) {
+		t.Fatalf("ip should stay locked: %v", err)
+	}
+
+	// 同一号码被多个 IP 请求：锁定号码
+	for _, ip := range []string{"3.3.3.1", "3.3.3.2"} {
+		_, _ = svc.SendCodeFrom(ctx, VerifyCodePurposeRegister, "a@b.com", ip)
+	}
+	_, err = svc.SendCodeFrom(ctx, VerifyCodePurposeRegister, "a@b.com", "3.3.3.3")
+	if !errors.As(err, &qe) || qe.Scope != SendCodeScopeIdentifier || qe.Reason != SendCodeReasonLocked {
+		t.Fatalf("identifier lock: %#v %v", qe, err)
+	}
+}
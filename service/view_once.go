@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrViewOnceRoomType 阅后即焚只能在私聊中发送
+	ErrViewOnceRoomType = newError(response.CodeParamError, "err.view_once_private_only")
+	// ErrViewOnceViewed 已查看过（或内容已清除）
+	ErrViewOnceViewed = newError(response.CodeParamError, "err.view_once_viewed")
+)
+
+// viewOnceExtra 阅后即焚消息对外返回 / 清除后保留的 extra
+var viewOnceExtra = datatypes.JSON(`{"view_once":true}`)
+
+// checkViewOnceRoom 阅后即焚仅支持私聊
+func (s *MessageService) checkViewOnceRoom(roomID uint64) error {
+	var room models.Room
+	if err := s.DB.Select("id, type").First(&room, roomID).Error; err != nil {
+		return err
+	}
+	if room.Type != 1 {
+		return ErrViewOnceRoomType
+	}
+	return nil
+}
+
+// OpenViewOnceMessage 接收方查看阅后即焚消息：只能查看一次（记录在 message_status.viewed_at），
+// 返回原内容后清除消息的 content/extra，并推送 view_once_viewed 给房间成员。发送者不能调用。
+func (s *MessageService) OpenViewOnceMessage(userID, messageID uint64) (*MessageListItemDTO, error) {
+	var msg models.Message
+	err := s.Tx(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_view_once = ?", messageID, true).
+			First(&msg).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMessageNotFound
+			}
+			return err
+		}
+		if msg.SenderID == userID {
+			return ErrPermissionDenied
+		}
+		var n int64
+		if err := tx.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", msg.RoomID, userID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrPermissionDenied
+		}
+		if msg.Content == "" {
+			return ErrViewOnceViewed
+		}
+		var st models.MessageStatus
+		if err := tx.Where("message_id = ? AND user_id = ?", messageID, userID).Limit(1).Find(&st).Error; err != nil {
+			return err
+		}
+		if st.ViewedAt != nil {
+			return ErrViewOnceViewed
+		}
+
+		now := time.Now()
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "message_id"}, {Name: "user_id"}, {Name: "room_id"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"viewed_at":  now,
+				"is_read":    true,
+				"read_at":    gorm.Expr("COALESCE(read_at, ?)", now),
+				"updated_at": now,
+			}),
+		}).Create(&models.MessageStatus{
+			MessageID: messageID, UserID: userID, RoomID: msg.RoomID,
+			IsRead: true, ReadAt: &now, IsDelivered: true, DeliveredAt: &now, ViewedAt: &now,
+			CreatedAt: now, UpdatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := s.Archive.record(tx, ArchiveEventViewOnce, userID, []models.Message{msg}); err != nil {
+			return err
+		}
+		// 私聊只有一个接收方，查看即清除
+		return tx.Model(&models.Message{}).Where("id = ?", messageID).
+			Updates(map[string]any{"content": "", "extra": viewOnceExtra, "updated_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.DB.Select("id, username, nickname, avatar").First(&msg.Sender, msg.SenderID).Error
+	dto := toMessageListItemDTO(&msg)
+	dto.Content, dto.Extra, dto.Voice = msg.Content, msg.Extra, voiceFromExtra(msg.Type, msg.Extra)
+
+	var members []uint64
+	_ = s.DB.Model(&models.RoomUser{}).Where("room_id = ?", msg.RoomID).Pluck("user_id", &members).Error
+	s.Emit(members, &message.ViewOnceViewedEvent{RoomID: msg.RoomID, MessageID: messageID, ViewerID: userID})
+	return dto, nil
+}
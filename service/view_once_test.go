@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOpenViewOnceMessage_Rejects(t *testing.T) {
+	db, mock, sqldb := newMockDB(t)
+	defer sqldb.Close()
+	s := &MessageService{Service: &Service{DB: db}}
+	msgRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "room_id", "sender_id", "type", "content", "is_view_once"}).
+			AddRow(9, 5, 1, 2, "https://img/x.png", true)
+	}
+
+	// 发送者不能查看自己的阅后即焚消息
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `im_message` WHERE \\(id = \\? AND is_view_once = \\?\\) AND `im_message`.`deleted_at` IS NULL ORDER BY `im_message`.`id` LIMIT \\? FOR UPDATE").
+		WithArgs(9, true, 1).
+		WillReturnRows(msgRows())
+	mock.ExpectRollback()
+	if _, err := s.OpenViewOnceMessage(1, 9); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("sender: want ErrPermissionDenied, got %v", err)
+	}
+
+	// 已查看过
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `im_message`").WillReturnRows(msgRows())
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `im_room_user` WHERE room_id = \\? AND user_id = \\?").
+		WithArgs(5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT \\* FROM `im_message_status` WHERE message_id = \\? AND user_id = \\? LIMIT \\?").
+		WithArgs(9, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "user_id", "viewed_at"}).AddRow(1, 9, 2, time.Now()))
+	mock.ExpectRollback()
+	if _, err := s.OpenViewOnceMessage(2, 9); !errors.Is(err, ErrViewOnceViewed) {
+		t.Fatalf("viewed: want ErrViewOnceViewed, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
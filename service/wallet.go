@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+
+	"github.com/cydxin/chat-sdk/response"
+)
+
+// ErrWalletNotConfigured 未注入钱包实现
+var ErrWalletNotConfigured = newError(response.CodeInternalError, "err.wallet_not_configured")
+
+// Wallet 钱包接口（由业务方实现，通过 chat_sdk.WithWallet 注入）。
+// 金额单位：分。bizID 为幂等键，同一个 bizID 重复调用必须只生效一次。
+type Wallet interface {
+	// Debit 扣减用户余额，余额不足应返回 error
+	Debit(ctx context.Context, userID uint64, amount int64, bizID string) error
+	// Credit 增加用户余额
+	Credit(ctx context.Context, userID uint64, amount int64, bizID string) error
+}
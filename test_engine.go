@@ -0,0 +1,133 @@
+package chat_sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testDBSeq atomic.Int64
+
+// TestEngine 测试引擎：SQLite 内存库 + miniredis，WS 推送同步记录，供嵌入 SDK 的应用写单元测试
+type TestEngine struct {
+	*ChatEngine
+
+	// Redis 内置的 miniredis（传入 WithRDB 时为 nil），可用 FastForward 模拟 TTL 过期
+	Redis *miniredis.Miniredis
+
+	mu     sync.Mutex
+	pushed map[uint64][]json.RawMessage
+
+	ownDB, ownRDB bool
+}
+
+// NewTestEngine 创建测试引擎，每次调用都是独立的空库。
+// 与 NewEngine 不同，它不走单例，会直接替换全局 Instance，因此不要在并行测试中使用；
+// 不启动定时任务，默认关闭链接预览与群头像合成并开启 Debug（发送验证码接口返回验证码）。
+// opts 可覆盖以上配置，传入 WithDB / WithRDB 时不再创建对应的内存实现。
+//
+// 使用示例:
+//
+//	e, err := chat_sdk.NewTestEngine()
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	defer e.Close()
+//	_ = e.MemberService.SendFriendRequest(aliceID, bobID, "hi")
+//	types := e.PushedTypes(bobID) // ["friend_request"]
+//	srv := httptest.NewServer(e.Handler(nil)) // 也可以走 HTTP 接口（见 test_engine_test.go）
+func NewTestEngine(opts ...Option) (*TestEngine, error) {
+	defaults := []Option{
+		WithServiceDebug(true),
+		WithLinkPreview(false),
+		func(c *Config) { c.GroupAvatarMerge.Enabled = false },
+	}
+	c := applyOptions(append(defaults, opts...))
+
+	te := &TestEngine{pushed: make(map[uint64][]json.RawMessage)}
+	if c.DB == nil {
+		// 每个引擎独立命名的共享缓存内存库：同一引擎内多连接可见，引擎之间隔离
+		dsn := fmt.Sprintf("file:chat_sdk_test_%d?mode=memory&cache=shared&_pragma=busy_timeout(5000)", testDBSeq.Add(1))
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			return nil, err
+		}
+		c.DB = db
+		te.ownDB = true
+	}
+	if c.RDB = service.NormalizeRedisClient(c.RDB); c.RDB == nil {
+		mr, err := miniredis.Run()
+		if err != nil {
+			return nil, err
+		}
+		te.Redis = mr
+		c.RDB = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		te.ownRDB = true
+	}
+
+	e, err := newEngine(c)
+	if err != nil {
+		return nil, err
+	}
+	te.ChatEngine = e
+	te.WsServer.tap = te.record
+	// newEngine 中迁移失败只记日志，这里再迁移一次以便把错误返回给测试
+	if err := te.AutoMigrate(); err != nil {
+		te.Close()
+		return nil, err
+	}
+	return te, nil
+}
+
+func (e *TestEngine) record(userID uint64, msg []byte) {
+	e.mu.Lock()
+	e.pushed[userID] = append(e.pushed[userID], append(json.RawMessage(nil), msg...))
+	e.mu.Unlock()
+}
+
+// Pushed 返回并清空 userID 收到的推送（与 WS / 长轮询下发内容一致，按时间顺序）
+func (e *TestEngine) Pushed(userID uint64) []json.RawMessage {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := e.pushed[userID]
+	delete(e.pushed, userID)
+	return out
+}
+
+// PushedTypes 返回并清空 userID 收到的推送的 type 字段，便于快速断言
+func (e *TestEngine) PushedTypes(userID uint64) []string {
+	var types []string
+	for _, raw := range e.Pushed(userID) {
+		var probe struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(raw, &probe)
+		types = append(types, probe.Type)
+	}
+	return types
+}
+
+// Close 停止 WS 主循环，关闭内存库与 miniredis
+func (e *TestEngine) Close() {
+	if e.ChatEngine == nil {
+		return
+	}
+	e.WsServer.Stop()
+	if e.ownRDB {
+		_ = e.config.RDB.Close()
+		e.Redis.Close()
+	}
+	if e.ownDB {
+		if sqlDB, err := e.config.DB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package chat_sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/response"
+	"github.com/cydxin/chat-sdk/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 按 NewTestEngine 的文档示例走一遍：建引擎（含完整迁移）、断言推送，再走一次 HTTP 登录与鉴权接口。
+// 模型的表或索引定义互相冲突时 NewTestEngine 会直接失败
+func TestNewTestEngine_DocExample(t *testing.T) {
+	e, err := NewTestEngine()
+	if err != nil {
+		t.Fatalf("NewTestEngine: %v", err)
+	}
+	defer e.Close()
+	if err := e.AutoMigrate(); err != nil {
+		t.Fatalf("AutoMigrate again: %v", err)
+	}
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	alice := models.User{UID: "u-alice", Username: "alice", Nickname: "Alice", Password: string(hash)}
+	bob := models.User{UID: "u-bob", Username: "bob", Nickname: "Bob", Password: string(hash)}
+	if err := e.config.DB.Create(&alice).Error; err != nil {
+		t.Fatalf("create alice: %v", err)
+	}
+	if err := e.config.DB.Create(&bob).Error; err != nil {
+		t.Fatalf("create bob: %v", err)
+	}
+
+	if err := e.MemberService.SendFriendRequest(alice.ID, bob.ID, "hi"); err != nil {
+		t.Fatalf("SendFriendRequest: %v", err)
+	}
+	if got := e.PushedTypes(bob.ID); len(got) != 1 || got[0] != "friend_request" {
+		t.Fatalf("pushed = %v", got)
+	}
+
+	srv := httptest.NewServer(e.Handler(nil))
+	defer srv.Close()
+	call := func(method, path, token string, body any) response.Response {
+		t.Helper()
+		rd := bytes.NewReader(nil)
+		if body != nil {
+			b, _ := json.Marshal(body)
+			rd = bytes.NewReader(b)
+		}
+		req, _ := http.NewRequest(method, srv.URL+"/api/v1"+path, rd)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var out response.Response
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != http.StatusOK || out.Code != response.CodeSuccess {
+			t.Fatalf("%s: status=%d resp=%+v err=%v", path, resp.StatusCode, out, err)
+		}
+		return out
+	}
+
+	var login service.LoginResp
+	b, _ := json.Marshal(call(http.MethodPost, "/user/login", "", service.LoginReq{Account: "alice", Password: "secret123"}).Data)
+	if err := json.Unmarshal(b, &login); err != nil || login.Token == "" {
+		t.Fatalf("login: %s %v", b, err)
+	}
+	var me service.UserDTO
+	b, _ = json.Marshal(call(http.MethodGet, "/user/info", login.Token, nil).Data)
+	if err := json.Unmarshal(b, &me); err != nil || me.ID != alice.ID || me.Nickname != "Alice" {
+		t.Fatalf("user info: %s %v", b, err)
+	}
+}
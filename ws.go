@@ -1,27 +1,62 @@
 package chat_sdk
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/service"
 	"github.com/gorilla/websocket"
 )
 
-const (
-	// Time 写入超时时间
-	writeWait = 10 * time.Second
+// WsLimits WebSocket 连接参数（见 WithWsLimits），字段 <=0 使用 DefaultWsLimits 对应值
+type WsLimits struct {
+	// MaxMessageSize 客户端单条消息最大字节数
+	MaxMessageSize int64
+	// PongWait pong 超时时间，ping 间隔取其 9/10
+	PongWait time.Duration
+	// WriteWait 写入超时时间
+	WriteWait time.Duration
+	// SendBuffer 每个连接的待发送消息缓冲条数
+	SendBuffer int
+	// DropPolicy 发送缓冲满时的处理方式，默认 WsDropNewest
+	DropPolicy WsDropPolicy
+}
 
-	// Time pong超时时间
-	pongWait = 60 * time.Second
+// DefaultWsLimits 默认 WS 连接参数
+var DefaultWsLimits = WsLimits{
+	MaxMessageSize: 512,
+	PongWait:       60 * time.Second,
+	WriteWait:      10 * time.Second,
+	SendBuffer:     256,
+}
 
-	// Send 对应的ping 必须小于pong
-	pingPeriod = (pongWait * 9) / 10
+func (l WsLimits) withDefaults() WsLimits {
+	if l.MaxMessageSize <= 0 {
+		l.MaxMessageSize = DefaultWsLimits.MaxMessageSize
+	}
+	if l.PongWait <= 0 {
+		l.PongWait = DefaultWsLimits.PongWait
+	}
+	if l.WriteWait <= 0 {
+		l.WriteWait = DefaultWsLimits.WriteWait
+	}
+	if l.SendBuffer <= 0 {
+		l.SendBuffer = DefaultWsLimits.SendBuffer
+	}
+	return l
+}
 
-	// Maximum 对等端允许消息大小
-	maxMessageSize = 512
-)
+// pingPeriod ping 间隔，必须小于 pong 超时
+func (l WsLimits) pingPeriod() time.Duration {
+	return (l.PongWait * 9) / 10
+}
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
@@ -39,8 +74,9 @@ type Client struct {
 	// 🔗链接
 	conn *websocket.Conn
 
-	// 消息缓冲区
-	send chan []byte
+	// 消息缓冲区：send 为普通通道（聊天消息等），sendHigh 为高优先级通道（握手、心跳、错误、系统广播），见 ws_send.go
+	send     chan []byte
+	sendHigh chan []byte
 
 	// UserID 和用户关联
 	UserID uint64
@@ -57,6 +93,27 @@ type Client struct {
 	Nickname string
 
 	Avatar string
+
+	// rooms 该连接订阅的房间（建连时按成员关系加载，受 hub.mu 保护）
+	rooms map[uint64]struct{}
+
+	// connectedAt / remoteIP 建连时间与客户端 IP（管理接口展示）
+	connectedAt time.Time
+	remoteIP    string
+	// lastActive 最后一次收到客户端消息的时间（UnixNano，不含 pong），用于空闲策略
+	lastActive atomic.Int64
+
+	// sendMu 保护向 send 写入与关闭（见 ws_send.go），sendClosed 后的写入直接丢弃
+	sendMu      sync.Mutex
+	sendClosed  bool
+	slowClosing atomic.Bool
+
+	// unbindToken 解除与 token 的绑定（见 ws_token.go），未绑定时为 nil
+	unbindToken  func()
+	boundClosing atomic.Bool
+
+	// registered 主循环把连接加入 userClients 后关闭（见 attach），为 nil 时不通知
+	registered chan struct{}
 }
 
 // UserSession 用户级别共享状态（同一用户多设备/多连接复用）
@@ -73,29 +130,59 @@ type UserSession struct {
 
 	// dirty 表示 ReadList 有更新但尚未落库
 	dirty bool
+	// readVersion ReadList 每次变化自增，flush 成功后仅当版本未变才清 dirty（避免 flush 期间的新回执丢失）
+	readVersion uint64
 	// lastFlush 上次落库时间
 	lastFlush time.Time
 
 	// lastReadChangeAt ReadList 最后一次变化时间（用于回收已落库且长时间无变化的数据）
 	lastReadChangeAt time.Time
+
+	// replay 断线续传用的事件缓冲（见 ws_resume.go）
+	replay replayBuffer
+
+	// presence 在线状态（取值同 models.OnlineStatus*，见 ws_idle.go）
+	presence atomic.Uint32
+	// invisible 隐身（建连时加载，切换设置时由 SetAppearOffline 更新），不计入房间在线成员
+	invisible atomic.Bool
 }
 
-// 合并阅读
-func (s *UserSession) mergeRead(roomID, lastRead uint64) {
+// 合并阅读，返回游标是否前进（多设备重复上报同一游标时返回 false）
+func (s *UserSession) mergeRead(roomID, lastRead uint64) bool {
 	if roomID == 0 || lastRead == 0 {
-		return
+		return false
 	}
 	s.ReadMu.Lock()
+	defer s.ReadMu.Unlock()
 	if s.ReadList == nil {
 		s.ReadList = make(map[uint64]uint64)
 	}
-	if old := s.ReadList[roomID]; lastRead > old {
-		s.ReadList[roomID] = lastRead
-		s.dirty = true
-		s.lastReadChangeAt = time.Now()
-	}
 	s.lastSeen = time.Now()
-	s.ReadMu.Unlock()
+	if old := s.ReadList[roomID]; lastRead <= old {
+		return false
+	}
+	s.ReadList[roomID] = lastRead
+	s.dirty = true
+	s.readVersion++
+	s.lastReadChangeAt = s.lastSeen
+	return true
+}
+
+// loadRead 合并从 DB 恢复的已读游标（已是落库数据，不标记 dirty）
+func (s *UserSession) loadRead(m map[uint64]uint64) {
+	s.ReadMu.Lock()
+	defer s.ReadMu.Unlock()
+	if s.ReadList == nil {
+		s.ReadList = make(map[uint64]uint64, len(m))
+	}
+	for roomID, lastRead := range m {
+		if roomID == 0 {
+			continue
+		}
+		if old, ok := s.ReadList[roomID]; !ok || lastRead > old {
+			s.ReadList[roomID] = lastRead
+		}
+	}
 }
 
 func (s *UserSession) snapshotRead() map[uint64]uint64 {
@@ -111,10 +198,12 @@ func (s *UserSession) snapshotRead() map[uint64]uint64 {
 	return snap
 }
 
-// markFlushed 在落库成功后调用
-func (s *UserSession) markFlushed() {
+// markFlushed 在落库成功后调用；version 为快照时的 readVersion，期间有新变化则保留 dirty
+func (s *UserSession) markFlushed(version uint64) {
 	s.ReadMu.Lock()
-	s.dirty = false
+	if s.readVersion == version {
+		s.dirty = false
+	}
 	s.lastFlush = time.Now()
 	// flush 成功后，认为当前 ReadList 状态稳定，更新变化时间
 	if !s.lastFlush.IsZero() {
@@ -123,29 +212,72 @@ func (s *UserSession) markFlushed() {
 	s.ReadMu.Unlock()
 }
 
-// snapshotReadAndDirty 返回快照及是否 dirty（用于周期 flush）
-func (s *UserSession) snapshotReadAndDirty() (map[uint64]uint64, bool) {
+// snapshotReadAndDirty 返回快照、快照版本及是否 dirty（用于周期 flush）
+func (s *UserSession) snapshotReadAndDirty() (map[uint64]uint64, uint64, bool) {
 	s.ReadMu.Lock()
 	defer s.ReadMu.Unlock()
 	if !s.dirty || len(s.ReadList) == 0 {
-		return nil, false
+		return nil, 0, false
 	}
 	snap := make(map[uint64]uint64, len(s.ReadList))
 	for k, v := range s.ReadList {
 		snap[k] = v
 	}
-	return snap, true
+	return snap, s.readVersion, true
+}
+
+// flushSessionRead 将 session 中未落库的已读游标写入 conversation.last_read_msg_id
+func (h *WsServer) flushSessionRead(sess *UserSession) error {
+	if sess == nil {
+		return nil
+	}
+	snap, version, dirty := sess.snapshotReadAndDirty()
+	if !dirty || snap == nil {
+		return nil
+	}
+	if Instance == nil || Instance.MsgService == nil || Instance.MsgService.ReadReceipt == nil {
+		return nil
+	}
+	if err := Instance.MsgService.ReadReceipt.FlushUserRead(sess.UserID, snap); err != nil {
+		log.Printf("flush read state failed: user=%d err=%v", sess.UserID, err)
+		return err
+	}
+	sess.markFlushed(version)
+	return nil
+}
+
+// FlushReadState 立即落库所有在线 session 的已读游标。
+// 建议在进程优雅退出前调用，避免丢失最近一个 flush 周期内的已读状态。
+func (h *WsServer) FlushReadState() error {
+	h.mu.RLock()
+	sessions := make([]*UserSession, 0, len(h.Sessions))
+	for _, s := range h.Sessions {
+		sessions = append(sessions, s)
+	}
+	h.mu.RUnlock()
+
+	var firstErr error
+	for _, sess := range sessions {
+		if err := h.flushSessionRead(sess); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 // readPump 将消息从client (websocket 连接) 到hub管理。
 func (c *Client) readPump() {
 	defer func() {
+		if c.unbindToken != nil {
+			c.unbindToken()
+		}
 		c.hub.unregister <- c
 		_ = c.conn.Close()
 	}()
-	c.conn.SetReadLimit(maxMessageSize)
-	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error { _ = c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	limits := c.hub.limits
+	c.conn.SetReadLimit(limits.MaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(limits.PongWait))
+	c.conn.SetPongHandler(func(string) error { _ = c.conn.SetReadDeadline(time.Now().Add(limits.PongWait)); return nil })
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
@@ -154,44 +286,56 @@ func (c *Client) readPump() {
 			}
 			break
 		}
+		c.touch(time.Now())
 		c.hub.handleMessage(c, message)
 	}
 }
 
 // writePump 将消息从hub管理写到具体的client (websocket 连接)。
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	limits := c.hub.limits
+	ticker := time.NewTicker(limits.pingPeriod())
 	defer func() {
 		ticker.Stop()
 		_ = c.conn.Close()
 	}()
+	// 两个通道由 closeSend 同时关闭；高优先级通道关闭后置 nil，等普通通道发完剩余消息再退出
+	high := c.sendHigh
 	for {
+		// 高优先级通道有消息时先发，不和积压的聊天消息一起排队
 		select {
-		case message, ok := <-c.send:
-			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		case message, ok := <-high:
 			if !ok {
-				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				high = nil
+				continue
+			}
+			if !c.writeBatch(high, message) {
 				return
 			}
+			continue
+		default:
+		}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+		select {
+		case message, ok := <-high:
+			if !ok {
+				high = nil
+				continue
+			}
+			if !c.writeBatch(high, message) {
 				return
 			}
-			_, _ = w.Write(message)
-
-			// 一次性发送管道剩余全部的消息，不重新走message, ok := <-c.send，提升性能
-			// 额外的消息批量写入数据库保持结果一致
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				_, _ = w.Write(<-c.send)
+		case message, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(limits.WriteWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
 			}
-
-			if err := w.Close(); err != nil {
+			if !c.writeBatch(high, message) {
 				return
 			}
 		case <-ticker.C:
-			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = c.conn.SetWriteDeadline(time.Now().Add(limits.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				log.Printf("writePump 写入ping失败")
 				return
@@ -200,6 +344,26 @@ func (c *Client) writePump() {
 	}
 }
 
+// writeBatch 把 first 连同两个通道当前积压的消息写成一帧：先高优先级、再普通消息。
+// 一次性发送管道剩余全部的消息，不重新走 select，提升性能。
+func (c *Client) writeBatch(high chan []byte, first []byte) bool {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.hub.limits.WriteWait))
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return false
+	}
+	_, _ = w.Write(first)
+
+	// 按 len 取，通道已关闭时也只读缓冲中剩余的消息
+	for n := len(high); n > 0; n-- {
+		_, _ = w.Write(<-high)
+	}
+	for n := len(c.send); n > 0; n-- {
+		_, _ = w.Write(<-c.send)
+	}
+	return w.Close() == nil
+}
+
 type WsServer struct {
 	clients map[*Client]bool
 	// 用户ID ->该用户所有活跃的Websocket连接（支持多设备）
@@ -211,32 +375,99 @@ type WsServer struct {
 	// 用户ID -> “延迟移除/flush” 的定时器
 	gcTimers map[uint64]*time.Timer
 
+	// 用户ID -> 长轮询队列（仅轮询过的用户才有）
+	pollQueues map[uint64]*pollQueue
+
+	// 房间ID -> 订阅该房间的连接（房间级扇出，见 PublishToRoom）
+	rooms map[uint64]map[*Client]struct{}
+
+	// roomLoader 建连时加载用户所在房间（NewEngine 中注入），为 nil 时连接不订阅任何房间
+	roomLoader func(userID uint64) ([]uint64, error)
+	// invisibleLoader 建连时加载用户是否隐身（NewEngine 中注入）
+	invisibleLoader func(userID uint64) (bool, error)
+
+	// roomOnlineEvery 房间在线人数推送的合并间隔（见 WithRoomOnlineFeed），<=0 不推送
+	roomOnlineEvery time.Duration
+	// onlineDirty 在线人数可能变化、待重新计数的房间；onlineLast 各房间上次推送的人数（受 mu 保护）
+	onlineDirty map[uint64]struct{}
+	onlineLast  map[uint64]int
+
 	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
 	// 回调处理消息
 	onMessage func(client *Client, msg []byte)
+
+	// limits 连接参数（NewEngine 中按 WithWsLimits 设置）
+	limits WsLimits
+
+	// tap 同步观察所有 SendToUser 推送（测试引擎用于断言）
+	tap func(userID uint64, msg []byte)
+
+	// idle 空闲策略（NewEngine 中按 WithWsIdlePolicy 设置）
+	idle WsIdlePolicy
+	// onPresence 在线状态变化回调（NewEngine 中注入），由单独协程串行调用
+	onPresence func(PresenceEvent)
+	presence   chan PresenceEvent
+	// connSeq 连接编号
+	connSeq atomic.Uint64
+
+	quit     chan struct{}
+	quitOnce sync.Once
 }
 
 func NewWsServer() *WsServer {
 	return &WsServer{
-		broadcast:   make(chan []byte),
+		broadcast:   make(chan []byte, wsBroadcastQueue),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		clients:     make(map[*Client]bool),
 		userClients: make(map[uint64][]*Client),
 		Sessions:    make(map[uint64]*UserSession),
 		gcTimers:    make(map[uint64]*time.Timer),
+		pollQueues:  make(map[uint64]*pollQueue),
+		rooms:       make(map[uint64]map[*Client]struct{}),
+		onlineDirty: make(map[uint64]struct{}),
+		onlineLast:  make(map[uint64]int),
+		presence:    make(chan PresenceEvent, presenceQueueSize),
+		limits:      DefaultWsLimits,
+		quit:        make(chan struct{}),
 	}
 }
 
+// Stop 停止 Run 主循环（不关闭已有连接）
+func (h *WsServer) Stop() {
+	h.quitOnce.Do(func() { close(h.quit) })
+}
+
 func (h *WsServer) Run() {
 	flushTicker := time.NewTicker(60 * time.Second)
 	defer flushTicker.Stop()
+	idleTicker := time.NewTicker(wsIdleCheckInterval)
+	defer idleTicker.Stop()
+	if h.onPresence != nil {
+		go h.dispatchPresence()
+	}
+	// 房间在线人数推送（未开启时 onlineC 为 nil，永不触发）
+	var onlineC <-chan time.Time
+	if h.roomOnlineEvery > 0 {
+		onlineTicker := time.NewTicker(h.roomOnlineEvery)
+		defer onlineTicker.Stop()
+		onlineC = onlineTicker.C
+	}
 
 	for {
 		select {
+		case <-h.quit:
+			return
+
+		case now := <-idleTicker.C:
+			h.checkIdle(now)
+
+		case <-onlineC:
+			h.flushRoomOnline()
+
 		case <-flushTicker.C:
 			// 在线周期 flush：只 flush dirty 的 session
 			// 这里不在 h.mu.Lock 下做 DB IO，避免阻塞 ws 主循环。
@@ -252,19 +483,15 @@ func (h *WsServer) Run() {
 				if sess == nil {
 					continue
 				}
-				snap, dirty := sess.snapshotReadAndDirty()
-				if dirty && snap != nil {
-					if Instance != nil && Instance.MsgService != nil && Instance.MsgService.ReadReceipt != nil {
-						if err := Instance.MsgService.ReadReceipt.FlushUserRead(sess.UserID, snap); err == nil {
-							sess.markFlushed()
-						}
-					}
-				}
+				_ = h.flushSessionRead(sess)
 
 				// 回收：已落库且 10 分钟无变化的 readList
 				sess.pruneReadListIfIdle(10 * time.Minute)
 			}
 
+			// 回收长时间未轮询的长轮询队列
+			h.prunePollQueues(pollQueueIdle)
+
 		case client := <-h.register:
 			h.mu.Lock()
 			// 1) 复用/创建用户级 session
@@ -289,13 +516,21 @@ func (h *WsServer) Run() {
 
 			h.clients[client] = true
 			h.userClients[client.UserID] = append(h.userClients[client.UserID], client)
+			for roomID := range client.rooms {
+				h.addRoomSubLocked(roomID, client)
+			}
+			h.setPresence(sess, models.OnlineStatusOnline)
 			h.mu.Unlock()
+			if client.registered != nil {
+				close(client.registered)
+			}
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				close(client.send)
+				h.dropRoomSubsLocked(client)
+				client.closeSend()
 
 				if userConns, exists := h.userClients[client.UserID]; exists {
 					for i, conn := range userConns {
@@ -305,17 +540,22 @@ func (h *WsServer) Run() {
 						}
 					}
 					if len(h.userClients[client.UserID]) == 0 {
+						// 最后一个连接断开：立即落库已读游标（不在锁内做 DB IO）；
 						// 不立刻 delete：交给 timer 决定是否清理，给断开-重连留窗口
+						if sess := h.Sessions[client.UserID]; sess != nil {
+							h.setPresence(sess, models.OnlineStatusOffline)
+							go func() { _ = h.flushSessionRead(sess) }()
+						}
 					}
 				}
 			}
 
-			// 3) 启动/重置 5 分钟 GC：仅当用户确实无任何连接时才 flush + 清理
+			// 3) 启动/重置 GC（wsResumeWindow）：仅当用户确实无任何连接时才 flush + 清理，窗口内重连可续传
 			uid := client.UserID
 			if t, ok := h.gcTimers[uid]; ok {
 				t.Stop()
 			}
-			h.gcTimers[uid] = time.AfterFunc(5*time.Minute, func() {
+			h.gcTimers[uid] = time.AfterFunc(wsResumeWindow, func() {
 				// timer 回调里不要直接用 client 指针（可能已复用/已变化），用 uid 查当前状态
 				h.mu.RLock()
 				conns := h.userClients[uid]
@@ -327,14 +567,10 @@ func (h *WsServer) Run() {
 					return
 				}
 
-				// flush session readList
-				if sess != nil {
-					snap := sess.snapshotRead()
-					if snap != nil {
-						if Instance != nil && Instance.MsgService != nil && Instance.MsgService.ReadReceipt != nil {
-							_ = Instance.MsgService.ReadReceipt.FlushUserRead(uid, snap)
-						}
-					}
+				// 断线时已 flush，这里兜底 flush 断线 flush 失败/之后的变更
+				if err := h.flushSessionRead(sess); err != nil {
+					// 落库失败保留 session，等下一个周期 flush 重试
+					return
 				}
 
 				// 清理 maps
@@ -348,50 +584,8 @@ func (h *WsServer) Run() {
 			h.mu.Unlock()
 
 		case message := <-h.broadcast:
-			// 注意：不能在 RLock 下修改 map / close channel，否则会引发竞态/崩溃。
-			var toRemove []*Client
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					toRemove = append(toRemove, client)
-				}
-			}
-			h.mu.RUnlock()
-
-			if len(toRemove) > 0 {
-				h.mu.Lock()
-				for _, client := range toRemove {
-					if _, ok := h.clients[client]; !ok {
-						continue
-					}
-					delete(h.clients, client)
-					// 从 userClients 中移除
-					if userConns, exists := h.userClients[client.UserID]; exists {
-						for i, conn := range userConns {
-							if conn == client {
-								h.userClients[client.UserID] = append(userConns[:i], userConns[i+1:]...)
-								break
-							}
-						}
-						if len(h.userClients[client.UserID]) == 0 {
-							delete(h.userClients, client.UserID)
-						}
-					}
-					// close 之前再确认一次，避免 panic（多处 close 的竞态）
-					select {
-					case <-client.send:
-						// channel 可能已被关闭并读到零值；下面安全 close 仍可能 panic，故用 recover
-					default:
-					}
-					func() {
-						defer func() { _ = recover() }()
-						close(client.send)
-					}()
-				}
-				h.mu.Unlock()
-			}
+			// 只写各连接的发送队列，缓冲满的连接按 DropPolicy 处理，不在这里增删 map
+			h.fanout(message)
 		}
 	}
 }
@@ -405,8 +599,40 @@ func (h *WsServer) SetOnMessage(fn func(client *Client, msg []byte)) {
 	h.onMessage = fn
 }
 
-// ServeWS 处理ws的请求
+// ServeWS 处理ws的请求。
+// 请求携带 AuthService 签发的 token（Bearer 或 ?token=）且属于 userID 时，连接自动绑定到该 token（见 ServeWSWithToken）。
 func (h *WsServer) ServeWS(w http.ResponseWriter, r *http.Request, userID uint64, name string, extras ...string) {
+	h.serveWS(w, r, userID, "", name, extras...)
+}
+
+// ServeWSWithToken 同 ServeWS，连接绑定到 token：token 注销（登出、封禁）时立即断开（close code WsCloseRevoked）。
+// token 无效或不属于 userID 时返回 401，不建连。
+func (h *WsServer) ServeWSWithToken(w http.ResponseWriter, r *http.Request, token string, userID uint64, name string, extras ...string) {
+	if token == "" {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+	h.serveWS(w, r, userID, token, name, extras...)
+}
+
+func (h *WsServer) serveWS(w http.ResponseWriter, r *http.Request, userID uint64, token string, name string, extras ...string) {
+	// IP 黑白名单/国家屏蔽（未挂 Gin 中间件时也能生效）
+	if Instance != nil && Instance.SecurityService != nil && !Instance.SecurityService.AllowRequest(r) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+	// 被暂停/封禁的账号不允许建连
+	if Instance != nil && Instance.AccountService != nil {
+		if err := Instance.AccountService.CheckActive(userID); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	token, ok := wsBindToken(r, token, userID)
+	if !ok {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
@@ -443,58 +669,286 @@ func (h *WsServer) ServeWS(w http.ResponseWriter, r *http.Request, userID uint64
 	}
 	h.mu.Unlock()
 
-	// 建连时从 DB 加载可见会话的 last_read_msg_id 到 session.readList
-	// 只在 session 新建或当前 readList 为空时加载，避免每次重连都打 DB。
+	// 建连时从 DB 恢复可见会话的 last_read_msg_id 到 session.readList
+	// 只在 session 新建或当前 readList 为空（已被回收）时加载，避免每次重连都打 DB；
+	// 断线窗口内重连会复用内存中的 readList（含尚未落库的变更）。
 	if Instance != nil && Instance.MsgService != nil && Instance.MsgService.SessionBootstrap != nil {
 		sess.ReadMu.Lock()
 		empty := len(sess.ReadList) == 0
 		sess.ReadMu.Unlock()
 		if created || empty {
 			if m, err := Instance.MsgService.SessionBootstrap.GetVisibleConversationLastReads(userID); err == nil {
-				for roomID, lastRead := range m {
-					sess.mergeRead(roomID, lastRead)
-				}
-				// 初始化加载不算未落库变更
-				sess.ReadMu.Lock()
-				sess.dirty = false
-				sess.ReadMu.Unlock()
+				sess.loadRead(m)
+			} else {
+				log.Printf("load read state failed: user=%d err=%v", userID, err)
 			}
 		}
 	}
 
 	client := &Client{
-		hub:      h,
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		UserID:   userID,
-		Name:     name,
-		Nickname: nickname,
-		Avatar:   avatar,
-		session:  sess,
-	}
-	client.hub.register <- client
+		hub:         h,
+		conn:        conn,
+		send:        make(chan []byte, h.limits.SendBuffer),
+		sendHigh:    make(chan []byte, wsHighLaneBuffer),
+		UserID:      userID,
+		SessionID:   strconv.FormatUint(h.connSeq.Add(1), 10),
+		Name:        name,
+		Nickname:    nickname,
+		Avatar:      avatar,
+		session:     sess,
+		rooms:       make(map[uint64]struct{}),
+		connectedAt: time.Now(),
+		remoteIP:    remoteIP(r),
+	}
+	client.lastActive.Store(client.connectedAt.UnixNano())
+	// 订阅所在房间（注册进 hub 时生效）；加载失败不影响建连，只是收不到房间级扇出
+	if h.roomLoader != nil {
+		if roomIDs, err := h.roomLoader(userID); err == nil {
+			for _, roomID := range roomIDs {
+				client.rooms[roomID] = struct{}{}
+			}
+		} else {
+			log.Printf("load rooms failed: user=%d err=%v", userID, err)
+		}
+	}
+	if h.invisibleLoader != nil {
+		if invisible, err := h.invisibleLoader(userID); err == nil {
+			sess.invisible.Store(invisible)
+		} else {
+			log.Printf("load invisible failed: user=%d err=%v", userID, err)
+		}
+	}
+	// 下发 session 握手；携带 resume_token/last_event_id 重连时补发断线期间的推送
+	q := r.URL.Query()
+	lastEventID, _ := strconv.ParseUint(q.Get("last_event_id"), 10, 64)
+	h.attach(client, q.Get("resume_token"), lastEventID)
 	log.Println("注册进去: ", client.UserID)
 
+	// 活跃用户统计（DAU/MAU）
+	if Instance != nil && Instance.ServerStatsService != nil {
+		go Instance.ServerStatsService.RecordActive(context.Background(), userID, time.Now())
+	}
+
+	// 绑定在 attach 之后、pump 启动之前：期间 token 被注销时 closeBound 关闭底层连接，readPump 启动即退出并正常注销
+	if token != "" {
+		if unbind, err := Instance.AuthService.BindConnection(r.Context(), token, client.closeBound); err != nil {
+			client.closeBound(service.ConnCloseRevoked)
+		} else {
+			client.unbindToken = unbind
+		}
+	}
+
 	go client.writePump()
 	go client.readPump()
 
 	// 不要 select{} 永久阻塞 handler；连接生命周期由 readPump/writePump 控制。
 }
 
+// remoteIP 客户端 IP（开启 WithTrustProxyHeaders 时取代理头）
+func remoteIP(r *http.Request) string {
+	if Instance != nil && Instance.SecurityService != nil {
+		if ip := Instance.SecurityService.ClientIP(r); ip != nil {
+			return ip.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ConnStats 当前 WS 连接数与在线用户数
+func (h *WsServer) ConnStats() (conns, users int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients), len(h.userClients)
+}
+
+// OnlineUser 在线用户的昵称/头像（实现 service.SessionStore，断线后 session 保留期间仍视为在线）
+func (h *WsServer) OnlineUser(userID uint64) (nickname, avatar string, ok bool) {
+	h.mu.RLock()
+	sess := h.Sessions[userID]
+	h.mu.RUnlock()
+	if sess == nil {
+		return "", "", false
+	}
+	return sess.Nickname, sess.Avatar, true
+}
+
+// ReadSnapshot 用户 session 中已读游标的快照（实现 service.SessionStore）
+func (h *WsServer) ReadSnapshot(userID uint64) map[uint64]uint64 {
+	h.mu.RLock()
+	sess := h.Sessions[userID]
+	h.mu.RUnlock()
+	if sess == nil {
+		return nil
+	}
+	return sess.snapshotRead()
+}
+
 // SendToUser 发送消息到用户
 func (h *WsServer) SendToUser(userID uint64, msg []byte) {
 	h.mu.RLock()
-	clients := h.userClients[userID]
+	sess := h.Sessions[userID]
+	conns := len(h.userClients[userID])
 	keys := len(h.userClients)
 	h.mu.RUnlock()
 
-	log.Printf("SendToUser user=%d userKeys=%d conns=%d", userID, keys, len(clients))
+	log.Printf("SendToUser user=%d userKeys=%d conns=%d", userID, keys, conns)
+	if h.tap != nil {
+		h.tap(userID, msg)
+	}
+	// 没有 session 说明用户不在线也不在续传窗口内，无需编号
+	if sess != nil {
+		h.deliver(sess, msg)
+	}
+	// 长轮询用户同样投递一份
+	h.enqueuePoll(userID, msg)
+}
+
+// SendToUsers 批量推送：一次加锁取出所有目标连接，重复的 userID 只推一次（同样投递长轮询队列）
+func (h *WsServer) SendToUsers(userIDs []uint64, msg []byte) {
+	if len(userIDs) == 0 {
+		return
+	}
+	seen := make(map[uint64]struct{}, len(userIDs))
+	uniq := make([]uint64, 0, len(userIDs))
+	var sessions []*UserSession
+	var queues []*pollQueue
+	h.mu.RLock()
+	for _, uid := range userIDs {
+		if _, ok := seen[uid]; ok {
+			continue
+		}
+		seen[uid] = struct{}{}
+		uniq = append(uniq, uid)
+		if sess := h.Sessions[uid]; sess != nil {
+			sessions = append(sessions, sess)
+		}
+		if q := h.pollQueues[uid]; q != nil {
+			queues = append(queues, q)
+		}
+	}
+	h.mu.RUnlock()
+
+	log.Printf("SendToUsers users=%d sessions=%d", len(uniq), len(sessions))
+	if h.tap != nil {
+		for _, uid := range uniq {
+			h.tap(uid, msg)
+		}
+	}
+	for _, sess := range sessions {
+		h.deliver(sess, msg)
+	}
+	for _, q := range queues {
+		q.push(msg)
+	}
+}
+
+// PublishToRoom 按房间订阅扇出给在线连接（exceptUserID 的连接除外），不查成员表、不投递长轮询队列。
+// 适合正在输入这类只对在线成员有意义的瞬时事件；需要可靠送达的推送用 SendToUsers。
+func (h *WsServer) PublishToRoom(roomID uint64, msg []byte, exceptUserID uint64) {
+	h.mu.RLock()
+	subs := h.rooms[roomID]
+	clients := make([]*Client, 0, len(subs))
+	for client := range subs {
+		if client.UserID != exceptUserID {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	if h.tap != nil {
+		seen := make(map[uint64]struct{}, len(clients))
+		for _, client := range clients {
+			if _, ok := seen[client.UserID]; !ok {
+				seen[client.UserID] = struct{}{}
+				h.tap(client.UserID, msg)
+			}
+		}
+	}
 	for _, client := range clients {
-		select {
-		case client.send <- msg:
-		default:
-			// 丢弃避免阻塞
+		client.enqueue(msg)
+	}
+}
+
+// InRoom 用户是否有连接订阅了该房间（即建连后仍是房间成员）
+func (h *WsServer) InRoom(userID, roomID uint64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.userClients[userID] {
+		if _, ok := client.rooms[roomID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribeRoom 让用户当前所有连接订阅房间（入群/建群后调用，未来的连接建连时自行加载）
+func (h *WsServer) SubscribeRoom(roomID uint64, userIDs ...uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, uid := range userIDs {
+		for _, client := range h.userClients[uid] {
+			client.rooms[roomID] = struct{}{}
+			h.addRoomSubLocked(roomID, client)
+		}
+	}
+}
+
+// UnsubscribeRoom 取消用户当前所有连接对房间的订阅（退群/被移出后调用）
+func (h *WsServer) UnsubscribeRoom(roomID uint64, userIDs ...uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, uid := range userIDs {
+		for _, client := range h.userClients[uid] {
+			delete(client.rooms, roomID)
+			h.removeRoomSubLocked(roomID, client)
+		}
+	}
+}
+
+func (h *WsServer) addRoomSubLocked(roomID uint64, client *Client) {
+	subs := h.rooms[roomID]
+	if subs == nil {
+		subs = make(map[*Client]struct{})
+		h.rooms[roomID] = subs
+	}
+	subs[client] = struct{}{}
+	h.markRoomOnlineDirtyLocked(roomID)
+}
+
+func (h *WsServer) removeRoomSubLocked(roomID uint64, client *Client) {
+	subs := h.rooms[roomID]
+	if subs == nil {
+		return
+	}
+	delete(subs, client)
+	if len(subs) == 0 {
+		delete(h.rooms, roomID)
+	}
+	h.markRoomOnlineDirtyLocked(roomID)
+}
+
+// dropRoomSubsLocked 连接断开时移除其全部房间订阅
+func (h *WsServer) dropRoomSubsLocked(client *Client) {
+	for roomID := range client.rooms {
+		h.removeRoomSubLocked(roomID, client)
+	}
+}
+
+// sendToUserExcept 发送消息到用户除 except 以外的连接（多设备同步，不投递长轮询队列）
+func (h *WsServer) sendToUserExcept(userID uint64, except *Client, msg []byte) {
+	h.mu.RLock()
+	clients := h.userClients[userID]
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		if client == except {
+			continue
 		}
+		client.enqueue(msg)
 	}
 }
 
@@ -0,0 +1,165 @@
+package chat_sdk
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WsCloseIdle 连接因空闲被服务端断开时的 close code，客户端收到后不应自动重连
+	WsCloseIdle = 4000
+
+	// wsIdleCheckInterval 空闲检查间隔
+	wsIdleCheckInterval = 15 * time.Second
+
+	// presenceQueueSize 待分发的在线状态变化事件数，超出丢弃
+	presenceQueueSize = 1024
+)
+
+// WsIdlePolicy 连接空闲策略（见 WithWsIdlePolicy），字段 <=0 表示关闭对应功能。
+// “活跃”指客户端主动发来的任意消息（含 {"type":"heartbeat"}），pong 不算。
+type WsIdlePolicy struct {
+	// AwayAfter 用户所有连接都超过该时长不活跃时标记为离开，再次活跃恢复为在线
+	AwayAfter time.Duration
+	// KickAfter 单个连接超过该时长不活跃时断开（close code WsCloseIdle）
+	KickAfter time.Duration
+}
+
+// PresenceEvent 用户在线状态变化：online→away→offline（状态取值同 User.OnlineStatus）
+type PresenceEvent struct {
+	UserID uint64
+	From   uint8
+	To     uint8
+	At     time.Time
+}
+
+// WsConnInfo 单个 WS 连接的活跃信息（管理接口）
+type WsConnInfo struct {
+	ConnID       string    `json:"conn_id"`
+	UserID       uint64    `json:"user_id"`
+	RemoteIP     string    `json:"remote_ip"`
+	ConnectedAt  time.Time `json:"connected_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	IdleSec      int64     `json:"idle_sec"`
+	Presence     uint8     `json:"presence"` // 用户当前在线状态：1-在线 2-离开
+}
+
+// touch 记录一次客户端上行消息；用户处于离开状态时恢复为在线
+func (c *Client) touch(now time.Time) {
+	c.lastActive.Store(now.UnixNano())
+	if sess := c.session; sess != nil && sess.presence.CompareAndSwap(models.OnlineStatusAway, models.OnlineStatusOnline) {
+		c.hub.emitPresence(sess.UserID, models.OnlineStatusAway, models.OnlineStatusOnline)
+	}
+}
+
+// setPresence 切换用户在线状态，状态有变化时分发事件
+func (h *WsServer) setPresence(sess *UserSession, to uint8) {
+	if from := uint8(sess.presence.Swap(uint32(to))); from != to {
+		h.emitPresence(sess.UserID, from, to)
+	}
+}
+
+// emitPresence 非阻塞写入分发队列（在 Run 主循环中也会调用，不能做 IO）
+func (h *WsServer) emitPresence(userID uint64, from, to uint8) {
+	if h.onPresence == nil {
+		return
+	}
+	select {
+	case h.presence <- PresenceEvent{UserID: userID, From: from, To: to, At: time.Now()}:
+	default:
+		log.Printf("presence queue full, drop user=%d %d->%d", userID, from, to)
+	}
+}
+
+// dispatchPresence 串行回调 onPresence，保证同一用户的状态变化按顺序处理
+func (h *WsServer) dispatchPresence() {
+	for {
+		select {
+		case <-h.quit:
+			return
+		case ev := <-h.presence:
+			h.onPresence(ev)
+		}
+	}
+}
+
+// checkIdle 按空闲策略标记离开、断开长时间不活跃的连接
+func (h *WsServer) checkIdle(now time.Time) {
+	p := h.idle
+	if p.AwayAfter <= 0 && p.KickAfter <= 0 {
+		return
+	}
+	var kick []*Client
+	var away []*UserSession
+	h.mu.RLock()
+	for uid, conns := range h.userClients {
+		var latest int64
+		for _, c := range conns {
+			last := c.lastActive.Load()
+			if last > latest {
+				latest = last
+			}
+			if p.KickAfter > 0 && now.Sub(time.Unix(0, last)) >= p.KickAfter {
+				kick = append(kick, c)
+			}
+		}
+		if len(conns) > 0 && p.AwayAfter > 0 && now.Sub(time.Unix(0, latest)) >= p.AwayAfter {
+			if sess := h.Sessions[uid]; sess != nil {
+				away = append(away, sess)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sess := range away {
+		if sess.presence.CompareAndSwap(models.OnlineStatusOnline, models.OnlineStatusAway) {
+			h.emitPresence(sess.UserID, models.OnlineStatusOnline, models.OnlineStatusAway)
+		}
+	}
+	// 关闭底层连接后 readPump 退出并走正常的注销流程；写超时按真实时间算，now 只用于判断空闲
+	for _, c := range kick {
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(WsCloseIdle, "idle timeout"), time.Now().Add(h.limits.WriteWait))
+		_ = c.conn.Close()
+	}
+}
+
+// Connections 当前实例的 WS 连接活跃信息，userID 不为 0 时只返回该用户的连接；按空闲时长倒序
+func (h *WsServer) Connections(userID uint64) []WsConnInfo {
+	now := time.Now()
+	h.mu.RLock()
+	out := make([]WsConnInfo, 0)
+	for uid, conns := range h.userClients {
+		if userID != 0 && uid != userID {
+			continue
+		}
+		for _, c := range conns {
+			last := time.Unix(0, c.lastActive.Load())
+			info := WsConnInfo{
+				ConnID:       c.SessionID,
+				UserID:       uid,
+				RemoteIP:     c.remoteIP,
+				ConnectedAt:  c.connectedAt,
+				LastActiveAt: last,
+				IdleSec:      int64(now.Sub(last) / time.Second),
+			}
+			if c.session != nil {
+				info.Presence = uint8(c.session.presence.Load())
+			}
+			out = append(out, info)
+		}
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].IdleSec != out[j].IdleSec {
+			return out[i].IdleSec > out[j].IdleSec
+		}
+		return out[i].ConnID < out[j].ConnID
+	})
+	return out
+}
@@ -0,0 +1,112 @@
+package chat_sdk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/gorilla/websocket"
+)
+
+// nextPresence 等待下一条在线状态事件
+func nextPresence(t *testing.T, ch <-chan PresenceEvent) PresenceEvent {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no presence event")
+		return PresenceEvent{}
+	}
+}
+
+func expectPresence(t *testing.T, ch <-chan PresenceEvent, userID uint64, from, to uint8) {
+	t.Helper()
+	if ev := nextPresence(t, ch); ev.UserID != userID || ev.From != from || ev.To != to {
+		t.Fatalf("presence = %+v, want user %d %d->%d", ev, userID, from, to)
+	}
+}
+
+func noPresence(t *testing.T, ch <-chan PresenceEvent) {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected presence %+v", ev)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// 用假时钟驱动 checkIdle/touch：全部连接不活跃时标记离开，活跃后恢复在线，单个连接超时被断开
+func TestCheckIdle_AwayAndKick(t *testing.T) {
+	h := NewWsServer()
+	h.idle = WsIdlePolicy{AwayAfter: time.Minute, KickAfter: 5 * time.Minute}
+	events := make(chan PresenceEvent, 16)
+	h.onPresence = func(ev PresenceEvent) { events <- ev }
+	startTestHub(t, h)
+
+	// 假时钟起点，连接的活跃时间都从这里算
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return clock.Add(d) }
+
+	phone := joinRoomClient(t, h, 1)
+	server, peer := wsPipe(t)
+	desktop := newHubClient(h, 1, 16)
+	desktop.conn = server
+	h.attach(desktop, "", 0)
+	waitConns(t, h, 1, 2)
+	phone.lastActive.Store(clock.UnixNano())
+	desktop.lastActive.Store(clock.UnixNano())
+	expectPresence(t, events, 1, models.OnlineStatusOffline, models.OnlineStatusOnline)
+
+	h.checkIdle(at(59 * time.Second))
+	noPresence(t, events)
+
+	h.checkIdle(at(time.Minute))
+	expectPresence(t, events, 1, models.OnlineStatusOnline, models.OnlineStatusAway)
+	// 已是离开状态，重复检查不再发事件
+	h.checkIdle(at(2 * time.Minute))
+	noPresence(t, events)
+
+	// 任一连接活跃即恢复在线
+	phone.touch(at(3 * time.Minute))
+	expectPresence(t, events, 1, models.OnlineStatusAway, models.OnlineStatusOnline)
+	if got := phone.session.presence.Load(); got != models.OnlineStatusOnline {
+		t.Fatalf("presence = %d after touch", got)
+	}
+
+	// desktop 从起点起一直不活跃：5 分钟时被断开；phone 期间活跃过，保留且用户保持在线
+	phone.touch(at(4*time.Minute + 30*time.Second))
+	h.checkIdle(at(5*time.Minute - time.Second))
+	noPresence(t, events)
+	h.checkIdle(at(5 * time.Minute))
+	_ = peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := peer.ReadMessage()
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != WsCloseIdle {
+		t.Fatalf("close = %v, want code %d", err, WsCloseIdle)
+	}
+	noPresence(t, events)
+
+	// 被断开的连接注销后用户仍在线，最后一个连接注销才离线
+	disconnectTestClient(t, h, desktop)
+	noPresence(t, events)
+	disconnectTestClient(t, h, phone)
+	expectPresence(t, events, 1, models.OnlineStatusOnline, models.OnlineStatusOffline)
+}
+
+// 策略关闭时不做任何处理
+func TestCheckIdle_Disabled(t *testing.T) {
+	h := NewWsServer()
+	events := make(chan PresenceEvent, 16)
+	h.onPresence = func(ev PresenceEvent) { events <- ev }
+	startTestHub(t, h)
+
+	c := joinRoomClient(t, h, 1)
+	expectPresence(t, events, 1, models.OnlineStatusOffline, models.OnlineStatusOnline)
+	h.checkIdle(time.Now().Add(24 * time.Hour))
+	noPresence(t, events)
+	if got := c.session.presence.Load(); got != models.OnlineStatusOnline {
+		t.Fatalf("presence = %d", got)
+	}
+}
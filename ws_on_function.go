@@ -1,12 +1,16 @@
 package chat_sdk
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/cydxin/chat-sdk/message"
 	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/service"
 )
 
 // bindWsHandlers 将 WS 回调从 engine.go 抽出来，避免 engine.go 臃肿。
@@ -14,129 +18,234 @@ import (
 // 这样可以直接访问 Instance 与 Client 类型，避免 service 层循环依赖。
 func (c *ChatEngine) bindWsHandlersOnMessage() {
 	c.WsServer.onMessage = func(client *Client, msg []byte) {
-		// 1) 先尝试解析 type
+		received := time.Now()
+		if client == nil {
+			return
+		}
+		// 1) 先尝试解析 type；格式错误统一回 error 事件（code=message.WsErrInvalidPayload），带上能解析出的 packet_id
 		var typeProbe struct {
-			Type string `json:"type"`
+			Type     string `json:"type"`
+			PacketID string `json:"packet_id"`
+		}
+		if err := json.Unmarshal(msg, &typeProbe); err != nil {
+			sendWsInvalid(client.UserID, "", "消息格式错误")
+			return
 		}
-		_ = json.Unmarshal(msg, &typeProbe)
 		// 已读回执
 		if typeProbe.Type == message.WsTypeReadAck {
 			var ack message.ReadAckReq
-			if err := json.Unmarshal(msg, &ack); err != nil {
+			if err := json.Unmarshal(msg, &ack); err != nil || ack.RoomID == 0 || ack.LastReadMsgID == 0 {
+				sendWsInvalid(client.UserID, typeProbe.PacketID, "read_ack 需要 room_id 与 last_read_msg_id")
 				return
 			}
-			if client == nil || ack.RoomID == 0 || ack.LastReadMsgID == 0 {
+			// 写入 session.readList（用户级共享内存，由周期/断线 flush 统一落库一次）
+			// 游标未前进（如其他设备已上报过）则直接忽略，避免重复写回执和回声广播
+			if client.session != nil && !client.session.mergeRead(ack.RoomID, ack.LastReadMsgID) {
 				return
 			}
-			// 写入 session.readList（用户级共享内存）
-			if client.session != nil {
-				client.session.mergeRead(ack.RoomID, ack.LastReadMsgID)
+			// 同步给同一用户的其他设备，清除未读角标
+			syncConversationRead(client, ack.RoomID, ack.LastReadMsgID)
+			// 逐条已读回执（群消息"谁已读"）
+			if rr := Instance.MsgService.ReadReceipt; rr != nil {
+				go func() {
+					if err := rr.MarkRead(client.UserID, ack.RoomID, ack.LastReadMsgID); err != nil {
+						log.Printf("mark read failed: %v", err)
+					}
+				}()
 			}
 			return
 		}
-
-		// 发送消息
-		var req message.Req
-		if err := json.Unmarshal(msg, &req); err != nil {
-			log.Printf("Invalid message format: %v", err)
-			return
-		}
-		if client == nil {
-			return
-		}
-
-		room, err := Instance.RoomService.GetRoomByID(req.SendTo)
-		if err != nil {
-			log.Printf("Room not found: %d, error: %v", req.SendTo, err)
-			return
-		}
-		senderID := client.UserID
-		// 1) 私聊拉黑校验（基于 friend.status=2）
-		if room.Type == 1 {
-			blocked, err := isBlockedPrivate(room.ID, senderID)
-			if err != nil {
-				log.Printf("blocked check failed: %v", err)
+		// 送达回执
+		if typeProbe.Type == message.WsTypeDeliveryAck {
+			var ack message.DeliveryAckReq
+			if err := json.Unmarshal(msg, &ack); err != nil || ack.RoomID == 0 || len(ack.MessageIDs) == 0 {
+				sendWsInvalid(client.UserID, typeProbe.PacketID, "delivery_ack 需要 room_id 与 message_ids")
 				return
 			}
-			if blocked {
-				sendWsError(senderID, "你们已互相拉黑/被对方拉黑，无法发送消息", req.PacketID)
-				return
+			if rr := Instance.MsgService.ReadReceipt; rr != nil {
+				go func() {
+					if err := rr.MarkDelivered(client.UserID, ack.RoomID, ack.MessageIDs); err != nil {
+						log.Printf("mark delivered failed: %v", err)
+					}
+				}()
 			}
+			return
 		}
-		// 2) 群聊成员存在性校验（防止退群/被踢还继续发）
-		if room.Type == 2 {
-			ok, err := isRoomMember(room.ID, senderID)
-			if err != nil {
-				log.Printf("member check failed: %v", err)
-				return
-			}
-			if !ok {
-				sendWsError(senderID, "你已不是群成员，无法发送消息", req.PacketID)
+		// 应用层心跳：活跃时间已在 readPump 记录，这里只回一条给当前连接供客户端判断链路存活
+		if typeProbe.Type == message.WsTypeHeartbeat {
+			b, _ := message.EncodeEvent(&message.HeartbeatEvent{ServerTime: time.Now().UnixMilli()})
+			client.enqueueHigh(b)
+			return
+		}
+		// 正在输入
+		if typeProbe.Type == message.WsTypeTyping {
+			var req message.TypingReq
+			if err := json.Unmarshal(msg, &req); err != nil || req.RoomID == 0 {
+				sendWsInvalid(client.UserID, typeProbe.PacketID, "typing 需要 room_id")
 				return
 			}
-		}
-		// 3) 保存消息（内部已处理群禁言/个人禁言）
-		savedMsg, err := Instance.MsgService.SaveMessage(room.ID, senderID, req.SendContent, req.SendType, req.Extra)
-		if err != nil {
-			sendWsError(senderID, err.Error(), req.PacketID)
+			relayTyping(client.UserID, req.RoomID)
 			return
 		}
 
-		extraBytes, _ := json.Marshal(req.Extra)
-		// 写入session
-		if client.session != nil {
-			client.session.mergeRead(room.ID, savedMsg.ID)
-		}
-		members, err := Instance.RoomService.GetRoomMembers(room.ID)
-		if err != nil {
-			log.Printf("Failed to get room members: %v", err)
+		// 发送消息
+		var req message.Req
+		if err := json.Unmarshal(msg, &req); err != nil || req.SendTo == 0 {
+			sendWsInvalid(client.UserID, typeProbe.PacketID, "消息需要 send_to（房间 ID）")
 			return
 		}
-		_ = Instance.ConversationService.SetConversationVisible(room.ID)
-		resp := struct {
-			Type           string          `json:"type"`
-			PacketID       string          `json:"packet_id"`
-			ID             uint64          `json:"id"`
-			RoomID         uint64          `json:"room_id"`
-			RoomType       uint8           `json:"room_type"`
-			SenderID       uint64          `json:"sender_id"`
-			SenderNickname string          `json:"sender_nickname"`
-			SenderAvatar   string          `json:"sender_avatar"`
-			MsgType        uint8           `json:"msg_type"`
-			Content        string          `json:"content"`
-			Extra          json.RawMessage `json:"extra,omitempty"`
-			CreatedAt      time.Time       `json:"created_at"`
-		}{
-			Type:      "message",
-			PacketID:  req.PacketID,
-			ID:        savedMsg.ID,
-			RoomID:    room.ID,
-			RoomType:  room.Type,
-			SenderID:  savedMsg.SenderID,
-			MsgType:   savedMsg.Type,
-			Content:   savedMsg.Content,
-			Extra:     extraBytes,
-			CreatedAt: savedMsg.CreatedAt,
+
+		// 校验、保存与扇出与 HTTP /message/send 共用同一流程；失败回 error 事件（带业务码）
+		if _, err := sendUserMessage(client.UserID, req, client.Nickname, client.Avatar, received); err != nil {
+			log.Printf("send message failed: user=%d room=%d err=%v", client.UserID, req.SendTo, err)
+			sendWsServiceError(client.UserID, err, req.PacketID)
 		}
+	}
+}
 
-		// 建议：无论私聊/群聊都带上 sender 昵称/头像，客户端无需再查。
-		resp.SenderNickname = client.Nickname
-		resp.SenderAvatar = client.Avatar
+// runAutoReply 对用户消息执行自动回复规则，命中则推送回复消息。
+func runAutoReply(room *models.Room, savedMsg *models.Message) {
+	reply, err := Instance.AutoReplyService.HandleInbound(room.ID, savedMsg.SenderID, savedMsg.Type, savedMsg.Content)
+	if err != nil {
+		log.Printf("auto reply failed: %v", err)
+		return
+	}
+	if reply == nil {
+		return
+	}
+	sender := Instance.UserService.UserBrief(reply.SenderID)
+	pushRoomMessage(room, reply, "", sender.Nickname, sender.Avatar)
+}
 
-		respBytes, _ := json.Marshal(resp)
-		for _, memberID := range members {
-			Instance.WsServer.SendToUser(memberID, respBytes)
-		}
+// pushRoomMessage 消息落库后推送给房间成员，并投递给房间内配置了 Webhook 的机器人，返回推送的成员数。
+// extra 取自落库后的消息（@ 列表等已校正），与历史消息一致。
+func pushRoomMessage(room *models.Room, savedMsg *models.Message, packetID, nickname, avatar string) int {
+	members, err := Instance.RoomService.GetRoomMembers(room.ID)
+	if err != nil {
+		log.Printf("Failed to get room members: %v", err)
+		return 0
+	}
+	_ = Instance.ConversationService.SetConversationVisible(room.ID)
+
+	extraBytes := json.RawMessage(savedMsg.Extra)
+	if len(extraBytes) == 0 {
+		extraBytes = json.RawMessage(`{}`)
+	}
+	resp := &message.RoomMessageEvent{
+		PacketID:       packetID,
+		ID:             savedMsg.ID,
+		RoomID:         room.ID,
+		RoomType:       room.Type,
+		SenderID:       savedMsg.SenderID,
+		SenderNickname: nickname,
+		SenderAvatar:   avatar,
+		MsgType:        savedMsg.Type,
+		Content:        savedMsg.Content,
+		Extra:          extraBytes,
+		IsSystem:       savedMsg.IsSystem,
+		RoomSeq:        savedMsg.Seq,
+		SendTime:       savedMsg.CreatedAt.UnixMilli(),
+		CreatedAt:      savedMsg.CreatedAt,
+	}
+	if savedMsg.IsViewOnce {
+		resp.ViewOnce = true
+		resp.Content, resp.Extra = "", json.RawMessage(`{"view_once":true}`)
+	}
+
+	respBytes, _ := message.EncodeEvent(resp)
+	Instance.WsServer.SendToUsers(members, respBytes)
+	Instance.BotService.DispatchToBots(members, savedMsg.SenderID, respBytes)
+	go Instance.RoomStatsService.Record(savedMsg)
+	go Instance.ServerStatsService.RecordMessage(context.Background(), savedMsg.CreatedAt)
+	if Instance.LinkPreviewService != nil && savedMsg.Type == 1 && !savedMsg.IsViewOnce && service.ExtractFirstURL(savedMsg.Content) != "" {
+		go pushLinkPreview(room.ID, savedMsg, members)
+	}
+	return len(members)
+}
+
+// pushStoredMessage 推送服务端生成并已落库的消息（系统消息等）。
+func pushStoredMessage(savedMsg *models.Message) {
+	room, err := Instance.RoomService.GetRoomByID(savedMsg.RoomID)
+	if err != nil {
+		log.Printf("Room not found: %d, error: %v", savedMsg.RoomID, err)
+		return
+	}
+	pushRoomMessage(room, savedMsg, "", "", "")
+}
+
+// pushLinkPreview 异步抓取链接预览，成功后推送 message_update 让客户端刷新链接卡片。
+func pushLinkPreview(roomID uint64, savedMsg *models.Message, members []uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	preview, err := Instance.LinkPreviewService.EnrichMessage(ctx, savedMsg)
+	if err != nil {
+		log.Printf("link preview failed: %v", err)
+		return
 	}
+	if preview == nil {
+		return
+	}
+	b, _ := message.EncodeEvent(&message.MessageUpdatedEvent{RoomID: roomID, MessageID: savedMsg.ID, Extra: json.RawMessage(savedMsg.Extra)})
+	Instance.WsServer.SendToUsers(members, b)
+}
+
+// syncConversationRead 推送 conversation_read 给同一用户的其他连接（多设备已读同步）
+func syncConversationRead(client *Client, roomID, lastRead uint64) {
+	b, _ := message.EncodeEvent(&message.ConversationReadEvent{RoomID: roomID, LastReadMsgID: lastRead})
+	client.hub.sendToUserExcept(client.UserID, client, b)
+}
+
+// relayTyping 按房间订阅把正在输入状态转发给房间内其他在线成员（未订阅该房间即非成员，直接忽略）
+func relayTyping(userID, roomID uint64) {
+	if !Instance.WsServer.InRoom(userID, roomID) {
+		return
+	}
+	b, _ := message.EncodeEvent(&message.TypingEvent{RoomID: roomID, UserID: userID})
+	Instance.WsServer.PublishToRoom(roomID, b, userID)
 }
 
-func sendWsError(userID uint64, msg string, packetID ...string) {
+// sendWsError 推送 error 事件（走控制通道，不编号、不补发），code/reason 见 message.WsErr*
+func sendWsError(userID uint64, evt *message.ErrorEvent) {
 	if Instance == nil || Instance.WsServer == nil {
 		return
 	}
-	payload := map[string]any{"type": "error", "message": msg, "packet_id": packetID[0]}
-	b, _ := json.Marshal(payload)
-	Instance.WsServer.SendToUser(userID, b)
+	b, _ := message.EncodeEvent(evt)
+	Instance.WsServer.SendControl(userID, b)
+}
+
+// sendWsInvalid 上行请求格式错误或缺少必填字段
+func sendWsInvalid(userID uint64, packetID, msg string) {
+	sendWsError(userID, message.NewErrorEvent(message.WsErrInvalidPayload, message.WsErrReasonInvalidPayload, msg, packetID))
+}
+
+// sendWsServiceError 把 service 错误转换为 error 事件推送；被禁言时 data 为 service.MuteError（截止时间），retry_after 为剩余秒数
+func sendWsServiceError(userID uint64, err error, packetID string) {
+	sendWsError(userID, wsErrorEvent(err, packetID))
+}
+
+// wsErrorEvent service 错误 -> error 事件：业务码原样作为 code，已知错误细分 reason
+func wsErrorEvent(err error, packetID string) *message.ErrorEvent {
+	var reason string
+	var se *service.Error
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound):
+		reason = message.WsErrReasonRoomNotFound
+	case errors.Is(err, service.ErrNotRoomMember):
+		reason = message.WsErrReasonNotMember
+	case errors.Is(err, service.ErrPrivateBlocked):
+		reason = message.WsErrReasonBlocked
+	case errors.Is(err, service.ErrHelpDeskClosed):
+		reason = message.WsErrReasonSessionClosed
+	case errors.As(err, &se) && strings.HasPrefix(se.Key, "err.extra_"):
+		reason = message.WsErrReasonInvalidExtra
+	}
+	evt := message.NewErrorEvent(service.ErrorCode(err), reason, err.Error(), packetID)
+	var me *service.MuteError
+	if errors.As(err, &me) {
+		evt.Data = me
+		evt.RetryAfter = me.RetryAfter
+	}
+	return evt
 }
 
 func isRoomMember(roomID, userID uint64) (bool, error) {
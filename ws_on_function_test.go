@@ -0,0 +1,100 @@
+package chat_sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+	"github.com/cydxin/chat-sdk/service"
+)
+
+// conversationReads 取出连接普通队列中的 conversation_read 推送
+func conversationReads(t *testing.T, c *Client) []message.ConversationReadEvent {
+	t.Helper()
+	var out []message.ConversationReadEvent
+	for _, b := range drain(c.send) {
+		var ev message.ConversationReadEvent
+		if err := json.Unmarshal(b, &ev); err != nil {
+			t.Fatalf("decode %s: %v", b, err)
+		}
+		if ev.Type == message.WsEventConversationRead {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// read_ack 把已读游标同步给同一用户的其他设备；上报的设备和其他用户收不到，游标未前进时不重复推送
+func TestReadAck_SyncsOtherDevices(t *testing.T) {
+	e, err := NewTestEngine()
+	if err != nil {
+		t.Fatalf("NewTestEngine: %v", err)
+	}
+	defer e.Close()
+	report, err := e.ProvisionService.Provision(service.ProvisionRequest{Users: []service.ProvisionUser{
+		{Username: "alice"}, {Username: "bob"},
+	}}, false)
+	if err != nil || report.Created != 2 {
+		t.Fatalf("provision: %+v %v", report, err)
+	}
+	aliceID, bobID := report.Rows[0].ID, report.Rows[1].ID
+	room, err := e.RoomService.CreatePrivateRoom(aliceID, bobID)
+	if err != nil {
+		t.Fatalf("CreatePrivateRoom: %v", err)
+	}
+	msg, err := e.MsgService.SaveMessage(room.ID, bobID, "hi", 1, message.Extra{})
+	if err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	h := e.WsServer
+	phone, _ := connectTestClient(t, h, aliceID, "", 0)
+	desktop, _ := connectTestClient(t, h, aliceID, "", 0)
+	tablet, _ := connectTestClient(t, h, aliceID, "", 0)
+	bob, _ := connectTestClient(t, h, bobID, "", 0)
+	readAck := func(c *Client, lastRead uint64) {
+		h.handleMessage(c, []byte(fmt.Sprintf(`{"type":%q,"room_id":%d,"last_read_msg_id":%d}`, message.WsTypeReadAck, room.ID, lastRead)))
+	}
+
+	readAck(phone, msg.ID)
+	for name, c := range map[string]*Client{"desktop": desktop, "tablet": tablet} {
+		evs := conversationReads(t, c)
+		if len(evs) != 1 || evs[0].RoomID != room.ID || evs[0].LastReadMsgID != msg.ID {
+			t.Fatalf("%s conversation_read = %+v", name, evs)
+		}
+	}
+	if evs := conversationReads(t, phone); len(evs) != 0 {
+		t.Fatalf("echoed to reporting device: %+v", evs)
+	}
+	if evs := conversationReads(t, bob); len(evs) != 0 {
+		t.Fatalf("synced to another user: %+v", evs)
+	}
+	if got := phone.session.snapshotRead()[room.ID]; got != msg.ID {
+		t.Fatalf("session read cursor = %d", got)
+	}
+	// 逐条已读回执异步写入，等它完成再继续，避免与引擎关闭交错
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var n int64
+		e.config.DB.Model(&models.MessageStatus{}).Where("message_id = ? AND user_id = ? AND is_read = ?", msg.ID, aliceID, true).Count(&n)
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("read receipt not written")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 其他设备随后上报同一游标或更旧的游标：不回声
+	readAck(desktop, msg.ID)
+	readAck(tablet, msg.ID-1)
+	for name, c := range map[string]*Client{"phone": phone, "desktop": desktop, "tablet": tablet} {
+		if evs := conversationReads(t, c); len(evs) != 0 {
+			t.Fatalf("%s got echo for stale cursor: %+v", name, evs)
+		}
+	}
+}
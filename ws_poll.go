@@ -0,0 +1,144 @@
+package chat_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	// pollQueueSize 每个用户长轮询队列保留的最大事件数（环形，超出丢最旧）
+	pollQueueSize = 256
+
+	// pollDefaultWait 长轮询默认挂起时间
+	pollDefaultWait = 25 * time.Second
+
+	// pollMaxWait 长轮询最大挂起时间
+	pollMaxWait = 60 * time.Second
+
+	// pollQueueIdle 队列多久没有被轮询就回收
+	pollQueueIdle = 2 * time.Minute
+)
+
+// PollEvent 长轮询返回的单个事件。
+// Data 与 WS 推送的消息体完全一致，客户端可复用同一套解析逻辑。
+type PollEvent struct {
+	Seq  uint64          `json:"seq"`
+	Data json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// pollQueue 用户级长轮询队列。
+// 说明：队列在用户第一次轮询时创建，SendToUser 会同时写入 WS 连接和该队列；
+// 没有轮询过的用户不会创建队列，避免给纯 WS 用户增加内存开销。
+type pollQueue struct {
+	mu     sync.Mutex
+	seq    uint64
+	events []PollEvent
+	// wake 有新事件时 close 并替换，用于唤醒挂起的轮询
+	wake     chan struct{}
+	lastPoll time.Time
+}
+
+func newPollQueue() *pollQueue {
+	return &pollQueue{wake: make(chan struct{}), lastPoll: time.Now()}
+}
+
+func (q *pollQueue) push(msg []byte) {
+	data := make([]byte, len(msg))
+	copy(data, msg)
+
+	q.mu.Lock()
+	q.seq++
+	q.events = append(q.events, PollEvent{Seq: q.seq, Data: data})
+	if len(q.events) > pollQueueSize {
+		q.events = q.events[len(q.events)-pollQueueSize:]
+	}
+	close(q.wake)
+	q.wake = make(chan struct{})
+	q.mu.Unlock()
+}
+
+// since 返回 seq > cursor 的事件；无事件时同时返回当前的唤醒 channel。
+func (q *pollQueue) since(cursor uint64) ([]PollEvent, uint64, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastPoll = time.Now()
+
+	// cursor 比当前 seq 还大（服务重启/队列被回收），视为从头开始
+	if cursor > q.seq {
+		cursor = 0
+	}
+	out := make([]PollEvent, 0)
+	for _, e := range q.events {
+		if e.Seq > cursor {
+			out = append(out, e)
+		}
+	}
+	if len(out) > 0 {
+		return out, out[len(out)-1].Seq, nil
+	}
+	return out, q.seq, q.wake
+}
+
+func (q *pollQueue) idleFor() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return time.Since(q.lastPoll)
+}
+
+// enqueuePoll 如果用户存在长轮询队列，则把消息写入队列。
+func (h *WsServer) enqueuePoll(userID uint64, msg []byte) {
+	h.mu.RLock()
+	q := h.pollQueues[userID]
+	h.mu.RUnlock()
+	if q != nil {
+		q.push(msg)
+	}
+}
+
+// Poll 长轮询拉取用户事件：有 seq > cursor 的事件立即返回，否则最多挂起 wait。
+// 返回事件列表与下一次请求应携带的 cursor。
+func (h *WsServer) Poll(ctx context.Context, userID, cursor uint64, wait time.Duration) ([]PollEvent, uint64) {
+	if wait <= 0 {
+		wait = pollDefaultWait
+	}
+	if wait > pollMaxWait {
+		wait = pollMaxWait
+	}
+
+	h.mu.Lock()
+	q := h.pollQueues[userID]
+	if q == nil {
+		q = newPollQueue()
+		h.pollQueues[userID] = q
+	}
+	h.mu.Unlock()
+
+	events, next, wake := q.since(cursor)
+	if len(events) > 0 {
+		return events, next
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-wake:
+		events, next, _ = q.since(cursor)
+		return events, next
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return events, next
+}
+
+// prunePollQueues 回收长时间没有轮询的队列。
+func (h *WsServer) prunePollQueues(idle time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for uid, q := range h.pollQueues {
+		if q.idleFor() >= idle {
+			delete(h.pollQueues, uid)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package chat_sdk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPoll_CursorReset(t *testing.T) {
+	h := NewWsServer()
+	ctx := context.Background()
+
+	// 第一次轮询建队列
+	if events, next := h.Poll(ctx, 1, 0, 10*time.Millisecond); len(events) != 0 || next != 0 {
+		t.Fatalf("first poll: %d events, next %d", len(events), next)
+	}
+	h.enqueuePoll(1, []byte(`{"type":"a"}`))
+	h.enqueuePoll(1, []byte(`{"type":"b"}`))
+
+	// cursor 超过当前 seq（服务重启/队列被回收），从头返回
+	events, next := h.Poll(ctx, 1, 999, time.Second)
+	if len(events) != 2 || events[0].Seq != 1 || events[1].Seq != 2 {
+		t.Fatalf("reset poll: %+v", events)
+	}
+	if next != 2 {
+		t.Fatalf("expected next 2, got %d", next)
+	}
+
+	// 正常 cursor 只返回之后的事件
+	events, next = h.Poll(ctx, 1, 1, time.Second)
+	if len(events) != 1 || string(events[0].Data) != `{"type":"b"}` || next != 2 {
+		t.Fatalf("cursor 1: %+v next %d", events, next)
+	}
+}
+
+func TestPoll_WakeOnMessage(t *testing.T) {
+	h := NewWsServer()
+	ctx := context.Background()
+	h.Poll(ctx, 1, 0, time.Millisecond)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		h.enqueuePoll(1, []byte(`{"type":"message"}`))
+	}()
+
+	start := time.Now()
+	events, next := h.Poll(ctx, 1, 0, 5*time.Second)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("poll not woken, waited %v", elapsed)
+	}
+	if len(events) != 1 || string(events[0].Data) != `{"type":"message"}` {
+		t.Fatalf("woken poll: %+v", events)
+	}
+	if next != 1 {
+		t.Fatalf("expected next 1, got %d", next)
+	}
+}
+
+func TestPoll_TimeoutEmpty(t *testing.T) {
+	h := NewWsServer()
+	wait := 50 * time.Millisecond
+
+	start := time.Now()
+	events, next := h.Poll(context.Background(), 1, 0, wait)
+	if elapsed := time.Since(start); elapsed < wait {
+		t.Fatalf("returned before wait: %v", elapsed)
+	}
+	// 超时返回空切片而不是 nil，JSON 序列化为 []
+	if events == nil || len(events) != 0 {
+		t.Fatalf("expected empty non-nil events, got %#v", events)
+	}
+	if next != 0 {
+		t.Fatalf("expected next 0, got %d", next)
+	}
+
+	// ctx 取消同样立即返回空结果
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	events, _ = h.Poll(ctx, 1, 0, 5*time.Second)
+	if events == nil || len(events) != 0 {
+		t.Fatalf("cancelled poll: %#v", events)
+	}
+}
+
+func TestPoll_QueueCap(t *testing.T) {
+	h := NewWsServer()
+	h.Poll(context.Background(), 1, 0, time.Millisecond)
+	for i := 0; i < pollQueueSize+10; i++ {
+		h.enqueuePoll(1, []byte(fmt.Sprintf(`{"i":%d}`, i)))
+	}
+
+	events, next := h.Poll(context.Background(), 1, 0, time.Millisecond)
+	if len(events) != pollQueueSize {
+		t.Fatalf("expected %d events, got %d", pollQueueSize, len(events))
+	}
+	if events[0].Seq != 11 || next != pollQueueSize+10 {
+		t.Fatalf("oldest seq %d, next %d", events[0].Seq, next)
+	}
+}
+
+func TestPrunePollQueues(t *testing.T) {
+	h := NewWsServer()
+	h.Poll(context.Background(), 1, 0, time.Millisecond)
+	h.Poll(context.Background(), 2, 0, time.Millisecond)
+
+	// 没轮询过的用户不建队列，写入直接丢弃
+	h.enqueuePoll(3, []byte(`{}`))
+
+	q := h.pollQueues[1]
+	q.mu.Lock()
+	q.lastPoll = time.Now().Add(-pollQueueIdle - time.Second)
+	q.mu.Unlock()
+
+	h.prunePollQueues(pollQueueIdle)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, ok := h.pollQueues[1]; ok {
+		t.Fatal("idle queue not pruned")
+	}
+	if _, ok := h.pollQueues[2]; !ok {
+		t.Fatal("active queue pruned")
+	}
+	if _, ok := h.pollQueues[3]; ok {
+		t.Fatal("queue created by enqueue")
+	}
+}
@@ -0,0 +1,118 @@
+package chat_sdk
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+)
+
+const (
+	// wsReplaySize 每个用户保留的可重放事件数（环形，超出丢最旧）
+	wsReplaySize = 256
+
+	// wsResumeWindow 最后一个连接断开后 session 的保留时间，窗口内重连可以续传
+	wsResumeWindow = 5 * time.Minute
+
+	// WsTypeSession 建连后服务端下发的第一条消息（message.SessionEvent）
+	WsTypeSession = message.WsEventSession
+)
+
+// replayBuffer 用户级事件重放缓冲。
+// 说明：SendToUser / SendToUsers 的推送会按用户编号（在 JSON 顶层加 "seq"）并写入缓冲；
+// 正在输入、多端同步等瞬时推送不编号也不重放。token 随 session 创建，session 回收后 seq 重新计数。
+type replayBuffer struct {
+	// mu 同时保护“编号 + 投递到连接”，保证续传时补发与新事件不乱序、不遗漏
+	mu     sync.Mutex
+	token  string
+	seq    uint64
+	events [][]byte // 已编号的事件，events[i] 的 seq 为 seq-len(events)+1+i
+}
+
+func (b *replayBuffer) ensureToken() string {
+	if b.token == "" {
+		var raw [8]byte
+		_, _ = rand.Read(raw[:])
+		b.token = hex.EncodeToString(raw[:])
+	}
+	return b.token
+}
+
+// stamp 给事件编号并写入缓冲（调用方持锁），返回带 seq 的副本；非 JSON 对象原样返回且不缓冲
+func (b *replayBuffer) stamp(msg []byte) []byte {
+	if len(msg) < 2 || msg[0] != '{' {
+		return msg
+	}
+	b.ensureToken()
+	b.seq++
+	prefix := `{"seq":` + strconv.FormatUint(b.seq, 10)
+	out := make([]byte, 0, len(prefix)+len(msg))
+	out = append(out, prefix...)
+	if msg[1] != '}' {
+		out = append(out, ',')
+	}
+	out = append(out, msg[1:]...)
+
+	b.events = append(b.events, out)
+	if len(b.events) > wsReplaySize {
+		b.events = b.events[len(b.events)-wsReplaySize:]
+	}
+	return out
+}
+
+// since 返回 seq > lastEventID 的事件（调用方持锁）；token 不匹配或中间有事件已被丢弃时 ok=false
+func (b *replayBuffer) since(token string, lastEventID uint64) ([][]byte, bool) {
+	if token == "" || token != b.token || lastEventID > b.seq {
+		return nil, false
+	}
+	missed := b.seq - lastEventID
+	if missed > uint64(len(b.events)) {
+		return nil, false
+	}
+	return b.events[uint64(len(b.events))-missed:], true
+}
+
+// deliver 给用户的事件编号并投递到其当前所有连接
+func (h *WsServer) deliver(sess *UserSession, msg []byte) {
+	sess.replay.mu.Lock()
+	defer sess.replay.mu.Unlock()
+	data := sess.replay.stamp(msg)
+
+	// 在 replay.mu 内取连接：续传的连接要么已拿到这条补发，要么已注册能收到这次投递
+	h.mu.RLock()
+	clients := h.userClients[sess.UserID]
+	h.mu.RUnlock()
+	for _, client := range clients {
+		client.enqueue(data)
+	}
+}
+
+// attach 下发 session 握手（能续传时补发断线期间的事件）并把连接注册进 hub
+func (h *WsServer) attach(client *Client, token string, lastEventID uint64) {
+	b := &client.session.replay
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	missed, ok := b.since(token, lastEventID)
+	// 补发量超过连接缓冲时放弃续传，避免丢事件（握手走高优先级通道，不占普通缓冲）
+	if ok && len(missed) > cap(client.send) {
+		missed, ok = nil, false
+	}
+	frame, _ := message.EncodeEvent(&message.SessionEvent{
+		ResumeToken: b.ensureToken(),
+		LastEventID: b.seq,
+		Resumed:     ok,
+		Replayed:    len(missed),
+	})
+	client.sendHigh <- frame
+	for _, e := range missed {
+		client.send <- e
+	}
+	// 等主循环把连接加入 userClients 后再释放 replay.mu，否则期间 deliver 的事件既不在补发里也投递不到该连接
+	client.registered = make(chan struct{})
+	h.register <- client
+	<-client.registered
+}
@@ -0,0 +1,227 @@
+package chat_sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+)
+
+// startTestHub 启动 hub 主循环，测试结束时停止
+func startTestHub(t *testing.T, h *WsServer) {
+	t.Helper()
+	go h.Run()
+	t.Cleanup(h.Stop)
+}
+
+// newHubClient 构造 userID 的连接（不启动 pump）；用户 session 不存在时先创建，和 serveWS 一样在 attach 前放入 h.Sessions
+func newHubClient(h *WsServer, userID uint64, buf int) *Client {
+	h.mu.Lock()
+	sess := h.Sessions[userID]
+	if sess == nil {
+		sess = &UserSession{UserID: userID}
+		h.Sessions[userID] = sess
+	}
+	h.mu.Unlock()
+	c := &Client{
+		hub:      h,
+		send:     make(chan []byte, buf),
+		sendHigh: make(chan []byte, wsHighLaneBuffer),
+		UserID:   userID,
+		session:  sess,
+		rooms:    make(map[uint64]struct{}),
+	}
+	c.lastActive.Store(time.Now().UnixNano())
+	return c
+}
+
+// waitConns 等待 hub 主循环处理完注册/注销，直到用户连接数为 n
+func waitConns(t *testing.T, h *WsServer, userID uint64, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		got := len(h.userClients[userID])
+		h.mu.RUnlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("user %d: %d conns, want %d", userID, got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// drain 取出通道当前积压的全部消息
+func drain(ch chan []byte) [][]byte {
+	var out [][]byte
+	for n := len(ch); n > 0; n-- {
+		out = append(out, <-ch)
+	}
+	return out
+}
+
+// readSessionEvent 取出 attach 下发的握手
+func readSessionEvent(t *testing.T, c *Client) message.SessionEvent {
+	t.Helper()
+	select {
+	case b := <-c.sendHigh:
+		var ev message.SessionEvent
+		if err := json.Unmarshal(b, &ev); err != nil || ev.Type != WsTypeSession {
+			t.Fatalf("handshake %s: %v", b, err)
+		}
+		return ev
+	default:
+		t.Fatal("no handshake")
+		return message.SessionEvent{}
+	}
+}
+
+// eventSeqs 解析事件顶层的 seq
+func eventSeqs(t *testing.T, events [][]byte) []uint64 {
+	t.Helper()
+	seqs := make([]uint64, 0, len(events))
+	for _, b := range events {
+		var v struct {
+			Seq uint64 `json:"seq"`
+		}
+		if err := json.Unmarshal(b, &v); err != nil {
+			t.Fatalf("decode %s: %v", b, err)
+		}
+		seqs = append(seqs, v.Seq)
+	}
+	return seqs
+}
+
+// connectTestClient 新建连接并 attach，返回连接与握手
+func connectTestClient(t *testing.T, h *WsServer, userID uint64, token string, lastEventID uint64) (*Client, message.SessionEvent) {
+	t.Helper()
+	h.mu.RLock()
+	n := len(h.userClients[userID])
+	h.mu.RUnlock()
+	c := newHubClient(h, userID, DefaultWsLimits.SendBuffer)
+	h.attach(c, token, lastEventID)
+	waitConns(t, h, userID, n+1)
+	return c, readSessionEvent(t, c)
+}
+
+// disconnectTestClient 注销连接（session 在续传窗口内保留）
+func disconnectTestClient(t *testing.T, h *WsServer, c *Client) {
+	t.Helper()
+	h.mu.RLock()
+	n := len(h.userClients[c.UserID])
+	h.mu.RUnlock()
+	h.unregister <- c
+	waitConns(t, h, c.UserID, n-1)
+}
+
+func pushN(h *WsServer, userID uint64, n int) {
+	for i := 0; i < n; i++ {
+		h.SendToUser(userID, []byte(fmt.Sprintf(`{"type":"message","i":%d}`, i)))
+	}
+}
+
+// 断线窗口内重连：补发 last_event_id 之后的事件，之后的新事件正常编号
+func TestResume_WithinWindow(t *testing.T) {
+	h := NewWsServer()
+	startTestHub(t, h)
+
+	c1, hello := connectTestClient(t, h, 1, "", 0)
+	if hello.Resumed || hello.LastEventID != 0 || hello.ResumeToken == "" {
+		t.Fatalf("first handshake: %+v", hello)
+	}
+	pushN(h, 1, 2)
+	if got := eventSeqs(t, drain(c1.send)); fmt.Sprint(got) != "[1 2]" {
+		t.Fatalf("live seqs = %v", got)
+	}
+
+	disconnectTestClient(t, h, c1)
+	pushN(h, 1, 3)
+
+	c2, ev := connectTestClient(t, h, 1, hello.ResumeToken, 2)
+	if !ev.Resumed || ev.Replayed != 3 || ev.LastEventID != 5 || ev.ResumeToken != hello.ResumeToken {
+		t.Fatalf("resume handshake: %+v", ev)
+	}
+	if got := eventSeqs(t, drain(c2.send)); fmt.Sprint(got) != "[3 4 5]" {
+		t.Fatalf("replayed seqs = %v", got)
+	}
+	pushN(h, 1, 1)
+	if got := eventSeqs(t, drain(c2.send)); fmt.Sprint(got) != "[6]" {
+		t.Fatalf("after resume seqs = %v", got)
+	}
+}
+
+// 超出重放缓冲或 token 不匹配时不续传：握手 resumed=false，不补发任何事件，客户端应全量同步
+func TestResume_BeyondWindow(t *testing.T) {
+	h := NewWsServer()
+	startTestHub(t, h)
+
+	c1, hello := connectTestClient(t, h, 1, "", 0)
+	disconnectTestClient(t, h, c1)
+	pushN(h, 1, wsReplaySize+1)
+
+	c2, ev := connectTestClient(t, h, 1, hello.ResumeToken, 0)
+	if ev.Resumed || ev.Replayed != 0 || ev.LastEventID != wsReplaySize+1 {
+		t.Fatalf("overflow handshake: %+v", ev)
+	}
+	if n := len(c2.send); n != 0 {
+		t.Fatalf("replayed %d events without resume", n)
+	}
+
+	// 缓冲内的游标可以续传，但 token 不匹配（session 已回收重建）同样不续传
+	c3, ev := connectTestClient(t, h, 1, "stale-token", wsReplaySize)
+	if ev.Resumed || ev.Replayed != 0 || len(c3.send) != 0 {
+		t.Fatalf("stale token handshake: %+v queued=%d", ev, len(c3.send))
+	}
+	// 游标超过当前 seq 同样视为无效
+	c4, ev := connectTestClient(t, h, 1, hello.ResumeToken, wsReplaySize+10)
+	if ev.Resumed || len(c4.send) != 0 {
+		t.Fatalf("future cursor handshake: %+v", ev)
+	}
+}
+
+// 重复抑制：已确认（seq <= last_event_id）的事件不再补发；补发与注册之间的新事件不重复、不遗漏
+func TestResume_NoDuplicates(t *testing.T) {
+	h := NewWsServer()
+	startTestHub(t, h)
+
+	c1, hello := connectTestClient(t, h, 1, "", 0)
+	pushN(h, 1, 4)
+	drain(c1.send)
+	disconnectTestClient(t, h, c1)
+
+	// 已是最新游标：续传成功但没有要补发的
+	c2, ev := connectTestClient(t, h, 1, hello.ResumeToken, 4)
+	if !ev.Resumed || ev.Replayed != 0 || len(c2.send) != 0 {
+		t.Fatalf("up-to-date handshake: %+v queued=%d", ev, len(c2.send))
+	}
+	disconnectTestClient(t, h, c2)
+
+	// 续传与并发推送交错：每个 seq 恰好收到一次
+	pushN(h, 1, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pushN(h, 1, 20)
+	}()
+	c3, ev := connectTestClient(t, h, 1, hello.ResumeToken, 3)
+	<-done
+	if !ev.Resumed {
+		t.Fatalf("resume handshake: %+v", ev)
+	}
+	seqs := eventSeqs(t, drain(c3.send))
+	if len(seqs) == 0 || seqs[0] != 4 {
+		t.Fatalf("first replayed seq = %v, want 4", seqs)
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] != seqs[i-1]+1 {
+			t.Fatalf("seqs not contiguous: %v", seqs)
+		}
+	}
+	if last := seqs[len(seqs)-1]; last != 26 {
+		t.Fatalf("last seq %d, want 26 (%v)", last, seqs)
+	}
+}
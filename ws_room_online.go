@@ -0,0 +1,141 @@
+package chat_sdk
+
+import (
+	"log"
+	"sort"
+
+	"github.com/cydxin/chat-sdk/message"
+	"github.com/cydxin/chat-sdk/models"
+)
+
+// roomOnlineListMax 在线成员列表最多返回的人数（人数仍按全部在线成员统计）
+const roomOnlineListMax = 200
+
+// RoomOnlineMember 房间内当前在线的成员
+type RoomOnlineMember struct {
+	UserID   uint64 `json:"user_id"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	Presence uint8  `json:"presence"` // 1-在线 2-离开
+}
+
+// RoomOnline 房间在线成员（本实例的 WS 连接，隐身用户不计入）
+type RoomOnline struct {
+	RoomID  uint64             `json:"room_id"`
+	Online  int                `json:"online"`
+	Members []RoomOnlineMember `json:"members"`
+}
+
+// roomOnlineSessionsLocked 订阅了房间的在线用户 session（去重、排除隐身），调用方持 h.mu
+func (h *WsServer) roomOnlineSessionsLocked(roomID uint64) []*UserSession {
+	subs := h.rooms[roomID]
+	seen := make(map[uint64]struct{}, len(subs))
+	out := make([]*UserSession, 0, len(subs))
+	for client := range subs {
+		sess := client.session
+		if sess == nil || sess.invisible.Load() {
+			continue
+		}
+		if _, ok := seen[sess.UserID]; ok {
+			continue
+		}
+		seen[sess.UserID] = struct{}{}
+		out = append(out, sess)
+	}
+	return out
+}
+
+// RoomOnline 房间当前在线的成员：在线在前、离开在后，同状态按用户 ID 升序，最多 limit 个（<=0 或超过上限时取 roomOnlineListMax）
+func (h *WsServer) RoomOnline(roomID uint64, limit int) RoomOnline {
+	if limit <= 0 || limit > roomOnlineListMax {
+		limit = roomOnlineListMax
+	}
+	h.mu.RLock()
+	sessions := h.roomOnlineSessionsLocked(roomID)
+	members := make([]RoomOnlineMember, 0, len(sessions))
+	for _, sess := range sessions {
+		members = append(members, RoomOnlineMember{
+			UserID:   sess.UserID,
+			Nickname: sess.Nickname,
+			Avatar:   sess.Avatar,
+			Presence: uint8(sess.presence.Load()),
+		})
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Presence != members[j].Presence {
+			return members[i].Presence == models.OnlineStatusOnline
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	out := RoomOnline{RoomID: roomID, Online: len(members), Members: members}
+	if len(out.Members) > limit {
+		out.Members = out.Members[:limit]
+	}
+	return out
+}
+
+// markRoomOnlineDirtyLocked 记录在线人数可能变化的房间（订阅增减、隐身切换），由 flushRoomOnline 合并推送；
+// 未开启推送时不记录。调用方持 h.mu 写锁
+func (h *WsServer) markRoomOnlineDirtyLocked(roomID uint64) {
+	if h.roomOnlineEvery > 0 {
+		h.onlineDirty[roomID] = struct{}{}
+	}
+}
+
+// SetAppearOffline 用户切换隐身后更新其 session，隐身用户不出现在房间在线列表中
+func (h *WsServer) SetAppearOffline(userID uint64, on bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sess := h.Sessions[userID]
+	if sess == nil || sess.invisible.Swap(on) == on {
+		return
+	}
+	for _, client := range h.userClients[userID] {
+		for roomID := range client.rooms {
+			h.markRoomOnlineDirtyLocked(roomID)
+		}
+	}
+}
+
+// flushRoomOnline 对在线人数可能变化的房间重新计数，与上次推送不同时向房间内在线连接推送 room_online
+func (h *WsServer) flushRoomOnline() {
+	type pending struct {
+		roomID  uint64
+		online  int
+		clients []*Client
+	}
+	var out []pending
+	h.mu.Lock()
+	for roomID := range h.onlineDirty {
+		delete(h.onlineDirty, roomID)
+		subs := h.rooms[roomID]
+		if len(subs) == 0 {
+			delete(h.onlineLast, roomID)
+			continue
+		}
+		n := len(h.roomOnlineSessionsLocked(roomID))
+		if last, ok := h.onlineLast[roomID]; ok && last == n {
+			continue
+		}
+		h.onlineLast[roomID] = n
+		clients := make([]*Client, 0, len(subs))
+		for client := range subs {
+			clients = append(clients, client)
+		}
+		out = append(out, pending{roomID: roomID, online: n, clients: clients})
+	}
+	h.mu.Unlock()
+
+	for _, p := range out {
+		b, err := message.EncodeEvent(&message.RoomOnlineEvent{RoomID: p.roomID, Online: p.online})
+		if err != nil {
+			log.Printf("encode room_online: %v", err)
+			continue
+		}
+		for _, client := range p.clients {
+			client.enqueue(b)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package chat_sdk
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cydxin/chat-sdk/message"
+)
+
+// joinRoomClient 注册一个订阅了 roomIDs 的连接
+func joinRoomClient(t *testing.T, h *WsServer, userID uint64, roomIDs ...uint64) *Client {
+	t.Helper()
+	h.mu.RLock()
+	n := len(h.userClients[userID])
+	h.mu.RUnlock()
+	c := newHubClient(h, userID, 16)
+	for _, id := range roomIDs {
+		c.rooms[id] = struct{}{}
+	}
+	h.attach(c, "", 0)
+	waitConns(t, h, userID, n+1)
+	readSessionEvent(t, c)
+	return c
+}
+
+// roomOnlineEvents 取出连接普通队列中的 room_online 推送
+func roomOnlineEvents(t *testing.T, c *Client) []message.RoomOnlineEvent {
+	t.Helper()
+	var out []message.RoomOnlineEvent
+	for _, b := range drain(c.send) {
+		var ev message.RoomOnlineEvent
+		if err := json.Unmarshal(b, &ev); err != nil {
+			t.Fatalf("decode %s: %v", b, err)
+		}
+		if ev.Type == message.WsEventRoomOnline {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// 一个间隔内的多次变化合并为一次推送；人数未变时不推送
+func TestFlushRoomOnline_Coalesces(t *testing.T) {
+	h := NewWsServer()
+	// 间隔足够长，由测试手动 flush
+	h.roomOnlineEvery = time.Hour
+	startTestHub(t, h)
+
+	a := joinRoomClient(t, h, 1, 10)
+	b := joinRoomClient(t, h, 2, 10, 20)
+	h.flushRoomOnline()
+	if evs := roomOnlineEvents(t, a); len(evs) != 1 || evs[0].RoomID != 10 || evs[0].Online != 2 {
+		t.Fatalf("user 1 room_online = %+v", evs)
+	}
+	// b 还单独在房间 20，按房间各推一次
+	if got := onlineByRoom(roomOnlineEvents(t, b)); len(got) != 2 || got[10] != 2 || got[20] != 1 {
+		t.Fatalf("user 2 room_online = %v", got)
+	}
+
+	// 没有变化：不推送
+	h.flushRoomOnline()
+	if evs := roomOnlineEvents(t, a); len(evs) != 0 {
+		t.Fatalf("flush without changes pushed %+v", evs)
+	}
+
+	// 同一用户的第二个设备：房间被标记但在线人数不变，不推送，新设备也不会收到
+	a2 := joinRoomClient(t, h, 1, 10)
+	h.flushRoomOnline()
+	for _, c := range []*Client{a, a2, b} {
+		if evs := roomOnlineEvents(t, c); len(evs) != 0 {
+			t.Fatalf("unchanged count pushed %+v", evs)
+		}
+	}
+
+	// 隐身再取消：间隔内来回切换，人数最终未变，不推送
+	h.SetAppearOffline(2, true)
+	h.SetAppearOffline(2, false)
+	h.flushRoomOnline()
+	if evs := roomOnlineEvents(t, a); len(evs) != 0 {
+		t.Fatalf("toggle pushed %+v", evs)
+	}
+
+	// 隐身：人数减少，推送给房间内所有在线连接（含隐身用户自己）
+	h.SetAppearOffline(2, true)
+	h.flushRoomOnline()
+	for _, c := range []*Client{a, a2} {
+		if evs := roomOnlineEvents(t, c); len(evs) != 1 || evs[0].RoomID != 10 || evs[0].Online != 1 {
+			t.Fatalf("user 1 after invisible = %+v", evs)
+		}
+	}
+	if got := onlineByRoom(roomOnlineEvents(t, b)); len(got) != 2 || got[10] != 1 || got[20] != 0 {
+		t.Fatalf("user 2 after invisible = %v", got)
+	}
+}
+
+func onlineByRoom(evs []message.RoomOnlineEvent) map[uint64]int {
+	out := make(map[uint64]int, len(evs))
+	for _, ev := range evs {
+		out[ev.RoomID] = ev.Online
+	}
+	return out
+}
+
+// 房间最后一个订阅断开后清理计数，不再向任何人推送
+func TestFlushRoomOnline_RoomEmptied(t *testing.T) {
+	h := NewWsServer()
+	h.roomOnlineEvery = time.Hour
+	startTestHub(t, h)
+
+	a := joinRoomClient(t, h, 1, 10)
+	h.flushRoomOnline()
+	roomOnlineEvents(t, a)
+
+	disconnectTestClient(t, h, a)
+	h.flushRoomOnline()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, ok := h.onlineLast[10]; ok {
+		t.Fatal("emptied room count kept")
+	}
+	if len(h.onlineDirty) != 0 {
+		t.Fatalf("dirty rooms left: %v", h.onlineDirty)
+	}
+}
+
+// 未开启推送时不记录待刷新的房间
+func TestFlushRoomOnline_Disabled(t *testing.T) {
+	h := NewWsServer()
+	startTestHub(t, h)
+
+	a := joinRoomClient(t, h, 1, 10)
+	h.SetAppearOffline(1, true)
+	h.flushRoomOnline()
+	h.mu.RLock()
+	dirty := len(h.onlineDirty)
+	h.mu.RUnlock()
+	if dirty != 0 {
+		t.Fatalf("dirty rooms recorded while disabled: %d", dirty)
+	}
+	if evs := roomOnlineEvents(t, a); len(evs) != 0 {
+		t.Fatalf("pushed while disabled: %+v", evs)
+	}
+}
@@ -0,0 +1,150 @@
+package chat_sdk
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WsDropPolicy 连接发送缓冲（WsLimits.SendBuffer）已满时的处理方式
+type WsDropPolicy uint8
+
+const (
+	// WsDropNewest 丢弃当前这条（默认）
+	WsDropNewest WsDropPolicy = iota
+	// WsDropOldest 丢弃缓冲中最旧的一条再写入，慢连接优先收到最新状态
+	WsDropOldest
+	// WsDropDisconnect 断开该连接（close code WsCloseSlow），客户端重连后可通过续传补齐
+	WsDropDisconnect
+)
+
+const (
+	// WsCloseSlow 连接因发送缓冲积压被服务端断开时的 close code，客户端可直接重连
+	WsCloseSlow = 4001
+
+	// wsBroadcastQueue 待扇出的全员广播条数，超出时 Broadcast 返回 false
+	wsBroadcastQueue = 64
+
+	// wsHighLaneBuffer 每个连接高优先级队列的缓冲条数（控制类消息量小，不随 SendBuffer 配置）
+	wsHighLaneBuffer = 32
+)
+
+// enqueue 写入连接的普通发送队列（聊天消息、房间事件等），缓冲满时按 DropPolicy 处理；返回是否写入。
+// 与 closeSend 共用 sendMu，连接注销后再写入直接丢弃，不会向已关闭的 channel 发送。
+func (c *Client) enqueue(msg []byte) bool {
+	return c.enqueueLane(c.send, msg)
+}
+
+// enqueueHigh 写入连接的高优先级队列（握手、心跳回包、错误、系统广播），writePump 优先发送，不被积压的聊天消息阻塞
+func (c *Client) enqueueHigh(msg []byte) bool {
+	return c.enqueueLane(c.sendHigh, msg)
+}
+
+func (c *Client) enqueueLane(lane chan []byte, msg []byte) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.sendClosed {
+		return false
+	}
+	select {
+	case lane <- msg:
+		return true
+	default:
+	}
+
+	switch c.hub.limits.DropPolicy {
+	case WsDropOldest:
+		select {
+		case <-lane:
+		default:
+		}
+		select {
+		case lane <- msg:
+			return true
+		default:
+		}
+	case WsDropDisconnect:
+		c.closeSlow()
+	}
+	return false
+}
+
+// closeSend 关闭两个发送队列（注销连接时调用），writePump 发完剩余消息后发送 close 帧退出
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.sendHigh)
+		close(c.send)
+	}
+}
+
+// closeSlow 断开积压的连接（只执行一次）；关闭底层连接后 readPump 退出并走正常注销流程
+func (c *Client) closeSlow() {
+	if !c.slowClosing.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("ws send buffer full, disconnect user=%d conn=%s", c.UserID, c.SessionID)
+	go func() {
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(WsCloseSlow, "send buffer full"), time.Now().Add(c.hub.limits.WriteWait))
+		_ = c.conn.Close()
+	}()
+}
+
+// Broadcast 向本实例所有 WS 连接广播（系统公告、全局配置变更等），不编号、不补发、不投递长轮询队列。
+// 消息进入有界队列后由主循环扇出，队列已满时直接返回 false（调用方自行限流或重试）。
+func (h *WsServer) Broadcast(msg []byte) bool {
+	select {
+	case h.broadcast <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// fanout 把广播写入每个连接的高优先级队列
+func (h *WsServer) fanout(msg []byte) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.enqueueHigh(msg)
+	}
+}
+
+// SendControl 向用户当前所有连接的高优先级队列推送控制类消息（如上行请求的错误回包）。
+// 与 SendToUser 不同：不编号、不补发、不投递长轮询队列，只对在线连接有意义。
+func (h *WsServer) SendControl(userID uint64, msg []byte) {
+	h.mu.RLock()
+	clients := h.userClients[userID]
+	h.mu.RUnlock()
+
+	if h.tap != nil {
+		h.tap(userID, msg)
+	}
+	for _, client := range clients {
+		client.enqueueHigh(msg)
+	}
+}
+
+// QueueStats 本实例 WS 队列占用率：各连接发送队列（两个通道合计）的平均占用率、全员广播队列占用率（0~1，过载检测用）
+func (h *WsServer) QueueStats() (sendFill, broadcastFill float64) {
+	h.mu.RLock()
+	var queued, capacity int
+	for client := range h.clients {
+		queued += len(client.send) + len(client.sendHigh)
+		capacity += cap(client.send) + cap(client.sendHigh)
+	}
+	h.mu.RUnlock()
+	if capacity > 0 {
+		sendFill = float64(queued) / float64(capacity)
+	}
+	return sendFill, float64(len(h.broadcast)) / float64(cap(h.broadcast))
+}
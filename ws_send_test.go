@@ -0,0 +1,180 @@
+package chat_sdk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsPipe 建一对真实的 websocket 连接，返回服务端与客户端两端
+func wsPipe(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(srv.Close)
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	server = <-accepted
+	t.Cleanup(func() { _ = server.Close() })
+	return server, client
+}
+
+// fillClient 注册一个普通缓冲为 buf 的在线连接，并取走握手
+func fillClient(t *testing.T, h *WsServer, userID uint64, buf int) *Client {
+	t.Helper()
+	c := newHubClient(h, userID, buf)
+	h.attach(c, "", 0)
+	waitConns(t, h, userID, 1)
+	readSessionEvent(t, c)
+	return c
+}
+
+func lanePayloads(ch chan []byte) []string {
+	var out []string
+	for _, b := range drain(ch) {
+		out = append(out, string(b))
+	}
+	return out
+}
+
+// 默认策略：普通队列满时丢弃新消息，连接保持在线，高优先级队列不受影响
+func TestEnqueue_DropNewestKeepsClient(t *testing.T) {
+	h := NewWsServer()
+	startTestHub(t, h)
+	c := fillClient(t, h, 1, 2)
+
+	for i := 0; i < 5; i++ {
+		if ok := c.enqueue([]byte(fmt.Sprintf("m%d", i))); ok != (i < 2) {
+			t.Fatalf("enqueue m%d = %v", i, ok)
+		}
+	}
+	if c.slowClosing.Load() {
+		t.Fatal("client disconnected under WsDropNewest")
+	}
+	if !c.enqueueHigh([]byte("ctl")) {
+		t.Fatal("high lane blocked by full send buffer")
+	}
+	waitConns(t, h, 1, 1)
+	if got := lanePayloads(c.send); fmt.Sprint(got) != "[m0 m1]" {
+		t.Fatalf("queued = %v", got)
+	}
+
+	// 队列腾出空间后恢复投递
+	if !c.enqueue([]byte("m5")) {
+		t.Fatal("enqueue after drain dropped")
+	}
+}
+
+// WsDropOldest：丢弃最旧的一条，慢连接保留最新消息且不断开
+func TestEnqueue_DropOldestKeepsClient(t *testing.T) {
+	h := NewWsServer()
+	h.limits.DropPolicy = WsDropOldest
+	startTestHub(t, h)
+	c := fillClient(t, h, 1, 2)
+
+	for i := 0; i < 5; i++ {
+		if !c.enqueue([]byte(fmt.Sprintf("m%d", i))) {
+			t.Fatalf("enqueue m%d dropped", i)
+		}
+	}
+	if c.slowClosing.Load() {
+		t.Fatal("client disconnected under WsDropOldest")
+	}
+	waitConns(t, h, 1, 1)
+	if got := lanePayloads(c.send); fmt.Sprint(got) != "[m3 m4]" {
+		t.Fatalf("queued = %v", got)
+	}
+}
+
+// 推送经由 SendToUser 时同样只丢消息：编号照常递增，续传仍可补齐被丢的事件
+func TestSendToUser_FullBufferDropsWithoutDisconnect(t *testing.T) {
+	h := NewWsServer()
+	startTestHub(t, h)
+	c := fillClient(t, h, 1, 2)
+
+	pushN(h, 1, 5)
+	if c.slowClosing.Load() {
+		t.Fatal("client disconnected")
+	}
+	waitConns(t, h, 1, 1)
+	if got := eventSeqs(t, drain(c.send)); fmt.Sprint(got) != "[1 2]" {
+		t.Fatalf("delivered seqs = %v", got)
+	}
+	c.session.replay.mu.Lock()
+	missed, ok := c.session.replay.since(c.session.replay.token, 2)
+	c.session.replay.mu.Unlock()
+	if !ok || len(missed) != 3 {
+		t.Fatalf("dropped events not replayable: ok=%v n=%d", ok, len(missed))
+	}
+}
+
+// WsDropDisconnect：缓冲满时以 WsCloseSlow 断开，只断开一次
+func TestEnqueue_DropDisconnect(t *testing.T) {
+	h := NewWsServer()
+	h.limits.DropPolicy = WsDropDisconnect
+	server, peer := wsPipe(t)
+	c := newHubClient(h, 1, 1)
+	c.conn = server
+
+	if !c.enqueue([]byte("m0")) {
+		t.Fatal("first enqueue dropped")
+	}
+	if c.enqueue([]byte("m1")) || c.enqueue([]byte("m2")) {
+		t.Fatal("enqueue into full buffer succeeded")
+	}
+	if !c.slowClosing.Load() {
+		t.Fatal("slow client not marked for disconnect")
+	}
+	_ = peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := peer.ReadMessage()
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != WsCloseSlow {
+		t.Fatalf("close = %v, want code %d", err, WsCloseSlow)
+	}
+}
+
+// 高优先级消息先于已积压的普通消息写出，即使普通消息先入队
+func TestWritePump_HighLaneFirst(t *testing.T) {
+	h := NewWsServer()
+	server, peer := wsPipe(t)
+	c := newHubClient(h, 1, 8)
+	c.conn = server
+
+	for i := 0; i < 4; i++ {
+		c.enqueue([]byte(fmt.Sprintf("[low%d]", i)))
+	}
+	c.enqueueHigh([]byte("[high0]"))
+	c.enqueueHigh([]byte("[high1]"))
+	c.closeSend()
+	go c.writePump()
+
+	// writeBatch 会把积压消息合并成一帧，按写出顺序拼接后比较
+	var wire strings.Builder
+	_ = peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, b, err := peer.ReadMessage()
+		if err != nil {
+			break
+		}
+		wire.Write(b)
+	}
+	if got, want := wire.String(), "[high0][high1][low0][low1][low2][low3]"; got != want {
+		t.Fatalf("wire order = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package chat_sdk
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cydxin/chat-sdk/service"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WsCloseRevoked 连接绑定的 token 被注销（登出、封禁）时的 close code，客户端收到后不应自动重连
+	WsCloseRevoked = 4002
+	// WsCloseSwitched 设备切换到其他账号时断开旧账号连接的 close code，客户端用新账号的 token 重连
+	WsCloseSwitched = 4003
+)
+
+// wsBindToken 确定连接要绑定的 token：
+// - 显式传入的 token 必须有效且属于 userID，否则拒绝建连；
+// - 未传入时取请求中的 token，能在 AuthService 中核对到 userID 才绑定（自定义鉴权的 token 不绑定）。
+func wsBindToken(r *http.Request, token string, userID uint64) (string, bool) {
+	if Instance == nil || Instance.AuthService == nil {
+		return "", token == ""
+	}
+	auth := Instance.AuthService
+	explicit := token != ""
+	if !explicit {
+		token = auth.ExtractToken(r)
+		if token == "" {
+			return "", true
+		}
+	}
+	uid, err := auth.Authenticate(r.Context(), token)
+	if err != nil || uid != userID {
+		return "", !explicit
+	}
+	return token, true
+}
+
+// closeBound 绑定的 token 注销或设备切换账号后断开连接（只执行一次）；关闭底层连接后 readPump 退出并走正常注销流程
+func (c *Client) closeBound(reason service.ConnCloseReason) {
+	if !c.boundClosing.CompareAndSwap(false, true) {
+		return
+	}
+	code, text := WsCloseRevoked, "token revoked"
+	if reason == service.ConnCloseSwitched {
+		code, text = WsCloseSwitched, "account switched"
+	}
+	log.Printf("ws %s, disconnect user=%d conn=%s", text, c.UserID, c.SessionID)
+	go func() {
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, text), time.Now().Add(c.hub.limits.WriteWait))
+		_ = c.conn.Close()
+	}()
+}